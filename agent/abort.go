@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/tools"
+)
+
+// ErrTaskAborted means the user aborted the task after it was saved for resuming
+var ErrTaskAborted = errors.New("task aborted")
+
+// abortedDirName is the state subdirectory holding snapshots of aborted tasks
+const abortedDirName = "aborted"
+
+// TaskSnapshot is an aborted task, captured with everything needed to
+// continue it exactly where it stopped
+type TaskSnapshot struct {
+	ConversationState
+	PendingToolCalls []api.ToolCall   `json:"pending_tool_calls,omitempty"` // requested by the model but not yet run
+	Todos            []tools.TodoItem `json:"todos"`
+	Iteration        int              `json:"iteration"`
+	Provider         string           `json:"provider"`
+	Model            string           `json:"model"`
+	Journal          []JournalEntry   `json:"journal,omitempty"` // file contents before each change, for /undo
+	Reason           string           `json:"reason,omitempty"`  // why the task stopped, when it wasn't aborted by the user
+}
+
+// abortedDir returns the directory for aborted task snapshots
+func abortedDir() (string, error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(stateDir, abortedDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create aborted task directory: %w", err)
+	}
+	return dir, nil
+}
+
+// SaveAbortedTask snapshots the in-flight conversation, todos and pending tool
+// calls, returning the snapshot's path
+func (a *Agent) SaveAbortedTask() (string, error) {
+	return a.saveTaskSnapshot("")
+}
+
+// saveTaskSnapshot snapshots the task, noting why it stopped
+func (a *Agent) saveTaskSnapshot(reason string) (string, error) {
+	dir, err := abortedDir()
+	if err != nil {
+		return "", err
+	}
+	sessionID := a.sessionID
+	if sessionID == "" {
+		sessionID = NewSessionID()
+	}
+
+	snapshot := TaskSnapshot{
+		ConversationState: ConversationState{
+			Messages:          a.messages,
+			TaskActions:       a.taskActions,
+			TotalCost:         a.totalCost,
+			TotalTokens:       a.totalTokens,
+			PromptTokens:      a.promptTokens,
+			CompletionTokens:  a.completionTokens,
+			CachedTokens:      a.cachedTokens,
+			CachedCostSavings: a.cachedCostSavings,
+			LastUpdated:       time.Now(),
+			SessionID:         sessionID,
+		},
+		PendingToolCalls: a.pendingToolCalls,
+		Todos:            tools.GetAllTodos(),
+		Iteration:        a.currentIteration,
+		Provider:         a.GetProvider(),
+		Model:            a.GetModel(),
+		Journal:          a.changeJournal,
+		Reason:           reason,
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal task snapshot: %w", err)
+	}
+
+	path := filepath.Join(dir, sessionID+".json")
+	if err := config.WriteFileAtomic(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to save task snapshot: %w", err)
+	}
+	return path, nil
+}
+
+// LoadAbortedTask loads the snapshot of an aborted task by session ID, or the
+// most recently aborted task when sessionID is empty
+func LoadAbortedTask(sessionID string) (*TaskSnapshot, string, error) {
+	dir, err := abortedDir()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var paths []string
+	if sessionID != "" {
+		paths = []string{filepath.Join(dir, sessionID+".json")}
+	} else if paths, err = filepath.Glob(filepath.Join(dir, "*.json")); err != nil {
+		return nil, "", fmt.Errorf("failed to list aborted tasks: %w", err)
+	}
+
+	var latest *TaskSnapshot
+	var latestPath string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if sessionID != "" {
+				return nil, "", fmt.Errorf("no aborted task for session %s: %w", sessionID, err)
+			}
+			continue
+		}
+		var snapshot TaskSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			if sessionID != "" {
+				return nil, "", fmt.Errorf("failed to parse task snapshot: %w", err)
+			}
+			continue
+		}
+		if latest == nil || snapshot.LastUpdated.After(latest.LastUpdated) {
+			latest, latestPath = &snapshot, path
+		}
+	}
+	if latest == nil {
+		return nil, "", fmt.Errorf("no aborted task to resume")
+	}
+	return latest, latestPath, nil
+}
+
+// ResumeAbortedTask restores an aborted task (the latest when sessionID is
+// empty), re-issues the tool calls it was stopped before, and continues the
+// conversation loop. The snapshot is consumed; aborting again saves a new one.
+func (a *Agent) ResumeAbortedTask(sessionID string) (string, error) {
+	snapshot, path, err := LoadAbortedTask(sessionID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("failed to remove task snapshot: %w", err)
+	}
+
+	a.ApplyState(&snapshot.ConversationState)
+	tools.RestoreTodos(snapshot.Todos)
+	a.changeJournal = snapshot.Journal
+	a.currentIteration = snapshot.Iteration
+	a.pendingToolCalls = snapshot.PendingToolCalls
+	a.pairedPaused = false
+	a.ClearInterrupt()
+
+	fmt.Printf("▶️  Resuming task aborted %s (iteration %d, %s)\n",
+		snapshot.LastUpdated.Format("2006-01-02 15:04"), snapshot.Iteration, snapshot.Model)
+	if snapshot.Reason != "" {
+		fmt.Printf("💥 It stopped on: %s\n", firstLine(snapshot.Reason))
+	}
+	if len(a.pendingToolCalls) > 0 {
+		names := make([]string, len(a.pendingToolCalls))
+		for i, call := range a.pendingToolCalls {
+			names[i] = call.Function.Name
+		}
+		fmt.Printf("🔁 Re-issuing %d interrupted tool call(s): %s\n", len(names), strings.Join(names, ", "))
+	}
+	return a.runConversation()
+}
+
+// runPendingToolCalls runs the tool calls an interrupt held back
+func (a *Agent) runPendingToolCalls() {
+	if pending := a.pendingToolCalls; len(pending) > 0 {
+		a.pendingToolCalls = nil
+		a.executeToolCalls(pending)
+	}
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestAbortAndResume(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"expect": "Tool call result for write_file", "content": "Done: notes.txt is written and the file was verified."}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	tools.ClearTodos()
+	defer tools.ClearTodos()
+
+	if _, _, err := LoadAbortedTask(""); err == nil {
+		t.Fatal("expected no aborted task before one is saved")
+	}
+
+	// Esc arrives while the model's tool calls are about to run
+	notes := "notes.txt"
+	agent.messages = []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "Write the notes"},
+	}
+	agent.currentIteration = 4
+	tools.AddTodo("Write the notes", "", "high")
+	agent.escPressed <- true
+	call := api.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "write_file"
+	call.Function.Arguments = `{"file_path": "notes.txt", "content": "hello\n"}`
+	agent.executeToolCalls([]api.ToolCall{call})
+	if len(agent.pendingToolCalls) != 1 || len(agent.messages) != 2 {
+		t.Fatalf("the interrupted call shouldn't run: pending %d, messages %d", len(agent.pendingToolCalls), len(agent.messages))
+	}
+	if _, err := os.Stat(notes); err == nil {
+		t.Fatal("the interrupted call wrote its file")
+	}
+
+	if _, err := agent.SaveAbortedTask(); err != nil {
+		t.Fatalf("SaveAbortedTask: %v", err)
+	}
+	snapshot, _, err := LoadAbortedTask("")
+	if err != nil || snapshot.Iteration != 4 || len(snapshot.Todos) != 1 || len(snapshot.PendingToolCalls) != 1 {
+		t.Fatalf("snapshot: %+v, %v", snapshot, err)
+	}
+
+	// Resuming in a fresh state restores everything and re-issues the call
+	agent.messages = nil
+	agent.pendingToolCalls = nil
+	agent.ClearInterrupt()
+	tools.ClearTodos()
+	result, err := agent.ResumeAbortedTask("")
+	if err != nil {
+		t.Fatalf("ResumeAbortedTask: %v", err)
+	}
+	if !strings.Contains(result, "notes.txt is written") {
+		t.Errorf("unexpected result %q", result)
+	}
+	if data, err := os.ReadFile(notes); err != nil || string(data) != "hello\n" {
+		t.Errorf("the pending call wasn't re-issued: %q, %v", data, err)
+	}
+	if todos := tools.GetAllTodos(); len(todos) != 1 || todos[0].Title != "Write the notes" {
+		t.Errorf("todos weren't restored: %+v", todos)
+	}
+	if agent.messages[1].Content != "Write the notes" {
+		t.Errorf("conversation wasn't restored: %+v", agent.messages)
+	}
+
+	// The snapshot is consumed
+	if _, err := agent.ResumeAbortedTask(""); err == nil {
+		t.Error("expected the snapshot to be resumed only once")
+	}
+}
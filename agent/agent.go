@@ -2,13 +2,17 @@ package agent
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alantheprice/coder/api"
 	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/policy"
 	"github.com/alantheprice/coder/tools"
 )
 
@@ -36,11 +40,60 @@ type Agent struct {
 	maxContextTokens      int          // Model's maximum context window
 	contextWarningIssued  bool         // Whether we've warned about approaching context limit
 	shellCommandHistory   map[string]*ShellCommandResult // Track shell commands for deduplication
+	approvalHandler       ApprovalHandler    // Consulted before risky actions (nil = no approval needed)
+	autoApprove           bool               // --yes: approve what would otherwise ask, except policy-required approvals
+	sessionApprovals      map[string]bool    // Kinds of action the user always allowed for this session
+	resumedSession        bool               // The next query continues a conversation restored with /session resume
+	sandboxMode           string             // --sandbox mode for this session ("" = the sandbox preference)
+	sandbox               *tools.Sandbox     // Sandbox shell commands run in (nil = unrestricted)
+	sandboxErr            error              // Why the configured sandbox couldn't be set up
+	sandboxResolved       bool               // sandbox and sandboxErr reflect the current mode
+	prefetched            prefetchCache      // Planned files read ahead of the read_file calls that want them
+	fullResults           map[string]string  // Full text of summarized tool results, by tool call ID
+	changeJournal         []JournalEntry     // File changes with snapshots, for /undo
+	journalIndex          int                // Index of the last journaled change
+	summaryClient         api.ClientInterface // Client for the result summary model, built on first use
+	summaryClientEntry    string             // result_summary_model value summaryClient was built for
+	approvalMu            sync.Mutex         // One approval prompt at a time when tool calls run in parallel
+	changes               taskChanges        // Lines and files the current task has changed, against the caps
+	disabledTools         map[string]bool    // Tools turned off with /tools disable for this session
+	toolPreset            string             // Tool preset last applied with --tools or /tools preset
+	policies              *policy.Set        // Project policies from .coder/policies.json (nil = none)
+	balance               *api.Balance       // Provider credit as last fetched from its billing API
+	costAtBalanceFetch    float64            // Session cost when the balance was fetched
+	balanceErr            error              // Why the last balance fetch failed, if it did
+	balanceCheckedAt      time.Time          // When the balance was last fetched or tried
+	balanceCheckedFor     api.ClientType     // The provider the balance was fetched for
+	lastTaskCost          float64            // Cost of the previous task, the estimate for the next
+	modelPricing          []api.ModelInfo    // Provider model list with prices, for cost previews
+	modelProfile          map[string]interface{} // Request parameters applied to the current model
+	workspaceSnapshot     *WorkspaceSnapshot // File hashes captured when the session's first task started
+	goWorkspace           *GoWorkspace       // Go modules of a multi-module repository, detected on the first task
+	goWorkspaceChecked    bool               // goWorkspace was detected (it stays nil for single-module repos)
+	terraformPlans        map[string]*terraformPlanFile // Last reviewed plan per Terraform directory, applied by terraform apply
+	fileWatcher           *FileWatcher       // Detects files changed outside the agent after being read
+	timings               *TaskTimings           // Wall-clock timing of the current task
+	toolUsage             *tools.ResourceUsage   // What the commands of the running tool call use
+	heavyToolCalls        []ToolTiming           // The session's most expensive tool calls
+	metrics               MetricsRecorder        // Optional usage metrics sink
+	outputCache           *OutputCache           // Cross-session cache of exploration command output
+	embeddings            api.EmbeddingsClient   // Created on first use by Embeddings()
+	knowledge             *KnowledgeBase         // Project facts distilled from earlier tasks (nil = disabled)
+	recordedTasks         int                    // Tasks archived for /recall this session
+	instances             *InstanceRegistry      // Other coder instances working in the same repository
+	turnLimit             int                    // Paired mode: tool calls before pausing for a go-ahead (0 = autonomous)
+	pairedPaused          bool                   // The last query paused; the next one continues it
+	streaming             bool                   // Print responses as they are generated (interactive mode)
+	lastStreamed          string                 // Content of the last streamed response
 	
 	// Interrupt handling
 	interruptRequested    bool               // Flag indicating interrupt was requested
 	interruptMessage      string             // User message to inject after interrupt
+	operationMu           sync.Mutex         // Guards the running operation, which Ctrl+C cancels from another goroutine
+	operationCtx          context.Context    // Context of the running task, command or request (nil when idle)
+	cancelOperation       context.CancelFunc // Cancels operationCtx
 	escPressed           chan bool           // Channel to signal Esc key press
+	pendingToolCalls     []api.ToolCall      // Tool calls an interrupt stopped before they ran
 }
 
 
@@ -50,7 +103,16 @@ func NewAgent() (*Agent, error) {
 	return NewAgentWithModel("")
 }
 
+// NewAgentWithModel creates an agent using the given model with the best
+// available provider, or the configured model when model is empty
 func NewAgentWithModel(model string) (*Agent, error) {
+	return NewAgentWithProvider("", model)
+}
+
+// NewAgentWithProvider creates an agent for an explicitly chosen provider and
+// model. An empty provider selects the best available one, and an empty model
+// uses the model configured for the provider.
+func NewAgentWithProvider(provider api.ClientType, model string) (*Agent, error) {
 	// Initialize configuration manager
 	configManager, err := config.NewManager()
 	if err != nil {
@@ -61,11 +123,14 @@ func NewAgentWithModel(model string) (*Agent, error) {
 	var clientType api.ClientType
 	var finalModel string
 	
-	if model != "" {
+	if provider != "" {
+		clientType, finalModel = provider, model
+		if finalModel == "" {
+			finalModel = configManager.GetModelForProvider(provider)
+		}
+	} else if model != "" {
 		finalModel = model
-		// When a model is specified, use the best available provider
-		// The provider should be explicitly set via command line --provider flag
-		// or via interactive /provider selection before this point
+		// When only a model is specified, use the best available provider
 		clientType, _, _ = configManager.GetBestProvider()
 	} else {
 		// Use configured provider and model
@@ -75,14 +140,20 @@ func NewAgentWithModel(model string) (*Agent, error) {
 		}
 	}
 
+	// Remap retired model IDs so the replacement is what gets saved
+	finalModel = api.ResolveModelAlias(clientType, finalModel)
+
 	// Create the client
 	client, err := api.NewUnifiedClientWithModel(clientType, finalModel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API client: %w", err)
 	}
 
-	// Save the selection for future use
-	if err := configManager.SetProviderAndModel(clientType, finalModel); err != nil {
+	// Save the selection for future use. A selection pinned by the project
+	// stays with the project rather than becoming the machine-wide default.
+	if provider == "" && configManager.IsPinned(clientType) {
+		fmt.Printf("📌 Using %s/%s pinned by %s\n", api.GetProviderName(clientType), finalModel, config.ProjectConfigFile)
+	} else if err := configManager.SetProviderAndModel(clientType, finalModel); err != nil {
 		// Log warning but don't fail - this is not critical
 		fmt.Printf("⚠️  Warning: Failed to save provider selection: %v\n", err)
 	}
@@ -104,8 +175,8 @@ func NewAgentWithModel(model string) (*Agent, error) {
 	// Clear old todos at session start
 	tools.ClearTodos()
 
-	// Conversation optimization is always enabled
-	optimizationEnabled := true
+	// Conversation optimization is enabled unless turned off in config
+	optimizerSettings := LoadOptimizerSettings(configManager.GetConfig())
 
 	agent := &Agent{
 		client:              client,
@@ -115,14 +186,61 @@ func NewAgentWithModel(model string) (*Agent, error) {
 		totalCost:           0.0,
 		clientType:          clientType,
 		debug:               debug,
-		optimizer:           NewConversationOptimizer(optimizationEnabled, debug),
+		optimizer:           NewConversationOptimizerWithSettings(optimizerSettings, debug),
 		configManager:       configManager,
 		shellCommandHistory: make(map[string]*ShellCommandResult),
+		fileWatcher:         NewFileWatcher(),
 		interruptRequested:  false,
 		interruptMessage:    "",
 		escPressed:          make(chan bool, 1),
 	}
 	
+	agent.applyModelProfile()
+	agent.applyLocalModelSettings()
+	agent.warmUpLocalModel()
+
+	// Count tokens with the model's tokenizer; its rank file is downloaded
+	// once unless disabled in config
+	if dir, err := config.GetConfigDir(); err == nil {
+		api.UseTokenizerFiles(filepath.Join(dir, "tokenizers"), configManager.GetConfig().GetBoolPreference("tokenizer_download", true))
+	}
+	agent.optimizer.SetTokenCounter(agent.countTokens)
+
+	// Organization policies are shared through the project; a broken policy file
+	// stops the agent rather than letting tool calls through unchecked
+	if wd, err := os.Getwd(); err == nil {
+		if agent.policies, err = policy.Load(wd); err != nil {
+			return nil, fmt.Errorf("failed to load project policies: %w", err)
+		}
+	}
+
+	// Reuse exploration output across sessions unless disabled in config
+	if configManager.GetConfig().GetBoolPreference("output_cache", true) {
+		if wd, err := os.Getwd(); err == nil {
+			if cache, err := OpenOutputCache(wd); err == nil {
+				agent.outputCache = cache
+			} else {
+				agent.debugLog("⚠️ Output cache unavailable: %v\n", err)
+			}
+		}
+	}
+
+	// Coordinate with other coder instances working in the same repository
+	if wd, err := os.Getwd(); err == nil {
+		agent.instances = NewInstanceRegistry(wd, "")
+	}
+
+	// Carry durable project facts between sessions unless disabled in config
+	if configManager.GetConfig().GetBoolPreference("knowledge_base", true) {
+		if wd, err := os.Getwd(); err == nil {
+			if kb, err := OpenKnowledgeBase(wd); err == nil {
+				agent.knowledge = kb
+			} else {
+				agent.debugLog("⚠️ Knowledge base unavailable: %v\n", err)
+			}
+		}
+	}
+
 	// Start Esc key monitoring goroutine
 	go agent.monitorEscKey()
 	
@@ -140,9 +258,12 @@ func NewAgentWithModel(model string) (*Agent, error) {
 
 
 
+// getProjectContext returns the first project context file found. Large files
+// are read whole; projectContextForPrompt cuts them to its budget.
 func getProjectContext() string {
 	// Check for project context files in order of priority
 	contextFiles := []string{
+		ProjectContextFile,
 		".cursor/markdown/project.md",
 		".cursor/markdown/context.md", 
 		".claude/project.md",
@@ -152,8 +273,8 @@ func getProjectContext() string {
 	}
 	
 	for _, filePath := range contextFiles {
-		content, err := tools.ReadFile(filePath)
-		if err == nil && strings.TrimSpace(content) != "" {
+		content, err := os.ReadFile(filePath)
+		if err == nil && strings.TrimSpace(string(content)) != "" {
 			return fmt.Sprintf("PROJECT CONTEXT:\n%s", content)
 		}
 	}
@@ -215,11 +336,15 @@ func (a *Agent) CheckForInterrupt() bool {
 	}
 }
 
-// HandleInterrupt processes an interrupt request and prompts for continuation
-func (a *Agent) HandleInterrupt() string {
+// HandleInterrupt processes an interrupt request and prompts for continuation.
+// Aborting saves the task for "coder resume --last" and returns ErrTaskAborted.
+func (a *Agent) HandleInterrupt() (string, error) {
 	fmt.Println("\n🛑 Esc key pressed! Current task paused.")
+	if len(a.pendingToolCalls) > 0 {
+		fmt.Printf("⏸️  %d tool call(s) haven't run yet\n", len(a.pendingToolCalls))
+	}
 	fmt.Println("💬 Enter instructions to modify or continue the current task:")
-	fmt.Println("   (or press Enter to resume, 'quit' to exit)")
+	fmt.Println("   (or press Enter to resume, 'abort' to save the task for later, 'quit' to exit)")
 	fmt.Print(">>> ")
 	
 	var input string
@@ -230,15 +355,24 @@ func (a *Agent) HandleInterrupt() string {
 	switch input {
 	case "", "resume", "continue":
 		fmt.Println("▶️  Resuming current task...")
-		return ""
-	case "quit", "exit", "stop":
-		fmt.Println("🚪 Exiting...")
-		os.Exit(0)
-		return ""
+		return "", nil
+	case "abort", "quit", "exit", "stop":
+		path, err := a.SaveAbortedTask()
+		if err != nil {
+			fmt.Printf("⚠️  Failed to save the task: %v\n", err)
+		} else {
+			fmt.Printf("💾 Task saved to %s - run 'coder resume --last' to continue it\n", path)
+		}
+		if input != "abort" {
+			fmt.Println("🚪 Exiting...")
+			os.Exit(0)
+		}
+		a.ClearInterrupt()
+		return "", ErrTaskAborted
 	default:
 		fmt.Printf("📝 Injecting new instruction: %s\n", input)
 		fmt.Println("▶️  Continuing with modified task...")
-		return input
+		return input, nil
 	}
 }
 
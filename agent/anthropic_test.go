@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+const anthropicStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_2","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Reading "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"the file."}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_2","name":"read_file","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"file_path\": "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"main.go\"}"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":25}}
+
+event: message_stop
+data: {"type":"message_stop"}
+`
+
+func TestAnthropicClient(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/models":
+			w.Write([]byte(`{"data": [{"id": "claude-sonnet-4-5-20250929", "display_name": "Claude Sonnet 4.5"}, {"id": "claude-haiku-4-5", "display_name": "Claude Haiku 4.5"}]}`))
+		case "/messages":
+			requestBody = nil
+			json.NewDecoder(r.Body).Decode(&requestBody)
+			if requestBody["stream"] == true {
+				w.Write([]byte(anthropicStream))
+				return
+			}
+			w.Write([]byte(`{"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5", "stop_reason": "tool_use",
+				"content": [{"type": "text", "text": "Let me look."}, {"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {"file_path": "go.mod"}}],
+				"usage": {"input_tokens": 1000000, "output_tokens": 1000000, "cache_read_input_tokens": 1000000, "cache_creation_input_tokens": 0}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("ANTHROPIC_API_KEY", "test-key")
+	t.Setenv("ANTHROPIC_BASE_URL", server.URL+"/")
+
+	client, err := api.NewUnifiedClientWithModel(api.AnthropicClientType, "")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	if client.GetModel() != "claude-sonnet-4-5" || client.GetProvider() != "anthropic" || !client.SupportsVision() {
+		t.Errorf("unexpected model %s or provider %s", client.GetModel(), client.GetProvider())
+	}
+
+	messages := []api.Message{
+		{Role: "system", Content: "You are a coding agent."},
+		{Role: "user", Content: "read go.mod"},
+		{Role: "user", Content: "then summarize it"},
+	}
+	resp, err := client.SendChatRequest(context.Background(), messages, api.GetToolDefinitions(), "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	message := resp.Choices[0].Message
+	if message.Content != "Let me look." || len(message.ToolCalls) != 1 || message.ToolCalls[0].ID != "toolu_1" ||
+		message.ToolCalls[0].Function.Name != "read_file" || message.ToolCalls[0].Function.Arguments != `{"file_path": "go.mod"}` {
+		t.Errorf("unexpected message %+v", message)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("expected tool_use to finish as tool_calls, got %q", resp.Choices[0].FinishReason)
+	}
+	// Fresh input at $3, cache reads at a tenth of that, output at $15
+	if resp.Usage.PromptTokens != 2000000 || resp.Usage.PromptTokensDetails.CachedTokens != 1000000 || math.Abs(resp.Usage.EstimatedCost-(3+0.3+15)) > 1e-9 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+
+	// System messages move to the cached system field, and same-role turns merge
+	system, _ := requestBody["system"].([]interface{})
+	turns, _ := requestBody["messages"].([]interface{})
+	tools, _ := requestBody["tools"].([]interface{})
+	if len(system) != 1 || len(turns) != 1 || requestBody["max_tokens"] == nil {
+		t.Fatalf("unexpected request %v", requestBody)
+	}
+	if block := system[0].(map[string]interface{}); block["text"] != "You are a coding agent." || block["cache_control"] == nil {
+		t.Errorf("unexpected system block %v", block)
+	}
+	if content := turns[0].(map[string]interface{})["content"].([]interface{}); len(content) != 2 {
+		t.Errorf("expected the user turns to merge, got %v", content)
+	}
+	if len(tools) == 0 || tools[0].(map[string]interface{})["input_schema"] == nil {
+		t.Errorf("expected tools with input schemas, got %v", tools)
+	}
+
+	// Streamed responses pass text through and assemble the tool input
+	var streamed strings.Builder
+	resp, err = client.SendChatRequestStream(context.Background(), messages, api.GetToolDefinitions(), "", func(content, reasoning string) {
+		streamed.WriteString(content)
+	})
+	if err != nil {
+		t.Fatalf("SendChatRequestStream: %v", err)
+	}
+	message = resp.Choices[0].Message
+	if streamed.String() != "Reading the file." || message.Content != "Reading the file." {
+		t.Errorf("streamed %q, assembled %q", streamed.String(), message.Content)
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].Function.Arguments != `{"file_path": "main.go"}` {
+		t.Errorf("unexpected streamed tool calls %+v", message.ToolCalls)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 25 || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("unexpected streamed usage %+v", resp.Usage)
+	}
+
+	models, err := api.GetAvailableModels(api.AnthropicClientType)
+	if err != nil || len(models) != 2 || models[0].Name != "Claude Sonnet 4.5" || models[0].InputCost != 3 || models[1].OutputCost != 5 {
+		t.Errorf("expected Anthropic's models with prices, got %+v (%v)", models, err)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if _, err := api.NewUnifiedClientWithModel(api.AnthropicClientType, ""); err == nil {
+		t.Error("expected an error without ANTHROPIC_API_KEY")
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ApprovalHandler is asked before the agent performs a risky action.
+// It returns true if the action may proceed.
+type ApprovalHandler func(toolName, detail string) bool
+
+// prefApprovalMode chooses which actions need approval: "risky" (default)
+// asks for high-risk actions, "edits" also asks before every file change
+const prefApprovalMode = "approval_mode"
+
+// approvalModeEdits is the approval mode that confirms every file change
+const approvalModeEdits = "edits"
+
+// approvalDecision is the user's answer to an approval prompt
+type approvalDecision int
+
+const (
+	approvalRejected approvalDecision = iota
+	approvalApproved
+	approvalAlwaysAllowed // approved, along with the same kind of action for the rest of the session
+)
+
+// riskyShellPatterns match shell commands that are destructive or leave the machine
+var riskyShellPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\brm\s+(-[a-zA-Z]*[rf][a-zA-Z]*\s+)+`),
+	regexp.MustCompile(`\bgit\s+push\b`),
+	regexp.MustCompile(`\bgit\s+reset\s+--hard\b`),
+	regexp.MustCompile(`\bgit\s+clean\s+-[a-zA-Z]*f`),
+	regexp.MustCompile(`\bsudo\b`),
+	regexp.MustCompile(`\b(mkfs|dd|shutdown|reboot)\b`),
+	regexp.MustCompile(`\bchmod\s+-R\b`),
+	regexp.MustCompile(`\b(curl|wget)\b[^|]*\|\s*(ba|z)?sh\b`),
+	regexp.MustCompile(`(?i)\bdrop\s+(table|database)\b`),
+}
+
+// SetApprovalHandler installs a handler consulted before risky actions
+func (a *Agent) SetApprovalHandler(handler ApprovalHandler) {
+	a.approvalHandler = handler
+}
+
+// SetAutoApprove approves actions that would otherwise ask, for unattended
+// runs with --yes. Approvals a project policy requires still ask.
+func (a *Agent) SetAutoApprove(enabled bool) {
+	a.autoApprove = enabled
+}
+
+// GetAutoApprove reports whether --yes approves actions that would ask
+func (a *Agent) GetAutoApprove() bool {
+	return a.autoApprove
+}
+
+// confirmEdits reports whether the approval mode asks before every file change
+func (a *Agent) confirmEdits() bool {
+	return a.configManager != nil && a.configManager.GetConfig().GetStringPreference(prefApprovalMode, "") == approvalModeEdits
+}
+
+// approvalKey identifies a kind of action for "always allow for this
+// session": the tool and the reasons it needs approval
+func approvalKey(toolName string, risk RiskAssessment) string {
+	reasons := append([]string(nil), risk.Reasons...)
+	sort.Strings(reasons)
+	return toolName + ": " + strings.Join(reasons, ", ")
+}
+
+// isRiskyShellCommand reports whether a shell command needs approval
+func isRiskyShellCommand(command string) bool {
+	for _, pattern := range riskyShellPatterns {
+		if pattern.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// approveShellCommand asks for confirmation of high-risk shell commands,
+// passing along the model's stated reason for running them
+func (a *Agent) approveShellCommand(command, why string) bool {
+	return a.approveAction("shell_command", command, why, assessShellRisk(command))
+}
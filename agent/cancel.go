@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrCancelled means the user cancelled the running operation with Ctrl+C
+var ErrCancelled = errors.New("operation cancelled")
+
+// BeginOperation starts a cancelable operation: API requests and shell
+// commands made until end is called use ctx, and CancelCurrent cancels it.
+// Nested calls share the outer operation.
+func (a *Agent) BeginOperation() (ctx context.Context, end func()) {
+	a.operationMu.Lock()
+	defer a.operationMu.Unlock()
+	if a.operationCtx != nil {
+		return a.operationCtx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.operationCtx, a.cancelOperation = ctx, cancel
+	return ctx, func() {
+		a.operationMu.Lock()
+		a.operationCtx, a.cancelOperation = nil, nil
+		a.operationMu.Unlock()
+		cancel()
+	}
+}
+
+// CancelCurrent cancels the running operation's in-flight request or command.
+// It reports false when nothing is running or the operation was already
+// cancelled, so a second Ctrl+C can exit instead.
+func (a *Agent) CancelCurrent() bool {
+	a.operationMu.Lock()
+	defer a.operationMu.Unlock()
+	if a.operationCtx == nil || a.operationCtx.Err() != nil {
+		return false
+	}
+	a.cancelOperation()
+	return true
+}
+
+// operationContext returns the running operation's context, or a background
+// context outside of one
+func (a *Agent) operationContext() context.Context {
+	a.operationMu.Lock()
+	defer a.operationMu.Unlock()
+	if a.operationCtx == nil {
+		return context.Background()
+	}
+	return a.operationCtx
+}
+
+// cancelled reports whether the running operation was cancelled
+func (a *Agent) cancelled() bool {
+	return a.operationContext().Err() != nil
+}
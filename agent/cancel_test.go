@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestCancelCurrent(t *testing.T) {
+	a := &Agent{}
+	if a.CancelCurrent() {
+		t.Error("expected nothing to cancel outside of an operation")
+	}
+
+	ctx, end := a.BeginOperation()
+	nested, endNested := a.BeginOperation()
+	if nested != ctx {
+		t.Error("expected nested operations to share the outer context")
+	}
+	endNested()
+	if a.operationContext() != ctx {
+		t.Error("expected the outer operation to survive the nested end")
+	}
+
+	if !a.CancelCurrent() || !a.cancelled() || ctx.Err() == nil {
+		t.Error("expected the running operation to be cancelled")
+	}
+	if a.CancelCurrent() {
+		t.Error("expected a second cancel to report nothing left to cancel")
+	}
+	end()
+	if a.cancelled() {
+		t.Error("expected no cancellation once the operation ended")
+	}
+}
+
+func TestCancelShellCommand(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := tools.ExecuteShellCommand(ctx, "sleep 5")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the command to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the command to stop promptly, took %v", elapsed)
+	}
+}
+
+func TestCancelChatRequest(t *testing.T) {
+	// The chat request hangs until the client gives up
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	t.Setenv("GROQ_API_KEY", "test-key")
+	t.Setenv("GROQ_BASE_URL", server.URL)
+
+	client, err := api.NewUnifiedClientWithModel(api.GroqClientType, "qwen/qwen3-32b")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = client.SendChatRequest(ctx, []api.Message{{Role: "user", Content: "hi"}}, nil, "")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the request to stop promptly, took %v", elapsed)
+	}
+}
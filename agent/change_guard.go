@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+const (
+	prefMaxTaskChangedLines = "max_task_changed_lines" // lines a task may change before asking (0 = no cap)
+	prefMaxTaskChangedFiles = "max_task_changed_files" // files a task may change before asking (0 = no cap)
+)
+
+// taskChanges totals the file changes made by the current task
+type taskChanges struct {
+	lines     int
+	files     map[string]bool
+	lineLimit int // caps in force, raised each time the user approves going past them
+	fileLimit int
+	snapshot  *WorkspaceSnapshot // taken before the task's first shell command, to see what commands change
+}
+
+// changeCaps returns the configured caps on lines and files changed per task
+func (a *Agent) changeCaps() (int, int) {
+	lines, files := 1500, 30
+	if a.configManager != nil {
+		config := a.configManager.GetConfig()
+		lines = config.GetIntPreference(prefMaxTaskChangedLines, lines)
+		files = config.GetIntPreference(prefMaxTaskChangedFiles, files)
+	}
+	return lines, files
+}
+
+// resetTaskChanges starts a new task's change totals with the configured caps
+func (a *Agent) resetTaskChanges() {
+	lineLimit, fileLimit := a.changeCaps()
+	a.changes = taskChanges{files: make(map[string]bool), lineLimit: lineLimit, fileLimit: fileLimit}
+}
+
+// changedLines estimates how many lines a file-changing tool call touches
+func changedLines(args map[string]interface{}) int {
+	lines := countLines(stringArg(args, "content", "source")) + max(countLines(stringArg(args, "old_string")), countLines(stringArg(args, "new_string")))
+	if edits, err := editsArg(args); err == nil {
+		for _, edit := range edits {
+			lines += max(countLines(edit.OldString), countLines(edit.NewString))
+		}
+	}
+	for _, line := range strings.Split(stringArg(args, "patch", "diff"), "\n") {
+		if (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) && !strings.HasPrefix(line, "+++ ") && !strings.HasPrefix(line, "--- ") {
+			lines++
+		}
+	}
+	return lines
+}
+
+// changedPaths returns the files a file-changing tool call writes: its
+// file_path, or every file of a patch
+func changedPaths(args map[string]interface{}) []string {
+	if patch := stringArg(args, "patch", "diff"); patch != "" {
+		return tools.PatchPaths(patch)
+	}
+	if path := stringArg(args, "file_path", "path"); path != "" {
+		return []string{filepath.Clean(path)}
+	}
+	return nil
+}
+
+// checkChangeSize pauses for approval when a file change would take the task
+// past its cap on changed lines or files. Approval raises the caps by their
+// configured size, so the next pause comes after as much again. What a shell
+// command changes is only known after it ran, so a command pauses once earlier
+// commands have taken the task past the file cap.
+func (a *Agent) checkChangeSize(toolName string, args map[string]interface{}) error {
+	shell := toolName == "shell_command"
+	if !isFileChangeTool(toolName) && !shell {
+		return nil
+	}
+	if a.changes.files == nil {
+		a.resetTaskChanges()
+	}
+
+	var paths []string
+	lines := a.changes.lines
+	if shell {
+		a.captureTaskSnapshot()
+	} else {
+		paths = changedPaths(args)
+		lines += changedLines(args)
+	}
+	files := len(a.changes.files)
+	for _, path := range paths {
+		if !a.changes.files[path] {
+			files++
+		}
+	}
+	overLines := a.changes.lineLimit > 0 && lines > a.changes.lineLimit
+	overFiles := a.changes.fileLimit > 0 && files > a.changes.fileLimit
+	if !overLines && !overFiles {
+		return nil
+	}
+
+	summary := fmt.Sprintf("this task would change %d lines in %d files (cap %d lines, %d files)", lines, files, a.changes.lineLimit, a.changes.fileLimit)
+	fmt.Printf("⏸️  Change-size cap reached: %s\n", summary)
+	why, _ := args["why"].(string)
+	detail := strings.Join(paths, ", ")
+	if shell {
+		detail = stringArg(args, "command", "cmd")
+	}
+	risk := RiskAssessment{Level: RiskHigh, Reasons: []string{summary}}
+	if !a.approveAction(toolName, detail, why, risk) {
+		return fmt.Errorf("change-size cap reached: %s and the user did not approve more changes. Stop changing files and summarize what was done and what remains", summary)
+	}
+
+	lineStep, fileStep := a.changeCaps()
+	if overLines {
+		a.changes.lineLimit = lines + lineStep
+	}
+	if overFiles {
+		a.changes.fileLimit = files + fileStep
+	}
+	return nil
+}
+
+// recordChange adds a successful file change to the task's totals
+func (a *Agent) recordChange(toolName string, args map[string]interface{}) {
+	if !isFileChangeTool(toolName) {
+		return
+	}
+	if a.changes.files == nil {
+		a.resetTaskChanges()
+	}
+	a.changes.lines += changedLines(args)
+	for _, path := range changedPaths(args) {
+		a.changes.files[path] = true
+	}
+}
+
+// captureTaskSnapshot records the workspace before the task's first shell
+// command, so the files commands change can be counted afterwards
+func (a *Agent) captureTaskSnapshot() {
+	if a.changes.snapshot != nil {
+		return
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	snapshot, err := CaptureWorkspaceSnapshot(wd)
+	if err != nil {
+		a.debugLog("⚠️ Failed to capture the task's workspace snapshot: %v\n", err)
+		return
+	}
+	a.changes.snapshot = snapshot
+}
+
+// recordShellChanges adds the files changed since the task's snapshot to its
+// totals, which catches changes made by shell commands (sed -i, generators,
+// git checkout). Their line counts aren't known, so they count toward the file
+// cap only.
+func (a *Agent) recordShellChanges() {
+	if a.changes.snapshot == nil {
+		return
+	}
+	changes, err := a.changes.snapshot.Diff()
+	if err != nil {
+		a.debugLog("⚠️ Failed to check the workspace for changes: %v\n", err)
+		return
+	}
+	for _, change := range changes {
+		if !a.changes.files[filepath.Join(a.changes.snapshot.Root, change.Path)] {
+			a.changes.files[change.Path] = true
+		}
+	}
+}
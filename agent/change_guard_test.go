@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestChangeSizeGuard tests that a task pauses for approval once its changes pass the caps
+func TestChangeSizeGuard(t *testing.T) {
+	a := &Agent{}
+	asked := 0
+	approve := true
+	a.SetApprovalHandler(func(toolName, detail string) bool {
+		asked++
+		return approve
+	})
+
+	edit := func(path string, lines int) error {
+		args := map[string]interface{}{"file_path": path, "content": strings.Repeat("x\n", lines-1) + "x"}
+		if err := a.checkChangeSize("write_file", args); err != nil {
+			return err
+		}
+		a.recordChange("write_file", args)
+		return nil
+	}
+
+	// 1400 lines in one file stays under the default 1500-line cap
+	if err := edit("a.go", 1400); err != nil || asked != 0 {
+		t.Fatalf("expected no approval under the cap, asked %d times (%v)", asked, err)
+	}
+	if err := a.checkChangeSize("read_file", map[string]interface{}{"file_path": "a.go"}); err != nil || asked != 0 {
+		t.Fatalf("reads shouldn't count against the cap (%v)", err)
+	}
+
+	// Passing the cap asks once, then allows as much again
+	if err := edit("b.go", 200); err != nil || asked != 1 {
+		t.Fatalf("expected one approval past the cap, asked %d times (%v)", asked, err)
+	}
+	if err := edit("c.go", 1000); err != nil || asked != 1 {
+		t.Fatalf("expected the approved cap to be raised, asked %d times (%v)", asked, err)
+	}
+	if a.changes.lines != 2600 || len(a.changes.files) != 3 {
+		t.Errorf("unexpected totals %d lines in %d files", a.changes.lines, len(a.changes.files))
+	}
+
+	// A refusal blocks the change and tells the model to stop
+	approve = false
+	err := edit("d.go", 1000)
+	if err == nil || !strings.Contains(err.Error(), "summarize") || asked != 2 {
+		t.Fatalf("expected a refused change, got %v after %d approvals", err, asked)
+	}
+	if a.changes.lines != 2600 {
+		t.Errorf("a refused change shouldn't count, got %d lines", a.changes.lines)
+	}
+
+	// The file cap counts distinct files
+	a.resetTaskChanges()
+	a.changes.fileLimit = 2
+	for _, path := range []string{"a.go", "./a.go", "b.go"} {
+		if err := edit(path, 1); err != nil {
+			t.Fatalf("expected %s within the file cap: %v", path, err)
+		}
+	}
+	if err := edit("c.go", 1); err == nil || !strings.Contains(err.Error(), "3 files") {
+		t.Errorf("expected the third file to pass the cap, got %v", err)
+	}
+}
+
+// TestChangeSizeGuardCountsShellChanges tests that files changed by shell
+// commands count toward the file cap
+func TestChangeSizeGuardCountsShellChanges(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("a.go", []byte("package a\n"), 0644)
+	a := &Agent{}
+	asked := 0
+	a.SetApprovalHandler(func(toolName, detail string) bool {
+		asked++
+		return false
+	})
+	a.resetTaskChanges()
+	a.changes.fileLimit = 2
+
+	shell := map[string]interface{}{"command": "go generate ./..."}
+	if err := a.checkChangeSize("shell_command", shell); err != nil {
+		t.Fatalf("expected the first command to run: %v", err)
+	}
+	// The command rewrites a.go, which an edit also changed, and generates b.go and c.go
+	args := map[string]interface{}{"file_path": "a.go", "content": "package a\n\nfunc A() {}"}
+	a.recordChange("write_file", args)
+	os.WriteFile("a.go", []byte("package a\n\nfunc A() {}\n"), 0644)
+	os.WriteFile("b.go", []byte("package a\n"), 0644)
+	os.WriteFile("c.go", []byte("package a\n"), 0644)
+	a.recordShellChanges()
+	if len(a.changes.files) != 3 {
+		t.Errorf("expected 3 changed files, got %v", a.changes.files)
+	}
+
+	// The next command pauses, since the task is already past the file cap
+	err := a.checkChangeSize("shell_command", shell)
+	if err == nil || !strings.Contains(err.Error(), "3 files") || asked != 1 {
+		t.Errorf("expected the next command to ask for approval, got %v after %d approvals", err, asked)
+	}
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+const (
+	// maxJournalEntries bounds the changes that can be undone; the oldest go first
+	maxJournalEntries = 100
+	// maxJournalSnapshotBytes bounds the file size snapshotted for undo
+	maxJournalSnapshotBytes = 2 << 20
+)
+
+// JournalEntry is one file change with the file's content before and after
+// it, so the change can be undone
+type JournalEntry struct {
+	Index       int // 1-based, in the order the changes were made
+	Path        string
+	Description string
+	Time        time.Time
+	Existed     bool   // The file was there before the change
+	Before      []byte // Content before the change (nil when the file was created)
+	After       []byte // Content after the change (nil when the file was deleted)
+	Undone      bool
+}
+
+// journalChange snapshots a change for /undo. before is the file's content
+// before the change; the content after it is read back from disk.
+func (a *Agent) journalChange(change tools.FileChange, description string, before []byte) {
+	after, err := os.ReadFile(change.Path)
+	if err != nil {
+		after = nil
+	}
+	if len(before) > maxJournalSnapshotBytes || len(after) > maxJournalSnapshotBytes {
+		a.debugLog("⚠️  %s is too large to snapshot; the change can't be undone\n", change.Path)
+		return
+	}
+	a.journalIndex++
+	a.changeJournal = append(a.changeJournal, JournalEntry{
+		Index:       a.journalIndex,
+		Path:        change.Path,
+		Description: description,
+		Time:        time.Now(),
+		Existed:     change.Kind != tools.ChangeCreated,
+		Before:      before,
+		After:       after,
+	})
+	if len(a.changeJournal) > maxJournalEntries {
+		a.changeJournal = a.changeJournal[len(a.changeJournal)-maxJournalEntries:]
+	}
+}
+
+// ChangeJournal returns the session's undoable file changes, oldest first
+func (a *Agent) ChangeJournal() []JournalEntry {
+	return a.changeJournal
+}
+
+// UndoChange reverts a journaled change: the most recent one still in place
+// when index is 0, otherwise the change with that index. It refuses when a
+// later change to the same file is still in place, or the file was edited
+// since the change, so nothing newer is lost.
+func (a *Agent) UndoChange(index int) (JournalEntry, error) {
+	pos := -1
+	for i := len(a.changeJournal) - 1; i >= 0; i-- {
+		entry := a.changeJournal[i]
+		if (index == 0 && !entry.Undone) || entry.Index == index {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		if index == 0 {
+			return JournalEntry{}, fmt.Errorf("no changes to undo")
+		}
+		return JournalEntry{}, fmt.Errorf("no change #%d in the journal (see /undo list)", index)
+	}
+	entry := &a.changeJournal[pos]
+	if entry.Undone {
+		return JournalEntry{}, fmt.Errorf("change #%d was already undone", entry.Index)
+	}
+	for _, later := range a.changeJournal[pos+1:] {
+		if !later.Undone && filepath.Clean(later.Path) == filepath.Clean(entry.Path) {
+			return JournalEntry{}, fmt.Errorf("change #%d to %s came after it; undo that first", later.Index, entry.Path)
+		}
+	}
+
+	defer a.fileWatcher.Lock(entry.Path)()
+	current, err := os.ReadFile(entry.Path)
+	exists := err == nil
+	if exists != (entry.After != nil) || !bytes.Equal(current, entry.After) {
+		return JournalEntry{}, fmt.Errorf("%s changed since change #%d; not undoing over those edits", entry.Path, entry.Index)
+	}
+
+	if entry.Existed {
+		info, err := os.Stat(entry.Path)
+		mode := os.FileMode(0644)
+		if err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			return JournalEntry{}, fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+		}
+		if err := os.WriteFile(entry.Path, entry.Before, mode); err != nil {
+			return JournalEntry{}, fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+		}
+	} else if err := os.Remove(entry.Path); err != nil {
+		return JournalEntry{}, fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+	}
+	entry.Undone = true
+
+	a.fileWatcher.Track(entry.Path)
+	a.invalidateModifiedFile(entry.Path)
+	var restored []byte // nil records a deletion
+	if entry.Existed {
+		restored = append([]byte{}, entry.Before...)
+	}
+	change := tools.DescribeChange(entry.Path, entry.After, restored, exists)
+	a.taskActions = append(a.taskActions, TaskAction{
+		Type:        "file_" + string(change.Kind),
+		Description: fmt.Sprintf("Undid change #%d (%s)", entry.Index, entry.Description),
+		Details:     entry.Path,
+		Change:      &change,
+	})
+	return *entry, nil
+}
+
+// undoLastChange runs the undo_last_change tool
+func (a *Agent) undoLastChange(index int) (string, error) {
+	entry, err := a.UndoChange(index)
+	if err != nil {
+		return "", err
+	}
+	if !entry.Existed {
+		return fmt.Sprintf("Undid change #%d (%s): removed %s, which the change created. Re-read files before editing them again.", entry.Index, entry.Description, entry.Path), nil
+	}
+	return fmt.Sprintf("Undid change #%d (%s): restored %s to its content before the change. Re-read it before editing it again.", entry.Index, entry.Description, entry.Path), nil
+}
+
+// FormatJournalEntry describes a journaled change in one line
+func FormatJournalEntry(entry JournalEntry) string {
+	state := ""
+	if entry.Undone {
+		state = " (undone)"
+	}
+	change := tools.DescribeChange(entry.Path, entry.Before, entry.After, entry.Existed)
+	return fmt.Sprintf("#%d %s %s (+%d/-%d lines)%s", entry.Index, entry.Time.Format("15:04:05"),
+		entry.Description, change.LinesAdded, change.LinesRemoved, state)
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestUndoChange(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	t.Chdir(t.TempDir())
+	path, created := "main.go", filepath.Join("cmd", "new.go")
+	os.WriteFile(path, []byte("package main\n"), 0644)
+
+	run := func(name, arguments string) string {
+		t.Helper()
+		call := api.ToolCall{ID: "call_" + name, Type: "function"}
+		call.Function.Name, call.Function.Arguments = name, arguments
+		result, err := agent.executeTool(call)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return result
+	}
+	run("edit_file", `{"file_path": "main.go", "old_string": "main", "new_string": "app"}`)
+	run("write_file", `{"file_path": "cmd/new.go", "content": "package app\n"}`)
+	run("write_file", `{"file_path": "main.go", "content": "package app\n\nfunc main() {}\n"}`)
+	if journal := agent.ChangeJournal(); len(journal) != 3 {
+		t.Fatalf("expected 3 journaled changes, got %d", len(journal))
+	}
+
+	// The edit can't be undone while the later rewrite of the file is in place
+	if _, err := agent.UndoChange(1); err == nil || !strings.Contains(err.Error(), "undo that first") {
+		t.Errorf("expected the later change to block the undo, got %v", err)
+	}
+
+	if entry, err := agent.UndoChange(0); err != nil || entry.Index != 3 {
+		t.Fatalf("expected the rewrite to be undone, got %+v (%v)", entry, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "package app\n" {
+		t.Errorf("expected the edited content back, got %q", data)
+	}
+
+	if result := run("undo_last_change", `{}`); !strings.Contains(result, "removed") {
+		t.Fatalf("expected the created file to be removed, got %q", result)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("expected the created file to be gone")
+	}
+
+	// An edit made since the change is never overwritten
+	os.WriteFile(path, []byte("package edited\n"), 0644)
+	if _, err := agent.UndoChange(1); err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Errorf("expected the outside edit to block the undo, got %v", err)
+	}
+	os.WriteFile(path, []byte("package app\n"), 0644)
+	if _, err := agent.UndoChange(1); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "package main\n" {
+		t.Errorf("expected the original content back, got %q", data)
+	}
+	if _, err := agent.UndoChange(0); err == nil {
+		t.Error("expected nothing left to undo")
+	}
+	if len(agent.ChangeJournal()) != 3 {
+		t.Error("expected undoing not to add journal entries")
+	}
+}
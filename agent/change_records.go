@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// recordFileChange adds a tool's change record to the task actions, so
+// summaries and /diff report what the tools did rather than what the model
+// says it did, and journals it with the file's content before the change
+// for /undo
+func (a *Agent) recordFileChange(change tools.FileChange, description string, before []byte) {
+	a.journalChange(change, description, before)
+	a.taskActions = append(a.taskActions, TaskAction{
+		Type:        "file_" + string(change.Kind),
+		Description: description,
+		Details:     change.Path,
+		Change:      &change,
+	})
+}
+
+// FileChangeTotal sums the recorded changes to one file
+type FileChangeTotal struct {
+	Path         string
+	Kind         tools.ChangeKind // created when the first change created the file, deleted when the last deleted it
+	Changes      int
+	LinesAdded   int
+	LinesRemoved int
+	BytesBefore  int
+	BytesAfter   int
+}
+
+// ChangeRecords returns the structured change records of the session, in
+// the order the changes were made
+func (a *Agent) ChangeRecords() []tools.FileChange {
+	var records []tools.FileChange
+	for _, action := range a.taskActions {
+		if action.Change != nil {
+			records = append(records, *action.Change)
+		}
+	}
+	return records
+}
+
+// ChangedFiles totals the change records per file, in the order the files
+// were first changed
+func (a *Agent) ChangedFiles() []FileChangeTotal {
+	var totals []FileChangeTotal
+	index := make(map[string]int)
+	for _, change := range a.ChangeRecords() {
+		path := filepath.Clean(change.Path)
+		i, seen := index[path]
+		if !seen {
+			i = len(totals)
+			index[path] = i
+			totals = append(totals, FileChangeTotal{Path: path, Kind: change.Kind, BytesBefore: change.BytesBefore})
+		}
+		total := &totals[i]
+		total.Changes++
+		total.LinesAdded += change.LinesAdded
+		total.LinesRemoved += change.LinesRemoved
+		total.BytesAfter = change.BytesAfter
+		if change.Kind == tools.ChangeDeleted {
+			total.Kind = tools.ChangeDeleted
+		} else if total.Kind == tools.ChangeDeleted {
+			total.Kind = tools.ChangeModified
+		}
+	}
+	return totals
+}
+
+// changedFilesSummary lists the changed files with their line counts, at most
+// limit of them (0 = all), or "" when nothing was changed
+func (a *Agent) changedFilesSummary(limit int) string {
+	totals := a.ChangedFiles()
+	if len(totals) == 0 {
+		return ""
+	}
+	var summary strings.Builder
+	shown := totals
+	if limit > 0 && len(shown) > limit {
+		shown = shown[len(shown)-limit:]
+		fmt.Fprintf(&summary, "  [%d most recently changed of %d files]\n", limit, len(totals))
+	}
+	for _, total := range shown {
+		fmt.Fprintf(&summary, "• %s %s (+%d/-%d lines", total.Kind, total.Path, total.LinesAdded, total.LinesRemoved)
+		if total.Changes > 1 {
+			fmt.Fprintf(&summary, ", %d changes", total.Changes)
+		}
+		summary.WriteString(")\n")
+	}
+	return summary.String()
+}
+
+// SessionDiff renders the recorded changes as unified diffs, oldest first.
+// With paths given, only the changes to those files are included.
+func (a *Agent) SessionDiff(paths ...string) string {
+	wanted := make(map[string]bool)
+	for _, path := range paths {
+		wanted[filepath.Clean(path)] = true
+	}
+	var diff strings.Builder
+	for _, change := range a.ChangeRecords() {
+		if len(wanted) > 0 && !wanted[filepath.Clean(change.Path)] {
+			continue
+		}
+		diff.WriteString(change.Diff())
+	}
+	return diff.String()
+}
+
+// CommitContext describes the session's changes to files for a commit
+// message: the task, what the agent did and the recorded diffs. It reports
+// false when the change records don't account for every file's difference
+// from HEAD, for example after the user edited one, so the caller falls back
+// to the staged diff.
+func (a *Agent) CommitContext(files []string) (string, bool) {
+	if len(files) == 0 {
+		return "", false
+	}
+	first := make(map[string]tools.FileChange)
+	latest := make(map[string]tools.FileChange)
+	for _, change := range a.ChangeRecords() {
+		path := a.recordPath(change.Path)
+		if _, ok := first[path]; !ok {
+			first[path] = change
+		}
+		latest[path] = change
+	}
+	wanted := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		change, ok := latest[file]
+		if !ok || a.fileWatcher.IsStale(change.Path) {
+			return "", false
+		}
+		// The first change must have started from the committed file
+		if size, committed := headFileSize(file); committed != (first[file].Kind != tools.ChangeCreated) || size != first[file].BytesBefore {
+			return "", false
+		}
+		size := 0
+		if info, err := os.Stat(change.Path); err == nil {
+			size = int(info.Size())
+		} else if change.Kind != tools.ChangeDeleted {
+			return "", false
+		}
+		if size != change.BytesAfter {
+			return "", false
+		}
+		wanted[file] = true
+	}
+	var context strings.Builder
+	if len(a.messages) > 1 {
+		fmt.Fprintf(&context, "TASK:\n%s\n\n", a.messages[1].Content)
+	}
+	context.WriteString("WHAT THE AGENT DID:\n")
+	var paths []string
+	for _, action := range a.taskActions {
+		if action.Change != nil && wanted[a.recordPath(action.Change.Path)] {
+			fmt.Fprintf(&context, "- %s: %s\n", action.Description, action.Change.Summary())
+			paths = append(paths, action.Change.Path)
+		}
+	}
+	fmt.Fprintf(&context, "\nCHANGES:\n%s", a.SessionDiff(paths...))
+	return context.String(), true
+}
+
+// recordPath returns a change record's path relative to the working
+// directory, the form git reports paths in from the repository root
+func (a *Agent) recordPath(path string) string {
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil {
+				return rel
+			}
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// headFileSize returns the size of a file as committed at HEAD, and whether
+// HEAD has it
+func headFileSize(path string) (int, bool) {
+	output, err := exec.Command("git", "cat-file", "-s", "HEAD:./"+filepath.ToSlash(path)).Output()
+	if err != nil {
+		return 0, false
+	}
+	var size int
+	if _, err := fmt.Sscanf(string(output), "%d", &size); err != nil {
+		return 0, false
+	}
+	return size, true
+}
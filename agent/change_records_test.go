@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestDescribeChange(t *testing.T) {
+	before := []byte("package a\n\nfunc A() {}\n\nfunc B() {}\n\nfunc C() {}\n")
+	after := []byte("package a\n\nfunc A(x int) {}\n\nfunc B() {}\n\nfunc C() {}\nfunc D() {}\n")
+	change := tools.DescribeChange("a.go", before, after, true)
+	if change.Kind != tools.ChangeModified || change.LinesAdded != 2 || change.LinesRemoved != 1 || len(change.Hunks) != 2 {
+		t.Fatalf("expected 2 hunks, +2/-1 lines, got %+v", change)
+	}
+	if hunk := change.Hunks[0]; hunk.OldStart != 3 || hunk.NewStart != 3 || strings.Join(hunk.Lines, "|") != "-func A() {}|+func A(x int) {}" {
+		t.Errorf("unexpected first hunk %+v", hunk)
+	}
+	if hunk := change.Hunks[1]; hunk.OldStart != 7 || hunk.OldLines != 0 || hunk.NewStart != 8 || hunk.NewLines != 1 {
+		t.Errorf("unexpected second hunk %+v", hunk)
+	}
+
+	created := tools.DescribeChange("new.go", nil, []byte("one\ntwo\n"), false)
+	if created.Kind != tools.ChangeCreated || created.LinesAdded != 2 || created.BytesAfter != 8 || !strings.HasPrefix(created.Diff(), "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,2 @@\n+one\n") {
+		t.Errorf("unexpected record for a created file: %+v\n%s", created, created.Diff())
+	}
+	if deleted := tools.DescribeChange("old.go", []byte("x\n"), nil, true); deleted.Kind != tools.ChangeDeleted || deleted.LinesRemoved != 1 {
+		t.Errorf("unexpected record for a deleted file: %+v", deleted)
+	}
+}
+
+func TestToolChangeRecords(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	t.Chdir(t.TempDir())
+	agent.taskActions = nil
+
+	run := func(name, arguments string) {
+		t.Helper()
+		call := api.ToolCall{ID: "call_" + name, Type: "function"}
+		call.Function.Name, call.Function.Arguments = name, arguments
+		if _, err := agent.executeTool(call); err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+	}
+	run("write_file", `{"file_path": "notes.txt", "content": "alpha\nbeta\n"}`)
+	run("edit_file", `{"file_path": "notes.txt", "old_string": "beta", "new_string": "BETA\ngamma"}`)
+
+	records := agent.ChangeRecords()
+	if len(records) != 2 || records[0].Kind != tools.ChangeCreated || records[1].Kind != tools.ChangeModified {
+		t.Fatalf("expected a create and a modify record, got %+v", records)
+	}
+	if agent.taskActions[0].Type != "file_created" || agent.taskActions[1].Type != "file_modified" {
+		t.Errorf("expected the task actions to be typed from the records, got %+v", agent.taskActions)
+	}
+	totals := agent.ChangedFiles()
+	if len(totals) != 1 || totals[0].Kind != tools.ChangeCreated || totals[0].LinesAdded != 4 || totals[0].LinesRemoved != 1 || totals[0].Changes != 2 {
+		t.Errorf("unexpected totals %+v", totals)
+	}
+
+	diff := agent.SessionDiff(filepath.Join(".", "notes.txt"))
+	for _, want := range []string{"+++ b/notes.txt", "-beta", "+BETA", "+gamma"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("expected %q in the diff:\n%s", want, diff)
+		}
+	}
+	if agent.SessionDiff("other.txt") != "" {
+		t.Error("expected no diff for a file that wasn't changed")
+	}
+	if summary := agent.GenerateCompactSummary(); !strings.Contains(summary, "created notes.txt (+4/-1 lines, 2 changes)") {
+		t.Errorf("expected the continuity summary to use the change records:\n%s", summary)
+	}
+}
+
+func TestCommitContext(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	t.Chdir(t.TempDir())
+	os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.WriteFile("other.go", []byte("package main\n"), 0644)
+	if err := exec.Command("sh", "-c", "git init -q && git add . && git -c user.email=t@t -c user.name=t commit -qm init").Run(); err != nil {
+		t.Skipf("git unavailable: %v", err)
+	}
+	// The user had already changed other.go before the task
+	os.WriteFile("other.go", []byte("package main\n\nvar userEdit = 1\n"), 0644)
+
+	agent.taskActions = nil
+	agent.messages = []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "print a greeting"}}
+	for _, arguments := range []string{
+		`{"file_path": "main.go", "old_string": "func main() {}", "new_string": "func main() {\n\tprintln(\"hi\")\n}"}`,
+		`{"file_path": "other.go", "old_string": "var userEdit = 1", "new_string": "var userEdit = 2"}`,
+	} {
+		call := api.ToolCall{ID: "call_edit", Type: "function"}
+		call.Function.Name, call.Function.Arguments = "edit_file", arguments
+		if _, err := agent.executeTool(call); err != nil {
+			t.Fatalf("edit failed: %v", err)
+		}
+	}
+
+	context, ok := agent.CommitContext([]string{"main.go"})
+	if !ok {
+		t.Fatal("expected the records to cover main.go")
+	}
+	for _, want := range []string{"print a greeting", "Edited main.go", "+\tprintln(\"hi\")"} {
+		if !strings.Contains(context, want) {
+			t.Errorf("expected %q in the commit context:\n%s", want, context)
+		}
+	}
+	if _, ok := agent.CommitContext([]string{"main.go", "other.go"}); ok {
+		t.Error("expected the user's earlier edit to other.go to require the staged diff")
+	}
+	if _, ok := agent.CommitContext([]string{"main.go", "README.md"}); ok {
+		t.Error("expected a file without records to require the staged diff")
+	}
+	os.WriteFile("main.go", []byte("package main\n\nfunc main() { println(\"changed by the user\") }\n"), 0644)
+	if _, ok := agent.CommitContext([]string{"main.go"}); ok {
+		t.Error("expected a later edit to main.go to require the staged diff")
+	}
+}
@@ -0,0 +1,250 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	prefCheckpoints     = "checkpoints"      // checkpoint the working tree before each task (default true)
+	prefCheckpointLimit = "checkpoint_limit" // checkpoints kept per repository
+
+	defaultCheckpointLimit = 20
+	checkpointRefPrefix    = "refs/coder/checkpoints/"
+	checkpointSubject      = "coder checkpoint: "
+)
+
+// Checkpoint is a snapshot of a repository's working tree, kept as a commit
+// under refs/coder/checkpoints so a whole task can be rolled back
+type Checkpoint struct {
+	Ref    string
+	Commit string
+	Tree   string
+	Time   time.Time
+	Label  string // The task it was taken before
+}
+
+// checkpointBeforeTask checkpoints the working tree before a task runs,
+// unless checkpoints are turned off or this isn't a git repository
+func (a *Agent) checkpointBeforeTask(task string) {
+	if a.configManager != nil && !a.configManager.GetConfig().GetBoolPreference(prefCheckpoints, true) {
+		return
+	}
+	root, err := GitRoot(".")
+	if err != nil {
+		return
+	}
+	checkpoint, created, err := CreateCheckpoint(root, task, a.CheckpointLimit())
+	if err != nil {
+		a.debugLog("⚠️  Checkpoint failed: %v\n", err)
+		return
+	}
+	if created {
+		a.debugLog("📍 Checkpoint %s before the task\n", checkpoint.Commit[:min(len(checkpoint.Commit), 12)])
+	}
+}
+
+// CheckpointLimit returns how many checkpoints to keep per repository
+func (a *Agent) CheckpointLimit() int {
+	if a.configManager == nil {
+		return defaultCheckpointLimit
+	}
+	return a.configManager.GetConfig().GetIntPreference(prefCheckpointLimit, defaultCheckpointLimit)
+}
+
+// GitRoot returns the top-level directory of the repository containing dir
+func GitRoot(dir string) (string, error) {
+	return gitIn(dir, nil, "rev-parse", "--show-toplevel")
+}
+
+// gitIn runs git in dir with extra environment and returns its trimmed output
+func gitIn(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// snapshotTree writes the working tree, tracked and untracked files but not
+// ignored ones, to a git tree object. It works on a copy of the index, so
+// the user's staged changes are left alone.
+func snapshotTree(root string) (string, error) {
+	file, err := os.CreateTemp("", "coder-checkpoint-*.index")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	indexPath := file.Name()
+	defer os.Remove(indexPath)
+
+	// Starting from the real index lets git skip hashing unchanged files
+	copied := false
+	if realIndex, err := gitIn(root, nil, "rev-parse", "--git-path", "index"); err == nil {
+		if !filepath.IsAbs(realIndex) {
+			realIndex = filepath.Join(root, realIndex)
+		}
+		if source, err := os.Open(realIndex); err == nil {
+			_, err = io.Copy(file, source)
+			source.Close()
+			copied = err == nil
+		}
+	}
+	file.Close()
+	if !copied {
+		os.Remove(indexPath) // git creates a fresh index; an empty file isn't one
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + indexPath}
+	if _, err := gitIn(root, env, "add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	return gitIn(root, env, "write-tree")
+}
+
+// CreateCheckpoint snapshots the working tree of the repository at root. It
+// reports false, returning the latest checkpoint, when nothing changed since
+// that one. Checkpoints beyond limit are dropped, oldest first.
+func CreateCheckpoint(root, label string, limit int) (Checkpoint, bool, error) {
+	tree, err := snapshotTree(root)
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	checkpoints, err := ListCheckpoints(root)
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	if len(checkpoints) > 0 && checkpoints[0].Tree == tree {
+		return checkpoints[0], false, nil
+	}
+
+	label = strings.Join(strings.Fields(label), " ")
+	if len(label) > 200 {
+		label = label[:200] + "..."
+	}
+	args := []string{"commit-tree", "--no-gpg-sign", tree, "-m", checkpointSubject + label}
+	if head, err := gitIn(root, nil, "rev-parse", "--verify", "-q", "HEAD"); err == nil && head != "" {
+		args = append(args, "-p", head)
+	}
+	// Checkpoints are coder's own commits and never need the user's identity
+	identity := []string{"GIT_AUTHOR_NAME=coder", "GIT_AUTHOR_EMAIL=coder@localhost", "GIT_COMMITTER_NAME=coder", "GIT_COMMITTER_EMAIL=coder@localhost"}
+	commit, err := gitIn(root, identity, args...)
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	now := time.Now()
+	checkpoint := Checkpoint{Ref: fmt.Sprintf("%s%d", checkpointRefPrefix, now.UnixNano()), Commit: commit, Tree: tree, Time: now, Label: label}
+	if _, err := gitIn(root, nil, "update-ref", checkpoint.Ref, commit); err != nil {
+		return Checkpoint{}, false, err
+	}
+
+	if limit > 0 && len(checkpoints)+1 > limit {
+		for _, old := range checkpoints[limit-1:] {
+			gitIn(root, nil, "update-ref", "-d", old.Ref)
+		}
+	}
+	return checkpoint, true, nil
+}
+
+// ListCheckpoints returns the repository's checkpoints, newest first
+func ListCheckpoints(root string) ([]Checkpoint, error) {
+	output, err := gitIn(root, nil, "for-each-ref", "--sort=-refname",
+		"--format=%(refname)%09%(objectname)%09%(tree)%09%(contents:subject)", checkpointRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []Checkpoint
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		nanos, _ := strconv.ParseInt(strings.TrimPrefix(fields[0], checkpointRefPrefix), 10, 64)
+		checkpoints = append(checkpoints, Checkpoint{
+			Ref:    fields[0],
+			Commit: fields[1],
+			Tree:   fields[2],
+			Time:   time.Unix(0, nanos),
+			Label:  strings.TrimPrefix(fields[3], checkpointSubject),
+		})
+	}
+	return checkpoints, nil
+}
+
+// RestoreCheckpoint puts the working tree of the repository at root back to
+// a checkpoint: files changed or deleted since get their checkpointed
+// content and files created since are removed. Ignored files and the index
+// are left alone. The current state is checkpointed first, so the restore
+// can be rolled back too. It returns the paths it changed.
+func RestoreCheckpoint(root string, checkpoint Checkpoint, limit int) ([]string, error) {
+	current, _, err := CreateCheckpoint(root, "before restoring the checkpoint from "+checkpoint.Time.Format("2006-01-02 15:04:05"), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkpoint the current state: %w", err)
+	}
+	output, err := gitIn(root, nil, "diff-tree", "-r", "--no-renames", "--name-status", "-z", checkpoint.Tree, current.Tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var restore, remove []string
+	fields := strings.Split(strings.TrimSuffix(output, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "A" {
+			remove = append(remove, fields[i+1])
+		} else {
+			restore = append(restore, fields[i+1])
+		}
+	}
+
+	for _, path := range remove {
+		full := filepath.Join(root, path)
+		if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		// Drop directories the removed files leave empty
+		for dir := filepath.Dir(full); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	if len(restore) > 0 {
+		cmd := exec.Command("git", "restore", "--source="+checkpoint.Commit, "--worktree", "--pathspec-from-file=-", "--pathspec-file-nul")
+		cmd.Dir = root
+		cmd.Stdin = strings.NewReader(strings.Join(restore, "\x00"))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("git restore: %s", strings.TrimSpace(string(output)))
+		}
+	}
+	return append(restore, remove...), nil
+}
+
+// RestoreCheckpoint rolls the working tree back to a checkpoint and forgets
+// what the agent read of the files it changed
+func (a *Agent) RestoreCheckpoint(checkpoint Checkpoint) ([]string, error) {
+	root, err := GitRoot(".")
+	if err != nil {
+		return nil, fmt.Errorf("checkpoints need a git repository: %w", err)
+	}
+	paths, err := RestoreCheckpoint(root, checkpoint, a.CheckpointLimit())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		full := filepath.Join(root, path)
+		a.fileWatcher.Track(full)
+		a.invalidateModifiedFile(full)
+	}
+	return paths, nil
+}
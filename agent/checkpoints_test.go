@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+		return string(output)
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n"), 0644)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(root, "old.go"), []byte("package old\n"), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	os.WriteFile(filepath.Join(root, "draft.txt"), []byte("untracked work\n"), 0644)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\n// staged\n"), 0644)
+	git("add", "main.go")
+
+	checkpoint, created, err := CreateCheckpoint(root, "add a feature", 5)
+	if err != nil || !created {
+		t.Fatalf("expected a checkpoint, got %v (%v)", created, err)
+	}
+	if _, created, _ := CreateCheckpoint(root, "again", 5); created {
+		t.Error("expected no new checkpoint when nothing changed")
+	}
+	if staged := git("diff", "--cached", "--name-only"); strings.TrimSpace(staged) != "main.go" {
+		t.Errorf("expected the user's index to be left alone, got %q", staged)
+	}
+
+	// The task edits, creates and deletes files
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package broken\n"), 0644)
+	os.MkdirAll(filepath.Join(root, "pkg", "gen"), 0755)
+	os.WriteFile(filepath.Join(root, "pkg", "gen", "new.go"), []byte("package gen\n"), 0644)
+	os.Remove(filepath.Join(root, "old.go"))
+	os.WriteFile(filepath.Join(root, "build.log"), []byte("ignored\n"), 0644)
+
+	paths, err := RestoreCheckpoint(root, checkpoint, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Errorf("expected 3 restored paths, got %v", paths)
+	}
+	for path, want := range map[string]string{"main.go": "package main\n\n// staged\n", "old.go": "package old\n", "draft.txt": "untracked work\n", "build.log": "ignored\n"} {
+		if data, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(data) != want {
+			t.Errorf("expected %s to hold %q, got %q (%v)", path, want, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "pkg")); !os.IsNotExist(err) {
+		t.Error("expected the created file and its directories to be removed")
+	}
+
+	checkpoints, err := ListCheckpoints(root)
+	if err != nil || len(checkpoints) != 2 {
+		t.Fatalf("expected the task and pre-restore checkpoints, got %+v (%v)", checkpoints, err)
+	}
+	if !strings.HasPrefix(checkpoints[0].Label, "before restoring") || checkpoints[1].Label != "add a feature" {
+		t.Errorf("unexpected checkpoint labels: %q, %q", checkpoints[0].Label, checkpoints[1].Label)
+	}
+
+	// Restoring the pre-restore checkpoint brings the task's work back
+	if _, err := RestoreCheckpoint(root, checkpoints[0], 5); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "pkg", "gen", "new.go")); string(data) != "package gen\n" {
+		t.Errorf("expected the task's new file back, got %q", data)
+	}
+}
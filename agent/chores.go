@@ -0,0 +1,273 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// maxChoreFixRounds bounds how often the agent is asked to fix a failed verification
+const maxChoreFixRounds = 2
+
+// Verification steps that run the project's own build and test commands
+const (
+	choreVerifyBuild = "build"
+	choreVerifyTest  = "test"
+)
+
+// ChoreParam is a parameter of a chore, passed as name=value
+type ChoreParam struct {
+	Name        string
+	Description string
+	Default     string // Used when the parameter isn't given
+	Required    bool
+}
+
+// Chore is a built-in maintenance workflow: a prompt with parameters, the
+// tools it may use and the checks that must pass once it is done
+type Chore struct {
+	Name        string
+	Description string
+	Params      []ChoreParam
+	Tools       []string // Tool preset entries, as in tool_presets
+	Prompt      string   // {name} is replaced by the value of parameter name
+	Verify      []string // Shell commands; "build" and "test" run the project's own
+}
+
+// ChoreCheck is the outcome of one verification step
+type ChoreCheck struct {
+	Command string
+	Output  string
+	Err     error
+	Skipped bool // No build or test command is known for the project
+}
+
+// ChoreResult is the outcome of running a chore
+type ChoreResult struct {
+	Reply     string       // The agent's final answer
+	Checks    []ChoreCheck // The last verification
+	FixRounds int          // Times the agent was asked to fix a failed verification
+}
+
+// Passed reports whether every verification step passed or was skipped
+func (r *ChoreResult) Passed() bool {
+	for _, check := range r.Checks {
+		if check.Err != nil && !check.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// choreEditTools read, search and edit files and run commands
+var choreEditTools = []string{"shell_command", "read_file", "edit_file", "edit_file_multi", "apply_patch", "list_directory", "glob", "search_code", "list_targets", "undo_last_change"}
+
+// builtinChores are the chores coder ships with
+var builtinChores = []Chore{
+	{
+		Name:        "copyright",
+		Description: "Update the year in copyright headers",
+		Params: []ChoreParam{
+			{Name: "year", Description: "The year headers should end with", Default: strconv.Itoa(time.Now().Year())},
+			{Name: "holder", Description: "Copyright holder to set (default: keep each header's holder)"},
+		},
+		Tools: choreEditTools,
+		Prompt: `Update the copyright headers of this project to the year {year}.
+
+1. Find the headers with search_code (e.g. "Copyright" in the comments at the top of source files) and the copyright line of the LICENSE file.
+2. Make each header end with {year}: "Copyright 2021 Acme" becomes "Copyright 2021-{year} Acme" and "2019-2023" becomes "2019-{year}". Leave headers already ending with {year} alone and keep each file's header style.
+3. Copyright holder: "{holder}". If it is empty, keep the holder each header names.
+
+Only change copyright lines. Skip vendored, third-party and generated ("DO NOT EDIT") files, and don't add headers to files without one. Reply with the number of files updated.`,
+		Verify: []string{"git diff --check", choreVerifyBuild},
+	},
+	{
+		Name:        "go-version",
+		Description: "Bump the Go version in go.mod files, CI and Dockerfiles",
+		Params: []ChoreParam{
+			{Name: "version", Description: "The Go version to move to, e.g. 1.24", Required: true},
+		},
+		Tools: choreEditTools,
+		Prompt: `Bump the Go version of this project to {version}.
+
+1. For each go.mod (glob "**/go.mod"), run "go mod edit -go={version}" in its directory, drop a toolchain line older than {version}, then run "go mod tidy". Update go.work the same way.
+2. Use search_code to update the other places that pin the Go version: CI workflows (go-version of setup-go, build matrices), Dockerfiles (FROM golang:...), .tool-versions, Makefiles and build instructions in the README.
+
+Don't rewrite code to use new language features. Reply with the files you changed.`,
+		Verify: []string{choreVerifyBuild, "go vet ./...", choreVerifyTest},
+	},
+	{
+		Name:        "mocks",
+		Description: "Regenerate mocks with the project's generator",
+		Params: []ChoreParam{
+			{Name: "packages", Description: "Packages to regenerate", Default: "./..."},
+		},
+		Tools: []string{"shell_command", "read_file", "list_directory", "glob", "search_code", "list_targets"},
+		Prompt: `Regenerate the mocks of {packages}.
+
+1. Find how the project generates them: //go:generate directives running mockgen, mockery or moq (search_code for "go:generate"), a .mockery.yaml, or a make/task target (list_targets).
+2. Run that generator, e.g. "go generate -run 'mockgen|mockery|moq' {packages}". If it isn't installed, run the version the project pins (go.mod, tools.go) with "go run <module>@<version>".
+3. Never edit generated mock files by hand. If the generator fails, report why instead.
+
+Reply with the mock files that changed.`,
+		Verify: []string{choreVerifyBuild, "go vet ./...", choreVerifyTest},
+	},
+	{
+		Name:        "deadcode",
+		Description: "Remove unreachable functions reported by deadcode",
+		Params: []ChoreParam{
+			{Name: "packages", Description: "Main packages to analyze", Default: "./..."},
+			{Name: "flags", Description: "deadcode flags; -test counts tests as reachable code", Default: "-test"},
+		},
+		Tools: choreEditTools,
+		Prompt: `Remove the dead code of this project.
+
+1. Run "deadcode {flags} {packages}" (or "go run golang.org/x/tools/cmd/deadcode@latest {flags} {packages}" if it isn't installed) to list unreachable functions.
+2. Remove each of them with its doc comment, plus the types, variables, constants and imports only it used.
+3. Keep functions other modules may use (exported from library packages), and those used through reflection, cgo exports, go:linkname or build tags deadcode didn't analyze. Say which you kept and why.
+4. Run deadcode again, since removing code can make more of it unreachable.
+
+Reply with what you removed.`,
+		Verify: []string{choreVerifyBuild, "go vet ./...", choreVerifyTest},
+	},
+}
+
+// Chores returns the built-in chores sorted by name
+func Chores() []Chore {
+	chores := append([]Chore(nil), builtinChores...)
+	sort.Slice(chores, func(i, j int) bool { return chores[i].Name < chores[j].Name })
+	return chores
+}
+
+// FindChore returns the built-in chore with a name
+func FindChore(name string) (Chore, bool) {
+	for _, chore := range builtinChores {
+		if chore.Name == name {
+			return chore, true
+		}
+	}
+	return Chore{}, false
+}
+
+// Render fills the chore's prompt with parameter values, using defaults for
+// those not given
+func (c Chore) Render(values map[string]string) (string, error) {
+	known := make(map[string]bool)
+	var replacements []string
+	for _, param := range c.Params {
+		known[param.Name] = true
+		value, ok := values[param.Name]
+		if !ok || value == "" {
+			value = param.Default
+		}
+		if value == "" && param.Required {
+			return "", fmt.Errorf("chore %s needs %s=<value> (%s)", c.Name, param.Name, param.Description)
+		}
+		replacements = append(replacements, "{"+param.Name+"}", value)
+	}
+	for name := range values {
+		if !known[name] {
+			return "", fmt.Errorf("chore %s has no parameter %s", c.Name, name)
+		}
+	}
+	return strings.NewReplacer(replacements...).Replace(c.Prompt), nil
+}
+
+// RunChore runs a chore with the given parameter values and verifies the
+// result, asking the agent to fix failed checks up to maxChoreFixRounds
+// times. The chore's tools are enabled for the run unless a tool preset was
+// applied.
+func (a *Agent) RunChore(chore Chore, values map[string]string) (*ChoreResult, error) {
+	prompt, err := chore.Render(values)
+	if err != nil {
+		return nil, err
+	}
+	if a.toolPreset == "" && len(chore.Tools) > 0 {
+		previous := a.disabledTools
+		defer func() { a.disabledTools = previous }()
+		if err := a.enableTools("chore "+chore.Name, chore.Tools); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := a.ProcessQuery(prompt)
+	if err != nil {
+		return nil, err
+	}
+	result := &ChoreResult{Reply: reply}
+	for {
+		result.Checks = a.verifyChore(chore)
+		if result.Passed() || result.FixRounds == maxChoreFixRounds {
+			return result, nil
+		}
+		result.FixRounds++
+		fmt.Printf("🔧 Verification failed - asking the agent to fix it (round %d/%d)\n", result.FixRounds, maxChoreFixRounds)
+		if reply, err = a.ProcessQuery(choreFixPrompt(chore, result.Checks)); err != nil {
+			return result, err
+		}
+		result.Reply = reply
+	}
+}
+
+// verifyChore runs the chore's verification steps, stopping at the first failure
+func (a *Agent) verifyChore(chore Chore) []ChoreCheck {
+	wd, _ := os.Getwd()
+	var checks []ChoreCheck
+	for _, command := range chore.Verify {
+		check := ChoreCheck{Command: command}
+		if command == choreVerifyBuild || command == choreVerifyTest {
+			check.Command = ""
+			for _, pack := range tools.DetectLanguagePacks(wd) {
+				if command == choreVerifyBuild {
+					check.Command = pack.BuildCommand()
+				} else {
+					check.Command = pack.TestCommand()
+				}
+				if check.Command != "" {
+					break
+				}
+			}
+			if check.Command == "" {
+				check.Command, check.Skipped = command, true
+				check.Err = fmt.Errorf("no %s command is known for this project", command)
+				checks = append(checks, check)
+				continue
+			}
+		}
+		a.debugLog("🔎 Verifying chore %s: %s\n", chore.Name, check.Command)
+		check.Output, check.Err = tools.ExecuteShellCommand(a.operationContext(), check.Command)
+		checks = append(checks, check)
+		if check.Err != nil {
+			break
+		}
+	}
+	return checks
+}
+
+// choreFixPrompt asks the agent to fix the failed verification of a chore
+func choreFixPrompt(chore Chore, checks []ChoreCheck) string {
+	var failed ChoreCheck
+	for _, check := range checks {
+		if check.Err != nil && !check.Skipped {
+			failed = check
+		}
+	}
+	output := strings.TrimSpace(failed.Output)
+	if len(output) > 6000 {
+		output = "...\n" + output[len(output)-6000:]
+	}
+	if output == "" {
+		output = failed.Err.Error()
+	}
+	return fmt.Sprintf(`Verifying the %s chore failed: "%s" did not pass.
+
+Output:
+%s
+
+Fix the problems while keeping the chore's changes. Reply with what you fixed.`, chore.Name, failed.Command, output)
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChoreRender(t *testing.T) {
+	chore, ok := FindChore("go-version")
+	if !ok {
+		t.Fatal("expected the go-version chore")
+	}
+	if _, err := chore.Render(nil); err == nil || !strings.Contains(err.Error(), "version=") {
+		t.Errorf("expected the required version to be asked for, got %v", err)
+	}
+	if _, err := chore.Render(map[string]string{"version": "1.24", "verison": "1.25"}); err == nil {
+		t.Error("expected an unknown parameter to fail")
+	}
+	prompt, err := chore.Render(map[string]string{"version": "1.24"})
+	if err != nil || !strings.Contains(prompt, "go mod edit -go=1.24") || strings.Contains(prompt, "{version}") {
+		t.Errorf("expected the version in the prompt, got %v:\n%s", err, prompt)
+	}
+
+	copyright, _ := FindChore("copyright")
+	prompt, _ = copyright.Render(map[string]string{"holder": "Acme Inc"})
+	if strings.Contains(prompt, "{year}") || !strings.Contains(prompt, `"Acme Inc"`) {
+		t.Errorf("expected the default year and the holder in the prompt:\n%s", prompt)
+	}
+}
+
+func TestChoreToolsAndVerification(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	for _, chore := range Chores() {
+		if err := agent.enableTools(chore.Name, chore.Tools); err != nil {
+			t.Errorf("chore %s: %v", chore.Name, err)
+		}
+	}
+	mocks, _ := FindChore("mocks")
+	agent.enableTools(mocks.Name, mocks.Tools)
+	if !agent.disabledTools["edit_file"] || agent.disabledTools["shell_command"] {
+		t.Error("expected the mocks chore to run generators but not edit files")
+	}
+
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+	result := &ChoreResult{Checks: agent.verifyChore(Chore{Name: "test", Verify: []string{choreVerifyBuild, "true", "echo broken && false", "echo unreached"}})}
+	if len(result.Checks) != 3 || !result.Checks[0].Skipped || result.Checks[1].Err != nil || result.Checks[2].Err == nil {
+		t.Fatalf("expected a skipped build, a passing and a failing check, got %+v", result.Checks)
+	}
+	if result.Passed() {
+		t.Error("expected the failed check to fail the chore")
+	}
+	if prompt := choreFixPrompt(Chore{Name: "test"}, result.Checks); !strings.Contains(prompt, "echo broken && false") || !strings.Contains(prompt, "broken") {
+		t.Errorf("expected the failed command and its output in the fix prompt:\n%s", prompt)
+	}
+}
@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The CLI tests drive the coder binary with scripts in testdata/cli. Each
+// script runs in a copy of test_environment/baseline_files, with the model's
+// responses replayed from the script's replay.json section. The format follows
+// testscript: commands first, then "-- name --" sections holding files.
+//
+//	exec <args>            run coder; "! exec" expects a non-zero exit code
+//	stdin <file>           pipe a section's content into the next exec
+//	stdout|stderr <regex>  the last exec's output matches ("! stdout" must not)
+//	exists <path>          a file exists in the work directory ("! exists" must not)
+//	grep <regex> <path>    a file's content matches ("! grep" must not)
+//
+// Set CODER_CLI_VERBOSE=1 to print each exec's output while writing scripts.
+
+// cliScript is a parsed CLI test script
+type cliScript struct {
+	commands []cliCommand
+	files    map[string]string
+}
+
+// cliCommand is one script line
+type cliCommand struct {
+	line    int
+	negate  bool
+	name    string
+	args    []string
+	literal string // the line, for failure messages
+}
+
+// parseCLIScript splits a script into commands and files
+func parseCLIScript(content string) (*cliScript, error) {
+	script := &cliScript{files: make(map[string]string)}
+	sectionHeader := regexp.MustCompile(`^-- (.+) --$`)
+	current := ""
+	for i, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if m := sectionHeader.FindStringSubmatch(trimmed); m != nil {
+			current = m[1]
+			script.files[current] = ""
+			continue
+		}
+		if current != "" {
+			script.files[current] += line
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		args, err := splitCLIArgs(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		command := cliCommand{line: i + 1, literal: trimmed}
+		if args[0] == "!" {
+			command.negate, args = true, args[1:]
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("line %d: missing command after !", i+1)
+		}
+		command.name, command.args = args[0], args[1:]
+		script.commands = append(script.commands, command)
+	}
+	return script, nil
+}
+
+// splitCLIArgs splits a line into words; single quotes group words
+func splitCLIArgs(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord, quoted := false, false
+	for _, r := range line {
+		switch {
+		case r == '\'':
+			quoted, inWord = !quoted, true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// buildCoderBinary builds the CLI once for all scripts
+func buildCoderBinary(t *testing.T) string {
+	t.Helper()
+	binary := filepath.Join(t.TempDir(), "coder")
+	cmd := exec.Command("go", "build", "-o", binary, ".")
+	cmd.Dir = ".."
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build coder: %v\n%s", err, output)
+	}
+	return binary
+}
+
+// copyFixtures copies the files of a fixture directory into dir
+func copyFixtures(t *testing.T, fixtures, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(fixtures)
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(fixtures, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCLIScripts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("CLI scripts build the coder binary")
+	}
+	scripts, err := filepath.Glob(filepath.Join("testdata", "cli", "*.txt"))
+	if err != nil || len(scripts) == 0 {
+		t.Fatalf("no CLI scripts found: %v", err)
+	}
+	binary := buildCoderBinary(t)
+
+	for _, path := range scripts {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			script, err := parseCLIScript(string(content))
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+			runCLIScript(t, binary, script)
+		})
+	}
+}
+
+// runCLIScript runs a script's commands in a fresh work directory
+func runCLIScript(t *testing.T, binary string, script *cliScript) {
+	home, work, scratch := t.TempDir(), t.TempDir(), t.TempDir()
+	copyFixtures(t, filepath.Join("..", "test_environment", "baseline_files"), work)
+
+	replay := filepath.Join(scratch, "replay.json")
+	if err := os.WriteFile(replay, []byte(script.files["replay.json"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range script.files {
+		if name == "replay.json" || strings.HasPrefix(name, "stdin/") {
+			continue
+		}
+		target := filepath.Join(work, name)
+		os.MkdirAll(filepath.Dir(target), 0755)
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := []string{
+		"HOME=" + home,
+		"PATH=" + os.Getenv("PATH"),
+		"OPENROUTER_API_KEY=test-key",
+		"CODER_REPLAY_FILE=" + replay,
+	}
+	var stdout, stderr, stdin string
+	for _, command := range script.commands {
+		fail := func(format string, args ...interface{}) {
+			t.Helper()
+			t.Fatalf("line %d: %s: %s\nstdout:\n%s\nstderr:\n%s", command.line, command.literal, strings.TrimSpace(fmt.Sprintf(format, args...)), stdout, stderr)
+		}
+		switch command.name {
+		case "stdin":
+			if len(command.args) != 1 {
+				fail("usage: stdin <section>")
+			}
+			content, ok := script.files[command.args[0]]
+			if !ok {
+				fail("no section %q", command.args[0])
+			}
+			stdin = content
+
+		case "exec":
+			cmd := exec.Command(binary, command.args...)
+			cmd.Dir = work
+			cmd.Env = env
+			cmd.Stdin = strings.NewReader(stdin) // never a terminal, so nothing waits for input
+			var out, errOut bytes.Buffer
+			cmd.Stdout, cmd.Stderr = &out, &errOut
+			err := cmd.Run()
+			stdout, stderr, stdin = out.String(), errOut.String(), ""
+			if os.Getenv("CODER_CLI_VERBOSE") != "" {
+				t.Logf("exec %s\nstdout:\n%s\nstderr:\n%s", strings.Join(command.args, " "), stdout, stderr)
+			}
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				fail("failed to run coder: %v", err)
+			}
+			if failed := err != nil; failed != command.negate {
+				fail("unexpected exit status: %v", err)
+			}
+
+		case "stdout", "stderr":
+			if len(command.args) != 1 {
+				fail("usage: %s <regex>", command.name)
+			}
+			output := stdout
+			if command.name == "stderr" {
+				output = stderr
+			}
+			pattern, err := regexp.Compile(`(?m)` + command.args[0])
+			if err != nil {
+				fail("bad regex: %v", err)
+			}
+			if pattern.MatchString(output) == command.negate {
+				fail("%s", map[bool]string{false: "no match", true: "unexpected match"}[command.negate])
+			}
+
+		case "exists":
+			if len(command.args) != 1 {
+				fail("usage: exists <path>")
+			}
+			_, err := os.Stat(filepath.Join(work, command.args[0]))
+			if (err == nil) == command.negate {
+				fail("%s", map[bool]string{false: "file doesn't exist", true: "file exists"}[command.negate])
+			}
+
+		case "grep":
+			if len(command.args) != 2 {
+				fail("usage: grep <regex> <path>")
+			}
+			data, err := os.ReadFile(filepath.Join(work, command.args[1]))
+			if err != nil {
+				fail("%v", err)
+			}
+			pattern, err := regexp.Compile(`(?m)` + command.args[0])
+			if err != nil {
+				fail("bad regex: %v", err)
+			}
+			if pattern.Match(data) == command.negate {
+				fail("%s in:\n%s", map[bool]string{false: "no match", true: "unexpected match"}[command.negate], data)
+			}
+
+		default:
+			fail("unknown command %q", command.name)
+		}
+	}
+}
+
+func TestParseCLIScript(t *testing.T) {
+	script, err := parseCLIScript("# comment\nexec 'add a route' --turns=3\n! stdout 'Error: .*'\n\n-- replay.json --\n{\"responses\": []}\n-- stdin/query --\nhello\n")
+	if err != nil {
+		t.Fatalf("parseCLIScript: %v", err)
+	}
+	if len(script.commands) != 2 {
+		t.Fatalf("expected 2 commands, got %+v", script.commands)
+	}
+	if exec := script.commands[0]; exec.name != "exec" || len(exec.args) != 2 || exec.args[0] != "add a route" {
+		t.Errorf("exec command: %+v", exec)
+	}
+	if stdout := script.commands[1]; !stdout.negate || stdout.args[0] != "Error: .*" {
+		t.Errorf("stdout command: %+v", stdout)
+	}
+	if script.files["replay.json"] != "{\"responses\": []}\n" || script.files["stdin/query"] != "hello\n" {
+		t.Errorf("files: %q", script.files)
+	}
+	if _, err := parseCLIScript("exec 'unterminated\n"); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
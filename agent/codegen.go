@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// Preferences for generated code
+const (
+	prefCodegenCommand      = "codegen_command"       // command that regenerates code from the schemas
+	prefAllowGeneratedEdits = "allow_generated_edits" // let write_file/edit_file change generated files
+)
+
+// generatedFileError refuses an edit of a generated file, pointing the model at
+// the schema and regenerate_code instead
+func (a *Agent) generatedFileError(filePath string) error {
+	if a.configManager != nil && a.configManager.GetConfig().GetBoolPreference(prefAllowGeneratedEdits, false) {
+		return nil
+	}
+	if _, err := os.Stat(filePath); err != nil || !tools.IsGeneratedFile(filePath) {
+		return nil
+	}
+	return fmt.Errorf("%s is generated code - change the .proto or OpenAPI spec it is generated from, then run regenerate_code", filePath)
+}
+
+// regenerateCode runs the project's code generator, lists the files it changed
+// and verifies the result with the project's build command
+func (a *Agent) regenerateCode() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	configured := ""
+	if a.configManager != nil {
+		configured = a.configManager.GetConfig().GetStringPreference(prefCodegenCommand, "")
+	}
+	command, source := tools.DetectCodegenCommand(wd, configured)
+	if command == "" {
+		return "", fmt.Errorf("no code generator found - add a buf.gen.yaml or openapitools.json, or set the %s preference", prefCodegenCommand)
+	}
+
+	before := gitStatusLines()
+	a.ToolLog("regenerating code", command)
+	output, err := tools.ExecuteShellCommand(a.operationContext(), command)
+	if err != nil {
+		return "", fmt.Errorf("code generation failed (%s from %s): %w", command, source, err)
+	}
+
+	var changed []string
+	for line := range gitStatusLines() {
+		if !before[line] && len(line) > 3 {
+			path := line[3:]
+			changed = append(changed, path)
+			a.invalidateModifiedFile(path)
+		}
+	}
+	sort.Strings(changed)
+
+	var result strings.Builder
+	fmt.Fprintf(&result, "Ran %s (from %s).\n", command, source)
+	if trimmed := strings.TrimSpace(output); trimmed != "" {
+		fmt.Fprintf(&result, "%s\n", truncateTranscript(trimmed, 2000))
+	}
+	if len(changed) == 0 {
+		result.WriteString("No files changed - the generated code was already up to date.\n")
+	} else {
+		fmt.Fprintf(&result, "Changed %d file(s):\n  %s\n", len(changed), strings.Join(changed, "\n  "))
+	}
+
+	// Verify the regenerated code with the first language's build
+	for _, pack := range tools.DetectLanguagePacks(wd) {
+		build := pack.BuildCommand()
+		if build == "" || !tools.CommandAvailable(build) {
+			continue
+		}
+		if buildOutput, err := a.executeShellCommandWithTruncation(build, "Verify the regenerated code builds"); err != nil {
+			fmt.Fprintf(&result, "❌ VERIFICATION FAILED: %s\n%s\n", build, strings.TrimSpace(err.Error()))
+		} else {
+			fmt.Fprintf(&result, "✅ Verified: %s passed\n%s", build, strings.TrimSpace(buildOutput))
+		}
+		break
+	}
+	return strings.TrimRight(result.String(), "\n"), nil
+}
+
+// gitStatusLines returns the lines of git status --porcelain as a set
+func gitStatusLines() map[string]bool {
+	lines := make(map[string]bool)
+	output, err := exec.Command("git", "status", "--porcelain", "--untracked-files=all").Output()
+	if err != nil {
+		return lines
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			lines[line] = true
+		}
+	}
+	return lines
+}
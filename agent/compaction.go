@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// compactKeepTurns is how many of the latest assistant turns compaction keeps verbatim
+const compactKeepTurns = aggressiveKeepTurns
+
+// minCompactMessages is the fewest older messages worth a summary request
+const minCompactMessages = 4
+
+// maxCompactMessageChars bounds each message in the transcript sent to be summarized
+const maxCompactMessageChars = 6000
+
+// prefAutoCompact turns automatic compaction near the context limit on or off
+const prefAutoCompact = "auto_compact"
+
+// compactSummaryHeader starts the system note that replaces compacted turns
+const compactSummaryHeader = "CONVERSATION SUMMARY (earlier turns were summarized to fit the context window; read files again before editing them):"
+
+// ErrNothingToCompact is returned when too few older turns exist to summarize
+var ErrNothingToCompact = errors.New("not enough earlier conversation to compact")
+
+// CompactionResult describes what a compaction replaced
+type CompactionResult struct {
+	Messages     int // Older messages replaced by the summary
+	TokensBefore int // Conversation tokens before compaction
+	TokensAfter  int // Conversation tokens after compaction
+}
+
+// CompactConversation asks the model to summarize the older turns of the
+// conversation into a system note. The system prompt, the original request
+// and the latest turns are kept verbatim; an earlier summary is folded into
+// the new one.
+func (a *Agent) CompactConversation() (*CompactionResult, error) {
+	if len(a.messages) <= 2 {
+		return nil, ErrNothingToCompact
+	}
+	end := recentTurnStart(a.messages, compactKeepTurns)
+	if end-2 < minCompactMessages {
+		return nil, ErrNothingToCompact
+	}
+
+	before := a.estimateContextTokens(a.messages)
+	// The summary isn't streamed: it is for the conversation, not the terminal
+	resp, err := a.client.SendChatRequest(a.operationContext(), []api.Message{
+		{Role: "system", Content: SystemPrompt(PromptCompact)},
+		{Role: "user", Content: compactionRequest(a.messages[1].Content, a.messages[2:end])},
+	}, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize the conversation: %w", err)
+	}
+	a.totalCost += resp.Usage.EstimatedCost
+	a.totalTokens += resp.Usage.TotalTokens
+	a.promptTokens += resp.Usage.PromptTokens
+	a.completionTokens += resp.Usage.CompletionTokens
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return nil, fmt.Errorf("failed to summarize the conversation: the model returned no summary")
+	}
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+
+	compacted := make([]api.Message, 0, len(a.messages)-end+3)
+	compacted = append(compacted, a.messages[:2]...)
+	compacted = append(compacted, api.Message{Role: "system", Content: compactSummaryHeader + "\n" + summary})
+	compacted = append(compacted, a.messages[end:]...)
+	a.messages = compacted
+
+	// Tracked reads and commands point at messages that are gone
+	a.optimizer.Reset()
+
+	result := &CompactionResult{Messages: end - 2, TokensBefore: before, TokensAfter: a.estimateContextTokens(a.messages)}
+	a.currentContextTokens = result.TokensAfter
+	a.debugLog("🗜️  Compacted %d messages: %s → %s tokens\n", result.Messages, a.formatTokenCount(before), a.formatTokenCount(result.TokensAfter))
+	return result, nil
+}
+
+// autoCompactEnabled reports whether turns are summarized when the context nears its limit
+func (a *Agent) autoCompactEnabled() bool {
+	if a.configManager == nil {
+		return true
+	}
+	return a.configManager.GetConfig().GetBoolPreference(prefAutoCompact, true)
+}
+
+// compactionRequest builds the transcript of the turns to summarize. Long
+// messages, usually tool output, are cut in the middle.
+func compactionRequest(query string, messages []api.Message) string {
+	var b strings.Builder
+	b.WriteString("ORIGINAL REQUEST:\n")
+	b.WriteString(truncateMiddle(query, maxCompactMessageChars))
+	b.WriteString("\n\nTRANSCRIPT TO SUMMARIZE:\n")
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			continue
+		}
+		role := msg.Role
+		switch {
+		case msg.Role == "system" && strings.HasPrefix(content, compactSummaryHeader):
+			role = "earlier summary"
+			content = strings.TrimSpace(strings.TrimPrefix(content, compactSummaryHeader))
+		case msg.Role == "user" && strings.HasPrefix(content, "Tool call result for "):
+			role = "tool result"
+		}
+		fmt.Fprintf(&b, "\n[%s]\n%s\n", role, truncateMiddle(content, maxCompactMessageChars))
+	}
+	return b.String()
+}
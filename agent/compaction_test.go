@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// longHistory builds a conversation with n assistant turns and large tool results
+func longHistory(n int) []api.Message {
+	messages := []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "Fix the retry loop"}}
+	for i := 0; i < n; i++ {
+		messages = append(messages,
+			api.Message{Role: "assistant", Content: "Reading the next file."},
+			api.Message{Role: "user", Content: fmt.Sprintf("Tool call result for read_file: retry%d.go\n", i) + strings.Repeat("for attempt := 0; attempt < max; attempt++ {}\n", 300)})
+	}
+	return messages
+}
+
+func TestCompactConversation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"expect": "[tool result]", "content": "Progress: read retry0.go; the loop never sleeps."},
+		{"expect": "[earlier summary]\nProgress: read retry0.go", "content": "Progress: read retry0.go twice."}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	agent.messages = longHistory(2)
+	if _, err := agent.CompactConversation(); err != ErrNothingToCompact {
+		t.Fatalf("expected too short a conversation not to be compacted, got %v", err)
+	}
+
+	agent.messages = longHistory(8)
+	recent := agent.messages[len(agent.messages)-2*compactKeepTurns:]
+	result, err := agent.CompactConversation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages != 10 || result.TokensAfter >= result.TokensBefore/2 {
+		t.Errorf("expected 10 messages summarized and most tokens freed, got %+v", result)
+	}
+	if len(agent.messages) != 3+len(recent) || agent.messages[1].Content != "Fix the retry loop" {
+		t.Fatalf("expected the prompt, request, summary and recent turns, got %d messages", len(agent.messages))
+	}
+	if summary := agent.messages[2]; summary.Role != "system" || !strings.Contains(summary.Content, "the loop never sleeps") {
+		t.Errorf("expected a system note with the summary, got %+v", summary)
+	}
+	for i, msg := range recent {
+		if agent.messages[3+i].Content != msg.Content {
+			t.Errorf("expected recent message %d to be kept verbatim", i)
+		}
+	}
+
+	// A later compaction folds the earlier summary into the new one
+	agent.messages = append(agent.messages, longHistory(4)[2:]...)
+	if _, err := agent.CompactConversation(); err != nil {
+		t.Fatal(err)
+	}
+	if notes := strings.Count(agent.messages[2].Content+agent.messages[3].Content, compactSummaryHeader); notes != 1 || !strings.Contains(agent.messages[2].Content, "twice") {
+		t.Errorf("expected a single rolled-up summary, got %q", agent.messages[2].Content)
+	}
+}
+
+func TestAutoCompactNearContextLimit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"expect": "TRANSCRIPT TO SUMMARIZE", "content": "Progress: read retry0.go."},
+		{"content": "Done: I edited the file so the retry loop sleeps between attempts."}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	agent.messages = longHistory(8)
+	agent.resumedSession = true
+	// The history takes 85% of the window: over the threshold, within the budget
+	agent.maxContextTokens = agent.estimateContextTokens(agent.messages) * 100 / 85
+	if _, err := agent.ProcessQuery("Keep going"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(agent.messages[2].Content, compactSummaryHeader) {
+		t.Errorf("expected older turns summarized before the request, got %q", agent.messages[2].Content)
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestCompressFileContent(t *testing.T) {
+	settings := DefaultOptimizerSettings()
+	settings.CompressMinChars = 100
+	a := &Agent{
+		optimizer: NewConversationOptimizerWithSettings(settings, false),
+		messages:  []api.Message{{Role: "system"}, {Role: "user", Content: "fix the bug in parseConfig"}},
+	}
+
+	var b strings.Builder
+	b.WriteString("// Copyright 2024 Example Corp\n// Licensed under the Apache License\n\npackage demo\n\n\n\n")
+	for i := 0; i < 20; i++ {
+		b.WriteString("func helper" + strings.Repeat("x", i) + "() {\n\treturn\n}\n\n\n\n\n\n")
+	}
+	b.WriteString("func parseConfig() error {\n\treturn nil\n}\n")
+	content := b.String()
+
+	// Compression is off by default
+	if a.compressFileContent("demo.go", content) != content {
+		t.Fatalf("Expected content to be unchanged when compression is off")
+	}
+
+	a.optimizer.settings.FileCompression = tools.CompressWhitespace
+	compressed := a.compressFileContent("demo.go", content)
+	if strings.Contains(compressed, "Copyright") || strings.Contains(compressed, "\n\n\n") {
+		t.Errorf("Expected license header and blank lines to be removed, got:\n%s", compressed)
+	}
+
+	a.optimizer.settings.FileCompression = tools.CompressOutline
+	compressed = a.compressFileContent("demo.go", content)
+	if !strings.Contains(compressed, "OUTLINE:") || !strings.Contains(compressed, "func parseConfig() error {\n\treturn nil") {
+		t.Errorf("Expected outline with the parseConfig region, got:\n%s", compressed)
+	}
+	if strings.Contains(compressed, "func helperxx() {\n\treturn") {
+		t.Errorf("Expected unrelated function bodies to be omitted")
+	}
+
+	// Small files are never compressed
+	if a.compressFileContent("small.go", "package small\n") != "package small\n" {
+		t.Errorf("Expected small files to be left alone")
+	}
+}
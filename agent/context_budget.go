@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+// Context budget categories
+const (
+	BudgetSystem = "system" // system prompt, user messages and memory (notes, summaries)
+	BudgetCode   = "code"   // file contents from read_file
+	BudgetTools  = "tools"  // other tool results and assistant turns
+)
+
+// Config preference keys for the context budget (percentages of the context window)
+const (
+	prefBudgetSystem   = "context_budget_system_pct"
+	prefBudgetCode     = "context_budget_code_pct"
+	prefBudgetTools    = "context_budget_tools_pct"
+	prefBudgetHeadroom = "context_budget_headroom_pct"
+)
+
+// ContextBudget splits the context window between content types, in percent
+type ContextBudget struct {
+	System   int
+	Code     int
+	Tools    int
+	Headroom int
+}
+
+// DefaultContextBudget returns the default split of the context window
+func DefaultContextBudget() ContextBudget {
+	return ContextBudget{System: 15, Code: 50, Tools: 25, Headroom: 10}
+}
+
+// LoadContextBudget reads the budget split from config, falling back to the
+// default when the configured percentages do not add up to at most 100
+func LoadContextBudget(cfg *config.Config) ContextBudget {
+	budget := DefaultContextBudget()
+	if cfg == nil {
+		return budget
+	}
+
+	configured := ContextBudget{
+		System:   cfg.GetIntPreference(prefBudgetSystem, budget.System),
+		Code:     cfg.GetIntPreference(prefBudgetCode, budget.Code),
+		Tools:    cfg.GetIntPreference(prefBudgetTools, budget.Tools),
+		Headroom: cfg.GetIntPreference(prefBudgetHeadroom, budget.Headroom),
+	}
+	if err := configured.Validate(); err != nil {
+		fmt.Printf("⚠️  Ignoring context budget from config: %v\n", err)
+		return budget
+	}
+	return configured
+}
+
+// Validate checks that the percentages are sensible
+func (b ContextBudget) Validate() error {
+	for _, pct := range []int{b.System, b.Code, b.Tools, b.Headroom} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("budget percentages must be between 0 and 100")
+		}
+	}
+	if total := b.System + b.Code + b.Tools + b.Headroom; total > 100 {
+		return fmt.Errorf("budget percentages add up to %d%%", total)
+	}
+	return nil
+}
+
+// share returns the percentage allotted to a category
+func (b ContextBudget) share(category string) int {
+	switch category {
+	case BudgetCode:
+		return b.Code
+	case BudgetTools:
+		return b.Tools
+	default:
+		return b.System
+	}
+}
+
+// String formats the budget for display
+func (b ContextBudget) String() string {
+	return fmt.Sprintf("system %d%% / code %d%% / tools %d%% / headroom %d%%", b.System, b.Code, b.Tools, b.Headroom)
+}
+
+// budgetCategory returns the budget category of a message
+func budgetCategory(msg api.Message) string {
+	switch {
+	case msg.Role == "assistant":
+		return BudgetTools
+	case msg.Role == "user" && strings.HasPrefix(msg.Content, "Tool call result for read_file:"):
+		return BudgetCode
+	case msg.Role == "user" && strings.HasPrefix(msg.Content, "Tool call result for "):
+		return BudgetTools
+	default:
+		return BudgetSystem
+	}
+}
+
+// EnforceContextBudget keeps each content type within its share of the context
+// window. A single message may never exceed its category's whole share, and when
+// the conversation no longer fits (minus headroom) the categories that are over
+// budget are trimmed first, oldest messages first.
+func (co *ConversationOptimizer) EnforceContextBudget(messages []api.Message, maxContextTokens int) []api.Message {
+	if !co.settings.Enabled || maxContextTokens <= 0 {
+		return messages
+	}
+
+	budget := co.settings.Budget
+	limit := func(category string) int {
+		return maxContextTokens * budget.share(category) / 100
+	}
+
+	optimized := make([]api.Message, len(messages))
+	copy(optimized, messages)
+
+	// A single giant output must not crowd out everything else
+	for i, msg := range optimized {
+		category := budgetCategory(msg)
+		if category == BudgetSystem {
+			continue
+		}
+		size := co.tokens(msg.Content)
+		if size <= limit(category) {
+			continue
+		}
+		// Cut to the share in chars, at the message's own chars per token
+		truncated := truncateMiddle(msg.Content, len(msg.Content)*limit(category)/size)
+		co.recordDrop(i, "over_budget", co.evictionTarget(msg, i), msg.Content, truncated)
+		optimized[i].Content = truncated
+	}
+
+	usage := make(map[string]int)
+	total := 0
+	for _, msg := range optimized {
+		size := co.messageTokens(msg)
+		usage[budgetCategory(msg)] += size
+		total += size
+	}
+
+	usable := maxContextTokens * (100 - budget.Headroom) / 100
+	if total <= usable {
+		return optimized
+	}
+
+	// Trim the category that is furthest over its share first
+	categories := []string{BudgetTools, BudgetCode}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return usage[categories[i]]-limit(categories[i]) > usage[categories[j]]-limit(categories[j])
+	})
+
+	protectedFrom := len(optimized) - 1 // the latest result is what the model is about to act on
+	for _, category := range categories {
+		for i := 2; i < protectedFrom && usage[category] > limit(category) && total > usable; i++ {
+			msg := optimized[i]
+			if budgetCategory(msg) != category || isCompacted(msg) || isTodoResult(msg) {
+				continue
+			}
+
+			summary := co.summarizeTurnMessage(msg)
+			saved := co.messageTokens(msg) - co.tokens(summary)
+			if saved <= 0 {
+				continue
+			}
+
+			co.recordDrop(i, "over_budget", co.evictionTarget(msg, i), msg.Content+msg.ReasoningContent, summary)
+			optimized[i] = api.Message{Role: msg.Role, Content: summary, ToolCallID: msg.ToolCallID}
+			usage[category] -= saved
+			total -= saved
+		}
+	}
+
+	if co.debug {
+		fmt.Printf("📐 Context budget: system %d / code %d / tools %d tokens (usable %d)\n",
+			usage[BudgetSystem], usage[BudgetCode], usage[BudgetTools], usable)
+	}
+	return optimized
+}
+
+// truncateMiddle shortens content to about limit chars, keeping its head and tail
+func truncateMiddle(content string, limit int) string {
+	if len(content) <= limit {
+		return content
+	}
+	head := limit * 2 / 3
+	tail := limit - head
+	omitted := len(content) - head - tail
+	return content[:head] +
+		fmt.Sprintf("\n\n[... %d chars omitted to fit the context budget ...]\n\n", omitted) +
+		content[len(content)-tail:]
+}
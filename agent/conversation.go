@@ -1,8 +1,10 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/alantheprice/coder/api"
 	"github.com/alantheprice/coder/tools"
@@ -10,6 +12,15 @@ import (
 
 // ProcessQuery handles the main conversation loop with the LLM
 func (a *Agent) ProcessQuery(userQuery string) (string, error) {
+	// Refuse to start when the spend cap or the provider balance would not cover the task
+	estimate := a.lastTaskCost
+	if preview, err := a.EstimateTaskCost(userQuery); err == nil && preview.Low > estimate {
+		estimate = preview.Low
+	}
+	if err := a.CheckSpend(estimate); err != nil {
+		return "", err
+	}
+
 	// Process any images in the user query first
 	processedQuery, err := a.processImagesInQuery(userQuery)
 	if err != nil {
@@ -18,20 +29,75 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 		processedQuery = userQuery
 	}
 	
-	// Initialize with system prompt and processed user query
-	a.messages = []api.Message{
-		{Role: "system", Content: a.systemPrompt},
-		{Role: "user", Content: processedQuery},
+	// Remember the workspace state so /whatchanged can report every change
+	a.captureWorkspaceSnapshotOnce()
+	// So /checkpoint restore can roll the whole task back
+	a.checkpointBeforeTask(userQuery)
+
+	if a.pairedPaused {
+		// Paired mode: the reply continues the paused conversation
+		a.pairedPaused = false
+		a.messages = append(a.messages, api.Message{
+			Role:    "user",
+			Content: fmt.Sprintf("USER REPLY (go-ahead or new directions): %s\n\nContinue the task accordingly.", processedQuery),
+		})
+	} else if a.resumedSession {
+		// A resumed session continues its restored conversation
+		a.resumedSession = false
+		a.messages = append(a.messages, api.Message{Role: "user", Content: processedQuery})
+		a.optimizer.Reset()
+		a.resetTaskChanges()
+	} else {
+		// Initialize with system prompt and processed user query
+		a.debugLog("🧾 System prompt: %s\n", a.taskPromptVariant())
+		a.messages = []api.Message{
+			{Role: "system", Content: a.taskSystemPrompt() + a.toolchainForPrompt() + a.goWorkspaceForPrompt() + a.disabledToolsForPrompt() + a.knowledgeForQuery(processedQuery)},
+			{Role: "user", Content: processedQuery},
+		}
+		a.optimizer.Reset()
+		a.resetTaskChanges()
 	}
 
 	a.currentIteration = 0
+	a.pendingToolCalls = nil
+	return a.runConversation()
+}
+
+// runConversation runs the request and tool-call loop from the current
+// iteration until the model finishes, the task pauses, or it is aborted
+func (a *Agent) runConversation() (result string, err error) {
+	// Ctrl+C cancels the task's requests and commands, not the program
+	_, end := a.BeginOperation()
+	defer end()
+	// A panic or a failed provider saves the task for "coder resume"
+	defer a.recoverTask(&result, &err)
+
+	// Tool calls carried over from an aborted task run before the next request
+	a.runPendingToolCalls()
+
+	a.timings = newTaskTimings()
+	defer func() { a.timings.Finished = time.Now() }()
 
+	costAtStart := a.totalCost
+	defer func() { a.lastTaskCost = a.totalCost - costAtStart }()
+
+	toolCallsThisStretch := 0
+	compactionFailed := false
 	for a.currentIteration < a.maxIterations {
+		if a.cancelled() {
+			return "", ErrCancelled
+		}
 		a.currentIteration++
 
 		// Check for interrupt signal at the start of each iteration
 		if a.CheckForInterrupt() {
-			interruptMessage := a.HandleInterrupt()
+			interruptMessage, err := a.HandleInterrupt()
+			if err != nil {
+				return "", err
+			}
+			// Clear interrupt state, then run the tool calls the interrupt held back
+			a.ClearInterrupt()
+			a.runPendingToolCalls()
 			if interruptMessage != "" {
 				// Inject user message into conversation
 				a.messages = append(a.messages, api.Message{
@@ -40,14 +106,21 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 				})
 				a.debugLog("🛑 Interrupt processed, continuing with: %s\n", interruptMessage)
 			}
-			// Clear interrupt state and continue
-			a.ClearInterrupt()
+			if len(a.pendingToolCalls) > 0 {
+				continue // interrupted again
+			}
 		}
 
 		a.debugLog("Iteration %d/%d\n", a.currentIteration, a.maxIterations)
 
+		// Tell the model about files that were edited outside the agent since it read them
+		a.handleExternalFileChanges()
+
 		// Optimize conversation before sending to API
 		optimizedMessages := a.optimizer.OptimizeConversation(a.messages)
+
+		// Keep each content type within its share of the context window
+		optimizedMessages = a.optimizer.EnforceContextBudget(optimizedMessages, a.maxContextTokens)
 		
 		if a.debug && len(optimizedMessages) < len(a.messages) {
 			saved := len(a.messages) - len(optimizedMessages)
@@ -59,8 +132,8 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 		contextTokens := a.estimateContextTokens(optimizedMessages)
 		a.currentContextTokens = contextTokens
 		
-		// Check if we're approaching the context limit (80%)
-		contextThreshold := int(float64(a.maxContextTokens) * 0.8)
+		// Check if we're approaching the context limit (80% unless configured otherwise)
+		contextThreshold := int(float64(a.maxContextTokens) * a.optimizer.AggressiveThreshold())
 		if contextTokens > contextThreshold {
 			if !a.contextWarningIssued {
 				a.debugLog("⚠️  Context approaching limit: %s/%s (%.1f%%)\n", 
@@ -69,26 +142,59 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 					float64(contextTokens)/float64(a.maxContextTokens)*100)
 				a.contextWarningIssued = true
 			}
-			
-			// Perform aggressive optimization when near limit
-			optimizedMessages = a.optimizer.AggressiveOptimization(optimizedMessages)
-			contextTokens = a.estimateContextTokens(optimizedMessages)
-			a.currentContextTokens = contextTokens
-			
-			if a.debug {
-				a.debugLog("🔄 Aggressive optimization applied: %s context tokens\n", 
-					a.formatTokenCount(contextTokens))
+
+			// Summarize older turns first, so what they established survives
+			if !compactionFailed && a.autoCompactEnabled() {
+				result, err := a.CompactConversation()
+				switch {
+				case err == nil:
+					fmt.Printf("🗜️  Context near its limit: summarized %d earlier messages (%s → %s tokens)\n",
+						result.Messages, a.formatTokenCount(result.TokensBefore), a.formatTokenCount(result.TokensAfter))
+					optimizedMessages = a.optimizer.EnforceContextBudget(a.optimizer.OptimizeConversation(a.messages), a.maxContextTokens)
+					contextTokens = a.estimateContextTokens(optimizedMessages)
+					a.currentContextTokens = contextTokens
+				case a.cancelled():
+					return "", ErrCancelled
+				case !errors.Is(err, ErrNothingToCompact):
+					// Don't pay for a failing summary on every iteration
+					compactionFailed = true
+					a.debugLog("⚠️  Compaction failed, evicting instead: %v\n", err)
+				}
+			}
+
+			if contextTokens > contextThreshold {
+				// Evict old content until the conversation is comfortably below the threshold
+				optimizedMessages = a.optimizer.AggressiveOptimization(optimizedMessages, contextThreshold*3/4)
+				contextTokens = a.estimateContextTokens(optimizedMessages)
+				a.currentContextTokens = contextTokens
+
+				if a.debug {
+					a.debugLog("🔄 Aggressive optimization applied: %s context tokens\n",
+						a.formatTokenCount(contextTokens))
+				}
 			}
 		}
 
 		// Send request to API using the unified interface
-		resp, err := a.client.SendChatRequest(optimizedMessages, api.GetToolDefinitions(), "high")
-		if err != nil {
-			return "", fmt.Errorf("API request failed: %w", err)
+		requestStart := time.Now()
+		resp, err := a.sendChatRequest(optimizedMessages, a.toolDefinitions())
+		a.timings.recordProvider(a.currentIteration, time.Since(requestStart))
+		if a.metrics != nil {
+			if err != nil {
+				a.metrics.RecordRequest(a.GetProvider(), a.GetModel(), 0, 0, 0, err)
+			} else {
+				a.metrics.RecordRequest(a.GetProvider(), a.GetModel(), resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.EstimatedCost, nil)
+			}
 		}
-
-		if len(resp.Choices) == 0 {
-			return "", fmt.Errorf("no response choices returned")
+		if err != nil && a.cancelled() {
+			return "", ErrCancelled
+		}
+		if errors.Is(err, ErrNoChoices) {
+			// Report what got done rather than losing the whole task
+			return a.partialResultReport(), fmt.Errorf("%w after retries and failover (iteration %d)", err, a.currentIteration)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrProviderFailed, err)
 		}
 
 		// Track token usage and cost
@@ -131,22 +237,13 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 
 		// Check if there are tool calls to execute
 		if len(choice.Message.ToolCalls) > 0 {
-			// Execute each tool call
-			toolResults := make([]string, 0)
-			for _, toolCall := range choice.Message.ToolCalls {
-				result, err := a.executeTool(toolCall)
-				if err != nil {
-					result = fmt.Sprintf("Error executing tool %s: %s", toolCall.Function.Name, err.Error())
-				}
-				toolResults = append(toolResults, fmt.Sprintf("Tool call result for %s: %s", toolCall.Function.Name, result))
-			}
-
-			// Add tool results to conversation
-			a.messages = append(a.messages, api.Message{
-				Role:    "user",
-				Content: strings.Join(toolResults, "\n\n"),
-			})
+			// Execute each tool call and add its result to the conversation
+			a.executeToolCalls(choice.Message.ToolCalls)
 
+			toolCallsThisStretch += len(choice.Message.ToolCalls)
+			if a.turnLimit > 0 && toolCallsThisStretch >= a.turnLimit {
+				return a.pauseForGoAhead(toolCallsThisStretch)
+			}
 			continue
 		} else {
 			// Check if content or reasoning_content contains tool calls that weren't properly parsed
@@ -159,21 +256,12 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 			if len(toolCalls) > 0 {
 				a.debugLog("Found malformed tool calls in content, executing them\n")
 
-				toolResults := make([]string, 0)
-				for _, toolCall := range toolCalls {
-					result, err := a.executeTool(toolCall)
-					if err != nil {
-						result = fmt.Sprintf("Error executing tool %s: %s", toolCall.Function.Name, err.Error())
-					}
-					toolResults = append(toolResults, fmt.Sprintf("Tool call result for %s: %s", toolCall.Function.Name, result))
-				}
-
-				// Add tool results to conversation
-				a.messages = append(a.messages, api.Message{
-					Role:    "user",
-					Content: strings.Join(toolResults, "\n\n"),
-				})
+				a.executeToolCalls(toolCalls)
 
+				toolCallsThisStretch += len(toolCalls)
+				if a.turnLimit > 0 && toolCallsThisStretch >= a.turnLimit {
+					return a.pauseForGoAhead(toolCallsThisStretch)
+				}
 				continue
 			}
 
@@ -195,10 +283,11 @@ func (a *Agent) ProcessQuery(userQuery string) (string, error) {
 	return "", fmt.Errorf("maximum iterations (%d) reached without completion", a.maxIterations)
 }
 
-// ProcessQueryWithContinuity processes a query with continuity from previous actions
+// ProcessQueryWithContinuity processes a query with continuity from previous
+// actions. The summary is part of the system prompt unless project context is
+// turned off; then it is added to the query.
 func (a *Agent) ProcessQueryWithContinuity(userQuery string) (string, error) {
-	// Load previous state if available
-	if a.previousSummary != "" {
+	if a.previousSummary != "" && !a.projectContextEnabled() {
 		continuityPrompt := fmt.Sprintf(`
 CONTINUITY FROM PREVIOUS SESSION:
 %s
@@ -212,7 +301,7 @@ Please continue working on this task chain, building upon the previous actions.`
 		return a.ProcessQuery(continuityPrompt)
 	}
 	
-	// No previous state, process normally
+	// Any summary is already in the system prompt
 	return a.ProcessQuery(userQuery)
 }
 
@@ -285,7 +374,7 @@ func (a *Agent) ClearConversationHistory() {
 }
 
 // SetConversationOptimization enables or disables conversation optimization
-// Note: Optimization is always enabled by default for optimal performance
+// Note: Optimization is enabled by default; see optimizer_enabled in config
 func (a *Agent) SetConversationOptimization(enabled bool) {
 	a.optimizer.SetEnabled(enabled)
 	if a.debug {
@@ -3,11 +3,13 @@ package agent
 import (
 	"crypto/md5"
 	"fmt"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
 )
 
 // FileReadRecord tracks file reads to detect redundancy
@@ -16,9 +18,21 @@ type FileReadRecord struct {
 	Content     string
 	ContentHash string
 	Timestamp   time.Time
+	ModTime     time.Time
 	MessageIndex int
 }
 
+// ToolResultRecord is the structured metadata of a tool result message. It is
+// registered by the agent so tracking does not depend on parsing message text,
+// and matched to its message by ToolCallID.
+type ToolResultRecord struct {
+	ToolCallID string
+	ToolName   string
+	FilePath   string    // read_file target
+	Command    string    // shell_command command line
+	ModTime    time.Time // file modification time at read
+}
+
 // ShellCommandRecord tracks shell commands to detect redundancy
 type ShellCommandRecord struct {
 	Command     string
@@ -33,23 +47,65 @@ type ShellCommandRecord struct {
 type ConversationOptimizer struct {
 	fileReads     map[string]*FileReadRecord    // filepath -> latest read record
 	shellCommands map[string]*ShellCommandRecord // command -> latest execution record
-	enabled       bool
+	listings      map[string]*ShellCommandRecord // list_directory/glob/search_code call -> latest result
+	staleBefore   map[string]int                 // filepath -> message index where the file was last modified
+	toolResults   map[string]*ToolResultRecord   // tool call id -> tool result metadata
+	settings      OptimizerSettings
+	lastDrops     []OptimizationDrop // what the last OptimizeConversation pass removed
+	tokenCounter  func(string) int   // the model's tokenizer; nil estimates 4 chars per token
 	debug         bool
 }
 
-// NewConversationOptimizer creates a new conversation optimizer
+// OptimizationDrop describes a message that was summarized or truncated
+type OptimizationDrop struct {
+	MessageIndex int
+	Reason       string // e.g. "stale", "redundant_read", "truncated", "evicted_shell", "over_budget"
+	Target       string // file path or command, if known
+	CharsSaved   int
+}
+
+// NewConversationOptimizer creates a new conversation optimizer with default settings
 func NewConversationOptimizer(enabled bool, debug bool) *ConversationOptimizer {
+	settings := DefaultOptimizerSettings()
+	settings.Enabled = enabled
+	return NewConversationOptimizerWithSettings(settings, debug)
+}
+
+// NewConversationOptimizerWithSettings creates a conversation optimizer with the given settings
+func NewConversationOptimizerWithSettings(settings OptimizerSettings, debug bool) *ConversationOptimizer {
 	return &ConversationOptimizer{
 		fileReads:     make(map[string]*FileReadRecord),
 		shellCommands: make(map[string]*ShellCommandRecord),
-		enabled:       enabled,
+		listings:      make(map[string]*ShellCommandRecord),
+		staleBefore:   make(map[string]int),
+		toolResults:   make(map[string]*ToolResultRecord),
+		settings:      settings,
 		debug:         debug,
 	}
 }
 
+// SetTokenCounter makes context sizes count tokens with the model's tokenizer
+func (co *ConversationOptimizer) SetTokenCounter(count func(string) int) {
+	co.tokenCounter = count
+}
+
+// tokens returns the size of a text in tokens
+func (co *ConversationOptimizer) tokens(text string) int {
+	if co.tokenCounter == nil {
+		return len(text) / 4
+	}
+	return co.tokenCounter(text)
+}
+
+// messageTokens returns the size of a message's content and reasoning in tokens
+func (co *ConversationOptimizer) messageTokens(msg api.Message) int {
+	return co.tokens(msg.Content) + co.tokens(msg.ReasoningContent)
+}
+
 // OptimizeConversation optimizes the conversation history by removing redundant content
 func (co *ConversationOptimizer) OptimizeConversation(messages []api.Message) []api.Message {
-	if !co.enabled {
+	co.lastDrops = nil
+	if !co.settings.Enabled {
 		return messages
 	}
 
@@ -57,32 +113,63 @@ func (co *ConversationOptimizer) OptimizeConversation(messages []api.Message) []
 	for i, msg := range messages {
 		co.trackFileRead(msg, i)
 		co.trackShellCommand(msg, i)
+		co.trackListing(msg, i)
 	}
 
 	// Second pass: optimize based on tracked data
 	optimized := make([]api.Message, 0, len(messages))
 	
 	for i, msg := range messages {
-		if co.isRedundantFileRead(msg, i) {
+		if co.isStaleFileRead(msg, i) {
+			filePath := co.filePathOf(msg)
+			optimized = append(optimized, api.Message{
+				Role:       msg.Role,
+				Content:    fmt.Sprintf("Tool call result for read_file: %s\n[STALE] File was modified on disk after this read - content omitted, read it again", filePath),
+				ToolCallID: msg.ToolCallID,
+			})
+			co.recordDrop(i, "stale", filePath, msg.Content, optimized[len(optimized)-1].Content)
+			if co.debug {
+				fmt.Printf("🔄 Dropped stale file read: %s\n", filePath)
+			}
+		} else if co.isRedundantFileRead(msg, i) {
 			// Replace with summary
 			summary := co.createFileReadSummary(msg)
 			optimized = append(optimized, api.Message{
-				Role:    msg.Role,
-				Content: summary,
+				Role:       msg.Role,
+				Content:    summary,
+				ToolCallID: msg.ToolCallID,
 			})
+			co.recordDrop(i, "redundant_read", co.filePathOf(msg), msg.Content, summary)
 			if co.debug {
-				fmt.Printf("🔄 Optimized redundant file read: %s\n", co.extractFilePath(msg.Content))
+				fmt.Printf("🔄 Optimized redundant file read: %s\n", co.filePathOf(msg))
 			}
 		} else if co.isRedundantShellCommand(msg, i) {
 			// Replace with summary
-			summary := co.createShellCommandSummary(msg)
+			summary := co.createShellCommandSummary(msg, co.commandOf(msg))
 			optimized = append(optimized, api.Message{
-				Role:    msg.Role,
-				Content: summary,
+				Role:       msg.Role,
+				Content:    summary,
+				ToolCallID: msg.ToolCallID,
 			})
+			co.recordDrop(i, "redundant_command", co.commandOf(msg), msg.Content, summary)
+			if co.debug {
+				fmt.Printf("🔄 Optimized redundant shell command: %s\n", co.commandOf(msg))
+			}
+		} else if key, ok := co.redundantListing(msg, i); ok {
+			summary := fmt.Sprintf("Tool call result for %s\n[OPTIMIZED] Same listing as a later call (%d lines) - output unchanged since",
+				key, strings.Count(co.extractShellOutput(msg.Content), "\n")+1)
+			optimized = append(optimized, api.Message{Role: msg.Role, Content: summary, ToolCallID: msg.ToolCallID})
+			co.recordDrop(i, "redundant_listing", key, msg.Content, summary)
 			if co.debug {
-				fmt.Printf("🔄 Optimized redundant shell command: %s\n", co.extractShellCommand(msg.Content))
+				fmt.Printf("🔄 Optimized redundant listing: %s\n", key)
 			}
+		} else if truncated, ok := co.truncateToolResult(msg); ok {
+			optimized = append(optimized, api.Message{
+				Role:       msg.Role,
+				Content:    truncated,
+				ToolCallID: msg.ToolCallID,
+			})
+			co.recordDrop(i, "truncated", "", msg.Content, truncated)
 		} else {
 			optimized = append(optimized, msg)
 		}
@@ -91,6 +178,30 @@ func (co *ConversationOptimizer) OptimizeConversation(messages []api.Message) []
 	return optimized
 }
 
+// truncateToolResult shortens tool results longer than the configured maximum
+func (co *ConversationOptimizer) truncateToolResult(msg api.Message) (string, bool) {
+	limit := co.settings.MaxToolResultChars
+	if limit <= 0 || msg.Role != "user" || len(msg.Content) <= limit || !strings.HasPrefix(msg.Content, "Tool call result for ") {
+		return "", false
+	}
+	return msg.Content[:limit] + fmt.Sprintf("\n... [TRUNCATED by optimizer: %d of %d chars kept]", limit, len(msg.Content)), true
+}
+
+// recordDrop remembers what a replacement removed so it can be reported
+func (co *ConversationOptimizer) recordDrop(index int, reason, target, original, replacement string) {
+	co.lastDrops = append(co.lastDrops, OptimizationDrop{
+		MessageIndex: index,
+		Reason:       reason,
+		Target:       target,
+		CharsSaved:   len(original) - len(replacement),
+	})
+}
+
+// LastDrops returns what the last optimization pass summarized or truncated
+func (co *ConversationOptimizer) LastDrops() []OptimizationDrop {
+	return co.lastDrops
+}
+
 // isRedundantFileRead checks if this message is a redundant file read
 func (co *ConversationOptimizer) isRedundantFileRead(msg api.Message, index int) bool {
 	if msg.Role != "user" {
@@ -102,7 +213,7 @@ func (co *ConversationOptimizer) isRedundantFileRead(msg api.Message, index int)
 		return false
 	}
 
-	filePath := co.extractFilePath(msg.Content)
+	filePath := co.filePathOf(msg)
 	if filePath == "" {
 		return false
 	}
@@ -118,7 +229,9 @@ func (co *ConversationOptimizer) isRedundantFileRead(msg api.Message, index int)
 		// 2. This is NOT the most recent read (index < record.MessageIndex) AND
 		// 3. The gap to the most recent read is at least 5 messages
 		messageGap := record.MessageIndex - index
-		if record.ContentHash == currentHash && index < record.MessageIndex && messageGap >= 5 {
+		// 4. The file was not modified on disk between the two reads
+		if record.ContentHash == currentHash && index < record.MessageIndex && messageGap >= co.settings.redundantReadGap() &&
+			sameModTime(co.modTimeOf(msg), record.ModTime) {
 			return true
 		}
 	}
@@ -126,13 +239,170 @@ func (co *ConversationOptimizer) isRedundantFileRead(msg api.Message, index int)
 	return false
 }
 
+// isStaleFileRead checks if this file read happened before an external modification
+func (co *ConversationOptimizer) isStaleFileRead(msg api.Message, index int) bool {
+	if msg.Role != "user" || !strings.Contains(msg.Content, "Tool call result for read_file:") {
+		return false
+	}
+
+	staleIndex, exists := co.staleBefore[co.filePathOf(msg)]
+	return exists && index < staleIndex
+}
+
+// RecordToolResult registers the metadata of the tool result message answering
+// record.ToolCallID. Results without an id can't be matched and are ignored.
+func (co *ConversationOptimizer) RecordToolResult(record ToolResultRecord) {
+	if record.ToolCallID == "" {
+		return
+	}
+	if record.FilePath != "" {
+		record.FilePath = filepath.Clean(record.FilePath)
+	}
+	co.toolResults[record.ToolCallID] = &record
+}
+
+// recordFor returns the recorded metadata of a tool result message. Messages are
+// matched by tool call id rather than position, so compaction and trimmed copies
+// of the conversation can't attach a record to the wrong message.
+func (co *ConversationOptimizer) recordFor(msg api.Message) (*ToolResultRecord, bool) {
+	if msg.ToolCallID == "" {
+		return nil, false
+	}
+	record, exists := co.toolResults[msg.ToolCallID]
+	return record, exists
+}
+
+// InvalidateFile drops the cached read record for a file that was modified, by
+// the agent or outside it. Reads before messageIndex are treated as stale from now on.
+func (co *ConversationOptimizer) InvalidateFile(filePath string, messageIndex int) {
+	filePath = filepath.Clean(filePath)
+	delete(co.fileReads, filePath)
+	if messageIndex > co.staleBefore[filePath] {
+		co.staleBefore[filePath] = messageIndex
+	}
+}
+
+// InvalidateForShellCommand invalidates the file reads a mutating shell command
+// may have touched and returns their paths. Commands with explicit targets only
+// invalidate the files they name; broad ones (git checkout, make, globs...) invalidate all.
+func (co *ConversationOptimizer) InvalidateForShellCommand(command string, messageIndex int) []string {
+	mutating, broad := classifyShellCommand(command)
+	if !mutating {
+		return nil
+	}
+
+	var invalidated []string
+	for _, path := range co.readPaths() {
+		if broad || strings.Contains(command, path) || strings.Contains(command, filepath.Base(path)) {
+			co.InvalidateFile(path, messageIndex)
+			invalidated = append(invalidated, path)
+		}
+	}
+	return invalidated
+}
+
+// readPaths returns every file path that has been read in the conversation
+func (co *ConversationOptimizer) readPaths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	add := func(path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for path := range co.fileReads {
+		add(path)
+	}
+	for _, record := range co.toolResults {
+		if record.ToolName == "read_file" {
+			add(record.FilePath)
+		}
+	}
+	return paths
+}
+
+// filePathOf returns the file path of a read_file result, preferring recorded metadata
+func (co *ConversationOptimizer) filePathOf(msg api.Message) string {
+	if record, exists := co.recordFor(msg); exists && record.ToolName == "read_file" {
+		return record.FilePath
+	}
+	if filePath := co.extractFilePath(msg.Content); filePath != "" {
+		return filepath.Clean(filePath)
+	}
+	return ""
+}
+
+// commandOf returns the command of a shell_command result, preferring recorded metadata
+func (co *ConversationOptimizer) commandOf(msg api.Message) string {
+	if record, exists := co.recordFor(msg); exists && record.ToolName == "shell_command" {
+		return record.Command
+	}
+	return co.extractShellCommand(msg.Content)
+}
+
+// modTimeOf returns the recorded modification time of the file a read_file result read, if known
+func (co *ConversationOptimizer) modTimeOf(msg api.Message) time.Time {
+	if record, exists := co.recordFor(msg); exists {
+		return record.ModTime
+	}
+	return time.Time{}
+}
+
+// sameModTime compares modification times, treating unknown times as equal
+func sameModTime(a, b time.Time) bool {
+	return a.IsZero() || b.IsZero() || a.Equal(b)
+}
+
+// mutatingShellPatterns are commands that modify the files they are given
+var mutatingShellPatterns = []string{
+	"rm ", "mv ", "cp ", "tee ", "touch ", "truncate ", "sed -i", "perl -i",
+	"gofmt -w", "goimports -w", "prettier --write", "dos2unix ",
+}
+
+// broadShellPatterns are commands that can modify files they do not name
+var broadShellPatterns = []string{
+	"git checkout ", "git restore ", "git reset ", "git stash ", "git apply ", "git am ",
+	"git pull ", "git merge ", "git rebase ", "git cherry-pick ", "git revert ",
+	"go generate ", "go fmt ", "go mod tidy ", "patch ", "make ", "npm ", "yarn ", "pnpm ",
+	"unzip ", "tar -x", "tar x", "-delete ", "-exec ", "xargs ",
+}
+
+// classifyShellCommand reports whether a command may modify files and whether
+// the affected files can be identified from its arguments
+func classifyShellCommand(command string) (mutating bool, broad bool) {
+	cmd := strings.ToLower(command)
+	for _, harmless := range []string{"2>&1", ">&2", "2>/dev/null", ">/dev/null", "> /dev/null"} {
+		cmd = strings.ReplaceAll(cmd, harmless, " ")
+	}
+	for _, sep := range []string{"&&", "||", ";", "|", "(", ")", "\n"} {
+		cmd = strings.ReplaceAll(cmd, sep, " ")
+	}
+	cmd = " " + strings.Join(strings.Fields(cmd), " ") + " "
+
+	for _, pattern := range broadShellPatterns {
+		if strings.Contains(cmd, " "+pattern) {
+			return true, true
+		}
+	}
+
+	mutating = strings.Contains(cmd, ">")
+	for _, pattern := range mutatingShellPatterns {
+		if strings.Contains(cmd, " "+pattern) {
+			mutating = true
+			break
+		}
+	}
+	return mutating, mutating && strings.ContainsAny(cmd, "*?")
+}
+
 // trackFileRead records a file read for future optimization
 func (co *ConversationOptimizer) trackFileRead(msg api.Message, index int) {
 	if msg.Role != "user" || !strings.Contains(msg.Content, "Tool call result for read_file:") {
 		return
 	}
 
-	filePath := co.extractFilePath(msg.Content)
+	filePath := co.filePathOf(msg)
 	if filePath == "" {
 		return
 	}
@@ -147,6 +417,7 @@ func (co *ConversationOptimizer) trackFileRead(msg api.Message, index int) {
 		Content:      content,
 		ContentHash:  hash,
 		Timestamp:    time.Now(),
+		ModTime:      co.modTimeOf(msg),
 		MessageIndex: index,
 	}
 }
@@ -191,8 +462,8 @@ func (co *ConversationOptimizer) createFileReadSummary(msg api.Message) string {
 	
 	// Determine file type
 	fileType := "file"
-	if strings.HasSuffix(filePath, ".go") {
-		fileType = "Go file"
+	if pack := tools.LanguagePackFor(filePath); pack != nil {
+		fileType = pack.Name() + " file"
 	} else if strings.HasSuffix(filePath, ".md") {
 		fileType = "Markdown file"
 	} else if strings.HasSuffix(filePath, ".json") {
@@ -206,7 +477,7 @@ func (co *ConversationOptimizer) createFileReadSummary(msg api.Message) string {
 // GetOptimizationStats returns statistics about optimization
 func (co *ConversationOptimizer) GetOptimizationStats() map[string]interface{} {
 	return map[string]interface{}{
-		"enabled":           co.enabled,
+		"enabled":           co.settings.Enabled,
 		"tracked_files":     len(co.fileReads),
 		"tracked_commands":  len(co.shellCommands),
 		"file_paths":       co.getTrackedFilePaths(),
@@ -243,7 +514,7 @@ func (co *ConversationOptimizer) isRedundantShellCommand(msg api.Message, index
 		return false
 	}
 
-	command := co.extractShellCommand(msg.Content)
+	command := co.commandOf(msg)
 	if command == "" {
 		return false
 	}
@@ -255,7 +526,7 @@ func (co *ConversationOptimizer) isRedundantShellCommand(msg api.Message, index
 		currentHash := co.hashContent(currentOutput)
 		
 		// Check if this is a transient command that should be optimized after some time
-		if record.IsTransient && record.MessageIndex < index-2 {
+		if record.IsTransient && record.MessageIndex < index-co.settings.TransientTTL {
 			return true
 		}
 		
@@ -268,13 +539,51 @@ func (co *ConversationOptimizer) isRedundantShellCommand(msg api.Message, index
 	return false
 }
 
+// listingKey identifies a list_directory, glob or search_code result by its tool and
+// arguments ("glob: . **/*.go"), or returns "" for other messages
+func (co *ConversationOptimizer) listingKey(msg api.Message) string {
+	record, exists := co.recordFor(msg)
+	if !exists || msg.Role != "user" || (record.ToolName != "list_directory" && record.ToolName != "glob" && record.ToolName != "search_code") {
+		return ""
+	}
+	return record.ToolName + ": " + record.Command
+}
+
+// trackListing records the latest result of each listing call
+func (co *ConversationOptimizer) trackListing(msg api.Message, index int) {
+	key := co.listingKey(msg)
+	if key == "" {
+		return
+	}
+	co.listings[key] = &ShellCommandRecord{
+		Command:      key,
+		OutputHash:   co.hashContent(co.extractShellOutput(msg.Content)),
+		Timestamp:    time.Now(),
+		MessageIndex: index,
+	}
+}
+
+// redundantListing reports whether a listing was repeated later with the
+// same output, returning its key
+func (co *ConversationOptimizer) redundantListing(msg api.Message, index int) (string, bool) {
+	key := co.listingKey(msg)
+	if key == "" {
+		return "", false
+	}
+	record, exists := co.listings[key]
+	if !exists || record.MessageIndex <= index {
+		return "", false
+	}
+	return key, record.OutputHash == co.hashContent(co.extractShellOutput(msg.Content))
+}
+
 // trackShellCommand records a shell command execution for future optimization
 func (co *ConversationOptimizer) trackShellCommand(msg api.Message, index int) {
 	if msg.Role != "user" || !strings.Contains(msg.Content, "Tool call result for shell_command:") {
 		return
 	}
 
-	command := co.extractShellCommand(msg.Content)
+	command := co.commandOf(msg)
 	if command == "" {
 		return
 	}
@@ -333,8 +642,7 @@ func (co *ConversationOptimizer) isTransientCommand(command string) bool {
 }
 
 // createShellCommandSummary creates a summary for a redundant shell command
-func (co *ConversationOptimizer) createShellCommandSummary(msg api.Message) string {
-	command := co.extractShellCommand(msg.Content)
+func (co *ConversationOptimizer) createShellCommandSummary(msg api.Message, command string) string {
 	output := co.extractShellOutput(msg.Content)
 	
 	// Count lines and characters in output
@@ -356,78 +664,209 @@ func (co *ConversationOptimizer) createShellCommandSummary(msg api.Message) stri
 func (co *ConversationOptimizer) Reset() {
 	co.fileReads = make(map[string]*FileReadRecord)
 	co.shellCommands = make(map[string]*ShellCommandRecord)
+	co.listings = make(map[string]*ShellCommandRecord)
+	co.staleBefore = make(map[string]int)
+	co.toolResults = make(map[string]*ToolResultRecord)
 }
 
 // SetEnabled enables or disables optimization
 func (co *ConversationOptimizer) SetEnabled(enabled bool) {
-	co.enabled = enabled
+	co.settings.Enabled = enabled
 }
 
 // IsEnabled returns whether optimization is enabled
 func (co *ConversationOptimizer) IsEnabled() bool {
-	return co.enabled
+	return co.settings.Enabled
+}
+
+// Settings returns the current optimizer settings
+func (co *ConversationOptimizer) Settings() OptimizerSettings {
+	return co.settings
+}
+
+// AggressiveThreshold returns the fraction of the context window that triggers AggressiveOptimization
+func (co *ConversationOptimizer) AggressiveThreshold() float64 {
+	return co.settings.aggressiveThreshold()
 }
 
-// AggressiveOptimization performs more aggressive optimization when approaching context limits
-func (co *ConversationOptimizer) AggressiveOptimization(messages []api.Message) []api.Message {
-	if !co.enabled {
+// aggressiveKeepTurns is how many of the latest assistant turns are never evicted
+const aggressiveKeepTurns = 3
+
+// todoTools produce small results the model needs to stay on track; they are never evicted
+var todoTools = []string{
+	"add_todo", "add_bulk_todos", "update_todo_status", "update_todo_status_bulk", "list_todos",
+	"list_all_todos", "get_next_todo", "get_active_todos_compact", "auto_complete_todos", "archive_completed",
+}
+
+// evictionTier is one step of AggressiveOptimization, applied oldest message first
+type evictionTier struct {
+	reason    string
+	matches   func(msg api.Message, index int) bool
+	summarize func(msg api.Message) string
+}
+
+// AggressiveOptimization evicts content in priority order until the conversation
+// fits targetTokens: exploration shell output first, then other shell output, then
+// old file reads, and finally older assistant turns are summarized. The system
+// prompt, the original query, user messages, todo results and the last few turns
+// are never touched. A "context notes" message listing what was evicted is
+// inserted after the original query.
+func (co *ConversationOptimizer) AggressiveOptimization(messages []api.Message, targetTokens int) []api.Message {
+	if !co.settings.Enabled || len(messages) <= 2 {
 		return messages
 	}
 
-	optimized := make([]api.Message, 0, len(messages))
-	
-	// Always keep system message and recent messages (last 5)
-	systemMsg := messages[0]
-	optimized = append(optimized, systemMsg)
-	
-	// Keep the original user query (usually index 1)
-	if len(messages) > 1 {
-		optimized = append(optimized, messages[1])
+	optimized := make([]api.Message, len(messages))
+	copy(optimized, messages)
+
+	totalTokens := 0
+	for _, msg := range optimized {
+		totalTokens += co.messageTokens(msg)
 	}
-	
-	// For middle messages, apply aggressive summarization
-	recentThreshold := len(messages) - 5  // Keep last 5 messages intact
-	if recentThreshold < 2 {
-		recentThreshold = 2
+
+	isShell := func(msg api.Message, index int) bool {
+		return msg.Role == "user" && strings.HasPrefix(msg.Content, "Tool call result for shell_command:")
 	}
-	
-	for i := 2; i < recentThreshold; i++ {
-		msg := messages[i]
-		
-		// Only summarize file reads that are old (more than 8 messages ago)
-		messageAge := len(messages) - i
-		if msg.Role == "user" && strings.Contains(msg.Content, "Tool call result for read_file:") && messageAge > 8 {
-			summary := co.createAggressiveSummary(msg)
-			optimized = append(optimized, api.Message{
-				Role:    msg.Role,
-				Content: summary,
-			})
-		} else if msg.Role == "user" && strings.Contains(msg.Content, "Tool call result for shell_command:") {
-			// Still summarize shell commands aggressively as they're less critical for context
-			summary := co.createAggressiveSummary(msg)
-			optimized = append(optimized, api.Message{
-				Role:    msg.Role,
-				Content: summary,
-			})
-		} else {
-			// Keep non-tool messages but truncate if very long
-			content := msg.Content
-			if len(content) > 800 {  // Moderate truncation to balance context and size
-				content = content[:800] + "... [TRUNCATED for context limit]"
+	tiers := []evictionTier{
+		{"evicted_exploration", func(msg api.Message, index int) bool {
+			return isShell(msg, index) && co.isTransientCommand(co.commandOf(msg))
+		}, co.createAggressiveSummary},
+		{"evicted_shell", isShell, co.createAggressiveSummary},
+		{"evicted_read", func(msg api.Message, index int) bool {
+			return msg.Role == "user" && strings.HasPrefix(msg.Content, "Tool call result for read_file:")
+		}, co.createAggressiveSummary},
+		{"summarized_turn", func(msg api.Message, index int) bool {
+			return msg.Role == "assistant" || (msg.Role == "user" && strings.HasPrefix(msg.Content, "Tool call result for ") && !isTodoResult(msg))
+		}, co.summarizeTurnMessage},
+	}
+
+	var notes []string
+	protectedFrom := recentTurnStart(optimized, aggressiveKeepTurns)
+	for _, tier := range tiers {
+		for i := 2; i < protectedFrom && totalTokens > targetTokens; i++ {
+			msg := optimized[i]
+			if isCompacted(msg) || !tier.matches(msg, i) {
+				continue
 			}
-			optimized = append(optimized, api.Message{
-				Role:    msg.Role,
-				Content: content,
-			})
+
+			summary := tier.summarize(msg)
+			saved := co.messageTokens(msg) - co.tokens(summary)
+			if saved <= 0 {
+				continue
+			}
+
+			co.recordDrop(i, tier.reason, co.evictionTarget(msg, i), msg.Content+msg.ReasoningContent, summary)
+			if note := co.contextNote(msg, i); note != "" {
+				notes = append(notes, note)
+			}
+			optimized[i] = api.Message{Role: msg.Role, Content: summary, ToolCallID: msg.ToolCallID}
+			totalTokens -= saved
 		}
 	}
-	
-	// Always keep recent messages (last 5) completely intact
-	for i := recentThreshold; i < len(messages); i++ {
-		optimized = append(optimized, messages[i])
+
+	if co.debug && totalTokens > targetTokens {
+		fmt.Printf("⚠️  Eviction stopped at ~%d tokens (target %d) - only protected messages remain\n", totalTokens, targetTokens)
 	}
-	
-	return optimized
+
+	if len(notes) == 0 {
+		return optimized
+	}
+
+	// Keep what was learned from evicted messages in one synthetic message after the query
+	withNotes := make([]api.Message, 0, len(optimized)+1)
+	withNotes = append(withNotes, optimized[:2]...)
+	withNotes = append(withNotes, api.Message{
+		Role:    "user",
+		Content: "CONTEXT NOTES (older messages were compacted to fit the context window):\n" + strings.Join(notes, "\n"),
+	})
+	return append(withNotes, optimized[2:]...)
+}
+
+// contextNote returns a one-line bullet describing what an evicted message contained
+func (co *ConversationOptimizer) contextNote(msg api.Message, index int) string {
+	if msg.Role == "assistant" {
+		text := strings.TrimSpace(msg.Content)
+		if text == "" {
+			return ""
+		}
+		if end := strings.IndexAny(text, ".\n"); end > 0 {
+			text = text[:end]
+		}
+		if len(text) > 160 {
+			text = text[:160] + "..."
+		}
+		return "- Earlier step: " + text
+	}
+
+	switch {
+	case strings.HasPrefix(msg.Content, "Tool call result for read_file:"):
+		content := co.extractFileContent(msg.Content)
+		return fmt.Sprintf("- Read %s (%d lines) - read it again if you need its content", co.filePathOf(msg), strings.Count(content, "\n")+1)
+	case strings.HasPrefix(msg.Content, "Tool call result for shell_command:"):
+		output := co.extractShellOutput(msg.Content)
+		return fmt.Sprintf("- Ran `%s` (%d lines of output)", co.commandOf(msg), strings.Count(output, "\n")+1)
+	case strings.HasPrefix(msg.Content, "Tool call result for "):
+		header := strings.TrimPrefix(msg.Content, "Tool call result for ")
+		if end := strings.Index(header, ":"); end > 0 {
+			return fmt.Sprintf("- Used %s", header[:end])
+		}
+	}
+	return ""
+}
+
+// recentTurnStart returns the index of the n-th most recent assistant message;
+// everything from there on is protected from eviction
+func recentTurnStart(messages []api.Message, n int) int {
+	seen := 0
+	for i := len(messages) - 1; i >= 2; i-- {
+		if messages[i].Role == "assistant" {
+			seen++
+			if seen == n {
+				return i
+			}
+		}
+	}
+	return 2
+}
+
+// isTodoResult reports whether a message is the result of a todo tool
+func isTodoResult(msg api.Message) bool {
+	for _, tool := range todoTools {
+		if strings.HasPrefix(msg.Content, "Tool call result for "+tool+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompacted reports whether a message was already summarized
+func isCompacted(msg api.Message) bool {
+	for _, marker := range []string{"[COMPACT]", "[OPTIMIZED]", "[STALE]", "[SUMMARIZED]"} {
+		if strings.Contains(msg.Content, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// evictionTarget returns the file path or command of an evicted tool result, if any
+func (co *ConversationOptimizer) evictionTarget(msg api.Message, index int) string {
+	if path := co.filePathOf(msg); path != "" {
+		return path
+	}
+	return co.commandOf(msg)
+}
+
+// summarizeTurnMessage keeps the start of an older assistant message or tool result
+func (co *ConversationOptimizer) summarizeTurnMessage(msg api.Message) string {
+	const keep = 300
+	if msg.Role != "assistant" {
+		return co.createAggressiveSummary(msg)
+	}
+	if len(msg.Content) <= keep {
+		return msg.Content + " [SUMMARIZED]"
+	}
+	return msg.Content[:keep] + fmt.Sprintf("... [SUMMARIZED: %d chars omitted]", len(msg.Content)-keep)
 }
 
 // createAggressiveSummary creates very compact summaries for tool results
@@ -1,9 +1,12 @@
 package agent
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
 )
 
 func TestConversationOptimizer(t *testing.T) {
@@ -141,6 +144,254 @@ func TestCreateFileReadSummary(t *testing.T) {
 	}
 }
 
+func TestToolResultRecordOverridesTextParsing(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+
+	messages := []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "query"},
+		{Role: "user", Content: "Tool call result for read_file: package main\nfunc a() {}", ToolCallID: "call_1"},
+		{Role: "user", Content: "Tool call result for read_file: package main\nfunc b() {}", ToolCallID: "call_2"},
+	}
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_1", ToolName: "read_file", FilePath: "./a.go"})
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_2", ToolName: "read_file", FilePath: "b.go"})
+
+	optimizer.OptimizeConversation(messages)
+
+	paths := optimizer.getTrackedFilePaths()
+	if len(paths) != 2 {
+		t.Fatalf("Expected reads to be tracked per recorded path, got %v", paths)
+	}
+	if _, exists := optimizer.fileReads["a.go"]; !exists {
+		t.Errorf("Expected recorded path to be cleaned and tracked, got %v", paths)
+	}
+}
+
+func TestToolResultRecordsFollowTheirMessages(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_1", ToolName: "read_file", FilePath: "a.go"})
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_2", ToolName: "shell_command", Command: "go test ./..."})
+
+	// The read was compacted away, so the command result moved to index 2
+	messages := []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "query"},
+		{Role: "user", Content: "Tool call result for shell_command: go test ./...\nok", ToolCallID: "call_2"},
+	}
+	optimizer.OptimizeConversation(messages)
+	if paths := optimizer.getTrackedFilePaths(); len(paths) != 0 {
+		t.Errorf("expected the read's record not to attach to the command result, got %v", paths)
+	}
+	if command := optimizer.commandOf(messages[2]); command != "go test ./..." {
+		t.Errorf("expected the command result to keep its record, got %q", command)
+	}
+
+	// Results without an id aren't matched to any record
+	if _, exists := optimizer.recordFor(api.Message{Role: "user", Content: "Tool call result for read_file: a.go"}); exists {
+		t.Error("expected a result without a tool call id to have no record")
+	}
+}
+
+func TestInvalidateFileMarksEarlierReadsStale(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+
+	messages := []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "query"},
+		{Role: "user", Content: "Tool call result for read_file: main.go\npackage main"},
+		{Role: "assistant", Content: "editing"},
+		{Role: "user", Content: "Tool call result for edit_file: ok"},
+	}
+	optimizer.InvalidateFile("./main.go", 4)
+
+	optimized := optimizer.OptimizeConversation(messages)
+	if !containsString(optimized[2].Content, "[STALE]") {
+		t.Errorf("Expected read before the edit to be stale, got: %s", optimized[2].Content)
+	}
+}
+
+func TestRedundantReadRequiresSameModTime(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+
+	messages := []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "Tool call result for read_file: main.go\npackage main", ToolCallID: "call_1"},
+	}
+	for i := 0; i < 5; i++ {
+		messages = append(messages, api.Message{Role: "assistant", Content: "thinking"})
+	}
+	messages = append(messages, api.Message{Role: "user", Content: "Tool call result for read_file: main.go\npackage main", ToolCallID: "call_2"})
+
+	first := time.Unix(1000, 0)
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_1", ToolName: "read_file", FilePath: "main.go", ModTime: first})
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_2", ToolName: "read_file", FilePath: "main.go", ModTime: first.Add(time.Second)})
+
+	optimized := optimizer.OptimizeConversation(messages)
+	if containsString(optimized[1].Content, "[OPTIMIZED]") {
+		t.Errorf("Expected read to be kept when the file was modified between reads")
+	}
+}
+
+func TestInvalidateForShellCommand(t *testing.T) {
+	tests := []struct {
+		command     string
+		invalidated int
+	}{
+		{"ls -la", 0},
+		{"go test ./... 2>&1", 0},
+		{"sed -i 's/a/b/' main.go", 1},
+		{"echo hi > other.txt", 0},
+		{"git checkout -- .", 2},
+		{"gofmt -w *.go", 2},
+	}
+
+	for _, tt := range tests {
+		optimizer := NewConversationOptimizer(true, false)
+		optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_1", ToolName: "read_file", FilePath: "main.go"})
+		optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_2", ToolName: "read_file", FilePath: "util/util.go"})
+
+		paths := optimizer.InvalidateForShellCommand(tt.command, 4)
+		if len(paths) != tt.invalidated {
+			t.Errorf("%q: expected %d invalidated files, got %v", tt.command, tt.invalidated, paths)
+		}
+	}
+}
+
+func TestOptimizerSettingsTruncateAndReportDrops(t *testing.T) {
+	settings := DefaultOptimizerSettings()
+	settings.MaxToolResultChars = 50
+	optimizer := NewConversationOptimizerWithSettings(settings, false)
+
+	long := "Tool call result for shell_command: go test ./...\n" + strings.Repeat("x", 200)
+	messages := []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: strings.Repeat("q", 200)},
+		{Role: "user", Content: long},
+	}
+
+	optimized := optimizer.OptimizeConversation(messages)
+	if !containsString(optimized[2].Content, "[TRUNCATED by optimizer") {
+		t.Errorf("Expected long tool result to be truncated, got: %s", optimized[2].Content)
+	}
+	if optimized[1].Content != messages[1].Content {
+		t.Errorf("Expected non-tool messages to be left alone")
+	}
+
+	drops := optimizer.LastDrops()
+	if len(drops) != 1 || drops[0].Reason != "truncated" || drops[0].CharsSaved <= 0 {
+		t.Errorf("Expected one truncation drop, got %+v", drops)
+	}
+}
+
+func TestLoadOptimizerSettings(t *testing.T) {
+	cfg := &config.Config{Preferences: map[string]interface{}{
+		"optimizer_enabled":               false,
+		"optimizer_aggressiveness":        "aggressive",
+		"optimizer_transient_ttl":         float64(6),
+		"optimizer_max_tool_result_chars": float64(4000),
+	}}
+
+	settings := LoadOptimizerSettings(cfg)
+	if settings.Enabled || settings.Aggressiveness != OptimizeAggressive || settings.TransientTTL != 6 || settings.MaxToolResultChars != 4000 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	cfg.Preferences["optimizer_aggressiveness"] = "extreme"
+	if LoadOptimizerSettings(cfg).Aggressiveness != OptimizeBalanced {
+		t.Errorf("Expected unknown aggressiveness to fall back to balanced")
+	}
+}
+
+func TestAggressiveOptimizationEvictsInPriorityOrder(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+	big := strings.Repeat("x", 4000)
+
+	messages := []api.Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "original query"},
+		{Role: "assistant", Content: "reading " + big},                               // 2
+		{Role: "user", Content: "Tool call result for read_file: main.go\n" + big},   // 3
+		{Role: "assistant", Content: "listing"},                                      // 4
+		{Role: "user", Content: "Tool call result for shell_command: ls -R\n" + big}, // 5
+		{Role: "user", Content: "Tool call result for list_todos: " + big},           // 6
+		{Role: "assistant", Content: "turn 1"},                                       // 7
+		{Role: "assistant", Content: "turn 2"},                                       // 8
+		{Role: "assistant", Content: "turn 3"},                                       // 9
+		{Role: "user", Content: "Tool call result for shell_command: ls -R\n" + big}, // 10
+	}
+
+	// Budget that is met once the exploration output has been evicted
+	optimized := optimizer.AggressiveOptimization(messages, 4500)
+	if !containsString(optimized[2].Content, "CONTEXT NOTES") || !containsString(optimized[2].Content, "Ran `ls -R`") {
+		t.Fatalf("Expected context notes describing the evicted output, got: %s", optimized[2].Content)
+	}
+	optimized = withoutContextNotes(optimized)
+	if !containsString(optimized[5].Content, "[COMPACT]") {
+		t.Errorf("Expected old exploration output to be evicted first")
+	}
+	if optimized[3].Content != messages[3].Content || optimized[2].Content != messages[2].Content {
+		t.Errorf("Expected file reads and turns to survive while the budget is met")
+	}
+
+	// A tiny budget evicts everything that is not protected
+	optimized = withoutContextNotes(optimizer.AggressiveOptimization(messages, 10))
+	if !containsString(optimized[3].Content, "[COMPACT]") || !containsString(optimized[2].Content, "[SUMMARIZED") {
+		t.Errorf("Expected file reads and old turns to be evicted under a tiny budget")
+	}
+	for _, i := range []int{0, 1, 6, 7, 8, 9, 10} {
+		if optimized[i].Content != messages[i].Content {
+			t.Errorf("Expected protected message %d to survive eviction", i)
+		}
+	}
+}
+
+// withoutContextNotes drops the synthetic notes message inserted after the query
+func withoutContextNotes(messages []api.Message) []api.Message {
+	return append(messages[:2:2], messages[3:]...)
+}
+
+func TestEnforceContextBudget(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+	const maxTokens = 1000 // 4000 chars: code 2000, tools 1000, usable 3600
+
+	// A single giant shell output is cut down to the tools share
+	messages := []api.Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "query"},
+		{Role: "user", Content: "Tool call result for shell_command: go list ./...\n" + strings.Repeat("x", 5000)},
+	}
+	optimized := optimizer.EnforceContextBudget(messages, maxTokens)
+	if len(optimized[2].Content) > 1100 || !containsString(optimized[2].Content, "omitted to fit the context budget") {
+		t.Errorf("Expected giant output to be truncated to the tools budget, got %d chars", len(optimized[2].Content))
+	}
+
+	// Over the usable budget, the over-budget category is trimmed before code
+	messages = []api.Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "query"},
+		{Role: "user", Content: "Tool call result for read_file: main.go\n" + strings.Repeat("c", 1800)},
+		{Role: "user", Content: "Tool call result for shell_command: make\n" + strings.Repeat("x", 900)},
+		{Role: "user", Content: "Tool call result for shell_command: make test\n" + strings.Repeat("y", 900)},
+		{Role: "assistant", Content: "latest"},
+	}
+	optimized = optimizer.EnforceContextBudget(messages, maxTokens)
+	if optimized[2].Content != messages[2].Content {
+		t.Errorf("Expected code within its budget to be kept")
+	}
+	if !containsString(optimized[3].Content, "[COMPACT]") {
+		t.Errorf("Expected oldest tool output to be trimmed, got: %s", optimized[3].Content)
+	}
+}
+
+func TestContextBudgetValidate(t *testing.T) {
+	if err := DefaultContextBudget().Validate(); err != nil {
+		t.Errorf("Expected default budget to be valid: %v", err)
+	}
+	if err := (ContextBudget{System: 50, Code: 50, Tools: 25}).Validate(); err == nil {
+		t.Errorf("Expected budget over 100%% to be rejected")
+	}
+}
+
 // Helper function to check if string contains substring
 func containsString(text, substr string) bool {
 	return len(text) >= len(substr) && findSubstring(text, substr) != -1
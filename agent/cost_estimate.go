@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// Typical task shape used for cost previews
+const (
+	estimateMinIterations       = 3    // a small, focused task
+	estimateMaxIterations       = 12   // a task that explores, edits and verifies
+	estimateGrowthPerIteration  = 1500 // prompt tokens added by each round of tool results
+	estimateCompletionPerRound  = 500  // tokens the model writes per iteration
+	estimateMaxAttachedFileSize = 200000
+)
+
+// CostEstimate is a range for what a task will cost before it starts
+type CostEstimate struct {
+	Model        string
+	PromptTokens int // size of the first request
+	Low          float64
+	High         float64
+}
+
+// String formats the estimate for the confirmation prompt
+func (e *CostEstimate) String() string {
+	return fmt.Sprintf("estimated $%.2f–$%.2f on %s (first request ~%d tokens)", e.Low, e.High, e.Model, e.PromptTokens)
+}
+
+// EstimateTaskCost estimates a task's cost from the size of its first request
+// (system prompt, query and the files it mentions) and typical iteration
+// counts, priced at the current model's rates
+func (a *Agent) EstimateTaskCost(query string) (*CostEstimate, error) {
+	inputPrice, outputPrice, err := a.tokenPrices()
+	if err != nil {
+		return nil, err
+	}
+
+	context := a.taskSystemPrompt() + query + attachedFileContent(query)
+	if a.pairedPaused {
+		// A paired-mode reply continues the paused conversation
+		context += messagesText(a.messages)
+	}
+	base := a.countTokens(context)
+
+	cost := func(iterations int) float64 {
+		prompt := iterations*base + estimateGrowthPerIteration*iterations*(iterations-1)/2
+		completion := iterations * estimateCompletionPerRound
+		return float64(prompt)*inputPrice + float64(completion)*outputPrice
+	}
+	return &CostEstimate{
+		Model:        a.GetModel(),
+		PromptTokens: base,
+		Low:          cost(estimateMinIterations),
+		High:         cost(estimateMaxIterations),
+	}, nil
+}
+
+// tokenPrices returns USD per input and output token. The session's observed
+// cost per token is the most accurate; otherwise the provider's model list is
+// consulted, falling back to the built-in rate table.
+func (a *Agent) tokenPrices() (float64, float64, error) {
+	if a.clientType == api.OllamaClientType {
+		return 0, 0, fmt.Errorf("local models are free")
+	}
+	if a.totalTokens > 0 && a.totalCost > 0 {
+		perToken := a.totalCost / float64(a.totalTokens)
+		return perToken, perToken, nil
+	}
+
+	if a.modelPricing == nil {
+		a.modelPricing, _ = api.GetAvailableModels(a.clientType)
+	}
+	if model, ok := api.FindModel(a.modelPricing, a.GetModel()); ok {
+		// OpenRouter prices per token (zero for :free models), the other providers per million tokens
+		if a.clientType == api.OpenRouterClientType {
+			return model.InputCost, model.OutputCost, nil
+		}
+		if model.InputCost > 0 || model.OutputCost > 0 {
+			return model.InputCost / 1000000, model.OutputCost / 1000000, nil
+		}
+	}
+
+	perToken := a.calculateCachedCost(1000000) / 1000000
+	return perToken, perToken, nil
+}
+
+// attachedFileContent returns the content of existing files mentioned in the query
+func attachedFileContent(query string) string {
+	var content strings.Builder
+	for _, word := range strings.Fields(query) {
+		path := strings.Trim(word, "`'\",;:()")
+		if !strings.ContainsAny(path, "./") {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.Size() > estimateMaxAttachedFileSize {
+			continue
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			content.Write(data)
+		}
+	}
+	return content.String()
+}
+
+// messagesText concatenates message contents
+func messagesText(messages []api.Message) string {
+	var text strings.Builder
+	for _, msg := range messages {
+		text.WriteString(msg.Content)
+	}
+	return text.String()
+}
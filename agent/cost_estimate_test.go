@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTaskCost(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	defer os.Unsetenv("OPENROUTER_API_KEY")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation failure: %v", err)
+	}
+	// Price from observed session spend: $1 per million tokens
+	agent.totalTokens = 1000000
+	agent.totalCost = 1.0
+
+	small, err := agent.EstimateTaskCost("fix the typo in the README")
+	if err != nil {
+		t.Fatalf("EstimateTaskCost failed: %v", err)
+	}
+	if small.Low <= 0 || small.High <= small.Low {
+		t.Errorf("expected a positive, increasing range, got %s", small)
+	}
+
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.go")
+	os.WriteFile(big, []byte(strings.Repeat("func example() {}\n", 2000)), 0644)
+	withFile, err := agent.EstimateTaskCost("refactor " + big)
+	if err != nil {
+		t.Fatalf("EstimateTaskCost failed: %v", err)
+	}
+	if withFile.PromptTokens <= small.PromptTokens+8000 {
+		t.Errorf("expected the mentioned file to count toward the prompt, got %d vs %d tokens", withFile.PromptTokens, small.PromptTokens)
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// ErrTaskCrashed means the task panicked and was saved for resuming
+var ErrTaskCrashed = errors.New("task crashed")
+
+// ErrProviderFailed marks a provider request that failed for good, after the
+// client's own retries
+var ErrProviderFailed = errors.New("API request failed")
+
+// recoverTask runs deferred around the conversation loop. A panic, or a
+// provider failure that ends the task, saves the conversation, todos,
+// pending tool calls and file change journal for "coder resume <id>", so
+// hours of autonomous work aren't lost to a crash.
+func (a *Agent) recoverTask(result *string, err *error) {
+	var reason string
+	if r := recover(); r != nil {
+		reason = fmt.Sprintf("panic: %v", r)
+		a.debugLog("💥 %s\n%s\n", reason, debug.Stack())
+		*result = ""
+		*err = fmt.Errorf("%w: %v", ErrTaskCrashed, r)
+	} else if *err != nil && (errors.Is(*err, ErrProviderFailed) || errors.Is(*err, ErrNoChoices)) && !a.cancelled() {
+		reason = (*err).Error()
+	}
+	if reason == "" {
+		return
+	}
+
+	path, saveErr := a.saveTaskSnapshot(reason)
+	if saveErr != nil {
+		fmt.Printf("⚠️  The task stopped (%s) and couldn't be saved: %v\n", firstLine(reason), saveErr)
+		return
+	}
+	fmt.Printf("💾 The task stopped (%s); its conversation, todos and file changes were saved to %s\n", firstLine(reason), path)
+	fmt.Printf("▶️  Resume with: coder resume %s\n", strings.TrimSuffix(filepath.Base(path), ".json"))
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	if end := strings.IndexByte(text, '\n'); end >= 0 {
+		return text[:end]
+	}
+	return text
+}
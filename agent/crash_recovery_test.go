@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+// panickingClient fails every request with a nil-pointer panic
+type panickingClient struct{ api.ClientInterface }
+
+func (panickingClient) SendChatRequest(ctx context.Context, messages []api.Message, tools []api.Tool, reasoning string) (*api.ChatResponse, error) {
+	var resp *api.ChatResponse
+	return resp, errors.New(resp.ID)
+}
+
+func TestCrashRecovery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"content": "Done: I wrote notes.txt and verified the file."}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	tools.ClearTodos()
+	defer tools.ClearTodos()
+
+	agent.messages = []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "Write the notes"}}
+	tools.AddTodo("Write the notes", "", "high")
+	call := api.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "write_file"
+	call.Function.Arguments = `{"file_path": "notes.txt", "content": "hello\n"}`
+	agent.executeToolCalls([]api.ToolCall{call})
+
+	// A panic in the loop ends the task with an error instead of the program
+	replay := agent.client
+	agent.client = panickingClient{replay}
+	if _, err := agent.runConversation(); !errors.Is(err, ErrTaskCrashed) {
+		t.Fatalf("expected ErrTaskCrashed, got %v", err)
+	}
+	snapshot, _, err := LoadAbortedTask("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(snapshot.Reason, "nil pointer") || len(snapshot.Todos) != 1 || len(snapshot.Journal) != 1 || len(snapshot.Messages) != len(agent.messages) {
+		t.Fatalf("expected the conversation, todos and journal saved with the panic, got reason %q, %d todos, %d journal entries, %d messages",
+			snapshot.Reason, len(snapshot.Todos), len(snapshot.Journal), len(snapshot.Messages))
+	}
+
+	// Resuming restores the journal, so the change can still be undone
+	agent.client = replay
+	agent.changeJournal = nil
+	if _, err := agent.ResumeAbortedTask(""); err != nil {
+		t.Fatalf("ResumeAbortedTask: %v", err)
+	}
+	if _, err := agent.UndoChange(0); err != nil {
+		t.Errorf("expected the restored journal to undo the write: %v", err)
+	}
+
+	// A provider that fails for good saves the task too; the script has no responses left
+	if _, err := agent.runConversation(); !errors.Is(err, ErrProviderFailed) {
+		t.Fatalf("expected ErrProviderFailed, got %v", err)
+	}
+	if snapshot, _, err := LoadAbortedTask(""); err != nil || !strings.Contains(snapshot.Reason, "no response left") {
+		t.Errorf("expected the task saved with the provider error, got %+v (%v)", snapshot, err)
+	}
+}
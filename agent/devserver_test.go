@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// TestDevServerHelperProcess is the dev server started by the tests below; it
+// only serves when run with CODER_TEST_DEVSERVER_ADDR
+func TestDevServerHelperProcess(t *testing.T) {
+	addr := os.Getenv("CODER_TEST_DEVSERVER_ADDR")
+	if addr == "" {
+		return
+	}
+	// Record each start, so the tests can tell a reused server from a new one
+	starts, _ := os.OpenFile(os.Getenv("CODER_TEST_DEVSERVER_STARTS"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	fmt.Fprintln(starts, "started")
+	starts.Close()
+	http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	os.Exit(0)
+}
+
+// freeAddr returns a local address nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestEnsureDevServerStartsReusesAndStops(t *testing.T) {
+	addr := freeAddr(t)
+	startsPath := filepath.Join(t.TempDir(), "starts")
+	cfg := tools.DevServerConfig{
+		Command: fmt.Sprintf("CODER_TEST_DEVSERVER_ADDR=%s CODER_TEST_DEVSERVER_STARTS='%s' exec '%s' -test.run=TestDevServerHelperProcess",
+			addr, startsPath, os.Args[0]),
+		URL: "http://" + addr,
+	}
+	defer tools.StopDevServer()
+
+	if err := tools.EnsureDevServer(cfg); err != nil {
+		t.Fatalf("EnsureDevServer: %v", err)
+	}
+	if resp, err := http.Get(cfg.URL); err != nil {
+		t.Fatalf("expected the dev server to answer: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	// A running server is reused rather than started again
+	if err := tools.EnsureDevServer(cfg); err != nil {
+		t.Fatalf("EnsureDevServer with the server running: %v", err)
+	}
+	if data, _ := os.ReadFile(startsPath); strings.Count(string(data), "started") != 1 {
+		t.Errorf("expected the dev server to start once, got %q", data)
+	}
+
+	tools.StopDevServer()
+	stopped := false
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", addr); err != nil {
+			stopped = true
+			break
+		} else {
+			conn.Close()
+		}
+	}
+	if !stopped {
+		t.Error("expected StopDevServer to stop the dev server")
+	}
+}
+
+func TestEnsureDevServerUsesARunningServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Something already answers, so no command is needed
+	if err := tools.EnsureDevServer(tools.DevServerConfig{URL: server.URL}); err != nil {
+		t.Errorf("expected the running server to be used, got %v", err)
+	}
+
+	err := tools.EnsureDevServer(tools.DevServerConfig{URL: "http://" + freeAddr(t)})
+	if err == nil || !strings.Contains(err.Error(), "no dev_server_command") {
+		t.Errorf("expected an error without a server or command, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// Preferences selecting the embeddings provider and model
+const (
+	prefEmbeddingsProvider = "embeddings_provider" // openai, deepinfra, mistral or ollama
+	prefEmbeddingsModel    = "embeddings_model"    // empty = the provider's default
+)
+
+// Embeddings returns the session's embeddings client, created on first use
+// from the preferences or, without them, from the available credentials
+func (a *Agent) Embeddings() (api.EmbeddingsClient, error) {
+	if a.embeddings != nil {
+		return a.embeddings, nil
+	}
+
+	provider, model := "", ""
+	if a.configManager != nil {
+		cfg := a.configManager.GetConfig()
+		provider = cfg.GetStringPreference(prefEmbeddingsProvider, "")
+		model = cfg.GetStringPreference(prefEmbeddingsModel, "")
+	}
+	if provider == "" {
+		provider = api.DefaultEmbeddingsProvider()
+	}
+	if provider == "" {
+		return nil, fmt.Errorf("no embeddings provider available: run Ollama or set OPENAI_API_KEY, DEEPINFRA_API_KEY or MISTRAL_API_KEY")
+	}
+
+	client, err := api.NewEmbeddingsClient(provider, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings client: %w", err)
+	}
+	a.debugLog("🧮 Using %s embeddings (%s)\n", provider, client.Model())
+	a.embeddings = client
+	return client, nil
+}
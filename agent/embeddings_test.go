@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// fakeEmbeddingsServer embeds each text as [len(text), 1], answering in reverse order
+func fakeEmbeddingsServer(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		var body struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		if r.URL.Path == "/api/embed" {
+			embeddings := make([][]float32, len(body.Input))
+			for i, text := range body.Input {
+				embeddings[i] = []float32{float32(len(text)), 1}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+			return
+		}
+		var data []map[string]interface{}
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			data = append(data, map[string]interface{}{"index": i, "embedding": []float32{float32(len(body.Input[i])), 1}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+}
+
+func TestOpenAICompatibleEmbeddingsBatches(t *testing.T) {
+	requests := 0
+	server := fakeEmbeddingsServer(t, &requests)
+	defer server.Close()
+
+	client := api.NewOpenAICompatibleEmbeddings(server.URL+"/v1/embeddings", "key", "test-embed")
+	client.BatchSize = 2
+	client.SetRateLimit(0)
+
+	vectors, err := client.Embed([]string{"a", "bb", "ccc", "dddd", "eeeee"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 batched requests, got %d", requests)
+	}
+	for i, vector := range vectors {
+		if vector[0] != float32(i+1) {
+			t.Errorf("vector %d out of order: %v", i, vector)
+		}
+	}
+}
+
+func TestOllamaEmbeddings(t *testing.T) {
+	requests := 0
+	server := fakeEmbeddingsServer(t, &requests)
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	client, err := api.NewEmbeddingsClient("ollama", "")
+	if err != nil {
+		t.Fatalf("NewEmbeddingsClient: %v", err)
+	}
+	if client.Model() != "nomic-embed-text" {
+		t.Errorf("expected the default Ollama model, got %q", client.Model())
+	}
+	vectors, err := client.Embed([]string{"x", "yy"})
+	if err != nil || len(vectors) != 2 || vectors[1][0] != 2 {
+		t.Errorf("unexpected vectors %v (%v)", vectors, err)
+	}
+}
+
+func TestNewEmbeddingsClientErrors(t *testing.T) {
+	if _, err := api.NewEmbeddingsClient("cerebras", ""); err == nil {
+		t.Error("expected an error for a provider without embeddings")
+	}
+	t.Setenv("MISTRAL_API_KEY", "")
+	if _, err := api.NewEmbeddingsClient("mistral", ""); err == nil {
+		t.Error("expected an error without MISTRAL_API_KEY")
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := api.CosineSimilarity([]float32{1, 0}, []float32{1, 0}); math.Abs(got-1) > 1e-9 {
+		t.Errorf("identical vectors: got %v", got)
+	}
+	if got := api.CosineSimilarity([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("orthogonal vectors: got %v", got)
+	}
+	if got := api.CosineSimilarity([]float32{1}, []float32{1, 2}); got != 0 {
+		t.Errorf("mismatched lengths: got %v", got)
+	}
+}
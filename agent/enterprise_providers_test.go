@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/providers"
+)
+
+func TestSignSigV4(t *testing.T) {
+	// Example request and signature from the AWS Signature Version 4 documentation
+	req, err := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := providers.AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	providers.SignSigV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("unexpected Authorization header:\n got %s\nwant %s", got, expected)
+	}
+
+	// Session credentials add a signed security token
+	req.Header.Del("Authorization")
+	creds.SessionToken = "session"
+	providers.SignSigV4(req, nil, creds, "us-east-1", "iam", time.Now())
+	if req.Header.Get("X-Amz-Security-Token") != "session" || !strings.Contains(req.Header.Get("Authorization"), "x-amz-security-token") {
+		t.Errorf("expected the session token to be sent and signed, got %q", req.Header.Get("Authorization"))
+	}
+}
+
+func TestEnterpriseProviderLookup(t *testing.T) {
+	for name, expected := range map[string]api.ClientType{"azure": api.AzureOpenAIClientType, "Bedrock": api.BedrockClientType} {
+		provider, err := api.GetProviderFromString(name)
+		if err != nil || provider != expected {
+			t.Errorf("GetProviderFromString(%q) = %q, %v", name, provider, err)
+		}
+	}
+	if name := api.GetProviderName(api.BedrockClientType); name != "AWS Bedrock" {
+		t.Errorf("unexpected provider name %q", name)
+	}
+}
+
+func TestAzureOpenAIDeployments(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_API_KEY", "test-key")
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://example.openai.azure.com/")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENT", "gpt-4o-prod")
+	t.Setenv("AZURE_OPENAI_DEPLOYMENTS", "gpt-4o-prod, gpt-4.1-mini")
+
+	if model := api.GetDefaultModelForProvider(api.AzureOpenAIClientType); model != "gpt-4o-prod" {
+		t.Errorf("expected the configured deployment as default model, got %q", model)
+	}
+
+	models, err := api.GetAvailableModels(api.AzureOpenAIClientType)
+	if err != nil {
+		t.Fatalf("GetAvailableModels: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "gpt-4o-prod" || models[1].ID != "gpt-4.1-mini" {
+		t.Errorf("expected the configured deployments once each, got %v", models)
+	}
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	if _, err := providers.NewAzureOpenAIProvider(); err == nil {
+		t.Error("expected an error without AZURE_OPENAI_ENDPOINT")
+	}
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// maxExplainDiffChars bounds how much of a diff is sent to be explained
+const maxExplainDiffChars = 120000
+
+// explainDiffFormat is the reply format of ExplainDiff, added to the review prompt
+const explainDiffFormat = `Reply in markdown with these sections:
+
+## Summary
+Two or three sentences on what the change does and why, as far as the diff and message show.
+
+## Changes
+The changes grouped by file or area, one bullet each. Describe behavior, not line-by-line edits.
+
+## Review notes
+The real problems you found, or "None".
+
+Be concise.`
+
+// ExplainDiff explains a diff in a single request, skipping the exploration
+// loop: the model sees only the diff, plus an optional note such as a commit
+// message or a question to focus on. The conversation is left untouched.
+func (a *Agent) ExplainDiff(diff, note string) (string, error) {
+	if strings.TrimSpace(diff) == "" {
+		return "", fmt.Errorf("the diff is empty")
+	}
+	return a.generateText([]api.Message{
+		{Role: "system", Content: SystemPrompt(PromptReview) + "\n\n" + explainDiffFormat},
+		{Role: "user", Content: explainDiffRequest(diff, note)},
+	})
+}
+
+// GenerateText asks the model a one-off question outside the conversation,
+// with the system prompt of a variant and no tools
+func (a *Agent) GenerateText(variant PromptVariant, prompt string) (string, error) {
+	return a.generateText([]api.Message{
+		{Role: "system", Content: SystemPrompt(variant)},
+		{Role: "user", Content: prompt},
+	})
+}
+
+// generateText sends a request without tools and tracks its usage
+func (a *Agent) generateText(messages []api.Message) (string, error) {
+	resp, err := a.sendChatRequest(messages, nil)
+	if err != nil {
+		return "", fmt.Errorf("API request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response choices returned")
+	}
+	a.totalCost += resp.Usage.EstimatedCost
+	a.totalTokens += resp.Usage.TotalTokens
+	a.promptTokens += resp.Usage.PromptTokens
+	a.completionTokens += resp.Usage.CompletionTokens
+	return resp.Choices[0].Message.Content, nil
+}
+
+// explainDiffRequest builds the user message of ExplainDiff, cutting the diff
+// at maxExplainDiffChars on a line boundary
+func explainDiffRequest(diff, note string) string {
+	diff = strings.TrimRight(diff, "\n")
+	truncated := ""
+	if len(diff) > maxExplainDiffChars {
+		cut := strings.LastIndex(diff[:maxExplainDiffChars], "\n")
+		if cut < 0 {
+			cut = maxExplainDiffChars
+		}
+		truncated = fmt.Sprintf("\n[diff truncated: %d of %d characters shown]", cut, len(diff))
+		diff = diff[:cut]
+	}
+
+	var b strings.Builder
+	if note = strings.TrimSpace(note); note != "" {
+		b.WriteString(note)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("```diff\n")
+	b.WriteString(diff)
+	b.WriteString("\n```")
+	b.WriteString(truncated)
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestExplainDiff(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"expect": "+\treturn nil", "content": "## Summary\nRun no longer fails.\n\n## Review notes\nNone"}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	messages := len(agent.messages)
+	diff := "--- a/run.go\n+++ b/run.go\n@@ -1 +1 @@\n-\treturn err\n+\treturn nil\n"
+	explanation, err := agent.ExplainDiff(diff, "Explain this commit.")
+	if err != nil || !strings.Contains(explanation, "Run no longer fails") {
+		t.Fatalf("expected the scripted explanation, got %q (%v)", explanation, err)
+	}
+	if len(agent.messages) != messages {
+		t.Error("expected the conversation to be left alone")
+	}
+	if _, err := agent.ExplainDiff("\n", ""); err == nil {
+		t.Error("expected an empty diff to fail")
+	}
+
+	long := strings.Repeat("+ added line\n", maxExplainDiffChars/10)
+	request := explainDiffRequest(long, "")
+	if len(request) > maxExplainDiffChars+200 || !strings.Contains(request, "[diff truncated:") || !strings.Contains(request, "+ added line\n```") {
+		t.Errorf("expected the diff cut on a line boundary with a note, got %d characters ending %q", len(request), request[len(request)-80:])
+	}
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/tools"
+)
+
+const (
+	prefEmptyResponseRetries = "empty_response_retries" // retries of the same model before failing over
+	prefFailoverChain        = "failover_chain"         // comma-separated "provider" or "provider:model" entries
+)
+
+// ErrNoChoices means the model, its retries and every failover candidate
+// returned responses without choices
+var ErrNoChoices = errors.New("no response choices returned")
+
+// emptyResponseBackoff is the delay before the first retry; it doubles per retry
+var emptyResponseBackoff = time.Second
+
+// failoverCandidate is a provider and model to switch to
+type failoverCandidate struct {
+	provider api.ClientType
+	model    string
+}
+
+// sendChatRequest sends a request, retrying an empty response with jittered
+// backoff and then failing over along the configured chain. A successful
+// failover keeps the new client for the rest of the session.
+func (a *Agent) sendChatRequest(messages []api.Message, toolDefs []api.Tool) (*api.ChatResponse, error) {
+	resp, err := a.requestFrom(a.client, messages, toolDefs)
+	if err != nil || len(resp.Choices) > 0 {
+		return resp, err
+	}
+
+	retries := 3
+	if a.configManager != nil {
+		retries = a.configManager.GetConfig().GetIntPreference(prefEmptyResponseRetries, retries)
+	}
+	for attempt := 0; attempt < retries; attempt++ {
+		delay := emptyResponseBackoff << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		a.debugLog("⚠️  %s returned no choices, retrying in %v (%d/%d)\n", a.GetModel(), delay.Round(time.Millisecond), attempt+1, retries)
+		select {
+		case <-time.After(delay):
+		case <-a.operationContext().Done():
+			return nil, ErrCancelled
+		}
+		resp, err = a.requestFrom(a.client, messages, toolDefs)
+		if err != nil || len(resp.Choices) > 0 {
+			return resp, err
+		}
+	}
+
+	for _, candidate := range a.failoverCandidates() {
+		client, err := api.NewUnifiedClientWithModel(candidate.provider, candidate.model)
+		if err != nil {
+			a.debugLog("⚠️  Failover to %s unavailable: %v\n", api.GetProviderName(candidate.provider), err)
+			continue
+		}
+		client.SetDebug(a.debug)
+		fmt.Printf("⚠️  %s returned no response after %d retries, failing over to %s (%s)\n", a.GetModel(), retries, client.GetModel(), api.GetProviderName(candidate.provider))
+		resp, err := a.requestFrom(client, messages, toolDefs)
+		if err != nil || len(resp.Choices) == 0 {
+			a.debugLog("⚠️  Failover to %s failed: %v\n", client.GetModel(), err)
+			continue
+		}
+		a.client = client
+		a.clientType = candidate.provider
+		a.maxContextTokens = a.getModelContextLimit()
+		return resp, nil
+	}
+	return nil, ErrNoChoices
+}
+
+// failoverCandidates returns the providers and models to try after the current
+// one: the failover_chain preference if set, otherwise the available providers
+// in priority order with their configured models
+func (a *Agent) failoverCandidates() []failoverCandidate {
+	if a.configManager == nil {
+		return nil
+	}
+	cfg := a.configManager.GetConfig()
+	current := failoverCandidate{a.clientType, a.GetModel()}
+
+	var entries []string
+	if chain := cfg.GetStringPreference(prefFailoverChain, ""); chain != "" {
+		entries = strings.Split(chain, ",")
+	} else {
+		available := make(map[api.ClientType]bool)
+		for _, provider := range a.configManager.ListAvailableProviders() {
+			available[provider] = true
+		}
+		for _, name := range cfg.ProviderPriority {
+			if provider, err := config.GetProviderFromConfigName(name); err == nil && available[provider] {
+				entries = append(entries, name)
+			}
+		}
+	}
+
+	var candidates []failoverCandidate
+	for _, entry := range entries {
+		// Model names may contain colons ("qwen3:30b"), provider names don't
+		name, model, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		provider, err := config.GetProviderFromConfigName(name)
+		if err != nil {
+			a.debugLog("⚠️  Ignoring failover entry %q: %v\n", entry, err)
+			continue
+		}
+		if model == "" {
+			model = cfg.GetModelForProvider(provider)
+		}
+		if candidate := (failoverCandidate{provider, model}); candidate != current {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// partialResultReport describes what a task got done before it had to stop
+func (a *Agent) partialResultReport() string {
+	var report strings.Builder
+	report.WriteString("⚠️  The task stopped early: no model returned a response. Progress so far:\n")
+
+	var completed, open []string
+	for _, todo := range tools.GetAllTodos() {
+		switch todo.Status {
+		case "completed":
+			completed = append(completed, todo.Title)
+		case "pending", "in_progress":
+			open = append(open, todo.Title)
+		}
+	}
+	if len(completed) > 0 {
+		report.WriteString("\nCompleted todos:\n")
+		for _, title := range completed {
+			fmt.Fprintf(&report, "  ✅ %s\n", title)
+		}
+	}
+	if len(open) > 0 {
+		report.WriteString("\nRemaining todos:\n")
+		for _, title := range open {
+			fmt.Fprintf(&report, "  ⬜ %s\n", title)
+		}
+	}
+
+	var changes []string
+	for _, action := range a.taskActions {
+		if action.Change != nil {
+			changes = append(changes, action.Change.Summary())
+		} else if action.Type == "file_created" || action.Type == "file_modified" {
+			changes = append(changes, action.Description)
+		}
+	}
+	if len(changes) > 0 {
+		report.WriteString("\nChanges made:\n")
+		for _, change := range changes {
+			fmt.Fprintf(&report, "  • %s\n", change)
+		}
+	}
+	if len(completed)+len(open)+len(changes) == 0 {
+		report.WriteString("\nNothing was completed or changed.\n")
+	}
+	return report.String()
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+// emptyChoicesClient returns responses without choices until it has answered
+// empty times; other interface methods aren't used by these tests
+type emptyChoicesClient struct {
+	api.ClientInterface
+	empty, calls int
+}
+
+func (c *emptyChoicesClient) SendChatRequest(ctx context.Context, messages []api.Message, tools []api.Tool, reasoning string) (*api.ChatResponse, error) {
+	c.calls++
+	resp := &api.ChatResponse{}
+	if c.calls > c.empty {
+		resp.Choices = make([]api.Choice, 1)
+		resp.Choices[0].Message.Content = "done"
+	}
+	return resp, nil
+}
+
+func (c *emptyChoicesClient) GetModel() string { return "flaky/model" }
+
+func TestSendChatRequestRecovery(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	backoff := emptyResponseBackoff
+	emptyResponseBackoff = time.Millisecond
+	defer func() { emptyResponseBackoff = backoff }()
+	prefs := agent.configManager.GetConfig().Preferences
+	prefs[prefEmptyResponseRetries] = 2.0
+	defer delete(prefs, prefEmptyResponseRetries)
+	defer delete(prefs, prefFailoverChain)
+	original := agent.client
+	defer func() { agent.client = original }()
+
+	// Retries recover a transient empty response
+	flaky := &emptyChoicesClient{empty: 2}
+	agent.client = flaky
+	resp, err := agent.sendChatRequest(nil, nil)
+	if err != nil || len(resp.Choices) != 1 || flaky.calls != 3 {
+		t.Fatalf("expected success on the last retry, got %v after %d calls", err, flaky.calls)
+	}
+
+	// Then the chain takes over, and its client is kept
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [{"content": "from the backup"}]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	prefs[prefFailoverChain] = "nosuchprovider, openrouter:backup/model"
+	agent.client = &emptyChoicesClient{empty: 100}
+	resp, err = agent.sendChatRequest(nil, nil)
+	if err != nil || resp.Choices[0].Message.Content != "from the backup" {
+		t.Fatalf("expected the failover response, got %+v, %v", resp, err)
+	}
+	if agent.GetModel() != "backup/model" {
+		t.Errorf("expected to keep the failover client, got model %s", agent.GetModel())
+	}
+
+	// With nothing left to try, the request fails with ErrNoChoices
+	prefs[prefFailoverChain] = "openrouter:flaky/model"
+	agent.client = &emptyChoicesClient{empty: 100}
+	agent.clientType = api.OpenRouterClientType
+	if _, err := agent.sendChatRequest(nil, nil); !errors.Is(err, ErrNoChoices) {
+		t.Errorf("expected ErrNoChoices, got %v", err)
+	}
+}
+
+func TestPartialResultReport(t *testing.T) {
+	tools.ClearTodos()
+	defer tools.ClearTodos()
+	tools.AddTodo("Add the endpoint", "", "high")
+	tools.AddTodo("Write tests", "", "medium")
+	tools.UpdateTodoStatus(tools.GetAllTodos()[0].ID, "completed")
+
+	a := &Agent{taskActions: []TaskAction{
+		{Type: "file_modified", Description: "Edited api.go", Details: "api.go"},
+		{Type: "file_read", Description: "Read main.go", Details: "main.go"},
+	}}
+	report := a.partialResultReport()
+	for _, want := range []string{"✅ Add the endpoint", "⬜ Write tests", "• Edited api.go"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report is missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "main.go") {
+		t.Errorf("reads shouldn't be reported as changes:\n%s", report)
+	}
+
+	tools.ClearTodos()
+	if report := (&Agent{}).partialResultReport(); !strings.Contains(report, "Nothing was completed") {
+		t.Errorf("expected an empty report, got:\n%s", report)
+	}
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// indexedFile is a content hash with the size and modification time it was
+// computed at; a different size or time invalidates it
+type indexedFile struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"hash"`
+	Hashed  int64  `json:"hashed"` // when the hash was computed
+}
+
+// validFor reports whether the entry still describes the file. A hash taken
+// right after a write is not trusted, since a second write within the same
+// timestamp would go unnoticed.
+func (e indexedFile) validFor(info os.FileInfo) bool {
+	return e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano() &&
+		time.Duration(e.Hashed-e.ModTime) >= racyWindow
+}
+
+// FileIndex persists workspace file hashes across sessions, so a file is only
+// hashed again after it changes. Files git already knows are left to git's
+// blob ids, so only untracked, locally modified and changed files are hashed,
+// which keeps large monorepos fast.
+type FileIndex struct {
+	path  string
+	Files map[string]indexedFile `json:"files"` // relative path -> hash
+	dirty bool
+}
+
+// OpenFileIndex loads the index for the project rooted at dir
+func OpenFileIndex(dir string) (*FileIndex, error) {
+	cacheDir, err := projectCacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return openFileIndexAt(filepath.Join(cacheDir, "file_index.json")), nil
+}
+
+// openFileIndexAt loads (or starts) an index file; a corrupt index is started over
+func openFileIndexAt(path string) *FileIndex {
+	index := &FileIndex{path: path}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, index)
+	}
+	if index.Files == nil {
+		index.Files = make(map[string]indexedFile)
+	}
+	return index
+}
+
+// Hash returns the content hash of a workspace file, reusing the indexed
+// hash while the file's size and modification time are unchanged
+func (ix *FileIndex) Hash(root, rel string, info os.FileInfo) (string, error) {
+	if entry, ok := ix.Files[rel]; ok && entry.validFor(info) {
+		return entry.Hash, nil
+	}
+	hash, err := hashWorkspaceFile(filepath.Join(root, rel))
+	if err != nil {
+		return "", err
+	}
+	ix.Files[rel] = indexedFile{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash, Hashed: time.Now().UnixNano()}
+	ix.dirty = true
+	return hash, nil
+}
+
+// Cached returns the indexed hash if it is still valid for info, without hashing
+func (ix *FileIndex) Cached(rel string, info os.FileInfo) (string, bool) {
+	entry, ok := ix.Files[rel]
+	if !ok || !entry.validFor(info) {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// Save writes the index if hashes were added, keeping entries other instances saved
+func (ix *FileIndex) Save() error {
+	if !ix.dirty || ix.path == "" {
+		return nil
+	}
+	return config.WithFileLock(ix.path, func() error {
+		onDisk := openFileIndexAt(ix.path)
+		for rel, entry := range onDisk.Files {
+			if _, ok := ix.Files[rel]; !ok {
+				ix.Files[rel] = entry
+			}
+		}
+		data, err := json.Marshal(ix)
+		if err != nil {
+			return fmt.Errorf("failed to marshal file index: %w", err)
+		}
+		if err := config.WriteFileAtomic(ix.path, data, 0600); err != nil {
+			return err
+		}
+		ix.dirty = false
+		return nil
+	})
+}
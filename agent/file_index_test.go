@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFileIndexReusesHashes tests that hashes survive a reload and are
+// invalidated by a size or modification time change
+func TestFileIndexReusesHashes(t *testing.T) {
+	root := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "file_index.json")
+	file := filepath.Join(root, "main.go")
+	os.WriteFile(file, []byte("package main"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(file, old, old)
+	info, _ := os.Stat(file)
+
+	index := openFileIndexAt(indexPath)
+	hash, err := index.Hash(root, "main.go", info)
+	if err != nil {
+		t.Fatalf("Failed to hash file: %v", err)
+	}
+	if err := index.Save(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+
+	reloaded := openFileIndexAt(indexPath)
+	if cached, ok := reloaded.Cached("main.go", info); !ok || cached != hash {
+		t.Fatalf("Expected the saved hash to be reused, got %q (%v)", cached, ok)
+	}
+
+	os.WriteFile(file, []byte("package main // changed"), 0644)
+	info, _ = os.Stat(file)
+	if _, ok := reloaded.Cached("main.go", info); ok {
+		t.Error("Expected a changed file to invalidate its hash")
+	}
+	if rehashed, _ := reloaded.Hash(root, "main.go", info); rehashed == hash {
+		t.Error("Expected a new hash for changed content")
+	}
+}
+
+// TestFileIndexDistrustsFreshHashes tests that a hash taken right after a
+// write is recomputed, since a same-size rewrite could keep the timestamp
+func TestFileIndexDistrustsFreshHashes(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "fresh.txt")
+	os.WriteFile(file, []byte("aaaa"), 0644)
+	info, _ := os.Stat(file)
+
+	index := openFileIndexAt("")
+	index.Hash(root, "fresh.txt", info)
+	if _, ok := index.Cached("fresh.txt", info); ok {
+		t.Error("Expected a hash of a just-written file not to be trusted")
+	}
+
+	os.WriteFile(file, []byte("bbbb"), 0644)
+	os.Chtimes(file, info.ModTime(), info.ModTime())
+	first, _ := hashWorkspaceFile(file)
+	if hash, _ := index.Hash(root, "fresh.txt", info); hash != first {
+		t.Error("Expected the rewritten file to be hashed again")
+	}
+}
+
+// TestWorkspaceSnapshotSameStatEdit tests that a file rewritten right after
+// the snapshot with the same size and modification time is still detected
+func TestWorkspaceSnapshotSameStatEdit(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "config.txt")
+	os.WriteFile(file, []byte("aaaa"), 0644)
+	info, _ := os.Stat(file)
+
+	snapshot, err := captureWorkspaceSnapshot(root, openFileIndexAt(""))
+	if err != nil {
+		t.Fatalf("Failed to capture snapshot: %v", err)
+	}
+
+	os.WriteFile(file, []byte("bbbb"), 0644)
+	os.Chtimes(file, info.ModTime(), info.ModTime())
+
+	changes, err := snapshot.Diff()
+	if err != nil {
+		t.Fatalf("Failed to diff snapshot: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Status != "modified" {
+		t.Errorf("Expected config.txt to be modified, got %+v", changes)
+	}
+}
+
+// TestWorkspaceSnapshotTouchedFile tests that a file whose timestamp changed
+// but whose content didn't is not reported
+func TestWorkspaceSnapshotTouchedFile(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "notes.txt")
+	os.WriteFile(file, []byte("unchanged"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(file, old, old)
+
+	index := openFileIndexAt("")
+	info, _ := os.Stat(file)
+	index.Hash(root, "notes.txt", info)
+
+	snapshot, err := captureWorkspaceSnapshot(root, index)
+	if err != nil {
+		t.Fatalf("Failed to capture snapshot: %v", err)
+	}
+	now := time.Now()
+	os.Chtimes(file, now, now)
+
+	changes, err := snapshot.Diff()
+	if err != nil {
+		t.Fatalf("Failed to diff snapshot: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes for a touched file, got %+v", changes)
+	}
+}
+
+// TestIsWholeRepoListing tests which commands enumerate the whole repository
+func TestIsWholeRepoListing(t *testing.T) {
+	tests := map[string]bool{
+		"tree":                 true,
+		"tree .":               true,
+		"tree -a":              true,
+		"tree -L 2":            false,
+		"tree src":             false,
+		"find .":               true,
+		"find . -name '*.go'":  false,
+		"ls -R":                true,
+		"ls -laR .":            true,
+		"ls -la":               false,
+		"ls -R pkg":            false,
+		"git ls-files":         true,
+		"git ls-files pkg/":    false,
+		"git ls-files | wc -l": false,
+		"grep -rn foo .":       false,
+	}
+	for command, expected := range tests {
+		if got := isWholeRepoListing(command); got != expected {
+			t.Errorf("isWholeRepoListing(%q) = %v, expected %v", command, got, expected)
+		}
+	}
+}
+
+// TestSparseOverview tests the directory summary given for large repositories
+func TestSparseOverview(t *testing.T) {
+	files := []string{"go.mod", "README.md"}
+	for i := 0; i < 30; i++ {
+		files = append(files, filepath.Join("services", "svc", string(rune('a'+i%26))+".go"))
+	}
+	for i := 0; i < 3; i++ {
+		files = append(files, filepath.Join("docs", string(rune('a'+i))+".md"))
+	}
+
+	overview := sparseOverview(files)
+	if !strings.HasPrefix(overview, "[SPARSE]") {
+		t.Errorf("Expected the overview to be marked sparse, got: %s", overview)
+	}
+	services := strings.Index(overview, "services/ (30 files)")
+	docs := strings.Index(overview, "docs/ (3 files)")
+	if services < 0 || docs < 0 || services > docs {
+		t.Errorf("Expected directories ordered by file count, got: %s", overview)
+	}
+	if !strings.Contains(overview, "README.md, go.mod") {
+		t.Errorf("Expected root files to be listed, got: %s", overview)
+	}
+}
@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// fileState is the on-disk fingerprint of a watched file
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+	hash    string // SHA-256 of the content; only set for tracked files
+}
+
+// FileWatcher tracks files the agent has read so that edits made outside the
+// agent (for example in the user's editor) can be detected. Files are polled at
+// iteration boundaries and before edits, which is when the model can act on it.
+// Polls compare stat fingerprints and confirm changes by content hash; edits
+// always compare hashes, so a same-size edit within the mtime granularity is
+// still caught.
+type FileWatcher struct {
+	mu    sync.Mutex
+	files map[string]fileState
+	stale map[string]bool
+	locks map[string]*sync.Mutex
+}
+
+// NewFileWatcher creates an empty file watcher
+func NewFileWatcher() *FileWatcher {
+	return &FileWatcher{
+		files: make(map[string]fileState),
+		stale: make(map[string]bool),
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// Track records the current on-disk state of a file the agent has seen or written
+func (w *FileWatcher) Track(path string) {
+	path = filepath.Clean(path)
+	state := fingerprintFile(path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[path] = state
+	delete(w.stale, path)
+}
+
+// Forget stops watching a file
+func (w *FileWatcher) Forget(path string) {
+	path = filepath.Clean(path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.files, path)
+	delete(w.stale, path)
+}
+
+// Lock serializes edits to one file and returns the function that releases it
+func (w *FileWatcher) Lock(path string) func() {
+	path = filepath.Clean(path)
+	w.mu.Lock()
+	lock, ok := w.locks[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		w.locks[path] = lock
+	}
+	w.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// CheckChanges returns files that changed on disk since they were last tracked.
+// Each change is reported once; the file stays stale until it is tracked again.
+func (w *FileWatcher) CheckChanges() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var changed []string
+	for path, known := range w.files {
+		if w.stale[path] {
+			continue
+		}
+		current := statFile(path)
+		if !current.differs(known) {
+			continue
+		}
+		// Touched but unchanged (a checkout or formatter run that changed nothing)
+		if current.exists && known.exists && hashFile(path) == known.hash {
+			current.hash = known.hash
+			w.files[path] = current
+			continue
+		}
+		w.stale[path] = true
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// IsStale reports whether a watched file changed on disk since it was last tracked
+func (w *FileWatcher) IsStale(path string) bool {
+	_, _, stale := w.Conflict(path)
+	return stale
+}
+
+// Conflict compares a watched file's content hash with the hash recorded when
+// it was last read or written. It returns both hashes and whether they differ.
+func (w *FileWatcher) Conflict(path string) (readHash, currentHash string, conflict bool) {
+	path = filepath.Clean(path)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	known, watched := w.files[path]
+	if !watched {
+		return "", "", false
+	}
+	current := fingerprintFile(path)
+	return known.hash, current.hash, w.stale[path] || current.exists != known.exists || current.hash != known.hash
+}
+
+// statFile returns the current fingerprint of a file
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// fingerprintFile returns the current fingerprint of a file including its content hash
+func fingerprintFile(path string) fileState {
+	state := statFile(path)
+	if state.exists {
+		state.hash = hashFile(path)
+	}
+	return state
+}
+
+// hashFile returns the hex SHA-256 of a file's content, or "" if it can't be read
+func hashFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// differs reports whether two fingerprints describe different file contents
+func (s fileState) differs(other fileState) bool {
+	return s.exists != other.exists || s.size != other.size || !s.modTime.Equal(other.modTime)
+}
+
+// handleExternalFileChanges invalidates stale reads and tells the model to re-read them
+func (a *Agent) handleExternalFileChanges() {
+	changed := a.fileWatcher.CheckChanges()
+	if len(changed) == 0 {
+		return
+	}
+
+	for _, path := range changed {
+		a.optimizer.InvalidateFile(path, len(a.messages))
+		fmt.Printf("👀 %s changed on disk since it was last read\n", path)
+	}
+
+	a.messages = append(a.messages, api.Message{
+		Role: "user",
+		Content: fmt.Sprintf("NOTE: The following files changed on disk after you read them (edited outside this session or by a shell command): %s. "+
+			"Your earlier view of them is out of date - read them again before editing.", strings.Join(changed, ", ")),
+	})
+}
+
+// editConflict refuses an edit to a file whose content changed since the agent
+// last read or wrote it outside the edit tools, whether by the user or by one
+// of the agent's own shell commands (a formatter, codegen), so that change is
+// never overwritten. The returned error is fed back to the model.
+func (a *Agent) editConflict(path string) error {
+	readHash, currentHash, conflict := a.fileWatcher.Conflict(path)
+	if !conflict {
+		return nil
+	}
+	a.optimizer.InvalidateFile(filepath.Clean(path), len(a.messages))
+	fmt.Printf("⚠️  Edit conflict: %s changed on disk since it was read - edit refused\n", path)
+	return fmt.Errorf("EDIT CONFLICT: %s changed on disk since you last read it (content hash %s, now %s), outside the edit tools - "+
+		"by the user or by a shell command such as a formatter or code generator. "+
+		"Your edit was NOT applied. Read the file again and reapply your change to its current content - do not overwrite the other change",
+		path, shortHash(readHash), shortHash(currentHash))
+}
+
+// shortHash abbreviates a content hash for messages
+func shortHash(hash string) string {
+	if hash == "" {
+		return "none (deleted)"
+	}
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// TestFileWatcherDetectsChanges tests that external modifications mark files stale
+func TestFileWatcherDetectsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched.txt")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	watcher := NewFileWatcher()
+	watcher.Track(path)
+
+	if changed := watcher.CheckChanges(); len(changed) != 0 {
+		t.Fatalf("Expected no changes, got %v", changed)
+	}
+
+	// Simulate an edit in the user's editor
+	if err := os.WriteFile(path, []byte("edited elsewhere"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	future := time.Now().Add(2 * time.Second)
+	os.Chtimes(path, future, future)
+
+	if !watcher.IsStale(path) {
+		t.Error("Expected file to be stale after external modification")
+	}
+	changed := watcher.CheckChanges()
+	if len(changed) != 1 || changed[0] != path {
+		t.Fatalf("Expected %s to be reported, got %v", path, changed)
+	}
+
+	// Each change is only reported once
+	if changed := watcher.CheckChanges(); len(changed) != 0 {
+		t.Errorf("Expected change to be reported once, got %v", changed)
+	}
+
+	// Re-reading the file clears the stale flag
+	watcher.Track(path)
+	if watcher.IsStale(path) {
+		t.Error("Expected file to be fresh after re-tracking")
+	}
+}
+
+// TestFileWatcherComparesContent tests that hashes catch edits stat misses and ignore touches
+func TestFileWatcherComparesContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched.txt")
+	os.WriteFile(path, []byte("original"), 0644)
+	info, _ := os.Stat(path)
+
+	watcher := NewFileWatcher()
+	watcher.Track(path)
+
+	// Touching the file without changing it isn't a change
+	future := time.Now().Add(2 * time.Second)
+	os.Chtimes(path, future, future)
+	if changed := watcher.CheckChanges(); len(changed) != 0 || watcher.IsStale(path) {
+		t.Errorf("Expected a touch to be ignored, got %v", changed)
+	}
+
+	// A same-size edit with the same modification time is still a conflict
+	os.WriteFile(path, []byte("ORIGINAL"), 0644)
+	os.Chtimes(path, future, future)
+	readHash, currentHash, conflict := watcher.Conflict(path)
+	if !conflict || readHash == currentHash {
+		t.Errorf("Expected a content conflict, got %s vs %s", readHash, currentHash)
+	}
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+
+	if _, _, conflict := NewFileWatcher().Conflict(path); conflict {
+		t.Error("Files that were never read can't conflict")
+	}
+}
+
+// TestEditConflict tests that edits to files changed since they were read are refused
+func TestEditConflict(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	path := "notes.txt"
+	os.WriteFile(path, []byte("alpha\nbeta\n"), 0644)
+	agent.fileWatcher.Track(path)
+
+	// The user edits the file after the agent read it
+	os.WriteFile(path, []byte("alpha\nbeta\ngamma\n"), 0644)
+
+	call := api.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "edit_file"
+	call.Function.Arguments = `{"file_path": "` + path + `", "old_string": "beta", "new_string": "BETA"}`
+	if _, err := agent.executeTool(call); err == nil || !strings.Contains(err.Error(), "EDIT CONFLICT") {
+		t.Fatalf("Expected an edit conflict, got %v", err)
+	} else if !strings.Contains(err.Error(), "shell command") || !strings.Contains(err.Error(), "Read the file again") {
+		t.Errorf("Expected the conflict to allow for the agent's own commands and ask for a re-read, got %v", err)
+	}
+	call.Function.Name = "write_file"
+	call.Function.Arguments = `{"file_path": "` + path + `", "content": "replaced\n"}`
+	if _, err := agent.executeTool(call); err == nil || !strings.Contains(err.Error(), "EDIT CONFLICT") {
+		t.Fatalf("Expected a write conflict, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "alpha\nbeta\ngamma\n" {
+		t.Errorf("The user's edit was overwritten: %q", data)
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+const geminiStream = `data: {"candidates": [{"content": {"role": "model", "parts": [{"text": "Reading "}]}}], "usageMetadata": {"promptTokenCount": 10}}
+
+data: {"candidates": [{"content": {"role": "model", "parts": [{"text": "the file."}]}}], "usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 4}}
+
+data: {"candidates": [{"content": {"role": "model", "parts": [{"functionCall": {"name": "read_file", "args": {"file_path": "main.go"}}}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 20, "thoughtsTokenCount": 5}}
+`
+
+func TestGeminiClient(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/models":
+			w.Write([]byte(`{"models": [
+				{"name": "models/gemini-2.5-pro", "displayName": "Gemini 2.5 Pro", "inputTokenLimit": 1048576, "supportedGenerationMethods": ["generateContent", "countTokens"]},
+				{"name": "models/text-embedding-004", "displayName": "Text Embedding 004", "inputTokenLimit": 2048, "supportedGenerationMethods": ["embedContent"]},
+				{"name": "models/gemini-2.5-flash", "displayName": "Gemini 2.5 Flash", "inputTokenLimit": 1048576, "supportedGenerationMethods": ["generateContent"]}]}`))
+		case "/models/gemini-2.5-flash:generateContent":
+			requestBody = nil
+			json.NewDecoder(r.Body).Decode(&requestBody)
+			w.Write([]byte(`{"responseId": "resp_1", "candidates": [{"content": {"role": "model", "parts": [{"text": "Let me look."}, {"functionCall": {"name": "read_file", "args": {"file_path": "go.mod"}}}]}, "finishReason": "STOP"}],
+				"usageMetadata": {"promptTokenCount": 2000000, "candidatesTokenCount": 500000, "thoughtsTokenCount": 500000, "cachedContentTokenCount": 1000000}}`))
+		case "/models/gemini-2.5-flash:streamGenerateContent":
+			if r.URL.Query().Get("alt") != "sse" {
+				http.Error(w, "expected alt=sse", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(geminiStream))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("GOOGLE_API_KEY", "")
+	t.Setenv("GEMINI_BASE_URL", server.URL+"/")
+
+	client, err := api.NewUnifiedClientWithModel(api.GeminiClientType, "")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	if client.GetModel() != "gemini-2.5-flash" || client.GetProvider() != "gemini" || !client.SupportsVision() {
+		t.Errorf("unexpected model %s or provider %s", client.GetModel(), client.GetProvider())
+	}
+
+	messages := []api.Message{
+		{Role: "system", Content: "You are a coding agent."},
+		{Role: "user", Content: "read go.mod"},
+		{Role: "user", Content: "then summarize it"},
+	}
+	resp, err := client.SendChatRequest(context.Background(), messages, api.GetToolDefinitions(), "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	message := resp.Choices[0].Message
+	if message.Content != "Let me look." || len(message.ToolCalls) != 1 || message.ToolCalls[0].ID == "" ||
+		message.ToolCalls[0].Function.Name != "read_file" || message.ToolCalls[0].Function.Arguments != `{"file_path": "go.mod"}` {
+		t.Errorf("unexpected message %+v", message)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("expected a function call to finish as tool_calls, got %q", resp.Choices[0].FinishReason)
+	}
+	// A million fresh input tokens at $0.30, a million cached at a quarter of
+	// that, and a million output (thinking included) at $2.50
+	if resp.Usage.CompletionTokens != 1000000 || resp.Usage.PromptTokensDetails.CachedTokens != 1000000 || math.Abs(resp.Usage.EstimatedCost-(0.3+0.075+2.5)) > 1e-9 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+
+	// System messages become the system instruction, same-role turns merge,
+	// and tools are declared as functions with schemas Gemini accepts
+	contents, _ := requestBody["contents"].([]interface{})
+	tools, _ := requestBody["tools"].([]interface{})
+	if requestBody["systemInstruction"] == nil || len(contents) != 1 || len(tools) != 1 {
+		t.Fatalf("unexpected request %v", requestBody)
+	}
+	if parts := contents[0].(map[string]interface{})["parts"].([]interface{}); len(parts) != 2 {
+		t.Errorf("expected the user turns to merge, got %v", parts)
+	}
+	declarations, _ := tools[0].(map[string]interface{})["functionDeclarations"].([]interface{})
+	if len(declarations) != len(api.GetToolDefinitions()) {
+		t.Errorf("expected every tool declared, got %d", len(declarations))
+	}
+	declared, _ := json.Marshal(declarations)
+	if strings.Contains(string(declared), "additionalProperties") {
+		t.Errorf("expected unsupported schema keywords to be dropped, got %s", declared)
+	}
+
+	// Streamed responses pass text through and collect the function call
+	var streamed strings.Builder
+	resp, err = client.SendChatRequestStream(context.Background(), messages, api.GetToolDefinitions(), "", func(content, reasoning string) {
+		streamed.WriteString(content)
+	})
+	if err != nil {
+		t.Fatalf("SendChatRequestStream: %v", err)
+	}
+	message = resp.Choices[0].Message
+	if streamed.String() != "Reading the file." || message.Content != "Reading the file." {
+		t.Errorf("streamed %q, assembled %q", streamed.String(), message.Content)
+	}
+	if len(message.ToolCalls) != 1 || message.ToolCalls[0].Function.Arguments != `{"file_path": "main.go"}` {
+		t.Errorf("unexpected streamed tool calls %+v", message.ToolCalls)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 25 || resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("unexpected streamed usage %+v", resp.Usage)
+	}
+
+	models, err := api.GetAvailableModels(api.GeminiClientType)
+	if err != nil || len(models) != 2 || models[0].ID != "gemini-2.5-pro" || models[0].InputCost != 1.25 || models[1].ContextLength != 1048576 {
+		t.Errorf("expected Gemini's chat models with prices, got %+v (%v)", models, err)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "")
+	if _, err := api.NewUnifiedClientWithModel(api.GeminiClientType, ""); err == nil {
+		t.Error("expected an error without GEMINI_API_KEY")
+	}
+}
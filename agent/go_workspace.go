@@ -0,0 +1,381 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// GoModule is one Go module in the repository
+type GoModule struct {
+	Dir  string // relative to the repository root, "." for the root
+	Path string // module path from go.mod
+}
+
+// GoWorkspace describes the Go modules of a repository with more than one,
+// either listed in a go.work file or found as nested go.mod files
+type GoWorkspace struct {
+	Root     string
+	WorkFile bool // modules come from go.work, so go commands work from the root
+	Modules  []GoModule
+}
+
+// goCommandsByModule are the go subcommands run in each module they target
+var goCommandsByModule = map[string]bool{"build": true, "test": true, "vet": true, "list": true}
+
+// goValueFlags are the go build/test flags followed by a separate value
+var goValueFlags = map[string]bool{
+	"-o": true, "-p": true, "-run": true, "-skip": true, "-bench": true, "-benchtime": true, "-count": true,
+	"-cpu": true, "-parallel": true, "-timeout": true, "-tags": true, "-ldflags": true, "-gcflags": true,
+	"-asmflags": true, "-mod": true, "-modfile": true, "-coverprofile": true, "-covermode": true,
+	"-coverpkg": true, "-exec": true, "-f": true, "-vettool": true, "-memprofile": true, "-cpuprofile": true,
+}
+
+// DetectGoWorkspace finds the Go modules under root. files lists the
+// repository's files when they are already known; otherwise they are listed.
+// It returns nil for repositories with at most one module and no go.work.
+func DetectGoWorkspace(root string, files []string) (*GoWorkspace, error) {
+	workspace := &GoWorkspace{Root: root}
+	if dirs, err := parseGoWork(filepath.Join(root, "go.work")); err == nil {
+		workspace.WorkFile = true
+		for _, dir := range dirs {
+			workspace.addModule(dir)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else {
+		if files == nil {
+			if files, err = listWorkspaceFiles(root); err != nil {
+				return nil, err
+			}
+		}
+		for _, rel := range files {
+			rel = filepath.ToSlash(rel)
+			if path.Base(rel) != "go.mod" || strings.Contains("/"+rel, "/vendor/") || strings.Contains("/"+rel, "/testdata/") {
+				continue
+			}
+			workspace.addModule(path.Dir(rel))
+		}
+	}
+
+	if len(workspace.Modules) < 2 && !workspace.WorkFile {
+		return nil, nil
+	}
+	sort.Slice(workspace.Modules, func(i, j int) bool { return workspace.Modules[i].Dir < workspace.Modules[j].Dir })
+	return workspace, nil
+}
+
+// addModule records the module in dir, reading its path from go.mod
+func (w *GoWorkspace) addModule(dir string) {
+	dir = path.Clean(filepath.ToSlash(dir))
+	module := GoModule{Dir: dir}
+	if file, err := os.Open(filepath.Join(w.Root, filepath.FromSlash(dir), "go.mod")); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[0] == "module" {
+				module.Path = strings.Trim(fields[1], `"`)
+				break
+			}
+		}
+		file.Close()
+	}
+	w.Modules = append(w.Modules, module)
+}
+
+// parseGoWork returns the module directories a go.work file uses
+func parseGoWork(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	inUse := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inUse && fields[0] == ")":
+			inUse = false
+		case inUse:
+			dirs = append(dirs, strings.Trim(fields[0], `"`))
+		case fields[0] == "use" && len(fields) >= 2 && fields[1] == "(":
+			inUse = true
+		case fields[0] == "use" && len(fields) >= 2:
+			dirs = append(dirs, strings.Trim(fields[1], `"`))
+		}
+	}
+	return dirs, nil
+}
+
+// ModuleOf returns the module containing dir (relative to the root), or nil
+func (w *GoWorkspace) ModuleOf(dir string) *GoModule {
+	dir = path.Clean(filepath.ToSlash(dir))
+	var best *GoModule
+	for i := range w.Modules {
+		module := &w.Modules[i]
+		if module.Dir == "." || dir == module.Dir || strings.HasPrefix(dir, module.Dir+"/") {
+			if best == nil || len(module.Dir) > len(best.Dir) || best.Dir == "." {
+				best = module
+			}
+		}
+	}
+	return best
+}
+
+// ModuleOfImport returns the module providing an import path, or nil
+func (w *GoWorkspace) ModuleOfImport(importPath string) *GoModule {
+	var best *GoModule
+	for i := range w.Modules {
+		module := &w.Modules[i]
+		if module.Path != "" && (importPath == module.Path || strings.HasPrefix(importPath, module.Path+"/")) {
+			if best == nil || len(module.Path) > len(best.Path) {
+				best = module
+			}
+		}
+	}
+	return best
+}
+
+// Describe lists the modules for the model and for /init
+func (w *GoWorkspace) Describe() string {
+	var b strings.Builder
+	if w.WorkFile {
+		fmt.Fprintf(&b, "This repository is a Go workspace: go.work uses %d modules, so go commands work from the root.\n", len(w.Modules))
+	} else {
+		fmt.Fprintf(&b, "This repository has %d Go modules and no go.work: run go commands inside the module directory, e.g. `cd <dir> && go build ./...`.\n", len(w.Modules))
+	}
+	for _, module := range w.Modules {
+		name := module.Path
+		if name == "" {
+			name = "(no go.mod)"
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", module.Dir, name)
+	}
+	b.WriteString("Imports of these module paths refer to the directories above, not to downloaded modules.")
+	return b.String()
+}
+
+// goWorkspaceOnce detects the repository's Go modules on the first task
+func (a *Agent) goWorkspaceOnce() *GoWorkspace {
+	if a.goWorkspaceChecked {
+		return a.goWorkspace
+	}
+	a.goWorkspaceChecked = true
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	if a.workspaceSnapshot != nil && a.workspaceSnapshot.Root == wd {
+		files = make([]string, 0, len(a.workspaceSnapshot.Files))
+		for rel := range a.workspaceSnapshot.Files {
+			files = append(files, rel)
+		}
+	}
+	workspace, err := DetectGoWorkspace(wd, files)
+	if err != nil {
+		a.debugLog("⚠️ Failed to detect Go modules: %v\n", err)
+		return nil
+	}
+	if workspace != nil {
+		a.debugLog("📦 Go workspace: %d modules (go.work: %v)\n", len(workspace.Modules), workspace.WorkFile)
+	}
+	a.goWorkspace = workspace
+	return workspace
+}
+
+// goWorkspaceForPrompt formats the module layout for the system prompt
+func (a *Agent) goWorkspaceForPrompt() string {
+	workspace := a.goWorkspaceOnce()
+	if workspace == nil {
+		return ""
+	}
+	return "\n\nGO MODULES:\n" + workspace.Describe()
+}
+
+// splitGoCommand groups the packages of a go build/test/vet/list command run
+// at the root by the module they belong to, with each pattern made relative to
+// its module. It returns nil when the command doesn't need splitting.
+func (w *GoWorkspace) splitGoCommand(command string) map[*GoModule][]string {
+	if w.WorkFile || strings.ContainsAny(command, ";&|><`$()") {
+		return nil
+	}
+	fields := strings.Fields(command)
+	if len(fields) < 2 || fields[0] != "go" || !goCommandsByModule[fields[1]] {
+		return nil
+	}
+
+	var patterns []string
+	for i := 2; i < len(fields); i++ {
+		field := fields[i]
+		if strings.HasPrefix(field, "-") {
+			if goValueFlags[field] {
+				i++
+			}
+			continue
+		}
+		if fields[1] == "test" && field == "--" {
+			break // test binary arguments follow
+		}
+		patterns = append(patterns, field)
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	targets := make(map[*GoModule][]string)
+	add := func(module *GoModule, pattern string) {
+		for _, existing := range targets[module] {
+			if existing == pattern {
+				return
+			}
+		}
+		targets[module] = append(targets[module], pattern)
+	}
+	for _, pattern := range patterns {
+		recursive := pattern == "..." || strings.HasSuffix(pattern, "/...")
+		base := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+		if base == "" {
+			base = "."
+		}
+		if !strings.HasPrefix(base, ".") {
+			module := w.ModuleOfImport(base)
+			if module == nil {
+				return nil // std or a dependency: leave the command alone
+			}
+			base = path.Join(module.Dir, strings.TrimPrefix(base, module.Path))
+		}
+		base = path.Clean(base)
+
+		if recursive {
+			// dir/... also spans the modules nested below dir
+			for i := range w.Modules {
+				module := &w.Modules[i]
+				if module.Dir != base && (base == "." || strings.HasPrefix(module.Dir, base+"/")) {
+					add(module, "./...")
+				}
+			}
+		}
+		module := w.ModuleOf(base)
+		if module == nil {
+			if recursive {
+				continue
+			}
+			return nil
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(base, module.Dir), "/")
+		if module.Dir == "." {
+			rel = base
+		}
+		pattern := "."
+		if rel != "" && rel != "." {
+			pattern = "./" + rel
+		}
+		if recursive {
+			pattern += "/..."
+		}
+		add(module, pattern)
+	}
+
+	if len(targets) == 1 {
+		for module := range targets {
+			if module.Dir == "." {
+				return nil // already runs in the right module
+			}
+		}
+	}
+	return targets
+}
+
+// runGoCommandByModule runs a go command in each module it targets when the
+// repository has several modules and no go.work. It returns false when the
+// command should run as is.
+func (a *Agent) runGoCommandByModule(command string) (string, bool, error) {
+	workspace := a.goWorkspaceOnce()
+	if workspace == nil {
+		return "", false, nil
+	}
+	targets := workspace.splitGoCommand(command)
+	if len(targets) == 0 {
+		return "", false, nil
+	}
+
+	fields := strings.Fields(command)
+	var flags []string
+	for i := 2; i < len(fields); i++ {
+		if fields[i] == "--" {
+			flags = append(flags, fields[i:]...)
+			break
+		}
+		if strings.HasPrefix(fields[i], "-") {
+			flags = append(flags, fields[i])
+			if goValueFlags[fields[i]] && i+1 < len(fields) {
+				i++
+				flags = append(flags, fields[i])
+			}
+		}
+	}
+
+	modules := make([]*GoModule, 0, len(targets))
+	for module := range targets {
+		modules = append(modules, module)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Dir < modules[j].Dir })
+
+	var output strings.Builder
+	var failed []string
+	for _, module := range modules {
+		args := append([]string{"go", fields[1]}, flags...)
+		args = append(args, targets[module]...)
+		moduleCommand := fmt.Sprintf("cd %s && %s", tools.ShellQuote(module.Dir), strings.Join(args, " "))
+		a.debugLog("📦 Running in module %s: %s\n", module.Dir, moduleCommand)
+
+		result, err := a.runShellCommand(moduleCommand)
+		fmt.Fprintf(&output, "=== module %s (%s): %s ===\n%s", module.Dir, module.Path, strings.Join(args, " "), result)
+		if result != "" && !strings.HasSuffix(result, "\n") {
+			output.WriteString("\n")
+		}
+		if err != nil {
+			if result == "" {
+				fmt.Fprintf(&output, "%v\n", err)
+			}
+			failed = append(failed, module.Dir)
+		}
+	}
+	if len(failed) > 0 {
+		return output.String(), true, fmt.Errorf("go %s failed in module %s:\n%s", fields[1], strings.Join(failed, ", "), output.String())
+	}
+	return output.String(), true, nil
+}
+
+// goModuleErrorHint explains a go command failure caused by running it
+// outside the right module
+func (a *Agent) goModuleErrorHint(command, output string) string {
+	if !strings.Contains(command, "go ") {
+		return ""
+	}
+	markers := []string{"cannot find main module", "does not contain main module", "main module (", "is not in std",
+		"no required module provides package", "go.work"}
+	found := false
+	for _, marker := range markers {
+		if strings.Contains(output, marker) {
+			found = true
+			break
+		}
+	}
+	workspace := a.goWorkspaceOnce()
+	if !found || workspace == nil {
+		return ""
+	}
+	return "\n\n[GO WORKSPACE] " + workspace.Describe()
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeGoModule creates a module with one package in dir under root
+func writeGoModule(t *testing.T, root, dir, modulePath string) {
+	t.Helper()
+	full := filepath.Join(root, dir)
+	if err := os.MkdirAll(full, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	os.WriteFile(filepath.Join(full, "go.mod"), []byte("module "+modulePath+"\n\ngo 1.24\n"), 0644)
+	os.WriteFile(filepath.Join(full, "lib.go"), []byte("package "+filepath.Base(modulePath)+"\n"), 0644)
+}
+
+// TestDetectGoWorkspaceFromGoWork tests reading the modules a go.work uses
+func TestDetectGoWorkspaceFromGoWork(t *testing.T) {
+	root := t.TempDir()
+	writeGoModule(t, root, "services/api", "example.com/api")
+	writeGoModule(t, root, "libs/shared", "example.com/shared")
+	os.WriteFile(filepath.Join(root, "go.work"), []byte("go 1.24\n\nuse (\n\t./services/api // the server\n\t./libs/shared\n)\n"), 0644)
+
+	workspace, err := DetectGoWorkspace(root, nil)
+	if err != nil || workspace == nil {
+		t.Fatalf("Expected a workspace, got %v (%v)", workspace, err)
+	}
+	if !workspace.WorkFile {
+		t.Error("Expected the workspace to come from go.work")
+	}
+	expected := []GoModule{{Dir: "libs/shared", Path: "example.com/shared"}, {Dir: "services/api", Path: "example.com/api"}}
+	if !reflect.DeepEqual(workspace.Modules, expected) {
+		t.Errorf("Expected modules %+v, got %+v", expected, workspace.Modules)
+	}
+	if module := workspace.ModuleOfImport("example.com/shared/util"); module == nil || module.Dir != "libs/shared" {
+		t.Errorf("Expected the import to resolve to libs/shared, got %+v", module)
+	}
+	if module := workspace.ModuleOf("services/api/handlers"); module == nil || module.Dir != "services/api" {
+		t.Errorf("Expected services/api/handlers to be in services/api, got %+v", module)
+	}
+}
+
+// TestDetectGoWorkspaceNestedModules tests finding nested go.mod files without a go.work
+func TestDetectGoWorkspaceNestedModules(t *testing.T) {
+	root := t.TempDir()
+	writeGoModule(t, root, ".", "example.com/root")
+	writeGoModule(t, root, "tools", "example.com/tools")
+	writeGoModule(t, root, "vendor/example.com/dep", "example.com/dep")
+
+	workspace, err := DetectGoWorkspace(root, nil)
+	if err != nil || workspace == nil {
+		t.Fatalf("Expected a workspace, got %v (%v)", workspace, err)
+	}
+	if workspace.WorkFile || len(workspace.Modules) != 2 {
+		t.Errorf("Expected two modules without go.work, got %+v", workspace)
+	}
+
+	single := t.TempDir()
+	writeGoModule(t, single, ".", "example.com/single")
+	if workspace, _ := DetectGoWorkspace(single, nil); workspace != nil {
+		t.Errorf("Expected no workspace for a single module, got %+v", workspace)
+	}
+}
+
+// TestSplitGoCommand tests mapping root-level go commands onto modules
+func TestSplitGoCommand(t *testing.T) {
+	workspace := &GoWorkspace{Modules: []GoModule{
+		{Dir: ".", Path: "example.com/root"},
+		{Dir: "services/api", Path: "example.com/api"},
+		{Dir: "tools", Path: "example.com/tools"},
+	}}
+	split := func(command string) map[string][]string {
+		result := make(map[string][]string)
+		for module, patterns := range workspace.splitGoCommand(command) {
+			result[module.Dir] = patterns
+		}
+		return result
+	}
+
+	tests := []struct {
+		command  string
+		expected map[string][]string
+	}{
+		{"go build ./...", map[string][]string{".": {"./..."}, "services/api": {"./..."}, "tools": {"./..."}}},
+		{"go test -run TestX ./services/api/handlers", map[string][]string{"services/api": {"./handlers"}}},
+		{"go vet ./services/...", map[string][]string{".": {"./services/..."}, "services/api": {"./..."}}},
+		{"go test example.com/tools/lint/...", map[string][]string{"tools": {"./lint/..."}}},
+		{"go build ./cmd/server", map[string][]string{}},
+		{"go test ./... | tail", map[string][]string{}},
+		{"go test fmt", map[string][]string{}},
+		{"go mod tidy", map[string][]string{}},
+	}
+	for _, tt := range tests {
+		if got := split(tt.command); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("splitGoCommand(%q) = %v, expected %v", tt.command, got, tt.expected)
+		}
+	}
+
+	workspace.WorkFile = true
+	if got := split("go build ./..."); len(got) != 0 {
+		t.Errorf("Expected go.work workspaces to run commands as is, got %v", got)
+	}
+}
+
+// TestRunGoCommandByModule tests running go list in each module of a repo without go.work
+func TestRunGoCommandByModule(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	defer os.Unsetenv("OPENROUTER_API_KEY")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	root := t.TempDir()
+	writeGoModule(t, root, "api", "example.com/api")
+	writeGoModule(t, root, "shared", "example.com/shared")
+	t.Chdir(root)
+
+	output, byModule, err := agent.runGoCommandByModule("go list ./...")
+	if !byModule {
+		t.Fatal("Expected go list to run per module")
+	}
+	if err != nil {
+		t.Fatalf("Expected go list to succeed, got %v", err)
+	}
+	for _, pkg := range []string{"example.com/api", "example.com/shared"} {
+		if !strings.Contains(output, pkg) {
+			t.Errorf("Expected %s in output, got: %s", pkg, output)
+		}
+	}
+
+	if hint := agent.goModuleErrorHint("go build ./...", "go: cannot find main module"); !strings.Contains(hint, "api: example.com/api") {
+		t.Errorf("Expected a hint listing the modules, got %q", hint)
+	}
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// openAICompatibleServer answers chat completions with a read_file tool call
+// and the given usage, and lists models
+func openAICompatibleServer(t *testing.T, usage string, models string, requestBody *map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/models":
+			w.Write([]byte(models))
+		case "/chat/completions":
+			json.NewDecoder(r.Body).Decode(requestBody)
+			w.Write([]byte(`{"id": "chat-1", "choices": [{"index": 0, "finish_reason": "tool_calls", "message": {"role": "assistant", "content": "",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "read_file", "arguments": "{\"file_path\": \"go.mod\"}"}}]}}],
+				"usage": ` + usage + `}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGroqAndDeepSeekClients(t *testing.T) {
+	var groqRequest, deepSeekRequest map[string]interface{}
+	groq := openAICompatibleServer(t,
+		`{"prompt_tokens": 1000000, "completion_tokens": 1000000, "total_tokens": 2000000}`,
+		`{"data": [{"id": "llama-3.3-70b-versatile", "owned_by": "Meta", "active": true, "context_window": 131072},
+			{"id": "whisper-large-v3", "owned_by": "OpenAI", "active": true, "context_window": 448},
+			{"id": "qwen/qwen3-32b", "owned_by": "Alibaba Cloud", "active": true, "context_window": 40960}]}`,
+		&groqRequest)
+	deepSeek := openAICompatibleServer(t,
+		`{"prompt_tokens": 1000000, "completion_tokens": 0, "total_tokens": 1000000, "prompt_cache_hit_tokens": 500000, "prompt_cache_miss_tokens": 500000}`,
+		`{"object": "list", "data": [{"id": "deepseek-chat", "object": "model"}, {"id": "deepseek-reasoner", "object": "model"}]}`,
+		&deepSeekRequest)
+	t.Setenv("GROQ_API_KEY", "test-key")
+	t.Setenv("GROQ_BASE_URL", groq.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+	t.Setenv("DEEPSEEK_BASE_URL", deepSeek.URL+"/")
+
+	for _, tc := range []struct {
+		provider api.ClientType
+		model    string
+		request  *map[string]interface{}
+		cost     float64
+		cached   int
+	}{
+		{api.GroqClientType, "llama-3.3-70b-versatile", &groqRequest, 0.59 + 0.79, 0},
+		{api.DeepSeekClientType, "deepseek-chat", &deepSeekRequest, 0.5*0.28 + 0.5*0.028, 500000},
+	} {
+		client, err := api.NewUnifiedClientWithModel(tc.provider, "")
+		if err != nil {
+			t.Fatalf("%s: NewUnifiedClientWithModel: %v", tc.provider, err)
+		}
+		if client.GetModel() != tc.model || client.GetProvider() != string(tc.provider) {
+			t.Errorf("%s: unexpected model %s or provider %s", tc.provider, client.GetModel(), client.GetProvider())
+		}
+		if err := client.CheckConnection(); err != nil {
+			t.Errorf("%s: CheckConnection: %v", tc.provider, err)
+		}
+
+		resp, err := client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "read go.mod"}}, api.GetToolDefinitions(), "")
+		if err != nil {
+			t.Fatalf("%s: SendChatRequest: %v", tc.provider, err)
+		}
+		calls := resp.Choices[0].Message.ToolCalls
+		if len(calls) != 1 || calls[0].Function.Name != "read_file" || calls[0].Function.Arguments != `{"file_path": "go.mod"}` {
+			t.Errorf("%s: unexpected tool calls %+v", tc.provider, calls)
+		}
+		if math.Abs(resp.Usage.EstimatedCost-tc.cost) > 1e-9 || resp.Usage.PromptTokensDetails.CachedTokens != tc.cached {
+			t.Errorf("%s: expected cost %v with %d cached tokens, got %+v", tc.provider, tc.cost, tc.cached, resp.Usage)
+		}
+		if (*tc.request)["model"] != tc.model || (*tc.request)["tools"] == nil {
+			t.Errorf("%s: unexpected request %v", tc.provider, *tc.request)
+		}
+	}
+
+	models, err := api.GetAvailableModels(api.GroqClientType)
+	if err != nil || len(models) != 2 || models[1].ID != "qwen/qwen3-32b" || models[1].ContextLength != 40960 || models[0].InputCost != 0.59 {
+		t.Errorf("expected Groq's chat models with prices, got %+v (%v)", models, err)
+	}
+	models, err = api.GetAvailableModels(api.DeepSeekClientType)
+	if err != nil || len(models) != 2 || models[1].ID != "deepseek-reasoner" {
+		t.Errorf("expected DeepSeek's models, got %+v (%v)", models, err)
+	}
+
+	t.Setenv("GROQ_API_KEY", "")
+	if _, err := api.NewUnifiedClientWithModel(api.GroqClientType, ""); err == nil {
+		t.Error("expected an error without GROQ_API_KEY")
+	}
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/providers"
+)
+
+func TestHuggingFaceEndpoint(t *testing.T) {
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/info":
+			json.NewEncoder(w).Encode(map[string]interface{}{"model_id": "acme/coder-ft", "max_input_tokens": 16384})
+		case "/v1/chat/completions":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"model":   "tgi",
+				"choices": []map[string]interface{}{{"message": map[string]interface{}{"role": "assistant", "content": "hello"}, "finish_reason": "stop"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("HF_ENDPOINT_URL", server.URL+"/")
+	t.Setenv("HF_ENDPOINTS", "reviewer="+server.URL)
+	t.Setenv("HF_TOKEN", "hf_test")
+
+	client, err := api.NewUnifiedClientWithModel(api.HuggingFaceClientType, "")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	if err := client.CheckConnection(); err != nil {
+		t.Fatalf("CheckConnection: %v", err)
+	}
+	if limit, _ := client.GetModelContextLimit(); limit != 16384 {
+		t.Errorf("expected the endpoint's max input tokens, got %d", limit)
+	}
+
+	resp, err := client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "hi"}}, nil, "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "hello" || authHeader != "Bearer hf_test" {
+		t.Errorf("unexpected response %q or auth header %q", resp.Choices[0].Message.Content, authHeader)
+	}
+
+	models, err := api.GetAvailableModels(api.HuggingFaceClientType)
+	if err != nil || len(models) != 2 || models[0].ID != "tgi" || models[1].ID != "reviewer" {
+		t.Errorf("expected the default and named endpoints, got %v (%v)", models, err)
+	}
+}
+
+func TestParseHuggingFaceEndpoints(t *testing.T) {
+	endpoints, err := providers.ParseHuggingFaceEndpoints("a=https://a.example/, b = http://b.local:8080")
+	if err != nil || endpoints["a"] != "https://a.example" || endpoints["b"] != "http://b.local:8080" {
+		t.Errorf("unexpected endpoints %v (%v)", endpoints, err)
+	}
+	if _, err := providers.ParseHuggingFaceEndpoints("missing-url"); err == nil {
+		t.Error("expected an error for an entry without a URL")
+	}
+}
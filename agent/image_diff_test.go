@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func writeTestPNG(t *testing.T, path string, paint func(x, y int) color.Color) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, paint(x, y))
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatalf("failed to encode %s: %v", path, err)
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	dir := t.TempDir()
+	white := func(x, y int) color.Color { return color.White }
+	expected := filepath.Join(dir, "mockup.png")
+	actual := filepath.Join(dir, "screenshot.png")
+	writeTestPNG(t, expected, white)
+	// The screenshot has a black 16x16 square the mockup lacks
+	writeTestPNG(t, actual, func(x, y int) color.Color {
+		if x >= 40 && x < 56 && y >= 8 && y < 24 {
+			return color.Black
+		}
+		return color.White
+	})
+
+	result, err := tools.CompareImages(actual, expected, "", 0)
+	if err != nil {
+		t.Fatalf("CompareImages failed: %v", err)
+	}
+	if result.ChangedPixels != 256 {
+		t.Errorf("expected 256 changed pixels, got %d", result.ChangedPixels)
+	}
+	if len(result.Regions) != 1 || !result.Regions[0].Overlaps(image.Rect(40, 8, 56, 24)) {
+		t.Errorf("expected one region around the square, got %v", result.Regions)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "screenshot_diff.png")); err != nil {
+		t.Errorf("expected overlay to be written: %v", err)
+	}
+
+	same, err := tools.CompareImages(expected, expected, filepath.Join(dir, "same.png"), 0)
+	if err != nil {
+		t.Fatalf("CompareImages failed: %v", err)
+	}
+	if same.ChangedPixels != 0 || same.PerceptualSimilar != 1 {
+		t.Errorf("expected identical images to match, got %d changed, similarity %.2f", same.ChangedPixels, same.PerceptualSimilar)
+	}
+}
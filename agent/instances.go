@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+const (
+	instancesDirName   = "instances"
+	instanceStaleAfter = 30 * time.Minute // an instance silent this long has exited or crashed
+)
+
+// InstanceRecord is what a running coder instance publishes about itself in
+// the project directory, so other instances in the same repository can see it
+type InstanceRecord struct {
+	PID       int                  `json:"pid"`
+	SessionID string               `json:"session_id"`
+	Started   time.Time            `json:"started"`
+	Heartbeat time.Time            `json:"heartbeat"`
+	Edits     map[string]time.Time `json:"edits"` // files written, by path
+}
+
+// InstanceRegistry tracks the coder instances working in one repository
+type InstanceRegistry struct {
+	root string
+	dir  string
+	self InstanceRecord
+}
+
+// NewInstanceRegistry returns the registry in the project directory under
+// root. Nothing is written until this instance edits a file.
+func NewInstanceRegistry(root, sessionID string) *InstanceRegistry {
+	stateDir, err := config.ProjectStateDir(root)
+	if err != nil {
+		stateDir = filepath.Join(root, config.ProjectDirName)
+	}
+	return &InstanceRegistry{
+		root: root,
+		dir:  filepath.Join(stateDir, instancesDirName),
+		self: InstanceRecord{
+			PID:       os.Getpid(),
+			SessionID: sessionID,
+			Started:   time.Now(),
+			Edits:     make(map[string]time.Time),
+		},
+	}
+}
+
+// Others returns the other live instances in the repository
+func (r *InstanceRegistry) Others() []InstanceRecord {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+	var others []InstanceRecord
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") || entry.Name() == r.fileName() {
+			continue
+		}
+		path := filepath.Join(r.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var record InstanceRecord
+		if json.Unmarshal(data, &record) != nil || time.Since(record.Heartbeat) > instanceStaleAfter {
+			os.Remove(path) // left behind by an instance that crashed
+			continue
+		}
+		others = append(others, record)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Started.Before(others[j].Started) })
+	return others
+}
+
+// EditedByOthers returns the live instances that edited path while they were running
+func (r *InstanceRegistry) EditedByOthers(path string) []InstanceRecord {
+	var editors []InstanceRecord
+	for _, other := range r.Others() {
+		if _, ok := other.Edits[instanceKey(path)]; ok {
+			editors = append(editors, other)
+		}
+	}
+	return editors
+}
+
+// RecordEdit publishes that this instance wrote path
+func (r *InstanceRegistry) RecordEdit(path string) error {
+	r.self.Edits[instanceKey(path)] = time.Now()
+	return r.publish()
+}
+
+// instanceKey identifies a file the same way in instances started from
+// different directories
+func instanceKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// publish writes this instance's record with a fresh heartbeat
+func (r *InstanceRegistry) publish() error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create instances directory: %w", err)
+	}
+	r.self.Heartbeat = time.Now()
+	data, err := json.Marshal(r.self)
+	if err != nil {
+		return fmt.Errorf("failed to marshal instance record: %w", err)
+	}
+	return config.WriteFileAtomic(filepath.Join(r.dir, r.fileName()), data, 0644)
+}
+
+// Close removes this instance's record
+func (r *InstanceRegistry) Close() {
+	os.Remove(filepath.Join(r.dir, r.fileName()))
+}
+
+// fileName is this instance's record file; the start time tells apart
+// agents sharing a process, like the Slack service's sessions
+func (r *InstanceRegistry) fileName() string {
+	return strconv.Itoa(r.self.PID) + "-" + strconv.FormatInt(r.self.Started.UnixNano(), 36) + ".json"
+}
+
+// publishInstanceEdit records an edit for other instances to see and returns
+// a warning for the tool result when another instance edited the same file
+func (a *Agent) publishInstanceEdit(path string) string {
+	if a.instances == nil {
+		return ""
+	}
+	if err := a.instances.RecordEdit(path); err != nil {
+		a.debugLog("⚠️ Failed to publish edit to other instances: %v\n", err)
+	}
+
+	editors := a.instances.EditedByOthers(path)
+	if len(editors) == 0 {
+		return ""
+	}
+	descriptions := make([]string, len(editors))
+	for i, editor := range editors {
+		descriptions[i] = fmt.Sprintf("pid %d, %s ago", editor.PID,
+			time.Since(editor.Edits[instanceKey(path)]).Round(time.Second))
+	}
+	fmt.Printf("⚠️  Another coder instance also edited %s (%s)\n", path, strings.Join(descriptions, "; "))
+	return fmt.Sprintf("\n\n⚠️ CONCURRENT EDIT: another coder instance in this repository also edited %s (%s). Re-read the file before relying on its contents.",
+		path, strings.Join(descriptions, "; "))
+}
+
+// OtherInstances returns the other coder instances running in this repository
+func (a *Agent) OtherInstances() []InstanceRecord {
+	if a.instances == nil {
+		return nil
+	}
+	return a.instances.Others()
+}
+
+// CloseInstance removes this instance from the repository's instance registry
+func (a *Agent) CloseInstance() {
+	if a.instances != nil {
+		a.instances.Close()
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+func TestInstanceRegistryFlagsConcurrentEdits(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	first := NewInstanceRegistry(root, "first")
+	second := NewInstanceRegistry(root, "second")
+	second.self.Started = first.self.Started.Add(time.Millisecond)
+
+	if others := first.Others(); len(others) != 0 {
+		t.Fatalf("expected no other instances before anything is published, got %d", len(others))
+	}
+	if err := first.RecordEdit(filepath.Join(root, "main.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	editors := second.EditedByOthers(filepath.Join(root, "main.go"))
+	if len(editors) != 1 || editors[0].SessionID != "first" {
+		t.Fatalf("expected the first instance as editor, got %+v", editors)
+	}
+	if len(second.EditedByOthers(filepath.Join(root, "other.go"))) != 0 {
+		t.Error("expected no editors for an untouched file")
+	}
+	if len(first.EditedByOthers(filepath.Join(root, "main.go"))) != 0 {
+		t.Error("expected an instance not to flag its own edits")
+	}
+
+	first.Close()
+	if len(second.Others()) != 0 {
+		t.Error("expected a closed instance to disappear")
+	}
+}
+
+func TestInstanceRegistryDropsStaleInstances(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	crashed := NewInstanceRegistry(root, "crashed")
+	if err := crashed.RecordEdit("main.go"); err != nil {
+		t.Fatal(err)
+	}
+	crashed.self.Heartbeat = time.Now().Add(-2 * instanceStaleAfter)
+	data, _ := json.Marshal(crashed.self)
+	path := filepath.Join(crashed.dir, crashed.fileName())
+	os.WriteFile(path, data, 0644)
+
+	if others := NewInstanceRegistry(root, "new").Others(); len(others) != 0 {
+		t.Errorf("expected the stale instance to be ignored, got %d", len(others))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the stale record to be removed")
+	}
+}
+
+func TestLockFileSerializesWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	os.WriteFile(path, []byte(""), 0600)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := config.WithFileLock(path, func() error {
+				data, _ := os.ReadFile(path)
+				return config.WriteFileAtomic(path, append(data, 'x'), 0600)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, _ := os.ReadFile(path)
+	if len(data) != 20 {
+		t.Errorf("expected 20 serialized writes, got %d", len(data))
+	}
+}
+
+func TestKnowledgeBaseSaveMergesOtherInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "knowledge.json")
+	first, _ := openKnowledgeBaseAt(path)
+	second, _ := openKnowledgeBaseAt(path)
+
+	first.Add("The build uses mage, not make", "a")
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	second.Add("Tests require docker compose up db", "b")
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, _ := openKnowledgeBaseAt(path)
+	if len(merged.Entries) != 2 {
+		t.Errorf("expected both instances' facts to be kept, got %d", len(merged.Entries))
+	}
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// processRunning reports whether a process exists and isn't a zombie
+func processRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	return err != nil || !strings.Contains(string(stat), ") Z ")
+}
+
+// readPID waits for a command to write a process ID to a file
+func readPID(t *testing.T, path string) int {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		if data, err := os.ReadFile(path); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				return pid
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no process ID written to %s", path)
+	return 0
+}
+
+func TestCancelledShellCommandKillsItsChildren(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		readPID(t, pidFile)
+		cancel()
+	}()
+
+	_, err := tools.ExecuteShellCommand(ctx, "sleep 30 & echo $! > "+pidFile+"; wait")
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("expected the command cancelled, got %v", err)
+	}
+	pid := readPID(t, pidFile)
+	for i := 0; i < 50 && processRunning(pid); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if processRunning(pid) {
+		syscall.Kill(pid, syscall.SIGKILL)
+		t.Errorf("expected the background sleep killed with the command")
+	}
+}
+
+func TestJobsListBackgroundProcesses(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	if _, err := tools.ExecuteShellCommand(context.Background(), "sleep 30 >/dev/null 2>&1 & echo $! > "+pidFile); err != nil {
+		t.Fatal(err)
+	}
+	pid := readPID(t, pidFile)
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	var job *tools.Job
+	for _, running := range tools.Jobs() {
+		if strings.Contains(running.Command, pidFile) {
+			job = &running
+		}
+	}
+	if job == nil || !job.Done {
+		t.Fatalf("expected the finished command listed for its background sleep, got %+v", tools.Jobs())
+	}
+	if err := tools.KillJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && processRunning(pid); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if processRunning(pid) {
+		t.Errorf("expected the background sleep killed")
+	}
+	for _, running := range tools.Jobs() {
+		if running.ID == job.ID {
+			t.Errorf("expected job %d forgotten once killed", job.ID)
+		}
+	}
+}
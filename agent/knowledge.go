@@ -0,0 +1,307 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+const (
+	maxKnowledgeEntries     = 200  // least recently useful facts are dropped beyond this
+	maxRecalledFacts        = 8    // facts injected into a new task
+	knowledgeDuplicateRatio = 0.7  // term overlap at which two facts are the same fact
+	maxDistillDigestChars   = 6000 // size of the task digest sent for distillation
+)
+
+// KnowledgeEntry is one durable fact learned about a project
+type KnowledgeEntry struct {
+	Fact      string    `json:"fact"`
+	SessionID string    `json:"session_id,omitempty"`
+	Added     time.Time `json:"added"`
+	LastUsed  time.Time `json:"last_used,omitempty"`
+	Uses      int       `json:"uses"`
+}
+
+// KnowledgeBase holds facts distilled from completed tasks, per project, so
+// later sessions start out knowing how the project builds, tests and behaves
+type KnowledgeBase struct {
+	path    string
+	Entries []*KnowledgeEntry `json:"entries"`
+}
+
+// OpenKnowledgeBase loads the knowledge base for the project rooted at dir
+func OpenKnowledgeBase(dir string) (*KnowledgeBase, error) {
+	cacheDir, err := projectCacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return openKnowledgeBaseAt(filepath.Join(cacheDir, "knowledge.json"))
+}
+
+// openKnowledgeBaseAt loads (or starts) a knowledge base file
+func openKnowledgeBaseAt(path string) (*KnowledgeBase, error) {
+	kb := &KnowledgeBase{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return kb, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read knowledge base: %w", err)
+	}
+	if err := json.Unmarshal(data, kb); err != nil {
+		return nil, fmt.Errorf("failed to parse knowledge base %s: %w", path, err)
+	}
+	return kb, nil
+}
+
+// Add records a fact unless an equivalent one is already known, and reports
+// whether it was new. A restated fact replaces the old wording.
+func (kb *KnowledgeBase) Add(fact, sessionID string) bool {
+	fact = strings.TrimSpace(fact)
+	if fact == "" {
+		return false
+	}
+	for _, entry := range kb.Entries {
+		if sameFact(entry.Fact, fact) {
+			entry.Fact = fact
+			entry.Added = time.Now()
+			return false
+		}
+	}
+	kb.Entries = append(kb.Entries, &KnowledgeEntry{Fact: fact, SessionID: sessionID, Added: time.Now()})
+	kb.evict()
+	return true
+}
+
+// Relevant returns up to limit facts that share terms with the query, most
+// relevant first, and marks them as used
+func (kb *KnowledgeBase) Relevant(query string, limit int) []*KnowledgeEntry {
+	relevant := kb.match(query, limit)
+	for _, entry := range relevant {
+		entry.LastUsed = time.Now()
+		entry.Uses++
+	}
+	return relevant
+}
+
+// match returns up to limit facts that share terms with the query, most relevant first
+func (kb *KnowledgeBase) match(query string, limit int) []*KnowledgeEntry {
+	chunks := make([]Chunk, len(kb.Entries))
+	for i, entry := range kb.Entries {
+		chunks[i] = Chunk{Text: entry.Fact}
+	}
+	scores, _ := lexicalReranker{}.Score(query, chunks)
+
+	var matched []*KnowledgeEntry
+	for _, i := range rankByScore(scores) {
+		if scores[i] <= 0 || len(matched) == limit {
+			break
+		}
+		matched = append(matched, kb.Entries[i])
+	}
+	return matched
+}
+
+// Save writes the knowledge base to disk, first merging in facts that other
+// instances running in the same project saved since it was loaded
+func (kb *KnowledgeBase) Save() error {
+	return config.WithFileLock(kb.path, func() error {
+		if onDisk, err := openKnowledgeBaseAt(kb.path); err == nil {
+			kb.merge(onDisk)
+		}
+		data, err := json.MarshalIndent(kb, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal knowledge base: %w", err)
+		}
+		return config.WriteFileAtomic(kb.path, data, 0600)
+	})
+}
+
+// merge adds the facts from another copy that this one doesn't know
+func (kb *KnowledgeBase) merge(other *KnowledgeBase) {
+	for _, entry := range other.Entries {
+		known := false
+		for _, mine := range kb.Entries {
+			if sameFact(mine.Fact, entry.Fact) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			kb.Entries = append(kb.Entries, entry)
+		}
+	}
+	kb.evict()
+}
+
+// evict drops the facts that were least recently added or used
+func (kb *KnowledgeBase) evict() {
+	if len(kb.Entries) <= maxKnowledgeEntries {
+		return
+	}
+	lastUseful := func(entry *KnowledgeEntry) time.Time {
+		if entry.LastUsed.After(entry.Added) {
+			return entry.LastUsed
+		}
+		return entry.Added
+	}
+	sort.SliceStable(kb.Entries, func(i, j int) bool {
+		return lastUseful(kb.Entries[i]).After(lastUseful(kb.Entries[j]))
+	})
+	kb.Entries = kb.Entries[:maxKnowledgeEntries]
+}
+
+// sameFact reports whether two facts are equal or share most of their terms
+func sameFact(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	termsA, termsB := factTerms(a), factTerms(b)
+	if len(termsA) == 0 || len(termsB) == 0 {
+		return false
+	}
+	shared := 0
+	for term := range termsA {
+		if termsB[term] {
+			shared++
+		}
+	}
+	union := len(termsA) + len(termsB) - shared
+	return float64(shared)/float64(union) >= knowledgeDuplicateRatio
+}
+
+// factTerms returns the distinct lowercase terms of a fact
+func factTerms(fact string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, term := range rerankTermPattern.FindAllString(fact, -1) {
+		terms[strings.ToLower(term)] = true
+	}
+	return terms
+}
+
+// knowledgeSchema is the structured answer of a distillation request
+var knowledgeSchema = api.ResponseSchema{
+	Name: "knowledge",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"facts": map[string]interface{}{
+				"type":        "array",
+				"description": "Durable facts about the project, one short sentence each",
+				"items":       map[string]interface{}{"type": "string"},
+			},
+		},
+		"required":             []string{"facts"},
+		"additionalProperties": false,
+	},
+}
+
+// DistillKnowledge extracts durable project facts from the task that just
+// completed and adds the new ones to the knowledge base. It returns the number
+// of facts added.
+func (a *Agent) DistillKnowledge(result string) (int, error) {
+	if a.knowledge == nil || len(a.messages) < 2 {
+		return 0, nil
+	}
+	digest := a.taskDigest(result)
+	if digest == "" {
+		return 0, nil // nothing was explored, so nothing was learned
+	}
+
+	var known strings.Builder
+	for _, entry := range a.knowledge.match(digest, 30) {
+		fmt.Fprintf(&known, "- %s\n", entry.Fact)
+	}
+
+	prompt := fmt.Sprintf(`From this record of a completed coding task, list durable facts about the project that would help with FUTURE tasks: how to build, test, lint or run it, services or setup it needs, conventions, and surprises (e.g. "The build uses mage, not make", "Tests require docker compose up db").
+Do NOT include what was done in this task, temporary state, or anything already known. Return an empty list when nothing durable was learned.
+
+ALREADY KNOWN:
+%s
+TASK RECORD:
+%s`, known.String(), digest)
+
+	var answer struct {
+		Facts []string `json:"facts"`
+	}
+	if err := a.GenerateStructured(prompt, knowledgeSchema, &answer); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, fact := range answer.Facts {
+		if a.knowledge.Add(fact, a.sessionID) {
+			added++
+		}
+	}
+	if err := a.knowledge.Save(); err != nil {
+		return added, err
+	}
+	a.debugLog("🧠 Distilled %d facts, %d new\n", len(answer.Facts), added)
+	return added, nil
+}
+
+// taskDigest condenses the finished task to the query, the commands that were
+// run with their outcome, and the final answer. It is empty when no tools ran.
+func (a *Agent) taskDigest(result string) string {
+	const resultPrefix = "Tool call result for shell_command:"
+	var commands strings.Builder
+	for _, msg := range a.messages[2:] {
+		start := strings.Index(msg.Content, resultPrefix) // high-risk results carry a tag first
+		if msg.Role != "user" || start < 0 || start > 40 {
+			continue
+		}
+		body := strings.TrimSpace(msg.Content[start+len(resultPrefix):])
+		line, output, _ := strings.Cut(body, "\n")
+		if strings.HasPrefix(line, "Error executing tool") {
+			fmt.Fprintf(&commands, "- FAILED: %s\n", truncateForDigest(line, 300))
+			continue
+		}
+		fmt.Fprintf(&commands, "- %s → %s\n", line, truncateForDigest(strings.TrimSpace(output), 200))
+	}
+	if commands.Len() == 0 {
+		return ""
+	}
+
+	digest := fmt.Sprintf("QUERY: %s\n\nCOMMANDS:\n%s\nRESULT: %s",
+		truncateForDigest(a.messages[1].Content, 1000), commands.String(), truncateForDigest(result, 1500))
+	return truncateForDigest(digest, maxDistillDigestChars)
+}
+
+// truncateForDigest shortens text to max characters, flattening newlines
+func truncateForDigest(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > max {
+		return text[:max] + "…"
+	}
+	return text
+}
+
+// knowledgeForQuery formats the known facts relevant to a new task for the
+// system prompt
+func (a *Agent) knowledgeForQuery(query string) string {
+	if a.knowledge == nil {
+		return ""
+	}
+	relevant := a.knowledge.Relevant(query, maxRecalledFacts)
+	if len(relevant) == 0 {
+		return ""
+	}
+	var section strings.Builder
+	section.WriteString("\n\nPROJECT KNOWLEDGE (learned in earlier sessions; verify if something seems off):\n")
+	for _, entry := range relevant {
+		fmt.Fprintf(&section, "- %s\n", entry.Fact)
+	}
+	if err := a.knowledge.Save(); err != nil {
+		a.debugLog("⚠️ Failed to save knowledge base: %v\n", err)
+	}
+	a.debugLog("🧠 Recalled %d project facts\n", len(relevant))
+	return section.String()
+}
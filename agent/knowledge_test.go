@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestKnowledgeBaseDeduplicatesFacts(t *testing.T) {
+	kb, err := openKnowledgeBaseAt(filepath.Join(t.TempDir(), "knowledge.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !kb.Add("The build uses mage, not make", "s1") {
+		t.Fatal("expected the first fact to be added")
+	}
+	if kb.Add("the build uses mage, not make.", "s2") {
+		t.Error("expected a restated fact to be treated as a duplicate")
+	}
+	if !kb.Add("Tests require docker compose up db", "s2") {
+		t.Error("expected a different fact to be added")
+	}
+	if len(kb.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(kb.Entries))
+	}
+
+	if err := kb.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := openKnowledgeBaseAt(kb.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Entries) != 2 {
+		t.Errorf("expected 2 entries after reload, got %d", len(reloaded.Entries))
+	}
+}
+
+func TestKnowledgeBaseRelevant(t *testing.T) {
+	kb, _ := openKnowledgeBaseAt(filepath.Join(t.TempDir(), "knowledge.json"))
+	kb.Add("The build uses mage, not make", "")
+	kb.Add("Integration tests require docker compose up db", "")
+	kb.Add("Frontend lives in web/ and uses pnpm", "")
+
+	relevant := kb.Relevant("the integration tests fail to connect to the db", 8)
+	if len(relevant) != 1 || !strings.Contains(relevant[0].Fact, "docker") {
+		t.Fatalf("expected only the docker fact, got %v", relevant)
+	}
+	if relevant[0].Uses != 1 {
+		t.Errorf("expected the recalled fact to be marked as used, got %d uses", relevant[0].Uses)
+	}
+}
+
+func TestTaskDigest(t *testing.T) {
+	agent := &Agent{messages: []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "Run the tests"},
+		{Role: "assistant", Content: ""},
+		{Role: "user", Content: "Tool call result for shell_command: make test\nmake: *** No rule to make target 'test'"},
+		{Role: "user", Content: "Tool call result for shell_command: Error executing tool shell_command: exit status 1"},
+	}}
+	digest := agent.taskDigest("Tests pass with mage test")
+	for _, expected := range []string{"QUERY: Run the tests", "- make test → make: *** No rule", "- FAILED: Error executing tool", "RESULT: Tests pass with mage test"} {
+		if !strings.Contains(digest, expected) {
+			t.Errorf("expected digest to contain %q, got:\n%s", expected, digest)
+		}
+	}
+
+	agent.messages = agent.messages[:3]
+	if digest := agent.taskDigest("answer"); digest != "" {
+		t.Errorf("expected no digest without commands, got %q", digest)
+	}
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// Preferences for the checks run on files after write_file and edit_file
+const (
+	prefCheckAfterEdit  = "check_after_edit"  // syntax check edited files (default true)
+	prefFormatAfterEdit = "format_after_edit" // run the language's formatter on edited files (default false)
+)
+
+// toolchainForPrompt lists the build, test and lint commands of the languages
+// detected in the project, so verification isn't Go-only
+func (a *Agent) toolchainForPrompt() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	packs := tools.DetectLanguagePacks(wd)
+	if len(packs) == 0 {
+		return ""
+	}
+
+	var section strings.Builder
+	section.WriteString("\n\nPROJECT TOOLCHAIN (use these to verify changes instead of guessing):\n")
+	for _, pack := range packs {
+		commands := []string{}
+		for _, command := range []struct{ label, command string }{
+			{"build", pack.BuildCommand()},
+			{"test", pack.TestCommand()},
+			{"lint", pack.LintCommand()},
+			{"format", pack.FormatCommand("FILE")},
+			{"rename symbol", pack.RenameCommand("FILE", 12, 5, "NewName")},
+		} {
+			if command.command != "" {
+				commands = append(commands, fmt.Sprintf("%s `%s`", command.label, command.command))
+			}
+		}
+		fmt.Fprintf(&section, "- %s: %s\n", pack.Name(), strings.Join(commands, ", "))
+	}
+	return strings.TrimRight(section.String(), "\n")
+}
+
+// afterEditChecks formats and syntax checks a file written by write_file or
+// edit_file, with the tools of its language. It returns a note for the tool
+// result when the file was reformatted or doesn't parse.
+func (a *Agent) afterEditChecks(filePath string) string {
+	pack := tools.LanguagePackFor(filePath)
+	if pack == nil || a.configManager == nil {
+		return ""
+	}
+	cfg := a.configManager.GetConfig()
+	var note string
+
+	if format := pack.FormatCommand(filePath); format != "" && cfg.GetBoolPreference(prefFormatAfterEdit, false) && tools.CommandAvailable(format) {
+		before, _ := os.ReadFile(filePath)
+		if _, err := tools.ExecuteShellCommand(a.operationContext(), format); err != nil {
+			a.debugLog("⚠️ Formatter failed for %s: %v\n", filePath, err)
+		} else if after, _ := os.ReadFile(filePath); string(after) != string(before) {
+			// The model's copy of the file is stale now
+			a.fileWatcher.Track(filePath)
+			a.invalidateModifiedFile(filePath)
+			note += fmt.Sprintf("\n\nThe file was reformatted with `%s` - re-read it before editing it again.", format)
+		}
+	}
+
+	if check := pack.CheckCommand(filePath); check != "" && cfg.GetBoolPreference(prefCheckAfterEdit, true) && tools.CommandAvailable(check) {
+		if output, err := tools.ExecuteShellCommand(a.operationContext(), check); err != nil {
+			a.debugLog("⚠️ Syntax check failed for %s: %v\n", filePath, err)
+			note += fmt.Sprintf("\n\n⚠️ SYNTAX CHECK FAILED (`%s`):\n%s", check, strings.TrimSpace(truncateTranscript(output, 2000)))
+		}
+	}
+	return note
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// TestLanguagePackFor tests selecting language packs by file type
+func TestLanguagePackFor(t *testing.T) {
+	tests := map[string]string{
+		"main.go":         "Go",
+		"src/App.tsx":     "TypeScript/JavaScript",
+		"lib/index.mjs":   "TypeScript/JavaScript",
+		"scripts/tool.py": "Python",
+		"src/main.rs":     "Rust",
+		"README.md":       "",
+		"Main.java":       "",
+	}
+	for file, expected := range tests {
+		pack := tools.LanguagePackFor(file)
+		name := ""
+		if pack != nil {
+			name = pack.Name()
+		}
+		if name != expected {
+			t.Errorf("LanguagePackFor(%q) = %q, expected %q", file, name, expected)
+		}
+	}
+
+	goPack := tools.LanguagePackFor("main.go")
+	if got := goPack.RenameCommand("pkg/my file.go", 12, 5, "NewName"); got != "gopls rename -w 'pkg/my file.go':12:5 NewName" {
+		t.Errorf("Unexpected rename command: %s", got)
+	}
+	if got := tools.LanguagePackFor("app.ts").CheckCommand("app.ts"); got != "" {
+		t.Errorf("Expected no syntax check for TypeScript files, got %q", got)
+	}
+	if got := tools.LanguagePackFor("app.js").CheckCommand("app.js"); got != "node --check app.js" {
+		t.Errorf("Unexpected JavaScript check command: %q", got)
+	}
+}
+
+// TestDetectLanguagePacks tests detecting a project's languages from marker files
+func TestDetectLanguagePacks(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "package.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(root, "pyproject.toml"), []byte(""), 0644)
+
+	var names []string
+	for _, pack := range tools.DetectLanguagePacks(root) {
+		names = append(names, pack.Name())
+	}
+	if strings.Join(names, ",") != "TypeScript/JavaScript,Python" {
+		t.Errorf("Expected TypeScript/JavaScript and Python, got %v", names)
+	}
+}
+
+// TestOutlineUsesLanguagePack tests that outlines use the pack's declaration pattern
+func TestOutlineUsesLanguagePack(t *testing.T) {
+	content := "import React from 'react'\n\nexport function App() {\n  return null\n}\n\nexport const theme = {}\n"
+	outline := tools.CompressFileContent("src/App.tsx", content, tools.CompressOutline, nil)
+	if !strings.Contains(outline, "L3: export function App() {") || !strings.Contains(outline, "L7: export const theme = {}") {
+		t.Errorf("Expected .tsx declarations in the outline, got: %s", outline)
+	}
+}
+
+// TestAfterEditChecks tests that a syntax error in an edited file is reported
+func TestAfterEditChecks(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt not installed")
+	}
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	defer os.Unsetenv("OPENROUTER_API_KEY")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.go")
+	bad := filepath.Join(dir, "bad.go")
+	os.WriteFile(good, []byte("package main\n\nfunc main() {}\n"), 0644)
+	os.WriteFile(bad, []byte("package main\n\nfunc main() {\n"), 0644)
+
+	if note := agent.afterEditChecks(good); note != "" {
+		t.Errorf("Expected no note for a valid file, got %q", note)
+	}
+	if note := agent.afterEditChecks(bad); !strings.Contains(note, "SYNTAX CHECK FAILED") {
+		t.Errorf("Expected a syntax error note, got %q", note)
+	}
+	if note := agent.afterEditChecks(filepath.Join(dir, "notes.md")); note != "" {
+		t.Errorf("Expected no checks for files without a language pack, got %q", note)
+	}
+}
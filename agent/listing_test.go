@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+// writeListingTree creates a small project with an ignored build directory
+func writeListingTree(t *testing.T, root string) {
+	t.Helper()
+	for path, content := range map[string]string{
+		".gitignore":              "build/\n*.log\n",
+		"main.go":                 "package main\n",
+		"main_test.go":            "package main\n",
+		"cmd/tool/main.go":        "package main\n",
+		"internal/gen/api.pb.go":  "package gen\n",
+		"internal/util/util.go":   "package util\n",
+		"internal/util/testdata/": "",
+		"build/out.bin":           "binary",
+		"debug.log":               "log",
+	} {
+		full := filepath.Join(root, path)
+		if strings.HasSuffix(path, "/") {
+			os.MkdirAll(full, 0755)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+	}
+}
+
+func TestListDirectory(t *testing.T) {
+	for _, useGit := range []bool{true, false} {
+		root := t.TempDir()
+		writeListingTree(t, root)
+		if useGit {
+			if err := exec.Command("git", "-C", root, "init", "-q").Run(); err != nil {
+				t.Skip("git not available")
+			}
+		}
+
+		listing, err := tools.ListDirectory(root, tools.ListOptions{MaxDepth: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(listing, "build") || strings.Contains(listing, "debug.log") {
+			t.Errorf("git=%v: expected ignored files to be left out:\n%s", useGit, listing)
+		}
+		for _, want := range []string{"6 files", "cmd/\n  tool/ (1 files)", "internal/\n  gen/ (1 files)", "main.go (13 B)"} {
+			if !strings.Contains(listing, want) {
+				t.Errorf("git=%v: expected %q in listing:\n%s", useGit, want, listing)
+			}
+		}
+
+		listing, _ = tools.ListDirectory(root, tools.ListOptions{Include: []string{"*.go"}, Exclude: []string{"internal/gen", "*_test.go"}})
+		if strings.Contains(listing, "api.pb.go") || strings.Contains(listing, "main_test.go") || strings.Contains(listing, ".gitignore") || !strings.Contains(listing, "util.go") {
+			t.Errorf("git=%v: expected include and exclude patterns to apply:\n%s", useGit, listing)
+		}
+	}
+}
+
+func TestGlobFiles(t *testing.T) {
+	root := t.TempDir()
+	writeListingTree(t, root)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"**/main.go", []string{"cmd/tool/main.go", "main.go"}},
+		{"*_test.go", []string{"main_test.go"}},
+		{"internal/*/*.go", []string{"internal/gen/api.pb.go", "internal/util/util.go"}},
+		{"*.bin", nil},
+	}
+	for _, tt := range tests {
+		result, err := tools.GlobFiles(root, tt.pattern, tools.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, line := range strings.Split(result, "\n")[1:] {
+			got = append(got, strings.Fields(line)[0])
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+
+	if result, _ := tools.GlobFiles(root, "**/*.go", tools.ListOptions{MaxDepth: 1, MaxEntries: 1}); !strings.Contains(result, "2 file(s)") || !strings.Contains(result, "1 more") {
+		t.Errorf("expected depth and entry limits to apply, got:\n%s", result)
+	}
+}
+
+func TestRedundantListingsAreOptimized(t *testing.T) {
+	optimizer := NewConversationOptimizer(true, false)
+	messages := []api.Message{
+		{Role: "system", Content: "system"},
+		{Role: "user", Content: "query"},
+		{Role: "user", Content: "Tool call result for glob: . **/*.go\n1 file(s)\nmain.go (13 B)", ToolCallID: "call_1"},
+		{Role: "user", Content: "Tool call result for glob: . *.md\n1 file(s)\nREADME.md (1 B)", ToolCallID: "call_2"},
+		{Role: "user", Content: "Tool call result for glob: . **/*.go\n1 file(s)\nmain.go (13 B)", ToolCallID: "call_3"},
+	}
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_1", ToolName: "glob", Command: ". **/*.go"})
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_2", ToolName: "glob", Command: ". *.md"})
+	optimizer.RecordToolResult(ToolResultRecord{ToolCallID: "call_3", ToolName: "glob", Command: ". **/*.go"})
+
+	optimized := optimizer.OptimizeConversation(messages)
+	if !strings.Contains(optimized[2].Content, "[OPTIMIZED]") {
+		t.Errorf("expected the repeated listing to be summarized, got %q", optimized[2].Content)
+	}
+	if strings.Contains(optimized[3].Content, "[OPTIMIZED]") || strings.Contains(optimized[4].Content, "[OPTIMIZED]") {
+		t.Error("expected other and latest listings to be kept")
+	}
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+)
+
+const (
+	prefLocalWarmUp    = "local_warmup"     // load the local model in the background when a session starts
+	prefLocalKeepAlive = "local_keep_alive" // how long the local model stays loaded, e.g. "30m" or "-1" for indefinitely
+)
+
+// localWarmUpTimeout bounds how long a background warm-up may take to load
+// a large model
+const localWarmUpTimeout = 10 * time.Minute
+
+// applyLocalModelSettings passes the keep-alive preference to a local client
+func (a *Agent) applyLocalModelSettings() {
+	local, ok := a.client.(api.LocalModelClient)
+	if !ok || a.configManager == nil {
+		return
+	}
+	local.SetKeepAlive(a.configManager.GetConfig().GetStringPreference(prefLocalKeepAlive, ""))
+}
+
+// warmUpLocalModel starts loading a local model in the background when the
+// warm-up preference is on, so the first query doesn't wait for the load. It
+// returns a channel that is closed once the load finishes, or nil when there
+// is nothing to warm up.
+func (a *Agent) warmUpLocalModel() <-chan struct{} {
+	local, ok := a.client.(api.LocalModelClient)
+	if !ok || a.configManager == nil || !a.configManager.GetConfig().GetBoolPreference(prefLocalWarmUp, false) {
+		return nil
+	}
+
+	fmt.Printf("🔥 Loading %s in the background...\n", a.GetModel())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), localWarmUpTimeout)
+		defer cancel()
+		start := time.Now()
+		if err := local.WarmUp(ctx); err != nil {
+			a.debugLog("⚠️ Warm-up of %s failed: %v\n", a.GetModel(), err)
+			return
+		}
+		a.debugLog("🔥 %s loaded in %v\n", a.GetModel(), time.Since(start).Round(time.Second))
+	}()
+	return done
+}
+
+// UnloadLocalModel frees the memory the local model holds. The next request
+// loads it again.
+func (a *Agent) UnloadLocalModel() error {
+	local, ok := a.client.(api.LocalModelClient)
+	if !ok {
+		return fmt.Errorf("%s doesn't run models locally", api.GetProviderName(a.clientType))
+	}
+	ctx, end := a.BeginOperation()
+	defer end()
+	if err := local.Unload(ctx); err != nil {
+		return fmt.Errorf("failed to unload %s: %w", a.GetModel(), err)
+	}
+	return nil
+}
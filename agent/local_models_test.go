@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+const gib = 1 << 30
+
+func TestRecommendLocalModel(t *testing.T) {
+	cases := []struct {
+		mem  api.LocalMemory
+		want string
+	}{
+		{api.LocalMemory{VRAM: 24 * gib, RAM: 64 * gib}, "qwen3-coder:30b"},
+		{api.LocalMemory{VRAM: 16 * gib, RAM: 32 * gib}, "qwen2.5-coder:14b"},
+		{api.LocalMemory{VRAM: 8 * gib, RAM: 16 * gib}, "qwen2.5-coder:7b"},
+		{api.LocalMemory{VRAM: 12 * gib, RAM: 16 * gib, Unified: true}, "qwen2.5-coder:14b"},
+		{api.LocalMemory{RAM: 16 * gib}, "qwen2.5-coder:7b"},
+		{api.LocalMemory{RAM: 4 * gib}, "qwen2.5-coder:3b"},
+		{api.LocalMemory{}, api.OllamaModel},
+	}
+	for _, c := range cases {
+		if got := api.RecommendLocalModel(c.mem); got != c.want {
+			t.Errorf("RecommendLocalModel(%s) = %s, want %s", c.mem, got, c.want)
+		}
+	}
+
+	mem := api.LocalMemory{VRAM: 8 * gib, RAM: 16 * gib}
+	for size, want := range map[int64]api.ModelFit{
+		4 * gib:  api.ModelFits,
+		14 * gib: api.ModelSlow,
+		40 * gib: api.ModelTooLarge,
+	} {
+		if got := api.FitLocalModel(size, mem); got != want {
+			t.Errorf("FitLocalModel(%dGB) = %q, want %q", size/gib, got, want)
+		}
+	}
+	if got := api.FitLocalModel(4*gib, api.LocalMemory{}); got != "" {
+		t.Errorf("expected no rating with unknown memory, got %q", got)
+	}
+}
+
+// ollamaRequests records the requests a fake Ollama server received
+type ollamaRequests struct {
+	mu       sync.Mutex
+	chat     map[string]interface{}   // the last chat request
+	generate []map[string]interface{} // load and unload requests, in order
+}
+
+func (r *ollamaRequests) lastChat() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.chat
+}
+
+func (r *ollamaRequests) loads() []map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]map[string]interface{}(nil), r.generate...)
+}
+
+// ollamaServer serves pulled models with their sizes and records requests
+func ollamaServer(t *testing.T, pulled map[string]int64) *ollamaRequests {
+	requests := &ollamaRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+		requests.mu.Lock()
+		defer requests.mu.Unlock()
+		switch r.URL.Path {
+		case "/api/tags":
+			var models []map[string]interface{}
+			for name, size := range pulled {
+				models = append(models, map[string]interface{}{"name": name, "size": size})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"models": models})
+		case "/api/generate":
+			requests.generate = append(requests.generate, body)
+			w.Write([]byte(`{"done": true}`))
+		case "/v1/chat/completions":
+			requests.chat = body
+			w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("OLLAMA_HOST", server.URL)
+	return requests
+}
+
+func TestLocalModelsFitThisMachine(t *testing.T) {
+	ollamaServer(t, map[string]int64{"qwen2.5-coder:7b": 4 * gib, "gpt-oss:latest": 14 * gib})
+	t.Setenv(api.VRAMOverrideEnv, "8")
+
+	models, err := api.GetAvailableModels(api.OllamaClientType)
+	if err != nil {
+		t.Fatalf("failed to list models: %v", err)
+	}
+	fits := map[string]api.ModelFit{}
+	for _, model := range models {
+		fits[model.ID] = model.Fit
+	}
+	if fits["qwen2.5-coder:7b"] != api.ModelFits || fits["gpt-oss:latest"] == api.ModelFits {
+		t.Errorf("expected only the 7b model to fit in 8GB, got %v", fits)
+	}
+	if warning := api.LocalModelWarning("gpt-oss:20b"); !strings.Contains(warning, "ollama pull qwen2.5-coder:7b") {
+		t.Errorf("expected gpt-oss:20b to be flagged with a better fit, got %q", warning)
+	}
+	if warning := api.LocalModelWarning("qwen2.5-coder:7b"); warning != "" {
+		t.Errorf("expected no warning for a model that fits, got %q", warning)
+	}
+
+	// A model that isn't pulled suggests pulling it, or the recommended model
+	client, err := api.NewUnifiedClientWithModel(api.OllamaClientType, "qwen2.5-coder:14b")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.CheckConnection(); err == nil ||
+		!strings.Contains(err.Error(), "ollama pull qwen2.5-coder:14b") ||
+		!strings.Contains(err.Error(), "ollama pull qwen2.5-coder:7b") {
+		t.Errorf("expected pull suggestions, got %v", err)
+	}
+	client, _ = api.NewUnifiedClientWithModel(api.OllamaClientType, "gpt-oss:20b")
+	if err := client.CheckConnection(); err != nil {
+		t.Errorf("expected gpt-oss:latest to satisfy gpt-oss:20b, got %v", err)
+	}
+}
+
+func TestOllamaStandardModelRequest(t *testing.T) {
+	requests := ollamaServer(t, map[string]int64{"qwen2.5-coder:7b": 4 * gib})
+
+	client, err := api.NewUnifiedClientWithModel(api.OllamaClientType, "qwen2.5-coder:7b")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	tool := api.Tool{Type: "function"}
+	tool.Function.Name = "read_file"
+	messages := []api.Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}}
+	resp, err := client.SendChatRequest(context.Background(), messages, []api.Tool{tool}, "")
+	if err != nil || resp.Choices[0].Message.Content != "ok" {
+		t.Fatalf("expected a response, got %+v, %v", resp, err)
+	}
+	request := requests.lastChat()
+	if request["model"] != "qwen2.5-coder:7b" {
+		t.Errorf("expected the requested model to be used, got %v", request["model"])
+	}
+	if sent, _ := request["messages"].([]interface{}); len(sent) != 2 {
+		t.Errorf("expected the messages to be sent as they are, got %v", request["messages"])
+	}
+	if sent, _ := request["tools"].([]interface{}); len(sent) != 1 {
+		t.Errorf("expected tools to be sent to a non-harmony model, got %v", request["tools"])
+	}
+}
+
+func TestLocalModelWarmUpAndUnload(t *testing.T) {
+	requests := ollamaServer(t, map[string]int64{"qwen2.5-coder:7b": 4 * gib})
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	if agent.GetProviderType() != api.OllamaClientType {
+		if err := agent.UnloadLocalModel(); err == nil {
+			t.Error("expected /unload to fail for a cloud provider")
+		}
+	}
+
+	prefs := agent.configManager.GetConfig().Preferences
+	prefs[prefLocalWarmUp] = true
+	prefs[prefLocalKeepAlive] = "-1"
+	defer delete(prefs, prefLocalWarmUp)
+	defer delete(prefs, prefLocalKeepAlive)
+	original, originalType := agent.client, agent.clientType
+	defer func() { agent.client, agent.clientType = original, originalType }()
+	agent.client, _ = api.NewUnifiedClientWithModel(api.OllamaClientType, "qwen2.5-coder:7b")
+	agent.clientType = api.OllamaClientType
+	agent.applyLocalModelSettings()
+
+	done := agent.warmUpLocalModel()
+	if done == nil {
+		t.Fatal("expected a warm-up for a local model")
+	}
+	<-done
+	if _, err := agent.client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "hi"}}, nil, ""); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if err := agent.UnloadLocalModel(); err != nil {
+		t.Fatalf("unload failed: %v", err)
+	}
+
+	// Warm-up, the keep-alive refresh after the request, then the unload
+	loads := requests.loads()
+	if len(loads) != 3 {
+		t.Fatalf("expected 3 load requests, got %v", loads)
+	}
+	for i, want := range []float64{-1, -1, 0} {
+		if loads[i]["model"] != "qwen2.5-coder:7b" || loads[i]["keep_alive"] != want || loads[i]["prompt"] != nil {
+			t.Errorf("load %d: expected keep_alive %v without a prompt, got %v", i, want, loads[i])
+		}
+	}
+
+	prefs[prefLocalWarmUp] = false
+	if agent.warmUpLocalModel() != nil {
+		t.Error("expected no warm-up with the preference off")
+	}
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain points HOME at a temporary directory, so tests that don't set
+// their own never write config or project state into the real ~/.coder. Go's
+// build and module caches stay where they were, for the tests that build
+// the coder binary.
+func TestMain(m *testing.M) {
+	realHome, _ := os.UserHomeDir()
+	if os.Getenv("GOCACHE") == "" {
+		if cacheDir, err := os.UserCacheDir(); err == nil {
+			os.Setenv("GOCACHE", filepath.Join(cacheDir, "go-build"))
+		}
+	}
+	if os.Getenv("GOPATH") == "" && realHome != "" {
+		os.Setenv("GOPATH", filepath.Join(realHome, "go"))
+	}
+
+	home, err := os.MkdirTemp("", "coder-agent-test-home-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create a test home directory: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("USERPROFILE", home) // os.UserHomeDir on Windows
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
@@ -0,0 +1,13 @@
+package agent
+
+// MetricsRecorder receives usage events from the agent, for example to export
+// them from a long-running service
+type MetricsRecorder interface {
+	RecordRequest(provider, model string, promptTokens, completionTokens int, cost float64, err error)
+	RecordToolExecution(provider, model, tool string, err error)
+}
+
+// SetMetricsRecorder installs a recorder for provider requests and tool executions
+func (a *Agent) SetMetricsRecorder(recorder MetricsRecorder) {
+	a.metrics = recorder
+}
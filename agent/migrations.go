@@ -0,0 +1,458 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+// prefMigrationDialect overrides the detected database of the migrations
+const prefMigrationDialect = "migration_dialect"
+
+// Migration tools
+const (
+	MigrationGoose         = "goose"
+	MigrationGolangMigrate = "golang-migrate"
+	MigrationAlembic       = "alembic"
+)
+
+// MigrationTool is the migration tool a project uses and where its migrations live
+type MigrationTool struct {
+	Name    string // goose, golang-migrate or alembic
+	Dir     string // migrations directory, relative to the project root
+	Dialect string // postgres or mysql
+}
+
+// migrationFileName matches a versioned migration: 0001_name.sql, 20240101120000_name.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_([\w-]+?)(\.up|\.down)?\.sql$`)
+
+// DetectMigrationTool finds the migration tool of the project in root from
+// alembic.ini, the existing migration files, or go.mod
+func DetectMigrationTool(root string) (*MigrationTool, error) {
+	tool := &MigrationTool{Dialect: detectMigrationDialect(root)}
+
+	if data, err := os.ReadFile(filepath.Join(root, "alembic.ini")); err == nil {
+		location := "alembic"
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(key) == "script_location" {
+				location = strings.TrimPrefix(strings.TrimSpace(value), "%(here)s/")
+			}
+		}
+		tool.Name, tool.Dir = MigrationAlembic, filepath.Join(location, "versions")
+		return tool, nil
+	}
+
+	files, err := listWorkspaceFiles(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project files: %w", err)
+	}
+	// Count migration files per tool and directory; the biggest set wins
+	counts := make(map[[2]string]int)
+	for _, file := range files {
+		m := migrationFileName.FindStringSubmatch(filepath.Base(file))
+		if m == nil {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if m[3] != "" {
+			counts[[2]string{MigrationGolangMigrate, dir}]++
+		} else if data, err := os.ReadFile(filepath.Join(root, file)); err == nil && strings.Contains(string(data), "+goose Up") {
+			counts[[2]string{MigrationGoose, dir}]++
+		}
+	}
+	best := 0
+	for key, count := range counts {
+		if count > best || (count == best && key[1] < tool.Dir) {
+			best, tool.Name, tool.Dir = count, key[0], key[1]
+		}
+	}
+	if tool.Name != "" {
+		return tool, nil
+	}
+
+	// A new project: the dependency names the tool, the directory is conventional
+	goMod, _ := os.ReadFile(filepath.Join(root, "go.mod"))
+	switch {
+	case strings.Contains(string(goMod), "github.com/pressly/goose"):
+		tool.Name = MigrationGoose
+	case strings.Contains(string(goMod), "github.com/golang-migrate/migrate"):
+		tool.Name = MigrationGolangMigrate
+	default:
+		return nil, fmt.Errorf("no migration tool found - coder supports goose, golang-migrate and alembic")
+	}
+	tool.Dir = "migrations"
+	if info, err := os.Stat(filepath.Join(root, "db", "migrations")); err == nil && info.IsDir() {
+		tool.Dir = filepath.Join("db", "migrations")
+	}
+	return tool, nil
+}
+
+// detectMigrationDialect guesses the project's database from its drivers and
+// docker-compose services; postgres unless MySQL shows up
+func detectMigrationDialect(root string) string {
+	for _, name := range []string{"go.mod", "requirements.txt", "pyproject.toml", "docker-compose.yml", "docker-compose.yaml", "compose.yaml"} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		content := strings.ToLower(string(data))
+		for _, marker := range []string{"go-sql-driver/mysql", "pymysql", "mysqlclient", "image: mysql", "image: mariadb"} {
+			if strings.Contains(content, marker) {
+				return "mysql"
+			}
+		}
+	}
+	return "postgres"
+}
+
+// existingMigrations returns the migration files in the tool's directory, oldest first
+func (m *MigrationTool) existingMigrations(root string) []string {
+	entries, err := os.ReadDir(filepath.Join(root, m.Dir))
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if m.Name == MigrationAlembic && strings.HasSuffix(name, ".py") && name != "__init__.py" ||
+			m.Name != MigrationAlembic && migrationFileName.MatchString(name) {
+			files = append(files, filepath.Join(m.Dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// nextVersion continues the project's numbering: sequential numbers of the same
+// width when the existing versions are short, a UTC timestamp otherwise
+func (m *MigrationTool) nextVersion(existing []string, now time.Time) string {
+	width, highest := 0, int64(0)
+	for _, file := range existing {
+		match := migrationFileName.FindStringSubmatch(filepath.Base(file))
+		if match == nil {
+			continue
+		}
+		if len(match[1]) >= 14 {
+			return now.UTC().Format("20060102150405")
+		}
+		n, _ := strconv.ParseInt(match[1], 10, 64)
+		if n > highest {
+			highest = n
+		}
+		if len(match[1]) > width {
+			width = len(match[1])
+		}
+	}
+	if width == 0 {
+		return now.UTC().Format("20060102150405")
+	}
+	return fmt.Sprintf("%0*d", width, highest+1)
+}
+
+// alembicRevision matches the revision identifiers of an alembic revision file
+var alembicRevision = regexp.MustCompile(`(?m)^(down_revision|revision)\s*(?::[^=]+)?=\s*(.+)$`)
+
+// quotedString matches a quoted Python string
+var quotedString = regexp.MustCompile(`['"]([^'"]+)['"]`)
+
+// nonSlugChars matches what a migration file name can't contain
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// alembicHead returns the revision no other revision builds on, or "" for none
+func alembicHead(root string, files []string) string {
+	revisions := make(map[string]bool)
+	parents := make(map[string]bool)
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			continue
+		}
+		for _, match := range alembicRevision.FindAllStringSubmatch(string(data), -1) {
+			for _, id := range quotedString.FindAllStringSubmatch(match[2], -1) {
+				if match[1] == "revision" {
+					revisions[id[1]] = true
+				} else {
+					parents[id[1]] = true
+				}
+			}
+		}
+	}
+	var heads []string
+	for revision := range revisions {
+		if !parents[revision] {
+			heads = append(heads, revision)
+		}
+	}
+	sort.Strings(heads)
+	if len(heads) == 0 {
+		return ""
+	}
+	return heads[0]
+}
+
+// Migration is a generated migration and the files holding it
+type Migration struct {
+	Tool    *MigrationTool
+	Name    string
+	Summary string
+	Up      string
+	Down    string
+	Files   map[string]string // path -> content
+}
+
+// migrationSlug turns a name into a file name part: lower case words joined by _
+func migrationSlug(name string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "_")
+	}
+	if slug == "" {
+		slug = "migration"
+	}
+	return slug
+}
+
+// Layout lays out the migration's up and down steps as the tool's files:
+// an .up.sql/.down.sql pair for golang-migrate, one annotated .sql file for
+// goose and a revision module for alembic
+func (m *MigrationTool) Layout(root string, migration *Migration, now time.Time) map[string]string {
+	existing := m.existingMigrations(root)
+	slug := migrationSlug(migration.Name)
+	up := strings.TrimSpace(migration.Up) + "\n"
+	down := strings.TrimSpace(migration.Down) + "\n"
+
+	switch m.Name {
+	case MigrationGolangMigrate:
+		base := filepath.Join(m.Dir, m.nextVersion(existing, now)+"_"+slug)
+		return map[string]string{base + ".up.sql": up, base + ".down.sql": down}
+	case MigrationGoose:
+		content := "-- +goose Up\n-- +goose StatementBegin\n" + up + "-- +goose StatementEnd\n\n" +
+			"-- +goose Down\n-- +goose StatementBegin\n" + down + "-- +goose StatementEnd\n"
+		return map[string]string{filepath.Join(m.Dir, m.nextVersion(existing, now)+"_"+slug+".sql"): content}
+	}
+
+	id := make([]byte, 6)
+	rand.Read(id)
+	revision := hex.EncodeToString(id)
+	downRevision := "None"
+	if head := alembicHead(root, existing); head != "" {
+		downRevision = "'" + head + "'"
+	}
+	indent := func(body string) string {
+		lines := strings.Split(strings.TrimRight(body, "\n"), "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = "    " + line
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	content := fmt.Sprintf(`"""%s
+
+Revision ID: %s
+Revises: %s
+Create Date: %s
+
+"""
+from alembic import op
+import sqlalchemy as sa
+
+
+# revision identifiers, used by Alembic.
+revision = '%s'
+down_revision = %s
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+%s
+
+
+def downgrade():
+%s
+`, migration.Summary, revision, strings.Trim(downRevision, "'"), now.Format("2006-01-02 15:04:05.000000"),
+		revision, downRevision, indent(up), indent(down))
+	return map[string]string{filepath.Join(m.Dir, revision+"_"+slug+".py"): content}
+}
+
+// migrationSchema is the structured answer of a migration request
+var migrationSchema = api.ResponseSchema{
+	Name: "migration",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":    map[string]interface{}{"type": "string", "description": "Short snake_case name of the migration, e.g. add_users_email_index"},
+			"summary": map[string]interface{}{"type": "string", "description": "One sentence describing the schema change"},
+			"up":      map[string]interface{}{"type": "string", "description": "Statements applying the change"},
+			"down":    map[string]interface{}{"type": "string", "description": "Statements reverting exactly what up does"},
+		},
+		"required":             []string{"name", "summary", "up", "down"},
+		"additionalProperties": false,
+	},
+}
+
+// maxMigrationContext caps how much of each recent migration the model sees
+const maxMigrationContext = 3000
+
+// GenerateMigration asks the model for the up and down steps of a described
+// schema change and writes them as a new migration of the project's tool
+func (a *Agent) GenerateMigration(tool *MigrationTool, description string) (*Migration, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	existing := tool.existingMigrations(wd)
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Write a database migration for this schema change:\n%s\n\n", description)
+	fmt.Fprintf(&prompt, "The project uses %s with a %s database.\n", tool.Name, tool.Dialect)
+	if tool.Name == MigrationAlembic {
+		prompt.WriteString("Give up and down as the Python bodies of upgrade() and downgrade(), using op.* and sa.* (alembic and sqlalchemy are imported), without the def lines or indentation.\n")
+	} else {
+		fmt.Fprintf(&prompt, "Give up and down as plain %s SQL statements ending in semicolons, without tool annotations.\n", tool.Dialect)
+	}
+	prompt.WriteString("down must revert exactly what up does, so running up, down and up again succeeds.\n")
+	if len(existing) > 0 {
+		recent := existing
+		if len(recent) > 3 {
+			recent = recent[len(recent)-3:]
+		}
+		fmt.Fprintf(&prompt, "\nThere are %d existing migrations. The most recent, showing the current schema and conventions:\n", len(existing))
+		for _, file := range recent {
+			if data, err := os.ReadFile(filepath.Join(wd, file)); err == nil {
+				fmt.Fprintf(&prompt, "\n--- %s ---\n%s\n", file, truncateTranscript(string(data), maxMigrationContext))
+			}
+		}
+	}
+
+	var answer struct {
+		Name    string `json:"name"`
+		Summary string `json:"summary"`
+		Up      string `json:"up"`
+		Down    string `json:"down"`
+	}
+	if err := a.GenerateStructured(prompt.String(), migrationSchema, &answer); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(answer.Up) == "" {
+		return nil, fmt.Errorf("the model returned an empty migration")
+	}
+
+	migration := &Migration{Tool: tool, Name: answer.Name, Summary: answer.Summary, Up: answer.Up, Down: answer.Down}
+	migration.Files = tool.Layout(wd, migration, time.Now())
+	if err := os.MkdirAll(filepath.Join(wd, tool.Dir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", tool.Dir, err)
+	}
+	for path, content := range migration.Files {
+		if err := os.WriteFile(filepath.Join(wd, path), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		a.recordFileChange(tools.DescribeChange(path, nil, []byte(content), false), fmt.Sprintf("Created migration %s", path), nil)
+	}
+	return migration, nil
+}
+
+// MigrationCheck is one step of verifying a migration
+type MigrationCheck struct {
+	Step    string
+	Command string
+	Output  string
+	Err     error
+	Skipped bool // the tool the step needs isn't installed
+}
+
+// migrationCommands returns the commands applying, reverting and re-applying
+// the migrations against a database, and the binary they need
+func (m *MigrationTool) migrationCommands(db *tools.DisposableDatabase) (binary string, steps [][2]string) {
+	dir := tools.ShellQuote(m.Dir)
+	switch m.Name {
+	case MigrationGolangMigrate:
+		base := fmt.Sprintf("migrate -path %s -database %s", dir, tools.ShellQuote(db.URL("postgres")))
+		return "migrate", [][2]string{{"apply all migrations", base + " up"}, {"revert the new migration", base + " down 1"}, {"re-apply it", base + " up"}}
+	case MigrationGoose:
+		base := fmt.Sprintf("goose -dir %s %s %s", dir, db.Dialect, tools.ShellQuote(db.DSN()))
+		return "goose", [][2]string{{"apply all migrations", base + " up"}, {"revert the new migration", base + " down"}, {"re-apply it", base + " up"}}
+	}
+	scheme := "postgresql"
+	if db.Dialect == "mysql" {
+		scheme = "mysql+pymysql"
+	}
+	// env.py usually reads the URL from DATABASE_URL or -x url=...
+	url := tools.ShellQuote(db.URL(scheme))
+	base := fmt.Sprintf("DATABASE_URL=%s alembic -x url=%s", url, url)
+	return "alembic", [][2]string{{"apply all migrations", base + " upgrade head"}, {"revert the new migration", base + " downgrade -1"}, {"re-apply it", base + " upgrade head"}}
+}
+
+// VerifyMigration runs the project's migrations against a disposable database,
+// reverts and re-applies the newest one, and checks the code still compiles
+// against the schema: sqlc compile where sqlc is used, then the project build
+func (a *Agent) VerifyMigration(tool *MigrationTool, dialect string) []MigrationCheck {
+	var checks []MigrationCheck
+	wd, _ := os.Getwd()
+	if dialect == "" {
+		dialect = tool.Dialect
+		if a.configManager != nil {
+			dialect = a.configManager.GetConfig().GetStringPreference(prefMigrationDialect, dialect)
+		}
+	}
+
+	db, err := tools.StartDisposableDatabase(dialect)
+	if err != nil {
+		checks = append(checks, MigrationCheck{Step: "start a disposable " + dialect + " database", Err: err, Skipped: true})
+	} else {
+		defer db.Stop()
+		binary, steps := tool.migrationCommands(db)
+		_, missing := exec.LookPath(binary)
+		for _, step := range steps {
+			check := MigrationCheck{Step: step[0], Command: step[1]}
+			if missing != nil {
+				check.Skipped, check.Err = true, fmt.Errorf("%s is not installed", binary)
+			} else {
+				check.Output, check.Err = tools.ExecuteShellCommand(a.operationContext(), step[1])
+			}
+			checks = append(checks, check)
+			if check.Err != nil {
+				break
+			}
+		}
+	}
+
+	for _, config := range []string{"sqlc.yaml", "sqlc.yml", "sqlc.json"} {
+		if _, err := os.Stat(filepath.Join(wd, config)); err == nil {
+			check := MigrationCheck{Step: "check the queries against the schema", Command: "sqlc compile"}
+			if tools.CommandAvailable(check.Command) {
+				check.Output, check.Err = tools.ExecuteShellCommand(a.operationContext(), check.Command)
+			} else {
+				check.Skipped, check.Err = true, fmt.Errorf("sqlc is not installed")
+			}
+			checks = append(checks, check)
+			break
+		}
+	}
+	for _, pack := range tools.DetectLanguagePacks(wd) {
+		build := pack.BuildCommand()
+		if build == "" || !tools.CommandAvailable(build) {
+			continue
+		}
+		check := MigrationCheck{Step: "build the models", Command: build}
+		check.Output, check.Err = tools.ExecuteShellCommand(a.operationContext(), build)
+		checks = append(checks, check)
+		break
+	}
+	return checks
+}
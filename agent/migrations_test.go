@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeMigrationFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectMigrationTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		tool    string
+		dir     string
+		dialect string
+	}{
+		{
+			name: "golang-migrate pairs",
+			files: map[string]string{
+				"db/migrations/000001_init.up.sql":   "CREATE TABLE users (id int);",
+				"db/migrations/000001_init.down.sql": "DROP TABLE users;",
+				"go.mod":                             "module x\n\nrequire github.com/go-sql-driver/mysql v1.7.0\n",
+			},
+			tool: MigrationGolangMigrate, dir: filepath.Join("db", "migrations"), dialect: "mysql",
+		},
+		{
+			name: "goose annotations",
+			files: map[string]string{
+				"sql/20240101120000_init.sql": "-- +goose Up\nCREATE TABLE users (id int);\n-- +goose Down\nDROP TABLE users;\n",
+				"sql/0001_notes.sql":          "not a migration",
+			},
+			tool: MigrationGoose, dir: "sql", dialect: "postgres",
+		},
+		{
+			name: "alembic",
+			files: map[string]string{
+				"alembic.ini":      "[alembic]\nscript_location = %(here)s/migrations\n",
+				"requirements.txt": "alembic\npsycopg2\n",
+			},
+			tool: MigrationAlembic, dir: filepath.Join("migrations", "versions"), dialect: "postgres",
+		},
+		{
+			name:  "goose dependency without migrations",
+			files: map[string]string{"go.mod": "module x\n\nrequire github.com/pressly/goose/v3 v3.20.0\n"},
+			tool:  MigrationGoose, dir: "migrations", dialect: "postgres",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeMigrationFiles(t, root, tt.files)
+			tool, err := DetectMigrationTool(root)
+			if err != nil {
+				t.Fatalf("DetectMigrationTool: %v", err)
+			}
+			if tool.Name != tt.tool || tool.Dir != tt.dir || tool.Dialect != tt.dialect {
+				t.Errorf("got %+v, want %s in %s (%s)", tool, tt.tool, tt.dir, tt.dialect)
+			}
+		})
+	}
+
+	if _, err := DetectMigrationTool(t.TempDir()); err == nil {
+		t.Error("expected an error for a project without migrations")
+	}
+}
+
+func TestMigrationLayout(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	migration := &Migration{Name: "Add users email!", Summary: "Add an email column", Up: "ALTER TABLE users ADD COLUMN email text;", Down: "ALTER TABLE users DROP COLUMN email;"}
+
+	root := t.TempDir()
+	writeMigrationFiles(t, root, map[string]string{
+		"migrations/0001_init.up.sql":    "",
+		"migrations/0001_init.down.sql":  "",
+		"migrations/0009_later.up.sql":   "",
+		"migrations/0009_later.down.sql": "",
+	})
+	files := (&MigrationTool{Name: MigrationGolangMigrate, Dir: "migrations"}).Layout(root, migration, now)
+	up := filepath.Join("migrations", "0010_add_users_email.up.sql")
+	down := filepath.Join("migrations", "0010_add_users_email.down.sql")
+	if files[up] != migration.Up+"\n" || files[down] != migration.Down+"\n" || len(files) != 2 {
+		t.Errorf("golang-migrate layout = %v", files)
+	}
+
+	files = (&MigrationTool{Name: MigrationGoose, Dir: "db"}).Layout(t.TempDir(), migration, now)
+	content, ok := files[filepath.Join("db", "20240506070809_add_users_email.sql")]
+	if !ok || len(files) != 1 {
+		t.Fatalf("goose layout = %v", files)
+	}
+	if !strings.Contains(content, "-- +goose Up") || strings.Index(content, migration.Up) > strings.Index(content, "-- +goose Down") {
+		t.Errorf("goose file doesn't hold up before down:\n%s", content)
+	}
+
+	root = t.TempDir()
+	writeMigrationFiles(t, root, map[string]string{
+		"versions/a1_init.py": "revision = 'a1'\ndown_revision = None\n",
+		"versions/b2_next.py": "revision: str = 'b2'\ndown_revision: Union[str, None] = 'a1'\n",
+	})
+	alembic := &Migration{Name: "add email", Summary: "Add email", Up: "op.add_column('users', sa.Column('email', sa.Text()))", Down: "op.drop_column('users', 'email')"}
+	files = (&MigrationTool{Name: MigrationAlembic, Dir: "versions"}).Layout(root, alembic, now)
+	if len(files) != 1 {
+		t.Fatalf("alembic layout = %v", files)
+	}
+	for path, content := range files {
+		if !strings.HasSuffix(path, "_add_email.py") {
+			t.Errorf("alembic file name = %s", path)
+		}
+		for _, want := range []string{"down_revision = 'b2'", "def upgrade():\n    op.add_column", "def downgrade():\n    op.drop_column"} {
+			if !strings.Contains(content, want) {
+				t.Errorf("alembic revision missing %q:\n%s", want, content)
+			}
+		}
+	}
+}
+
+func TestMigrationNextVersionSwitchesToTimestamps(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	tool := &MigrationTool{Name: MigrationGoose}
+	if got := tool.nextVersion([]string{"20230101000000_init.sql", "0002_x.sql"}, now); got != "20240506070809" {
+		t.Errorf("timestamped project: got %s", got)
+	}
+	if got := tool.nextVersion(nil, now); got != "20240506070809" {
+		t.Errorf("empty project: got %s", got)
+	}
+	if got := tool.nextVersion([]string{"1_init.sql", "2_x.sql"}, now); got != "3" {
+		t.Errorf("unpadded sequence: got %s", got)
+	}
+}
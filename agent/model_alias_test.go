@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestResolveModelAlias(t *testing.T) {
+	if got := api.ResolveModelAlias(api.GroqClientType, "llama3-70b-8192"); got != "llama-3.3-70b-versatile" {
+		t.Errorf("expected the retired Groq model to be remapped, got %s", got)
+	}
+	if got := api.ResolveModelAlias(api.OpenRouterClientType, "deepseek/deepseek-chat-v3-0324:free"); got != "deepseek/deepseek-chat-v3.1:free" {
+		t.Errorf("expected the removed free variant to be remapped, got %s", got)
+	}
+	// Aliases are per provider, and current IDs pass through
+	if got := api.ResolveModelAlias(api.OpenRouterClientType, "llama3-70b-8192"); got != "llama3-70b-8192" {
+		t.Errorf("expected another provider's alias to be ignored, got %s", got)
+	}
+	if got := api.ResolveModelAlias(api.GroqClientType, "qwen/qwen3-32b"); got != "qwen/qwen3-32b" {
+		t.Errorf("expected a current model to be unchanged, got %s", got)
+	}
+
+	t.Setenv("GROQ_API_KEY", "test-key")
+	client, err := api.NewUnifiedClientWithModel(api.GroqClientType, "llama3-8b-8192")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	if client.GetModel() != "llama-3.1-8b-instant" {
+		t.Errorf("expected the client to use the replacement model, got %s", client.GetModel())
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/types"
+)
+
+func TestGetModelProfile(t *testing.T) {
+	cfg := &config.Config{ModelProfiles: map[string]map[string]interface{}{
+		"*":                           {"temperature": 0.7},
+		"deepseek/*":                  {"temperature": 0.3, "top_p": 0.9},
+		"deepseek/deepseek-chat-v3.1": {"temperature": 0.1, "provider": map[string]interface{}{"order": []string{"DeepInfra"}}},
+	}}
+
+	profile := cfg.GetModelProfile("deepseek/deepseek-chat-v3.1")
+	if profile["temperature"] != 0.1 || profile["top_p"] != 0.9 || profile["provider"] == nil {
+		t.Errorf("expected exact profile to override the pattern profiles, got %v", profile)
+	}
+	if profile := cfg.GetModelProfile("qwen/qwen3-coder"); profile["temperature"] != 0.7 || profile["top_p"] != nil {
+		t.Errorf("expected only the catch-all profile, got %v", profile)
+	}
+	if profile := (&config.Config{}).GetModelProfile("any"); profile != nil {
+		t.Errorf("expected no profile without configuration, got %v", profile)
+	}
+}
+
+func TestMergeRequestParameters(t *testing.T) {
+	body := map[string]interface{}{"model": "m", "max_tokens": 4000, "temperature": 0.7}
+	types.MergeRequestParameters(body, map[string]interface{}{
+		"model":       "other",
+		"temperature": 0.2,
+		"stop":        []string{"</done>"},
+		"max_tokens":  float64(8000),
+	})
+	if body["model"] != "m" || body["temperature"] != 0.2 || body["stop"] == nil {
+		t.Errorf("unexpected merge result: %v", body)
+	}
+	if body["max_tokens"] != 4000 {
+		t.Errorf("expected max_tokens to stay at the context-aware 4000, got %v", body["max_tokens"])
+	}
+
+	types.MergeRequestParameters(body, map[string]interface{}{"max_tokens": float64(1024)})
+	if body["max_tokens"] != 1024 {
+		t.Errorf("expected the profile to lower max_tokens to 1024, got %v", body["max_tokens"])
+	}
+}
+
+func TestOpenRouterVariants(t *testing.T) {
+	models := []api.ModelInfo{
+		{ID: "deepseek/deepseek-chat-v3.1", InputCost: 0.0000002, OutputCost: 0.0000008, ContextLength: 163840},
+	}
+
+	nitro, ok := api.FindModel(models, "deepseek/deepseek-chat-v3.1:nitro")
+	if !ok || nitro.ContextLength != 163840 || nitro.InputCost == 0 {
+		t.Errorf("expected :nitro to resolve to the priced base model, got %+v, %v", nitro, ok)
+	}
+	free, ok := api.FindModel(models, "deepseek/deepseek-chat-v3.1:free")
+	if !ok || free.InputCost != 0 || free.OutputCost != 0 || free.ContextLength != 163840 {
+		t.Errorf("expected :free to resolve to a zero-cost base model, got %+v, %v", free, ok)
+	}
+	if _, ok := api.FindModel(models, "unknown/model:nitro"); ok {
+		t.Error("expected an unknown base model not to be found")
+	}
+}
+
+func TestWithOpenRouterRouting(t *testing.T) {
+	defaults := map[string]interface{}{"order": []interface{}{"DeepInfra"}, "allow_fallbacks": false, "data_collection": "deny"}
+
+	profile := withOpenRouterRouting(nil, defaults, "m")
+	if profile["provider"] == nil {
+		t.Errorf("expected default routing to be applied, got %v", profile)
+	}
+
+	own := map[string]interface{}{"provider": map[string]interface{}{"sort": "throughput"}}
+	if profile := withOpenRouterRouting(own, defaults, "m"); profile["provider"].(map[string]interface{})["sort"] != "throughput" {
+		t.Errorf("expected the model's own routing to win, got %v", profile)
+	}
+
+	bad := map[string]interface{}{"provider": map[string]interface{}{"data_collection": "maybe"}, "temperature": 0.2}
+	if profile := withOpenRouterRouting(bad, nil, "m"); profile["provider"] != nil || profile["temperature"] != 0.2 {
+		t.Errorf("expected invalid routing to be dropped and the rest kept, got %v", profile)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/providers"
 )
 
 // GetModel gets the current model being used by the agent
@@ -33,40 +34,61 @@ func (a *Agent) SetModel(model string) error {
 	
 	// Check if we need to switch providers
 	if requiredProvider != a.clientType {
-		if a.debug {
-			a.debugLog("🔄 Switching from %s to %s for model %s\n", 
-				api.GetProviderName(a.clientType), api.GetProviderName(requiredProvider), model)
-		}
-		
-		// Create a new client with the required provider
-		newClient, err := api.NewUnifiedClientWithModel(requiredProvider, model)
-		if err != nil {
-			return fmt.Errorf("failed to create client for provider %s: %w", api.GetProviderName(requiredProvider), err)
+		if err := a.switchClient(requiredProvider, model); err != nil {
+			return err
 		}
-		
-		// Set debug mode on the new client
-		newClient.SetDebug(a.debug)
-		
-		// Check connection
-		if err := newClient.CheckConnection(); err != nil {
-			return fmt.Errorf("connection check failed for provider %s: %w", api.GetProviderName(requiredProvider), err)
-		}
-		
-		// Switch to the new client
-		a.client = newClient
-		a.clientType = requiredProvider
 	} else {
 		// Same provider, just update the model
 		if err := a.client.SetModel(model); err != nil {
 			return fmt.Errorf("failed to set model on client: %w", err)
 		}
 	}
+	return a.modelChanged(requiredProvider, model)
+}
+
+// SetProvider switches to a provider with a new client and persists the
+// choice. An empty model uses the model configured for the provider.
+func (a *Agent) SetProvider(provider api.ClientType, model string) error {
+	if model == "" {
+		model = a.configManager.GetModelForProvider(provider)
+	}
+	if err := a.switchClient(provider, model); err != nil {
+		return err
+	}
+	return a.modelChanged(provider, model)
+}
+
+// switchClient replaces the client with one for provider, once it passes
+// the provider's connection check
+func (a *Agent) switchClient(provider api.ClientType, model string) error {
+	if a.debug {
+		a.debugLog("🔄 Switching from %s to %s for model %s\n", 
+			api.GetProviderName(a.clientType), api.GetProviderName(provider), model)
+	}
 	
-	// Save to configuration
-	if err := a.configManager.SetProviderAndModel(requiredProvider, model); err != nil {
+	newClient, err := api.NewUnifiedClientWithModel(provider, model)
+	if err != nil {
+		return fmt.Errorf("failed to create client for provider %s: %w", api.GetProviderName(provider), err)
+	}
+	newClient.SetDebug(a.debug)
+	if err := newClient.CheckConnection(); err != nil {
+		return fmt.Errorf("connection check failed for provider %s: %w", api.GetProviderName(provider), err)
+	}
+	
+	a.client = newClient
+	a.clientType = provider
+	return nil
+}
+
+// modelChanged saves the selection and applies the new model's settings
+func (a *Agent) modelChanged(provider api.ClientType, model string) error {
+	if err := a.configManager.SetProviderAndModel(provider, model); err != nil {
 		return fmt.Errorf("failed to save model selection: %w", err)
 	}
 	
+	a.applyModelProfile()
+	a.applyLocalModelSettings()
+
 	// Update context limits for the new model
 	a.maxContextTokens = a.getModelContextLimit()
 	a.currentContextTokens = 0
@@ -83,6 +105,14 @@ func (a *Agent) determineProviderForModel(modelID string) (api.ClientType, error
 		api.CerebrasClientType,
 		api.GroqClientType,
 		api.DeepSeekClientType,
+		api.MistralClientType,
+		api.XAIClientType,
+		api.AzureOpenAIClientType,
+		api.BedrockClientType,
+		api.HuggingFaceClientType,
+		api.AnthropicClientType,
+		api.OpenAIClientType,
+		api.GeminiClientType,
 		api.OllamaClientType,      // Check Ollama last as it's local
 	}
 	
@@ -120,14 +150,12 @@ func (a *Agent) determineProviderForModel(modelID string) (api.ClientType, error
 			a.debugLog("✅ Got %d models from %s\n", len(models), api.GetProviderName(provider))
 		}
 		
-		// Check if this provider has the model
-		for _, model := range models {
-			if model.ID == modelID {
-				if a.debug {
-					a.debugLog("🎉 Found model %s in provider %s\n", modelID, api.GetProviderName(provider))
-				}
-				return provider, nil
+		// Check if this provider has the model (or, for OpenRouter variants, its base model)
+		if _, found := api.FindModel(models, modelID); found {
+			if a.debug {
+				a.debugLog("🎉 Found model %s in provider %s\n", modelID, api.GetProviderName(provider))
 			}
+			return provider, nil
 		}
 		
 		if a.debug {
@@ -138,213 +166,12 @@ func (a *Agent) determineProviderForModel(modelID string) (api.ClientType, error
 	return "", fmt.Errorf("model %s not found in any available provider", modelID)
 }
 
-// getModelsForProvider gets models for a specific provider without environment manipulation
+// getModelsForProvider gets models for a specific provider
 func (a *Agent) getModelsForProvider(provider api.ClientType) ([]api.ModelInfo, error) {
-	// Check if provider is available first
 	if !a.isProviderAvailable(provider) {
 		return nil, fmt.Errorf("provider %s not available", api.GetProviderName(provider))
 	}
-	
-	// For each provider, directly call the appropriate function based on current environment
-	// This avoids the complexity of environment manipulation
-	switch provider {
-	case api.OpenRouterClientType:
-		if os.Getenv("OPENROUTER_API_KEY") != "" {
-			// Backup all other keys temporarily 
-			deepinfraKey := os.Getenv("DEEPINFRA_API_KEY")
-			cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-			groqKey := os.Getenv("GROQ_API_KEY")
-			deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
-			
-			// Clear other keys temporarily
-			os.Unsetenv("DEEPINFRA_API_KEY")
-			os.Unsetenv("CEREBRAS_API_KEY")
-			os.Unsetenv("GROQ_API_KEY")
-			os.Unsetenv("DEEPSEEK_API_KEY")
-			
-			// Get OpenRouter models
-			models, err := api.GetAvailableModels()
-			
-			// Restore other keys
-			if deepinfraKey != "" {
-				os.Setenv("DEEPINFRA_API_KEY", deepinfraKey)
-			}
-			if cerebrasKey != "" {
-				os.Setenv("CEREBRAS_API_KEY", cerebrasKey)
-			}
-			if groqKey != "" {
-				os.Setenv("GROQ_API_KEY", groqKey)
-			}
-			if deepseekKey != "" {
-				os.Setenv("DEEPSEEK_API_KEY", deepseekKey)
-			}
-			
-			return models, err
-		}
-		return nil, fmt.Errorf("OPENROUTER_API_KEY not set")
-		
-	case api.DeepInfraClientType:
-		if os.Getenv("DEEPINFRA_API_KEY") != "" {
-			// Similar approach for DeepInfra
-			openrouterKey := os.Getenv("OPENROUTER_API_KEY")
-			cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-			groqKey := os.Getenv("GROQ_API_KEY")
-			deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
-			
-			os.Unsetenv("OPENROUTER_API_KEY")
-			os.Unsetenv("CEREBRAS_API_KEY")
-			os.Unsetenv("GROQ_API_KEY")
-			os.Unsetenv("DEEPSEEK_API_KEY")
-			
-			models, err := api.GetAvailableModels()
-			
-			if openrouterKey != "" {
-				os.Setenv("OPENROUTER_API_KEY", openrouterKey)
-			}
-			if cerebrasKey != "" {
-				os.Setenv("CEREBRAS_API_KEY", cerebrasKey)
-			}
-			if groqKey != "" {
-				os.Setenv("GROQ_API_KEY", groqKey)
-			}
-			if deepseekKey != "" {
-				os.Setenv("DEEPSEEK_API_KEY", deepseekKey)
-			}
-			
-			return models, err
-		}
-		return nil, fmt.Errorf("DEEPINFRA_API_KEY not set")
-		
-	case api.CerebrasClientType:
-		if os.Getenv("CEREBRAS_API_KEY") != "" {
-			openrouterKey := os.Getenv("OPENROUTER_API_KEY")
-			deepinfraKey := os.Getenv("DEEPINFRA_API_KEY")
-			groqKey := os.Getenv("GROQ_API_KEY")
-			deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
-			
-			os.Unsetenv("OPENROUTER_API_KEY")
-			os.Unsetenv("DEEPINFRA_API_KEY")
-			os.Unsetenv("GROQ_API_KEY")
-			os.Unsetenv("DEEPSEEK_API_KEY")
-			
-			models, err := api.GetAvailableModels()
-			
-			if openrouterKey != "" {
-				os.Setenv("OPENROUTER_API_KEY", openrouterKey)
-			}
-			if deepinfraKey != "" {
-				os.Setenv("DEEPINFRA_API_KEY", deepinfraKey)
-			}
-			if groqKey != "" {
-				os.Setenv("GROQ_API_KEY", groqKey)
-			}
-			if deepseekKey != "" {
-				os.Setenv("DEEPSEEK_API_KEY", deepseekKey)
-			}
-			
-			return models, err
-		}
-		return nil, fmt.Errorf("CEREBRAS_API_KEY not set")
-		
-	case api.GroqClientType:
-		if os.Getenv("GROQ_API_KEY") != "" {
-			openrouterKey := os.Getenv("OPENROUTER_API_KEY")
-			deepinfraKey := os.Getenv("DEEPINFRA_API_KEY")
-			cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-			deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
-			
-			os.Unsetenv("OPENROUTER_API_KEY")
-			os.Unsetenv("DEEPINFRA_API_KEY")
-			os.Unsetenv("CEREBRAS_API_KEY")
-			os.Unsetenv("DEEPSEEK_API_KEY")
-			
-			models, err := api.GetAvailableModels()
-			
-			if openrouterKey != "" {
-				os.Setenv("OPENROUTER_API_KEY", openrouterKey)
-			}
-			if deepinfraKey != "" {
-				os.Setenv("DEEPINFRA_API_KEY", deepinfraKey)
-			}
-			if cerebrasKey != "" {
-				os.Setenv("CEREBRAS_API_KEY", cerebrasKey)
-			}
-			if deepseekKey != "" {
-				os.Setenv("DEEPSEEK_API_KEY", deepseekKey)
-			}
-			
-			return models, err
-		}
-		return nil, fmt.Errorf("GROQ_API_KEY not set")
-		
-	case api.DeepSeekClientType:
-		if os.Getenv("DEEPSEEK_API_KEY") != "" {
-			openrouterKey := os.Getenv("OPENROUTER_API_KEY")
-			deepinfraKey := os.Getenv("DEEPINFRA_API_KEY")
-			cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-			groqKey := os.Getenv("GROQ_API_KEY")
-			
-			os.Unsetenv("OPENROUTER_API_KEY")
-			os.Unsetenv("DEEPINFRA_API_KEY")
-			os.Unsetenv("CEREBRAS_API_KEY")
-			os.Unsetenv("GROQ_API_KEY")
-			
-			models, err := api.GetAvailableModels()
-			
-			if openrouterKey != "" {
-				os.Setenv("OPENROUTER_API_KEY", openrouterKey)
-			}
-			if deepinfraKey != "" {
-				os.Setenv("DEEPINFRA_API_KEY", deepinfraKey)
-			}
-			if cerebrasKey != "" {
-				os.Setenv("CEREBRAS_API_KEY", cerebrasKey)
-			}
-			if groqKey != "" {
-				os.Setenv("GROQ_API_KEY", groqKey)
-			}
-			
-			return models, err
-		}
-		return nil, fmt.Errorf("DEEPSEEK_API_KEY not set")
-		
-	case api.OllamaClientType:
-		// For Ollama, we need to clear API keys to ensure it's selected
-		openrouterKey := os.Getenv("OPENROUTER_API_KEY")
-		deepinfraKey := os.Getenv("DEEPINFRA_API_KEY")
-		cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-		groqKey := os.Getenv("GROQ_API_KEY")
-		deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
-		
-		os.Unsetenv("OPENROUTER_API_KEY")
-		os.Unsetenv("DEEPINFRA_API_KEY")
-		os.Unsetenv("CEREBRAS_API_KEY")
-		os.Unsetenv("GROQ_API_KEY")
-		os.Unsetenv("DEEPSEEK_API_KEY")
-		
-		models, err := api.GetAvailableModels()
-		
-		if openrouterKey != "" {
-			os.Setenv("OPENROUTER_API_KEY", openrouterKey)
-		}
-		if deepinfraKey != "" {
-			os.Setenv("DEEPINFRA_API_KEY", deepinfraKey)
-		}
-		if cerebrasKey != "" {
-			os.Setenv("CEREBRAS_API_KEY", cerebrasKey)
-		}
-		if groqKey != "" {
-			os.Setenv("GROQ_API_KEY", groqKey)
-		}
-		if deepseekKey != "" {
-			os.Setenv("DEEPSEEK_API_KEY", deepseekKey)
-		}
-		
-		return models, err
-		
-	default:
-		return nil, fmt.Errorf("unknown provider type: %s", provider)
-	}
+	return api.GetAvailableModels(provider)
 }
 
 // isProviderAvailable checks if a provider is currently available
@@ -365,4 +192,61 @@ func (a *Agent) isProviderAvailable(provider api.ClientType) bool {
 	}
 	
 	return os.Getenv(envVar) != ""
-}
\ No newline at end of file
+}
+
+// GetModelProfile returns the request parameters applied to the current model
+func (a *Agent) GetModelProfile() map[string]interface{} {
+	return a.modelProfile
+}
+
+// applyModelProfile passes the current model's parameter profile from the
+// config to the client, replacing the previous model's profile
+func (a *Agent) applyModelProfile() {
+	configurable, ok := a.client.(api.RequestParametersClient)
+	if !ok || a.configManager == nil {
+		return
+	}
+	cfg := a.configManager.GetConfig()
+	profile := cfg.GetModelProfile(a.GetModel())
+	if a.clientType == api.OpenRouterClientType {
+		profile = withOpenRouterRouting(profile, cfg.Preferences["openrouter_routing"], a.GetModel())
+	}
+	// A temperature pinned by the project applies to whichever model is in use
+	if project := a.configManager.ProjectConfig(); project != nil && project.Temperature != nil {
+		if profile == nil {
+			profile = make(map[string]interface{})
+		}
+		profile["temperature"] = *project.Temperature
+	}
+	configurable.SetRequestParameters(profile)
+	a.modelProfile = profile
+	if len(profile) > 0 {
+		a.debugLog("🎛️  Applied parameter profile for %s: %v\n", a.GetModel(), profile)
+	}
+}
+
+// withOpenRouterRouting adds the default OpenRouter provider routing
+// preferences to a profile that has none of its own, and drops invalid routing
+// with a warning rather than sending a request OpenRouter would reject
+func withOpenRouterRouting(profile map[string]interface{}, defaults interface{}, model string) map[string]interface{} {
+	if _, ok := profile["provider"]; !ok {
+		routing, ok := defaults.(map[string]interface{})
+		if !ok {
+			return profile
+		}
+		if profile == nil {
+			profile = make(map[string]interface{})
+		}
+		profile["provider"] = routing
+	}
+
+	routing, _ := profile["provider"].(map[string]interface{})
+	if err := providers.ValidateOpenRouterRouting(routing); routing == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("provider must be an object")
+		}
+		fmt.Printf("⚠️  Ignoring OpenRouter routing preferences for %s: %v\n", model, err)
+		delete(profile, "provider")
+	}
+	return profile
+}
@@ -85,6 +85,14 @@ func TestGetProviderType(t *testing.T) {
 		api.CerebrasClientType,
 		api.GroqClientType,
 		api.DeepSeekClientType,
+		api.MistralClientType,
+		api.XAIClientType,
+		api.AzureOpenAIClientType,
+		api.BedrockClientType,
+		api.HuggingFaceClientType,
+		api.AnthropicClientType,
+		api.OpenAIClientType,
+		api.GeminiClientType,
 		api.OllamaClientType,
 	}
 
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestEditFileMultiIsAtomic(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("main.go", []byte("package main\n\nfunc a() {}\nfunc b() {}\n"), 0644)
+
+	_, _, err := tools.EditFileMultiChange("main.go", []tools.Replacement{{OldString: "func a()", NewString: "func first()"}, {OldString: "func c()", NewString: "func third()"}})
+	if err == nil || !strings.Contains(err.Error(), "edit 2") {
+		t.Fatalf("expected the second edit to fail, got %v", err)
+	}
+	if data, _ := os.ReadFile("main.go"); strings.Contains(string(data), "first") {
+		t.Error("expected no edit to be applied when one fails")
+	}
+
+	// Later edits see the result of earlier ones
+	_, change, err := tools.EditFileMultiChange("main.go", []tools.Replacement{{OldString: "func a()", NewString: "func first()"}, {OldString: "first() {}\nfunc b()", NewString: "first() {}\nfunc second()"}})
+	if err != nil || change.LinesAdded != 2 || change.LinesRemoved != 2 {
+		t.Fatalf("expected both edits to apply, got %+v (%v)", change, err)
+	}
+	if data, _ := os.ReadFile("main.go"); string(data) != "package main\n\nfunc first() {}\nfunc second() {}\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("main.go", []byte("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n"), 0644)
+	os.WriteFile("old.txt", []byte("gone\n"), 0644)
+
+	// Line numbers are off by two and the empty context line lost its space
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -5,4 +5,4 @@
+
+ func main() {
+-	fmt.Println("hi")
++	fmt.Println("hello")
+ }
+--- /dev/null
++++ b/docs/notes.md
+@@ -0,0 +1,2 @@
++# Notes
++No trailing newline
+\ No newline at end of file
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`
+	patches, err := tools.ParsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, changes, err := tools.ApplyPatchChanges(".", patches)
+	if err != nil || len(changes) != 3 {
+		t.Fatalf("expected 3 changed files, got %v:\n%s", err, result)
+	}
+	if data, _ := os.ReadFile("main.go"); !strings.Contains(string(data), `fmt.Println("hello")`) {
+		t.Errorf("expected main.go to be patched, got %q", data)
+	}
+	if data, _ := os.ReadFile("docs/notes.md"); string(data) != "# Notes\nNo trailing newline" {
+		t.Errorf("expected the created file without a trailing newline, got %q", data)
+	}
+	if _, err := os.Stat("old.txt"); !os.IsNotExist(err) || changes[2].Kind != tools.ChangeDeleted {
+		t.Error("expected old.txt to be deleted")
+	}
+
+	// A hunk that doesn't apply leaves every file alone
+	bad := "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package app\n+package cmd\n--- a/docs/notes.md\n+++ b/docs/notes.md\n@@ -1 +1 @@\n-# Notes\n+# Changelog\n"
+	patches, _ = tools.ParsePatch(bad)
+	if _, _, err := tools.ApplyPatchChanges(".", patches); err == nil || !strings.Contains(err.Error(), "hunk 1 of main.go") {
+		t.Errorf("expected the first hunk to fail, got %v", err)
+	}
+	if data, _ := os.ReadFile("docs/notes.md"); !strings.HasPrefix(string(data), "# Notes") {
+		t.Error("expected no file to change when a hunk fails")
+	}
+}
+
+func TestApplyPatchIsAllOrNothing(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("a.txt", []byte("one\n"), 0644)
+	os.WriteFile("b.txt", []byte("two\n"), 0644)
+	os.WriteFile("blocker", []byte("a file, not a directory\n"), 0644)
+
+	// a.txt and b.txt are written before blocker/new.txt fails
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n--- a/b.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-two\n" +
+		"--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+three\n--- /dev/null\n+++ b/blocker/new.txt\n@@ -0,0 +1 @@\n+new\n"
+	patches, err := tools.ParsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, changes, err := tools.ApplyPatchChanges(".", patches); err == nil || len(changes) != 0 || !strings.Contains(err.Error(), "the patch was not applied") {
+		t.Fatalf("expected the failed write to undo the patch, got %v with changes %+v", err, changes)
+	}
+	if data, _ := os.ReadFile("a.txt"); string(data) != "one\n" {
+		t.Errorf("expected a.txt to be restored, got %q", data)
+	}
+	if data, _ := os.ReadFile("b.txt"); string(data) != "two\n" {
+		t.Errorf("expected the deleted b.txt to be restored, got %q", data)
+	}
+	if _, err := os.Stat("c.txt"); !os.IsNotExist(err) {
+		t.Error("expected the created c.txt to be removed")
+	}
+
+	// A rename doesn't overwrite an existing file
+	rename := "--- a/a.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-one\n+1\n"
+	if patches, err = tools.ParsePatch(rename); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tools.ApplyPatchChanges(".", patches); err == nil || !strings.Contains(err.Error(), "b.txt, which already exists") {
+		t.Errorf("expected the rename onto b.txt to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile("b.txt"); string(data) != "two\n" {
+		t.Errorf("expected b.txt to be left alone, got %q", data)
+	}
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	t.Chdir(t.TempDir())
+	os.WriteFile("a.txt", []byte("one\ntwo\n"), 0644)
+	os.WriteFile("b.txt", []byte("three\n"), 0644)
+	args, _ := json.Marshal(map[string]string{"patch": "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-three\n+3\n"})
+	call := api.ToolCall{ID: "call_patch", Type: "function"}
+	call.Function.Name, call.Function.Arguments = "apply_patch", string(args)
+	if _, err := agent.executeTool(call); err != nil {
+		t.Fatal(err)
+	}
+	if journal := agent.ChangeJournal(); len(journal) != 2 || journal[0].Path != "a.txt" || journal[1].Path != "b.txt" {
+		t.Fatalf("expected both files journaled, got %+v", journal)
+	}
+	if lines := changedLines(map[string]interface{}{"edits": []interface{}{map[string]interface{}{"old_string": "a\nb", "new_string": "c"}}}); lines != 2 {
+		t.Errorf("expected multi-edit lines to count, got %d", lines)
+	}
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+const testNotebook = `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Analysis\n"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 3,
+   "metadata": {
+    "scrolled": true
+   },
+   "outputs": [
+    {
+     "name": "stdout",
+     "output_type": "stream",
+     "text": [
+      "42\n"
+     ]
+    }
+   ],
+   "source": [
+    "x = 40 + 2\n",
+    "print(x)"
+   ]
+  }
+ ],
+ "metadata": {
+  "kernelspec": {
+   "display_name": "Python 3",
+   "language": "python",
+   "name": "python3"
+  },
+  "language_info": {
+   "version": "3.11.4"
+  }
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`
+
+// writeTestNotebook writes the test notebook to a temp directory
+func writeTestNotebook(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "analysis.ipynb")
+	if err := os.WriteFile(path, []byte(testNotebook), 0644); err != nil {
+		t.Fatalf("Failed to write notebook: %v", err)
+	}
+	return path
+}
+
+// TestReadNotebook tests rendering a notebook as cells with outputs
+func TestReadNotebook(t *testing.T) {
+	path := writeTestNotebook(t)
+	rendered, err := tools.ReadNotebook(path, true)
+	if err != nil {
+		t.Fatalf("Failed to read notebook: %v", err)
+	}
+	for _, expected := range []string{"2 cells, kernel python3", "[cell 0] markdown\n# Analysis", "[cell 1] code (execution 3)\nx = 40 + 2\nprint(x)", "--- output ---\n42"} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected %q in rendered notebook:\n%s", expected, rendered)
+		}
+	}
+	if withoutOutputs, _ := tools.ReadNotebook(path, false); strings.Contains(withoutOutputs, "--- output ---") {
+		t.Error("Expected outputs to be left out")
+	}
+}
+
+// TestEditCell tests replacing, inserting and deleting cells
+func TestEditCell(t *testing.T) {
+	path := writeTestNotebook(t)
+
+	if _, err := tools.EditCell(path, 1, tools.CellReplace, "x = 1\nprint(x)\n", ""); err != nil {
+		t.Fatalf("Failed to replace cell: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	content := string(data)
+	for _, expected := range []string{`"x = 1\n",`, `"print(x)\n"`, `"execution_count": null`, `"outputs": []`, `"scrolled": true`, `"version": "3.11.4"`} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("Expected %s in saved notebook:\n%s", expected, content)
+		}
+	}
+	if !strings.HasPrefix(content, "{\n \"cells\": [\n  {\n   \"cell_type\": \"markdown\"") {
+		t.Errorf("Expected Jupyter's formatting to be kept:\n%s", content)
+	}
+
+	if _, err := tools.EditCell(path, 2, tools.CellInsert, "Done.", "markdown"); err != nil {
+		t.Fatalf("Failed to insert cell: %v", err)
+	}
+	if _, err := tools.EditCell(path, 0, tools.CellDelete, "", ""); err != nil {
+		t.Fatalf("Failed to delete cell: %v", err)
+	}
+	rendered, _ := tools.ReadNotebook(path, true)
+	if !strings.Contains(rendered, "[cell 0] code") || !strings.Contains(rendered, "[cell 1] markdown\nDone.") {
+		t.Errorf("Unexpected cells after insert and delete:\n%s", rendered)
+	}
+	nb, _ := tools.LoadNotebook(path)
+	if nb.NumCells() != 2 {
+		t.Errorf("Expected 2 cells, got %d", nb.NumCells())
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"id": "`) {
+		t.Error("Expected the inserted cell to get an id in an nbformat 4.5 notebook")
+	}
+
+	if _, err := tools.EditCell(path, 7, tools.CellReplace, "x", ""); err == nil || !strings.Contains(err.Error(), "0-1") {
+		t.Errorf("Expected an out of range error naming the valid cells, got %v", err)
+	}
+}
+
+// TestRunCell tests running a cell with jupyter, or the error without it
+func TestRunCell(t *testing.T) {
+	path := writeTestNotebook(t)
+	if _, err := tools.RunCell(path, 0); err == nil || !strings.Contains(err.Error(), "not code") {
+		t.Errorf("Expected markdown cells to be rejected, got %v", err)
+	}
+
+	if _, err := exec.LookPath("jupyter"); err != nil {
+		if _, err := tools.RunCell(path, 1); err == nil || !strings.Contains(err.Error(), "jupyter is not installed") {
+			t.Errorf("Expected an error naming the missing jupyter, got %v", err)
+		}
+		return
+	}
+	result, err := tools.RunCell(path, 1)
+	if err != nil {
+		t.Skipf("jupyter could not run the notebook: %v", err)
+	}
+	if !strings.Contains(result, "42") {
+		t.Errorf("Expected the cell's output, got %s", result)
+	}
+}
@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// Spoken notification events, selected with the tts_notifications preference
+const (
+	TTSOff       = "off"
+	TTSSummary   = "summary"   // read the final summary of each task
+	TTSApprovals = "approvals" // read approval requests
+	TTSAll       = "all"
+)
+
+// prefTTSNotifications is the preference that opts into spoken notifications
+const prefTTSNotifications = "tts_notifications"
+
+// ttsEnabledFor reports whether an event should be read aloud
+func (a *Agent) ttsEnabledFor(event string) bool {
+	if a.configManager == nil {
+		return false
+	}
+	setting := a.configManager.GetConfig().GetStringPreference(prefTTSNotifications, TTSOff)
+	return setting == TTSAll || setting == event
+}
+
+// speak reads a notification aloud if the event is enabled
+func (a *Agent) speak(event, text string) {
+	if !a.ttsEnabledFor(event) {
+		return
+	}
+	if err := tools.Speak(text); err != nil {
+		a.debugLog("⚠️ Spoken notification failed: %v\n", err)
+	}
+}
+
+// AnnounceTaskResult reads the outcome of a finished task aloud when enabled
+func (a *Agent) AnnounceTaskResult(result string, err error) {
+	if err != nil {
+		a.speak(TTSSummary, fmt.Sprintf("Task failed. %v", err))
+		return
+	}
+	summary := fmt.Sprintf("Task completed after %d iterations, costing %s. ", a.currentIteration, spokenCost(a.totalCost))
+	a.speak(TTSSummary, summary+strings.TrimSpace(result))
+}
+
+// spokenCost formats a dollar amount for speech
+func spokenCost(cost float64) string {
+	if cost < 0.01 {
+		return "less than a cent"
+	}
+	return fmt.Sprintf("%.2f dollars", cost)
+}
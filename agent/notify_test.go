@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestSpeakableText(t *testing.T) {
+	got := tools.SpeakableText("✅ **Done!** Updated `main.go`\n\n## Next\n- run tests")
+	if got != "Done! Updated main.go Next - run tests" {
+		t.Errorf("unexpected speakable text: %q", got)
+	}
+
+	long := strings.Repeat("This sentence is spoken. ", 40)
+	if got := tools.SpeakableText(long); len(got) > 400 || !strings.HasSuffix(got, ".") {
+		t.Errorf("expected long text cut at a sentence end within 400 chars, got %d chars: %q", len(got), got[len(got)-20:])
+	}
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/providers"
+	"github.com/alantheprice/coder/types"
+)
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := openAICompatibleServer(t,
+		`{"prompt_tokens": 2000000, "completion_tokens": 1000000, "total_tokens": 3000000, "prompt_tokens_details": {"cached_tokens": 1000000}}`,
+		`{"data": [{"id": "acme-large", "context_window": 65536}, {"id": "acme-embed"}, {"id": "acme-small", "active": false}]}`,
+		&requestBody)
+	t.Setenv("ACME_API_KEY", "test-key")
+	t.Setenv("ACME_BASE_URL", server.URL+"/")
+
+	// A new provider is only a config
+	config := providers.ProviderConfig{
+		Name:           "acme",
+		Label:          "Acme",
+		BaseURL:        "https://api.acme.invalid/v1",
+		BaseURLEnv:     "ACME_BASE_URL",
+		APIKeyEnv:      "ACME_API_KEY",
+		DefaultModel:   "acme-large",
+		MaxTokensParam: "max_completion_tokens",
+		FitMaxTokens:   true,
+		ListedContext:  true,
+		Pricing:        func(string) (float64, float64, float64) { return 1, 0.5, 2 },
+		KeepModel:      func(id string) bool { return !strings.Contains(id, "embed") },
+	}
+	provider, err := providers.NewOpenAICompatibleProviderWithModel(config, "")
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleProviderWithModel: %v", err)
+	}
+	if provider.GetProvider() != "acme" || provider.GetModel() != "acme-large" || provider.SupportsVision() {
+		t.Errorf("unexpected provider %s or model %s", provider.GetProvider(), provider.GetModel())
+	}
+	if limit, _ := provider.GetModelContextLimit(); limit != 65536 {
+		t.Errorf("expected the listed context window, got %d", limit)
+	}
+
+	resp, err := provider.SendChatRequest(context.Background(), []types.Message{{Role: "user", Content: "read go.mod"}}, nil, "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	// A million fresh input tokens at $1, a million cached at $0.50, a million output at $2
+	if math.Abs(resp.Usage.EstimatedCost-3.5) > 1e-9 {
+		t.Errorf("expected cost 3.5, got %+v", resp.Usage)
+	}
+	if requestBody["max_tokens"] != nil || requestBody["max_completion_tokens"] != 16000.0 || requestBody["temperature"] != 0.7 {
+		t.Errorf("unexpected request %v", requestBody)
+	}
+
+	models, err := provider.ListModels()
+	if err != nil || len(models) != 1 || models[0].ID != "acme-large" || models[0].InputCost != 1 {
+		t.Errorf("expected only the active chat model, got %+v (%v)", models, err)
+	}
+
+	t.Setenv("ACME_API_KEY", "")
+	if _, err := providers.NewOpenAICompatibleProvider(config); err == nil || !strings.Contains(err.Error(), "ACME_API_KEY") {
+		t.Errorf("expected an error naming the key, got %v", err)
+	}
+}
+
+func TestOpenAICompatibleDailyLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"message": "Tokens per day limit exceeded: daily limit reached"}}`))
+	}))
+	defer server.Close()
+	t.Setenv("CEREBRAS_API_KEY", "test-key")
+	t.Setenv("CEREBRAS_BASE_URL", server.URL)
+
+	provider, err := providers.NewCerebrasProviderWithModel("")
+	if err != nil {
+		t.Fatalf("NewCerebrasProviderWithModel: %v", err)
+	}
+	if limit, _ := provider.GetModelContextLimit(); limit != 32768 {
+		t.Errorf("expected the default model's 32K context, got %d", limit)
+	}
+	_, err = provider.SendChatRequest(context.Background(), []types.Message{{Role: "user", Content: "hi"}}, nil, "")
+	if err == nil || !strings.Contains(err.Error(), "daily limit exceeded") || requests != 1 {
+		t.Errorf("expected a daily limit to fail without retries, got %v after %d requests", err, requests)
+	}
+}
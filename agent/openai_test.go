@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestOpenAIClient(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := openAICompatibleServer(t,
+		`{"prompt_tokens": 2000000, "completion_tokens": 1000000, "total_tokens": 3000000, "prompt_tokens_details": {"cached_tokens": 1000000}}`,
+		`{"object": "list", "data": [{"id": "gpt-4.1", "owned_by": "system"}, {"id": "text-embedding-3-small", "owned_by": "system"},
+			{"id": "gpt-5-mini-2025-08-07", "owned_by": "system"}, {"id": "gpt-4o-realtime-preview", "owned_by": "system"}, {"id": "dall-e-3", "owned_by": "system"}]}`,
+		&requestBody)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	client, err := api.NewUnifiedClientWithModel(api.OpenAIClientType, "")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	if client.GetModel() != "gpt-4.1" || client.GetProvider() != "openai" || !client.SupportsVision() {
+		t.Errorf("unexpected model %s or provider %s", client.GetModel(), client.GetProvider())
+	}
+	if limit, _ := client.GetModelContextLimit(); limit != 1047576 {
+		t.Errorf("expected GPT-4.1's 1M context, got %d", limit)
+	}
+
+	resp, err := client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "read go.mod"}}, api.GetToolDefinitions(), "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	calls := resp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].Function.Name != "read_file" {
+		t.Errorf("unexpected tool calls %+v", calls)
+	}
+	// A million fresh input tokens at $2, a million cached at $0.50, a million output at $8
+	if math.Abs(resp.Usage.EstimatedCost-10.5) > 1e-9 {
+		t.Errorf("expected cost 10.5, got %+v", resp.Usage)
+	}
+	if requestBody["model"] != "gpt-4.1" || requestBody["temperature"] == nil || requestBody["tools"] == nil {
+		t.Errorf("unexpected request %v", requestBody)
+	}
+
+	// Reasoning models get no temperature, and max_tokens goes by its new name
+	client.SetModel("gpt-5-mini")
+	client.(api.RequestParametersClient).SetRequestParameters(map[string]interface{}{"max_tokens": 4000})
+	requestBody = nil
+	if _, err := client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "hi"}}, nil, ""); err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	if requestBody["temperature"] != nil || requestBody["max_tokens"] != nil || requestBody["max_completion_tokens"] != 4000.0 {
+		t.Errorf("unexpected reasoning model request %v", requestBody)
+	}
+
+	models, err := api.GetAvailableModels(api.OpenAIClientType)
+	if err != nil || len(models) != 2 || models[1].ID != "gpt-5-mini-2025-08-07" || models[1].InputCost != 0.25 || models[1].ContextLength != 400000 {
+		t.Errorf("expected OpenAI's chat models with prices, got %+v (%v)", models, err)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "")
+	if _, err := api.NewUnifiedClientWithModel(api.OpenAIClientType, ""); err == nil {
+		t.Error("expected an error without OPENAI_API_KEY")
+	}
+}
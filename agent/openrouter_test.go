@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestOpenRouterClient(t *testing.T) {
+	var requestBody map[string]interface{}
+	modelListRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/models":
+			modelListRequests++
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": []map[string]interface{}{
+				{"id": "qwen/qwen3-coder", "context_length": 262144, "pricing": map[string]string{"prompt": "0.0000002", "completion": "0.0000008"}},
+				{"id": "deepseek/deepseek-chat-v3.1", "context_length": 163840},
+			}})
+		case "/chat/completions":
+			requestBody = nil
+			json.NewDecoder(r.Body).Decode(&requestBody)
+			w.Write([]byte(`{"id": "gen-1", "model": "qwen/qwen3-coder", "choices": [{"index": 0, "finish_reason": "tool_calls", "message": {
+				"role": "assistant", "content": "",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "read_file", "arguments": "{\"file_path\": \"main.go\"}"}}]}}],
+				"usage": {"prompt_tokens": 1200, "completion_tokens": 30, "total_tokens": 1230, "cost": 0.000264, "prompt_tokens_details": {"cached_tokens": 1000}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("OPENROUTER_BASE_URL", server.URL+"/")
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+
+	client, err := api.NewUnifiedClientWithModel(api.OpenRouterClientType, "qwen/qwen3-coder")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	if client.GetProvider() != "openrouter" || client.GetModel() != "qwen/qwen3-coder" {
+		t.Errorf("unexpected provider %s or model %s", client.GetProvider(), client.GetModel())
+	}
+	if limit, _ := client.GetModelContextLimit(); limit != 262144 {
+		t.Errorf("expected the listed context length, got %d", limit)
+	}
+
+	resp, err := client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "read main.go"}}, api.GetToolDefinitions(), "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	calls := resp.Choices[0].Message.ToolCalls
+	if len(calls) != 1 || calls[0].Function.Name != "read_file" || calls[0].Function.Arguments != `{"file_path": "main.go"}` {
+		t.Errorf("unexpected tool calls %+v", calls)
+	}
+	if resp.Usage.TotalTokens != 1230 || resp.Usage.EstimatedCost != 0.000264 || resp.Usage.PromptTokensDetails.CachedTokens != 1000 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+	if requestBody["model"] != "qwen/qwen3-coder" || requestBody["tools"] == nil || requestBody["usage"] == nil {
+		t.Errorf("unexpected request %v", requestBody)
+	}
+
+	// Variants resolve to their base model, and the model list is fetched once
+	if err := client.SetModel("deepseek/deepseek-chat-v3.1:free"); err != nil {
+		t.Fatalf("SetModel: %v", err)
+	}
+	if limit, _ := client.GetModelContextLimit(); limit != 163840 {
+		t.Errorf("expected the base model's context length, got %d", limit)
+	}
+	if _, err := client.SendChatRequest(context.Background(), []api.Message{{Role: "user", Content: "hi"}}, nil, ""); err != nil || requestBody["model"] != "deepseek/deepseek-chat-v3.1:free" {
+		t.Errorf("the request didn't use the new model: %v, %v", requestBody["model"], err)
+	}
+	if modelListRequests != 1 {
+		t.Errorf("expected the model list to be cached, fetched %d times", modelListRequests)
+	}
+	if err := client.SetModel(""); err == nil {
+		t.Error("expected an empty model to be rejected")
+	}
+
+	// Without a model the provider's default is used
+	client, err = api.NewUnifiedClientWithModel(api.OpenRouterClientType, "")
+	if err != nil || client.GetModel() == "" {
+		t.Errorf("expected a default model, got %q (%v)", client.GetModel(), err)
+	}
+}
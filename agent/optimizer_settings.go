@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/tools"
+)
+
+// Optimizer aggressiveness levels
+const (
+	OptimizeConservative = "conservative"
+	OptimizeBalanced     = "balanced"
+	OptimizeAggressive   = "aggressive"
+)
+
+// Config preference keys for the conversation optimizer
+const (
+	prefOptimizerEnabled        = "optimizer_enabled"
+	prefOptimizerAggressiveness = "optimizer_aggressiveness"
+	prefOptimizerTransientTTL   = "optimizer_transient_ttl"
+	prefOptimizerMaxToolResult  = "optimizer_max_tool_result_chars"
+	prefFileCompression         = "file_compression"
+	prefFileCompressionMinChars = "file_compression_min_chars"
+)
+
+// OptimizerSettings are the user-tunable knobs of the conversation optimizer
+type OptimizerSettings struct {
+	Enabled            bool
+	Aggressiveness     string // conservative, balanced or aggressive
+	TransientTTL       int    // messages after which repeated exploration output (ls, find...) is summarized
+	MaxToolResultChars int    // tool results longer than this are truncated (0 = no limit)
+	Budget             ContextBudget
+	FileCompression    string // off, whitespace or outline - applied to read_file results of large files
+	CompressMinChars   int    // files at least this large are compressed
+}
+
+// DefaultOptimizerSettings returns the settings used when nothing is configured
+func DefaultOptimizerSettings() OptimizerSettings {
+	return OptimizerSettings{
+		Enabled:            true,
+		Aggressiveness:     OptimizeBalanced,
+		TransientTTL:       2,
+		MaxToolResultChars: 0,
+		Budget:             DefaultContextBudget(),
+		FileCompression:    tools.CompressNone,
+		CompressMinChars:   40000,
+	}
+}
+
+// LoadOptimizerSettings reads optimizer settings from the config preferences
+func LoadOptimizerSettings(cfg *config.Config) OptimizerSettings {
+	settings := DefaultOptimizerSettings()
+	if cfg == nil {
+		return settings
+	}
+
+	settings.Enabled = cfg.GetBoolPreference(prefOptimizerEnabled, settings.Enabled)
+	settings.TransientTTL = cfg.GetIntPreference(prefOptimizerTransientTTL, settings.TransientTTL)
+	settings.MaxToolResultChars = cfg.GetIntPreference(prefOptimizerMaxToolResult, settings.MaxToolResultChars)
+	if level := cfg.GetStringPreference(prefOptimizerAggressiveness, ""); ValidAggressiveness(level) {
+		settings.Aggressiveness = level
+	}
+	settings.Budget = LoadContextBudget(cfg)
+	if mode := cfg.GetStringPreference(prefFileCompression, ""); tools.ValidCompressionMode(mode) {
+		settings.FileCompression = mode
+	}
+	settings.CompressMinChars = cfg.GetIntPreference(prefFileCompressionMinChars, settings.CompressMinChars)
+	return settings
+}
+
+// ValidAggressiveness reports whether level is a known aggressiveness level
+func ValidAggressiveness(level string) bool {
+	return level == OptimizeConservative || level == OptimizeBalanced || level == OptimizeAggressive
+}
+
+// redundantReadGap is how many messages must separate two identical reads
+// before the older one is summarized
+func (s OptimizerSettings) redundantReadGap() int {
+	switch s.Aggressiveness {
+	case OptimizeConservative:
+		return 10
+	case OptimizeAggressive:
+		return 2
+	default:
+		return 5
+	}
+}
+
+// aggressiveThreshold is the fraction of the context window at which
+// AggressiveOptimization kicks in
+func (s OptimizerSettings) aggressiveThreshold() float64 {
+	switch s.Aggressiveness {
+	case OptimizeConservative:
+		return 0.9
+	case OptimizeAggressive:
+		return 0.6
+	default:
+		return 0.8
+	}
+}
+
+// String formats the settings for display
+func (s OptimizerSettings) String() string {
+	maxResult := "unlimited"
+	if s.MaxToolResultChars > 0 {
+		maxResult = fmt.Sprintf("%d chars", s.MaxToolResultChars)
+	}
+	return fmt.Sprintf("enabled=%t aggressiveness=%s transient_ttl=%d max_tool_result=%s file_compression=%s budget=(%s)",
+		s.Enabled, s.Aggressiveness, s.TransientTTL, maxResult, s.FileCompression, s.Budget)
+}
+
+// GetOptimizerSettings returns the optimizer settings of the current session
+func (a *Agent) GetOptimizerSettings() OptimizerSettings {
+	return a.optimizer.Settings()
+}
+
+// GetLastOptimizationDrops returns what the optimizer removed or summarized in its last pass
+func (a *Agent) GetLastOptimizationDrops() []OptimizationDrop {
+	return a.optimizer.LastDrops()
+}
@@ -0,0 +1,393 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+const (
+	bloomBits             = 1 << 17 // 16KB filter, ~1% false positives at 10K outputs
+	bloomHashes           = 5
+	maxCachedOutputs      = 50
+	maxCachedOutputSize   = 256 * 1024
+	largeOutputThreshold  = 4000 // outputs at least this large are remembered across sessions
+	unchangedPreviewLines = 40
+)
+
+// cacheableCommandPrefixes are read-only exploration commands whose output only
+// depends on the repository's files. Commands that read installed packages,
+// such as npm ls or pip list, depend on files git ignores or that live
+// outside the repository, which the workspace fingerprint doesn't cover.
+var cacheableCommandPrefixes = []string{
+	"tree", "ls", "find", "go list", "git ls-files", "git log", "du", "wc -l",
+	"cargo tree",
+}
+
+// pathCommands read the paths they're given, or the working directory
+var pathCommands = map[string]bool{"tree": true, "ls": true, "find": true, "du": true, "wc": true}
+
+// BloomFilter is a fixed-size probabilistic set of hashes
+type BloomFilter struct {
+	Bits []byte `json:"bits"`
+}
+
+// newBloomFilter creates an empty filter
+func newBloomFilter() *BloomFilter {
+	return &BloomFilter{Bits: make([]byte, bloomBits/8)}
+}
+
+// positions returns the bit positions for a key
+func (b *BloomFilter) positions(key string) []uint32 {
+	sum := sha256.Sum256([]byte(key))
+	positions := make([]uint32, bloomHashes)
+	for i := range positions {
+		positions[i] = binary.BigEndian.Uint32(sum[i*4:]) % bloomBits
+	}
+	return positions
+}
+
+// Add inserts a key
+func (b *BloomFilter) Add(key string) {
+	for _, pos := range b.positions(key) {
+		b.Bits[pos/8] |= 1 << (pos % 8)
+	}
+}
+
+// MayContain reports whether key was probably added
+func (b *BloomFilter) MayContain(key string) bool {
+	for _, pos := range b.positions(key) {
+		if b.Bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedOutput is a stored exploration command result
+type cachedOutput struct {
+	Command     string    `json:"command"`
+	Dir         string    `json:"dir"`
+	Fingerprint string    `json:"fingerprint"`
+	Output      string    `json:"output"`
+	RanAt       time.Time `json:"ran_at"`
+}
+
+// OutputCache remembers exploration command output per project across sessions.
+// Outputs are served from the cache while the workspace is unchanged, and large
+// outputs already delivered in an earlier session are recorded in a Bloom filter.
+type OutputCache struct {
+	path    string
+	Seen    *BloomFilter             `json:"seen"`
+	Outputs map[string]*cachedOutput `json:"outputs"`
+}
+
+// OpenOutputCache loads the cache for the project rooted at dir
+func OpenOutputCache(dir string) (*OutputCache, error) {
+	cacheDir, err := projectCacheDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return openOutputCacheAt(filepath.Join(cacheDir, "outputs.json"))
+}
+
+// projectCacheDir returns (and creates) the project state directory where
+// cross-session data is kept
+func projectCacheDir(dir string) (string, error) {
+	return config.ProjectStateDir(dir)
+}
+
+// openOutputCacheAt loads (or starts) a cache file
+func openOutputCacheAt(path string) (*OutputCache, error) {
+	cache := &OutputCache{path: path, Seen: newBloomFilter(), Outputs: make(map[string]*cachedOutput)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output cache: %w", err)
+	}
+	if err := json.Unmarshal(data, cache); err != nil || cache.Seen == nil || len(cache.Seen.Bits) != bloomBits/8 {
+		// A corrupt or outdated cache is simply started over
+		return &OutputCache{path: path, Seen: newBloomFilter(), Outputs: make(map[string]*cachedOutput)}, nil
+	}
+	if cache.Outputs == nil {
+		cache.Outputs = make(map[string]*cachedOutput)
+	}
+	return cache, nil
+}
+
+// Lookup returns the cached output of a command if the workspace is unchanged
+func (c *OutputCache) Lookup(dir, command, fingerprint string) (string, bool) {
+	entry, ok := c.Outputs[cacheKey(dir, command)]
+	if !ok || entry.Fingerprint != fingerprint {
+		return "", false
+	}
+	return entry.Output, true
+}
+
+// Store records the output of a command and saves the cache
+func (c *OutputCache) Store(dir, command, fingerprint, output string) error {
+	if len(output) > maxCachedOutputSize {
+		return nil
+	}
+	c.Outputs[cacheKey(dir, command)] = &cachedOutput{
+		Command:     command,
+		Dir:         dir,
+		Fingerprint: fingerprint,
+		Output:      output,
+		RanAt:       time.Now(),
+	}
+	c.evictOldest()
+	return c.save()
+}
+
+// SeenBefore reports whether this exact output of the command was delivered in an
+// earlier session, and remembers it for later sessions
+func (c *OutputCache) SeenBefore(command, output string) bool {
+	key := command + "\x00" + output
+	if c.Seen.MayContain(key) {
+		return true
+	}
+	c.Seen.Add(key)
+	if err := c.save(); err != nil {
+		fmt.Printf("⚠️  Failed to save output cache: %v\n", err)
+	}
+	return false
+}
+
+// evictOldest keeps the number of stored outputs bounded
+func (c *OutputCache) evictOldest() {
+	if len(c.Outputs) <= maxCachedOutputs {
+		return
+	}
+	keys := make([]string, 0, len(c.Outputs))
+	for key := range c.Outputs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return c.Outputs[keys[i]].RanAt.Before(c.Outputs[keys[j]].RanAt) })
+	for _, key := range keys[:len(keys)-maxCachedOutputs] {
+		delete(c.Outputs, key)
+	}
+}
+
+// save writes the cache to disk, first merging in what other instances
+// running in the same project saved since it was loaded
+func (c *OutputCache) save() error {
+	return config.WithFileLock(c.path, func() error {
+		if onDisk, err := openOutputCacheAt(c.path); err == nil {
+			c.merge(onDisk)
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output cache: %w", err)
+		}
+		return config.WriteFileAtomic(c.path, data, 0600)
+	})
+}
+
+// merge adds another copy's seen outputs and newer stored outputs
+func (c *OutputCache) merge(other *OutputCache) {
+	for i := range c.Seen.Bits {
+		c.Seen.Bits[i] |= other.Seen.Bits[i]
+	}
+	for key, entry := range other.Outputs {
+		if mine, ok := c.Outputs[key]; !ok || entry.RanAt.After(mine.RanAt) {
+			c.Outputs[key] = entry
+		}
+	}
+	c.evictOldest()
+}
+
+// cacheKey identifies a command run in a directory
+func cacheKey(dir, command string) string {
+	return dir + "\x00" + command
+}
+
+// isCacheableCommand reports whether a command is a read-only exploration command
+func isCacheableCommand(command string) bool {
+	command = strings.TrimSpace(command)
+	if strings.ContainsAny(command, ";&|><`$") {
+		return false
+	}
+	for _, prefix := range cacheableCommandPrefixes {
+		if command == prefix || strings.HasPrefix(command, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceFingerprint identifies the current workspace state using git: the
+// checked-out commit, all uncommitted changes, and the size and modification
+// time of untracked files, whose edits git status doesn't show. Files git
+// ignores are not covered (see touchesUncoveredPaths). It is empty outside git.
+func workspaceFingerprint(dir string) string {
+	hasher := sha256.New()
+	for _, args := range [][]string{
+		{"rev-parse", "HEAD"},
+		{"status", "--porcelain", "--untracked-files=all"},
+		{"diff", "HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			return ""
+		}
+		hasher.Write(output)
+	}
+
+	cmd := exec.Command("git", "ls-files", "--others", "--exclude-standard", "-z")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	for _, name := range strings.Split(string(output), "\x00") {
+		if name == "" {
+			continue
+		}
+		if info, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			fmt.Fprintf(hasher, "%s\x00%d\x00%d\n", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// touchesUncoveredPaths reports whether a command that reads paths reads any
+// the workspace fingerprint doesn't cover: files git ignores, such as
+// node_modules or build output, or anything outside the repository
+func touchesUncoveredPaths(dir, command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || !pathCommands[fields[0]] {
+		return false
+	}
+	var targets []string
+	for _, arg := range fields[1:] {
+		arg = strings.Trim(arg, `'"`)
+		if strings.HasPrefix(arg, "-") {
+			if fields[0] == "find" {
+				break // the expression starts at the first flag
+			}
+			continue
+		}
+		targets = append(targets, arg)
+	}
+	if len(targets) == 0 {
+		targets = []string{"."}
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return true
+	}
+	top := strings.TrimSpace(string(output))
+	if resolved, err := filepath.EvalSymlinks(top); err == nil {
+		top = resolved
+	}
+	for _, target := range targets {
+		if strings.HasPrefix(target, "~") {
+			return true
+		}
+		abs := target
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(dir, abs)
+		}
+		abs = resolveExistingPath(abs)
+		rel, err := filepath.Rel(top, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	// Ignored files or directories at or under any target
+	cmd = exec.Command("git", append([]string{"ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z", "--"}, targets...)...)
+	cmd.Dir = dir
+	output, err = cmd.Output()
+	return err != nil || len(output) > 0
+}
+
+// resolveExistingPath follows symlinks in a path when it exists
+func resolveExistingPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// cacheableIn reports whether a command's output can be cached for dir: it
+// is an exploration command and only reads what the fingerprint covers
+func cacheableIn(dir, command string) bool {
+	return isCacheableCommand(command) && !touchesUncoveredPaths(dir, command)
+}
+
+// cachedShellOutput serves an exploration command from the cross-session cache.
+// It returns the output and true when the command does not need to run.
+func (a *Agent) cachedShellOutput(command string) (string, bool) {
+	if a.outputCache == nil || !isCacheableCommand(command) {
+		return "", false
+	}
+	dir, err := os.Getwd()
+	if err != nil || !cacheableIn(dir, command) {
+		return "", false
+	}
+	fingerprint := workspaceFingerprint(dir)
+	if fingerprint == "" {
+		return "", false
+	}
+
+	output, ok := a.outputCache.Lookup(dir, command, fingerprint)
+	if ok {
+		a.debugLog("📦 Served from output cache (workspace unchanged): %s\n", command)
+	}
+	return output, ok
+}
+
+// rememberShellOutput stores an exploration command's output for later sessions
+func (a *Agent) rememberShellOutput(command, output string) {
+	if a.outputCache == nil || !isCacheableCommand(command) {
+		return
+	}
+	dir, err := os.Getwd()
+	if err != nil || !cacheableIn(dir, command) {
+		return
+	}
+	if fingerprint := workspaceFingerprint(dir); fingerprint != "" {
+		if err := a.outputCache.Store(dir, command, fingerprint, output); err != nil {
+			a.debugLog("⚠️ Failed to store output cache: %v\n", err)
+		}
+	}
+}
+
+// summarizeRepeatedOutput shortens large outputs that were already delivered in
+// an earlier session this conversation continues from
+func (a *Agent) summarizeRepeatedOutput(command, output string) string {
+	if a.outputCache == nil || len(output) < largeOutputThreshold || !isCacheableCommand(command) {
+		return output
+	}
+	if !a.outputCache.SeenBefore(command, output) || a.previousSummary == "" {
+		return output
+	}
+
+	lines := strings.Split(output, "\n")
+	preview := lines
+	if len(preview) > unchangedPreviewLines {
+		preview = preview[:unchangedPreviewLines]
+	}
+	return fmt.Sprintf("[UNCHANGED] Output identical to a previous session (%d lines, %d chars). First %d lines:\n%s\n... (narrow the command, e.g. to a subdirectory, to see more)",
+		len(lines), len(output), len(preview), strings.Join(preview, "\n"))
+}
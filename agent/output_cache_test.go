@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutputCachePersistsAcrossSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outputs.json")
+
+	cache, err := openOutputCacheAt(path)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	if err := cache.Store("/repo", "go list ./...", "fp1", "pkg/a\npkg/b"); err != nil {
+		t.Fatalf("Failed to store output: %v", err)
+	}
+	if cache.SeenBefore("tree", "big output") {
+		t.Errorf("Expected first delivery of an output not to be seen before")
+	}
+
+	// A new session reads the same cache file
+	cache, err = openOutputCacheAt(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	if output, ok := cache.Lookup("/repo", "go list ./...", "fp1"); !ok || output != "pkg/a\npkg/b" {
+		t.Errorf("Expected cached output for unchanged workspace, got %q (%v)", output, ok)
+	}
+	if _, ok := cache.Lookup("/repo", "go list ./...", "fp2"); ok {
+		t.Errorf("Expected cache miss after the workspace changed")
+	}
+	if !cache.SeenBefore("tree", "big output") {
+		t.Errorf("Expected output delivered in an earlier session to be remembered")
+	}
+	if cache.SeenBefore("tree", "different output") {
+		t.Errorf("Expected a different output not to be reported as seen")
+	}
+}
+
+func TestIsCacheableCommand(t *testing.T) {
+	tests := map[string]bool{
+		"tree":              true,
+		"go list ./...":     true,
+		"ls -la src":        true,
+		"ls > files.txt":    false,
+		"find . | xargs rm": false,
+		"go test ./...":     false,
+		"lsof":              false,
+	}
+	for command, want := range tests {
+		if got := isCacheableCommand(command); got != want {
+			t.Errorf("isCacheableCommand(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+func TestWorkspaceFingerprintCoversUntrackedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0644)
+	commit := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init")
+	commit.Dir = dir
+	if err := commit.Run(); err != nil {
+		t.Skipf("git commit failed: %v", err)
+	}
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("first"), 0644)
+	before := workspaceFingerprint(dir)
+	if before == "" {
+		t.Fatalf("Expected a fingerprint inside a git repository")
+	}
+
+	os.WriteFile(notes, []byte("second draft"), 0644)
+	os.Chtimes(notes, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if after := workspaceFingerprint(dir); after == before {
+		t.Errorf("Expected editing an untracked file to change the fingerprint")
+	}
+}
+
+func TestCommandsReadingUncoveredPathsAreNotCached(t *testing.T) {
+	dir := t.TempDir()
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Skipf("git not available: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("node_modules/\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "node_modules", "left-pad"), 0755)
+	os.WriteFile(filepath.Join(dir, "node_modules", "left-pad", "index.js"), []byte("x"), 0644)
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+
+	tests := map[string]bool{
+		"ls -la src":          true,
+		"find src -name *.go": true,
+		"go list ./...":       true,
+		"ls node_modules":     false,
+		"find .":              false,
+		"tree":                false,
+		"ls ../":              false,
+		"ls ~/projects":       false,
+		"du -sh /etc":         false,
+	}
+	for command, want := range tests {
+		if got := cacheableIn(dir, command); got != want {
+			t.Errorf("cacheableIn(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// DefaultPairedTurns is the tool-call budget per stretch in paired mode
+const DefaultPairedTurns = 5
+
+// SetTurnLimit switches paired mode on (n tool calls per stretch) or off (n = 0)
+func (a *Agent) SetTurnLimit(n int) {
+	if n < 0 {
+		n = 0
+	}
+	a.turnLimit = n
+	if n == 0 {
+		a.pairedPaused = false
+	}
+}
+
+// GetTurnLimit returns the paired-mode tool-call budget (0 = autonomous)
+func (a *Agent) GetTurnLimit() int {
+	return a.turnLimit
+}
+
+// IsPaused reports whether the agent is waiting for a go-ahead in paired mode
+func (a *Agent) IsPaused() bool {
+	return a.pairedPaused
+}
+
+// pauseForGoAhead ends a paired-mode stretch: the model summarizes what it
+// found and changed and what it intends next, without calling more tools
+func (a *Agent) pauseForGoAhead(toolCalls int) (string, error) {
+	a.messages = append(a.messages, api.Message{
+		Role: "user",
+		Content: fmt.Sprintf(`PAUSE: you have made %d tool calls, the limit for this stretch in paired mode. Do not call any tools now.
+Reply briefly with:
+1. What you found
+2. What you changed (files and why)
+3. What you intend to do next
+Then wait for the user's go-ahead.`, toolCalls),
+	})
+
+	resp, err := a.client.SendChatRequest(a.operationContext(), a.optimizer.OptimizeConversation(a.messages), nil, "")
+	if err != nil {
+		return "", fmt.Errorf("API request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response choices returned")
+	}
+	a.totalCost += resp.Usage.EstimatedCost
+	a.totalTokens += resp.Usage.TotalTokens
+	a.promptTokens += resp.Usage.PromptTokens
+	a.completionTokens += resp.Usage.CompletionTokens
+
+	summary := resp.Choices[0].Message.Content
+	a.messages = append(a.messages, api.Message{Role: "assistant", Content: summary})
+	a.pairedPaused = true
+
+	fmt.Printf("\n⏸️  Paused after %d tool calls (paired mode) - reply to continue, or give new directions\n", toolCalls)
+	return summary, nil
+}
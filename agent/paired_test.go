@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestSetTurnLimit(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	defer os.Unsetenv("OPENROUTER_API_KEY")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation failure: %v", err)
+	}
+
+	if agent.GetTurnLimit() != 0 {
+		t.Errorf("expected autonomous mode by default, got turn limit %d", agent.GetTurnLimit())
+	}
+
+	agent.SetTurnLimit(3)
+	if agent.GetTurnLimit() != 3 {
+		t.Errorf("expected turn limit 3, got %d", agent.GetTurnLimit())
+	}
+
+	// Leaving paired mode drops a pending pause so the next query starts fresh
+	agent.pairedPaused = true
+	agent.SetTurnLimit(0)
+	if agent.IsPaused() {
+		t.Error("expected switching to autonomous mode to clear the pause")
+	}
+
+	agent.SetTurnLimit(-2)
+	if agent.GetTurnLimit() != 0 {
+		t.Errorf("expected negative limits to mean autonomous mode, got %d", agent.GetTurnLimit())
+	}
+}
+
+func TestProcessQueryPausesAfterTurnLimit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		os.WriteFile(name, []byte("contents of "+name+"\n"), 0644)
+	}
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"tool_calls": [{"name": "read_file", "arguments": {"file_path": "a.txt"}}, {"name": "read_file", "arguments": {"file_path": "b.txt"}}]},
+		{"expect": "PAUSE: you have made 2 tool calls", "content": "Found a.txt and b.txt; next I will read the file c.txt."},
+		{"expect": "USER REPLY (go-ahead or new directions): go ahead", "tool_calls": [{"name": "read_file", "arguments": {"file_path": "c.txt"}}]},
+		{"expect": "contents of c.txt", "content": "Done: the file c.txt was read as well."}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.SetTurnLimit(2)
+
+	summary, err := agent.ProcessQuery("Read the text files")
+	if err != nil {
+		t.Fatalf("ProcessQuery: %v", err)
+	}
+	if !agent.IsPaused() || !strings.Contains(summary, "next I will read") {
+		t.Fatalf("expected a pause after 2 tool calls, got paused=%v, %q", agent.IsPaused(), summary)
+	}
+
+	// The reply continues the paused conversation with a fresh budget
+	result, err := agent.ProcessQuery("go ahead")
+	if err != nil {
+		t.Fatalf("ProcessQuery after the pause: %v", err)
+	}
+	if agent.IsPaused() || !strings.Contains(result, "c.txt was read") {
+		t.Errorf("expected the task to finish, got paused=%v, %q", agent.IsPaused(), result)
+	}
+	if agent.messages[1].Content != "Read the text files" {
+		t.Errorf("expected the continued conversation to keep the original query, got %q", agent.messages[1].Content)
+	}
+}
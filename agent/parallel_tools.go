@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// prefParallelTools caps how many read-only tool calls run at once (1 = one at a time)
+const prefParallelTools = "parallel_tools"
+
+// defaultParallelTools keeps bulk reads fast without opening many files at once
+const defaultParallelTools = 4
+
+// parallelSafeTools only read files, so consecutive calls to them can run
+// concurrently. Anything that writes, runs commands or updates todos runs on
+// its own, in order.
+var parallelSafeTools = map[string]bool{
+	"read_file":         true,
+	"read_notebook":     true,
+	"fetch_full_result": true,
+	"list_directory":    true,
+	"glob":              true,
+	"search_code":       true,
+}
+
+// parallelToolLimit returns the configured number of concurrent tool calls
+func (a *Agent) parallelToolLimit() int {
+	if a.configManager == nil {
+		return defaultParallelTools
+	}
+	return a.configManager.GetConfig().GetIntPreference(prefParallelTools, defaultParallelTools)
+}
+
+// parallelBatchSize returns how many of the leading tool calls can run
+// together: the run of read-only calls at the front, or just the first call
+func (a *Agent) parallelBatchSize(toolCalls []api.ToolCall) int {
+	if a.parallelToolLimit() < 2 {
+		return 1
+	}
+	n := 0
+	for n < len(toolCalls) && parallelSafeTools[toolCalls[n].Function.Name] {
+		n++
+	}
+	return max(n, 1)
+}
+
+// runToolCalls runs a batch of tool calls with at most parallelToolLimit in
+// flight. Outcomes are returned in call order.
+func (a *Agent) runToolCalls(toolCalls []api.ToolCall) []toolOutcome {
+	outcomes := make([]toolOutcome, len(toolCalls))
+	if len(toolCalls) == 1 {
+		outcomes[0] = a.runToolCall(toolCalls[0])
+		return outcomes
+	}
+
+	a.debugLog("⚡ Running %d read-only tool calls in parallel\n", len(toolCalls))
+	slots := make(chan struct{}, a.parallelToolLimit())
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var toolPanic interface{}
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			// A panic would end the program from here; it is raised again below
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() { toolPanic = r })
+				}
+			}()
+			outcomes[i] = a.runToolCall(toolCall)
+		}()
+	}
+	wg.Wait()
+	if toolPanic != nil {
+		panic(toolPanic)
+	}
+	return outcomes
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestParallelToolCalls(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	call := func(id, name, arguments string) api.ToolCall {
+		toolCall := api.ToolCall{ID: id, Type: "function"}
+		toolCall.Function.Name = name
+		toolCall.Function.Arguments = arguments
+		return toolCall
+	}
+	var calls []api.ToolCall
+	for i := 0; i < 6; i++ {
+		path := fmt.Sprintf("file%d.txt", i)
+		os.WriteFile(path, []byte(fmt.Sprintf("contents of %d\n", i)), 0644)
+		calls = append(calls, call(fmt.Sprintf("call_%d", i), "read_file", fmt.Sprintf(`{"file_path": %q}`, path)))
+	}
+	calls = append(calls, call("call_write", "write_file", `{"file_path": "out.txt", "content": "done\n"}`),
+		call("call_last", "read_file", `{"file_path": "out.txt"}`))
+
+	if n := agent.parallelBatchSize(calls); n != 6 {
+		t.Errorf("expected the six leading reads in one batch, got %d", n)
+	}
+	if n := agent.parallelBatchSize(calls[6:]); n != 1 {
+		t.Errorf("expected a write to run on its own, got %d", n)
+	}
+
+	agent.messages = []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "Read the files"}}
+	agent.executeToolCalls(calls)
+	results := agent.messages[2:]
+	if len(results) != len(calls) {
+		t.Fatalf("expected one result per call, got %d", len(results))
+	}
+	for i := 0; i < 6; i++ {
+		if !strings.Contains(results[i].Content, fmt.Sprintf("file%d.txt\ncontents of %d", i, i)) {
+			t.Errorf("result %d is out of order: %q", i, results[i].Content)
+		}
+	}
+	// The read after the write sees what was written
+	if !strings.Contains(results[7].Content, "out.txt\ndone") {
+		t.Errorf("expected the last read to follow the write, got %q", results[7].Content)
+	}
+
+	agent.configManager.GetConfig().Preferences[prefParallelTools] = 1
+	if n := agent.parallelBatchSize(calls); n != 1 {
+		t.Errorf("expected parallel_tools=1 to run calls one at a time, got %d", n)
+	}
+}
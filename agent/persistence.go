@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
 )
 
 // ConversationState represents the state of a conversation that can be persisted
@@ -67,7 +68,7 @@ func (a *Agent) SaveState(sessionID string) error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 	
-	return os.WriteFile(stateFile, data, 0600)
+	return config.WriteFileAtomic(stateFile, data, 0600)
 }
 
 // LoadState loads a conversation state by session ID
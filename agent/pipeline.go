@@ -0,0 +1,329 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+// maxReviewDiffChars caps how much of the change set is shown to the reviewer
+const maxReviewDiffChars = 40000
+
+// PipelineConfig selects the model for each pipeline stage. An empty stage
+// model means Model, the session's model.
+type PipelineConfig struct {
+	Provider         api.ClientType // the session's provider ("" = the best available one)
+	Model            string         // the session's model ("" = the provider's configured model)
+	PlannerModel     string
+	ImplementerModel string
+	ReviewerModel    string
+	ReviewRounds     int    // how many times review findings are sent back for fixing
+	AutoApprove      bool   // approve high-risk actions without asking, as with --yes
+	Sandbox          string // sandbox mode set with --sandbox ("" = the sandbox preference)
+}
+
+// LoadPipelineConfig reads the pipeline settings from the config preferences
+func LoadPipelineConfig(cfg *config.Config) PipelineConfig {
+	pipeline := PipelineConfig{ReviewRounds: 1}
+	if cfg == nil {
+		return pipeline
+	}
+	pipeline.PlannerModel = cfg.GetStringPreference("pipeline_planner_model", "")
+	pipeline.ImplementerModel = cfg.GetStringPreference("pipeline_implementer_model", "")
+	pipeline.ReviewerModel = cfg.GetStringPreference("pipeline_reviewer_model", "")
+	pipeline.ReviewRounds = cfg.GetIntPreference("pipeline_review_rounds", pipeline.ReviewRounds)
+	return pipeline
+}
+
+// Plan is the planner's artifact handed to the implementer
+type Plan struct {
+	Summary string     `json:"summary"`
+	Steps   []PlanStep `json:"steps"`
+	Risks   []string   `json:"risks"`
+}
+
+// PlanStep is one unit of implementation work
+type PlanStep struct {
+	Title   string   `json:"title"`
+	Files   []string `json:"files"`
+	Details string   `json:"details"`
+}
+
+// Review is the reviewer's artifact
+type Review struct {
+	Approved bool            `json:"approved"`
+	Summary  string          `json:"summary"`
+	Findings []ReviewFinding `json:"findings"`
+}
+
+// ReviewFinding is a problem the reviewer wants fixed
+type ReviewFinding struct {
+	Severity   string `json:"severity"`
+	File       string `json:"file"`
+	Issue      string `json:"issue"`
+	Suggestion string `json:"suggestion"`
+}
+
+// PipelineResult collects the artifacts of a pipeline run
+type PipelineResult struct {
+	Plan           Plan
+	Implementation string
+	Reviews        []Review
+	TotalCost      float64
+}
+
+// planSchema is the structured output requested from the planner
+var planSchema = api.ResponseSchema{
+	Name: "implementation_plan",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"summary": map[string]interface{}{"type": "string"},
+			"steps": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"title":   map[string]interface{}{"type": "string"},
+						"files":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+						"details": map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"title", "files", "details"},
+					"additionalProperties": false,
+				},
+			},
+			"risks": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+		"required":             []string{"summary", "steps", "risks"},
+		"additionalProperties": false,
+	},
+}
+
+// reviewSchema is the structured output requested from the reviewer
+var reviewSchema = api.ResponseSchema{
+	Name: "code_review",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"approved": map[string]interface{}{"type": "boolean"},
+			"summary":  map[string]interface{}{"type": "string"},
+			"findings": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"severity":   map[string]interface{}{"type": "string", "enum": []string{"blocker", "major", "minor"}},
+						"file":       map[string]interface{}{"type": "string"},
+						"issue":      map[string]interface{}{"type": "string"},
+						"suggestion": map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"severity", "file", "issue", "suggestion"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"approved", "summary", "findings"},
+		"additionalProperties": false,
+	},
+}
+
+// RunPipeline hands a task through separate planner, implementer and reviewer
+// agents. The planner explores without editing and produces a structured plan,
+// the implementer carries it out, and the reviewer checks the resulting changes;
+// blocking findings go back to the implementer for up to ReviewRounds rounds.
+func RunPipeline(task string, cfg PipelineConfig) (*PipelineResult, error) {
+	result := &PipelineResult{}
+
+	// Stage 1: plan
+	fmt.Println("\n🧭 Pipeline stage 1/3: planning")
+	planner, err := newPlanner(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create planner: %w", err)
+	}
+	if _, err := planner.ProcessQuery(plannerPrompt(task)); err != nil {
+		return nil, fmt.Errorf("planning failed: %w", err)
+	}
+	if err := planner.GenerateStructuredFromConversation("Write the implementation plan for the task based on your exploration.", planSchema, &result.Plan); err != nil {
+		return nil, fmt.Errorf("planner did not produce a plan: %w", err)
+	}
+	result.TotalCost += planner.GetTotalCost()
+	printPlan(result.Plan)
+
+	// Stage 2: implement
+	fmt.Println("\n🛠️  Pipeline stage 2/3: implementing")
+	implementer, err := newStageAgent(cfg, cfg.ImplementerModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create implementer: %w", err)
+	}
+	planJSON, _ := json.MarshalIndent(result.Plan, "", "  ")
+	var planned []string
+	for _, step := range result.Plan.Steps {
+		planned = append(planned, step.Files...)
+	}
+	implementer.prefetchPlan(planned, string(planJSON))
+	result.Implementation, err = implementer.ProcessQuery(fmt.Sprintf("TASK:\n%s\n\nImplement the task by following this plan from the planning stage:\n%s", task, planJSON))
+	if err != nil {
+		return nil, fmt.Errorf("implementation failed: %w", err)
+	}
+
+	// Stage 3: review, sending blocking findings back for fixes
+	reviewer, err := newStageAgent(cfg, cfg.ReviewerModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reviewer: %w", err)
+	}
+	for round := 0; ; round++ {
+		fmt.Printf("\n🔍 Pipeline stage 3/3: review (round %d)\n", round+1)
+		changes, err := implementer.GetWorkspaceChanges()
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect changes for review: %w", err)
+		}
+
+		var review Review
+		if err := reviewer.GenerateStructuredAs(PromptReview, reviewerPrompt(task, planJSON, result.Implementation, changesForReview(changes)), reviewSchema, &review); err != nil {
+			return nil, fmt.Errorf("review failed: %w", err)
+		}
+		result.Reviews = append(result.Reviews, review)
+		printReview(review)
+
+		if review.Approved || round >= cfg.ReviewRounds {
+			break
+		}
+		findingsJSON, _ := json.MarshalIndent(review.Findings, "", "  ")
+		fmt.Println("\n🛠️  Addressing review findings")
+		result.Implementation, err = implementer.ProcessQuery(fmt.Sprintf("A reviewer checked your changes and asked for fixes. Address each finding:\n%s", findingsJSON))
+		if err != nil {
+			return nil, fmt.Errorf("fixing review findings failed: %w", err)
+		}
+	}
+
+	result.TotalCost += implementer.GetTotalCost() + reviewer.GetTotalCost()
+	return result, nil
+}
+
+// newStageAgent creates the agent for a pipeline stage on the session's
+// provider, with the stage's model or the session's, and the session's
+// approval and sandbox settings
+func newStageAgent(cfg PipelineConfig, model string) (*Agent, error) {
+	if model == "" {
+		model = cfg.Model
+	}
+	stage, err := NewAgentWithProvider(cfg.Provider, model)
+	if err != nil {
+		return nil, err
+	}
+	stage.SetAutoApprove(cfg.AutoApprove)
+	if cfg.Sandbox != "" {
+		if err := stage.SetSandbox(cfg.Sandbox); err != nil {
+			return nil, fmt.Errorf("failed to set up the sandbox: %w", err)
+		}
+	}
+	return stage, nil
+}
+
+// newPlanner creates the planner stage agent. It only explores, so it gets
+// the read-only tools of the explore preset.
+func newPlanner(cfg PipelineConfig) (*Agent, error) {
+	planner, err := newStageAgent(cfg, cfg.PlannerModel)
+	if err != nil {
+		return nil, err
+	}
+	preset, _ := planner.configManager.GetConfig().GetToolPreset("explore")
+	if err := planner.enableTools("explore", preset); err != nil {
+		return nil, fmt.Errorf("failed to restrict the planner's tools: %w", err)
+	}
+	return planner, nil
+}
+
+// plannerPrompt asks for exploration without modifications
+func plannerPrompt(task string) string {
+	return fmt.Sprintf(`You are the PLANNER in a planner -> implementer -> reviewer pipeline.
+Explore the repository with your read-only tools to understand what the task needs.
+Do NOT modify, create or delete any files - a separate implementer will make the changes.
+When you understand the code well enough, reply with a short description of your findings.
+
+TASK:
+%s`, task)
+}
+
+// reviewerPrompt gives the reviewer everything it needs to judge the change
+func reviewerPrompt(task string, planJSON []byte, implementation, changes string) string {
+	return fmt.Sprintf(`You are the REVIEWER in a planner -> implementer -> reviewer pipeline.
+Review the changes below against the task and the plan. Look for bugs, missing plan steps, broken error handling and missing tests.
+Approve only if there are no blocker or major findings.
+
+TASK:
+%s
+
+PLAN:
+%s
+
+IMPLEMENTER'S SUMMARY:
+%s
+
+CHANGES:
+%s`, task, planJSON, implementation, changes)
+}
+
+// changesForReview renders the changed files as diffs (or contents for new files)
+func changesForReview(changes []WorkspaceChange) string {
+	if len(changes) == 0 {
+		return "(no files were changed)"
+	}
+
+	var out strings.Builder
+	for _, change := range changes {
+		if out.Len() > maxReviewDiffChars {
+			fmt.Fprintf(&out, "\n(further changes omitted: %s %s)\n", change.Status, change.Path)
+			continue
+		}
+		fmt.Fprintf(&out, "\n=== %s (%s) ===\n", change.Path, change.Status)
+		switch change.Status {
+		case "deleted":
+			continue
+		case "modified":
+			if diff, err := exec.Command("git", "diff", "HEAD", "--", change.Path).Output(); err == nil && len(diff) > 0 {
+				out.Write(diff)
+				continue
+			}
+		}
+		if content, err := os.ReadFile(change.Path); err == nil {
+			out.Write(content)
+		}
+	}
+	if out.Len() > maxReviewDiffChars {
+		return truncateMiddle(out.String(), maxReviewDiffChars)
+	}
+	return out.String()
+}
+
+// printPlan shows the planner's output
+func printPlan(plan Plan) {
+	fmt.Printf("📋 Plan: %s\n", plan.Summary)
+	for i, step := range plan.Steps {
+		fmt.Printf("  %d. %s", i+1, step.Title)
+		if len(step.Files) > 0 {
+			fmt.Printf(" (%s)", strings.Join(step.Files, ", "))
+		}
+		fmt.Println()
+	}
+	for _, risk := range plan.Risks {
+		fmt.Printf("  ⚠️  %s\n", risk)
+	}
+}
+
+// printReview shows the reviewer's verdict
+func printReview(review Review) {
+	if review.Approved {
+		fmt.Printf("✅ Review approved: %s\n", review.Summary)
+	} else {
+		fmt.Printf("❌ Review requested changes: %s\n", review.Summary)
+	}
+	for _, finding := range review.Findings {
+		fmt.Printf("  [%s] %s: %s\n", finding.Severity, finding.File, finding.Issue)
+	}
+}
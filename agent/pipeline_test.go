@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+func TestChangesForReview(t *testing.T) {
+	dir := t.TempDir()
+	added := filepath.Join(dir, "new.go")
+	if err := os.WriteFile(added, []byte("package demo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := changesForReview([]WorkspaceChange{
+		{Path: added, Status: "added"},
+		{Path: filepath.Join(dir, "old.go"), Status: "deleted"},
+	})
+	if !strings.Contains(got, "new.go (added) ===\npackage demo") {
+		t.Errorf("expected added file content in review input, got:\n%s", got)
+	}
+	if !strings.Contains(got, "old.go (deleted) ===") {
+		t.Errorf("expected deleted file to be listed, got:\n%s", got)
+	}
+
+	if got := changesForReview(nil); got != "(no files were changed)" {
+		t.Errorf("unexpected review input for no changes: %q", got)
+	}
+}
+
+func TestLoadPipelineConfig(t *testing.T) {
+	cfg := &config.Config{Preferences: map[string]interface{}{
+		"pipeline_reviewer_model": "strong-model",
+		"pipeline_review_rounds":  float64(3),
+	}}
+	got := LoadPipelineConfig(cfg)
+	if got.ReviewerModel != "strong-model" || got.ReviewRounds != 3 || got.PlannerModel != "" {
+		t.Errorf("unexpected pipeline config: %+v", got)
+	}
+	if LoadPipelineConfig(nil).ReviewRounds != 1 {
+		t.Error("expected one review round by default")
+	}
+}
+
+func TestPipelineStagesUseTheSessionProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Chdir(t.TempDir())
+	cfg := PipelineConfig{Provider: api.OpenRouterClientType, Model: "session/model", ReviewerModel: "review/model"}
+
+	planner, err := newPlanner(cfg)
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer planner.CloseInstance()
+	if planner.GetProviderType() != api.OpenRouterClientType || planner.GetModel() != "session/model" {
+		t.Errorf("expected the planner on the session's provider and model, got %s %s", planner.GetProviderType(), planner.GetModel())
+	}
+	for _, tool := range []string{"write_file", "edit_file", "apply_patch"} {
+		if planner.IsToolEnabled(tool) {
+			t.Errorf("expected the planner not to have %s", tool)
+		}
+	}
+	if !planner.IsToolEnabled("read_file") {
+		t.Error("expected the planner to read files")
+	}
+
+	reviewer, err := newStageAgent(cfg, cfg.ReviewerModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reviewer.CloseInstance()
+	if reviewer.GetProviderType() != api.OpenRouterClientType || reviewer.GetModel() != "review/model" {
+		t.Errorf("expected the reviewer's own model on the session's provider, got %s %s", reviewer.GetProviderType(), reviewer.GetModel())
+	}
+}
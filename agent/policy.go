@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alantheprice/coder/policy"
+)
+
+// policyInput describes a tool call to the project's policies
+func (a *Agent) policyInput(toolName string, args map[string]interface{}) policy.Input {
+	return policy.Input{
+		Tool:     toolName,
+		Path:     policyPath(stringArg(args, "file_path", "path", "image_path")),
+		Command:  stringArg(args, "command", "cmd"),
+		Size:     changedLines(args),
+		Provider: a.GetProvider(),
+		Model:    a.GetModel(),
+	}
+}
+
+// policyPath cleans a path the model wrote and makes it relative to the
+// project root, with forward slashes, so "./db//x.sql" and "/repo/db/x.sql"
+// match rules written for "db/x.sql". Paths outside the project stay absolute.
+func policyPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	path = filepath.Clean(path)
+	if root, err := os.Getwd(); err == nil {
+		abs := path
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(root, abs)
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+		path = abs
+	}
+	return filepath.ToSlash(path)
+}
+
+// authorizeToolCall applies the project's policies to a tool call, then the
+// risk classifier for calls no policy denied. The policies come from the
+// repository, which may not be trusted, so an allow rule settles the call
+// against later rules but can't skip the confirmation of a high-risk call.
+func (a *Agent) authorizeToolCall(toolName string, args map[string]interface{}) error {
+	detail := stringArg(args, "command", "cmd", "file_path", "path")
+	if toolName == "apply_patch" {
+		detail = strings.Join(changedPaths(args), ", ")
+	}
+	decision := a.policyDecision(toolName, args)
+	switch decision.Action {
+	case policy.Deny:
+		return fmt.Errorf("%s was blocked by %s", toolName, decision.Reason())
+	case policy.Allow:
+		a.debugLog("✅ %s allowed by %s, pending the risk check\n", toolName, decision.Reason())
+	case policy.RequireApproval:
+		risk := assessToolRisk(toolName, args)
+		risk.Level = RiskHigh
+		risk.Reasons = append(risk.Reasons, decision.Reason())
+		risk.Required = true
+		why, _ := args["why"].(string)
+		if !a.approveAction(toolName, detail, why, risk) {
+			return fmt.Errorf("%s was not approved: %s", toolName, detail)
+		}
+		return nil
+	}
+
+	if !a.approveToolCall(toolName, args) {
+		return fmt.Errorf("%s was not approved: %s", toolName, detail)
+	}
+	return nil
+}
+
+// policyDecision evaluates the policies for a tool call. A patch is
+// evaluated once per file it changes: a denial or approval requirement for
+// any file applies to the whole patch, and it is allowed only if every file is.
+func (a *Agent) policyDecision(toolName string, args map[string]interface{}) policy.Decision {
+	input := a.policyInput(toolName, args)
+	if toolName != "apply_patch" {
+		return a.policies.Evaluate(input)
+	}
+	var result policy.Decision
+	allowed := 0
+	paths := changedPaths(args)
+	for _, path := range paths {
+		input.Path = policyPath(path)
+		decision := a.policies.Evaluate(input)
+		switch decision.Action {
+		case policy.Deny:
+			return decision
+		case policy.RequireApproval:
+			result = decision
+		case policy.Allow:
+			allowed++
+			if result.Action == "" && allowed == len(paths) {
+				result = decision
+			}
+		}
+	}
+	return result
+}
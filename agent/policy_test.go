@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/policy"
+)
+
+func TestPolicyExpressions(t *testing.T) {
+	vars := map[string]interface{}{
+		"tool":     "shell_command",
+		"command":  "git push origin main",
+		"path":     "",
+		"size":     12,
+		"provider": "openrouter",
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`tool == "shell_command" && command.startsWith("git push")`, true},
+		{`tool == 'edit_file' || size > 10`, true},
+		{`!(size <= 12)`, false},
+		{`provider in ["openrouter", "deepinfra"]`, true},
+		{`provider in ["ollama"]`, false},
+		{`command.matches("push\\s+origin\\s+(main|master)$")`, true},
+		{`command.contains("--force")`, false},
+		{`size(path) == 0 && path.endsWith("")`, true},
+	}
+	for _, tt := range tests {
+		expr, err := policy.Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%s) failed: %v", tt.expr, err)
+			continue
+		}
+		got, err := expr.Eval(vars)
+		if err != nil || got != tt.want {
+			t.Errorf("Eval(%s) = %v, %v; want %v", tt.expr, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`tool ==`, `command.startsWith("x"`, `"unterminated`, `size > 1 1`} {
+		if _, err := policy.Compile(bad); err == nil {
+			t.Errorf("expected Compile(%s) to fail", bad)
+		}
+	}
+	if expr, _ := policy.Compile(`pathh == "x"`); expr != nil {
+		if _, err := expr.Eval(vars); err == nil {
+			t.Error("expected an unknown variable to be an error")
+		}
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	set, err := policy.Parse([]byte(`{"rules": [
+		{"name": "no-force-push", "when": "command.contains('push --force')", "action": "deny", "message": "force pushes are not allowed"},
+		{"name": "migrations", "when": "path.startsWith('db/migrations/')", "action": "require_approval"},
+		{"name": "typo", "when": "tool == 'read_file' && pth == 'x'", "action": "allow"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	decision := set.Evaluate(policy.Input{Tool: "shell_command", Command: "git push --force"})
+	if decision.Action != policy.Deny || !strings.Contains(decision.Reason(), "force pushes are not allowed") {
+		t.Errorf("expected force push to be denied, got %+v", decision)
+	}
+	if decision := set.Evaluate(policy.Input{Tool: "edit_file", Path: "db/migrations/001.sql"}); decision.Action != policy.RequireApproval {
+		t.Errorf("expected migration edit to require approval, got %q", decision.Action)
+	}
+	// The broken third rule fails closed for calls the first two do not decide
+	if decision := set.Evaluate(policy.Input{Tool: "read_file", Path: "main.go"}); decision.Action != policy.Deny {
+		t.Errorf("expected a rule that fails to evaluate to deny, got %q", decision.Action)
+	}
+
+	var none *policy.Set
+	if decision := none.Evaluate(policy.Input{Tool: "read_file"}); decision.Action != "" {
+		t.Errorf("expected no decision without policies, got %q", decision.Action)
+	}
+
+	if _, err := policy.Parse([]byte(`{"rules": [{"name": "x", "when": "true", "action": "maybe"}]}`)); err == nil {
+		t.Error("expected an unknown action to be rejected")
+	}
+	if _, err := policy.Parse([]byte(`{"rules": [{"name": "x", "when": "pth == 'x'", "action": "deny"}]}`)); err == nil {
+		t.Error("expected a misspelled variable to be rejected when loading")
+	}
+}
+
+func TestPolicyParseRejectsNullRule(t *testing.T) {
+	if _, err := policy.Parse([]byte(`{"rules": [null]}`)); err == nil || !strings.Contains(err.Error(), "rule 1 is empty") {
+		t.Errorf("expected a null rule to be rejected, got %v", err)
+	}
+}
+
+func TestPolicyPathsAreNormalized(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	root, _ = os.Getwd()
+	set, err := policy.Parse([]byte(`{"rules": [{"name": "migrations", "when": "path.startsWith('db/migrations/')", "action": "deny"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.policies = set
+	for _, path := range []string{"db/migrations/x.sql", "./db/migrations/x.sql", "db//migrations/x.sql", "src/../db/migrations/x.sql", filepath.Join(root, "db/migrations/x.sql")} {
+		if decision := agent.policyDecision("edit_file", map[string]interface{}{"file_path": path}); decision.Action != policy.Deny {
+			t.Errorf("expected %s to be denied, got %q", path, decision.Action)
+		}
+	}
+	patch := "--- a/./db/migrations/x.sql\n+++ b/./db/migrations/x.sql\n@@ -1 +1 @@\n-a\n+b\n"
+	if decision := agent.policyDecision("apply_patch", map[string]interface{}{"patch": patch}); decision.Action != policy.Deny {
+		t.Errorf("expected the patch to be denied, got %q", decision.Action)
+	}
+	if got := policyPath("/etc/passwd"); got != "/etc/passwd" {
+		t.Errorf("expected a path outside the project to stay absolute, got %s", got)
+	}
+}
+
+func TestProjectAllowDoesNotSkipRiskConfirmation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Chdir(t.TempDir())
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.policies, err = policy.Parse([]byte(`{"rules": [{"name": "anything-goes", "when": "true", "action": "allow"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var asked []string
+	agent.SetApprovalHandler(func(toolName, detail string) bool {
+		asked = append(asked, detail)
+		return false
+	})
+
+	if err := agent.authorizeToolCall("shell_command", map[string]interface{}{"command": "rm -rf build"}); err == nil {
+		t.Error("expected the rejected high-risk command to be refused despite the allow rule")
+	}
+	if err := agent.authorizeToolCall("read_file", map[string]interface{}{"file_path": "main.go"}); err != nil {
+		t.Errorf("expected a low-risk call to be allowed, got %v", err)
+	}
+	if len(asked) != 1 || !strings.Contains(asked[0], "rm -rf build") {
+		t.Errorf("expected only the high-risk command to be confirmed, asked %q", asked)
+	}
+}
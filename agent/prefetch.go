@@ -0,0 +1,200 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// prefPrefetch turns speculative prefetching of planned files on or off
+const prefPrefetch = "prefetch"
+
+const (
+	maxPrefetchFiles       = 40 // files read ahead per plan
+	maxPrefetchSymbolFiles = 3  // files read ahead per symbol the plan names
+	prefetchWorkers        = 4
+	prefetchSearchTimeout  = 10 * time.Second
+)
+
+var (
+	// planPathPattern matches path-like words such as agent/tools.go or README.md
+	planPathPattern = regexp.MustCompile(`(?:[\w.-]+/)*[\w-][\w.-]*\.[A-Za-z0-9]{1,8}\b`)
+	// planSymbolPattern matches identifiers a plan quotes in backticks
+	planSymbolPattern = regexp.MustCompile("`([A-Za-z_][A-Za-z0-9_]{3,})(?:\\(\\))?`")
+)
+
+// prefetchedFile is a file read ahead of the read_file call that wants it
+type prefetchedFile struct {
+	content string
+	modTime time.Time
+	size    int64
+}
+
+// prefetchCache holds prefetched files until read_file takes them
+type prefetchCache struct {
+	mu    sync.Mutex
+	files map[string]prefetchedFile
+}
+
+// store keeps a file's content with the stat it was read at
+func (c *prefetchCache) store(path, content string, info os.FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.files == nil {
+		c.files = make(map[string]prefetchedFile)
+	}
+	c.files[path] = prefetchedFile{content: content, modTime: info.ModTime(), size: info.Size()}
+}
+
+// take removes and returns a file's prefetched content, if the file hasn't
+// changed since it was read
+func (c *prefetchCache) take(path string) (string, bool) {
+	c.mu.Lock()
+	file, ok := c.files[path]
+	delete(c.files, path)
+	c.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.ModTime().Equal(file.modTime) || info.Size() != file.size {
+		return "", false
+	}
+	return file.content, true
+}
+
+// prefetchEnabled reports whether the prefetch preference is on (default true)
+func (a *Agent) prefetchEnabled() bool {
+	return a.configManager == nil || a.configManager.GetConfig().GetBoolPreference(prefPrefetch, true)
+}
+
+// prefetchPlan reads the files a plan mentions in the background, so the
+// read_file calls that follow return at once. Files are named directly or
+// found through the symbols the plan quotes. It returns a channel closed when
+// prefetching finishes, or nil when there's nothing to do.
+func (a *Agent) prefetchPlan(files []string, text string) <-chan struct{} {
+	if !a.prefetchEnabled() {
+		return nil
+	}
+	paths := planFiles(files, text)
+	symbols := planSymbols(text)
+	if len(paths) == 0 && len(symbols) == 0 {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		paths = append(paths, filesMentioning(symbols)...)
+		a.prefetchFiles(paths)
+	}()
+	return done
+}
+
+// prefetchFiles reads files into the prefetch cache, a few at a time
+func (a *Agent) prefetchFiles(paths []string) {
+	seen := make(map[string]bool)
+	var queue []string
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if seen[path] || len(queue) >= maxPrefetchFiles {
+			continue
+		}
+		seen[path] = true
+		queue = append(queue, path)
+	}
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < prefetchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				info, err := os.Stat(path)
+				if err != nil || info.IsDir() {
+					continue
+				}
+				// ReadFile applies read_file's size and text checks
+				content, err := tools.ReadFile(path)
+				if err != nil {
+					continue
+				}
+				a.prefetched.store(path, content, info)
+			}
+		}()
+	}
+	for _, path := range queue {
+		work <- path
+	}
+	close(work)
+	wg.Wait()
+	a.debugLog("⚡ Prefetched %d planned file(s)\n", len(queue))
+}
+
+// readFile serves read_file from the prefetch cache when the file hasn't
+// changed since it was prefetched, and from disk otherwise
+func (a *Agent) readFile(path string) (string, error) {
+	if content, ok := a.prefetched.take(filepath.Clean(path)); ok {
+		a.debugLog("⚡ %s served from prefetch\n", path)
+		return content, nil
+	}
+	return tools.ReadFile(path)
+}
+
+// planFiles collects the project files a plan names, both listed and
+// mentioned in its text
+func planFiles(files []string, text string) []string {
+	candidates := append(append([]string(nil), files...), planPathPattern.FindAllString(text, -1)...)
+	var paths []string
+	for _, path := range candidates {
+		path = strings.Trim(strings.TrimSpace(path), "`'\"")
+		if path == "" || isOutsideProject(path) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// planSymbols collects the identifiers a plan quotes in backticks
+func planSymbols(text string) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, match := range planSymbolPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			symbols = append(symbols, match[1])
+		}
+	}
+	return symbols
+}
+
+// filesMentioning finds a few tracked files that mention each symbol as a
+// whole word. Outside a git repository it finds nothing.
+func filesMentioning(symbols []string) []string {
+	var files []string
+	for _, symbol := range symbols {
+		ctx, cancel := context.WithTimeout(context.Background(), prefetchSearchTimeout)
+		output, err := exec.CommandContext(ctx, "git", "grep", "-l", "-I", "-w", "-e", symbol).Output()
+		cancel()
+		if err != nil {
+			continue
+		}
+		matches := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if len(matches) > maxPrefetchSymbolFiles {
+			matches = matches[:maxPrefetchSymbolFiles]
+		}
+		files = append(files, matches...)
+	}
+	return files
+}
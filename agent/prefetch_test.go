@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefetchPlan(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	os.MkdirAll(filepath.Join(root, "api"), 0755)
+	os.WriteFile(filepath.Join(root, "api", "routes.go"), []byte("package api\n"), 0644)
+	os.WriteFile(filepath.Join(root, "widget.go"), []byte("package main\n\nfunc ServeWidget() {}\n"), 0644)
+	os.WriteFile(filepath.Join(root, "unrelated.go"), []byte("package main\n"), 0644)
+	if err := exec.Command("sh", "-c", "git init -q && git add .").Run(); err != nil {
+		t.Skipf("git unavailable: %v", err)
+	}
+
+	a := &Agent{}
+	done := a.prefetchPlan([]string{"api/routes.go", "missing.go"}, "Then update `ServeWidget` to take options, see docs/none.md")
+	if done == nil {
+		t.Fatal("expected prefetching to start")
+	}
+	<-done
+	if len(a.prefetched.files) != 2 {
+		t.Fatalf("expected the listed file and the symbol's file to be prefetched, got %v", a.prefetched.files)
+	}
+
+	// A prefetched read is served once; a changed file is read from disk
+	if content, err := a.readFile("api/routes.go"); err != nil || content != "package api\n" {
+		t.Errorf("unexpected read: %q, %v", content, err)
+	}
+	if _, ok := a.prefetched.files["api/routes.go"]; ok {
+		t.Error("expected the prefetched copy to be used up by the read")
+	}
+	os.WriteFile(filepath.Join(root, "widget.go"), []byte("package main\n\nfunc ServeWidget(opts Options) {}\n"), 0644)
+	if content, _ := a.readFile("widget.go"); content != "package main\n\nfunc ServeWidget(opts Options) {}\n" {
+		t.Errorf("expected the changed file from disk, got %q", content)
+	}
+
+	if a.prefetchPlan(nil, "Nothing to read here") != nil {
+		t.Error("expected no prefetching for a plan without files or symbols")
+	}
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		version, constraint string
+		want                bool
+	}{
+		{"1.24.1", ">=1.22", true},
+		{"1.21.9", ">=1.22", false},
+		{"20.11.0", "20", true},
+		{"18.19.0", "v20", false},
+		{"20.11.0", "^20", true},
+		{"21.0.0", "^20", false},
+		{"1.22.5", "~1.22", true},
+		{"1.23.0", "~1.22.1", false},
+		{"1.22.5", "1.22.x", true},
+		{"1.9.0", "<2", true},
+		{"2.1.0", "<2", false},
+		{"16.0.0", ">=18 <21 || 16", true},
+		{"17.0.0", ">=18 <21 || =16", false},
+		{"3.12.1", "=3.12", true},
+		{"3.0.0", "*", true},
+	}
+	for _, tt := range tests {
+		got, err := tools.VersionSatisfies(tt.version, tt.constraint)
+		if err != nil {
+			t.Errorf("VersionSatisfies(%s, %s): %v", tt.version, tt.constraint, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("VersionSatisfies(%s, %s) = %v, want %v", tt.version, tt.constraint, got, tt.want)
+		}
+	}
+	if _, err := tools.VersionSatisfies("1.0.0", "!=1"); err == nil {
+		t.Error("expected an error for an unknown operator")
+	}
+}
+
+func TestLoadPrerequisites(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		tools.PrerequisitesFile: `{"tools": [{"name": "node", "version": ">=22", "install": "nvm install 22"}, {"name": "protoc"}]}`,
+		"go.mod":                "module example.com/x\n\ngo 1.23.4\n",
+		"package.json":          `{"engines": {"node": ">=18"}}`,
+		".tool-versions":        "terraform 1.7.0 # pinned\nnodejs 20.1.0\n",
+		"compose.yaml":          "services: {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prerequisites, err := tools.LoadPrerequisites(root)
+	if err != nil {
+		t.Fatalf("LoadPrerequisites: %v", err)
+	}
+	got := make(map[string]tools.Prerequisite)
+	for _, p := range prerequisites {
+		got[p.Name] = p
+	}
+	if len(got) != 5 {
+		t.Errorf("expected node, protoc, terraform, go and docker, got %+v", prerequisites)
+	}
+	if node := got["node"]; node.Version != ">=22" || node.Source != tools.PrerequisitesFile || node.Install != "nvm install 22" {
+		t.Errorf("the declared node requirement should win: %+v", node)
+	}
+	if goReq := got["go"]; goReq.Version != ">=1.23.4" || goReq.Source != "go.mod" {
+		t.Errorf("go requirement: %+v", goReq)
+	}
+	if tf := got["terraform"]; tf.Version != "1.7.0" {
+		t.Errorf("terraform requirement: %+v", tf)
+	}
+	if docker := got["docker"]; docker.Source != "compose.yaml" {
+		t.Errorf("docker requirement: %+v", docker)
+	}
+
+	os.WriteFile(filepath.Join(root, tools.PrerequisitesFile), []byte("{"), 0644)
+	if _, err := tools.LoadPrerequisites(root); err == nil {
+		t.Error("expected an error for an invalid prerequisites file")
+	}
+}
+
+func TestCheckPrerequisite(t *testing.T) {
+	ctx := context.Background()
+	status := tools.CheckPrerequisite(ctx, tools.Prerequisite{Name: "go", Version: ">=1.0"}, nil)
+	if !status.OK || status.Installed == "" {
+		t.Errorf("go should satisfy >=1.0: %+v", status)
+	}
+	status = tools.CheckPrerequisite(ctx, tools.Prerequisite{Name: "go", Version: ">=999"}, nil)
+	if status.OK || status.Missing || status.Problem == "" {
+		t.Errorf("go shouldn't satisfy >=999: %+v", status)
+	}
+	status = tools.CheckPrerequisite(ctx, tools.Prerequisite{Name: "coder-no-such-tool"}, nil)
+	if status.OK || !status.Missing {
+		t.Errorf("a missing tool should be reported missing: %+v", status)
+	}
+	var ran []string
+	run := func(command string) (string, error) {
+		ran = append(ran, command)
+		return "custom 2.5.1", nil
+	}
+	status = tools.CheckPrerequisite(ctx, tools.Prerequisite{Name: "custom", Command: "custom-version", Version: "^2"}, run)
+	if !status.OK || status.Installed != "2.5.1" || len(ran) != 1 || ran[0] != "custom-version" {
+		t.Errorf("a custom version command should go through the runner: %+v, ran %v", status, ran)
+	}
+	status = tools.CheckPrerequisite(ctx, tools.Prerequisite{Name: "custom", Command: "custom-version"}, nil)
+	if status.OK || status.Problem == "" {
+		t.Errorf("a custom version command shouldn't run without a runner: %+v", status)
+	}
+}
+
+func TestCheckPrerequisiteDoesNotUseAShell(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	for _, name := range []string{"go; touch " + marker, "$(touch " + marker + ")", "./scripts/evil"} {
+		status := tools.CheckPrerequisite(context.Background(), tools.Prerequisite{Name: name}, nil)
+		if status.OK {
+			t.Errorf("expected %q not to pass: %+v", name, status)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("a tool name was run through a shell")
+	}
+}
+
+func TestRunProjectCommandNeedsApproval(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Chdir(t.TempDir())
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.SetAutoApprove(true)
+
+	var asked []string
+	agent.SetApprovalHandler(func(toolName, detail string) bool {
+		asked = append(asked, detail)
+		return false
+	})
+	if _, err := agent.RunProjectCommand("touch ran", "check a version"); err == nil {
+		t.Error("expected a rejected command to fail")
+	}
+	if _, err := os.Stat("ran"); err == nil {
+		t.Error("a rejected command was run")
+	}
+	if len(asked) != 1 {
+		t.Errorf("expected approval to be asked even with --yes, asked %d times", len(asked))
+	}
+
+	agent.SetApprovalHandler(func(toolName, detail string) bool { return true })
+	if output, err := agent.RunProjectCommand("echo tool 1.2.3", "check a version"); err != nil || output != "tool 1.2.3\n" {
+		t.Errorf("expected an approved command to run, got %q, %v", output, err)
+	}
+}
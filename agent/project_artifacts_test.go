@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/config"
+)
+
+func TestCleanProjectArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".coder", "tmp"), 0755)
+	os.WriteFile(filepath.Join(dir, ".coder", "state.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, ".coder", "policies.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, ".coder_state.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "commit_msg.txt"), []byte("msg"), 0644)
+
+	removed, err := config.CleanProjectArtifacts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 4 {
+		t.Errorf("expected 4 removed artifacts, got %v", removed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".coder", "policies.json")); err != nil {
+		t.Error("expected shared policies to be kept")
+	}
+	for _, path := range []string{".coder/state.json", ".coder/tmp", ".coder_state.json", "commit_msg.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+}
+
+func TestProjectStateDirMigratesLegacyState(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".coder", "instances"), 0755)
+	os.WriteFile(filepath.Join(dir, ".coder", "state.json"), []byte(`{"summary":"repo"}`), 0644)
+	os.WriteFile(filepath.Join(dir, ".coder", "policies.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, ".coder_state.json"), []byte(`{"summary":"root"}`), 0644)
+
+	stateDir, err := config.ProjectStateDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(stateDir, dir) {
+		t.Fatalf("expected the state directory outside the repository, got %s", stateDir)
+	}
+	if data, err := os.ReadFile(filepath.Join(stateDir, "state.json")); err != nil || string(data) != `{"summary":"repo"}` {
+		t.Errorf("expected the repository state to be moved, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "instances")); err != nil {
+		t.Error("expected the instances directory to be moved")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".coder", "policies.json")); err != nil {
+		t.Error("expected shared policies to stay in the repository")
+	}
+	for _, path := range []string{".coder/state.json", ".coder/instances", ".coder_state.json"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be moved out of the repository", path)
+		}
+	}
+	if root, _ := os.ReadFile(filepath.Join(stateDir, "root")); strings.TrimSpace(string(root)) != dir {
+		t.Errorf("expected the state directory to record its project, got %q", root)
+	}
+
+	other, err := config.ProjectStateDir(t.TempDir())
+	if err != nil || other == stateDir {
+		t.Errorf("expected another project to get its own state directory, got %s (%v)", other, err)
+	}
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+func TestParseProjectConfig(t *testing.T) {
+	project, err := config.ParseProjectConfig(`# Shared model selection
+provider: groq
+model: "openai/gpt-oss-120b"  # pinned for consistent reviews
+temperature: 0.2
+`)
+	if err != nil {
+		t.Fatalf("ParseProjectConfig: %v", err)
+	}
+	if project.Provider != api.GroqClientType || project.Model != "openai/gpt-oss-120b" {
+		t.Errorf("unexpected pin: %+v", project)
+	}
+	if project.Temperature == nil || *project.Temperature != 0.2 {
+		t.Errorf("expected temperature 0.2, got %v", project.Temperature)
+	}
+
+	for source, want := range map[string]string{
+		"provider: nowhere":         "unknown provider",
+		"model: gpt-4o":             "without a provider",
+		"temperature: hot":          "between 0 and 2",
+		"provider: groq\nbudget: 5": `unknown key "budget"`,
+		"provider:\n  name: groq":   "nested values",
+	} {
+		if _, err := config.ParseProjectConfig(source); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", source, want, err)
+		}
+	}
+}
+
+func TestProjectPinnedProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	t.Setenv("GROQ_API_KEY", "test-key")
+	t.Setenv("MISTRAL_API_KEY", "")
+
+	if err := os.WriteFile(config.ProjectConfigFile, []byte("provider: groq\nmodel: qwen/qwen3-32b\ntemperature: 0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager, err := config.NewManager()
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	provider, model, err := manager.GetBestProvider()
+	if err != nil || provider != api.GroqClientType || model != "qwen/qwen3-32b" {
+		t.Errorf("expected the pinned groq model, got %s %s %v", provider, model, err)
+	}
+	if !manager.IsPinned(api.GroqClientType) || manager.IsPinned(api.OpenRouterClientType) {
+		t.Error("expected only groq to be pinned")
+	}
+
+	// The pinned temperature overrides the user's model profile
+	client, err := api.NewUnifiedClientWithModel(provider, model)
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	agent := &Agent{client: client, clientType: provider, configManager: manager}
+	manager.GetConfig().ModelProfiles = map[string]map[string]interface{}{"*": {"temperature": 0.9, "top_p": 0.8}}
+	agent.applyModelProfile()
+	if agent.modelProfile["temperature"] != 0.1 || agent.modelProfile["top_p"] != 0.8 {
+		t.Errorf("expected pinned temperature merged into the profile, got %v", agent.modelProfile)
+	}
+
+	// An unavailable pin falls back to the user's own selection
+	if err := os.WriteFile(config.ProjectConfigFile, []byte("provider: mistral\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if manager, err = config.NewManager(); err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if provider, _, err := manager.GetBestProvider(); err != nil || provider == api.MistralClientType {
+		t.Errorf("expected a fallback provider, got %s %v", provider, err)
+	}
+
+	// A broken project config stops startup rather than being ignored
+	if err := os.WriteFile(config.ProjectConfigFile, []byte("provider groq\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.NewManager(); err == nil {
+		t.Error("expected an error for a malformed project config")
+	}
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// prefProjectContext adds the project context file and the previous
+// session's summary to the system prompt of new tasks (on by default)
+const prefProjectContext = "project_context"
+
+// prefProjectContextTokens caps the tokens both may take together
+const prefProjectContextTokens = "project_context_tokens"
+
+// defaultProjectContextTokens is the cap when project_context_tokens is unset
+const defaultProjectContextTokens = 4000
+
+// previousSessionHeader introduces the previous session's summary in the system prompt
+const previousSessionHeader = "PREVIOUS SESSION (what was done last time in this project; check the files before relying on it):"
+
+// projectContextEnabled reports whether new tasks start with the project context
+func (a *Agent) projectContextEnabled() bool {
+	if a.configManager == nil {
+		return true
+	}
+	return a.configManager.GetConfig().GetBoolPreference(prefProjectContext, true)
+}
+
+// projectContextBudget returns the tokens the project context may take
+func (a *Agent) projectContextBudget() int {
+	if a.configManager == nil {
+		return defaultProjectContextTokens
+	}
+	return a.configManager.GetConfig().GetIntPreference(prefProjectContextTokens, defaultProjectContextTokens)
+}
+
+// projectContextForPrompt returns the project context file and the previous
+// session's summary for the system prompt, within the token budget. The file
+// is read for every task, so edits and /init apply without a restart. When
+// both don't fit, the file is cut so that at least a quarter of the budget
+// is left for the summary, which gets whatever the file doesn't use.
+func (a *Agent) projectContextForPrompt() string {
+	if !a.projectContextEnabled() {
+		return ""
+	}
+	budget := a.projectContextBudget()
+	if budget <= 0 {
+		return ""
+	}
+	projectContext := getProjectContext()
+	summary := strings.TrimSpace(a.previousSummary)
+
+	projectBudget := budget
+	if summary != "" {
+		projectBudget = budget - budget/4
+	}
+	var section strings.Builder
+	if projectContext != "" {
+		projectContext = a.cutToTokens(projectContext, projectBudget, "the rest of the project context file was cut to fit project_context_tokens")
+		section.WriteString("\n\n" + projectContext)
+	}
+	if summary != "" {
+		remaining := budget - a.countTokens(projectContext)
+		summary = a.cutToTokens(previousSessionHeader+"\n"+summary, remaining, "the rest of the previous session's summary was cut")
+		if remaining > 0 && summary != "" {
+			section.WriteString("\n\n" + summary)
+		}
+	}
+	return section.String()
+}
+
+// cutToTokens cuts text on a line boundary so it takes at most budget tokens,
+// noting the cut with reason
+func (a *Agent) cutToTokens(text string, budget int, reason string) string {
+	tokens := a.countTokens(text)
+	if tokens <= budget {
+		return text
+	}
+	note := fmt.Sprintf("\n[... %s ...]", reason)
+	limit := budget - a.countTokens(note)
+	if limit <= 0 {
+		return ""
+	}
+	cut := text
+	for a.countTokens(cut) > limit {
+		// Shrink in proportion to the overshoot, then back to the last full line
+		size := len(cut) * limit / a.countTokens(cut)
+		if size >= len(cut) {
+			size = len(cut) - 1
+		}
+		if newline := strings.LastIndex(cut[:size], "\n"); newline > 0 {
+			size = newline
+		}
+		cut = cut[:size]
+	}
+	a.debugLog("✂️  Cut context for the system prompt from %d to %d tokens\n", tokens, budget)
+	return strings.TrimRight(cut, "\n") + note
+}
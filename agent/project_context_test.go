@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestProjectContextInSystemPrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	if strings.Contains(agent.taskSystemPrompt(), "PROJECT CONTEXT") {
+		t.Fatal("expected no project context without a context file")
+	}
+
+	// Written after the agent started: every task reads it again
+	os.MkdirAll(".coder", 0755)
+	var layout strings.Builder
+	layout.WriteString("# Project: shop\n")
+	for i := 0; i < 2000; i++ {
+		layout.WriteString("- internal/cart: totals, discounts and tax rules for the checkout\n")
+	}
+	os.WriteFile(ProjectContextFile, []byte(layout.String()), 0644)
+	agent.SetPreviousSummary("Added retries to the payment client.")
+
+	cfg := agent.configManager.GetConfig()
+	cfg.Preferences[prefProjectContextTokens] = 1000
+	defer delete(cfg.Preferences, prefProjectContextTokens)
+	section := agent.projectContextForPrompt()
+	if !strings.Contains(section, "# Project: shop") || !strings.Contains(section, "cut to fit project_context_tokens") {
+		t.Errorf("expected the start of the project context, cut, got %q", section[:min(len(section), 200)])
+	}
+	if !strings.Contains(section, previousSessionHeader+"\nAdded retries") {
+		t.Error("expected the previous session's summary after the project context")
+	}
+	if tokens := agent.countTokens(section); tokens > 1000 {
+		t.Errorf("expected at most 1000 tokens, got %d", tokens)
+	}
+	if prompt := agent.taskSystemPrompt(); !strings.HasSuffix(prompt, section) || !strings.HasPrefix(prompt, agent.systemPrompt) {
+		t.Error("expected the task's system prompt to end with the project context")
+	}
+
+	cfg.Preferences[prefProjectContext] = false
+	defer delete(cfg.Preferences, prefProjectContext)
+	if agent.taskSystemPrompt() != agent.systemPrompt {
+		t.Error("expected project_context off to leave the system prompt alone")
+	}
+}
@@ -0,0 +1,315 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/tools"
+)
+
+// ProjectContextFile is the project description /init writes and every task
+// starts from
+const ProjectContextFile = ".coder/project.md"
+
+// maxScanDirectories bounds the top-level directories listed in a scan
+const maxScanDirectories = 30
+
+// ErrProjectContextExists is returned by InitProjectContext when the file
+// exists and regenerating wasn't asked for
+var ErrProjectContextExists = errors.New(ProjectContextFile + " already exists")
+
+// buildFiles are the build system and tooling files a scan looks for at the root
+var buildFiles = []string{
+	"go.mod", "go.work", "package.json", "tsconfig.json", "Cargo.toml", "pyproject.toml", "setup.py",
+	"requirements.txt", "Pipfile", "pom.xml", "build.gradle", "build.gradle.kts", "CMakeLists.txt",
+	"Makefile", "justfile", "Taskfile.yml", "Dockerfile", "docker-compose.yml", "Gemfile", "composer.json",
+}
+
+// entryPointNames are the file names that usually start a program
+var entryPointNames = map[string]bool{
+	"main.go": true, "main.py": true, "__main__.py": true, "manage.py": true, "app.py": true,
+	"main.rs": true, "lib.rs": true, "index.js": true, "index.ts": true, "main.ts": true,
+	"server.js": true, "server.ts": true, "Main.java": true, "main.c": true, "main.cpp": true,
+}
+
+// ProjectScan holds the facts /init gathers without the model
+type ProjectScan struct {
+	Root        string
+	Files       int
+	Languages   []ScanCount // Files per language, most first
+	BuildFiles  []string    // Build system files at the root
+	Commands    []string    // Build and test commands of the detected languages
+	Directories []ScanCount // Files per top-level directory, most first
+	EntryPoints []string    // Files that likely start a program
+	GoModules   string      // The Go workspace description, for multi-module repositories
+}
+
+// ScanCount is a name with a file count
+type ScanCount struct {
+	Name  string
+	Files int
+}
+
+// ScanProject gathers the languages, build files, layout and entry points of
+// the repository at root. Files git ignores are left out.
+func ScanProject(root string) (*ProjectScan, error) {
+	files, err := listWorkspaceFiles(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project files: %w", err)
+	}
+	scan := &ProjectScan{Root: root, Files: len(files)}
+
+	languages := make(map[string]int)
+	directories := make(map[string]int)
+	for _, rel := range files {
+		rel = filepath.ToSlash(rel)
+		if pack := tools.LanguagePackFor(rel); pack != nil {
+			languages[pack.Name()]++
+		} else if ext := path.Ext(rel); ext != "" && len(ext) <= 6 {
+			languages[ext]++
+		}
+		if slash := strings.Index(rel, "/"); slash > 0 {
+			directories[rel[:slash]]++
+		}
+		if isEntryPoint(rel) {
+			scan.EntryPoints = append(scan.EntryPoints, rel)
+		}
+	}
+	scan.Languages = sortedCounts(languages, 0)
+	scan.Directories = sortedCounts(directories, maxScanDirectories)
+	sort.Strings(scan.EntryPoints)
+
+	for _, name := range buildFiles {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			scan.BuildFiles = append(scan.BuildFiles, name)
+		}
+	}
+	scan.EntryPoints = append(scan.EntryPoints, packageJSONEntryPoints(root)...)
+	for _, pack := range tools.DetectLanguagePacks(root) {
+		if pack.BuildCommand() != "" {
+			scan.Commands = append(scan.Commands, fmt.Sprintf("%s build: %s", pack.Name(), pack.BuildCommand()))
+		}
+		if pack.TestCommand() != "" {
+			scan.Commands = append(scan.Commands, fmt.Sprintf("%s test: %s", pack.Name(), pack.TestCommand()))
+		}
+	}
+	if workspace, err := DetectGoWorkspace(root, files); err == nil && workspace != nil {
+		scan.GoModules = workspace.Describe()
+	}
+	return scan, nil
+}
+
+// isEntryPoint reports whether a file likely starts a program: a known main
+// file outside vendored and test code, or anything directly under cmd/
+func isEntryPoint(rel string) bool {
+	for _, skip := range []string{"vendor/", "node_modules/", "testdata/", "test/", "tests/"} {
+		if strings.HasPrefix(rel, skip) || strings.Contains(rel, "/"+skip) {
+			return false
+		}
+	}
+	if strings.HasPrefix(rel, "cmd/") && strings.Count(rel, "/") == 2 && path.Base(rel) == "main.go" {
+		return true
+	}
+	return entryPointNames[path.Base(rel)] && strings.Count(rel, "/") <= 2
+}
+
+// packageJSONEntryPoints returns the main file, binaries and scripts of a
+// root package.json
+func packageJSONEntryPoints(root string) []string {
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg struct {
+		Main    string            `json:"main"`
+		Bin     json.RawMessage   `json:"bin"`
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return nil
+	}
+	var entries []string
+	if pkg.Main != "" {
+		entries = append(entries, fmt.Sprintf("package.json main: %s", pkg.Main))
+	}
+	var bin string
+	var bins map[string]string
+	if json.Unmarshal(pkg.Bin, &bin) == nil && bin != "" {
+		entries = append(entries, fmt.Sprintf("package.json bin: %s", bin))
+	} else if json.Unmarshal(pkg.Bin, &bins) == nil {
+		for _, name := range sortedKeys(bins) {
+			entries = append(entries, fmt.Sprintf("package.json bin %s: %s", name, bins[name]))
+		}
+	}
+	for _, name := range sortedKeys(pkg.Scripts) {
+		entries = append(entries, fmt.Sprintf("npm run %s: %s", name, pkg.Scripts[name]))
+	}
+	return entries
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedCounts orders counts by file count, then name, keeping at most limit (0 = all)
+func sortedCounts(counts map[string]int, limit int) []ScanCount {
+	sorted := make([]ScanCount, 0, len(counts))
+	for name, files := range counts {
+		sorted = append(sorted, ScanCount{Name: name, Files: files})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Files != sorted[j].Files {
+			return sorted[i].Files > sorted[j].Files
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// Describe renders the scan as markdown, for the model and as the fallback
+// project description
+func (s *ProjectScan) Describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Project: %s (%d files)\n", filepath.Base(s.Root), s.Files)
+	writeCounts := func(title string, counts []ScanCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, count := range counts {
+			fmt.Fprintf(&b, "- %s: %d files\n", count.Name, count.Files)
+		}
+	}
+	writeList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	writeCounts("Languages", s.Languages)
+	writeList("Build files", s.BuildFiles)
+	writeList("Build and test commands", s.Commands)
+	writeCounts("Top-level directories", s.Directories)
+	writeList("Entry points", s.EntryPoints)
+	if s.GoModules != "" {
+		fmt.Fprintf(&b, "\nGo modules:\n%s\n", s.GoModules)
+	}
+	return b.String()
+}
+
+// projectInitPrompt asks the agent to explore the project and describe it
+func projectInitPrompt(scan *ProjectScan) string {
+	return fmt.Sprintf(`Write the project context file for this repository. It is given to you at the start of every later task, so it should tell a new contributor what they need before changing code.
+
+A scan of the repository found:
+%s
+Explore the repository with your read-only tools to confirm and fill in these facts: read the README, the build files and the main entry points, and look inside the largest directories. Don't change any files.
+
+Your final answer is written to %s as is, so reply with only the markdown, with these sections:
+
+# Project: <name>
+## Overview - what the project does, in two or three sentences
+## Languages and build system - the languages, the build system and the exact build, test and lint commands
+## Layout - one bullet per important directory or file and what it holds
+## Entry points - where programs start and how requests flow through the code
+## Conventions - the naming, error handling, testing and formatting patterns the code follows
+
+Be concise and specific to this repository: name real files, packages and commands, and leave out anything you didn't see.`, scan.Describe(), ProjectContextFile)
+}
+
+// InitProjectContext scans the repository, has the agent explore it with
+// read-only tools and writes its description to ProjectContextFile. An
+// existing file is only replaced when regenerate is set. The tool selection
+// is restored afterwards.
+func (a *Agent) InitProjectContext(regenerate bool) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	target := filepath.Join(wd, ProjectContextFile)
+	if _, err := os.Stat(target); err == nil && !regenerate {
+		return "", ErrProjectContextExists
+	}
+
+	scan, err := ScanProject(wd)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("🔎 Scanned %d files: %s\n", scan.Files, scanSummary(scan))
+
+	preset, _ := a.configManager.GetConfig().GetToolPreset("explore")
+	previousTools, previousPreset := a.disabledTools, a.toolPreset
+	defer func() { a.disabledTools, a.toolPreset = previousTools, previousPreset }()
+	if err := a.enableTools("explore", preset); err != nil {
+		return "", err
+	}
+
+	reply, err := a.ProcessQuery(projectInitPrompt(scan))
+	if err != nil {
+		return "", fmt.Errorf("failed to describe the project: %w", err)
+	}
+	content := stripMarkdownFence(reply)
+	if content == "" {
+		return "", fmt.Errorf("failed to describe the project: the agent returned no description")
+	}
+	content += fmt.Sprintf("\n\n_Generated by /init on %s. Run /init --regenerate to refresh it._\n", time.Now().Format("2006-01-02"))
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(ProjectContextFile), err)
+	}
+	if err := config.WriteFileAtomic(target, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", ProjectContextFile, err)
+	}
+	return target, nil
+}
+
+// scanSummary names the main language and the build files of a scan in one line
+func scanSummary(scan *ProjectScan) string {
+	var parts []string
+	if len(scan.Languages) > 0 {
+		parts = append(parts, "mostly "+scan.Languages[0].Name)
+	}
+	if len(scan.BuildFiles) > 0 {
+		parts = append(parts, "build files "+strings.Join(scan.BuildFiles, ", "))
+	}
+	if len(scan.EntryPoints) > 0 {
+		parts = append(parts, fmt.Sprintf("%d entry points", len(scan.EntryPoints)))
+	}
+	if len(parts) == 0 {
+		return "nothing recognized"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// stripMarkdownFence removes a code fence wrapping a whole reply
+func stripMarkdownFence(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(reply, "```") || !strings.HasSuffix(reply, "```") {
+		return reply
+	}
+	reply = strings.TrimSuffix(reply, "```")
+	if newline := strings.Index(reply, "\n"); newline >= 0 {
+		reply = reply[newline+1:]
+	}
+	return strings.TrimSpace(reply)
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestScanProject(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":                    "module example.com/shop\n\ngo 1.24\n",
+		"Makefile":                  "build:\n\tgo build ./...\n",
+		"cmd/server/main.go":        "package main\n",
+		"internal/cart/cart.go":     "package cart\n",
+		"internal/cart/total.go":    "package cart\n",
+		"internal/testdata/main.go": "package main\n",
+		"web/package.json":          `{"main": "index.js"}`,
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	scan, err := ScanProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.Languages) == 0 || scan.Languages[0].Name != "Go" || scan.Languages[0].Files != 4 {
+		t.Errorf("expected Go to lead with 4 files, got %+v", scan.Languages)
+	}
+	if strings.Join(scan.BuildFiles, ",") != "go.mod,Makefile" {
+		t.Errorf("expected go.mod and Makefile, got %v", scan.BuildFiles)
+	}
+	if strings.Join(scan.EntryPoints, ",") != "cmd/server/main.go" {
+		t.Errorf("expected only the cmd entry point, got %v", scan.EntryPoints)
+	}
+	if len(scan.Directories) == 0 || scan.Directories[0] != (ScanCount{Name: "internal", Files: 3}) {
+		t.Errorf("expected internal to be the largest directory, got %+v", scan.Directories)
+	}
+	if description := scan.Describe(); !strings.Contains(description, "- cmd/server/main.go") || !strings.Contains(description, "go test") {
+		t.Errorf("expected entry points and commands in the description, got:\n%s", description)
+	}
+}
+
+func TestInitProjectContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	os.WriteFile("go.mod", []byte("module example.com/tool\n\ngo 1.24\n"), 0644)
+	os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644)
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [
+		{"expect": "- main.go", "tool_calls": [{"name": "read_file", "arguments": {"file_path": "main.go"}}]},
+		{"content": "`+"```markdown\\n# Project: tool\\n## Layout\\n- main.go: the file where the program starts\\n```"+`"}
+	]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.SetToolEnabled("read_file", false)
+
+	path, err := agent.InitProjectContext(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# Project: tool\n") || strings.Contains(string(data), "```") {
+		t.Errorf("expected the reply without its fence, got:\n%s", data)
+	}
+	if !strings.Contains(getProjectContext(), "# Project: tool") {
+		t.Errorf("expected new tasks to start from %s", ProjectContextFile)
+	}
+	if disabled := agent.DisabledTools(); len(disabled) != 1 || disabled[0] != "read_file" {
+		t.Errorf("expected the tool selection restored, got %v", disabled)
+	}
+
+	if _, err := agent.InitProjectContext(false); err != ErrProjectContextExists {
+		t.Errorf("expected an existing file to be kept, got %v", err)
+	}
+}
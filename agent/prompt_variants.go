@@ -0,0 +1,70 @@
+package agent
+
+// PromptVariant names the system prompt for a kind of invocation. Only agentic
+// tasks need the full tool-usage prompt; the others get a much shorter one.
+type PromptVariant string
+
+const (
+	PromptAgentic PromptVariant = "agentic" // The full tool-using workflow
+	PromptQA      PromptVariant = "qa"      // Answering questions with read-only tools
+	PromptReview  PromptVariant = "review"  // Reviewing or explaining a diff, without tools
+	PromptCommit  PromptVariant = "commit"  // Writing commit messages, without tools
+	PromptCompact PromptVariant = "compact" // Summarizing older turns of a conversation
+)
+
+// prefPromptVariant forces the agentic or Q&A prompt for tasks ("auto" by default)
+const prefPromptVariant = "prompt_variant"
+
+// promptVariantFiles are the embedded prompts of the variants besides agentic
+var promptVariantFiles = map[PromptVariant]string{
+	PromptQA:      "prompts/qa.md",
+	PromptReview:  "prompts/review.md",
+	PromptCommit:  "prompts/commit.md",
+	PromptCompact: "prompts/compact.md",
+}
+
+// SystemPrompt returns the system prompt of a variant. Tasks add the project
+// context to it (see taskSystemPrompt); review and commit prompts work from
+// the request alone.
+func SystemPrompt(variant PromptVariant) string {
+	file, ok := promptVariantFiles[variant]
+	if !ok {
+		return getEmbeddedSystemPrompt()
+	}
+	content, err := promptsFS.ReadFile(file)
+	if err != nil {
+		return getEmbeddedSystemPrompt()
+	}
+	prompt := extractPromptFromMarkdown(string(content))
+	if prompt == "" {
+		return getEmbeddedSystemPrompt()
+	}
+	return prompt
+}
+
+// taskPromptVariant picks the system prompt of a task: Q&A when no enabled
+// tool can change files, as with the explore preset, and agentic otherwise.
+// The prompt_variant preference can force either.
+func (a *Agent) taskPromptVariant() PromptVariant {
+	if a.configManager != nil {
+		switch variant := PromptVariant(a.configManager.GetConfig().GetStringPreference(prefPromptVariant, "auto")); variant {
+		case PromptAgentic, PromptQA:
+			return variant
+		}
+	}
+	for _, tool := range a.toolDefinitions() {
+		if isFileChangeTool(tool.Function.Name) {
+			return PromptAgentic
+		}
+	}
+	return PromptQA
+}
+
+// taskSystemPrompt returns the system prompt for the next task, with the
+// project context
+func (a *Agent) taskSystemPrompt() string {
+	if variant := a.taskPromptVariant(); variant != PromptAgentic {
+		return SystemPrompt(variant) + a.projectContextForPrompt()
+	}
+	return a.systemPrompt + a.projectContextForPrompt()
+}
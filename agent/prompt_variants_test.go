@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemPromptVariants(t *testing.T) {
+	agentic := SystemPrompt(PromptAgentic)
+	for _, variant := range []PromptVariant{PromptQA, PromptReview, PromptCommit} {
+		prompt := SystemPrompt(variant)
+		if prompt == "" || prompt == agentic {
+			t.Errorf("expected %s to have its own prompt", variant)
+		}
+		if len(prompt) >= len(agentic)/2 {
+			t.Errorf("expected the %s prompt to be much shorter than the agentic one, got %d vs %d characters", variant, len(prompt), len(agentic))
+		}
+	}
+	if strings.Contains(SystemPrompt(PromptCommit), "edit_file") {
+		t.Error("expected the commit prompt to leave out tool instructions")
+	}
+}
+
+func TestTaskPromptVariant(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	if got := agent.taskPromptVariant(); got != PromptAgentic {
+		t.Errorf("expected the agentic prompt with all tools, got %s", got)
+	}
+	if err := agent.ApplyToolPreset("explore"); err != nil {
+		t.Fatalf("ApplyToolPreset(explore): %v", err)
+	}
+	if got := agent.taskPromptVariant(); got != PromptQA {
+		t.Errorf("expected the Q&A prompt with read-only tools, got %s", got)
+	}
+	if agent.taskSystemPrompt() == agent.systemPrompt {
+		t.Error("expected read-only tasks not to send the agentic prompt")
+	}
+
+	cfg := agent.configManager.GetConfig()
+	cfg.Preferences[prefPromptVariant] = "agentic"
+	defer delete(cfg.Preferences, prefPromptVariant)
+	if got := agent.taskPromptVariant(); got != PromptAgentic {
+		t.Errorf("expected prompt_variant to force the agentic prompt, got %s", got)
+	}
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/providers"
+	"github.com/alantheprice/coder/types"
+)
+
+func TestMistralAndXAIPricing(t *testing.T) {
+	tests := []struct {
+		name          string
+		pricing       func(string) (float64, float64)
+		model         string
+		input, output float64
+	}{
+		{"mistral dated", providers.MistralPricing, "mistral-large-2411", 2.00, 6.00},
+		{"mistral latest", providers.MistralPricing, "devstral-small-latest", 0.10, 0.30},
+		{"mistral unknown", providers.MistralPricing, "mistral-embed", 0, 0},
+		{"xai longest prefix", providers.XAIPricing, "grok-3-mini-fast", 0.30, 0.50},
+		{"xai family", providers.XAIPricing, "grok-4-0709", 3.00, 15.00},
+	}
+	for _, tt := range tests {
+		input, output := tt.pricing(tt.model)
+		if input != tt.input || output != tt.output {
+			t.Errorf("%s: pricing(%q) = %v, %v; want %v, %v", tt.name, tt.model, input, output, tt.input, tt.output)
+		}
+	}
+}
+
+func TestMistralAndXAIProviderLookup(t *testing.T) {
+	for name, expected := range map[string]api.ClientType{"mistral": api.MistralClientType, "xai": api.XAIClientType, "grok": api.XAIClientType} {
+		provider, err := api.GetProviderFromString(name)
+		if err != nil || provider != expected {
+			t.Errorf("GetProviderFromString(%q) = %q, %v", name, provider, err)
+		}
+	}
+
+	t.Setenv("XAI_API_KEY", "")
+	if _, err := api.NewUnifiedClientWithModel(api.XAIClientType, "grok-4"); err == nil {
+		t.Error("expected an error without XAI_API_KEY")
+	}
+}
+
+func TestMistralRequests(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := openAICompatibleServer(t,
+		`{"prompt_tokens": 1000000, "completion_tokens": 1000000, "total_tokens": 2000000}`,
+		`{"object": "list", "data": [{"id": "devstral-medium-latest", "max_context_length": 131072, "capabilities": {"completion_chat": true}},
+			{"id": "mistral-embed", "max_context_length": 8192, "capabilities": {"completion_chat": false}}]}`,
+		&requestBody)
+	t.Setenv("MISTRAL_API_KEY", "test-key")
+	t.Setenv("MISTRAL_BASE_URL", server.URL)
+
+	provider, err := providers.NewMistralProviderWithModel("")
+	if err != nil {
+		t.Fatalf("NewMistralProviderWithModel: %v", err)
+	}
+	provider.SetRequestParameters(map[string]interface{}{"seed": 7, "tool_choice": "required"})
+	messages := []types.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: " "}, {Role: "user", Content: "read go.mod"}}
+	resp, err := provider.SendChatRequest(context.Background(), messages, nil, "")
+	if err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	if sent, _ := requestBody["messages"].([]interface{}); len(sent) != 2 {
+		t.Errorf("expected the empty assistant message dropped, got %v", requestBody["messages"])
+	}
+	if requestBody["random_seed"] != 7.0 || requestBody["seed"] != nil || requestBody["tool_choice"] != "any" || requestBody["max_tokens"] == nil {
+		t.Errorf("unexpected request %v", requestBody)
+	}
+	// devstral-medium: a million input tokens at $0.40 and a million output at $2
+	if resp.Usage.EstimatedCost < 2.39 || resp.Usage.EstimatedCost > 2.41 {
+		t.Errorf("expected cost 2.40, got %+v", resp.Usage)
+	}
+
+	models, err := provider.ListModels()
+	if err != nil || len(models) != 1 || models[0].ContextLength != 131072 || models[0].InputCost != 0.40 {
+		t.Errorf("expected only the chat model with its context and price, got %+v (%v)", models, err)
+	}
+}
+
+func TestXAIRequests(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := openAICompatibleServer(t, `{"prompt_tokens": 10, "completion_tokens": 10, "total_tokens": 20}`, "", &requestBody)
+	models := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/language-models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"models": [{"id": "grok-4-0709", "aliases": ["grok-4"], "prompt_text_token_price": 30000, "completion_text_token_price": 150000}]}`))
+	}))
+	defer models.Close()
+	t.Setenv("XAI_API_KEY", "test-key")
+	t.Setenv("XAI_BASE_URL", server.URL)
+
+	provider, err := providers.NewXAIProviderWithModel("grok-3-mini")
+	if err != nil {
+		t.Fatalf("NewXAIProviderWithModel: %v", err)
+	}
+	provider.SetRequestParameters(map[string]interface{}{"stop": []string{"END"}, "max_tokens": 2000})
+	if _, err := provider.SendChatRequest(context.Background(), []types.Message{{Role: "user", Content: "hi"}}, nil, "high"); err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	if requestBody["temperature"] != nil || requestBody["stop"] != nil || requestBody["reasoning_effort"] != "high" ||
+		requestBody["max_tokens"] != nil || requestBody["max_completion_tokens"] != 2000.0 {
+		t.Errorf("unexpected reasoning model request %v", requestBody)
+	}
+
+	provider.SetModel("grok-4")
+	requestBody = nil
+	if _, err := provider.SendChatRequest(context.Background(), []types.Message{{Role: "user", Content: "hi"}}, nil, "high"); err != nil {
+		t.Fatalf("SendChatRequest: %v", err)
+	}
+	if requestBody["reasoning_effort"] != nil {
+		t.Errorf("expected no reasoning effort for grok-4, got %v", requestBody)
+	}
+
+	t.Setenv("XAI_BASE_URL", models.URL)
+	provider, err = providers.NewXAIProvider()
+	if err != nil {
+		t.Fatalf("NewXAIProvider: %v", err)
+	}
+	listed, err := provider.ListModels()
+	if err != nil || len(listed) != 1 || listed[0].InputCost != 3 || listed[0].OutputCost != 15 || listed[0].ContextLength != 256000 || listed[0].Description != "grok-4" {
+		t.Errorf("expected the language model with its price and context, got %+v (%v)", listed, err)
+	}
+}
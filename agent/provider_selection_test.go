@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestProviderSelectionLeavesEnvironmentAlone(t *testing.T) {
+	var request map[string]interface{}
+	groq := openAICompatibleServer(t, `{}`,
+		`{"data": [{"id": "qwen/qwen3-32b", "active": true, "context_window": 40960}]}`, &request)
+	openRouter := openAICompatibleServer(t, `{}`,
+		`{"data": [{"id": "deepseek/deepseek-chat-v3.1", "context_length": 163840}]}`, &request)
+	t.Setenv("GROQ_API_KEY", "test-key")
+	t.Setenv("GROQ_BASE_URL", groq.URL)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("OPENROUTER_BASE_URL", openRouter.URL)
+
+	agent := &Agent{}
+	models, err := agent.getModelsForProvider(api.GroqClientType)
+	if err != nil || len(models) != 1 || models[0].ID != "qwen/qwen3-32b" {
+		t.Errorf("expected Groq's models with another provider's key set, got %+v (%v)", models, err)
+	}
+	provider, err := agent.determineProviderForModel("qwen/qwen3-32b")
+	if err != nil || provider != api.GroqClientType {
+		t.Errorf("expected the model to be found at Groq, got %s (%v)", provider, err)
+	}
+
+	if os.Getenv("OPENROUTER_API_KEY") != "test-key" || os.Getenv("GROQ_API_KEY") != "test-key" {
+		t.Error("expected API keys to be left untouched")
+	}
+}
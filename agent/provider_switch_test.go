@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestSetProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": []}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("GROQ_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	// The provider is switched even when another provider serves the same model name
+	model := agent.GetModel()
+	if err := agent.SetProvider(api.GroqClientType, model); err != nil {
+		t.Fatal(err)
+	}
+	if agent.GetProviderType() != api.GroqClientType || agent.GetModel() != model {
+		t.Errorf("expected %s on groq, got %s on %s", model, agent.GetModel(), agent.GetProviderType())
+	}
+	if cfg := agent.configManager.GetConfig(); cfg.LastUsedProvider != api.GroqClientType {
+		t.Errorf("expected the switch to be saved, got %s", cfg.LastUsedProvider)
+	}
+
+	// Without a model, the provider's configured model is used
+	if err := agent.SetProvider(api.OpenRouterClientType, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := agent.configManager.GetModelForProvider(api.OpenRouterClientType); agent.GetModel() != want {
+		t.Errorf("expected the configured model %s, got %s", want, agent.GetModel())
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestReadFileLines(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var b strings.Builder
+	for i := 1; i <= 3000; i++ {
+		fmt.Fprintf(&b, "line %d of a large generated file\n", i)
+	}
+	os.WriteFile("big.txt", []byte(b.String()), 0644)
+
+	if _, err := tools.ReadFile("big.txt"); err == nil || !strings.Contains(err.Error(), "offset and limit") {
+		t.Errorf("expected the whole-file read to point at offset and limit, got %v", err)
+	}
+	result, err := tools.ReadFileLines("big.txt", 1001, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[lines 1001-1002 of 3000 - read more with offset=1003]\n  1001\tline 1001 of a large generated file\n  1002\tline 1002 of a large generated file\n"; result != want {
+		t.Errorf("unexpected section:\n%s", result)
+	}
+	if result, _ := tools.ReadFileLines("big.txt", 2900, 0); !strings.HasPrefix(result, "[lines 2900-3000 of 3000]\n") {
+		t.Errorf("expected the last section without a continuation, got %q", result[:40])
+	}
+	if _, err := tools.ReadFileLines("big.txt", 3001, 10); err == nil || !strings.Contains(err.Error(), "3000 lines") {
+		t.Errorf("expected an offset past the end to fail with the line count, got %v", err)
+	}
+}
+
+func TestReadFileRangeTool(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	t.Chdir(t.TempDir())
+	os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644)
+	args, _ := json.Marshal(map[string]interface{}{"file_path": "main.go", "offset": "3"})
+	call := api.ToolCall{ID: "call_read", Type: "function"}
+	call.Function.Name, call.Function.Arguments = "read_file", string(args)
+	result, err := agent.executeTool(call)
+	if err != nil || result != "[lines 3-3 of 3]\n     3\tfunc main() {}\n" {
+		t.Errorf("expected line 3 with its number, got %q (%v)", result, err)
+	}
+}
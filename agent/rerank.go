@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// Reranking modes, selected with the rerank_mode preference
+const (
+	RerankOff        = "off"
+	RerankLexical    = "lexical"    // term overlap with the task; free and instant (default)
+	RerankEmbeddings = "embeddings" // similarity from the embeddings client, e.g. a small local Ollama model
+	RerankLLM        = "llm"        // the current model scores short previews in one request
+)
+
+// Reranking preferences and defaults
+const (
+	prefRerankMode        = "rerank_mode"
+	prefRerankBudgetChars = "rerank_budget_chars"
+
+	rerankMinCandidates    = 40   // fewer candidates are passed through unchanged
+	defaultRerankBudget    = 8000 // characters of candidates kept
+	llmRerankPreviewChars  = 200  // characters of each candidate the LLM sees
+	llmRerankMaxCandidates = 150  // candidates sent to the LLM, the lexically best ones
+)
+
+// Chunk is one retrieved candidate, e.g. a grep match with its context
+type Chunk struct {
+	Text string
+}
+
+// Reranker scores chunks by relevance to a query; higher is more relevant
+type Reranker interface {
+	Score(query string, chunks []Chunk) ([]float64, error)
+}
+
+// ValidRerankMode reports whether mode is a known reranking mode
+func ValidRerankMode(mode string) bool {
+	switch mode {
+	case RerankOff, RerankLexical, RerankEmbeddings, RerankLLM:
+		return true
+	}
+	return false
+}
+
+// lexicalReranker scores by how many distinct query terms a chunk contains,
+// weighting rare terms higher, like a simplified BM25
+type lexicalReranker struct{}
+
+var rerankTermPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+
+// Score implements Reranker
+func (lexicalReranker) Score(query string, chunks []Chunk) ([]float64, error) {
+	terms := make(map[string]bool)
+	for _, term := range rerankTermPattern.FindAllString(query, -1) {
+		if !commonQueryWords[strings.ToLower(term)] {
+			terms[strings.ToLower(term)] = true
+		}
+	}
+
+	lowered := make([]string, len(chunks))
+	documentFrequency := make(map[string]int)
+	for i, chunk := range chunks {
+		lowered[i] = strings.ToLower(chunk.Text)
+		for term := range terms {
+			if strings.Contains(lowered[i], term) {
+				documentFrequency[term]++
+			}
+		}
+	}
+
+	scores := make([]float64, len(chunks))
+	for i := range chunks {
+		for term := range terms {
+			if strings.Contains(lowered[i], term) {
+				scores[i] += math.Log(1 + float64(len(chunks))/float64(documentFrequency[term]))
+			}
+		}
+	}
+	return scores, nil
+}
+
+// commonQueryWords carry no signal about which code is relevant
+var commonQueryWords = map[string]bool{
+	"the": true, "and": true, "for": true, "this": true, "that": true, "with": true, "from": true,
+	"please": true, "make": true, "should": true, "when": true, "what": true, "where": true,
+	"find": true, "add": true, "fix": true, "use": true, "all": true, "are": true, "not": true,
+}
+
+// embeddingReranker scores by cosine similarity between query and chunk embeddings
+type embeddingReranker struct {
+	client api.EmbeddingsClient
+}
+
+// Score implements Reranker
+func (r embeddingReranker) Score(query string, chunks []Chunk) ([]float64, error) {
+	texts := make([]string, len(chunks)+1)
+	texts[0] = query
+	for i, chunk := range chunks {
+		texts[i+1] = chunk.Text
+	}
+	vectors, err := r.client.Embed(texts)
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]float64, len(chunks))
+	for i := range chunks {
+		scores[i] = api.CosineSimilarity(vectors[0], vectors[i+1])
+	}
+	return scores, nil
+}
+
+// llmReranker asks the current model to rate short previews of each chunk in a single request
+type llmReranker struct {
+	agent *Agent
+}
+
+// rerankSchema is the structured answer of an LLM reranking request
+var rerankSchema = api.ResponseSchema{
+	Name: "rerank",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"scores": map[string]interface{}{
+				"type":        "array",
+				"description": "Relevance from 0 (irrelevant) to 10 (essential), one per candidate in order",
+				"items":       map[string]interface{}{"type": "number"},
+			},
+		},
+		"required":             []string{"scores"},
+		"additionalProperties": false,
+	},
+}
+
+// Score implements Reranker
+func (r llmReranker) Score(query string, chunks []Chunk) ([]float64, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Rate how relevant each candidate is to this task.\n\nTASK: %s\n\nCANDIDATES:\n", query)
+	for i, chunk := range chunks {
+		preview := chunk.Text
+		if len(preview) > llmRerankPreviewChars {
+			preview = preview[:llmRerankPreviewChars]
+		}
+		fmt.Fprintf(&prompt, "[%d] %s\n", i, strings.ReplaceAll(preview, "\n", " ⏎ "))
+	}
+
+	var answer struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := r.agent.GenerateStructured(prompt.String(), rerankSchema, &answer); err != nil {
+		return nil, err
+	}
+	if len(answer.Scores) != len(chunks) {
+		return nil, fmt.Errorf("expected %d scores, got %d", len(chunks), len(answer.Scores))
+	}
+	return answer.Scores, nil
+}
+
+// SelectChunks keeps the highest-scoring chunks that fit in budget characters,
+// returned in their original order so file groupings stay readable
+func SelectChunks(chunks []Chunk, scores []float64, budget int) []Chunk {
+	var kept []int
+	used := 0
+	for _, i := range rankByScore(scores) {
+		size := len(chunks[i].Text) + 1
+		if used+size > budget {
+			continue
+		}
+		kept = append(kept, i)
+		used += size
+	}
+	sort.Ints(kept)
+
+	selected := make([]Chunk, len(kept))
+	for j, i := range kept {
+		selected[j] = chunks[i]
+	}
+	return selected
+}
+
+// rankByScore returns indexes ordered from the highest score to the lowest,
+// keeping the original order among equal scores
+func rankByScore(scores []float64) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool { return scores[order[x]] > scores[order[y]] })
+	return order
+}
+
+// splitSearchOutput splits grep-style output into chunks: context groups
+// separated by "--" when present, otherwise one chunk per matching line
+func splitSearchOutput(output string) []Chunk {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return nil
+	}
+	var parts []string
+	if strings.Contains(output, "\n--\n") {
+		parts = strings.Split(output, "\n--\n")
+	} else {
+		parts = strings.Split(output, "\n")
+	}
+	chunks := make([]Chunk, 0, len(parts))
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			chunks = append(chunks, Chunk{Text: part})
+		}
+	}
+	return chunks
+}
+
+// isSearchCommand reports whether a command is a plain code search whose
+// output lines are independent matches
+func isSearchCommand(command string) bool {
+	command = strings.TrimSpace(command)
+	if strings.ContainsAny(command, ";&|><`$") {
+		return false
+	}
+	for _, prefix := range []string{"grep", "rg", "ag", "ack", "git grep"} {
+		if strings.HasPrefix(command, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// reranker returns the configured reranker, or nil when reranking is off
+func (a *Agent) reranker() (Reranker, string) {
+	mode := RerankLexical
+	if a.configManager != nil {
+		if configured := a.configManager.GetConfig().GetStringPreference(prefRerankMode, RerankLexical); ValidRerankMode(configured) {
+			mode = configured
+		}
+	}
+	switch mode {
+	case RerankOff:
+		return nil, mode
+	case RerankEmbeddings:
+		client, err := a.Embeddings()
+		if err != nil {
+			a.debugLog("⚠️ Embedding reranker unavailable, using lexical: %v\n", err)
+			return lexicalReranker{}, RerankLexical
+		}
+		return embeddingReranker{client: client}, mode
+	case RerankLLM:
+		return llmReranker{agent: a}, mode
+	}
+	return lexicalReranker{}, RerankLexical
+}
+
+// rerankSearchOutput keeps the search results most relevant to the task when
+// a search returns more candidates than are worth putting in the prompt
+func (a *Agent) rerankSearchOutput(command, why, output string) string {
+	if !isSearchCommand(command) {
+		return output
+	}
+	chunks := splitSearchOutput(output)
+	if len(chunks) < rerankMinCandidates {
+		return output
+	}
+	budget := defaultRerankBudget
+	if a.configManager != nil {
+		budget = a.configManager.GetConfig().GetIntPreference(prefRerankBudgetChars, defaultRerankBudget)
+	}
+	if len(output) <= budget {
+		return output
+	}
+	reranker, mode := a.reranker()
+	if reranker == nil {
+		return output
+	}
+
+	query := why
+	if len(a.messages) > 1 {
+		query = a.messages[1].Content + "\n" + why
+	}
+
+	candidates := chunks
+	if mode == RerankLLM && len(candidates) > llmRerankMaxCandidates {
+		// Narrow to the lexically best candidates to keep the request cheap
+		lexical, _ := lexicalReranker{}.Score(query, chunks)
+		best := rankByScore(lexical)[:llmRerankMaxCandidates]
+		sort.Ints(best)
+		candidates = make([]Chunk, len(best))
+		for j, i := range best {
+			candidates[j] = chunks[i]
+		}
+	}
+
+	scores, err := reranker.Score(query, candidates)
+	if err != nil {
+		a.debugLog("⚠️ %s reranking failed, using lexical: %v\n", mode, err)
+		candidates = chunks
+		scores, _ = lexicalReranker{}.Score(query, chunks)
+		mode = RerankLexical
+	}
+
+	selected := SelectChunks(candidates, scores, budget)
+	separator := "\n"
+	if strings.Contains(output, "\n--\n") {
+		separator = "\n--\n"
+	}
+	texts := make([]string, len(selected))
+	for i, chunk := range selected {
+		texts[i] = chunk.Text
+	}
+	a.debugLog("🎯 Reranked %d search results (%s), kept %d\n", len(chunks), mode, len(selected))
+	return fmt.Sprintf("[RERANKED] Showing the %d of %d matches most relevant to the task (%s ranking). Narrow the search to see others.\n%s",
+		len(selected), len(chunks), mode, strings.Join(texts, separator))
+}
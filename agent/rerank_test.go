@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestLexicalRerankerPrefersTaskTerms(t *testing.T) {
+	chunks := []Chunk{
+		{Text: "main.go:10: fmt.Println(\"hello\")"},
+		{Text: "auth/session.go:42: func refreshSessionToken(user *User) error {"},
+		{Text: "auth/login.go:7: // login handler"},
+	}
+	scores, err := lexicalReranker{}.Score("fix refreshSessionToken expiring the session early", chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores[1] <= scores[0] || scores[1] <= scores[2] {
+		t.Errorf("expected the refreshSessionToken match to rank first, got %v", scores)
+	}
+}
+
+func TestSelectChunksKeepsBudgetAndOrder(t *testing.T) {
+	chunks := []Chunk{{Text: "aaaa"}, {Text: "bbbb"}, {Text: "cccc"}, {Text: "dddd"}}
+	selected := SelectChunks(chunks, []float64{1, 5, 0, 3}, 10)
+	if len(selected) != 2 || selected[0].Text != "bbbb" || selected[1].Text != "dddd" {
+		t.Errorf("expected the two best chunks in original order, got %v", selected)
+	}
+}
+
+func TestIsSearchCommand(t *testing.T) {
+	for command, expected := range map[string]bool{
+		"grep -rn Token .":        true,
+		"rg -n 'func main'":       true,
+		"git grep -n Session":     true,
+		"grep -rn Token . | head": false,
+		"ls -la":                  false,
+	} {
+		if got := isSearchCommand(command); got != expected {
+			t.Errorf("isSearchCommand(%q) = %v, want %v", command, got, expected)
+		}
+	}
+}
+
+func TestRerankSearchOutput(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	agent.messages = []api.Message{
+		{Role: "system", Content: "You are a coding assistant"},
+		{Role: "user", Content: "Why does refreshSessionToken fail?"},
+	}
+
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("pkg/file%03d.go:%d: unrelated := compute(%d) // padding padding padding padding", i, i, i))
+	}
+	lines[137] = "auth/session.go:42: func refreshSessionToken(user *User) error {"
+	output := strings.Join(lines, "\n")
+
+	reranked := agent.rerankSearchOutput("grep -rn . pkg auth", "find session refresh", output)
+	if !strings.HasPrefix(reranked, "[RERANKED]") || !strings.Contains(reranked, "refreshSessionToken") {
+		t.Fatalf("expected reranked output keeping the relevant match, got %.200s", reranked)
+	}
+	if len(reranked) > defaultRerankBudget+300 {
+		t.Errorf("expected output within the budget, got %d chars", len(reranked))
+	}
+	if unchanged := agent.rerankSearchOutput("ls -R", "", output); unchanged != output {
+		t.Error("expected non-search commands to pass through unchanged")
+	}
+}
+
+type fakeEmbeddings struct{}
+
+func (fakeEmbeddings) Model() string { return "fake" }
+
+// Embed maps texts mentioning "session" to one direction and others to another
+func (fakeEmbeddings) Embed(texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(strings.ToLower(text), "session") {
+			vectors[i] = []float32{1, 0}
+		} else {
+			vectors[i] = []float32{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func TestEmbeddingReranker(t *testing.T) {
+	chunks := []Chunk{{Text: "util.go: func pad()"}, {Text: "auth.go: func newSession()"}}
+	scores, err := embeddingReranker{client: fakeEmbeddings{}}.Score("session handling", chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores[1] <= scores[0] {
+		t.Errorf("expected the similar chunk to score higher, got %v", scores)
+	}
+}
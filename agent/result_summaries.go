@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+const (
+	prefResultSummaryModel      = "result_summary_model"       // "provider" or "provider:model" that summarizes large results ("" = off)
+	prefResultSummaryMinChars   = "result_summary_min_chars"   // results at least this long are summarized
+	prefResultSummaryMinResults = "result_summary_min_results" // only when an iteration produces this many large results
+)
+
+const (
+	defaultResultSummaryMinChars   = 6000
+	defaultResultSummaryMinResults = 3
+)
+
+// summarizableTools produce exploration output worth summarizing
+var summarizableTools = map[string]bool{
+	"read_file":        true,
+	"read_notebook":    true,
+	"shell_command":    true,
+	"summarize_schema": true,
+	"list_targets":     true,
+	"list_directory":   true,
+	"glob":             true,
+	"search_code":      true,
+}
+
+// appendedResult is a tool result message added during the current iteration
+type appendedResult struct {
+	index    int // position in the conversation
+	toolCall api.ToolCall
+	err      error
+}
+
+// resultSummarizer returns the client for the configured summary model, or
+// nil when summarization is off or the model can't be reached
+func (a *Agent) resultSummarizer() api.ClientInterface {
+	if a.configManager == nil {
+		return nil
+	}
+	cfg := a.configManager.GetConfig()
+	entry := strings.TrimSpace(cfg.GetStringPreference(prefResultSummaryModel, ""))
+	if entry == "" {
+		return nil
+	}
+	if a.summaryClient != nil && a.summaryClientEntry == entry {
+		return a.summaryClient
+	}
+
+	// Model names may contain colons ("qwen2.5-coder:7b"), provider names don't
+	name, model, _ := strings.Cut(entry, ":")
+	provider, err := config.GetProviderFromConfigName(name)
+	if err != nil {
+		a.debugLog("⚠️  Ignoring %s %q: %v\n", prefResultSummaryModel, entry, err)
+		return nil
+	}
+	if model == "" {
+		model = cfg.GetModelForProvider(provider)
+	}
+	client, err := api.NewUnifiedClientWithModel(provider, model)
+	if err != nil {
+		a.debugLog("⚠️  Result summaries unavailable: %v\n", err)
+		return nil
+	}
+	a.summaryClient, a.summaryClientEntry = client, entry
+	return client
+}
+
+// summarizeLargeResults replaces the large exploration results of an
+// iteration with summaries from the cheap summary model, made in parallel.
+// It only acts when the iteration produced enough large results to crowd the
+// context; the full text stays available through fetch_full_result.
+func (a *Agent) summarizeLargeResults(results []appendedResult) {
+	if a.configManager == nil || a.cancelled() {
+		return
+	}
+	cfg := a.configManager.GetConfig()
+	minChars := cfg.GetIntPreference(prefResultSummaryMinChars, defaultResultSummaryMinChars)
+	minResults := cfg.GetIntPreference(prefResultSummaryMinResults, defaultResultSummaryMinResults)
+
+	var large []appendedResult
+	for _, result := range results {
+		if result.err == nil && summarizableTools[result.toolCall.Function.Name] && len(a.messages[result.index].Content) >= minChars {
+			large = append(large, result)
+		}
+	}
+	if len(large) == 0 || len(large) < minResults {
+		return
+	}
+	client := a.resultSummarizer()
+	if client == nil {
+		return
+	}
+
+	a.ToolLog("summarizing results", fmt.Sprintf("%d large results with %s", len(large), client.GetModel()))
+	summaries := make([]string, len(large))
+	costs := make([]float64, len(large))
+	limit := make(chan struct{}, a.parallelToolLimit())
+	var wg sync.WaitGroup
+	for i, result := range large {
+		wg.Add(1)
+		go func(i int, content string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			summaries[i], costs[i] = a.summarizeResult(client, content)
+		}(i, a.messages[result.index].Content)
+	}
+	wg.Wait()
+
+	for i, result := range large {
+		a.totalCost += costs[i]
+		if summaries[i] == "" {
+			continue
+		}
+		full := a.messages[result.index].Content
+		if a.fullResults == nil {
+			a.fullResults = make(map[string]string)
+		}
+		a.fullResults[result.toolCall.ID] = full
+
+		// Keep the "Tool call result for ..." line that names the file or command
+		header, _, _ := strings.Cut(full, "\n")
+		a.messages[result.index].Content = fmt.Sprintf("%s\n[SUMMARY of %d chars by %s - call fetch_full_result with id %q for the exact text before editing or quoting it]\n%s",
+			header, len(full), client.GetModel(), result.toolCall.ID, summaries[i])
+		a.debugLog("📝 Summarized %s result: %d → %d chars\n", result.toolCall.Function.Name, len(full), len(a.messages[result.index].Content))
+	}
+}
+
+// summarizeResult asks the summary model to condense one tool result for the
+// current task. It returns "" when the request fails, so the full result is
+// kept.
+func (a *Agent) summarizeResult(client api.ClientInterface, content string) (string, float64) {
+	task := ""
+	if len(a.messages) > 1 {
+		task = a.messages[1].Content
+	}
+	messages := []api.Message{
+		{Role: "system", Content: "You condense tool output for a coding agent. Keep every file path, function and type signature, line number, error message and value that matters for the task. Drop boilerplate and repetition. Reply with the summary only."},
+		{Role: "user", Content: fmt.Sprintf("TASK:\n%s\n\nTOOL OUTPUT:\n%s", task, content)},
+	}
+	resp, err := client.SendChatRequest(a.operationContext(), messages, nil, "low")
+	if err != nil || len(resp.Choices) == 0 {
+		a.debugLog("⚠️  Result summary failed, keeping the full result: %v\n", err)
+		return "", 0
+	}
+	summary := strings.TrimSpace(resp.Choices[0].Message.Content)
+	if summary == "" || len(summary) >= len(content) {
+		return "", resp.Usage.EstimatedCost
+	}
+	return summary, resp.Usage.EstimatedCost
+}
+
+// fetchFullResult returns the full text of a summarized tool result
+func (a *Agent) fetchFullResult(id string) (string, error) {
+	full, ok := a.fullResults[id]
+	if !ok {
+		return "", fmt.Errorf("no summarized result with id %q; only results marked [SUMMARY ...] can be fetched", id)
+	}
+	return full, nil
+}
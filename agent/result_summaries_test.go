@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// fakeSummaryClient answers every request with a short summary
+type fakeSummaryClient struct {
+	api.ClientInterface
+	calls atomic.Int32
+}
+
+func (c *fakeSummaryClient) SendChatRequest(ctx context.Context, messages []api.Message, tools []api.Tool, reasoning string) (*api.ChatResponse, error) {
+	c.calls.Add(1)
+	resp := &api.ChatResponse{Choices: make([]api.Choice, 1)}
+	resp.Choices[0].Message.Content = "short summary"
+	resp.Usage.EstimatedCost = 0.001
+	return resp, nil
+}
+
+func (c *fakeSummaryClient) GetModel() string { return "cheap/model" }
+
+func readFileCall(id, path string) api.ToolCall {
+	call := api.ToolCall{ID: id, Type: "function"}
+	call.Function.Name = "read_file"
+	call.Function.Arguments = fmt.Sprintf(`{"file_path": %q}`, path)
+	return call
+}
+
+func TestSummarizeLargeResults(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	dir := t.TempDir()
+	large := strings.Repeat("func example() {}\n", 500)
+	var calls []api.ToolCall
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("large%d.go", i))
+		os.WriteFile(path, []byte(large), 0644)
+		calls = append(calls, readFileCall(fmt.Sprintf("call_%d", i), path))
+	}
+	small := filepath.Join(dir, "small.go")
+	os.WriteFile(small, []byte("package small\n"), 0644)
+	calls = append(calls, readFileCall("call_small", small))
+
+	prefs := agent.configManager.GetConfig().Preferences
+	prefs[prefResultSummaryModel] = "openrouter:cheap/model"
+	defer delete(prefs, prefResultSummaryModel)
+	summarizer := &fakeSummaryClient{}
+	agent.summaryClient, agent.summaryClientEntry = summarizer, "openrouter:cheap/model"
+	agent.messages = []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "explain the examples"}}
+	costBefore := agent.totalCost
+
+	agent.executeToolCalls(calls)
+	if summarizer.calls.Load() != 3 {
+		t.Fatalf("expected the 3 large results to be summarized, got %d requests", summarizer.calls.Load())
+	}
+	for i, message := range agent.messages[2:5] {
+		if !strings.Contains(message.Content, "short summary") || !strings.Contains(message.Content, fmt.Sprintf("call_%d", i)) ||
+			!strings.HasPrefix(message.Content, "Tool call result for read_file: ") {
+			t.Errorf("expected result %d to be summarized with its header and id, got %q", i, message.Content)
+		}
+	}
+	if strings.Contains(agent.messages[5].Content, "SUMMARY") {
+		t.Errorf("expected the small result to be kept, got %q", agent.messages[5].Content)
+	}
+	if agent.totalCost-costBefore < 0.0029 {
+		t.Errorf("expected the summary cost to be counted, got %f", agent.totalCost-costBefore)
+	}
+
+	// The full text is one tool call away
+	fetch := api.ToolCall{ID: "call_fetch", Type: "function"}
+	fetch.Function.Name = "fetch_full_result"
+	fetch.Function.Arguments = `{"id": "call_1"}`
+	agent.executeToolCalls([]api.ToolCall{fetch})
+	if last := agent.messages[len(agent.messages)-1].Content; !strings.Contains(last, large) {
+		t.Errorf("expected the full result, got %d chars", len(last))
+	}
+	if _, err := agent.fetchFullResult("call_small"); err == nil {
+		t.Error("expected an error for a result that wasn't summarized")
+	}
+
+	// Fewer large results than the minimum are left alone
+	agent.executeToolCalls(calls[:2])
+	if summarizer.calls.Load() != 3 {
+		t.Errorf("expected no summaries below %d large results, got %d requests", defaultResultSummaryMinResults, summarizer.calls.Load())
+	}
+}
@@ -0,0 +1,239 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// largeChangeLines is the size above which a single file change counts as high risk
+const largeChangeLines = 500
+
+// RiskLevel grades how much damage a tool call could do
+type RiskLevel int
+
+const (
+	RiskLow RiskLevel = iota
+	RiskHigh
+)
+
+// RiskAssessment is the static classifier's verdict on a tool call
+type RiskAssessment struct {
+	Level   RiskLevel
+	Reasons []string
+	// Required is set when a project policy demands approval, which --yes
+	// doesn't give
+	Required bool
+}
+
+// Tag labels a high-risk action in logs and the transcript
+func (r RiskAssessment) Tag() string {
+	if r.Level < RiskHigh {
+		return ""
+	}
+	return fmt.Sprintf("[HIGH RISK: %s]", strings.Join(r.Reasons, ", "))
+}
+
+// shellRiskRules map command patterns to the reason they are high risk
+var shellRiskRules = []struct {
+	reason   string
+	patterns []*regexp.Regexp
+}{
+	{"deletion", []*regexp.Regexp{
+		regexp.MustCompile(`(^|[;&|(]\s*|\b(xargs|sudo)\s+)(rm|rmdir|unlink|shred)\s`),
+		regexp.MustCompile(`\bgit\s+(rm|clean)\b`),
+		regexp.MustCompile(`\bfind\b.*\s-delete\b`),
+	}},
+	{"network access", []*regexp.Regexp{
+		regexp.MustCompile(`\b(curl|wget|ssh|scp|rsync|nc|ncat|telnet|ftp)\s`),
+		regexp.MustCompile(`\bgit\s+(push|pull|fetch|clone)\b`),
+	}},
+	{"package installation", []*regexp.Regexp{
+		regexp.MustCompile(`\b(npm|pnpm|yarn|bun)\s+(install|i|add|ci)\b`),
+		regexp.MustCompile(`\b(pip3?|gem|cargo|brew|pipx)\s+install\b`),
+		regexp.MustCompile(`\bcargo\s+add\b`),
+		regexp.MustCompile(`\bgo\s+(get|install)\b`),
+		regexp.MustCompile(`\b(apt|apt-get|dnf|yum|apk|pacman)\s+(install|add|-S)\b`),
+	}},
+	{"infrastructure change", []*regexp.Regexp{
+		regexp.MustCompile(`\b(terraform|tofu)\s+(.*\s)?(apply|destroy|import|state\s+(rm|mv|push))\b`),
+	}},
+}
+
+// assessShellRisk classifies a shell command
+func assessShellRisk(command string) RiskAssessment {
+	var risk RiskAssessment
+	if isRiskyShellCommand(command) {
+		risk.Reasons = append(risk.Reasons, "destructive command")
+	}
+	for _, rule := range shellRiskRules {
+		for _, pattern := range rule.patterns {
+			if pattern.MatchString(command) {
+				risk.Reasons = append(risk.Reasons, rule.reason)
+				break
+			}
+		}
+	}
+	if len(risk.Reasons) > 0 {
+		risk.Level = RiskHigh
+	}
+	return risk
+}
+
+// assessToolRisk classifies a tool call from its name and arguments
+func assessToolRisk(toolName string, args map[string]interface{}) RiskAssessment {
+	switch toolName {
+	case "shell_command":
+		return assessShellRisk(stringArg(args, "command", "cmd"))
+	case "write_file", "edit_file", "edit_cell", "edit_file_multi", "apply_patch":
+		var risk RiskAssessment
+		for _, path := range changedPaths(args) {
+			if isOutsideProject(path) {
+				risk.Reasons = append(risk.Reasons, "file outside the project")
+				break
+			}
+		}
+		if changed := changedLines(args); changed > largeChangeLines {
+			risk.Reasons = append(risk.Reasons, fmt.Sprintf("%d-line change", changed))
+		}
+		if len(risk.Reasons) > 0 {
+			risk.Level = RiskHigh
+		}
+		return risk
+	}
+	return RiskAssessment{}
+}
+
+// isOutsideProject reports whether a path resolves outside the working directory
+func isOutsideProject(path string) bool {
+	root, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(root, abs)
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// countLines counts the lines in a string
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
+
+// approveToolCall escalates high-risk tool calls to confirmation. The
+// approval handler is asked when one is installed; otherwise the user is
+// asked on the terminal, even though ordinary actions run unattended.
+func (a *Agent) approveToolCall(toolName string, args map[string]interface{}) bool {
+	why, _ := args["why"].(string)
+	if toolName == "shell_command" {
+		return a.approveShellCommand(stringArg(args, "command", "cmd"), why)
+	}
+	risk := assessToolRisk(toolName, args)
+	if a.confirmEdits() && isFileChangeTool(toolName) {
+		risk.Level = RiskHigh
+		risk.Reasons = append(risk.Reasons, "file change")
+	}
+	return a.approveAction(toolName, strings.Join(changedPaths(args), ", "), why, risk)
+}
+
+// isFileChangeTool reports whether a tool writes to files
+func isFileChangeTool(toolName string) bool {
+	switch toolName {
+	case "write_file", "edit_file", "edit_cell", "edit_file_multi", "apply_patch":
+		return true
+	}
+	return false
+}
+
+// approveAction asks for confirmation of a high-risk action, unless the same
+// kind of action was always allowed earlier in the session or --yes approves
+// it. Required approvals are asked for every time.
+func (a *Agent) approveAction(toolName, detail, why string, risk RiskAssessment) bool {
+	if risk.Level < RiskHigh {
+		return true
+	}
+	if why = strings.TrimSpace(why); why != "" {
+		detail += "\nWhy: " + why
+	}
+	detail += "\nRisk: " + strings.Join(risk.Reasons, ", ")
+
+	a.approvalMu.Lock()
+	defer a.approvalMu.Unlock()
+	key := approvalKey(toolName, risk)
+	if a.sessionApprovals[key] && !risk.Required {
+		a.debugLog("✅ %s allowed for this session\n", key)
+		return true
+	}
+	if a.autoApprove && !risk.Required {
+		fmt.Printf("✅ Auto-approved high-risk %s: %s\n", toolName, strings.ReplaceAll(detail, "\n", " | "))
+		return true
+	}
+	a.speak(TTSApprovals, "Approval needed for a high-risk "+strings.ReplaceAll(toolName, "_", " "))
+	if a.approvalHandler != nil {
+		return a.approvalHandler(toolName, detail)
+	}
+
+	switch confirmOnTerminal(toolName, detail, risk.Required) {
+	case approvalAlwaysAllowed:
+		if a.sessionApprovals == nil {
+			a.sessionApprovals = make(map[string]bool)
+		}
+		a.sessionApprovals[key] = true
+		fmt.Printf("✅ Allowing %s for the rest of the session\n", key)
+		return true
+	case approvalApproved:
+		return true
+	default:
+		return false
+	}
+}
+
+// confirmOnTerminal asks the user to approve a high-risk action, reject it,
+// or, unless the approval is required every time, always allow that kind of
+// action for the session. Without a terminal to ask on, the action is refused.
+func confirmOnTerminal(toolName, detail string, required bool) approvalDecision {
+	fmt.Printf("\n⚠️  High-risk %s:\n%s\n", toolName, detail)
+	if stat, err := os.Stdin.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		if required {
+			fmt.Println("❌ Refused: no terminal to confirm on, and this action must be approved each time (--yes doesn't cover it)")
+		} else {
+			fmt.Println("❌ Refused: no terminal to confirm on (run with --yes to approve automatically)")
+		}
+		return approvalRejected
+	}
+	if required {
+		answer, err := tools.AskUser("Proceed? [y]es / [N]o")
+		if err != nil || parseApproval(answer) != approvalApproved {
+			return approvalRejected
+		}
+		return approvalApproved
+	}
+	answer, err := tools.AskUser("Proceed? [y]es / [N]o / [a]lways allow for this session")
+	if err != nil {
+		return approvalRejected
+	}
+	return parseApproval(answer)
+}
+
+// parseApproval reads an answer to an approval prompt; anything unrecognised
+// rejects the action
+func parseApproval(answer string) approvalDecision {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return approvalApproved
+	case "a", "always":
+		return approvalAlwaysAllowed
+	default:
+		return approvalRejected
+	}
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssessToolRisk(t *testing.T) {
+	tests := []struct {
+		tool   string
+		args   map[string]interface{}
+		reason string // empty means low risk
+	}{
+		{"shell_command", map[string]interface{}{"command": "ls -la"}, ""},
+		{"shell_command", map[string]interface{}{"command": "go test ./..."}, ""},
+		{"shell_command", map[string]interface{}{"command": "grep -rn rm ."}, ""},
+		{"shell_command", map[string]interface{}{"command": "rm old.go"}, "deletion"},
+		{"shell_command", map[string]interface{}{"command": "find . -name '*.tmp' -delete"}, "deletion"},
+		{"shell_command", map[string]interface{}{"command": "curl https://example.com"}, "network access"},
+		{"shell_command", map[string]interface{}{"command": "npm install left-pad"}, "package installation"},
+		{"shell_command", map[string]interface{}{"command": "go get github.com/foo/bar"}, "package installation"},
+		{"shell_command", map[string]interface{}{"command": "sudo make install"}, "destructive command"},
+		{"edit_file", map[string]interface{}{"file_path": "agent/agent.go", "old_string": "a", "new_string": "b"}, ""},
+		{"edit_file", map[string]interface{}{"file_path": "/etc/hosts", "old_string": "a", "new_string": "b"}, "file outside the project"},
+		{"write_file", map[string]interface{}{"file_path": "../other/main.go", "content": "package main"}, "file outside the project"},
+		{"write_file", map[string]interface{}{"file_path": "big.go", "content": strings.Repeat("x\n", 600)}, "601-line change"},
+		{"read_file", map[string]interface{}{"file_path": "/etc/hosts"}, ""},
+	}
+
+	for _, tt := range tests {
+		risk := assessToolRisk(tt.tool, tt.args)
+		if tt.reason == "" {
+			if risk.Level != RiskLow {
+				t.Errorf("%s %v: expected low risk, got %s", tt.tool, tt.args, risk.Tag())
+			}
+			continue
+		}
+		if risk.Level != RiskHigh || !strings.Contains(risk.Tag(), tt.reason) {
+			t.Errorf("%s %v: expected high risk for %q, got %q", tt.tool, tt.args, tt.reason, risk.Tag())
+		}
+	}
+}
+
+// TestApproveToolCallEscalatesHighRisk tests that only high-risk file changes reach the handler
+func TestApproveToolCallEscalatesHighRisk(t *testing.T) {
+	a := &Agent{}
+	var asked []string
+	a.SetApprovalHandler(func(toolName, detail string) bool {
+		asked = append(asked, detail)
+		return false
+	})
+
+	if !a.approveToolCall("edit_file", map[string]interface{}{"file_path": "main.go", "old_string": "a", "new_string": "b"}) {
+		t.Error("expected an ordinary edit to be approved without asking")
+	}
+	if a.approveToolCall("write_file", map[string]interface{}{"file_path": "/tmp/outside.txt", "content": "x", "why": "Scratch notes"}) {
+		t.Error("expected the handler's refusal for a file outside the project")
+	}
+	if len(asked) != 1 || !strings.Contains(asked[0], "Why: Scratch notes") || !strings.Contains(asked[0], "Risk: file outside the project") {
+		t.Errorf("unexpected approval requests: %q", asked)
+	}
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+const (
+	prefSandbox      = "sandbox"       // off, auto, paths or container
+	prefSandboxImage = "sandbox_image" // image the container sandbox runs commands in
+)
+
+// SetSandbox runs this session's shell commands in a sandbox of the given
+// mode (see tools.NewSandbox), overriding the sandbox preference
+func (a *Agent) SetSandbox(mode string) error {
+	sandbox, err := a.newSandbox(mode)
+	if err != nil {
+		return err
+	}
+	a.sandboxMode, a.sandbox, a.sandboxErr, a.sandboxResolved = mode, sandbox, nil, true
+	return nil
+}
+
+// GetSandboxMode returns the sandbox mode set with --sandbox, or the
+// sandbox preference
+func (a *Agent) GetSandboxMode() string {
+	if a.sandboxMode != "" {
+		return a.sandboxMode
+	}
+	if a.configManager == nil {
+		return tools.SandboxOff
+	}
+	return a.configManager.GetConfig().GetStringPreference(prefSandbox, tools.SandboxOff)
+}
+
+// Sandbox returns the sandbox shell commands run in, or nil when they run
+// unrestricted. A sandbox that can't be set up is reported by the commands.
+func (a *Agent) Sandbox() *tools.Sandbox {
+	sandbox, _ := a.shellSandbox()
+	return sandbox
+}
+
+// shellSandbox resolves the sandbox preference on first use
+func (a *Agent) shellSandbox() (*tools.Sandbox, error) {
+	if !a.sandboxResolved {
+		a.sandbox, a.sandboxErr = a.newSandbox(a.GetSandboxMode())
+		a.sandboxResolved = true
+	}
+	return a.sandbox, a.sandboxErr
+}
+
+// newSandbox creates a sandbox rooted at the working directory
+func (a *Agent) newSandbox(mode string) (*tools.Sandbox, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project root: %w", err)
+	}
+	image := ""
+	if a.configManager != nil {
+		image = a.configManager.GetConfig().GetStringPreference(prefSandboxImage, "")
+	}
+	return tools.NewSandbox(mode, root, image)
+}
+
+// runShellCommand runs a shell command for the model, in the sandbox when
+// one is enabled. A sandbox that can't be set up refuses every command
+// rather than running it unrestricted.
+func (a *Agent) runShellCommand(command string) (string, error) {
+	sandbox, err := a.shellSandbox()
+	if err != nil {
+		return "", fmt.Errorf("sandbox unavailable: %w", err)
+	}
+	// The running tool call adds what the command used to its record
+	return tools.ExecuteSandboxedCommand(tools.WithResourceUsage(a.operationContext(), a.toolUsage), command, sandbox)
+}
+
+// RunProjectCommand runs a command taken from the project's files, such as a
+// custom version check in .coder/prerequisites.json. A cloned repository
+// can't be trusted, so it always asks for approval, which --yes doesn't
+// give, and runs in the sandbox for at most tools.PrerequisiteTimeout.
+func (a *Agent) RunProjectCommand(command, why string) (string, error) {
+	risk := RiskAssessment{Level: RiskHigh, Reasons: []string{"command from the project's files"}, Required: true}
+	if !a.approveAction("shell_command", command, why, risk) {
+		return "", fmt.Errorf("command not approved: %s", command)
+	}
+	sandbox, err := a.shellSandbox()
+	if err != nil {
+		return "", fmt.Errorf("sandbox unavailable: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(a.operationContext(), tools.PrerequisiteTimeout)
+	defer cancel()
+	output, err := tools.ExecuteSandboxedCommand(ctx, command, sandbox)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("command timed out after %v", tools.PrerequisiteTimeout)
+	}
+	return output, err
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestSandboxCheck(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	outside := t.TempDir()
+	os.Mkdir(filepath.Join(root, "sub"), 0755)
+	os.Symlink(outside, filepath.Join(root, "escape"))
+
+	sandbox, err := tools.NewSandbox(tools.SandboxPaths, root, "")
+	if err != nil || sandbox == nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	allowed := []string{
+		"echo hi > out.txt",
+		"go test ./... 2>&1 | tail -5 > /dev/null",
+		"cd sub && ls",
+		"cp /etc/hosts sub/hosts",
+		"mkdir -p sub/new && touch sub/new/file",
+		"sed -i 's/a/b/' sub/file.txt",
+		"cat /etc/hosts",
+	}
+	for _, command := range allowed {
+		if err := sandbox.Check(command); err != nil {
+			t.Errorf("expected %q to be allowed, got %v", command, err)
+		}
+	}
+	refused := []string{
+		"echo hi > " + filepath.Join(outside, "out.txt"),
+		"echo hi >> ~/.bashrc",
+		"cd / && ls",
+		"cd",
+		"rm -rf ../other",
+		"cp sub/file " + outside,
+		"sed -i 's/a/b/' /etc/hosts",
+		"touch escape/file",
+		"FOO=1 sudo tee /etc/motd < sub/file",
+	}
+	for _, command := range refused {
+		if err := sandbox.Check(command); err == nil {
+			t.Errorf("expected %q to be refused", command)
+		}
+	}
+
+	if _, err := tools.NewSandbox("jail", root, ""); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+	if off, err := tools.NewSandbox(tools.SandboxOff, root, ""); off != nil || err != nil {
+		t.Errorf("expected no sandbox when off, got %v, %v", off, err)
+	}
+}
+
+func TestSandboxedShellCommand(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	root := t.TempDir()
+	t.Chdir(root)
+	outside := filepath.Join(t.TempDir(), "out.txt")
+
+	if err := agent.SetSandbox("jail"); err == nil {
+		t.Error("expected an unknown sandbox mode to fail")
+	}
+	if err := agent.SetSandbox(tools.SandboxPaths); err != nil {
+		t.Fatalf("failed to enable the sandbox: %v", err)
+	}
+	if agent.GetSandboxMode() != tools.SandboxPaths || agent.Sandbox() == nil {
+		t.Fatalf("expected the paths sandbox, got %q", agent.GetSandboxMode())
+	}
+
+	if _, err := agent.runShellCommand("echo hi > " + outside); err == nil || !strings.Contains(err.Error(), "outside the project root") {
+		t.Errorf("expected the write outside the project to be refused, got %v", err)
+	}
+	if _, err := os.Stat(outside); err == nil {
+		t.Error("expected the refused command not to run")
+	}
+	if _, err := agent.runShellCommand("echo hi > inside.txt"); err != nil {
+		t.Fatalf("expected a write inside the project to run, got %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "inside.txt")); string(content) != "hi\n" {
+		t.Errorf("expected the command to write inside.txt, got %q", content)
+	}
+}
+
+func TestAutoSandboxRefusesWithoutContainerRuntime(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // neither docker nor podman
+	if sandbox, err := tools.NewSandbox(tools.SandboxAuto, t.TempDir(), ""); err == nil {
+		t.Fatalf("expected auto to refuse instead of falling back, got %s", sandbox)
+	}
+	if sandbox, err := tools.NewSandbox(tools.SandboxPaths, t.TempDir(), ""); err != nil || sandbox.Mode != tools.SandboxPaths {
+		t.Errorf("expected the paths sandbox when chosen explicitly, got %v, %v", sandbox, err)
+	}
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// TestSummarizeProto tests summarizing services, messages and enums of a .proto file
+func TestSummarizeProto(t *testing.T) {
+	proto := `syntax = "proto3";
+
+package shop.v1;
+
+import "google/api/annotations.proto";
+
+/* Orders are placed
+   by customers */
+service OrderService {
+  // Places an order
+  rpc PlaceOrder(PlaceOrderRequest) returns (Order) {
+    option (google.api.http) = {
+      post: "/v1/orders"
+      body: "*"
+    };
+  }
+  rpc WatchOrders(WatchRequest) returns (stream Order);
+}
+
+message Order {
+  string id = 1;
+  repeated LineItem items = 2;
+  map<string, string> labels = 3;
+  Status status = 4;
+  reserved 5, 6;
+  oneof payment {
+    string card_token = 7;
+    string invoice_id = 8;
+  }
+  message LineItem {
+    string sku = 1;
+    int32 quantity = 2;
+  }
+}
+
+enum Status {
+  STATUS_UNSPECIFIED = 0;
+  STATUS_SHIPPED = 1;
+}
+`
+	summary := tools.SummarizeProto(proto)
+	for _, expected := range []string{
+		"package shop.v1",
+		"service OrderService\n  PlaceOrder(PlaceOrderRequest) returns (Order)\n  WatchOrders(WatchRequest) returns (stream Order)\nmessage Order",
+		"  repeated LineItem items = 2",
+		"  map<string, string> labels = 3",
+		"  reserved 5, 6",
+		"  oneof payment\n    string card_token = 7",
+		"  message LineItem\n    string sku = 1",
+		"enum Status\n  STATUS_UNSPECIFIED = 0",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected %q in summary:\n%s", expected, summary)
+		}
+	}
+}
+
+// TestSummarizeOpenAPI tests summarizing YAML and JSON OpenAPI specs
+func TestSummarizeOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	yamlSpec := `openapi: "3.0.3"
+info:
+  title: Shop API
+  version: 1.2.0
+  description: |
+    Orders: create and list.
+paths:
+  /orders:
+    get:
+      operationId: listOrders
+      summary: List orders
+    post:
+      operationId: createOrder
+  /v1/{name}:cancel:
+    post:
+      operationId: cancelOrder
+components:
+  schemas:
+    Order:
+      type: object
+      properties:
+        id:
+          type: string
+    Error:
+      type: object
+`
+	os.WriteFile(filepath.Join(dir, "api.yaml"), []byte(yamlSpec), 0644)
+	summary, err := tools.SummarizeSchema(filepath.Join(dir, "api.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to summarize YAML spec: %v", err)
+	}
+	for _, expected := range []string{"OpenAPI 3.0.3: Shop API 1.2.0", "ENDPOINTS (3)", "GET /orders listOrders - List orders", "POST /orders createOrder", "POST /v1/{name}:cancel cancelOrder", "SCHEMAS (2):\n  Error\n  Order"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected %q in summary:\n%s", expected, summary)
+		}
+	}
+
+	jsonSpec := `{"swagger": "2.0", "info": {"title": "Legacy", "version": "1"}, "paths": {"/users/{id}": {"get": {"operationId": "getUser"}, "parameters": []}}, "definitions": {"User": {}}}`
+	os.WriteFile(filepath.Join(dir, "swagger.json"), []byte(jsonSpec), 0644)
+	summary, err = tools.SummarizeSchema(filepath.Join(dir, "swagger.json"))
+	if err != nil {
+		t.Fatalf("Failed to summarize JSON spec: %v", err)
+	}
+	if !strings.Contains(summary, "GET /users/{id} getUser") || !strings.Contains(summary, "SCHEMAS (1):\n  User") {
+		t.Errorf("Unexpected JSON summary:\n%s", summary)
+	}
+
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "app"}`), 0644)
+	if _, err := tools.SummarizeSchema(filepath.Join(dir, "package.json")); err == nil {
+		t.Error("Expected an error for JSON that isn't a spec")
+	}
+}
+
+// TestIsGeneratedFile tests recognizing generated code by marker, name and manifest
+func TestIsGeneratedFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+		return path
+	}
+
+	tests := map[string]bool{
+		write("marker.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\npackage api\n"): true,
+		write("orders.pb.go", "package api\n"):                                                true,
+		write("orders_pb2.py", "import x\n"):                                                  true,
+		write("client/api.ts", "export const x = 1\n"):                                        true,
+		write("handwritten.go", "package api\n\n// Do edit this\n"):                           false,
+	}
+	write("client/.openapi-generator/FILES", "README.md\napi.ts\n")
+	for path, expected := range tests {
+		if got := tools.IsGeneratedFile(path); got != expected {
+			t.Errorf("IsGeneratedFile(%s) = %v, expected %v", filepath.Base(path), got, expected)
+		}
+	}
+}
+
+// TestRegenerateCode tests running the configured generator and refusing hand edits of its output
+func TestRegenerateCode(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	defer os.Unsetenv("OPENROUTER_API_KEY")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	exec.Command("git", "init", "-q").Run()
+
+	cfg := agent.configManager.GetConfig()
+	if cfg.Preferences == nil {
+		cfg.Preferences = make(map[string]interface{})
+	}
+	previous, hadPrevious := cfg.Preferences[prefCodegenCommand]
+	defer func() {
+		if hadPrevious {
+			cfg.Preferences[prefCodegenCommand] = previous
+		} else {
+			delete(cfg.Preferences, prefCodegenCommand)
+		}
+	}()
+	cfg.Preferences[prefCodegenCommand] = `mkdir -p gen && printf '// Code generated by test. DO NOT EDIT.\npackage gen\n' > gen/api.go`
+
+	result, err := agent.regenerateCode()
+	if err != nil {
+		t.Fatalf("Failed to regenerate code: %v", err)
+	}
+	if !strings.Contains(result, "Changed 1 file(s):\n  gen/api.go") {
+		t.Errorf("Expected the generated file to be listed, got:\n%s", result)
+	}
+	if err := agent.generatedFileError("gen/api.go"); err == nil || !strings.Contains(err.Error(), "regenerate_code") {
+		t.Errorf("Expected edits of generated code to be refused, got %v", err)
+	}
+	if err := agent.generatedFileError("gen/new.go"); err != nil {
+		t.Errorf("Expected new files to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestSearchCode(t *testing.T) {
+	for _, useGit := range []bool{true, false} {
+		root := t.TempDir()
+		writeListingTree(t, root)
+		os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc Run() {}\n\nfunc run() { Run() }\n"), 0644)
+		os.WriteFile(filepath.Join(root, "build/gen.go"), []byte("func Run() {}\n"), 0644)
+		os.WriteFile(filepath.Join(root, "blob.dat"), []byte("func Run\x00"), 0644)
+		if useGit {
+			if err := exec.Command("git", "-C", root, "init", "-q").Run(); err != nil {
+				t.Skip("git not available")
+			}
+		}
+
+		result, err := tools.SearchCode(root, `func \w+\(`, tools.SearchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Matches) != 2 || result.Matches[0] != (tools.SearchMatch{File: "main.go", Line: 3, Text: "func Run() {}"}) || result.Matches[1].Line != 5 {
+			t.Errorf("git=%v: expected the two functions in main.go, got %+v", useGit, result.Matches)
+		}
+
+		result, _ = tools.SearchCode(root, "PACKAGE", tools.SearchOptions{IgnoreCase: true, Exclude: []string{"internal"}, MaxResults: 2})
+		if len(result.Matches) != 2 || !result.Truncated {
+			t.Errorf("git=%v: expected the search to stop at 2 matches, got %+v", useGit, result)
+		}
+
+		result, _ = tools.SearchCode(root, "{ Run() }", tools.SearchOptions{Literal: true, Include: []string{"*.go"}})
+		if len(result.Matches) != 1 || result.Matches[0].Line != 5 {
+			t.Errorf("git=%v: expected a literal match on line 5, got %+v", useGit, result.Matches)
+		}
+	}
+
+	root := t.TempDir()
+	writeListingTree(t, root)
+	if _, err := tools.SearchCode(root, "func (", tools.SearchOptions{}); err == nil {
+		t.Error("expected an invalid regular expression to fail")
+	}
+	result, _ := tools.SearchCode(filepath.Join(root, "main.go"), "package", tools.SearchOptions{})
+	formatted := tools.FormatSearchResult("main.go", "package", result)
+	if formatted != "1 match(es) for \"package\" in 1 file(s) under main.go\nmain.go\n  1: package main" {
+		t.Errorf("unexpected formatting of a single file search:\n%s", formatted)
+	}
+	if formatted := tools.FormatSearchResult(root, "nothing", tools.SearchResult{}); !strings.HasPrefix(formatted, "No matches") {
+		t.Errorf("expected a no matches message, got %q", formatted)
+	}
+}
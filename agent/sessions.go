@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/tools"
+)
+
+// sessionsDirName is the directory under ~/.coder holding named sessions
+const sessionsDirName = "sessions"
+
+// sessionNamePattern keeps session names usable as file names
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// NamedSession is a conversation saved under a name with /session save, with
+// everything needed to pick it up again
+type NamedSession struct {
+	ConversationState
+	Name     string           `json:"name"`
+	Todos    []tools.TodoItem `json:"todos"`
+	Provider string           `json:"provider"`
+	Model    string           `json:"model"`
+	Project  string           `json:"project"` // working directory the session was saved in
+}
+
+// sessionsDir returns the directory for named sessions
+func sessionsDir() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, sessionsDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	return dir, nil
+}
+
+// sessionPath returns the file a named session is stored in
+func sessionPath(name string) (string, error) {
+	if !sessionNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	dir, err := sessionsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// SaveNamedSession saves the conversation, todos and token stats under a
+// name, replacing an earlier session of that name. It returns the file path.
+func (a *Agent) SaveNamedSession(name string) (string, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return "", err
+	}
+	project, _ := os.Getwd()
+	session := NamedSession{
+		ConversationState: ConversationState{
+			Messages:          a.messages,
+			TaskActions:       a.taskActions,
+			TotalCost:         a.totalCost,
+			TotalTokens:       a.totalTokens,
+			PromptTokens:      a.promptTokens,
+			CompletionTokens:  a.completionTokens,
+			CachedTokens:      a.cachedTokens,
+			CachedCostSavings: a.cachedCostSavings,
+			LastUpdated:       time.Now(),
+			SessionID:         a.sessionID,
+		},
+		Name:     name,
+		Todos:    tools.GetAllTodos(),
+		Provider: a.GetProvider(),
+		Model:    a.GetModel(),
+		Project:  project,
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := config.WriteFileAtomic(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to save session: %w", err)
+	}
+	return path, nil
+}
+
+// LoadNamedSession reads a named session
+func LoadNamedSession(name string) (*NamedSession, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no session named %q (see /session list)", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var session NamedSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %q: %w", name, err)
+	}
+	return &session, nil
+}
+
+// ListNamedSessions returns the saved sessions, most recently saved first
+func ListNamedSessions() ([]NamedSession, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var sessions []NamedSession
+	for _, path := range paths {
+		session, err := LoadNamedSession(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUpdated.After(sessions[j].LastUpdated)
+	})
+	return sessions, nil
+}
+
+// ResumeNamedSession restores a named session's messages, todos and token
+// stats. The next query continues its conversation instead of starting a
+// new one.
+func (a *Agent) ResumeNamedSession(name string) (*NamedSession, error) {
+	session, err := LoadNamedSession(name)
+	if err != nil {
+		return nil, err
+	}
+	a.ApplyState(&session.ConversationState)
+	tools.RestoreTodos(session.Todos)
+	// The restored conversation carries its own context
+	a.previousSummary = ""
+	a.pendingToolCalls = nil
+	a.resumedSession = len(a.messages) > 0
+	return session, nil
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestNamedSessions(t *testing.T) {
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	defer tools.ClearTodos()
+
+	agent.messages = []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "refactor auth"}, {Role: "assistant", Content: "done"}}
+	agent.totalTokens, agent.promptTokens, agent.totalCost = 1200, 1000, 0.25
+	tools.ClearTodos()
+	tools.AddTodo("Move token checks", "", "high")
+
+	path, err := agent.SaveNamedSession("auth-refactor")
+	if err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if path != filepath.Join(home, ".coder", "sessions", "auth-refactor.json") {
+		t.Errorf("unexpected session path %s", path)
+	}
+	for _, name := range []string{"../escape", "", "a/b"} {
+		if _, err := agent.SaveNamedSession(name); err == nil {
+			t.Errorf("expected session name %q to be rejected", name)
+		}
+	}
+
+	agent.messages, agent.totalTokens, agent.promptTokens, agent.totalCost = nil, 0, 0, 0
+	tools.ClearTodos()
+	session, err := agent.ResumeNamedSession("auth-refactor")
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if len(agent.messages) != 3 || agent.totalTokens != 1200 || agent.promptTokens != 1000 || agent.totalCost != 0.25 {
+		t.Errorf("expected messages and token stats restored, got %d messages, %d tokens, $%f", len(agent.messages), agent.totalTokens, agent.totalCost)
+	}
+	if todos := tools.GetAllTodos(); len(todos) != 1 || todos[0].Title != "Move token checks" || len(session.Todos) != 1 {
+		t.Errorf("expected the todo restored, got %v", todos)
+	}
+	if !agent.resumedSession {
+		t.Error("expected the next query to continue the restored conversation")
+	}
+
+	sessions, err := ListNamedSessions()
+	if err != nil || len(sessions) != 1 || sessions[0].Name != "auth-refactor" {
+		t.Errorf("expected the saved session to be listed, got %v, %v", sessions, err)
+	}
+	if _, err := agent.ResumeNamedSession("missing"); err == nil {
+		t.Error("expected resuming an unknown session to fail")
+	}
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -9,7 +10,7 @@ import (
 )
 
 // executeShellCommandWithTruncation handles shell command execution with smart truncation and deduplication
-func (a *Agent) executeShellCommandWithTruncation(command string) (string, error) {
+func (a *Agent) executeShellCommandWithTruncation(command, why string) (string, error) {
 	const maxOutputLength = 20000 // 20K character limit
 	
 	// Check if we've run this exact command before
@@ -31,12 +32,48 @@ func (a *Agent) executeShellCommandWithTruncation(command string) (string, error
 		return output, nil
 	}
 	
-	// Execute the command for the first time
-	a.ToolLog("executing command", command)
-	a.debugLog("Executing shell command: %s\n", command)
-	
-	fullResult, err := tools.ExecuteShellCommand(command)
-	a.debugLog("Shell command result: %s, error: %v\n", fullResult, err)
+	// Whole-repository listings of large monorepos get an overview instead
+	if overview, sparse := a.sparseExploration(command); sparse {
+		a.ToolLog("sparse overview", command)
+		a.ToolIntent(why)
+		return overview, nil
+	}
+
+	// Exploration output from an earlier session is reused while the workspace is unchanged
+	fullResult, cached := a.cachedShellOutput(command)
+	var err error
+	if !cached {
+		// Execute the command for the first time
+		a.ToolLog("executing command", command)
+		a.ToolIntent(why)
+		a.debugLog("Executing shell command: %s\n", command)
+
+		// Without a go.work, go commands at the root of a multi-module repo run per module
+		var byModule bool
+		fullResult, byModule, err = a.runGoCommandByModule(command)
+		if !byModule {
+			fullResult, err = a.runShellCommand(command)
+		}
+		fullResult, err = sanitizeShellResult(fullResult, err)
+		if err != nil {
+			if hint := a.goModuleErrorHint(command, fullResult+err.Error()); hint != "" {
+				err = fmt.Errorf("%w%s", err, hint)
+			}
+		}
+		a.debugLog("Shell command result: %s, error: %v\n", fullResult, err)
+		if err == nil {
+			a.rememberShellOutput(command, fullResult)
+		}
+	} else {
+		a.ToolLog("using cached output", command)
+		a.ToolIntent(why)
+		fullResult = tools.SanitizeOutput(fullResult) // remembered by versions that didn't sanitize
+	}
+	fullResult, err = a.postProcessShellOutput(command, fullResult, err)
+	if err == nil {
+		fullResult = a.summarizeRepeatedOutput(command, fullResult)
+		fullResult = a.rerankSearchOutput(command, why, fullResult)
+	}
 	
 	// Determine what to return (truncated or full)
 	var returnResult string
@@ -78,4 +115,14 @@ func (a *Agent) updatePreviousShellCommandMessage(prevResult *ShellCommandResult
 			msg.Content = briefMessage
 		}
 	}
-}
\ No newline at end of file
+}
+
+// sanitizeShellResult strips escape sequences and control characters from
+// command output, and from the copy of it a failed command's error carries
+func sanitizeShellResult(output string, err error) (string, error) {
+	clean := tools.SanitizeOutput(output)
+	if err != nil && clean != output && strings.Contains(err.Error(), output) {
+		err = errors.New(strings.Replace(err.Error(), output, clean, 1))
+	}
+	return clean, err
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// postProcessShellOutput runs shell output through the post-processors whose
+// pattern matches the command (config shell_post_processors, or the defaults).
+// A failed command's error carries its output too, so that copy is rewritten
+// the same way.
+func (a *Agent) postProcessShellOutput(command, output string, err error) (string, error) {
+	if a.configManager == nil || output == "" {
+		return output, err
+	}
+
+	processed := output
+	var applied []string
+	for _, processor := range a.configManager.GetConfig().GetShellPostProcessors() {
+		if processor.Match != "" {
+			pattern, compileErr := regexp.Compile(processor.Match)
+			if compileErr != nil {
+				a.debugLog("⚠️ Ignoring shell post-processor with invalid match %q: %v\n", processor.Match, compileErr)
+				continue
+			}
+			if !pattern.MatchString(command) {
+				continue
+			}
+		}
+		for _, filter := range processor.Filters {
+			if !tools.ValidOutputFilter(filter) {
+				a.debugLog("⚠️ Ignoring unknown shell output filter %q\n", filter)
+				continue
+			}
+			processed = tools.ApplyOutputFilter(filter, processed)
+			applied = append(applied, filter)
+		}
+		if processor.Pipe != "" {
+			piped, pipeErr := tools.PipeOutput(processor.Pipe, processed)
+			if pipeErr != nil {
+				a.debugLog("⚠️ Keeping unpiped output: %v\n", pipeErr)
+				continue
+			}
+			processed = piped
+			applied = append(applied, "pipe")
+		}
+	}
+	if processed == output {
+		return output, err
+	}
+
+	processed = fmt.Sprintf("[OUTPUT POST-PROCESSED (%s): %d of %d lines kept]\n%s",
+		strings.Join(applied, ", "), strings.Count(processed, "\n")+1, strings.Count(output, "\n")+1, processed)
+	a.debugLog("Post-processed output of %s with %s\n", command, strings.Join(applied, ", "))
+	if err != nil && strings.Contains(err.Error(), output) {
+		err = errors.New(strings.Replace(err.Error(), output, processed, 1))
+	}
+	return processed, err
+}
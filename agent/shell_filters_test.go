@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestOutputFilters(t *testing.T) {
+	if got := tools.ApplyOutputFilter(tools.FilterStripANSI, "\x1b[1;31mFAIL\x1b[0m done\x1b]0;title\x07"); got != "FAIL done" {
+		t.Errorf("strip_ansi: got %q", got)
+	}
+	if got := tools.ApplyOutputFilter(tools.FilterCollapseProgress, "Downloading\n 10% [=>   ]\n 50% [==>  ]\n100% [====]\nspin |\rspin /\rdone\nnext"); got != "Downloading\n100% [====]\ndone\nnext" {
+		t.Errorf("collapse_progress: got %q", got)
+	}
+	if got := tools.ApplyOutputFilter(tools.FilterDedupeLines, "a\nwarn\nwarn\nwarn\nb"); got != "a\nwarn\n[previous line repeated 2 more times]\nb" {
+		t.Errorf("dedupe_lines: got %q", got)
+	}
+
+	goTest := "=== RUN   TestA\n    a_test.go:5: setup\n--- PASS: TestA (0.00s)\n=== RUN   TestB\n    b_test.go:9: want 2, got 3\n--- FAIL: TestB (0.00s)\nFAIL\nFAIL\texample.com/b\t0.01s\nok  \texample.com/a\t0.01s"
+	if got := tools.ApplyOutputFilter(tools.FilterGoTestFailures, goTest); got != "    b_test.go:9: want 2, got 3\n--- FAIL: TestB (0.00s)\nFAIL\nFAIL\texample.com/b\t0.01s" {
+		t.Errorf("go_test_failures: got %q", got)
+	}
+}
+
+func TestSanitizeOutput(t *testing.T) {
+	if got := tools.SanitizeOutput("\x1b[32mok\x1b[0m\r\nloading |\rloading /\rloaded\nab\bc\x07\x00\tend"); got != "ok\nloaded\nac\tend" {
+		t.Errorf("unexpected sanitized output %q", got)
+	}
+	if plain := "line one\n\tline two\n"; tools.SanitizeOutput(plain) != plain {
+		t.Error("expected plain output to be unchanged")
+	}
+
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Chdir(t.TempDir())
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation failure: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	output, err := agent.executeShellCommandWithTruncation(`printf '\033[31mred\033[0m\n50%%\r100%%\n'`, "")
+	if err != nil || output != "red\n100%\n" {
+		t.Errorf("unexpected output %q (%v)", output, err)
+	}
+	_, err = agent.executeShellCommandWithTruncation(`printf '\033[1mbroken\033[0m\n'; exit 2`, "")
+	if err == nil || strings.Contains(err.Error(), "\x1b") || !strings.Contains(err.Error(), "exit code 2: broken") {
+		t.Errorf("expected a sanitized error, got %q", err)
+	}
+}
+
+func TestShellPostProcessors(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Chdir(t.TempDir())
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation failure: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	// The default go test processor keeps the failure, including in the error
+	failing := `printf '=== RUN   TestA\n--- PASS: TestA (0.00s)\n=== RUN   TestB\n    b_test.go:9: boom\n--- FAIL: TestB (0.00s)\nFAIL\n'; exit 1 # go test`
+	output, err := agent.executeShellCommandWithTruncation(failing, "")
+	if err == nil || strings.Contains(err.Error(), "TestA") || !strings.Contains(err.Error(), "--- FAIL: TestB") {
+		t.Errorf("expected the error to carry only the failure, got %v", err)
+	}
+	if !strings.HasPrefix(output, "[OUTPUT POST-PROCESSED (go_test_failures)") || strings.Contains(output, "PASS: TestA") {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	// Configured processors replace the defaults; bad entries are skipped
+	cfg := agent.configManager.GetConfig()
+	cfg.ShellPostProcessors = []config.ShellPostProcessor{
+		{Match: `^printf`, Filters: []string{"dedupe_lines", "no_such_filter"}, Pipe: "grep -v noise"},
+		{Match: `(`, Filters: []string{"strip_ansi"}},
+	}
+	defer func() { cfg.ShellPostProcessors = nil }()
+	output, err = agent.executeShellCommandWithTruncation(`printf 'keep\nnoise\nkeep\nkeep\n'`, "")
+	if err != nil || !strings.Contains(output, "keep\n[previous line repeated 1 more times]") || strings.Contains(output, "noise") {
+		t.Errorf("unexpected output %q (%v)", output, err)
+	}
+	if output, _ = agent.executeShellCommandWithTruncation(`echo untouched`, ""); output != "untouched\n" {
+		t.Errorf("expected unmatched commands to pass through, got %q", output)
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	largeRepoFileCount   = 20000 // workspaces with this many files are explored sparsely
+	sparseOverviewDirs   = 40    // directories listed in the overview
+	sparseOverviewSample = 5     // files shown per directory
+)
+
+// isWholeRepoListing reports whether command lists every file in the
+// repository: tree without a depth limit, find or ls -R from the root, or git
+// ls-files without a pathspec
+func isWholeRepoListing(command string) bool {
+	command = strings.TrimSpace(command)
+	if strings.ContainsAny(command, ";&|><`$") {
+		return false // piped or filtered output is already scoped
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+
+	var args []string
+	var flags []string
+	collect := func(rest []string) {
+		for _, field := range rest {
+			if strings.HasPrefix(field, "-") {
+				flags = append(flags, field)
+			} else {
+				args = append(args, field)
+			}
+		}
+	}
+	rootOnly := func() bool {
+		for _, arg := range args {
+			if arg != "." && arg != "./" {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case fields[0] == "tree":
+		collect(fields[1:])
+		for _, flag := range flags {
+			if flag == "-L" || flag == "-d" || strings.HasPrefix(flag, "-P") || strings.HasPrefix(flag, "-I") {
+				return false
+			}
+		}
+		return rootOnly()
+	case fields[0] == "find":
+		// Only "find" and "find ." with no tests at all: any predicate scopes it
+		return len(fields) == 1 || (len(fields) == 2 && (fields[1] == "." || fields[1] == "./"))
+	case fields[0] == "ls":
+		collect(fields[1:])
+		recursive := false
+		for _, flag := range flags {
+			if (!strings.HasPrefix(flag, "--") && strings.Contains(flag, "R")) || flag == "--recursive" {
+				recursive = true
+			}
+		}
+		return recursive && rootOnly()
+	case len(fields) >= 2 && fields[0] == "git" && fields[1] == "ls-files":
+		collect(fields[2:])
+		for _, arg := range args {
+			if arg != "--" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// sparseExploration answers a whole-repository listing in a large workspace
+// with an overview of its top-level directories, so one command doesn't
+// enumerate 100k files. It returns false when the command should just run.
+func (a *Agent) sparseExploration(command string) (string, bool) {
+	if a.workspaceSnapshot == nil || len(a.workspaceSnapshot.Files) < largeRepoFileCount || !isWholeRepoListing(command) {
+		return "", false
+	}
+	files := make([]string, 0, len(a.workspaceSnapshot.Files))
+	for rel := range a.workspaceSnapshot.Files {
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return sparseOverview(files), true
+}
+
+// sparseOverview summarizes files by top-level directory, largest first
+func sparseOverview(files []string) string {
+	counts := make(map[string]int)
+	samples := make(map[string][]string)
+	var rootFiles []string
+	for _, rel := range files {
+		rel = path.Clean(strings.ReplaceAll(rel, "\\", "/"))
+		dir, _, nested := strings.Cut(rel, "/")
+		if !nested {
+			rootFiles = append(rootFiles, rel)
+			continue
+		}
+		counts[dir]++
+		if len(samples[dir]) < sparseOverviewSample {
+			samples[dir] = append(samples[dir], rel)
+		}
+	}
+
+	dirs := make([]string, 0, len(counts))
+	for dir := range counts {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if counts[dirs[i]] != counts[dirs[j]] {
+			return counts[dirs[i]] > counts[dirs[j]]
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[SPARSE] This repository has %d files, too many to list at once. Top-level directories by file count:\n", len(files))
+	for i, dir := range dirs {
+		if i == sparseOverviewDirs {
+			fmt.Fprintf(&b, "  ... and %d more directories\n", len(dirs)-i)
+			break
+		}
+		sort.Strings(samples[dir])
+		fmt.Fprintf(&b, "  %s/ (%d files) e.g. %s\n", dir, counts[dir], strings.Join(samples[dir], ", "))
+	}
+	if len(rootFiles) > 0 {
+		sort.Strings(rootFiles)
+		if len(rootFiles) > sparseOverviewDirs {
+			rootFiles = append(rootFiles[:sparseOverviewDirs], "...")
+		}
+		fmt.Fprintf(&b, "Files at the root: %s\n", strings.Join(rootFiles, ", "))
+	}
+	b.WriteString("Explore only the directories the task touches, e.g. `tree -L 2 <dir>`, `git ls-files <dir>` or `grep -rn <pattern> <dir>`.")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+// balanceRefreshInterval is how long a fetched provider balance is trusted
+const balanceRefreshInterval = 5 * time.Minute
+
+// GetSpendCap returns the configured session spend cap in USD (0 = no cap)
+func (a *Agent) GetSpendCap() float64 {
+	if a.configManager == nil {
+		return 0
+	}
+	return a.configManager.GetConfig().GetFloatPreference("spend_cap", 0)
+}
+
+// GetLastTaskCost returns what the previous task in this session cost
+func (a *Agent) GetLastTaskCost() float64 {
+	return a.lastTaskCost
+}
+
+// GetBalance returns the provider's remaining credit, refetching it when
+// stale. Failed fetches are remembered for as long, so providers without a
+// billing API aren't asked again on every task. Spending since the fetch is
+// subtracted so the figure stays current.
+func (a *Agent) GetBalance(refresh bool) (*api.Balance, error) {
+	if refresh || a.balanceCheckedFor != a.clientType || time.Since(a.balanceCheckedAt) > balanceRefreshInterval {
+		a.balance, a.balanceErr = api.GetBalance(a.clientType, config.GetSecret)
+		a.balanceCheckedAt, a.balanceCheckedFor = time.Now(), a.clientType
+		a.costAtBalanceFetch = a.totalCost
+	}
+	if a.balanceErr != nil {
+		return nil, a.balanceErr
+	}
+	current := *a.balance
+	current.Remaining -= a.totalCost - a.costAtBalanceFetch
+	return &current, nil
+}
+
+// CheckSpend refuses to start a task whose estimated cost would exceed the
+// configured spend cap or the provider's remaining prepaid credit. Free
+// models and postpaid accounts (no positive balance) are never refused.
+func (a *Agent) CheckSpend(estimate float64) error {
+	if estimate <= 0 {
+		return nil
+	}
+	if spendCap := a.GetSpendCap(); spendCap > 0 && a.totalCost+estimate > spendCap {
+		return fmt.Errorf("spend cap of $%.2f reached: $%.4f spent this session and the next task is estimated at $%.4f (raise spend_cap to continue)",
+			spendCap, a.totalCost, estimate)
+	}
+
+	balance, err := a.GetBalance(false)
+	if err != nil {
+		if !errors.Is(err, api.ErrBalanceUnavailable) {
+			a.debugLog("⚠️ Could not check %s balance: %v\n", a.GetProvider(), err)
+		}
+		return nil
+	}
+	if a.balance.Remaining > 0 && balance.Remaining < estimate {
+		return fmt.Errorf("remaining %s balance of $%.4f does not cover the estimated $%.4f for this task - add credit to continue",
+			balance.Provider, balance.Remaining, estimate)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// creditsServer serves OpenRouter's /credits with the given credits and
+// usage, counting requests in fetches if it isn't nil
+func creditsServer(t *testing.T, totalCredits, totalUsage float64, fetches *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches != nil && r.URL.Path == "/credits" {
+			*fetches++
+		}
+		if r.URL.Path != "/credits" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"total_credits": %v, "total_usage": %v}}`, totalCredits, totalUsage)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetBalanceParsesOpenRouterCredits(t *testing.T) {
+	t.Setenv("OPENROUTER_BASE_URL", creditsServer(t, 10, 2.5, nil).URL+"/")
+	var account, envVar string
+	balance, err := api.GetBalance(api.OpenRouterClientType, func(a, e string) (string, error) {
+		account, envVar = a, e
+		return "test-key", nil
+	})
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if balance.Provider != "openrouter" || balance.Remaining != 7.5 || balance.Used != 2.5 {
+		t.Errorf("unexpected balance %+v", balance)
+	}
+	if account != "openrouter" || envVar != "OPENROUTER_API_KEY" {
+		t.Errorf("expected the key from the openrouter keyring entry, looked up %q/%q", account, envVar)
+	}
+
+	_, err = api.GetBalance(api.OpenRouterClientType, func(string, string) (string, error) { return "wrong-key", nil })
+	if err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("expected the billing API's error, got %v", err)
+	}
+	_, err = api.GetBalance(api.OpenRouterClientType, func(string, string) (string, error) { return "", fmt.Errorf("no key") })
+	if err == nil {
+		t.Error("expected an error without a key")
+	}
+	if _, err := api.GetBalance(api.GroqClientType, nil); err != api.ErrBalanceUnavailable {
+		t.Errorf("expected no balance for Groq, got %v", err)
+	}
+}
+
+func TestCheckSpend(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("OPENROUTER_BASE_URL", creditsServer(t, 1, 0.5, nil).URL)
+	agent, err := NewAgentWithProvider(api.OpenRouterClientType, "")
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	// $0.50 of credit is left
+	if err := agent.CheckSpend(0.10); err != nil {
+		t.Errorf("expected a task within the balance to start, got %v", err)
+	}
+	if err := agent.CheckSpend(0.60); err == nil || !strings.Contains(err.Error(), "balance") {
+		t.Errorf("expected a task beyond the balance to be refused, got %v", err)
+	}
+
+	// Spending since the fetch counts against the balance
+	agent.totalCost += 0.45
+	if err := agent.CheckSpend(0.10); err == nil {
+		t.Error("expected the session's spending to be subtracted from the balance")
+	}
+
+	agent.configManager.GetConfig().Preferences["spend_cap"] = 0.40
+	if err := agent.CheckSpend(0.01); err == nil || !strings.Contains(err.Error(), "spend cap of $0.40") {
+		t.Errorf("expected the spend cap to refuse the task, got %v", err)
+	}
+}
+
+func TestCheckSpendWithoutPrepaidCredit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	fetches := 0
+	t.Setenv("OPENROUTER_BASE_URL", creditsServer(t, 0, 0, &fetches).URL)
+	agent, err := NewAgentWithProvider(api.OpenRouterClientType, "")
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	// A free model costs nothing, so the balance isn't fetched
+	if err := agent.CheckSpend(0); err != nil || fetches != 0 {
+		t.Errorf("expected a free task to start without a balance fetch, got %v after %d fetches", err, fetches)
+	}
+	// No prepaid credit (pay-as-you-go or postpaid) is not an empty balance
+	if err := agent.CheckSpend(0.10); err != nil {
+		t.Errorf("expected a task on an account without prepaid credit to start, got %v", err)
+	}
+	if err := agent.CheckSpend(0.10); err != nil || fetches != 1 {
+		t.Errorf("expected the balance to be fetched once per refresh interval, got %d fetches (%v)", fetches, err)
+	}
+}
@@ -5,8 +5,15 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
 )
 
+// stateFileName is the conversation state for continuity, in the project
+// state directory
+const stateFileName = "state.json"
+
 // ExportState exports the current agent state for persistence
 func (a *Agent) ExportState() ([]byte, error) {
 	// Generate compact summary for next session continuity
@@ -41,7 +48,7 @@ func (a *Agent) SaveStateToFile(filename string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filename, stateData, 0644)
+	return config.WriteFileAtomic(filename, stateData, 0644)
 }
 
 // LoadStateFromFile loads agent state from a file
@@ -88,7 +95,10 @@ func (a *Agent) SaveConversationSummary() error {
 	_ = a.GenerateConversationSummary() // Generate summary to update state
 	
 	// Save state to file
-	stateFile := ".coder_state.json"
+	stateFile, err := config.ProjectPath(stateFileName)
+	if err != nil {
+		return err
+	}
 	if err := a.SaveStateToFile(stateFile); err != nil {
 		return fmt.Errorf("failed to save conversation state: %v", err)
 	}
@@ -142,6 +152,15 @@ func (a *Agent) GetPreviousSummary() string {
 // SetSessionID sets the session identifier for continuity
 func (a *Agent) SetSessionID(sessionID string) {
 	a.sessionID = sessionID
+	if a.instances != nil {
+		a.instances.self.SessionID = sessionID
+	}
+}
+
+// NewSessionID returns an identifier unique to this instance and start time,
+// so instances running side by side never share session files
+func NewSessionID() string {
+	return fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
 }
 
 // GetSessionID returns the session identifier
@@ -151,7 +170,11 @@ func (a *Agent) GetSessionID() string {
 
 // loadPreviousSummary loads the previous conversation summary from the state file
 func (a *Agent) loadPreviousSummary() {
-	stateFile := ".coder_state.json"
+	stateFile, err := config.ProjectPath(stateFileName)
+	if err != nil {
+		a.debugLog("⚠️  No project state directory: %v\n", err)
+		return
+	}
 	
 	// Check if state file exists
 	if _, err := os.Stat(stateFile); err == nil {
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// prefStreaming turns streamed output in interactive mode on or off
+const prefStreaming = "streaming"
+
+// SetStreaming makes responses print as they are generated, unless the
+// streaming preference is off
+func (a *Agent) SetStreaming(enabled bool) {
+	if enabled && a.configManager != nil {
+		enabled = a.configManager.GetConfig().GetBoolPreference(prefStreaming, true)
+	}
+	a.streaming = enabled
+}
+
+// StreamedResult reports whether result was already printed as it streamed
+func (a *Agent) StreamedResult(result string) bool {
+	return a.streaming && strings.TrimSpace(result) != "" && strings.TrimSpace(result) == strings.TrimSpace(a.lastStreamed)
+}
+
+// requestFrom sends a request to client, streaming the response to the
+// terminal in streaming mode
+func (a *Agent) requestFrom(client api.ClientInterface, messages []api.Message, toolDefs []api.Tool) (*api.ChatResponse, error) {
+	if !a.streaming {
+		return client.SendChatRequest(a.operationContext(), messages, toolDefs, "high")
+	}
+
+	var content strings.Builder
+	inReasoning := false
+	resp, err := client.SendChatRequestStream(a.operationContext(), messages, toolDefs, "high", func(text, reasoning string) {
+		// Reasoning is only shown when debugging, dimmed
+		if reasoning != "" && a.debug {
+			if !inReasoning {
+				fmt.Print("\033[2m")
+				inReasoning = true
+			}
+			fmt.Print(reasoning)
+		}
+		if text == "" {
+			return
+		}
+		if inReasoning {
+			fmt.Print("\033[0m\n")
+			inReasoning = false
+		}
+		content.WriteString(text)
+		fmt.Print(text)
+	})
+	if inReasoning {
+		fmt.Print("\033[0m\n")
+	}
+	if content.Len() > 0 && !strings.HasSuffix(content.String(), "\n") {
+		fmt.Println()
+	}
+	a.lastStreamed = content.String()
+	return resp, err
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/providers"
+)
+
+const sampleStream = `: OPENROUTER PROCESSING
+
+data: {"id":"gen-1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"role":"assistant","reasoning":"Thinking"}}]}
+
+data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"Reading "}}]}
+
+data: {"id":"gen-1","choices":[{"index":0,"delta":{"content":"the file."}}]}
+
+data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"file_"}}]}}]}
+
+data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"path\": \"main.go\"}"}}]}}]}
+
+data: {"id":"gen-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"list_targets","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}
+
+data: {"id":"gen-1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}
+
+data: [DONE]
+`
+
+func TestReadChatStream(t *testing.T) {
+	var content, reasoning strings.Builder
+	resp, err := providers.ReadChatStream(strings.NewReader(sampleStream), func(c, r string) {
+		content.WriteString(c)
+		reasoning.WriteString(r)
+	})
+	if err != nil {
+		t.Fatalf("ReadChatStream: %v", err)
+	}
+	if content.String() != "Reading the file." || reasoning.String() != "Thinking" {
+		t.Errorf("callback got content %q, reasoning %q", content.String(), reasoning.String())
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("expected one choice, got %+v", resp.Choices)
+	}
+	message := resp.Choices[0].Message
+	if message.Role != "assistant" || message.Content != "Reading the file." || message.ReasoningContent != "Thinking" {
+		t.Errorf("assembled message: %+v", message)
+	}
+	if len(message.ToolCalls) != 2 || message.ToolCalls[0].Function.Arguments != `{"file_path": "main.go"}` || message.ToolCalls[1].Function.Name != "list_targets" {
+		t.Errorf("assembled tool calls: %+v", message.ToolCalls)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" || resp.Usage.TotalTokens != 17 || resp.ID != "gen-1" {
+		t.Errorf("finish reason %q, usage %+v, id %q", resp.Choices[0].FinishReason, resp.Usage, resp.ID)
+	}
+
+	// Fragments without an index are told apart by their IDs
+	resp, err = providers.ReadChatStream(strings.NewReader(`data: {"choices":[{"delta":{"tool_calls":[{"id":"a","function":{"name":"read_file","arguments":"{}"}},{"id":"b","function":{"name":"list_targets","arguments":"{"}}]}}]}
+data: {"choices":[{"delta":{"tool_calls":[{"function":{"arguments":"}"}}]}}]}
+`), nil)
+	if err != nil || len(resp.Choices[0].Message.ToolCalls) != 2 || resp.Choices[0].Message.ToolCalls[1].Function.Arguments != "{}" {
+		t.Errorf("unindexed tool calls: %+v, %v", resp, err)
+	}
+
+	for _, stream := range []string{
+		`data: {"error":{"message":"overloaded"}}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1000}]}}]}`,
+		`data: {not json`,
+	} {
+		if _, err := providers.ReadChatStream(strings.NewReader(stream), nil); err == nil {
+			t.Errorf("expected an error for %s", stream)
+		}
+	}
+	if resp, err := providers.ReadChatStream(strings.NewReader("data: [DONE]\n"), nil); err != nil || len(resp.Choices) != 0 {
+		t.Errorf("an empty stream should have no choices: %+v, %v", resp, err)
+	}
+}
+
+func TestProviderStreaming(t *testing.T) {
+	var requestBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(sampleStream))
+	}))
+	defer server.Close()
+	t.Setenv("HF_ENDPOINT_URL", server.URL)
+
+	client, err := api.NewUnifiedClientWithModel(api.HuggingFaceClientType, "")
+	if err != nil {
+		t.Fatalf("NewUnifiedClientWithModel: %v", err)
+	}
+	var streamed strings.Builder
+	resp, err := client.SendChatRequestStream(context.Background(), []api.Message{{Role: "user", Content: "hi"}}, nil, "", func(content, reasoning string) {
+		streamed.WriteString(content)
+	})
+	if err != nil {
+		t.Fatalf("SendChatRequestStream: %v", err)
+	}
+	if requestBody["stream"] != true {
+		t.Errorf("request wasn't streamed: %v", requestBody)
+	}
+	if streamed.String() != "Reading the file." || resp.Choices[0].Message.Content != "Reading the file." || len(resp.Choices[0].Message.ToolCalls) != 2 {
+		t.Errorf("streamed %q, response %+v", streamed.String(), resp.Choices[0].Message)
+	}
+}
+
+func TestAgentStreaming(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "replay.json")
+	os.WriteFile(script, []byte(`{"responses": [{"content": "Done, the file is updated."}, {"content": "again"}]}`), 0644)
+	t.Setenv(api.ReplayEnv, script)
+	os.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	agent.SetStreaming(true)
+	resp, err := agent.sendChatRequest([]api.Message{{Role: "user", Content: "update it"}}, nil)
+	if err != nil || resp.Choices[0].Message.Content != "Done, the file is updated." {
+		t.Fatalf("sendChatRequest: %+v, %v", resp, err)
+	}
+	if !agent.StreamedResult("Done, the file is updated.\n") {
+		t.Error("the streamed response should be recognised as already printed")
+	}
+	if agent.StreamedResult("something else") {
+		t.Error("only the streamed response was printed")
+	}
+
+	agent.SetStreaming(false)
+	agent.sendChatRequest(nil, nil)
+	if agent.StreamedResult("again") {
+		t.Error("nothing is printed without streaming")
+	}
+}
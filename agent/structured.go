@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// GenerateStructured asks the model a one-off question outside the conversation
+// and decodes its schema-validated answer into out. No tools are offered.
+func (a *Agent) GenerateStructured(prompt string, schema api.ResponseSchema, out interface{}) error {
+	return a.requestStructured([]api.Message{{Role: "user", Content: prompt}}, schema, out)
+}
+
+// GenerateStructuredAs is like GenerateStructured with the system prompt of a
+// variant, e.g. PromptCommit for commit messages
+func (a *Agent) GenerateStructuredAs(variant PromptVariant, prompt string, schema api.ResponseSchema, out interface{}) error {
+	return a.requestStructured([]api.Message{{Role: "system", Content: SystemPrompt(variant)}, {Role: "user", Content: prompt}}, schema, out)
+}
+
+// GenerateStructuredFromConversation is like GenerateStructured but the model
+// also sees the conversation so far, e.g. to turn its exploration into a plan
+func (a *Agent) GenerateStructuredFromConversation(prompt string, schema api.ResponseSchema, out interface{}) error {
+	messages := make([]api.Message, len(a.messages), len(a.messages)+1)
+	copy(messages, a.messages)
+	return a.requestStructured(append(messages, api.Message{Role: "user", Content: prompt}), schema, out)
+}
+
+// requestStructured sends a structured-output request and tracks its usage
+func (a *Agent) requestStructured(messages []api.Message, schema api.ResponseSchema, out interface{}) error {
+	resp, err := api.RequestStructured(a.operationContext(), a.client, messages, schema, false, out)
+	if resp != nil {
+		a.totalCost += resp.Usage.EstimatedCost
+		a.totalTokens += resp.Usage.TotalTokens
+		a.promptTokens += resp.Usage.PromptTokens
+		a.completionTokens += resp.Usage.CompletionTokens
+	}
+	if err != nil {
+		return fmt.Errorf("structured request for %s failed: %w", schema.Name, err)
+	}
+	a.debugLog("🧾 Structured %s response validated\n", schema.Name)
+	return nil
+}
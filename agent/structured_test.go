@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestExtractAndValidateStructuredOutput(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title":    map[string]interface{}{"type": "string"},
+			"severity": map[string]interface{}{"type": "string", "enum": []string{"low", "high"}},
+			"lines":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+		},
+		"required":             []string{"title", "severity"},
+		"additionalProperties": false,
+	}
+
+	fenced := "Here you go:\n```json\n{\"title\": \"Fix\", \"severity\": \"high\", \"lines\": [3, 7]}\n```"
+	document, err := api.ExtractJSON(fenced)
+	if err != nil {
+		t.Fatalf("expected JSON to be extracted from fenced answer: %v", err)
+	}
+	if err := api.ValidateJSON(document, schema); err != nil {
+		t.Errorf("expected valid document, got %v", err)
+	}
+
+	invalid := []struct {
+		document string
+		want     string
+	}{
+		{`{"severity": "low"}`, `missing required field "title"`},
+		{`{"title": "x", "severity": "medium"}`, "must be one of"},
+		{`{"title": "x", "severity": "low", "lines": [1.5]}`, "$.lines[0] must be of type integer"},
+		{`{"title": "x", "severity": "low", "extra": true}`, `unexpected field "extra"`},
+		{`["not", "an", "object"]`, "$ must be of type object"},
+	}
+	for _, tc := range invalid {
+		err := api.ValidateJSON([]byte(tc.document), schema)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("ValidateJSON(%s) = %v, want error containing %q", tc.document, err, tc.want)
+		}
+	}
+
+	if _, err := api.ExtractJSON("no json here"); err == nil {
+		t.Error("expected an error when the answer contains no JSON")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/alantheprice/coder/api"
 	"github.com/alantheprice/coder/tools"
 )
 
@@ -59,6 +60,7 @@ func (a *Agent) PrintConversationSummary(forceFull bool) {
 		a.formatTokenCount(a.currentContextTokens), 
 		a.formatTokenCount(a.maxContextTokens), 
 		contextUsage)
+	fmt.Printf("🧮 Token counting:     %s\n", api.TokenizerStatus(a.GetModel()))
 	
 	if a.cachedTokens > 0 {
 		efficiency := float64(a.cachedTokens)/float64(a.totalTokens)*100
@@ -89,6 +91,10 @@ func (a *Agent) PrintConversationSummary(forceFull bool) {
 		costPerIteration := a.totalCost / float64(a.currentIteration)
 		fmt.Printf("📋 Cost per iteration: $%.6f\n", costPerIteration)
 	}
+
+	// Show where the time of the last task went
+	a.printTimingSummary()
+	a.printHeavyToolCalls()
 	
 	// Show optimization stats if enabled
 	if a.optimizer.IsEnabled() {
@@ -131,6 +137,15 @@ func (a *Agent) PrintConciseSummary() {
 		a.formatTokenCount(actualProcessed), 
 		a.formatTokenCount(a.cachedTokens), 
 		costStr)
+	if totals := a.ChangedFiles(); len(totals) > 0 {
+		added, removed := 0, 0
+		for _, total := range totals {
+			added += total.LinesAdded
+			removed += total.LinesRemoved
+		}
+		fmt.Printf("📝 Files changed: %d (+%d/-%d lines) - /diff shows the changes\n", len(totals), added, removed)
+	}
+	a.printHeavyToolCalls()
 }
 
 // calculateCachedCost calculates the cost savings from cached tokens
@@ -142,6 +157,9 @@ func (a *Agent) calculateCachedCost(cachedTokens int) float64 {
 	// Calculate cost savings based on model pricing (input token rate)
 	costPerToken := 0.0
 	model := a.GetModel()
+	if strings.HasSuffix(model, ":free") {
+		return 0.0
+	}
 	
 	// Get input token pricing based on model and provider
 	provider := a.GetProvider()
@@ -225,6 +243,14 @@ func (a *Agent) GenerateConversationSummary() string {
 		}
 		summary.WriteString("\n")
 	}
+
+	// Add the files the tools changed, from their change records
+	if changed := a.changedFilesSummary(0); changed != "" {
+		summary.WriteString("📝 FILES CHANGED:\n")
+		summary.WriteString("──────────────────────────────\n")
+		summary.WriteString(changed)
+		summary.WriteString("\n")
+	}
 	
 	// Add todo summary
 	todoSummary := tools.GetTaskSummary()
@@ -252,6 +278,7 @@ func (a *Agent) GenerateConversationSummary() string {
 	summary.WriteString(fmt.Sprintf("• Iterations: %d\n", a.currentIteration))
 	summary.WriteString(fmt.Sprintf("• Total cost: $%.6f\n", a.totalCost))
 	summary.WriteString(fmt.Sprintf("• Total tokens: %s\n", a.formatTokenCount(a.totalTokens)))
+	summary.WriteString(a.timingSummaryLine())
 	
 	if a.cachedTokens > 0 {
 		efficiency := float64(a.cachedTokens)/float64(a.totalTokens)*100
@@ -299,8 +326,14 @@ func (a *Agent) GenerateCompactSummary() string {
 		summary.WriteString("\n")
 	}
 	
-	// Add key technical changes (limited and focused)
-	if len(a.taskActions) > 0 {
+	// Add key technical changes (limited and focused), from the tools' change
+	// records when there are any
+	if changed := a.changedFilesSummary(6); changed != "" {
+		summary.WriteString("🔧 KEY TECHNICAL CHANGES:\n")
+		summary.WriteString("─────────────────────────────\n")
+		summary.WriteString(changed)
+		summary.WriteString("\n")
+	} else if len(a.taskActions) > 0 {
 		summary.WriteString("🔧 KEY TECHNICAL CHANGES:\n")
 		summary.WriteString("─────────────────────────────\n")
 		
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestDiscoverTargets(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"Makefile": `VERSION := 1.0
+CC ::= gcc
+
+# Build the binary
+.PHONY: build
+build: deps
+	go build ./...
+
+test: build ## Run the tests
+	go test ./...
+
+%.o: %.c
+	$(CC) -c $<
+
+lint fmt:
+	golangci-lint run
+`,
+		"Taskfile.yml": `version: '3'
+
+vars:
+  NAME: app
+
+tasks:
+  default:
+    cmds:
+      - task: build
+  docker:build:
+    desc: Build the image
+    cmds:
+      - docker build .
+  setup:
+    internal: true
+    cmds:
+      - echo setup
+`,
+		"package.json":   `{"scripts": {"dev": "vite", "pretest": "tsc", "test": "vitest", "prepare": "husky"}}`,
+		"pnpm-lock.yaml": "",
+		"magefile.go": `//go:build mage
+
+package main
+
+type Docker mg.Namespace
+
+// Install installs the tool
+func Install() error { return nil }
+
+// Push pushes the image
+func (Docker) Push() error { return nil }
+
+func helper() {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]tools.Target)
+	for _, target := range tools.DiscoverTargets(root) {
+		got[target.Command] = target
+	}
+	want := map[string]string{
+		"make build":        "Build the binary",
+		"make test":         "Run the tests",
+		"make lint":         "",
+		"make fmt":          "",
+		"task default":      "",
+		"task docker:build": "Build the image",
+		"pnpm run dev":      "vite",
+		"pnpm run test":     "vitest",
+		"pnpm run prepare":  "husky",
+		"mage install":      "Install installs the tool",
+		"mage docker:push":  "Push pushes the image",
+	}
+	for command, description := range want {
+		target, ok := got[command]
+		if !ok {
+			t.Errorf("missing %s", command)
+			continue
+		}
+		if target.Description != description {
+			t.Errorf("%s: description %q, want %q", command, target.Description, description)
+		}
+	}
+	for _, unwanted := range []string{"make VERSION", "make CC", "make %.o", "make .PHONY", "task setup", "task NAME", "pnpm run pretest", "mage helper"} {
+		if _, ok := got[unwanted]; ok {
+			t.Errorf("unexpected target %s", unwanted)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d targets, want %d: %v", len(got), len(want), got)
+	}
+
+	formatted := tools.FormatTargets(tools.DiscoverTargets(root))
+	if !strings.Contains(formatted, "Makefile:\n  make build - Build the binary") {
+		t.Errorf("formatted targets:\n%s", formatted)
+	}
+	if tools.FormatTargets(nil) == "" {
+		t.Error("expected a message when there are no targets")
+	}
+}
@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// terraformTimeout limits one terraform command; plans and applies talk to cloud APIs
+const terraformTimeout = 15 * time.Minute
+
+// terraformPlanFile is a saved plan and what it changes
+type terraformPlanFile struct {
+	path string
+	plan *tools.TerraformPlan
+}
+
+// runTerraformCommand runs terraform (or tofu) in dir without prompting for input
+func runTerraformCommand(binary, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), terraformTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TF_IN_AUTOMATION=1", "TF_INPUT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s %s failed: %w\n%s", binary, strings.Join(args, " "), err, strings.TrimSpace(truncateTranscript(string(output), 4000)))
+	}
+	return string(output), nil
+}
+
+// ensureTerraformInit runs terraform init once for a directory. validate only
+// needs the providers, so it skips the backend and its credentials.
+func ensureTerraformInit(binary, dir string, backend bool) error {
+	if _, err := os.Stat(filepath.Join(dir, ".terraform")); err == nil {
+		return nil
+	}
+	args := []string{"init", "-input=false", "-no-color"}
+	if !backend {
+		args = append(args, "-backend=false")
+	}
+	_, err := runTerraformCommand(binary, dir, args...)
+	return err
+}
+
+// terraformPlanPath returns where the plan of a directory is saved, outside
+// the repository since plans can hold secrets
+func terraformPlanPath(dir string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := projectCacheDir(wd)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(dir))
+	return filepath.Join(cacheDir, "terraform-"+hex.EncodeToString(hash[:6])+".tfplan"), nil
+}
+
+// runTerraform runs one step of the infrastructure verify loop in dir:
+// fmt, validate, plan (summarized by resource) or apply of the last plan.
+// Applying a plan that destroys resources needs explicit approval.
+func (a *Agent) runTerraform(action, dir, why string) (string, error) {
+	binary, err := tools.TerraformBinary()
+	if err != nil {
+		return "", fmt.Errorf("%w - install Terraform or OpenTofu to verify infrastructure code", err)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if matches, _ := filepath.Glob(filepath.Join(abs, "*.tf")); len(matches) == 0 {
+		return "", fmt.Errorf("%s has no .tf files - pass the directory of the Terraform configuration as dir", dir)
+	}
+
+	switch action {
+	case "fmt":
+		a.ToolLog("terraform fmt", dir)
+		output, err := runTerraformCommand(binary, abs, "fmt", "-recursive", "-list=true", "-no-color")
+		if err != nil {
+			return "", err
+		}
+		var changed []string
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				path := filepath.Join(dir, line)
+				changed = append(changed, path)
+				a.fileWatcher.Track(path)
+				a.invalidateModifiedFile(path)
+			}
+		}
+		if len(changed) == 0 {
+			return fmt.Sprintf("%s fmt: all files in %s are formatted", binary, dir), nil
+		}
+		return fmt.Sprintf("%s fmt reformatted %d file(s) - re-read them before editing:\n  %s", binary, len(changed), strings.Join(changed, "\n  ")), nil
+
+	case "validate":
+		a.ToolLog("terraform validate", dir)
+		if err := ensureTerraformInit(binary, abs, false); err != nil {
+			return "", err
+		}
+		// validate -json exits non-zero for invalid configurations; the JSON says why
+		output, runErr := runTerraformCommand(binary, abs, "validate", "-json", "-no-color")
+		valid, diagnostics, err := tools.TerraformDiagnostics([]byte(output))
+		if err != nil {
+			if runErr != nil {
+				return "", runErr
+			}
+			return "", err
+		}
+		if !valid {
+			return "", fmt.Errorf("%s validate: the configuration in %s is invalid:\n%s", binary, dir, diagnostics)
+		}
+		if diagnostics != "" {
+			return fmt.Sprintf("✅ %s validate: %s is valid, with warnings:\n%s", binary, dir, diagnostics), nil
+		}
+		return fmt.Sprintf("✅ %s validate: %s is valid", binary, dir), nil
+
+	case "plan":
+		a.ToolLog("terraform plan", dir)
+		a.ToolIntent(why)
+		if a.CheckForInterrupt() {
+			return "", fmt.Errorf("🛑 Terraform plan interrupted by user")
+		}
+		if err := ensureTerraformInit(binary, abs, true); err != nil {
+			return "", err
+		}
+		planPath, err := terraformPlanPath(abs)
+		if err != nil {
+			return "", err
+		}
+		if _, err := runTerraformCommand(binary, abs, "plan", "-input=false", "-no-color", "-out="+planPath); err != nil {
+			return "", err
+		}
+		output, err := runTerraformCommand(binary, abs, "show", "-json", planPath)
+		if err != nil {
+			return "", err
+		}
+		plan, err := tools.ParseTerraformPlan([]byte(output))
+		if err != nil {
+			return "", err
+		}
+		if a.terraformPlans == nil {
+			a.terraformPlans = make(map[string]*terraformPlanFile)
+		}
+		a.terraformPlans[abs] = &terraformPlanFile{path: planPath, plan: plan}
+
+		result := fmt.Sprintf("%s plan for %s:\n%s", binary, dir, plan.Summary())
+		if plan.Destructive() {
+			result += "\n\n⚠️ This plan DESTROYS resources. Applying it needs the user's explicit approval - check the destroys are intended first."
+		}
+		return result, nil
+
+	case "apply":
+		saved := a.terraformPlans[abs]
+		if saved == nil {
+			return "", fmt.Errorf("no plan for %s - run terraform with action plan first, so the applied changes are the reviewed ones", dir)
+		}
+		summary := fmt.Sprintf("%s apply in %s\n%s", binary, dir, saved.plan.Summary())
+		if saved.plan.Destructive() {
+			risk := RiskAssessment{Level: RiskHigh, Reasons: []string{fmt.Sprintf("destroys %d resource(s)", len(saved.plan.Destroyed()))}}
+			if !a.approveAction("terraform", summary, why, risk) {
+				return "", fmt.Errorf("applying the plan for %s was not approved - it destroys: %s", dir, strings.Join(saved.plan.Destroyed(), ", "))
+			}
+		}
+		a.ToolLog("terraform apply", dir)
+		a.ToolIntent(why)
+		output, err := runTerraformCommand(binary, abs, "apply", "-input=false", "-no-color", saved.path)
+		delete(a.terraformPlans, abs) // a saved plan can only be applied once
+		os.Remove(saved.path)
+		if err != nil {
+			return "", err
+		}
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		for i, line := range lines {
+			if strings.HasPrefix(line, "Apply complete!") {
+				return fmt.Sprintf("✅ %s", strings.Join(lines[i:], "\n")), nil
+			}
+		}
+		return truncateTranscript(strings.TrimSpace(output), 2000), nil
+	}
+	return "", fmt.Errorf("unknown terraform action %q - use fmt, validate, plan or apply", action)
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+const testPlanJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "change": {"actions": ["create"]}},
+    {"address": "aws_instance.web", "change": {"actions": ["update"]}},
+    {"address": "aws_db_instance.main", "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_iam_role.old", "change": {"actions": ["delete"]}},
+    {"address": "aws_vpc.main", "change": {"actions": ["no-op"]}},
+    {"address": "data.aws_ami.ubuntu", "change": {"actions": ["read"]}}
+  ],
+  "output_changes": {
+    "bucket_arn": {"actions": ["create"]},
+    "vpc_id": {"actions": ["no-op"]}
+  }
+}`
+
+func TestParseTerraformPlan(t *testing.T) {
+	plan, err := tools.ParseTerraformPlan([]byte(testPlanJSON))
+	if err != nil {
+		t.Fatalf("ParseTerraformPlan: %v", err)
+	}
+	if len(plan.Creates) != 1 || len(plan.Updates) != 1 || len(plan.Replaces) != 1 || len(plan.Deletes) != 1 || len(plan.Reads) != 1 {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if !plan.Destructive() {
+		t.Error("a plan with deletes and replaces should be destructive")
+	}
+	if got := strings.Join(plan.Destroyed(), ","); got != "aws_iam_role.old,aws_db_instance.main" {
+		t.Errorf("Destroyed() = %s", got)
+	}
+
+	summary := plan.Summary()
+	for _, want := range []string{"Plan: 1 to add, 1 to change, 1 to replace, 1 to destroy.", "DESTROY (1):\n  aws_iam_role.old", "OUTPUTS changed (1):\n  bucket_arn"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+	if strings.Contains(summary, "aws_vpc.main") {
+		t.Errorf("summary lists a no-op resource:\n%s", summary)
+	}
+	if strings.Index(summary, "DESTROY") > strings.Index(summary, "CREATE") {
+		t.Errorf("destructive changes should come first:\n%s", summary)
+	}
+
+	empty, err := tools.ParseTerraformPlan([]byte(`{"resource_changes": [{"address": "a.b", "change": {"actions": ["no-op"]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty.Empty() || empty.Destructive() || !strings.HasPrefix(empty.Summary(), "No changes.") {
+		t.Errorf("no-op plan: %+v %q", empty, empty.Summary())
+	}
+}
+
+func TestTerraformDiagnostics(t *testing.T) {
+	valid, diagnostics, err := tools.TerraformDiagnostics([]byte(`{
+  "valid": false,
+  "diagnostics": [
+    {"severity": "error", "summary": "Unsupported argument", "detail": "An argument named \"nmae\" is not expected here.",
+     "range": {"filename": "main.tf", "start": {"line": 12, "column": 3}}}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("TerraformDiagnostics: %v", err)
+	}
+	if valid {
+		t.Error("expected invalid")
+	}
+	if !strings.Contains(diagnostics, "error: Unsupported argument (main.tf:12)") || !strings.Contains(diagnostics, `"nmae"`) {
+		t.Errorf("diagnostics = %q", diagnostics)
+	}
+}
+
+func TestTerraformApplyIsHighRiskInShell(t *testing.T) {
+	for _, command := range []string{"terraform apply -auto-approve", "tofu destroy", "terraform -chdir=infra apply", "terraform state rm aws_s3_bucket.logs"} {
+		if risk := assessShellRisk(command); risk.Level != RiskHigh {
+			t.Errorf("%q should be high risk", command)
+		}
+	}
+	for _, command := range []string{"terraform plan", "terraform validate", "terraform fmt -check"} {
+		if risk := assessShellRisk(command); risk.Level != RiskLow {
+			t.Errorf("%q should be low risk, got %s", command, risk.Tag())
+		}
+	}
+}
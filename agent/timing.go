@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+// Thresholds above which a tool call counts as expensive in the session summary
+const (
+	heavyCPUTime  = 10 * time.Second
+	heavyMaxRSS   = 1 << 30 // 1 GiB
+	heavyDuration = 30 * time.Second
+)
+
+// maxHeavyToolCalls bounds the expensive tool calls kept for the session
+const maxHeavyToolCalls = 5
+
+// ToolTiming records how long a single tool call took and, for calls that
+// ran commands, the CPU time and memory they used
+type ToolTiming struct {
+	Name      string
+	Detail    string // file path or command, if any
+	Iteration int
+	Duration  time.Duration
+	CPUTime   time.Duration // User plus system time of its commands
+	MaxRSS    int64         // Peak memory of its largest process, in bytes
+}
+
+// heavy reports whether a tool call used enough time or memory to point out
+func (t ToolTiming) heavy() bool {
+	return t.CPUTime >= heavyCPUTime || t.MaxRSS >= heavyMaxRSS || t.Duration >= heavyDuration
+}
+
+// weight orders expensive tool calls: the larger of their CPU and wall time
+func (t ToolTiming) weight() time.Duration {
+	return max(t.CPUTime, t.Duration)
+}
+
+// usageSummary describes the CPU time and memory of a call that ran commands
+func (t ToolTiming) usageSummary() string {
+	if t.CPUTime == 0 && t.MaxRSS == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [cpu %s, peak %s]", formatDuration(t.CPUTime), formatBytes(t.MaxRSS))
+}
+
+// IterationTiming splits one iteration into provider and tool time
+type IterationTiming struct {
+	Iteration int
+	Provider  time.Duration
+	Tools     time.Duration
+}
+
+// Total returns the time spent in the iteration's provider call and tools
+func (it IterationTiming) Total() time.Duration {
+	return it.Provider + it.Tools
+}
+
+// TaskTimings collects wall-clock timing for the current task
+type TaskTimings struct {
+	Started    time.Time
+	Finished   time.Time
+	Provider   time.Duration
+	Tools      time.Duration
+	ToolCalls  []ToolTiming
+	Iterations []IterationTiming
+}
+
+// newTaskTimings starts timing a task
+func newTaskTimings() *TaskTimings {
+	return &TaskTimings{Started: time.Now()}
+}
+
+// Total returns the wall-clock duration of the task so far
+func (t *TaskTimings) Total() time.Duration {
+	if t.Finished.IsZero() {
+		return time.Since(t.Started)
+	}
+	return t.Finished.Sub(t.Started)
+}
+
+// iteration returns the timing entry for an iteration, creating it if needed
+func (t *TaskTimings) iteration(n int) *IterationTiming {
+	if len(t.Iterations) == 0 || t.Iterations[len(t.Iterations)-1].Iteration != n {
+		t.Iterations = append(t.Iterations, IterationTiming{Iteration: n})
+	}
+	return &t.Iterations[len(t.Iterations)-1]
+}
+
+// recordProvider adds the duration of a provider call
+func (t *TaskTimings) recordProvider(iteration int, d time.Duration) {
+	t.Provider += d
+	t.iteration(iteration).Provider += d
+}
+
+// recordTool adds the duration and resource usage of a tool call
+func (t *TaskTimings) recordTool(iteration int, name, detail string, d time.Duration, usage tools.ResourceUsage) ToolTiming {
+	t.Tools += d
+	t.iteration(iteration).Tools += d
+	call := ToolTiming{Name: name, Detail: detail, Iteration: iteration, Duration: d, CPUTime: usage.CPUTime, MaxRSS: usage.MaxRSS}
+	t.ToolCalls = append(t.ToolCalls, call)
+	return call
+}
+
+// HeaviestTools returns the n tool calls that used the most CPU time
+func (t *TaskTimings) HeaviestTools(n int) []ToolTiming {
+	var calls []ToolTiming
+	for _, call := range t.ToolCalls {
+		if call.CPUTime > 0 {
+			calls = append(calls, call)
+		}
+	}
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].CPUTime > calls[j].CPUTime })
+	if len(calls) > n {
+		calls = calls[:n]
+	}
+	return calls
+}
+
+// SlowestTools returns the n slowest tool calls
+func (t *TaskTimings) SlowestTools(n int) []ToolTiming {
+	calls := append([]ToolTiming(nil), t.ToolCalls...)
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].Duration > calls[j].Duration })
+	if len(calls) > n {
+		calls = calls[:n]
+	}
+	return calls
+}
+
+// SlowestIterations returns the n slowest iterations
+func (t *TaskTimings) SlowestIterations(n int) []IterationTiming {
+	iterations := append([]IterationTiming(nil), t.Iterations...)
+	sort.SliceStable(iterations, func(i, j int) bool { return iterations[i].Total() > iterations[j].Total() })
+	if len(iterations) > n {
+		iterations = iterations[:n]
+	}
+	return iterations
+}
+
+// GetTaskTimings returns timing for the current or last task (nil before the first task)
+func (a *Agent) GetTaskTimings() *TaskTimings {
+	return a.timings
+}
+
+// recordHeavyToolCall keeps a tool call among the session's most expensive
+// ones when it crossed a threshold
+func (a *Agent) recordHeavyToolCall(call ToolTiming) {
+	if !call.heavy() {
+		return
+	}
+	a.heavyToolCalls = append(a.heavyToolCalls, call)
+	sort.SliceStable(a.heavyToolCalls, func(i, j int) bool { return a.heavyToolCalls[i].weight() > a.heavyToolCalls[j].weight() })
+	if len(a.heavyToolCalls) > maxHeavyToolCalls {
+		a.heavyToolCalls = a.heavyToolCalls[:maxHeavyToolCalls]
+	}
+}
+
+// HeavyToolCalls returns the session's tool calls that took over 10s of CPU
+// time, 1 GiB of memory or 30s, most expensive first
+func (a *Agent) HeavyToolCalls() []ToolTiming {
+	return a.heavyToolCalls
+}
+
+// printHeavyToolCalls lists the session's expensive tool calls, if any
+func (a *Agent) printHeavyToolCalls() {
+	if len(a.heavyToolCalls) == 0 {
+		return
+	}
+	fmt.Println("🔥 Expensive tool calls this session:")
+	for _, call := range a.heavyToolCalls {
+		fmt.Printf("   %8s  %s %s%s (iteration %d)\n", formatDuration(call.Duration), call.Name, shortDetail(call.Detail), call.usageSummary(), call.Iteration)
+	}
+}
+
+// printTimingSummary prints where the time of the last task went
+func (a *Agent) printTimingSummary() {
+	t := a.timings
+	if t == nil {
+		return
+	}
+
+	total := t.Total()
+	other := total - t.Provider - t.Tools
+	if other < 0 {
+		other = 0
+	}
+
+	fmt.Println()
+	fmt.Println("⏱️  Timing")
+	fmt.Println("──────────────────────────────")
+	fmt.Printf("🕐 Total duration:    %s\n", formatDuration(total))
+	fmt.Printf("🤖 Provider calls:    %s (%.0f%%)\n", formatDuration(t.Provider), percentOf(t.Provider, total))
+	fmt.Printf("🔧 Tool execution:    %s (%.0f%%)\n", formatDuration(t.Tools), percentOf(t.Tools, total))
+	fmt.Printf("⚙️  Other:             %s (%.0f%%)\n", formatDuration(other), percentOf(other, total))
+
+	if slowest := t.SlowestTools(3); len(slowest) > 0 {
+		fmt.Println("🐢 Slowest tools:")
+		for _, call := range slowest {
+			fmt.Printf("   %8s  %s %s%s (iteration %d)\n", formatDuration(call.Duration), call.Name, shortDetail(call.Detail), call.usageSummary(), call.Iteration)
+		}
+	}
+
+	if heaviest := t.HeaviestTools(3); len(heaviest) > 0 {
+		fmt.Println("🧮 Most CPU time:")
+		for _, call := range heaviest {
+			fmt.Printf("   %8s  %s %s (peak %s, wall %s)\n", formatDuration(call.CPUTime), call.Name, shortDetail(call.Detail), formatBytes(call.MaxRSS), formatDuration(call.Duration))
+		}
+	}
+
+	if slowest := t.SlowestIterations(3); len(slowest) > 1 {
+		fmt.Println("🐌 Slowest iterations:")
+		for _, it := range slowest {
+			fmt.Printf("   %8s  #%d (provider %s, tools %s)\n",
+				formatDuration(it.Total()), it.Iteration, formatDuration(it.Provider), formatDuration(it.Tools))
+		}
+	}
+}
+
+// timingSummaryLine returns a one-line timing breakdown for text summaries
+func (a *Agent) timingSummaryLine() string {
+	if a.timings == nil {
+		return ""
+	}
+	t := a.timings
+	return fmt.Sprintf("• Duration: %s (provider %s, tools %s)\n",
+		formatDuration(t.Total()), formatDuration(t.Provider), formatDuration(t.Tools))
+}
+
+// shortDetail cuts a tool call's detail for a summary line
+func shortDetail(detail string) string {
+	if len(detail) > 50 {
+		return detail[:47] + "..."
+	}
+	return detail
+}
+
+// formatBytes formats a memory size for display
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.0f MiB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+}
+
+// formatDuration rounds a duration for display
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
+
+// percentOf returns part as a percentage of total
+func percentOf(part, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestTaskTimingsSlowest(t *testing.T) {
+	timings := newTaskTimings()
+	timings.recordProvider(1, 2*time.Second)
+	timings.recordTool(1, "read_file", "main.go", 10*time.Millisecond, tools.ResourceUsage{})
+	timings.recordProvider(2, time.Second)
+	timings.recordTool(2, "shell_command", "go test ./...", 5*time.Second, tools.ResourceUsage{CPUTime: 12 * time.Second})
+	timings.recordTool(2, "read_file", "util.go", 20*time.Millisecond, tools.ResourceUsage{})
+
+	if timings.Provider != 3*time.Second {
+		t.Errorf("Expected 3s provider time, got %s", timings.Provider)
+	}
+	if len(timings.Iterations) != 2 {
+		t.Fatalf("Expected 2 iterations, got %d", len(timings.Iterations))
+	}
+
+	tools := timings.SlowestTools(2)
+	if len(tools) != 2 || tools[0].Name != "shell_command" || tools[1].Detail != "util.go" {
+		t.Errorf("Unexpected slowest tools: %+v", tools)
+	}
+
+	if heaviest := timings.HeaviestTools(3); len(heaviest) != 1 || heaviest[0].Name != "shell_command" {
+		t.Errorf("expected only the command that used CPU time, got %+v", heaviest)
+	}
+
+	iterations := timings.SlowestIterations(1)
+	if len(iterations) != 1 || iterations[0].Iteration != 2 || iterations[0].Tools != 5020*time.Millisecond {
+		t.Errorf("Unexpected slowest iteration: %+v", iterations)
+	}
+}
+
+func TestShellCommandResourceUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.timings = newTaskTimings()
+
+	// A busy loop, so the command has CPU time to report
+	call := api.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "shell_command"
+	call.Function.Arguments = `{"command": "i=0; while [ $i -lt 50000 ]; do i=$((i+1)); done; echo counted"}`
+	outcome := agent.runToolCall(call)
+	if outcome.err != nil {
+		t.Fatal(outcome.err)
+	}
+	if outcome.usage.Processes != 1 || outcome.usage.CPUTime <= 0 || outcome.usage.MaxRSS <= 0 {
+		t.Errorf("expected the command's CPU time and memory, got %+v", outcome.usage)
+	}
+	if agent.toolUsage != nil {
+		t.Error("expected the usage record released after the call")
+	}
+	agent.appendToolResult(call, outcome)
+	if heaviest := agent.timings.HeaviestTools(1); len(heaviest) != 1 || heaviest[0].CPUTime != outcome.usage.CPUTime {
+		t.Errorf("expected the command's CPU time in the task timings, got %+v", heaviest)
+	}
+}
+
+func TestHeavyToolCalls(t *testing.T) {
+	agent := &Agent{}
+	for i, call := range []ToolTiming{
+		{Name: "read_file", Duration: time.Second},
+		{Name: "shell_command", Detail: "go build ./...", Duration: 15 * time.Second, CPUTime: 90 * time.Second, MaxRSS: 2 << 30},
+		{Name: "shell_command", Detail: "curl example.com", Duration: 45 * time.Second, CPUTime: 50 * time.Millisecond},
+		{Name: "shell_command", Detail: "go vet ./...", Duration: 4 * time.Second, CPUTime: 6 * time.Second},
+	} {
+		call.Iteration = i + 1
+		agent.recordHeavyToolCall(call)
+	}
+
+	heavy := agent.HeavyToolCalls()
+	if len(heavy) != 2 || heavy[0].Detail != "go build ./..." || heavy[1].Detail != "curl example.com" {
+		t.Fatalf("expected the build then the slow download, got %+v", heavy)
+	}
+	if summary := heavy[0].usageSummary(); !strings.Contains(summary, "cpu 1m30s") || !strings.Contains(summary, "peak 2.0 GiB") {
+		t.Errorf("unexpected usage summary %q", summary)
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// toyRanks builds a rank file with every byte plus the given merges, in order
+func toyRanks(merges ...string) string {
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, merge := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), 256+i)
+	}
+	return b.String()
+}
+
+func TestTokenizerSplitsLikeTiktoken(t *testing.T) {
+	// Merges across piece boundaries rank first, so they show when text isn't split
+	ranks := toyRanks("o ", "  ", "n'", "34", "he", "ll", "hell", "hello", " w", "or", "ld", "do", "don", "'t", " b", "12", "123", "45")
+	tokenizer, err := api.NewTokenizer(api.EncodingCL100K, strings.NewReader(ranks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokenizer.Exact() {
+		t.Fatal("expected a tokenizer with ranks to be exact")
+	}
+	for text, want := range map[string]int{
+		"hello":       1, // the whole piece is a token
+		"hello world": 4, // hello | " w" or ld
+		"don't":       2, // don | 't
+		"a  b":        3, // a | " " | " b"
+		"12345":       2, // 123 | 45
+		"x\n\n  y":    6, // x | \n \n | " " | " " y
+	} {
+		if got := tokenizer.Count(text); got != want {
+			t.Errorf("Count(%q) = %d, want %d", text, got, want)
+		}
+	}
+
+	// o200k_base splits at case changes and keeps contractions with the word
+	o200k, _ := api.NewTokenizer(api.EncodingO200K, strings.NewReader(toyRanks("oW", "hello", "World", " it's")))
+	if got := o200k.Count("helloWorld it's"); got != 3 {
+		t.Errorf("expected hello | World | \" it's\", got %d tokens", got)
+	}
+
+	if _, err := api.NewTokenizer("p50k_base", nil); err == nil {
+		t.Error("expected an unknown encoding to fail")
+	}
+	if _, err := api.NewTokenizer(api.EncodingCL100K, strings.NewReader("not-base64! 1\n")); err == nil {
+		t.Error("expected a malformed rank file to fail")
+	}
+}
+
+func TestApproximateTokenCounts(t *testing.T) {
+	if api.EncodingForModel("openai/gpt-4o-mini") != api.EncodingO200K || api.EncodingForModel("o3-mini") != api.EncodingO200K {
+		t.Error("expected GPT-4o and o-series models to use o200k_base")
+	}
+	if api.EncodingForModel("deepseek/deepseek-chat-v3.1:free") != api.EncodingCL100K {
+		t.Error("expected other models to use cl100k_base")
+	}
+
+	tokenizer, _ := api.NewTokenizer(api.EncodingCL100K, nil)
+	if got := tokenizer.Count("The quick brown fox jumps over the lazy dog."); got != 10 {
+		t.Errorf("expected a token per English word and the period, got %d", got)
+	}
+	chinese := "这是一个用于测试的中文句子"
+	if got := tokenizer.Count(chinese); got < len([]rune(chinese)) || got <= len(chinese)/4 {
+		t.Errorf("expected about a token per CJK character, got %d for %d characters", got, len([]rune(chinese)))
+	}
+	if got := tokenizer.Count("estimateContextTokens"); got != 3 {
+		t.Errorf("expected a token per identifier hump, got %d", got)
+	}
+}
+
+func TestContextTokensUseTokenizer(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	chinese := strings.Repeat("这是一个测试", 100)
+	messages := []api.Message{{Role: "user", Content: chinese}, {Role: "assistant", Content: "ok"}}
+	if got := agent.estimateContextTokens(messages); got < 600 {
+		t.Errorf("expected the CJK text to count about a token per character, got %d (chars/4 would be %d)", got, len(chinese)/4)
+	}
+
+	// Eviction works in the same tokens as the estimate
+	var history []api.Message
+	history = append(history, api.Message{Role: "system", Content: "system"}, api.Message{Role: "user", Content: "task"})
+	for i := 0; i < 8; i++ {
+		history = append(history,
+			api.Message{Role: "assistant", Content: "checking"},
+			api.Message{Role: "user", Content: "Tool call result for shell_command: cat notes.txt\n" + chinese})
+	}
+	target := agent.estimateContextTokens(history) / 2
+	optimized := agent.optimizer.AggressiveOptimization(history, target)
+	if got := agent.estimateContextTokens(optimized); got > target+target/5 {
+		t.Errorf("expected eviction to get near %d tokens, got %d", target, got)
+	}
+}
@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
+)
+
+// parseToolArguments decodes a tool call's arguments into an object. Models
+// often emit nearly-valid JSON, so when strict decoding fails the arguments are
+// repaired (code fences, double encoding, raw newlines in strings, trailing
+// commas, truncated output) and decoded again. The error is the strict one, so
+// the model sees what was actually wrong with what it sent.
+func parseToolArguments(raw string) (map[string]interface{}, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return map[string]interface{}{}, nil
+	}
+	var args map[string]interface{}
+	err := json.Unmarshal([]byte(trimmed), &args)
+	if err != nil {
+		repaired, ok := repairToolArguments(trimmed, 1)
+		if !ok || json.Unmarshal([]byte(repaired), &args) != nil {
+			return nil, fmt.Errorf("failed to parse tool arguments: %w", err)
+		}
+	}
+	if args == nil { // "null"
+		args = map[string]interface{}{}
+	}
+	return args, nil
+}
+
+// repairToolArguments rewrites nearly-valid JSON arguments into an object that
+// may decode; depth bounds how often a double-encoded string is unwrapped
+func repairToolArguments(s string, depth int) (string, bool) {
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		if newline := strings.IndexByte(s, '\n'); newline != -1 && !strings.Contains(s[:newline], "{") {
+			s = s[newline+1:] // the language tag
+		}
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+	if strings.HasPrefix(s, `"`) && depth > 0 {
+		var inner string
+		if json.Unmarshal([]byte(s), &inner) != nil {
+			return "", false
+		}
+		inner = strings.TrimSpace(inner)
+		if json.Valid([]byte(inner)) {
+			return inner, true
+		}
+		return repairToolArguments(inner, depth-1)
+	}
+	if !strings.HasPrefix(s, "{") {
+		return "", false
+	}
+
+	out := make([]byte, 0, len(s)+8)
+	var closers []byte
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\n':
+				out = append(out, '\\', 'n')
+				continue
+			case c == '\r':
+				out = append(out, '\\', 'r')
+				continue
+			case c == '\t':
+				out = append(out, '\\', 't')
+				continue
+			case c < 0x20:
+				out = append(out, fmt.Sprintf(`\u%04x`, c)...)
+				continue
+			}
+			out = append(out, c)
+			continue
+		}
+		if len(closers) == 0 && len(out) > 0 {
+			// Something follows the complete object
+			if strings.TrimSpace(s[i:]) == "" {
+				break
+			}
+			return "", false
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			if len(closers) == 0 || closers[len(closers)-1] != c {
+				return "", false
+			}
+			closers = closers[:len(closers)-1]
+			out = trimTrailingComma(out)
+		}
+		out = append(out, c)
+	}
+
+	// Close whatever a truncated response left open
+	if inString {
+		if escaped {
+			out = out[:len(out)-1]
+		}
+		out = append(out, '"')
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		out = append(trimTrailingComma(out), closers[i])
+	}
+	return string(out), true
+}
+
+// trimTrailingComma drops a comma (and the whitespace after it) ending out
+func trimTrailingComma(out []byte) []byte {
+	trimmed := strings.TrimRight(string(out), " \t\r\n")
+	if strings.HasSuffix(trimmed, ",") {
+		return out[:len(trimmed)-1]
+	}
+	return out
+}
+
+// editFileArguments validates the arguments of an edit_file call
+func editFileArguments(args map[string]interface{}) (filePath, oldString, newString string, err error) {
+	filePath = stringArg(args, "file_path", "path")
+	if filePath == "" {
+		return "", "", "", fmt.Errorf("invalid file_path argument")
+	}
+	oldString, ok := args["old_string"].(string)
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid old_string argument")
+	}
+	newString, ok = args["new_string"].(string)
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid new_string argument")
+	}
+	if oldString == newString {
+		return "", "", "", fmt.Errorf("old_string and new_string are identical - the edit would change nothing")
+	}
+	return filePath, oldString, newString, nil
+}
+
+// editFileMultiArguments validates the arguments of an edit_file_multi call
+func editFileMultiArguments(args map[string]interface{}) (string, []tools.Replacement, error) {
+	filePath := stringArg(args, "file_path", "path")
+	if filePath == "" {
+		return "", nil, fmt.Errorf("invalid file_path argument")
+	}
+	edits, err := editsArg(args)
+	return filePath, edits, err
+}
+
+// editsArg reads the edits of an edit_file_multi call, which may come as an
+// array of objects or as that array JSON-encoded
+func editsArg(args map[string]interface{}) ([]tools.Replacement, error) {
+	raw := args["edits"]
+	if encoded, ok := raw.(string); ok {
+		if err := json.Unmarshal([]byte(encoded), &raw); err != nil {
+			return nil, fmt.Errorf("invalid edits argument: %w", err)
+		}
+	}
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("edits must be a non-empty array of {old_string, new_string} objects")
+	}
+	edits := make([]tools.Replacement, 0, len(list))
+	for i, item := range list {
+		edit, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("edit %d is not an {old_string, new_string} object", i+1)
+		}
+		oldString, okOld := edit["old_string"].(string)
+		newString, okNew := edit["new_string"].(string)
+		if !okOld || !okNew {
+			return nil, fmt.Errorf("edit %d needs old_string and new_string strings", i+1)
+		}
+		edits = append(edits, tools.Replacement{OldString: oldString, NewString: newString})
+	}
+	return edits, nil
+}
+
+// shellNames are the shells a {"cmd": [...]} call may start with
+var shellNames = map[string]bool{"bash": true, "sh": true, "zsh": true, "/bin/bash": true, "/bin/sh": true}
+
+// extractToolCallsFromContent attempts to parse tool calls from the assistant's content or reasoning_content
+func (a *Agent) extractToolCallsFromContent(content string) []api.ToolCall {
+	var toolCalls []api.ToolCall
+
+	if content == "" {
+		return toolCalls
+	}
+
+	// Look for tool_calls JSON structure in content
+	if start := strings.Index(content, `{"tool_calls":`); start != -1 {
+		var toolCallData struct {
+			ToolCalls []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Name      string          `json:"name"`
+					Arguments json.RawMessage `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		}
+		// Decode just the object, so prose after it doesn't break parsing
+		if err := json.NewDecoder(strings.NewReader(content[start:])).Decode(&toolCallData); err == nil {
+			for i, call := range toolCallData.ToolCalls {
+				if call.Function.Name == "" {
+					continue
+				}
+				// Arguments are a JSON string in the API, but content often holds an
+				// object; anything unparseable is left for executeTool to report
+				arguments := string(call.Function.Arguments)
+				var encoded string
+				if json.Unmarshal(call.Function.Arguments, &encoded) == nil {
+					arguments = encoded
+				} else if len(call.Function.Arguments) == 0 || arguments == "null" {
+					arguments = "{}"
+				}
+				toolCalls = append(toolCalls, newContentToolCall(call.ID, call.Function.Name, arguments, i))
+			}
+		}
+	}
+
+	// Also check for alternative formats like {"cmd": ["bash", "-lc", "ls -R"]}
+	if len(toolCalls) == 0 && strings.Contains(content, `"cmd":`) {
+		var cmdData struct {
+			Cmd []string `json:"cmd"`
+		}
+
+		start := strings.Index(content, "{")
+		if start != -1 && json.NewDecoder(strings.NewReader(content[start:])).Decode(&cmdData) == nil && len(cmdData.Cmd) > 0 {
+			// Convert cmd format to shell_command tool call, dropping the shell and its flags
+			cmd := cmdData.Cmd
+			if len(cmd) > 1 && shellNames[cmd[0]] {
+				cmd = cmd[1:]
+				if len(cmd) > 1 && strings.HasPrefix(cmd[0], "-") {
+					cmd = cmd[1:]
+				}
+			}
+			command := strings.TrimSpace(strings.Join(cmd, " "))
+			if command != "" {
+				arguments, _ := json.Marshal(map[string]string{"command": command})
+				toolCalls = append(toolCalls, newContentToolCall("", "shell_command", string(arguments), 0))
+			}
+		}
+	}
+
+	return toolCalls
+}
+
+// newContentToolCall builds a tool call recovered from content, with an ID if it had none
+func newContentToolCall(id, name, arguments string, index int) api.ToolCall {
+	if id == "" {
+		id = fmt.Sprintf("call_%d_%d", time.Now().UnixNano(), index)
+	}
+	var toolCall api.ToolCall
+	toolCall.ID = id
+	toolCall.Type = "function"
+	toolCall.Function.Name = name
+	toolCall.Function.Arguments = arguments
+	return toolCall
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestParseToolArguments(t *testing.T) {
+	tests := []struct {
+		name, raw string
+		want      map[string]interface{}
+	}{
+		{"valid", `{"file_path": "a.go"}`, map[string]interface{}{"file_path": "a.go"}},
+		{"empty", "  ", map[string]interface{}{}},
+		{"null", "null", map[string]interface{}{}},
+		{"code fence", "```json\n{\"command\": \"ls\"}\n```", map[string]interface{}{"command": "ls"}},
+		{"double encoded", `"{\"command\": \"ls\"}"`, map[string]interface{}{"command": "ls"}},
+		{"trailing commas", `{"a": [1, 2,], "b": "x",}`, map[string]interface{}{"a": []interface{}{1.0, 2.0}, "b": "x"}},
+		{"raw newline", "{\"content\": \"line 1\nline 2\"}", map[string]interface{}{"content": "line 1\nline 2"}},
+		{"truncated", `{"file_path": "a.go", "content": "package ma`, map[string]interface{}{"file_path": "a.go", "content": "package ma"}},
+		{"truncated escape", `{"content": "a\`, map[string]interface{}{"content": "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseToolArguments(tt.raw)
+			if err != nil {
+				t.Fatalf("parseToolArguments(%q): %v", tt.raw, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseToolArguments(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	for _, raw := range []string{`[1, 2]`, `{"a": 1} {"b": 2}`, `{"a": }`, `{"a": 1]`, `ls -la`, `""`} {
+		if args, err := parseToolArguments(raw); err == nil {
+			t.Errorf("parseToolArguments(%q) = %v, want an error", raw, args)
+		} else if !strings.HasPrefix(err.Error(), "failed to parse tool arguments") {
+			t.Errorf("unexpected error for %q: %v", raw, err)
+		}
+	}
+}
+
+func TestExtractToolCallsFromContent(t *testing.T) {
+	a := &Agent{}
+
+	calls := a.extractToolCallsFromContent(`Let me look. {"tool_calls": [{"id": "c1", "function": {"name": "read_file", "arguments": {"file_path": "main.go"}}}, {"function": {"name": "shell_command", "arguments": "{\"command\": \"ls\"}"}}, {"function": {"name": ""}}]} Done.`)
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %+v", calls)
+	}
+	if calls[0].ID != "c1" || calls[0].Function.Name != "read_file" || calls[0].Function.Arguments != `{"file_path": "main.go"}` {
+		t.Errorf("object arguments: %+v", calls[0])
+	}
+	if calls[1].ID == "" || calls[1].Function.Arguments != `{"command": "ls"}` {
+		t.Errorf("string arguments: %+v", calls[1])
+	}
+
+	tests := map[string]string{
+		`{"cmd": ["bash", "-lc", "ls -R"]}`:            "ls -R",
+		`{"cmd": ["ls", "-la"]}`:                       "ls -la",
+		`Running {"cmd": ["sh", "echo \"hi\""]} now`:   `echo "hi"`,
+		`{"cmd": ["bash", "-c", "grep -n 'x' a.go"]} `: "grep -n 'x' a.go",
+	}
+	for content, want := range tests {
+		calls := a.extractToolCallsFromContent(content)
+		if len(calls) != 1 || calls[0].Function.Name != "shell_command" {
+			t.Errorf("%s: expected one shell_command, got %+v", content, calls)
+			continue
+		}
+		args, err := parseToolArguments(calls[0].Function.Arguments)
+		if err != nil || args["command"] != want {
+			t.Errorf("%s: command = %v (%v), want %q", content, args["command"], err, want)
+		}
+	}
+
+	for _, content := range []string{"", "no tools here", `{"tool_calls": [`, `{"cmd": []}`, `{"cmd": [" "]}`} {
+		if calls := a.extractToolCallsFromContent(content); len(calls) != 0 {
+			t.Errorf("%q: expected no tool calls, got %+v", content, calls)
+		}
+	}
+}
+
+func TestEditFileArguments(t *testing.T) {
+	path, oldString, newString, err := editFileArguments(map[string]interface{}{"path": "a.go", "old_string": "x", "new_string": ""})
+	if err != nil || path != "a.go" || oldString != "x" || newString != "" {
+		t.Errorf("editFileArguments = %q, %q, %q, %v", path, oldString, newString, err)
+	}
+	for _, args := range []map[string]interface{}{
+		{"old_string": "x", "new_string": "y"},
+		{"file_path": 3.0, "old_string": "x", "new_string": "y"},
+		{"file_path": "a.go", "new_string": "y"},
+		{"file_path": "a.go", "old_string": "x", "new_string": nil},
+		{"file_path": "a.go", "old_string": "x", "new_string": "x"},
+	} {
+		if _, _, _, err := editFileArguments(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func FuzzParseToolArguments(f *testing.F) {
+	for _, seed := range []string{
+		`{"file_path": "a.go", "content": "x"}`,
+		"```json\n{\"a\": 1}\n```",
+		`"{\"a\": [1,]}"`,
+		`{"a": {"b": [1, {"c": "d`,
+		"{\"a\": \"\x01\t\"}",
+		`{"a": "\u00`,
+		`{}}`,
+		`null`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, raw string) {
+		args, err := parseToolArguments(raw)
+		if err != nil {
+			if args != nil {
+				t.Errorf("parseToolArguments(%q) returned arguments with an error", raw)
+			}
+			return
+		}
+		if args == nil {
+			t.Errorf("parseToolArguments(%q) returned nil arguments", raw)
+		}
+	})
+}
+
+func FuzzExtractToolCallsFromContent(f *testing.F) {
+	for _, seed := range []string{
+		`{"tool_calls": [{"id": "1", "function": {"name": "read_file", "arguments": "{\"file_path\": \"a\"}"}}]}`,
+		`text {"tool_calls": [{"function": {"name": "x", "arguments": {"a": [1]}}}]} more }`,
+		`{"tool_calls": [{"function": {"name": "x", "arguments": 42}}]}`,
+		`{"cmd": ["bash", "-lc", "echo \"}\""]}`,
+		`"cmd": {"cmd": ["sh"]}`,
+		`{"tool_calls": null, "cmd": ["ls"]}`,
+	} {
+		f.Add(seed)
+	}
+	a := &Agent{}
+	f.Fuzz(func(t *testing.T, content string) {
+		for _, call := range a.extractToolCallsFromContent(content) {
+			if call.ID == "" || call.Function.Name == "" || call.Type != "function" {
+				t.Errorf("incomplete tool call from %q: %+v", content, call)
+			}
+			if call.Function.Name == "shell_command" && !strings.Contains(content, "tool_calls") {
+				args, err := parseToolArguments(call.Function.Arguments)
+				if err != nil || stringArg(args, "command") == "" {
+					t.Errorf("cmd call from %q has bad arguments %q: %v", content, call.Function.Arguments, err)
+				}
+			}
+		}
+	})
+}
+
+func FuzzEditFile(f *testing.F) {
+	f.Add("package main\n\nfunc main() {}\n", `{"old_string": "main() {}", "new_string": "main() {\n}"}`)
+	f.Add("a a a", `{"old_string": "a", "new_string": "b"}`)
+	f.Add("x", `{"old_string": "", "new_string": "y"}`)
+	f.Add("x", `{"old_string": "x", "new_string": null}`)
+	f.Add("héllo", "{\"old_string\": \"é\", \"new_string\": \"e\n\"")
+	f.Fuzz(func(t *testing.T, original, raw string) {
+		path := filepath.Join(t.TempDir(), "file.txt")
+		if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args, err := parseToolArguments(raw)
+		if err != nil {
+			return
+		}
+		args["file_path"] = path // never let the input choose where to write
+		_, oldString, newString, err := editFileArguments(args)
+		if err == nil {
+			_, err = tools.EditFile(path, oldString, newString)
+		}
+
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		if err != nil {
+			if string(data) != original {
+				t.Errorf("a failed edit changed the file: %v", err)
+			}
+			return
+		}
+		if strings.Count(original, oldString) != 1 {
+			t.Errorf("edit succeeded with %d matches of %q", strings.Count(original, oldString), oldString)
+		}
+		if want := strings.Replace(original, oldString, newString, 1); string(data) != want {
+			t.Errorf("edited content = %q, want %q", data, want)
+		}
+	})
+}
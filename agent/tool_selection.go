@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// ToolNames returns the names of the tools the model can be offered
+func ToolNames() []string {
+	definitions := api.GetToolDefinitions()
+	names := make([]string, len(definitions))
+	for i, tool := range definitions {
+		names[i] = tool.Function.Name
+	}
+	return names
+}
+
+// isKnownTool reports whether a tool is offered to the model
+func isKnownTool(name string) bool {
+	for _, known := range ToolNames() {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetToolEnabled enables or disables a tool for the rest of the session.
+// Disabled tools are left out of the tool list sent to the model and refused
+// if it calls them anyway.
+func (a *Agent) SetToolEnabled(name string, enabled bool) error {
+	if !isKnownTool(name) {
+		return fmt.Errorf("unknown tool '%s'. Tools are: %s", name, strings.Join(ToolNames(), ", "))
+	}
+	a.toolPreset = "" // the tools no longer match a preset
+	if enabled {
+		delete(a.disabledTools, name)
+		return nil
+	}
+	if a.disabledTools == nil {
+		a.disabledTools = make(map[string]bool)
+	}
+	a.disabledTools[name] = true
+	return nil
+}
+
+// IsToolEnabled reports whether a tool is offered to the model
+func (a *Agent) IsToolEnabled(name string) bool {
+	return !a.disabledTools[name]
+}
+
+// DisabledTools returns the tools disabled for this session, sorted
+func (a *Agent) DisabledTools() []string {
+	var names []string
+	for name := range a.disabledTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toolDefinitions returns the definitions of the enabled tools
+func (a *Agent) toolDefinitions() []api.Tool {
+	definitions := api.GetToolDefinitions()
+	if len(a.disabledTools) == 0 {
+		return definitions
+	}
+	enabled := definitions[:0]
+	for _, tool := range definitions {
+		if !a.disabledTools[tool.Function.Name] {
+			enabled = append(enabled, tool)
+		}
+	}
+	return enabled
+}
+
+// disabledToolsForPrompt tells the model which tools the system prompt
+// describes but it can't use
+func (a *Agent) disabledToolsForPrompt() string {
+	disabled := a.DisabledTools()
+	if len(disabled) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\nDISABLED TOOLS: the user disabled %s for this session. Don't call them; work with the remaining tools or explain what you would need.", strings.Join(disabled, ", "))
+}
+
+// ApplyToolPreset enables exactly the tools of a named preset from the
+// config's tool_presets or the defaults (full, explore, ci)
+func (a *Agent) ApplyToolPreset(name string) error {
+	cfg := a.configManager.GetConfig()
+	preset, ok := cfg.GetToolPreset(name)
+	if !ok {
+		return fmt.Errorf("unknown tool preset '%s'. Presets are: %s", name, strings.Join(cfg.ToolPresetNames(), ", "))
+	}
+
+	if err := a.enableTools(name, preset); err != nil {
+		return err
+	}
+	a.toolPreset = name
+	return nil
+}
+
+// enableTools enables exactly the tools a preset's entries name
+func (a *Agent) enableTools(name string, preset []string) error {
+	enabled := make(map[string]bool)
+	for _, entry := range preset {
+		switch {
+		case entry == "*":
+			for _, tool := range ToolNames() {
+				enabled[tool] = true
+			}
+		case strings.HasPrefix(entry, "-"):
+			delete(enabled, strings.TrimPrefix(entry, "-"))
+		case isKnownTool(entry):
+			enabled[entry] = true
+		default:
+			return fmt.Errorf("tool preset '%s' names unknown tool '%s'", name, entry)
+		}
+	}
+
+	a.disabledTools = make(map[string]bool)
+	for _, tool := range ToolNames() {
+		if !enabled[tool] {
+			a.disabledTools[tool] = true
+		}
+	}
+	return nil
+}
+
+// ToolPreset returns the name of the preset last applied, if any
+func (a *Agent) ToolPreset() string {
+	return a.toolPreset
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestDisableTools(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation failure: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	all := len(agent.toolDefinitions())
+	if all != len(ToolNames()) || agent.disabledToolsForPrompt() != "" {
+		t.Fatalf("expected every tool enabled by default, got %d of %d", all, len(ToolNames()))
+	}
+
+	for _, name := range []string{"shell_command", "write_file"} {
+		if err := agent.SetToolEnabled(name, false); err != nil {
+			t.Fatalf("SetToolEnabled(%s): %v", name, err)
+		}
+	}
+	if err := agent.SetToolEnabled("rm_rf", false); err == nil {
+		t.Error("expected unknown tools to be rejected")
+	}
+
+	definitions := agent.toolDefinitions()
+	if len(definitions) != all-2 {
+		t.Errorf("expected two tools dropped from the request, got %d of %d", len(definitions), all)
+	}
+	for _, tool := range definitions {
+		if tool.Function.Name == "shell_command" || tool.Function.Name == "write_file" {
+			t.Errorf("disabled tool %s was still offered", tool.Function.Name)
+		}
+	}
+	if prompt := agent.disabledToolsForPrompt(); !strings.Contains(prompt, "shell_command, write_file") {
+		t.Errorf("expected the prompt to name the disabled tools, got %q", prompt)
+	}
+
+	// A model that calls a disabled tool anyway is refused
+	call := api.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "shell_command"
+	call.Function.Arguments = `{"command": "echo hi"}`
+	if _, err := agent.executeTool(call); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("expected the disabled tool to be refused, got %v", err)
+	}
+
+	if err := agent.SetToolEnabled("shell_command", true); err != nil || !agent.IsToolEnabled("shell_command") {
+		t.Errorf("expected shell_command to be enabled again (%v)", err)
+	}
+	if disabled := agent.DisabledTools(); len(disabled) != 1 || disabled[0] != "write_file" {
+		t.Errorf("expected only write_file disabled, got %v", disabled)
+	}
+}
+
+func TestApplyToolPreset(t *testing.T) {
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation failure: %v", err)
+	}
+	defer agent.CloseInstance()
+
+	if err := agent.ApplyToolPreset("explore"); err != nil {
+		t.Fatalf("ApplyToolPreset(explore): %v", err)
+	}
+	if agent.IsToolEnabled("write_file") || agent.IsToolEnabled("edit_file") || !agent.IsToolEnabled("read_file") || agent.ToolPreset() != "explore" {
+		t.Errorf("expected explore to allow reads only, disabled %v", agent.DisabledTools())
+	}
+
+	if err := agent.ApplyToolPreset("ci"); err != nil {
+		t.Fatalf("ApplyToolPreset(ci): %v", err)
+	}
+	if disabled := agent.DisabledTools(); len(disabled) != 3 || !agent.IsToolEnabled("write_file") {
+		t.Errorf("expected ci to disable the browser and screenshot tools, got %v", disabled)
+	}
+
+	// Configured presets replace or add to the defaults
+	cfg := agent.configManager.GetConfig()
+	cfg.ToolPresets = map[string][]string{"no-shell": {"*", "-shell_command"}, "broken": {"read_file", "rm_rf"}}
+	defer func() { cfg.ToolPresets = nil }()
+	if err := agent.ApplyToolPreset("no-shell"); err != nil {
+		t.Fatalf("ApplyToolPreset(no-shell): %v", err)
+	}
+	if disabled := agent.DisabledTools(); len(disabled) != 1 || disabled[0] != "shell_command" {
+		t.Errorf("expected only shell_command disabled, got %v", disabled)
+	}
+	if err := agent.ApplyToolPreset("broken"); err == nil {
+		t.Error("expected a preset naming an unknown tool to be rejected")
+	}
+	if err := agent.ApplyToolPreset("missing"); err == nil || !strings.Contains(err.Error(), "no-shell") {
+		t.Errorf("expected the error to list the presets, got %v", err)
+	}
+
+	// Toggling a tool leaves the preset
+	agent.SetToolEnabled("shell_command", true)
+	if agent.ToolPreset() != "" || len(agent.DisabledTools()) != 0 {
+		t.Errorf("expected no preset and no disabled tools, got %q %v", agent.ToolPreset(), agent.DisabledTools())
+	}
+}
@@ -1,9 +1,11 @@
 package agent
 
 import (
-	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,18 +13,270 @@ import (
 	"github.com/alantheprice/coder/tools"
 )
 
+// executeToolCalls runs each tool call and appends one result message per call.
+// Consecutive read-only calls run concurrently, but their results are appended
+// in the order the model made the calls. read_file and shell_command results
+// carry their path or command on the first line, and every result is
+// registered with the optimizer by message index. When the calls finish, large
+// exploration results may be replaced by summaries (see summarizeLargeResults).
+func (a *Agent) executeToolCalls(toolCalls []api.ToolCall) {
+	var appended []appendedResult
+	for i := 0; i < len(toolCalls); {
+		// Calls an interrupt stops before are kept to run on resume;
+		// cancelled tasks drop them
+		if a.cancelled() {
+			return
+		}
+		if a.CheckForInterrupt() {
+			a.pendingToolCalls = toolCalls[i:]
+			return
+		}
+
+		batch := toolCalls[i : i+a.parallelBatchSize(toolCalls[i:])]
+		for j, outcome := range a.runToolCalls(batch) {
+			appended = append(appended, appendedResult{index: len(a.messages), toolCall: batch[j], err: outcome.err})
+			a.appendToolResult(batch[j], outcome)
+		}
+		i += len(batch)
+	}
+	a.summarizeLargeResults(appended)
+}
+
+// toolOutcome is the result of running one tool call, before it is added to
+// the conversation
+type toolOutcome struct {
+	args     map[string]interface{}
+	risk     RiskAssessment
+	result   string
+	err      error
+	duration time.Duration
+	usage    tools.ResourceUsage // What the commands it ran used
+}
+
+// runToolCall executes a tool call without touching the conversation, so
+// read-only calls can run concurrently
+func (a *Agent) runToolCall(toolCall api.ToolCall) toolOutcome {
+	args, _ := parseToolArguments(toolCall.Function.Arguments)
+
+	risk := assessToolRisk(toolCall.Function.Name, args)
+	if risk.Level == RiskHigh {
+		fmt.Printf("⚠️  %s %s\n", risk.Tag(), toolCall.Function.Name)
+	}
+
+	// Only calls that run alone can run commands, so they own the usage record
+	var usage tools.ResourceUsage
+	if !parallelSafeTools[toolCall.Function.Name] {
+		a.toolUsage = &usage
+		defer func() { a.toolUsage = nil }()
+	}
+	started := time.Now()
+	result, err := a.executeTool(toolCall)
+	return toolOutcome{args: args, risk: risk, result: result, err: err, duration: time.Since(started), usage: usage}
+}
+
+// appendToolResult records a tool call's timing and metrics and appends its
+// result message
+func (a *Agent) appendToolResult(toolCall api.ToolCall, outcome toolOutcome) {
+	args, result, err := outcome.args, outcome.result, outcome.err
+	record := ToolResultRecord{
+		ToolCallID: toolCall.ID,
+		ToolName:   toolCall.Function.Name,
+	}
+	if record.ToolCallID == "" {
+		// Some providers send tool calls without ids; the result still needs one to be tracked
+		record.ToolCallID = fmt.Sprintf("call_%d", len(a.messages))
+	}
+
+	if a.timings != nil {
+		detail := stringArg(args, "file_path", "path", "command", "cmd", "image_path")
+		if toolCall.Function.Name == "apply_patch" {
+			// A patch can change several files
+			detail = strings.Join(changedPaths(args), ", ")
+		}
+		call := a.timings.recordTool(a.currentIteration, toolCall.Function.Name, detail, outcome.duration, outcome.usage)
+		a.recordHeavyToolCall(call)
+	}
+	if a.metrics != nil {
+		a.metrics.RecordToolExecution(a.GetProvider(), a.GetModel(), toolCall.Function.Name, err)
+	}
+
+	content := fmt.Sprintf("Tool call result for %s: %s", toolCall.Function.Name, result)
+	if err != nil {
+		content = fmt.Sprintf("Tool call result for %s: Error executing tool %s: %s", toolCall.Function.Name, toolCall.Function.Name, err.Error())
+	} else {
+		a.recordChange(toolCall.Function.Name, args)
+		switch toolCall.Function.Name {
+		case "read_file":
+			record.FilePath = stringArg(args, "file_path", "path")
+			record.ModTime = statFile(record.FilePath).modTime
+			content = fmt.Sprintf("Tool call result for read_file: %s\n%s", record.FilePath, result)
+		case "shell_command":
+			record.Command = stringArg(args, "command", "cmd")
+			if !strings.Contains(record.Command, "\n") {
+				content = fmt.Sprintf("Tool call result for shell_command: %s\n%s", record.Command, result)
+			}
+		case "list_directory", "glob", "search_code":
+			record.Command = listingQuery(args)
+			content = fmt.Sprintf("Tool call result for %s: %s\n%s", toolCall.Function.Name, record.Command, result)
+		}
+	}
+
+	if outcome.risk.Level == RiskHigh {
+		content = outcome.risk.Tag() + " " + content
+	}
+
+	a.optimizer.RecordToolResult(record)
+	a.messages = append(a.messages, api.Message{
+		Role:       "user",
+		Content:    content,
+		ToolCallID: record.ToolCallID,
+	})
+}
+
+// stringArg returns the first string argument found under any of the given names
+func stringArg(args map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if value, ok := args[name].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// intArg returns an integer argument, which JSON decodes as a number or the model may quote
+func intArg(args map[string]interface{}, name string) (int, bool) {
+	switch value := args[name].(type) {
+	case float64:
+		return int(value), value == float64(int(value))
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// stringListArg returns a list argument, which the model may send as an
+// array or a comma-separated string
+func stringListArg(args map[string]interface{}, name string) []string {
+	var list []string
+	switch value := args[name].(type) {
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				list = append(list, strings.TrimSpace(s))
+			}
+		}
+	case string:
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+// listOptionsArg reads the depth and pattern arguments of list_directory and glob
+func listOptionsArg(args map[string]interface{}, defaultDepth int) tools.ListOptions {
+	depth, ok := intArg(args, "max_depth")
+	if !ok || depth < 0 {
+		depth = defaultDepth
+	}
+	return tools.ListOptions{MaxDepth: depth, Include: stringListArg(args, "include"), Exclude: stringListArg(args, "exclude")}
+}
+
+// listingQuery describes a list_directory, glob or search_code call, so
+// identical calls can be recognized in the conversation
+func listingQuery(args map[string]interface{}) string {
+	query := stringArg(args, "path", "dir")
+	if query == "" {
+		query = "."
+	}
+	if pattern := stringArg(args, "pattern"); pattern != "" {
+		query += " " + pattern
+	}
+	if depth, ok := intArg(args, "max_depth"); ok {
+		query += fmt.Sprintf(" depth=%d", depth)
+	}
+	if include := stringListArg(args, "include"); len(include) > 0 {
+		query += " include=" + strings.Join(include, ",")
+	}
+	if exclude := stringListArg(args, "exclude"); len(exclude) > 0 {
+		query += " exclude=" + strings.Join(exclude, ",")
+	}
+	for _, flag := range []string{"ignore_case", "literal"} {
+		if set, _ := args[flag].(bool); set {
+			query += " " + flag
+		}
+	}
+	if limit, ok := intArg(args, "max_results"); ok {
+		query += fmt.Sprintf(" max_results=%d", limit)
+	}
+	return query
+}
+
+// invalidateModifiedFile marks earlier reads of a file as stale after it was modified.
+// Cached shell output is dropped too since it may depend on the file.
+func (a *Agent) invalidateModifiedFile(filePath string) {
+	a.optimizer.InvalidateFile(filePath, len(a.messages))
+	a.shellCommandHistory = make(map[string]*ShellCommandResult)
+}
+
+// afterFileChange records a change an edit tool made, shows its diff and
+// returns the notes of the checks run on the changed file
+func (a *Agent) afterFileChange(change tools.FileChange, description, before string) string {
+	a.recordFileChange(change, description, []byte(before))
+	a.fileWatcher.Track(change.Path)
+	a.invalidateModifiedFile(change.Path)
+	if change.Kind == tools.ChangeDeleted {
+		return ""
+	}
+	if after, err := os.ReadFile(change.Path); err == nil {
+		a.ShowColoredDiff(before, string(after), 50)
+	}
+	return a.afterEditChecks(change.Path) + a.frontendVerifyHint(change.Path) + a.publishInstanceEdit(change.Path)
+}
+
+// frontendVerifyHint reminds the model to check the rendered result after a UI
+// file changed in a project with a configured dev server
+func (a *Agent) frontendVerifyHint(filePath string) string {
+	if !tools.IsFrontendFile(filePath) || tools.LoadDevServerConfig(a.configManager.GetConfig()).Command == "" {
+		return ""
+	}
+	return "\n\nThis file affects the rendered UI - run verify_frontend once your UI edits are done to check the result."
+}
+
+// compressFileContent applies the configured compression to large file reads
+func (a *Agent) compressFileContent(filePath, content string) string {
+	settings := a.optimizer.Settings()
+	if settings.FileCompression == tools.CompressNone || len(content) < settings.CompressMinChars {
+		return content
+	}
+
+	query := ""
+	if len(a.messages) > 1 {
+		query = a.messages[1].Content
+	}
+	compressed := tools.CompressFileContent(filePath, content, settings.FileCompression, tools.FocusTerms(query, content))
+	if len(compressed) >= len(content) {
+		return content
+	}
+	a.debugLog("🗜️  Compressed %s: %d → %d chars (%s)\n", filePath, len(content), len(compressed), settings.FileCompression)
+	return compressed
+}
+
 // executeTool handles the execution of individual tool calls
 func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
-	var args map[string]interface{}
-	if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-		return "", fmt.Errorf("failed to parse tool arguments: %w", err)
+	args, err := parseToolArguments(toolCall.Function.Arguments)
+	if err != nil {
+		return "", err
 	}
 
 	// Log the tool call for debugging
 	a.debugLog("🔧 Executing tool: %s with args: %v\n", toolCall.Function.Name, args)
 	
 	// Validate tool name and provide helpful error for common mistakes
-	validTools := []string{"shell_command", "read_file", "write_file", "edit_file", "add_todo", "update_todo_status", "list_todos", "add_bulk_todos", "auto_complete_todos", "get_next_todo", "list_all_todos", "get_active_todos_compact", "archive_completed", "update_todo_status_bulk", "analyze_ui_screenshot", "analyze_image_content"}
+	validTools := []string{"shell_command", "read_file", "write_file", "edit_file", "add_todo", "update_todo_status", "list_todos", "add_bulk_todos", "auto_complete_todos", "get_next_todo", "list_all_todos", "get_active_todos_compact", "archive_completed", "update_todo_status_bulk", "analyze_ui_screenshot", "analyze_image_content", "compare_images", "verify_frontend", "read_notebook", "edit_cell", "run_cell", "summarize_schema", "regenerate_code", "terraform", "list_targets", "fetch_full_result", "undo_last_change", "list_directory", "glob", "search_code", "edit_file_multi", "apply_patch"}
 	isValidTool := false
 	for _, valid := range validTools {
 		if toolCall.Function.Name == valid {
@@ -40,6 +294,19 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		}
 		return "", fmt.Errorf("unknown tool '%s'. Valid tools are: %v", toolCall.Function.Name, validTools)
 	}
+	if !a.IsToolEnabled(toolCall.Function.Name) {
+		return "", fmt.Errorf("%s is disabled for this session; use the other tools", toolCall.Function.Name)
+	}
+
+	// Project policies can deny a call first; high-risk actions then need
+	// confirmation even when everything else runs unattended
+	if err := a.authorizeToolCall(toolCall.Function.Name, args); err != nil {
+		return "", err
+	}
+	// Runaway tasks pause once their total changes pass the per-task caps
+	if err := a.checkChangeSize(toolCall.Function.Name, args); err != nil {
+		return "", err
+	}
 
 	switch toolCall.Function.Name {
 	case "shell_command":
@@ -51,7 +318,14 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 				return "", fmt.Errorf("invalid command argument")
 			}
 		}
-		return a.executeShellCommandWithTruncation(command)
+		why, _ := args["why"].(string)
+		result, err := a.executeShellCommandWithTruncation(command, why)
+		a.recordShellChanges()
+		if paths := a.optimizer.InvalidateForShellCommand(command, len(a.messages)); len(paths) > 0 {
+			a.debugLog("🔄 Shell command may have modified: %s\n", strings.Join(paths, ", "))
+			a.shellCommandHistory = make(map[string]*ShellCommandResult)
+		}
+		return result, err
 
 	case "read_file":
 		filePath, ok := args["file_path"].(string)
@@ -64,7 +338,32 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		}
 		a.ToolLog("reading file", filePath)
 		a.debugLog("Reading file: %s\n", filePath)
-		result, err := tools.ReadFile(filePath)
+		if tools.IsNotebook(filePath) {
+			// Raw notebook JSON is mostly escaping and outputs; show the cells instead
+			result, err := tools.ReadNotebook(filePath, true)
+			if err == nil {
+				a.fileWatcher.Track(filePath)
+				result = "[NOTEBOOK: shown as cells - change it with edit_cell, not edit_file]\n" + result
+			}
+			return result, err
+		}
+		offset, ranged := intArg(args, "offset")
+		limit, limited := intArg(args, "limit")
+		if ranged || limited {
+			// Sections are returned exactly, with line numbers
+			result, err := tools.ReadFileLines(filePath, offset, limit)
+			if err == nil {
+				a.fileWatcher.Track(filePath)
+			}
+			return result, err
+		}
+		result, err := a.readFile(filePath)
+		if err == nil {
+			a.fileWatcher.Track(filePath)
+			if full, _ := args["full"].(bool); !full {
+				result = a.compressFileContent(filePath, result)
+			}
+		}
 		a.debugLog("Read file result: %s, error: %v\n", result, err)
 		return result, err
 
@@ -81,30 +380,54 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		if !ok {
 			return "", fmt.Errorf("invalid content argument")
 		}
+		if err := a.generatedFileError(filePath); err != nil {
+			return "", err
+		}
+		// Rewriting a file the agent has read must not clobber edits made since
+		defer a.fileWatcher.Lock(filePath)()
+		if err := a.editConflict(filePath); err != nil {
+			return "", err
+		}
 		a.ToolLog("writing file", filePath)
 		a.debugLog("Writing file: %s\n", filePath)
-		result, err := tools.WriteFile(filePath, content)
+		before, _ := os.ReadFile(filePath)
+		result, change, err := tools.WriteFileChange(filePath, content)
+		if err == nil {
+			a.fileWatcher.Track(filePath)
+			a.invalidateModifiedFile(filePath)
+			if change.Kind == tools.ChangeCreated {
+				a.recordFileChange(change, "Created "+filePath, nil)
+			} else {
+				a.recordFileChange(change, "Rewrote "+filePath, before)
+			}
+		}
 		a.debugLog("Write file result: %s, error: %v\n", result, err)
+		if err == nil {
+			result += a.afterEditChecks(filePath)
+			result += a.frontendVerifyHint(filePath)
+			result += a.publishInstanceEdit(filePath)
+		}
 		return result, err
 
 	case "edit_file":
-		filePath, ok := args["file_path"].(string)
-		if !ok {
-			// Try alternative parameter name for backward compatibility
-			filePath, ok = args["path"].(string)
-			if !ok {
-				return "", fmt.Errorf("invalid file_path argument")
-			}
+		filePath, oldString, newString, err := editFileArguments(args)
+		if err != nil {
+			return "", err
 		}
-		oldString, ok := args["old_string"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid old_string argument")
+		
+		if tools.IsNotebook(filePath) {
+			return "", fmt.Errorf("%s is a Jupyter notebook - use read_notebook and edit_cell to change its cells", filePath)
 		}
-		newString, ok := args["new_string"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid new_string argument")
+		if err := a.generatedFileError(filePath); err != nil {
+			return "", err
 		}
-		
+
+		// Refuse to edit based on content that changed on disk since it was read
+		defer a.fileWatcher.Lock(filePath)()
+		if err := a.editConflict(filePath); err != nil {
+			return "", err
+		}
+
 		// Read the original content for diff display
 		originalContent, err := tools.ReadFile(filePath)
 		if err != nil {
@@ -112,20 +435,96 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		}
 		
 		a.ToolLog("editing file", filePath)
+		why, _ := args["why"].(string)
+		a.ToolIntent(why)
 		a.debugLog("Editing file: %s\n", filePath)
-		result, err := tools.EditFile(filePath, oldString, newString)
+		result, change, err := tools.EditFileChange(filePath, oldString, newString)
 		
 		if err == nil {
+			a.recordFileChange(change, "Edited "+filePath, []byte(originalContent))
+			a.fileWatcher.Track(filePath)
+			a.invalidateModifiedFile(filePath)
+
 			// Read the new content and show diff
 			newContent, readErr := tools.ReadFile(filePath)
 			if readErr == nil {
 				a.ShowColoredDiff(originalContent, newContent, 50)
 			}
+			result += a.afterEditChecks(filePath)
+			result += a.frontendVerifyHint(filePath)
+			result += a.publishInstanceEdit(filePath)
 		}
 		
 		a.debugLog("Edit file result: %s, error: %v\n", result, err)
 		return result, err
 
+	case "edit_file_multi":
+		filePath, edits, err := editFileMultiArguments(args)
+		if err != nil {
+			return "", err
+		}
+		if tools.IsNotebook(filePath) {
+			return "", fmt.Errorf("%s is a Jupyter notebook - use read_notebook and edit_cell to change its cells", filePath)
+		}
+		if err := a.generatedFileError(filePath); err != nil {
+			return "", err
+		}
+
+		defer a.fileWatcher.Lock(filePath)()
+		if err := a.editConflict(filePath); err != nil {
+			return "", err
+		}
+		originalContent, err := tools.ReadFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read original file for diff: %w", err)
+		}
+
+		a.ToolLog("editing file", fmt.Sprintf("%s (%d edits)", filePath, len(edits)))
+		why, _ := args["why"].(string)
+		a.ToolIntent(why)
+		result, change, err := tools.EditFileMultiChange(filePath, edits)
+		if err != nil {
+			return "", err
+		}
+		return result + a.afterFileChange(change, fmt.Sprintf("Edited %s (%d edits)", filePath, len(edits)), originalContent), nil
+
+	case "apply_patch":
+		patches, err := tools.ParsePatch(stringArg(args, "patch", "diff"))
+		if err != nil {
+			return "", err
+		}
+		paths := changedPaths(args)
+		sort.Strings(paths)
+		for _, path := range paths {
+			if tools.IsNotebook(path) {
+				return "", fmt.Errorf("%s is a Jupyter notebook - use read_notebook and edit_cell to change its cells", path)
+			}
+			if err := a.generatedFileError(path); err != nil {
+				return "", err
+			}
+		}
+
+		// Locks are taken in path order, so concurrent patches can't deadlock
+		originals := make(map[string]string)
+		for _, path := range paths {
+			defer a.fileWatcher.Lock(path)()
+			if err := a.editConflict(path); err != nil {
+				return "", err
+			}
+			if content, err := os.ReadFile(path); err == nil {
+				originals[path] = string(content)
+			}
+		}
+
+		a.ToolLog("applying patch", strings.Join(paths, ", "))
+		why, _ := args["why"].(string)
+		a.ToolIntent(why)
+		result, changes, err := tools.ApplyPatchChanges(".", patches)
+		for _, change := range changes {
+			result += a.afterFileChange(change, "Patched "+change.Path, originals[change.Path])
+		}
+		return result, err
+
 	case "add_todo":
 		title, ok := args["title"].(string)
 		if !ok {
@@ -142,6 +541,7 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		a.ToolLog("adding todo", title)
 		a.debugLog("Adding todo: %s\n", title)
 		result := tools.AddTodo(title, description, priority)
+		a.prefetchPlan(nil, title+"\n"+description)
 		a.debugLog("Add todo result: %s\n", result)
 		return result, nil
 
@@ -256,6 +656,11 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		}
 		a.debugLog("Adding bulk todos: %d items\n", len(todos))
 		result := tools.AddBulkTodos(todos)
+		var plan strings.Builder
+		for _, todo := range todos {
+			plan.WriteString(todo.Title + "\n" + todo.Description + "\n")
+		}
+		a.prefetchPlan(nil, plan.String())
 		a.debugLog("Add bulk todos result: %s\n", result)
 		return result, nil
 
@@ -338,6 +743,7 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		if !ok {
 			return "", fmt.Errorf("invalid image_path argument")
 		}
+		refresh, _ := args["refresh"].(bool)
 		
 		// Clear any previous vision usage before the call
 		tools.ClearLastVisionUsage()
@@ -351,7 +757,7 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 			return "", fmt.Errorf("🛑 UI analysis interrupted by user")
 		}
 		// Always use empty prompt for UI screenshots to maximize caching efficiency
-		result, err := tools.AnalyzeImage(imagePath, "", "frontend")
+		result, err := tools.AnalyzeImage(imagePath, "", "frontend", refresh)
 		if err != nil {
 			return "", fmt.Errorf("UI screenshot analysis failed: %w", err)
 		}
@@ -371,11 +777,211 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		
 		return result, nil
 
+	case "compare_images":
+		actualPath, ok := args["actual_path"].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid actual_path argument")
+		}
+		expectedPath, ok := args["expected_path"].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid expected_path argument")
+		}
+		overlayPath, _ := args["overlay_path"].(string)
+		threshold, _ := args["threshold"].(float64)
+
+		a.ToolLog("comparing images", fmt.Sprintf("%s vs %s", filepath.Base(actualPath), filepath.Base(expectedPath)))
+		result, err := tools.CompareImages(actualPath, expectedPath, overlayPath, threshold)
+		if err != nil {
+			return "", fmt.Errorf("image comparison failed: %w", err)
+		}
+
+		// Vision commentary is skipped for identical images and when no vision model is set up
+		if commentary, ok := args["commentary"].(bool); (!ok || commentary) && result.ChangedPixels > 0 && tools.HasVisionCapability() {
+			if a.CheckForInterrupt() {
+				return "", fmt.Errorf("🛑 Image comparison interrupted by user")
+			}
+			tools.ClearLastVisionUsage()
+			if err := tools.CommentOnDifferences(result); err != nil {
+				a.debugLog("⚠️ Vision commentary failed: %v\n", err)
+			}
+			if visionUsage := tools.GetLastVisionUsage(); visionUsage != nil {
+				a.totalCost += visionUsage.EstimatedCost
+				a.totalTokens += visionUsage.TotalTokens
+				a.promptTokens += visionUsage.PromptTokens
+				a.completionTokens += visionUsage.CompletionTokens
+				a.debugLog("💰 Image comparison call: %s → %d tokens, $%.6f\n",
+					filepath.Base(actualPath), visionUsage.TotalTokens, visionUsage.EstimatedCost)
+			}
+		}
+
+		return result.String(), nil
+
+	case "verify_frontend":
+		var routes []string
+		if rawRoutes, ok := args["routes"].([]interface{}); ok {
+			for _, route := range rawRoutes {
+				if r, ok := route.(string); ok {
+					routes = append(routes, r)
+				}
+			}
+		}
+		focus, _ := args["focus"].(string)
+		devServer := tools.LoadDevServerConfig(a.configManager.GetConfig())
+
+		a.ToolLog("verifying frontend", fmt.Sprintf("%s %v", devServer.URL, routes))
+		if a.CheckForInterrupt() {
+			return "", fmt.Errorf("🛑 Frontend verification interrupted by user")
+		}
+		tools.ClearLastVisionUsage()
+		result, err := tools.VerifyFrontend(devServer, routes, focus)
+		if err != nil {
+			return "", fmt.Errorf("frontend verification failed: %w", err)
+		}
+		if visionUsage := tools.GetLastVisionUsage(); visionUsage != nil {
+			a.totalCost += visionUsage.EstimatedCost
+			a.totalTokens += visionUsage.TotalTokens
+			a.promptTokens += visionUsage.PromptTokens
+			a.completionTokens += visionUsage.CompletionTokens
+			a.debugLog("💰 Frontend verification: %d tokens, $%.6f\n", visionUsage.TotalTokens, visionUsage.EstimatedCost)
+		}
+		return result, nil
+
+	case "read_notebook":
+		filePath := stringArg(args, "file_path", "path")
+		if filePath == "" {
+			return "", fmt.Errorf("invalid file_path argument")
+		}
+		includeOutputs, ok := args["include_outputs"].(bool)
+		a.ToolLog("reading notebook", filePath)
+		result, err := tools.ReadNotebook(filePath, !ok || includeOutputs)
+		if err == nil {
+			a.fileWatcher.Track(filePath)
+		}
+		return result, err
+
+	case "edit_cell":
+		filePath := stringArg(args, "file_path", "path")
+		cell, ok := intArg(args, "cell")
+		if filePath == "" || !ok {
+			return "", fmt.Errorf("edit_cell needs file_path and cell")
+		}
+		defer a.fileWatcher.Lock(filePath)()
+		if err := a.editConflict(filePath); err != nil {
+			return "", err
+		}
+		action := stringArg(args, "action")
+		a.ToolLog("editing notebook", fmt.Sprintf("%s cell %d", filePath, cell))
+		a.ToolIntent(stringArg(args, "why"))
+		before, _ := os.ReadFile(filePath)
+		result, err := tools.EditCell(filePath, cell, action, stringArg(args, "source"), stringArg(args, "cell_type"))
+		if err == nil {
+			after, _ := os.ReadFile(filePath)
+			a.recordFileChange(tools.DescribeChange(filepath.Clean(filePath), before, after, true), fmt.Sprintf("Edited cell %d of %s", cell, filePath), before)
+			a.fileWatcher.Track(filePath)
+			a.invalidateModifiedFile(filePath)
+			result += a.publishInstanceEdit(filePath)
+		}
+		return result, err
+
+	case "run_cell":
+		filePath := stringArg(args, "file_path", "path")
+		cell, ok := intArg(args, "cell")
+		if filePath == "" || !ok {
+			return "", fmt.Errorf("run_cell needs file_path and cell")
+		}
+		a.ToolLog("running notebook cell", fmt.Sprintf("%s cell %d", filePath, cell))
+		if a.CheckForInterrupt() {
+			return "", fmt.Errorf("🛑 Notebook run interrupted by user")
+		}
+		result, err := tools.RunCell(filePath, cell)
+		if err == nil {
+			// The stored outputs changed
+			a.fileWatcher.Track(filePath)
+			a.invalidateModifiedFile(filePath)
+		}
+		return result, err
+
+	case "summarize_schema":
+		filePath := stringArg(args, "file_path", "path")
+		if filePath == "" {
+			return "", fmt.Errorf("invalid file_path argument")
+		}
+		a.ToolLog("summarizing schema", filePath)
+		return tools.SummarizeSchema(filePath)
+
+	case "regenerate_code":
+		a.ToolIntent(stringArg(args, "why"))
+		if a.CheckForInterrupt() {
+			return "", fmt.Errorf("🛑 Code generation interrupted by user")
+		}
+		return a.regenerateCode()
+
+	case "terraform":
+		action := stringArg(args, "action")
+		if action == "" {
+			return "", fmt.Errorf("terraform needs an action: fmt, validate, plan or apply")
+		}
+		return a.runTerraform(action, stringArg(args, "dir", "path"), stringArg(args, "why"))
+
+	case "list_targets":
+		dir := stringArg(args, "dir", "path")
+		if dir == "" {
+			dir = "."
+		}
+		a.ToolLog("listing targets", dir)
+		return tools.FormatTargets(tools.DiscoverTargets(dir)), nil
+
+	case "list_directory":
+		dir := stringArg(args, "path", "dir")
+		if dir == "" {
+			dir = "."
+		}
+		a.ToolLog("listing directory", dir)
+		return tools.ListDirectory(dir, listOptionsArg(args, tools.DefaultListDepth))
+
+	case "glob":
+		dir := stringArg(args, "path", "dir")
+		if dir == "" {
+			dir = "."
+		}
+		pattern := stringArg(args, "pattern")
+		a.ToolLog("finding files", pattern)
+		return tools.GlobFiles(dir, pattern, listOptionsArg(args, 0))
+
+	case "search_code":
+		dir := stringArg(args, "path", "dir")
+		if dir == "" {
+			dir = "."
+		}
+		pattern := stringArg(args, "pattern", "query")
+		opts := tools.SearchOptions{Include: stringListArg(args, "include"), Exclude: stringListArg(args, "exclude")}
+		opts.IgnoreCase, _ = args["ignore_case"].(bool)
+		opts.Literal, _ = args["literal"].(bool)
+		opts.MaxResults, _ = intArg(args, "max_results")
+		a.ToolLog("searching code", pattern)
+		result, err := tools.SearchCode(dir, pattern, opts)
+		if err != nil {
+			return "", err
+		}
+		a.debugLog("🔎 %d match(es) for %q via %s\n", len(result.Matches), pattern, result.Engine)
+		return tools.FormatSearchResult(dir, pattern, result), nil
+
+	case "fetch_full_result":
+		id := stringArg(args, "id", "tool_call_id")
+		a.ToolLog("fetching full result", id)
+		return a.fetchFullResult(id)
+
+	case "undo_last_change":
+		index, _ := intArg(args, "index")
+		a.ToolLog("undoing change", fmt.Sprintf("#%d", index))
+		return a.undoLastChange(index)
+
 	case "analyze_image_content":
 		imagePath, ok := args["image_path"].(string)
 		if !ok {
 			return "", fmt.Errorf("invalid image_path argument")
 		}
+		refresh, _ := args["refresh"].(bool)
 		
 		// Get optional analysis prompt
 		analysisPrompt := ""
@@ -399,7 +1005,7 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 		if a.CheckForInterrupt() {
 			return "", fmt.Errorf("🛑 Content analysis interrupted by user")
 		}
-		result, err := tools.AnalyzeImage(imagePath, analysisPrompt, "general")
+		result, err := tools.AnalyzeImage(imagePath, analysisPrompt, "general", refresh)
 		if err != nil {
 			return "", fmt.Errorf("image content analysis failed: %w", err)
 		}
@@ -424,67 +1030,6 @@ func (a *Agent) executeTool(toolCall api.ToolCall) (string, error) {
 	}
 }
 
-// extractToolCallsFromContent attempts to parse tool calls from the assistant's content or reasoning_content
-func (a *Agent) extractToolCallsFromContent(content string) []api.ToolCall {
-	var toolCalls []api.ToolCall
-
-	if content == "" {
-		return toolCalls
-	}
-
-	// Look for tool_calls JSON structure in content
-	if strings.Contains(content, "tool_calls") {
-		// Try to extract and parse tool calls from content
-		start := strings.Index(content, `{"tool_calls":`)
-		if start != -1 {
-			// Find the end of the JSON object
-			end := strings.LastIndex(content[start:], "}")
-			if end != -1 {
-				jsonStr := content[start : start+end+1]
-
-				var toolCallData struct {
-					ToolCalls []api.ToolCall `json:"tool_calls"`
-				}
-
-				if err := json.Unmarshal([]byte(jsonStr), &toolCallData); err == nil {
-					toolCalls = toolCallData.ToolCalls
-				}
-			}
-		}
-	}
-
-	// Also check for alternative formats like {"cmd": ["bash", "-lc", "ls -R"]}
-	if strings.Contains(content, `"cmd":`) {
-		// Try to parse the cmd format
-		var cmdData struct {
-			Cmd []string `json:"cmd"`
-		}
-
-		if err := json.Unmarshal([]byte(content), &cmdData); err == nil && len(cmdData.Cmd) > 0 {
-			// Convert cmd format to shell_command tool call
-			command := strings.Join(cmdData.Cmd[1:], " ") // Skip the shell (e.g., "bash")
-			if len(cmdData.Cmd) > 1 {
-				command = strings.Join(cmdData.Cmd[1:], " ")
-			}
-
-			toolCall := api.ToolCall{
-				ID:   fmt.Sprintf("call_%d", time.Now().UnixNano()),
-				Type: "function",
-				Function: struct {
-					Name      string `json:"name"`
-					Arguments string `json:"arguments"`
-				}{
-					Name:      "shell_command",
-					Arguments: fmt.Sprintf(`{"command": "%s"}`, command),
-				},
-			}
-			toolCalls = append(toolCalls, toolCall)
-		}
-	}
-
-	return toolCalls
-}
-
 // containsMalformedToolCalls checks if content contains tool call-like patterns that aren't properly formatted
 func (a *Agent) containsMalformedToolCalls(content string) bool {
 	if content == "" {
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// prefTranscriptHashing replaces paths, hostnames and usernames in the
+// transcript archive with keyed hashes, so it can be shared (off by default)
+const prefTranscriptHashing = "transcript_hashing"
+
+// redactionKeyFile holds the per-project hashing key, outside the repository
+const redactionKeyFile = "transcript.key"
+
+// identifyingPattern matches URLs, user@host addresses, paths and dotted names
+// (db.internal.corp, secrets.yaml), in that order
+var identifyingPattern = regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.-]*://[^\s/?#"'<>]+(?:/[^\s?#"'<>]*)?|[\w.+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)+|[\w.~-]*(?:/[\w.@+-]+)+/?|\b[a-z_][\w-]+(?:\.[\w-]*[a-z][\w-]*)+`)
+
+// remoteHostPattern matches the host of a remote login, which may have no dots
+// (ssh prod-db-01, ssh -p 2222 deploy@bastion)
+var remoteHostPattern = regexp.MustCompile(`(?i)\b((?:ssh|sftp|mosh|telnet|ping)\s+(?:-\w+\s+(?:\d+\s+)?)*(?:([\w.+-]+)@)?)([a-z0-9][\w.-]*[a-z0-9])\b`)
+
+// hostSuffixes are the last labels that make a dotted name a host rather than a file
+var hostSuffixes = map[string]bool{
+	"com": true, "net": true, "org": true, "io": true, "dev": true, "app": true, "cloud": true,
+	"internal": true, "corp": true, "local": true, "lan": true, "intranet": true, "home": true,
+}
+
+// transcriptRedactor hashes identifying fields with a per-project key. The same
+// value always gets the same token, so a log can still be followed.
+type transcriptRedactor struct {
+	key   []byte
+	home  string            // replaced with ~ before paths are hashed
+	names map[string]string // this machine's user and host names, and their kind
+}
+
+// transcriptRedactor returns the project's redactor, or nil when hashing is off
+func (a *Agent) transcriptRedactor() (*transcriptRedactor, error) {
+	if a.configManager == nil || !a.configManager.GetConfig().GetBoolPreference(prefTranscriptHashing, false) {
+		return nil, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := projectCacheDir(wd)
+	if err != nil {
+		return nil, err
+	}
+	key, err := loadRedactionKey(filepath.Join(dir, redactionKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	return newTranscriptRedactor(key), nil
+}
+
+// newTranscriptRedactor creates a redactor that also hides this machine's
+// user and host names wherever they appear
+func newTranscriptRedactor(key []byte) *transcriptRedactor {
+	r := &transcriptRedactor{key: key, names: make(map[string]string)}
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		r.home = home
+	}
+	if current, err := user.Current(); err == nil && len(current.Username) >= 3 {
+		r.names[current.Username] = "user"
+	}
+	if host, err := os.Hostname(); err == nil && len(host) >= 3 {
+		r.names[host] = "host"
+	}
+	return r
+}
+
+// loadRedactionKey reads the project's key, creating a random one the first time
+func loadRedactionKey(path string) ([]byte, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		if key, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil && len(key) >= 16 {
+			return key, nil
+		}
+		return nil, fmt.Errorf("invalid transcript key in %s", path)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read transcript key: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate transcript key: %w", err)
+	}
+	if err := config.WriteFileAtomic(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save transcript key: %w", err)
+	}
+	return key, nil
+}
+
+// token returns the keyed hash of a value, e.g. "host_1f0c93ab52"
+func (r *transcriptRedactor) token(kind, value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return kind + "_" + hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+// path hashes a path, keeping its extension so the kind of file stays visible
+func (r *transcriptRedactor) path(path string) string {
+	ext := filepath.Ext(path)
+	if len(ext) > 6 || strings.ContainsAny(ext, "/~") {
+		ext = ""
+	}
+	return r.token("path", strings.TrimSuffix(path, "/")) + ext
+}
+
+// text hashes the URLs, addresses, paths and local names in free text
+func (r *transcriptRedactor) text(text string) string {
+	if r.home != "" {
+		text = strings.ReplaceAll(text, r.home, "~")
+	}
+	// Longer names first, so a host name containing the user name stays whole
+	names := make([]string, 0, len(r.names))
+	for name := range r.names {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		text = replaceWord(text, name, r.token(r.names[name], name))
+	}
+	text = remoteHostPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := remoteHostPattern.FindStringSubmatch(match)
+		if strings.HasPrefix(parts[3], "host_") {
+			return match // this machine's name, already hashed
+		}
+		prefix := parts[1]
+		if parts[2] != "" {
+			prefix = strings.TrimSuffix(prefix, parts[2]+"@") + r.token("user", parts[2]) + "@"
+		}
+		return prefix + r.hostToken(parts[3])
+	})
+
+	return identifyingPattern.ReplaceAllStringFunc(text, func(match string) string {
+		switch {
+		case strings.Contains(match, "://"):
+			scheme := match[:strings.Index(match, "://")+3]
+			host, urlPath := match[len(scheme):], ""
+			if slash := strings.Index(host, "/"); slash >= 0 {
+				host, urlPath = host[:slash], host[slash:]
+				if strings.Trim(urlPath, "/") != "" {
+					urlPath = "/" + r.token("path", urlPath)
+				}
+			}
+			if at := strings.LastIndex(host, "@"); at >= 0 {
+				return scheme + r.token("user", host[:at]) + "@" + r.hostToken(host[at+1:]) + urlPath
+			}
+			return scheme + r.hostToken(host) + urlPath
+		case strings.Contains(match, "@") && !strings.Contains(match, "/"):
+			at := strings.LastIndex(match, "@")
+			return r.token("user", match[:at]) + "@" + r.hostToken(match[at+1:])
+		case !strings.Contains(match, "/") && hostSuffixes[strings.ToLower(match[strings.LastIndex(match, ".")+1:])]:
+			return r.hostToken(match)
+		default:
+			return r.path(match)
+		}
+	})
+}
+
+// hostToken hashes a host name, keeping localhost and the port readable
+func (r *transcriptRedactor) hostToken(host string) string {
+	name, port := host, ""
+	if colon := strings.LastIndex(host, ":"); colon > 0 {
+		name, port = host[:colon], host[colon:]
+	}
+	if name == "localhost" || name == "127.0.0.1" {
+		return host
+	}
+	return r.token("host", name) + port
+}
+
+// record hashes the identifying fields of a transcript record
+func (r *transcriptRedactor) record(record TranscriptRecord) TranscriptRecord {
+	record.Task = r.text(record.Task)
+	record.Result = r.text(record.Result)
+	record.Diff = r.text(record.Diff)
+	commands := make([]string, len(record.Commands))
+	for i, command := range record.Commands {
+		commands[i] = r.text(command)
+	}
+	record.Commands = commands
+	files := make([]string, len(record.Files))
+	for i, file := range record.Files {
+		files[i] = r.path(file)
+	}
+	record.Files = files
+	return record
+}
+
+// replaceWord replaces old where it isn't part of a longer word
+func replaceWord(text, old, replacement string) string {
+	pattern := regexp.MustCompile(`(^|[^\w.-])` + regexp.QuoteMeta(old) + `($|[^\w-])`)
+	for {
+		replaced := pattern.ReplaceAllString(text, "${1}"+replacement+"${2}")
+		if replaced == text {
+			return text
+		}
+		text = replaced
+	}
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+func TestTranscriptHashing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	manager, err := config.NewManager()
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	manager.GetConfig().Preferences[prefTranscriptHashing] = true
+
+	task := "Fix the redirect in internal/auth/login.go, see https://wiki.corp.example.com/auth and ask deploy@build01.corp.example.com. " +
+		"The session store is db.internal.corp, e.g. via ssh -p 2222 admin@prod-db-01, and the keys are in secrets.yaml"
+	agent := &Agent{
+		configManager: manager,
+		messages:      []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: task}},
+		timings: &TaskTimings{ToolCalls: []ToolTiming{
+			{Name: "shell_command", Detail: "go test ./internal/auth && cat " + home + "/notes.txt"},
+			{Name: "shell_command", Detail: "ssh bastion01 'psql -h db.internal.corp' < secrets.yaml"},
+			{Name: "edit_file", Detail: "internal/auth/login.go"},
+		}},
+	}
+	if err := agent.RecordTranscript("Changed internal/auth/login.go and checked it against db.internal.corp; the redirect now works."); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadTranscripts()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one record, got %d (%v)", len(records), err)
+	}
+	record := records[0]
+	logged := strings.Join([]string{record.Task, record.Result, strings.Join(record.Commands, " "), strings.Join(record.Files, " ")}, "\n")
+	for _, secret := range []string{"internal/auth", "wiki.corp", "build01", "deploy@", home, "db.internal", "prod-db-01", "admin@", "bastion01", "secrets"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("expected %q to be hashed, got:\n%s", secret, logged)
+		}
+	}
+
+	// The same path gets the same token everywhere, keeping its extension
+	token := record.Files[0]
+	if !strings.HasPrefix(token, "path_") || !strings.HasSuffix(token, ".go") || !strings.Contains(record.Task, token) || !strings.Contains(record.Result, token) {
+		t.Errorf("expected %q in the task and result, got %q and %q", token, record.Task, record.Result)
+	}
+	if !strings.Contains(record.Task, "Fix the redirect in") || !strings.Contains(record.Task, "https://host_") || !strings.Contains(record.Task, "e.g. via ssh -p 2222 user_") {
+		t.Errorf("expected the rest of the task to stay readable, got %q", record.Task)
+	}
+
+	// Bare host names are hashed as hosts, single file names as paths keeping their extension
+	redactor := newTranscriptRedactor([]byte("a test key for the transcript"))
+	for text, want := range map[string]string{
+		"db.internal.corp":  redactor.hostToken("db.internal.corp"),
+		"ssh prod-db-01":    "ssh " + redactor.hostToken("prod-db-01"),
+		"open secrets.yaml": "open " + redactor.path("secrets.yaml"),
+		"version 1.2.3":     "version 1.2.3",
+	} {
+		if got := redactor.text(text); got != want {
+			t.Errorf("expected %q to become %q, got %q", text, want, got)
+		}
+	}
+	if !strings.HasSuffix(redactor.path("secrets.yaml"), ".yaml") {
+		t.Errorf("expected the file token to keep its extension, got %q", redactor.path("secrets.yaml"))
+	}
+
+	// The key stays outside the repository, private to the user
+	dir, _ := projectCacheDir(mustGetwd(t))
+	info, err := os.Stat(filepath.Join(dir, redactionKeyFile))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a private per-project key, got %v (%v)", info, err)
+	}
+	other := newTranscriptRedactor([]byte("another project's key"))
+	if other.path("internal/auth/login.go") == token {
+		t.Error("expected another key to give other tokens")
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wd
+}
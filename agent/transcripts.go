@@ -0,0 +1,395 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/config"
+)
+
+const (
+	maxTranscriptResultChars = 2000 // final answer kept per task
+	maxTranscriptDiffChars   = 4000 // diff kept per task
+	maxRecallEmbedChars      = 2000 // text of each task that is embedded
+	recallMaxRecords         = 5    // tasks given to the model to answer from
+)
+
+// TranscriptRecord is the archived outcome of one completed task
+type TranscriptRecord struct {
+	ID        string    `json:"id"` // "<session>#<task>", cited by /recall
+	SessionID string    `json:"session_id"`
+	Time      time.Time `json:"time"`
+	Task      string    `json:"task"`
+	Result    string    `json:"result"`
+	Commands  []string  `json:"commands,omitempty"`
+	Files     []string  `json:"files,omitempty"`
+	Diff      string    `json:"diff,omitempty"`
+	Cost      float64   `json:"cost"`
+}
+
+// searchText is the text a record is matched on
+func (r *TranscriptRecord) searchText() string {
+	return strings.Join([]string{r.Task, r.Result, strings.Join(r.Commands, "\n"), strings.Join(r.Files, " "), r.Diff}, "\n")
+}
+
+// transcriptsPath returns the project's transcript archive
+func transcriptsPath() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	cacheDir, err := projectCacheDir(wd)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "transcripts.jsonl"), nil
+}
+
+// RecordTranscript archives the task that just completed so /recall can find it
+// later. The session is given an ID on its first recorded task.
+func (a *Agent) RecordTranscript(result string) error {
+	if len(a.messages) < 2 {
+		return nil
+	}
+	if a.sessionID == "" {
+		a.SetSessionID(NewSessionID())
+	}
+	a.recordedTasks++
+
+	record := TranscriptRecord{
+		ID:        fmt.Sprintf("%s#%d", a.sessionID, a.recordedTasks),
+		SessionID: a.sessionID,
+		Time:      time.Now(),
+		Task:      a.messages[1].Content,
+		Result:    truncateTranscript(result, maxTranscriptResultChars),
+		Cost:      a.lastTaskCost,
+	}
+	if a.timings != nil {
+		seen := make(map[string]bool)
+		addFile := func(path string) {
+			if path != "" && !seen[path] {
+				seen[path] = true
+				record.Files = append(record.Files, path)
+			}
+		}
+		for _, call := range a.timings.ToolCalls {
+			switch call.Name {
+			case "shell_command":
+				record.Commands = append(record.Commands, call.Detail)
+			case "write_file", "edit_file", "edit_cell", "edit_file_multi":
+				addFile(call.Detail)
+			case "apply_patch":
+				// The detail lists every file the patch changed
+				for _, path := range strings.Split(call.Detail, ",") {
+					addFile(strings.TrimSpace(path))
+				}
+			}
+		}
+	}
+	record.Diff = truncateTranscript(gitDiffOf(record.Files), maxTranscriptDiffChars)
+
+	redactor, err := a.transcriptRedactor()
+	if err != nil {
+		return err
+	}
+	if redactor != nil {
+		record = redactor.record(record)
+	}
+
+	path, err := transcriptsPath()
+	if err != nil {
+		return err
+	}
+	return appendTranscript(path, record)
+}
+
+// appendTranscript adds a record to an archive file
+func appendTranscript(path string, record TranscriptRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %w", err)
+	}
+	return config.WithFileLock(path, func() error {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open transcript archive: %w", err)
+		}
+		defer file.Close()
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		return nil
+	})
+}
+
+// gitDiffOf returns the uncommitted diff of files, or "" outside a git repository
+func gitDiffOf(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	output, err := exec.Command("git", append([]string{"diff", "--no-color", "HEAD", "--"}, files...)...).Output()
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
+
+// truncateTranscript shortens text to max characters
+func truncateTranscript(text string, max int) string {
+	if len(text) > max {
+		return text[:max] + "\n... (truncated)"
+	}
+	return text
+}
+
+// LoadTranscripts returns the project's archived tasks followed by the tasks in
+// saved session state files, oldest first
+func LoadTranscripts() ([]TranscriptRecord, error) {
+	path, err := transcriptsPath()
+	if err != nil {
+		return nil, err
+	}
+	records, err := readTranscripts(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(records, savedSessionTranscripts()...), nil
+}
+
+// readTranscripts reads an archive file, skipping corrupt lines
+func readTranscripts(path string) ([]TranscriptRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript archive: %w", err)
+	}
+	defer file.Close()
+
+	var records []TranscriptRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record TranscriptRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil && record.ID != "" {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// savedSessionTranscripts turns the conversations saved with /continuity save
+// (and each session's automatic save) into records
+func savedSessionTranscripts() []TranscriptRecord {
+	sessions, err := ListSessions()
+	if err != nil {
+		return nil
+	}
+	var records []TranscriptRecord
+	for _, name := range sessions {
+		sessionID := strings.TrimPrefix(name, "session_")
+		state, err := (&Agent{}).LoadState(sessionID)
+		if err != nil || len(state.Messages) < 2 {
+			continue
+		}
+		record := TranscriptRecord{
+			ID:        "saved:" + sessionID,
+			SessionID: sessionID,
+			Time:      state.LastUpdated,
+			Task:      state.Messages[1].Content,
+			Cost:      state.TotalCost,
+		}
+		for i := len(state.Messages) - 1; i > 1; i-- {
+			if state.Messages[i].Role == "assistant" && state.Messages[i].Content != "" {
+				record.Result = truncateTranscript(state.Messages[i].Content, maxTranscriptResultChars)
+				break
+			}
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records
+}
+
+// transcriptVectors caches the embeddings of archived tasks for one model
+type transcriptVectors struct {
+	Model   string               `json:"model"`
+	Vectors map[string][]float32 `json:"vectors"`
+}
+
+// embedTranscripts returns one vector per record, embedding only the records
+// not already in the project's vector cache
+func embedTranscripts(client api.EmbeddingsClient, records []TranscriptRecord) ([][]float32, error) {
+	path, err := transcriptsPath()
+	if err != nil {
+		return nil, err
+	}
+	path = strings.TrimSuffix(path, ".jsonl") + "_vectors.json"
+
+	cache := transcriptVectors{Model: client.Model(), Vectors: make(map[string][]float32)}
+	if data, err := os.ReadFile(path); err == nil {
+		var stored transcriptVectors
+		if json.Unmarshal(data, &stored) == nil && stored.Model == client.Model() && stored.Vectors != nil {
+			cache = stored
+		}
+	}
+
+	var missing []int
+	var texts []string
+	for i, record := range records {
+		// Saved sessions change when they are saved again, so they are never cached
+		if _, ok := cache.Vectors[record.ID]; !ok || strings.HasPrefix(record.ID, "saved:") {
+			missing = append(missing, i)
+			texts = append(texts, truncateTranscript(record.searchText(), maxRecallEmbedChars))
+		}
+	}
+	if len(texts) > 0 {
+		vectors, err := client.Embed(texts)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range missing {
+			cache.Vectors[records[i].ID] = vectors[j]
+		}
+		if data, err := json.Marshal(cache); err == nil {
+			config.WithFileLock(path, func() error { return config.WriteFileAtomic(path, data, 0600) })
+		}
+	}
+
+	vectors := make([][]float32, len(records))
+	for i, record := range records {
+		vectors[i] = cache.Vectors[record.ID]
+	}
+	return vectors, nil
+}
+
+// RecallAnswer is the answer to a question about earlier sessions
+type RecallAnswer struct {
+	Answer  string
+	Sources []TranscriptRecord // the cited tasks
+}
+
+// recallSchema is the structured answer of a recall request
+var recallSchema = api.ResponseSchema{
+	Name: "recall",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"answer": map[string]interface{}{
+				"type":        "string",
+				"description": "The answer, citing tasks inline as [id]",
+			},
+			"citations": map[string]interface{}{
+				"type":        "array",
+				"description": "IDs of the tasks the answer is based on",
+				"items":       map[string]interface{}{"type": "string"},
+			},
+		},
+		"required":             []string{"answer", "citations"},
+		"additionalProperties": false,
+	},
+}
+
+// Recall answers a question about earlier sessions from the archived tasks
+// that match it best, by full text and, when available, by embeddings
+func (a *Agent) Recall(question string) (*RecallAnswer, error) {
+	records, err := LoadTranscripts()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no stored sessions yet - tasks are archived as they complete")
+	}
+
+	chunks := make([]Chunk, len(records))
+	for i := range records {
+		chunks[i] = Chunk{Text: records[i].searchText()}
+	}
+	// Hashed records match the hashed paths and names of the question
+	matchText := question
+	if redactor, err := a.transcriptRedactor(); err == nil && redactor != nil {
+		matchText += "\n" + redactor.text(question)
+	}
+	scores, _ := lexicalReranker{}.Score(matchText, chunks)
+	best := 0.0
+	for _, score := range scores {
+		if score > best {
+			best = score
+		}
+	}
+	for i := range scores {
+		if best > 0 {
+			scores[i] /= best
+		}
+	}
+
+	if client, err := a.Embeddings(); err != nil {
+		a.debugLog("⚠️ Recall without embeddings: %v\n", err)
+	} else if vectors, err := embedTranscripts(client, records); err != nil {
+		a.debugLog("⚠️ Recall without embeddings: %v\n", err)
+	} else if query, err := client.Embed([]string{question}); err == nil {
+		for i := range scores {
+			scores[i] += api.CosineSimilarity(query[0], vectors[i])
+		}
+	}
+
+	var candidates []TranscriptRecord
+	for _, i := range rankByScore(scores) {
+		if scores[i] <= 0 || len(candidates) == recallMaxRecords {
+			break
+		}
+		candidates = append(candidates, records[i])
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("nothing in %d stored tasks matches %q", len(records), question)
+	}
+
+	var context strings.Builder
+	for _, record := range candidates {
+		fmt.Fprintf(&context, "=== [%s] %s ===\nTASK: %s\nRESULT: %s\n", record.ID, record.Time.Format("2006-01-02 15:04"),
+			truncateTranscript(record.Task, 1000), record.Result)
+		if len(record.Commands) > 0 {
+			fmt.Fprintf(&context, "COMMANDS: %s\n", strings.Join(record.Commands, "; "))
+		}
+		if len(record.Files) > 0 {
+			fmt.Fprintf(&context, "FILES CHANGED: %s\n", strings.Join(record.Files, ", "))
+		}
+		if record.Diff != "" {
+			fmt.Fprintf(&context, "DIFF:\n%s\n", record.Diff)
+		}
+	}
+
+	prompt := fmt.Sprintf(`Answer the question using only these records of earlier coding tasks. Cite the tasks you use inline as [id]. If the records don't answer it, say so. Today is %s.
+
+QUESTION: %s
+
+%s`, time.Now().Format("2006-01-02 (Monday)"), question, context.String())
+
+	var answer struct {
+		Answer    string   `json:"answer"`
+		Citations []string `json:"citations"`
+	}
+	if err := a.GenerateStructured(prompt, recallSchema, &answer); err != nil {
+		return nil, err
+	}
+
+	recall := &RecallAnswer{Answer: answer.Answer}
+	for _, record := range candidates {
+		for _, id := range answer.Citations {
+			if strings.Trim(id, "[] ") == record.ID {
+				recall.Sources = append(recall.Sources, record)
+				break
+			}
+		}
+	}
+	return recall, nil
+}
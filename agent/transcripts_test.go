@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/api"
+)
+
+func TestRecordTranscript(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	agent := &Agent{
+		messages: []api.Message{
+			{Role: "system", Content: "system"},
+			{Role: "user", Content: "Fix the login redirect"},
+		},
+		timings: &TaskTimings{ToolCalls: []ToolTiming{
+			{Name: "shell_command", Detail: "go test ./auth"},
+			{Name: "edit_file", Detail: "auth/login.go"},
+			{Name: "edit_file", Detail: "auth/login.go"},
+			{Name: "apply_patch", Detail: "auth/login.go, auth/session.go"},
+		}},
+	}
+	if err := agent.RecordTranscript("Fixed the redirect loop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.RecordTranscript("Done again"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := LoadTranscripts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	first := records[0]
+	if first.ID != agent.GetSessionID()+"#1" || records[1].ID != agent.GetSessionID()+"#2" {
+		t.Errorf("expected task IDs numbered within the session, got %q and %q", first.ID, records[1].ID)
+	}
+	if first.Task != "Fix the login redirect" || first.Result != "Fixed the redirect loop" {
+		t.Errorf("unexpected record: %+v", first)
+	}
+	if len(first.Commands) != 1 || strings.Join(first.Files, " ") != "auth/login.go auth/session.go" {
+		t.Errorf("expected one command and the deduplicated files, including the patched ones, got %v and %v", first.Commands, first.Files)
+	}
+}
+
+func TestPatchedFilesAreRecorded(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	agent, err := NewAgent()
+	if err != nil {
+		t.Skipf("Skipping test due to agent creation error: %v", err)
+	}
+	defer agent.CloseInstance()
+	agent.SetAutoApprove(true)
+
+	t.Chdir(t.TempDir())
+	os.WriteFile("a.txt", []byte("one\n"), 0644)
+	os.WriteFile("b.txt", []byte("two\n"), 0644)
+	args, _ := json.Marshal(map[string]string{"patch": "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-two\n+2\n"})
+	call := api.ToolCall{ID: "call_patch", Type: "function"}
+	call.Function.Name, call.Function.Arguments = "apply_patch", string(args)
+	agent.messages = []api.Message{{Role: "system", Content: "system"}, {Role: "user", Content: "Number the files"}}
+	agent.timings = newTaskTimings()
+	agent.executeToolCalls([]api.ToolCall{call})
+
+	if err := agent.RecordTranscript("Numbered both files"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadTranscripts()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected one record, got %d, %v", len(records), err)
+	}
+	if files := strings.Join(records[0].Files, " "); files != "a.txt b.txt" {
+		t.Errorf("expected both patched files in the record, got %q", files)
+	}
+}
+
+func TestReadTranscriptsSkipsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcripts.jsonl")
+	if err := appendTranscript(path, TranscriptRecord{ID: "s#1", Task: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString("{not json\n")
+	file.Close()
+	if err := appendTranscript(path, TranscriptRecord{ID: "s#2", Task: "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := readTranscripts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Task != "second" {
+		t.Errorf("expected the two valid records, got %+v", records)
+	}
+}
+
+func TestRecallWithoutTranscripts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	_, err := (&Agent{}).Recall("what did we do last week?")
+	if err == nil || !strings.Contains(err.Error(), "no stored sessions") {
+		t.Errorf("expected a no stored sessions error, got %v", err)
+	}
+}
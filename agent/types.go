@@ -2,6 +2,7 @@ package agent
 
 import (
 	"github.com/alantheprice/coder/api"
+	"github.com/alantheprice/coder/tools"
 )
 
 // TaskAction represents a completed action during task execution
@@ -9,6 +10,7 @@ type TaskAction struct {
 	Type        string // "file_created", "file_modified", "command_executed", "file_read"
 	Description string // Human-readable description
 	Details     string // Additional details like file path, command, etc.
+	Change      *tools.FileChange `json:",omitempty"` // What a file change did, as reported by the tool that made it
 }
 
 // ShellCommandResult tracks shell command execution for deduplication
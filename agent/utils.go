@@ -45,15 +45,36 @@ func (a *Agent) ToolLog(action, target string) {
 	}
 }
 
-// estimateContextTokens estimates the token count for messages
+// ToolIntent shows the model's stated reason for a tool call under its ToolLog line
+func (a *Agent) ToolIntent(why string) {
+	const gray = "\033[90m"
+	const reset = "\033[0m"
+
+	if why = strings.TrimSpace(why); why != "" {
+		fmt.Printf("%s   ↳ why: %s%s\n", gray, why, reset)
+	}
+}
+
+// messageTokenOverhead is what each message costs beyond its content: the
+// role and the delimiters of the chat format
+const messageTokenOverhead = 4
+
+// estimateContextTokens counts the tokens of messages with the model's tokenizer
 func (a *Agent) estimateContextTokens(messages []api.Message) int {
-	totalChars := 0
+	total := 0
 	for _, msg := range messages {
-		totalChars += len(msg.Content)
-		totalChars += len(msg.ReasoningContent)
+		total += messageTokenOverhead + a.countTokens(msg.Content) + a.countTokens(msg.ReasoningContent)
+	}
+	return total
+}
+
+// countTokens counts the tokens of text for the current model
+func (a *Agent) countTokens(text string) int {
+	model := ""
+	if a.client != nil {
+		model = a.client.GetModel()
 	}
-	// Rough estimate: 4 chars per token (conservative)
-	return totalChars / 4
+	return api.CountTokens(model, text)
 }
 
 // formatTokenCount formats token count with thousands separators
@@ -115,6 +136,22 @@ func (a *Agent) getProviderEnvVar(provider api.ClientType) string {
 		return "GROQ_API_KEY"
 	case api.DeepSeekClientType:
 		return "DEEPSEEK_API_KEY"
+	case api.AzureOpenAIClientType:
+		return "AZURE_OPENAI_API_KEY"
+	case api.BedrockClientType:
+		return "AWS_ACCESS_KEY_ID"
+	case api.MistralClientType:
+		return "MISTRAL_API_KEY"
+	case api.XAIClientType:
+		return "XAI_API_KEY"
+	case api.HuggingFaceClientType:
+		return "HF_ENDPOINT_URL"
+	case api.AnthropicClientType:
+		return "ANTHROPIC_API_KEY"
+	case api.OpenAIClientType:
+		return "OPENAI_API_KEY"
+	case api.GeminiClientType:
+		return "GEMINI_API_KEY"
 	case api.OllamaClientType:
 		return "" // Ollama doesn't use an API key
 	default:
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alantheprice/coder/tools"
+)
+
+func TestTranscribeRemote(t *testing.T) {
+	audioPath := filepath.Join(t.TempDir(), "speech.wav")
+	os.WriteFile(audioPath, []byte("RIFF fake audio"), 0644)
+	t.Setenv("GROQ_API_KEY", "test-key")
+
+	reply := `{"text": "  add a readme file \n"}`
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		if r.FormValue("model") != "whisper-large-v3-turbo" || r.FormValue("response_format") != "json" {
+			t.Errorf("unexpected form fields model=%q response_format=%q", r.FormValue("model"), r.FormValue("response_format"))
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("expected the recording as the file field: %v", err)
+		} else {
+			audio, _ := io.ReadAll(file)
+			if header.Filename != "speech.wav" || string(audio) != "RIFF fake audio" {
+				t.Errorf("unexpected upload %s: %q", header.Filename, audio)
+			}
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	defer server.Close()
+
+	cfg := tools.LoadVoiceConfig(nil)
+	cfg.Provider, cfg.URL, cfg.Model = tools.STTGroq, server.URL, "whisper-large-v3-turbo"
+	text, err := tools.Transcribe(audioPath, cfg)
+	if err != nil || text != "add a readme file" {
+		t.Errorf("expected the trimmed transcription, got %q, %v", text, err)
+	}
+
+	reply = `not json`
+	if _, err := tools.Transcribe(audioPath, cfg); err == nil || !strings.Contains(err.Error(), "failed to parse transcription") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+
+	status, reply = http.StatusUnauthorized, `{"error": {"message": "invalid key"}}`
+	if _, err := tools.Transcribe(audioPath, cfg); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("expected the endpoint's error, got %v", err)
+	}
+}
+
+func TestTranscribeRemoteNeedsAKey(t *testing.T) {
+	// No key in the environment, and no secret-tool on PATH for the keyring
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("PATH", t.TempDir())
+	audioPath := filepath.Join(t.TempDir(), "speech.wav")
+	os.WriteFile(audioPath, []byte("RIFF fake audio"), 0644)
+
+	_, err := tools.Transcribe(audioPath, tools.LoadVoiceConfig(nil))
+	if err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY is not set") {
+		t.Errorf("expected a missing key error, got %v", err)
+	}
+}
+
+func TestRecordSpeechWithoutARecorder(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	before, _ := filepath.Glob(filepath.Join(os.TempDir(), "coder-voice-*.wav"))
+
+	_, err := tools.RecordSpeech(tools.LoadVoiceConfig(nil))
+	if err == nil || !strings.Contains(err.Error(), "no audio recorder found") {
+		t.Errorf("expected a no-recorder error, got %v", err)
+	}
+	// The empty recording file is removed
+	if after, _ := filepath.Glob(filepath.Join(os.TempDir(), "coder-voice-*.wav")); len(after) > len(before) {
+		t.Errorf("expected the recording file to be removed, found %v", after)
+	}
+}
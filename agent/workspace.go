@@ -0,0 +1,342 @@
+package agent
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSnapshotFileSize skips hashing very large files (they are tracked by size and mtime)
+const maxSnapshotFileSize = 10 * 1024 * 1024
+
+// snapshotSkipDirs are directories never included in a workspace snapshot
+var snapshotSkipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+	"__pycache__":  true,
+	"dist":         true,
+	"build":        true,
+}
+
+// racyWindow is how close to the snapshot a file may have been modified and
+// still be rewritten later with the same size and modification time; such
+// files are hashed when the snapshot is taken, like git's racy-clean check
+const racyWindow = 2 * time.Second
+
+// fileStamp is the state of a file in a snapshot. A file git reports as
+// unmodified keeps git's blob id, so its content is known without reading it;
+// other files are hashed unless the file index already has their hash.
+type fileStamp struct {
+	Size    int64
+	ModTime int64
+	Hash    string
+	Blob    string // git blob id of the content, for clean tracked files
+}
+
+// WorkspaceSnapshot records the state of each file in the workspace. Taking
+// it stats files and reads only those git doesn't already know; content is
+// compared later just for files whose size or modification time changed.
+type WorkspaceSnapshot struct {
+	Root       string
+	CapturedAt time.Time
+	Files      map[string]fileStamp // relative path -> state
+	index      *FileIndex
+}
+
+// WorkspaceChange describes a file that differs from the snapshot
+type WorkspaceChange struct {
+	Path     string
+	Status   string // "added", "modified" or "deleted"
+	ViaTools bool   // true if the change was made through write_file/edit_file
+}
+
+// CaptureWorkspaceSnapshot records every tracked (or, outside git, every
+// visible) file under root, reusing hashes from the project's file index
+func CaptureWorkspaceSnapshot(root string) (*WorkspaceSnapshot, error) {
+	index, err := OpenFileIndex(root)
+	if err != nil {
+		index = openFileIndexAt("") // not persisted, but still saves rehashing within the session
+	}
+	return captureWorkspaceSnapshot(root, index)
+}
+
+// captureWorkspaceSnapshot records the workspace using the given index
+func captureWorkspaceSnapshot(root string, index *FileIndex) (*WorkspaceSnapshot, error) {
+	files, err := listWorkspaceFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := cleanGitBlobs(root)
+	snapshot := &WorkspaceSnapshot{
+		Root:       root,
+		CapturedAt: time.Now(),
+		Files:      make(map[string]fileStamp, len(files)),
+		index:      index,
+	}
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(root, rel))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		stamp := fileStamp{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		if hash, ok := index.Cached(rel, info); ok {
+			stamp.Hash = hash
+		} else if blob, clean := blobs[rel]; clean && snapshot.CapturedAt.Sub(info.ModTime()) >= racyWindow {
+			stamp.Blob = blob
+		} else {
+			stamp.Hash, _ = index.Hash(root, rel, info)
+		}
+		snapshot.Files[rel] = stamp
+	}
+
+	// Forget files that no longer exist so the index doesn't grow forever
+	for rel := range index.Files {
+		if _, ok := snapshot.Files[rel]; !ok {
+			delete(index.Files, rel)
+			index.dirty = true
+		}
+	}
+	if err := index.Save(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Diff compares the snapshot with the current state of the workspace. Files
+// whose size and modification time are unchanged are not read.
+func (s *WorkspaceSnapshot) Diff() ([]WorkspaceChange, error) {
+	files, err := listWorkspaceFiles(s.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []WorkspaceChange
+	current := make(map[string]bool, len(files))
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(s.Root, rel))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		current[rel] = true
+
+		old, existed := s.Files[rel]
+		switch {
+		case !existed:
+			changes = append(changes, WorkspaceChange{Path: rel, Status: "added"})
+		case old.Size == info.Size() && old.ModTime == info.ModTime().UnixNano():
+			// unchanged; racy files were hashed at capture and are checked below
+			if old.Hash != "" && s.CapturedAt.Sub(info.ModTime()) < racyWindow {
+				// the index can't tell these apart by stat, so the file is read again
+				if hash, err := hashWorkspaceFile(filepath.Join(s.Root, rel)); err == nil && hash != old.Hash {
+					changes = append(changes, WorkspaceChange{Path: rel, Status: "modified"})
+				}
+			}
+		case old.Hash != "":
+			// Stat changed: compare content, since a touch or revert keeps it equal
+			if hash, err := s.index.Hash(s.Root, rel, info); err != nil || hash != old.Hash {
+				changes = append(changes, WorkspaceChange{Path: rel, Status: "modified"})
+			}
+		case old.Blob != "":
+			if blob, err := gitBlobID(filepath.Join(s.Root, rel), len(old.Blob)); err != nil || blob != old.Blob {
+				changes = append(changes, WorkspaceChange{Path: rel, Status: "modified"})
+			}
+		default:
+			// The file couldn't be read when the snapshot was taken
+			changes = append(changes, WorkspaceChange{Path: rel, Status: "modified"})
+		}
+	}
+	for rel := range s.Files {
+		if !current[rel] {
+			changes = append(changes, WorkspaceChange{Path: rel, Status: "deleted"})
+		}
+	}
+	if err := s.index.Save(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// listWorkspaceFiles lists tracked and untracked-but-not-ignored files via git,
+// falling back to a directory walk outside of git repositories
+func listWorkspaceFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = root
+	if output, err := cmd.Output(); err == nil {
+		var files []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files = append(files, line)
+			}
+		}
+		return files, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (snapshotSkipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// hashWorkspaceFile returns a content hash, or a size/mtime fingerprint for very large files
+func hashWorkspaceFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxSnapshotFileSize {
+		return fmt.Sprintf("size:%d:mtime:%d", info.Size(), info.ModTime().UnixNano()), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// cleanGitBlobs returns the blob id of each tracked file whose content matches
+// git's index, from "git ls-files -s" less the files "git diff-files" reports
+// as changed. Outside git it returns nil.
+func cleanGitBlobs(root string) map[string]string {
+	staged := exec.Command("git", "ls-files", "-s", "-z")
+	staged.Dir = root
+	output, err := staged.Output()
+	if err != nil {
+		return nil
+	}
+	changed := exec.Command("git", "diff-files", "--name-only", "-z")
+	changed.Dir = root
+	changedOutput, err := changed.Output()
+	if err != nil {
+		return nil
+	}
+	dirty := make(map[string]bool)
+	for _, path := range strings.Split(string(changedOutput), "\x00") {
+		dirty[path] = true
+	}
+
+	blobs := make(map[string]string)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		// "<mode> <blob> <stage>\t<path>"
+		meta, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[2] != "0" || dirty[path] {
+			continue
+		}
+		blobs[filepath.FromSlash(path)] = fields[1]
+	}
+	return blobs
+}
+
+// gitBlobID computes the id git gives a file's content, with SHA-1 or, for
+// repositories using 64-character ids, SHA-256
+func gitBlobID(path string, idLen int) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	hasher := sha1.New()
+	if idLen == 2*sha256.Size {
+		hasher = sha256.New()
+	}
+	fmt.Fprintf(hasher, "blob %d\x00", len(data))
+	hasher.Write(data)
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// captureWorkspaceSnapshotOnce takes the session-start snapshot on the first task
+func (a *Agent) captureWorkspaceSnapshotOnce() {
+	if a.workspaceSnapshot != nil {
+		return
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+
+	snapshot, err := CaptureWorkspaceSnapshot(wd)
+	if err != nil {
+		a.debugLog("⚠️ Failed to capture workspace snapshot: %v\n", err)
+		return
+	}
+	a.workspaceSnapshot = snapshot
+	a.debugLog("📸 Workspace snapshot captured: %d files\n", len(snapshot.Files))
+}
+
+// GetWorkspaceChanges lists files that changed since the session started
+func (a *Agent) GetWorkspaceChanges() ([]WorkspaceChange, error) {
+	if a.workspaceSnapshot == nil {
+		return nil, nil
+	}
+
+	changes, err := a.workspaceSnapshot.Diff()
+	if err != nil {
+		return nil, err
+	}
+
+	toolPaths := make(map[string]bool)
+	for _, action := range a.taskActions {
+		if action.Type != "file_created" && action.Type != "file_modified" {
+			continue
+		}
+		path := action.Details
+		if filepath.IsAbs(path) {
+			if rel, err := filepath.Rel(a.workspaceSnapshot.Root, path); err == nil {
+				path = rel
+			}
+		}
+		toolPaths[filepath.Clean(path)] = true
+	}
+	for i := range changes {
+		changes[i].ViaTools = toolPaths[filepath.Clean(changes[i].Path)]
+	}
+	return changes, nil
+}
+
+// GetWorkspaceSnapshotTime returns when the session-start snapshot was taken
+func (a *Agent) GetWorkspaceSnapshotTime() time.Time {
+	if a.workspaceSnapshot == nil {
+		return time.Time{}
+	}
+	return a.workspaceSnapshot.CapturedAt
+}
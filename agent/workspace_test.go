@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWorkspaceSnapshotDiff tests detection of added, modified and deleted files
+func TestWorkspaceSnapshotDiff(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	write("keep.txt", "same")
+	write("change.txt", "before")
+	write("remove.txt", "gone soon")
+
+	snapshot, err := CaptureWorkspaceSnapshot(root)
+	if err != nil {
+		t.Fatalf("Failed to capture snapshot: %v", err)
+	}
+
+	write("change.txt", "after")
+	write("new.txt", "hello")
+	os.Remove(filepath.Join(root, "remove.txt"))
+
+	changes, err := snapshot.Diff()
+	if err != nil {
+		t.Fatalf("Failed to diff snapshot: %v", err)
+	}
+
+	expected := map[string]string{
+		"change.txt": "modified",
+		"new.txt":    "added",
+		"remove.txt": "deleted",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for _, change := range changes {
+		if expected[change.Path] != change.Status {
+			t.Errorf("Expected %s to be %s, got %s", change.Path, expected[change.Path], change.Status)
+		}
+	}
+}
+
+func TestWorkspaceSnapshotIgnoresUnchangedContent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	// Files written well before the snapshot, so their stat info isn't racy
+	past := time.Now().Add(-time.Hour)
+	for name, content := range map[string]string{"touched.txt": "same", "edited.txt": "before", "dirty.txt": "staged", "untracked.txt": "local"} {
+		write(name, content)
+		os.Chtimes(filepath.Join(root, name), past, past)
+	}
+	git("init", "-q")
+	git("add", "touched.txt", "edited.txt", "dirty.txt")
+	git("commit", "-q", "-m", "initial")
+	write("dirty.txt", "uncommitted")
+	os.Chtimes(filepath.Join(root, "dirty.txt"), past, past)
+
+	snapshot, err := CaptureWorkspaceSnapshot(root)
+	if err != nil {
+		t.Fatalf("Failed to capture snapshot: %v", err)
+	}
+	if stamp := snapshot.Files["touched.txt"]; stamp.Blob == "" || stamp.Hash != "" {
+		t.Errorf("expected a clean tracked file to keep git's blob id without being read, got %+v", stamp)
+	}
+
+	// A touch, a reverted edit and a rewrite with the same content are not changes
+	now := time.Now()
+	os.Chtimes(filepath.Join(root, "touched.txt"), now, now)
+	write("dirty.txt", "reverted soon")
+	write("dirty.txt", "uncommitted")
+	write("untracked.txt", "local")
+	write("edited.txt", "after")
+
+	changes, err := snapshot.Diff()
+	if err != nil {
+		t.Fatalf("Failed to diff snapshot: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "edited.txt" || changes[0].Status != "modified" {
+		t.Errorf("expected only edited.txt to be modified, got %+v", changes)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrBalanceUnavailable means the provider has no billing endpoint to query
+var ErrBalanceUnavailable = errors.New("provider does not report a balance")
+
+// Balance is the remaining credit reported by a provider's billing API
+type Balance struct {
+	Provider  string
+	Remaining float64 // USD left to spend
+	Used      float64 // USD spent so far, when reported
+	FetchedAt time.Time
+}
+
+// SecretLookup finds an API key by keyring account, with the environment
+// variable taking precedence (see config.GetSecret)
+type SecretLookup func(account, envVar string) (string, error)
+
+// GetBalance fetches the remaining credit for a provider, with the API key
+// secret finds. OpenRouter reports prepaid credits; DeepInfra reports the
+// account's prepaid balance.
+func GetBalance(clientType ClientType, secret SecretLookup) (*Balance, error) {
+	if os.Getenv(ReplayEnv) != "" {
+		return nil, ErrBalanceUnavailable // replayed sessions make no requests
+	}
+	switch clientType {
+	case OpenRouterClientType:
+		return getOpenRouterBalance(secret)
+	case DeepInfraClientType:
+		return getDeepInfraBalance(secret)
+	}
+	return nil, ErrBalanceUnavailable
+}
+
+// getOpenRouterBalance reads /credits, which reports total purchased credits
+// and usage. OPENROUTER_BASE_URL overrides the API root, as it does for chat.
+func getOpenRouterBalance(secret SecretLookup) (*Balance, error) {
+	apiKey, err := secret("openrouter", "OPENROUTER_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	baseURL := strings.TrimRight(os.Getenv("OPENROUTER_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "https://openrouter.ai/api/v1"
+	}
+	var response struct {
+		Data struct {
+			TotalCredits float64 `json:"total_credits"`
+			TotalUsage   float64 `json:"total_usage"`
+		} `json:"data"`
+	}
+	if err := getBillingJSON(baseURL+"/credits", apiKey, &response); err != nil {
+		return nil, err
+	}
+	return &Balance{
+		Provider:  "openrouter",
+		Remaining: response.Data.TotalCredits - response.Data.TotalUsage,
+		Used:      response.Data.TotalUsage,
+		FetchedAt: time.Now(),
+	}, nil
+}
+
+// getDeepInfraBalance reads the payment checklist, whose stripe_balance is
+// negative while the account holds prepaid credit and positive on postpaid
+// accounts that owe for usage, which leaves Remaining at or below zero
+func getDeepInfraBalance(secret SecretLookup) (*Balance, error) {
+	apiKey, err := secret("deepinfra", "DEEPINFRA_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		StripeBalance *float64 `json:"stripe_balance"`
+	}
+	if err := getBillingJSON("https://api.deepinfra.com/payment/checklist", apiKey, &response); err != nil {
+		return nil, err
+	}
+	if response.StripeBalance == nil {
+		return nil, ErrBalanceUnavailable
+	}
+	return &Balance{
+		Provider:  "deepinfra",
+		Remaining: -*response.StripeBalance,
+		FetchedAt: time.Now(),
+	}, nil
+}
+
+// getBillingJSON performs an authenticated GET and decodes the JSON response
+func getBillingJSON(url, apiKey string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch balance: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("billing API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse balance: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,9 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/alantheprice/coder/providers"
+	"github.com/alantheprice/coder/types"
 )
 
 const (
@@ -41,6 +45,7 @@ type Message struct {
 	Content          string      `json:"content"`
 	ReasoningContent string      `json:"reasoning_content,omitempty"`
 	Images           []ImageData `json:"images,omitempty"` // Support for multiple images
+	ToolCallID       string      `json:"-"`                // The tool call a tool result answers; local only, never sent
 }
 
 type ToolCall struct {
@@ -98,6 +103,11 @@ type ChatRequest struct {
 	ToolChoice string    `json:"tool_choice,omitempty"`
 	MaxTokens  int       `json:"max_tokens,omitempty"`
 	Reasoning  string    `json:"reasoning,omitempty"`
+
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+
+	// Parameters is the per-model profile merged into the request body
+	Parameters map[string]interface{} `json:"-"`
 }
 
 type Client struct {
@@ -132,7 +142,7 @@ func NewClientWithModel(model string) (*Client, error) {
 	}, nil
 }
 
-func (c *Client) SendChatRequest(req ChatRequest) (*ChatResponse, error) {
+func (c *Client) SendChatRequest(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	var finalReq ChatRequest
 	
 	// Use harmony format only for GPT-OSS models
@@ -162,6 +172,8 @@ func (c *Client) SendChatRequest(req ChatRequest) (*ChatResponse, error) {
 			Messages:  []Message{{Role: "user", Content: harmonyText}},
 			MaxTokens: req.MaxTokens,
 			Reasoning: req.Reasoning,
+
+			Parameters: req.Parameters,
 			// Note: Don't include Tools in harmony format - they're embedded in the text
 		}
 	} else {
@@ -169,12 +181,12 @@ func (c *Client) SendChatRequest(req ChatRequest) (*ChatResponse, error) {
 		finalReq = req
 	}
 
-	reqBody, err := json.Marshal(finalReq)
+	reqBody, err := marshalWithParameters(finalReq, finalReq.Parameters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", DeepInfraURL, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", DeepInfraURL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -224,6 +236,48 @@ func (c *Client) SendChatRequest(req ChatRequest) (*ChatResponse, error) {
 	return &chatResp, nil
 }
 
+// SendChatRequestStream sends a standard (non-harmony) request as a stream,
+// passing content to onToken as it arrives
+func (c *Client) SendChatRequestStream(ctx context.Context, req ChatRequest, onToken StreamCallback) (*ChatResponse, error) {
+	params := map[string]interface{}{"stream": true}
+	for key, value := range req.Parameters {
+		params[key] = value
+	}
+	reqBody, err := marshalWithParameters(req, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", DeepInfraURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiToken)
+
+	if c.debug {
+		log.Printf("DeepInfra Stream Request Body: %s", string(reqBody))
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	streamed, err := providers.ReadChatStream(resp.Body, onToken)
+	if err != nil {
+		return nil, err
+	}
+	return fromProviderResponse(streamed), nil
+}
+
 func (c *Client) GetModel() string {
 	return c.model
 }
@@ -251,8 +305,12 @@ func GetToolDefinitions() []Tool {
 							"type":        "string",
 							"description": "Shell command to execute",
 						},
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence on why this command is needed, shown to the user",
+						},
 					},
-					"required": []string{"command"},
+					"required": []string{"command", "why"},
 				},
 			},
 		},
@@ -264,7 +322,7 @@ func GetToolDefinitions() []Tool {
 				Parameters  interface{} `json:"parameters"`
 			}{
 				Name:        "read_file",
-				Description: "Read contents of a specific file",
+				Description: "Read contents of a specific file. Very large files may be returned compressed; pass full=true for the exact content before editing. Pass offset and/or limit to read a line range of any size of file, with line numbers; the result gives the total line count and the offset to continue from",
 				Parameters: map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
@@ -272,6 +330,18 @@ func GetToolDefinitions() []Tool {
 							"type":        "string",
 							"description": "Path to file to read",
 						},
+						"full": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Return the exact, uncompressed file content",
+						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "Line to start reading from (1-based)",
+						},
+						"limit": map[string]interface{}{
+							"type":        "integer",
+							"description": "Number of lines to read (default 400)",
+						},
 					},
 					"required": []string{"file_path"},
 				},
@@ -301,8 +371,74 @@ func GetToolDefinitions() []Tool {
 							"type":        "string",
 							"description": "New string to replace with",
 						},
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence on why this edit is needed, shown to the user",
+						},
 					},
-					"required": []string{"file_path", "old_string", "new_string"},
+					"required": []string{"file_path", "old_string", "new_string", "why"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "edit_file_multi",
+				Description: "Make several replacements in one file at once. Edits apply in order, each to the result of the ones before; every old_string must appear exactly once. If any edit fails, none is applied.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to file to edit",
+						},
+						"edits": map[string]interface{}{
+							"type":        "array",
+							"description": "Replacements to make, in order",
+							"items": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"old_string": map[string]interface{}{"type": "string", "description": "Exact string to replace"},
+									"new_string": map[string]interface{}{"type": "string", "description": "New string to replace with"},
+								},
+								"required": []string{"old_string", "new_string"},
+							},
+						},
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence on why these edits are needed, shown to the user",
+						},
+					},
+					"required": []string{"file_path", "edits", "why"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "apply_patch",
+				Description: "Apply a unified diff (git diff format: ---/+++ headers and @@ hunks with context lines) to one or more files. Hunks are located by their context, so line numbers may be off. Use /dev/null to create or delete files. If any hunk doesn't apply, no file is changed.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"patch": map[string]interface{}{
+							"type":        "string",
+							"description": "The unified diff, with paths relative to the current directory",
+						},
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence on why this change is needed, shown to the user",
+						},
+					},
+					"required": []string{"patch", "why"},
 				},
 			},
 		},
@@ -493,6 +629,10 @@ func GetToolDefinitions() []Tool {
 							"type":        "string",
 							"description": "Path to UI screenshot, mockup, or design file",
 						},
+						"refresh": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Re-analyze even if this exact image was analyzed before (results are cached by image content)",
+						},
 					},
 					"required": []string{"image_path"},
 				},
@@ -518,10 +658,423 @@ func GetToolDefinitions() []Tool {
 							"type":        "string",
 							"description": "Optional specific prompt for content extraction (extract text, read code, analyze diagram, etc.)",
 						},
+						"refresh": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Re-analyze even if this exact image was analyzed before (results are cached by image content)",
+						},
 					},
 					"required": []string{"image_path"},
 				},
 			},
 		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "compare_images",
+				Description: "Compare a fresh screenshot against a target image (e.g. a mockup) to verify UI work. Reports the percentage of changed pixels, a perceptual similarity score and the changed regions, writes an overlay PNG with differences in red, and adds vision-model commentary on what differs.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"actual_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the current screenshot",
+						},
+						"expected_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the target image or mockup",
+						},
+						"overlay_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Where to write the diff overlay PNG (default: <actual>_diff.png)",
+						},
+						"threshold": map[string]interface{}{
+							"type":        "number",
+							"description": "Per-pixel color distance from 0 to 1 above which a pixel counts as changed (default 0.1)",
+						},
+						"commentary": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Ask the vision model to describe the differences (default true)",
+						},
+					},
+					"required": []string{"actual_path", "expected_path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "verify_frontend",
+				Description: "Check the rendered UI after frontend edits: starts the configured dev server in the background if it is not running, screenshots the given routes with a headless browser and analyzes each screenshot with the vision model. Returns the screenshot paths, which can be passed to compare_images.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"routes": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "Routes to screenshot, e.g. [\"/\", \"/settings\"] (default: configured dev_server_routes)",
+						},
+						"focus": map[string]interface{}{
+							"type":        "string",
+							"description": "Optional aspect to check closely, e.g. the element you just changed",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "read_notebook",
+				Description: "Read a Jupyter notebook (.ipynb) as numbered cells with their text outputs, instead of its raw JSON. Use the cell numbers with edit_cell and run_cell.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the notebook",
+						},
+						"include_outputs": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Show the stored outputs of code cells (default true)",
+						},
+					},
+					"required": []string{"file_path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "edit_cell",
+				Description: "Change one cell of a Jupyter notebook: replace its source, insert a new cell or delete it. Keeps the notebook's JSON valid; never use edit_file or write_file on an existing .ipynb.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the notebook",
+						},
+						"cell": map[string]interface{}{
+							"type":        "integer",
+							"description": "Cell number from read_notebook; for insert, the new cell's position (the cell count appends)",
+						},
+						"action": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"replace", "insert", "delete"},
+							"description": "What to do with the cell (default replace)",
+						},
+						"source": map[string]interface{}{
+							"type":        "string",
+							"description": "The cell's full new source, for replace and insert",
+						},
+						"cell_type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"code", "markdown", "raw"},
+							"description": "Cell type for insert (default code), or to convert a cell on replace",
+						},
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence of intent shown to the user",
+						},
+					},
+					"required": []string{"file_path", "cell"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "run_cell",
+				Description: "Run a code cell of a Jupyter notebook with its kernel (requires jupyter) and store its outputs. The cells before it run first so it sees their state.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the notebook",
+						},
+						"cell": map[string]interface{}{
+							"type":        "integer",
+							"description": "Number of the code cell to run",
+						},
+					},
+					"required": []string{"file_path", "cell"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "summarize_schema",
+				Description: "Summarize a .proto file (services, RPCs, messages, enums with field numbers) or an OpenAPI/Swagger spec (endpoints with operation IDs, schemas). Use it to understand an API before changing it.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"file_path": map[string]interface{}{
+							"type":        "string",
+							"description": "Path to the .proto file or OpenAPI spec (.yaml, .yml or .json)",
+						},
+					},
+					"required": []string{"file_path"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "regenerate_code",
+				Description: "Regenerate code from the project's schemas (buf generate, openapi-generator or the configured codegen_command), list the files that changed and verify the build. Run it after editing a .proto or OpenAPI spec; generated files can't be edited directly.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence of intent shown to the user",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "terraform",
+				Description: "Verify infrastructure code with Terraform/OpenTofu: fmt formats the .tf files, validate reports errors with file and line, plan summarizes the resources it would add, change, replace and destroy, apply applies the last plan of the directory. Run fmt, validate and plan after editing .tf files; never run terraform apply or destroy with shell_command.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"action": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"fmt", "validate", "plan", "apply"},
+							"description": "Step to run; apply needs an earlier plan, and plans that destroy resources need the user's approval",
+						},
+						"dir": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory of the Terraform configuration (default: current directory)",
+						},
+						"why": map[string]interface{}{
+							"type":        "string",
+							"description": "One short sentence of intent shown to the user",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "list_targets",
+				Description: "List the project's Makefile targets, Taskfile tasks, package.json scripts and mage targets with their descriptions and the exact command to run each. Use it instead of reading those files to find how to build, test, lint or run the project.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"dir": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory to look in (default: current directory)",
+						},
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "list_directory",
+				Description: "List the files under a directory as an indented tree with sizes, directories first. Files git ignores are left out. Directories below max_depth show their file count. Prefer it over ls, find and tree.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory to list (default: current directory)",
+						},
+						"max_depth": map[string]interface{}{
+							"type":        "integer",
+							"description": "Directory levels to show (default 2, 0 = unlimited)",
+						},
+						"include": listPatternsSchema("Only list files matching one of these patterns, e.g. [\"*.go\"]"),
+						"exclude": listPatternsSchema("Leave out files and directories matching these patterns, e.g. [\"testdata\", \"*.pb.go\"]"),
+					},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "glob",
+				Description: "Find files by pattern, e.g. \"**/*_test.go\" or \"cmd/*/main.go\". Patterns without a slash match file names at any depth. Files git ignores are left out. Returns matching paths with sizes, sorted.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"pattern": map[string]interface{}{
+							"type":        "string",
+							"description": "Glob pattern; ** matches any number of directories",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory to search (default: current directory)",
+						},
+						"max_depth": map[string]interface{}{
+							"type":        "integer",
+							"description": "Directory levels to search (default 0 = unlimited)",
+						},
+						"exclude": listPatternsSchema("Leave out files and directories matching these patterns"),
+					},
+					"required": []string{"pattern"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "search_code",
+				Description: "Search file contents for a regular expression, using ripgrep when installed. Returns matching lines grouped by file with line numbers. Files git ignores, binary files and files over 1 MB are skipped. Prefer it over grep and rg shell commands.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"pattern": map[string]interface{}{
+							"type":        "string",
+							"description": "Regular expression (RE2 syntax), or plain text with literal set",
+						},
+						"path": map[string]interface{}{
+							"type":        "string",
+							"description": "Directory or file to search (default: current directory)",
+						},
+						"ignore_case": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Match case-insensitively",
+						},
+						"literal": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Treat the pattern as plain text instead of a regular expression",
+						},
+						"include": listPatternsSchema("Only search files matching one of these patterns, e.g. [\"*.go\"]"),
+						"exclude": listPatternsSchema("Skip files and directories matching these patterns, e.g. [\"*_test.go\", \"vendor\"]"),
+						"max_results": map[string]interface{}{
+							"type":        "integer",
+							"description": "Matches to return before stopping (default 100)",
+						},
+					},
+					"required": []string{"pattern"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "fetch_full_result",
+				Description: "Get the full text of a tool result that was replaced by a [SUMMARY ...]. Use it before editing or quoting content you only have a summary of.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{
+							"type":        "string",
+							"description": "The id given in the summary note",
+						},
+					},
+					"required": []string{"id"},
+				},
+			},
+		},
+		{
+			Type: "function",
+			Function: struct {
+				Name        string      `json:"name"`
+				Description string      `json:"description"`
+				Parameters  interface{} `json:"parameters"`
+			}{
+				Name:        "undo_last_change",
+				Description: "Revert a file change made with write_file, edit_file or edit_cell, restoring the file's earlier content (or removing a file the change created). Use it when an edit went wrong. Changes to the same file are undone newest first.",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"index": map[string]interface{}{
+							"type":        "integer",
+							"description": "Journal index of the change to undo; omit to undo the most recent change",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// listPatternsSchema describes a list of file patterns for the listing tools
+func listPatternsSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": description,
+	}
+}
+
+// marshalWithParameters encodes a request and merges a parameter profile into it
+func marshalWithParameters(req interface{}, params map[string]interface{}) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil || len(params) == 0 {
+		return body, err
+	}
+	var merged map[string]interface{}
+	if err := json.Unmarshal(body, &merged); err != nil {
+		return nil, err
+	}
+	// JSON numbers decode as float64; restore max_tokens so the merge can compare it
+	if maxTokens, ok := merged["max_tokens"].(float64); ok {
+		merged["max_tokens"] = int(maxTokens)
 	}
+	types.MergeRequestParameters(merged, params)
+	return json.Marshal(merged)
 }
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ProviderCheck is the outcome of checking that a provider answers
+type ProviderCheck struct {
+	Provider ClientType
+	Models   int           // Models the provider listed
+	Latency  time.Duration // How long the listing took
+	Err      error
+}
+
+// CheckProviders lists each provider's models concurrently, which shows that
+// its endpoint is reachable and its credentials are accepted. A provider that
+// doesn't answer within timeout fails the check.
+func CheckProviders(providers []ClientType, timeout time.Duration) []ProviderCheck {
+	checks := make([]ProviderCheck, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider ClientType) {
+			defer wg.Done()
+			checks[i] = checkProvider(provider, timeout)
+		}(i, provider)
+	}
+	wg.Wait()
+	return checks
+}
+
+// checkProvider lists one provider's models, giving up after timeout
+func checkProvider(provider ClientType, timeout time.Duration) ProviderCheck {
+	type listing struct {
+		models []ModelInfo
+		err    error
+	}
+	start := time.Now()
+	done := make(chan listing, 1)
+	go func() {
+		models, err := GetAvailableModels(provider)
+		done <- listing{models, err}
+	}()
+
+	select {
+	case result := <-done:
+		return ProviderCheck{Provider: provider, Models: len(result.models), Latency: time.Since(start), Err: result.err}
+	case <-time.After(timeout):
+		return ProviderCheck{Provider: provider, Latency: timeout, Err: fmt.Errorf("no answer within %v", timeout)}
+	}
+}
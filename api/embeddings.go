@@ -0,0 +1,313 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmbeddingsClient turns text into vectors for semantic search and memory
+type EmbeddingsClient interface {
+	// Embed returns one vector per input, in input order
+	Embed(texts []string) ([][]float32, error)
+	// Model returns the embedding model name, which identifies compatible vectors
+	Model() string
+}
+
+// embeddingsEndpoint describes an OpenAI-compatible /embeddings API
+type embeddingsEndpoint struct {
+	url          string
+	envVar       string
+	defaultModel string
+}
+
+// embeddingsEndpoints lists the providers with an OpenAI-compatible embeddings API
+var embeddingsEndpoints = map[string]embeddingsEndpoint{
+	"openai":    {"https://api.openai.com/v1/embeddings", "OPENAI_API_KEY", "text-embedding-3-small"},
+	"deepinfra": {"https://api.deepinfra.com/v1/openai/embeddings", "DEEPINFRA_API_KEY", "BAAI/bge-base-en-v1.5"},
+	"mistral":   {"https://api.mistral.ai/v1/embeddings", "MISTRAL_API_KEY", "mistral-embed"},
+}
+
+// Defaults for batching and rate limiting
+const (
+	DefaultEmbeddingsBatchSize = 64
+	DefaultEmbeddingsPerMinute = 60 // requests, not texts
+	defaultOllamaEmbedModel    = "nomic-embed-text"
+)
+
+// NewEmbeddingsClient creates an embeddings client for a provider ("openai",
+// "deepinfra", "mistral" or "ollama"); an empty model uses the provider's default
+func NewEmbeddingsClient(provider, model string) (EmbeddingsClient, error) {
+	provider = strings.ToLower(provider)
+	if provider == "ollama" {
+		if model == "" {
+			model = defaultOllamaEmbedModel
+		}
+		return NewOllamaEmbeddings(model), nil
+	}
+
+	endpoint, ok := embeddingsEndpoints[provider]
+	if !ok {
+		return nil, fmt.Errorf("provider %q has no embeddings API (supported: openai, deepinfra, mistral, ollama)", provider)
+	}
+	apiKey := os.Getenv(endpoint.envVar)
+	if apiKey == "" {
+		return nil, fmt.Errorf("%s not set", endpoint.envVar)
+	}
+	if model == "" {
+		model = endpoint.defaultModel
+	}
+	return NewOpenAICompatibleEmbeddings(endpoint.url, apiKey, model), nil
+}
+
+// DefaultEmbeddingsProvider picks an embeddings provider from the available
+// credentials, preferring a local Ollama server
+func DefaultEmbeddingsProvider() string {
+	if NewOllamaEmbeddings(defaultOllamaEmbedModel).available() {
+		return "ollama"
+	}
+	for _, provider := range []string{"openai", "deepinfra", "mistral"} {
+		if os.Getenv(embeddingsEndpoints[provider].envVar) != "" {
+			return provider
+		}
+	}
+	return ""
+}
+
+// rateLimiter spaces requests evenly to stay under a per-minute limit
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter allows perMinute requests per minute (0 disables limiting)
+func newRateLimiter(perMinute int) *rateLimiter {
+	limiter := &rateLimiter{}
+	if perMinute > 0 {
+		limiter.interval = time.Minute / time.Duration(perMinute)
+	}
+	return limiter
+}
+
+// wait blocks until the next request may be sent
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
+
+// OpenAICompatibleEmbeddings calls an OpenAI-style /embeddings endpoint
+type OpenAICompatibleEmbeddings struct {
+	httpClient *http.Client
+	url        string
+	apiKey     string
+	model      string
+	BatchSize  int // texts per request
+	limiter    *rateLimiter
+}
+
+// NewOpenAICompatibleEmbeddings creates a client for an OpenAI-style embeddings URL
+func NewOpenAICompatibleEmbeddings(url, apiKey, model string) *OpenAICompatibleEmbeddings {
+	return &OpenAICompatibleEmbeddings{
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		url:        url,
+		apiKey:     apiKey,
+		model:      model,
+		BatchSize:  DefaultEmbeddingsBatchSize,
+		limiter:    newRateLimiter(DefaultEmbeddingsPerMinute),
+	}
+}
+
+// SetRateLimit changes the maximum requests per minute (0 disables limiting)
+func (c *OpenAICompatibleEmbeddings) SetRateLimit(perMinute int) {
+	c.limiter = newRateLimiter(perMinute)
+}
+
+// Model returns the embedding model name
+func (c *OpenAICompatibleEmbeddings) Model() string {
+	return c.model
+}
+
+// Embed embeds texts in batches
+func (c *OpenAICompatibleEmbeddings) Embed(texts []string) ([][]float32, error) {
+	return embedInBatches(texts, c.BatchSize, func(batch []string) ([][]float32, error) {
+		var response struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		body := map[string]interface{}{"model": c.model, "input": batch}
+		if err := c.post(body, &response); err != nil {
+			return nil, err
+		}
+
+		vectors := make([][]float32, len(batch))
+		for _, item := range response.Data {
+			if item.Index < 0 || item.Index >= len(batch) {
+				return nil, fmt.Errorf("embeddings response index %d out of range", item.Index)
+			}
+			vectors[item.Index] = item.Embedding
+		}
+		return vectors, nil
+	})
+}
+
+// post sends an authenticated, rate-limited request to the embeddings URL
+func (c *OpenAICompatibleEmbeddings) post(body interface{}, out interface{}) error {
+	headers := map[string]string{"Authorization": "Bearer " + c.apiKey}
+	return postEmbeddingsJSON(c.httpClient, c.limiter, c.url, headers, body, out)
+}
+
+// OllamaEmbeddings calls a local Ollama server's /api/embed endpoint
+type OllamaEmbeddings struct {
+	httpClient *http.Client
+	baseURL    string
+	model      string
+	BatchSize  int
+	limiter    *rateLimiter
+}
+
+// NewOllamaEmbeddings creates a client for the Ollama server at OLLAMA_HOST
+// (default http://localhost:11434)
+func NewOllamaEmbeddings(model string) *OllamaEmbeddings {
+	return &OllamaEmbeddings{
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+		baseURL:    ollamaBaseURL(),
+		model:      model,
+		BatchSize:  DefaultEmbeddingsBatchSize,
+		limiter:    newRateLimiter(0), // local, so no limit
+	}
+}
+
+// Model returns the embedding model name
+func (c *OllamaEmbeddings) Model() string {
+	return c.model
+}
+
+// Embed embeds texts in batches
+func (c *OllamaEmbeddings) Embed(texts []string) ([][]float32, error) {
+	return embedInBatches(texts, c.BatchSize, func(batch []string) ([][]float32, error) {
+		var response struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		body := map[string]interface{}{"model": c.model, "input": batch}
+		if err := postEmbeddingsJSON(c.httpClient, c.limiter, c.baseURL+"/api/embed", nil, body, &response); err != nil {
+			return nil, err
+		}
+		return response.Embeddings, nil
+	})
+}
+
+// available reports whether the Ollama server responds
+func (c *OllamaEmbeddings) available() bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(c.baseURL + "/api/tags")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// embedInBatches splits texts into batches, embeds each and checks the count
+func embedInBatches(texts []string, batchSize int, embed func([]string) ([][]float32, error)) ([][]float32, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingsBatchSize
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := embed(texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed texts %d-%d: %w", start, end-1, err)
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		for i, vector := range batch {
+			if len(vector) == 0 {
+				return nil, fmt.Errorf("missing embedding for text %d", start+i)
+			}
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// postEmbeddingsJSON sends a rate-limited JSON POST, backing off on 429
+func postEmbeddingsJSON(client *http.Client, limiter *rateLimiter, url string, headers map[string]string, body interface{}, out interface{}) error {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	maxRetries := 3
+	for attempt := 0; ; attempt++ {
+		limiter.wait()
+		req, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %w", err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			time.Sleep(time.Duration(math.Pow(2, float64(attempt))) * time.Second)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("embeddings API error (status %d): %s", resp.StatusCode, string(respBody))
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse embeddings: %w", err)
+		}
+		return nil
+	}
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors (0 if either is empty)
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -1,14 +1,17 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/alantheprice/coder/types"
 )
 
 // ClientInterface defines the common interface for all API clients
 type ClientInterface interface {
-	SendChatRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
+	SendChatRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
 	CheckConnection() error
 	SetDebug(debug bool)
 	SetModel(model string) error
@@ -17,7 +20,25 @@ type ClientInterface interface {
 	GetModelContextLimit() (int, error)
 	SupportsVision() bool
 	GetVisionModel() string
-	SendVisionRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
+	SendVisionRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
+	// SendChatRequestStream passes the response to onToken as it is generated
+	// and returns the assembled response
+	SendChatRequestStream(ctx context.Context, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error)
+}
+
+// StreamCallback receives a response's content and reasoning as they are generated
+type StreamCallback = types.StreamCallback
+
+// sendUnstreamed answers a stream request with a regular request, for clients
+// that can't stream; the whole response goes to onToken at once
+func sendUnstreamed(ctx context.Context, client ClientInterface, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error) {
+	resp, err := client.SendChatRequest(ctx, messages, tools, reasoning)
+	if err == nil && onToken != nil && len(resp.Choices) > 0 {
+		if message := resp.Choices[0].Message; message.Content != "" || message.ReasoningContent != "" {
+			onToken(message.Content, message.ReasoningContent)
+		}
+	}
+	return resp, err
 }
 
 // ClientType represents the type of client to use
@@ -30,6 +51,14 @@ const (
 	OpenRouterClientType ClientType = "openrouter"
 	GroqClientType      ClientType = "groq"
 	DeepSeekClientType  ClientType = "deepseek"
+	AzureOpenAIClientType ClientType = "azure"
+	BedrockClientType   ClientType = "bedrock"
+	MistralClientType   ClientType = "mistral"
+	XAIClientType       ClientType = "xai"
+	HuggingFaceClientType ClientType = "huggingface"
+	AnthropicClientType ClientType = "anthropic"
+	OpenAIClientType    ClientType = "openai"
+	GeminiClientType    ClientType = "gemini"
 )
 
 // NewUnifiedClient creates a client with default model for the provider
@@ -44,12 +73,17 @@ func NewUnifiedClientWithModel(clientType ClientType, model string) (ClientInter
 	if model == "" {
 		model = GetDefaultModelForProvider(clientType)
 	}
+	model = ResolveModelAlias(clientType, model)
 	
+	if script := os.Getenv(ReplayEnv); script != "" {
+		return NewReplayClient(script, clientType, model)
+	}
+
 	switch clientType {
 	case DeepInfraClientType:
 		return NewDeepInfraClientWrapper(model)
 	case OllamaClientType:
-		return NewOllamaClient()
+		return NewOllamaClient(model)
 	case CerebrasClientType:
 		return NewCerebrasClientWrapper(model)
 	case OpenRouterClientType:
@@ -58,6 +92,22 @@ func NewUnifiedClientWithModel(clientType ClientType, model string) (ClientInter
 		return NewGroqClientWrapper(model)
 	case DeepSeekClientType:
 		return NewDeepSeekClientWrapper(model)
+	case AzureOpenAIClientType:
+		return NewAzureOpenAIProvider(model)
+	case BedrockClientType:
+		return NewBedrockProvider(model)
+	case MistralClientType:
+		return NewMistralProvider(model)
+	case XAIClientType:
+		return NewXAIProvider(model)
+	case HuggingFaceClientType:
+		return NewHuggingFaceProvider(model)
+	case AnthropicClientType:
+		return NewAnthropicProvider(model)
+	case OpenAIClientType:
+		return NewOpenAIProvider(model)
+	case GeminiClientType:
+		return NewGeminiProvider(model)
 	default:
 		return nil, fmt.Errorf("unknown client type: %s", clientType)
 	}
@@ -84,14 +134,12 @@ func NewOpenRouterClientWrapper(model string) (ClientInterface, error) {
 
 // NewGroqClientWrapper creates a Groq client wrapper
 func NewGroqClientWrapper(model string) (ClientInterface, error) {
-	// For now, return an error since Groq provider is not fully implemented
-	return nil, fmt.Errorf("Groq provider not yet implemented")
+	return NewGroqProvider(model)
 }
 
 // NewDeepSeekClientWrapper creates a DeepSeek client wrapper
 func NewDeepSeekClientWrapper(model string) (ClientInterface, error) {
-	// For now, return an error since DeepSeek provider is not fully implemented
-	return nil, fmt.Errorf("DeepSeek provider not yet implemented")
+	return NewDeepSeekProvider(model)
 }
 
 // GetClientTypeFromEnv determines which client to use based on environment variables
@@ -106,6 +154,13 @@ func GetClientTypeFromEnv() ClientType {
 		{"CEREBRAS_API_KEY", CerebrasClientType},
 		{"GROQ_API_KEY", GroqClientType},
 		{"DEEPSEEK_API_KEY", DeepSeekClientType},
+		{"MISTRAL_API_KEY", MistralClientType},
+		{"XAI_API_KEY", XAIClientType},
+		{"AZURE_OPENAI_API_KEY", AzureOpenAIClientType},
+		{"HF_ENDPOINT_URL", HuggingFaceClientType},
+		{"ANTHROPIC_API_KEY", AnthropicClientType},
+		{"OPENAI_API_KEY", OpenAIClientType},
+		{"GEMINI_API_KEY", GeminiClientType},
 	}
 
 	for _, provider := range envProviders {
@@ -128,11 +183,31 @@ func GetDefaultModelForProvider(clientType ClientType) string {
 	case OllamaClientType:
 		return "gpt-oss:20b"
 	case CerebrasClientType:
-		return "cerebras/btlm-3b-8k-base"
+		return "qwen-3-235b-a22b-instruct-2507"
 	case GroqClientType:
-		return "llama3-70b-8192"
+		return "llama-3.3-70b-versatile"
 	case DeepSeekClientType:
 		return "deepseek-chat"
+	case AzureOpenAIClientType:
+		// Azure routes by deployment name, which each organisation chooses
+		if deployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT"); deployment != "" {
+			return deployment
+		}
+		return "gpt-4o"
+	case BedrockClientType:
+		return "anthropic.claude-3-5-sonnet-20240620-v1:0"
+	case MistralClientType:
+		return "devstral-medium-latest"
+	case XAIClientType:
+		return "grok-code-fast-1"
+	case HuggingFaceClientType:
+		return "tgi" // the endpoint in HF_ENDPOINT_URL
+	case AnthropicClientType:
+		return "claude-sonnet-4-5"
+	case OpenAIClientType:
+		return "gpt-4.1"
+	case GeminiClientType:
+		return "gemini-2.5-flash"
 	default:
 		return "deepseek/deepseek-chat" // Default to OpenRouter
 	}
@@ -154,6 +229,18 @@ func GetVisionModelForProvider(clientType ClientType) string {
 		return "llama-3.2-11b-vision-preview" // Groq has vision models
 	case DeepSeekClientType:
 		return "" // DeepSeek doesn't have vision models in their API yet
+	case AzureOpenAIClientType:
+		return "" // Deployments are user-named, so no vision model can be assumed
+	case BedrockClientType:
+		return "anthropic.claude-3-5-sonnet-20240620-v1:0"
+	case MistralClientType, XAIClientType, HuggingFaceClientType:
+		return "" // Messages are sent as plain text
+	case AnthropicClientType:
+		return "claude-sonnet-4-5"
+	case OpenAIClientType:
+		return "gpt-4.1"
+	case GeminiClientType:
+		return "gemini-2.5-flash"
 	default:
 		return "" // No vision support by default
 	}
@@ -189,6 +276,13 @@ func GetClientTypeWithFallback() (ClientType, error) {
 		{"CEREBRAS_API_KEY", CerebrasClientType},
 		{"GROQ_API_KEY", GroqClientType},
 		{"DEEPSEEK_API_KEY", DeepSeekClientType},
+		{"MISTRAL_API_KEY", MistralClientType},
+		{"XAI_API_KEY", XAIClientType},
+		{"AZURE_OPENAI_API_KEY", AzureOpenAIClientType},
+		{"HF_ENDPOINT_URL", HuggingFaceClientType},
+		{"ANTHROPIC_API_KEY", AnthropicClientType},
+		{"OPENAI_API_KEY", OpenAIClientType},
+		{"GEMINI_API_KEY", GeminiClientType},
 	}
 
 	for _, provider := range envProviders {
@@ -212,6 +306,14 @@ func GetAvailableProviders() []ClientType {
 		OpenRouterClientType,
 		GroqClientType,
 		DeepSeekClientType,
+		AzureOpenAIClientType,
+		BedrockClientType,
+		MistralClientType,
+		XAIClientType,
+		HuggingFaceClientType,
+		AnthropicClientType,
+		OpenAIClientType,
+		GeminiClientType,
 	}
 }
 
@@ -230,6 +332,22 @@ func GetProviderName(clientType ClientType) string {
 		return "Groq"
 	case DeepSeekClientType:
 		return "DeepSeek"
+	case AzureOpenAIClientType:
+		return "Azure OpenAI"
+	case BedrockClientType:
+		return "AWS Bedrock"
+	case MistralClientType:
+		return "Mistral"
+	case XAIClientType:
+		return "xAI"
+	case HuggingFaceClientType:
+		return "Hugging Face"
+	case AnthropicClientType:
+		return "Anthropic"
+	case OpenAIClientType:
+		return "OpenAI"
+	case GeminiClientType:
+		return "Google Gemini"
 	default:
 		return string(clientType)
 	}
@@ -251,17 +369,53 @@ func GetProviderFromString(providerStr string) (ClientType, error) {
 		return GroqClientType, nil
 	case "deepseek":
 		return DeepSeekClientType, nil
+	case "azure":
+		return AzureOpenAIClientType, nil
+	case "bedrock":
+		return BedrockClientType, nil
+	case "mistral":
+		return MistralClientType, nil
+	case "xai", "grok":
+		return XAIClientType, nil
+	case "huggingface", "hf", "tgi":
+		return HuggingFaceClientType, nil
+	case "anthropic", "claude":
+		return AnthropicClientType, nil
+	case "openai":
+		return OpenAIClientType, nil
+	case "gemini":
+		return GeminiClientType, nil
 	default:
 		return "", fmt.Errorf("unknown provider: %s", providerStr)
 	}
 }
 
+// RequestParametersClient is implemented by clients that merge a per-model
+// parameter profile into each request. It reports whether the profile is used.
+type RequestParametersClient interface {
+	SetRequestParameters(params map[string]interface{}) bool
+}
+
+// LocalModelClient is implemented by clients of local servers that load the
+// model into memory on demand
+type LocalModelClient interface {
+	// SetKeepAlive sets how long the model stays loaded after a request, such
+	// as "30m" or "-1" for indefinitely; "" leaves the server's default
+	SetKeepAlive(keepAlive string)
+	// WarmUp loads the model without generating anything
+	WarmUp(ctx context.Context) error
+	// Unload frees the memory the model holds until the next request
+	Unload(ctx context.Context) error
+}
+
 // DeepInfraClientWrapper wraps the existing DeepInfra client to implement ClientInterface
 type DeepInfraClientWrapper struct {
-	client *Client
+	client         *Client
+	responseFormat map[string]interface{}
+	requestParams  map[string]interface{}
 }
 
-func (w *DeepInfraClientWrapper) SendChatRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+func (w *DeepInfraClientWrapper) SendChatRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
 	// Calculate context-aware max_tokens to avoid exceeding model limits
 	maxTokens := w.calculateMaxTokens(messages, tools)
 	
@@ -271,8 +425,43 @@ func (w *DeepInfraClientWrapper) SendChatRequest(messages []Message, tools []Too
 		Tools:     tools,
 		MaxTokens: maxTokens,
 		Reasoning: reasoning,
+
+		ResponseFormat: w.responseFormat,
+		Parameters:     w.requestParams,
 	}
-	return w.client.SendChatRequest(req)
+	return w.client.SendChatRequest(ctx, req)
+}
+
+// SendChatRequestStream streams the response; GPT-OSS responses are
+// post-processed whole, so they aren't streamed
+func (w *DeepInfraClientWrapper) SendChatRequestStream(ctx context.Context, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error) {
+	if IsGPTOSSModel(w.client.model) {
+		return sendUnstreamed(ctx, w, messages, tools, reasoning, onToken)
+	}
+	req := ChatRequest{
+		Model:     w.client.model,
+		Messages:  messages,
+		Tools:     tools,
+		MaxTokens: w.calculateMaxTokens(messages, tools),
+		Reasoning: reasoning,
+
+		ResponseFormat: w.responseFormat,
+		Parameters:     w.requestParams,
+	}
+	return w.client.SendChatRequestStream(ctx, req, onToken)
+}
+
+// SetResponseFormat constrains subsequent responses to a JSON schema. GPT-OSS
+// models use the harmony completion format, which has no response_format.
+func (w *DeepInfraClientWrapper) SetResponseFormat(format map[string]interface{}) bool {
+	w.responseFormat = format
+	return !IsGPTOSSModel(w.client.model)
+}
+
+// SetRequestParameters sets the parameter profile merged into each request
+func (w *DeepInfraClientWrapper) SetRequestParameters(params map[string]interface{}) bool {
+	w.requestParams = params
+	return true
 }
 
 // calculateMaxTokens calculates appropriate max_tokens based on input size and model limits
@@ -283,12 +472,10 @@ func (w *DeepInfraClientWrapper) calculateMaxTokens(messages []Message, tools []
 		contextLimit = 32000 // Conservative default
 	}
 	
-	// Rough estimation: 1 token ≈ 4 characters
+	// Count tokens from messages with the model's tokenizer
 	inputTokens := 0
-	
-	// Estimate tokens from messages
 	for _, msg := range messages {
-		inputTokens += len(msg.Content) / 4
+		inputTokens += CountTokens(w.client.model, msg.Content)
 	}
 	
 	// Estimate tokens from tools (tools descriptions can be large)
@@ -373,10 +560,10 @@ func (w *DeepInfraClientWrapper) GetVisionModel() string {
 	return GetVisionModelForProvider(DeepInfraClientType)
 }
 
-func (w *DeepInfraClientWrapper) SendVisionRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+func (w *DeepInfraClientWrapper) SendVisionRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
 	if !w.SupportsVision() {
 		// Fallback to regular chat request if no vision model available
-		return w.SendChatRequest(messages, tools, reasoning)
+		return w.SendChatRequest(ctx, messages, tools, reasoning)
 	}
 	
 	// Temporarily switch to vision model for this request
@@ -385,11 +572,11 @@ func (w *DeepInfraClientWrapper) SendVisionRequest(messages []Message, tools []T
 	
 	if err := w.SetModel(visionModel); err != nil {
 		// If we can't set the vision model, fallback to regular request
-		return w.SendChatRequest(messages, tools, reasoning)
+		return w.SendChatRequest(ctx, messages, tools, reasoning)
 	}
 	
 	// Send the vision request
-	response, err := w.SendChatRequest(messages, tools, reasoning)
+	response, err := w.SendChatRequest(ctx, messages, tools, reasoning)
 	
 	// Restore original model
 	w.SetModel(originalModel)
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// VRAMOverrideEnv sets the GPU memory, in GB, used to size local models when
+// it can't be detected (AMD and Intel GPUs, remote Ollama servers)
+const VRAMOverrideEnv = "CODER_VRAM_GB"
+
+const gb = 1 << 30
+
+// modelOverhead approximates the memory a loaded model needs beyond its
+// weights, mostly the KV cache
+const modelOverhead = 1.2
+
+// LocalMemory describes the memory available to run local models
+type LocalMemory struct {
+	VRAM    int64 // bytes of GPU memory, 0 when no GPU was found
+	RAM     int64 // bytes of system memory, 0 when unknown
+	Unified bool  // the GPU shares system memory, as on Apple silicon
+}
+
+// Known reports whether any memory could be detected
+func (m LocalMemory) Known() bool {
+	return m.VRAM > 0 || m.RAM > 0
+}
+
+func (m LocalMemory) String() string {
+	switch {
+	case m.Unified:
+		return fmt.Sprintf("%.0fGB unified memory", float64(m.RAM)/gb)
+	case m.VRAM > 0:
+		return fmt.Sprintf("%.0fGB VRAM", float64(m.VRAM)/gb)
+	case m.RAM > 0:
+		return fmt.Sprintf("%.0fGB RAM, no GPU detected", float64(m.RAM)/gb)
+	default:
+		return "unknown memory"
+	}
+}
+
+// ModelFit says how well a local model suits the machine's memory
+type ModelFit string
+
+const (
+	ModelFits     ModelFit = "fits"
+	ModelSlow     ModelFit = "will be slow"
+	ModelTooLarge ModelFit = "too large"
+)
+
+// FitLocalModel rates a model of the given download size against mem. A model
+// fits when it loads entirely into GPU memory and is slow when part of it
+// spills to system memory or there's no GPU. It returns "" when the memory is
+// unknown.
+func FitLocalModel(size int64, mem LocalMemory) ModelFit {
+	if !mem.Known() || size <= 0 {
+		return ""
+	}
+	needed := int64(float64(size) * modelOverhead)
+	switch {
+	case mem.VRAM > 0 && needed <= mem.VRAM:
+		return ModelFits
+	case needed <= mem.VRAM+mem.RAM && !mem.Unified:
+		return ModelSlow
+	default:
+		return ModelTooLarge
+	}
+}
+
+// localModel is a coder-tuned model worth recommending for local inference
+type localModel struct {
+	name string
+	size int64 // approximate download size
+	cpu  bool  // small enough to be usable without a GPU
+}
+
+// localModelCatalog lists recommendations best first
+var localModelCatalog = []localModel{
+	{name: "qwen3-coder:30b", size: 19 * gb},
+	{name: "gpt-oss:20b", size: 14 * gb},
+	{name: "qwen2.5-coder:14b", size: 9 * gb},
+	{name: "qwen2.5-coder:7b", size: 47 * gb / 10, cpu: true},
+	{name: "qwen2.5-coder:3b", size: 19 * gb / 10, cpu: true},
+}
+
+// RecommendLocalModel picks the best catalog model for mem: the largest that
+// fits in GPU memory, otherwise a small one that runs on the CPU
+func RecommendLocalModel(mem LocalMemory) string {
+	if !mem.Known() {
+		return OllamaModel
+	}
+	for _, model := range localModelCatalog {
+		if FitLocalModel(model.size, mem) == ModelFits {
+			return model.name
+		}
+	}
+	for _, model := range localModelCatalog {
+		if model.cpu && FitLocalModel(model.size, mem) != ModelTooLarge {
+			return model.name
+		}
+	}
+	return localModelCatalog[len(localModelCatalog)-1].name
+}
+
+// LocalModelSuggestion describes the recommended model for this machine with
+// the command that pulls it
+func LocalModelSuggestion() string {
+	mem := DetectLocalMemory()
+	model := RecommendLocalModel(mem)
+	if !mem.Known() {
+		return fmt.Sprintf("%s (ollama pull %s)", model, model)
+	}
+	return fmt.Sprintf("%s for %s (ollama pull %s)", model, mem, model)
+}
+
+// LocalModelWarning explains when a pulled Ollama model is too large for this
+// machine or will run slowly, suggesting a better fit. It returns "" when the
+// model suits the machine or can't be checked.
+func LocalModelWarning(model string) string {
+	models, err := getOllamaModels()
+	if err != nil {
+		return ""
+	}
+	mem := DetectLocalMemory()
+	for _, info := range models {
+		if !sameLocalModel(info.ID, model) {
+			continue
+		}
+		var problem string
+		switch info.Fit {
+		case ModelTooLarge:
+			problem = fmt.Sprintf("%s (%s) is too large for %s", model, info.Size, mem)
+		case ModelSlow:
+			problem = fmt.Sprintf("%s (%s) doesn't fit in %s and will be slow", model, info.Size, mem)
+		default:
+			return ""
+		}
+		if recommended := RecommendLocalModel(mem); !sameLocalModel(recommended, model) {
+			problem += fmt.Sprintf("; try %s (ollama pull %s)", recommended, recommended)
+		}
+		return problem
+	}
+	return ""
+}
+
+// DetectLocalMemory finds the GPU and system memory. NVIDIA GPUs are queried
+// with nvidia-smi and Apple silicon shares system memory with its GPU; other
+// GPUs can be described with CODER_VRAM_GB.
+func DetectLocalMemory() LocalMemory {
+	mem := LocalMemory{RAM: systemMemory()}
+	if value := os.Getenv(VRAMOverrideEnv); value != "" {
+		if size, err := strconv.ParseFloat(value, 64); err == nil && size > 0 {
+			mem.VRAM = int64(size * gb)
+			return mem
+		}
+	}
+
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" && mem.RAM > 0 {
+		// Metal can use roughly three quarters of unified memory by default
+		mem.Unified = true
+		mem.VRAM = mem.RAM / 4 * 3
+		return mem
+	}
+	mem.VRAM = nvidiaMemory()
+	return mem
+}
+
+// nvidiaMemory totals the memory of all NVIDIA GPUs, which Ollama splits
+// models across
+func nvidiaMemory() int64 {
+	output, err := exec.Command("nvidia-smi", "--query-gpu=memory.total", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if mib, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64); err == nil {
+			total += mib << 20
+		}
+	}
+	return total
+}
+
+// systemMemory returns the total system memory, or 0 when it can't be read
+func systemMemory() int64 {
+	if runtime.GOOS == "darwin" {
+		output, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+		if err != nil {
+			return 0
+		}
+		size, _ := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+		return size
+	}
+
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			return kb << 10
+		}
+	}
+	return 0
+}
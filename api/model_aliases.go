@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+)
+
+// retiredModels maps model IDs that providers renamed or retired to their
+// replacements, per provider. Saved configs, project pins and failover chains
+// still refer to old IDs long after a provider drops them, so they are
+// remapped rather than failing on the first request.
+var retiredModels = map[ClientType]map[string]string{
+	GroqClientType: {
+		"llama3-70b-8192":            "llama-3.3-70b-versatile",
+		"llama3-8b-8192":             "llama-3.1-8b-instant",
+		"llama-3.1-70b-versatile":    "llama-3.3-70b-versatile",
+		"llama-3.2-90b-text-preview": "llama-3.3-70b-versatile",
+	},
+	CerebrasClientType: {
+		"llama3.1-70b": "llama-3.3-70b",
+	},
+	OpenRouterClientType: {
+		// Free variants that OpenRouter no longer serves
+		"deepseek/deepseek-chat:free":         "deepseek/deepseek-chat-v3.1:free",
+		"deepseek/deepseek-chat-v3-0324:free": "deepseek/deepseek-chat-v3.1:free",
+	},
+	AnthropicClientType: {
+		"claude-3-5-sonnet-latest": "claude-sonnet-4-5",
+		"claude-3-7-sonnet-latest": "claude-sonnet-4-5",
+		"claude-3-opus-latest":     "claude-opus-4-1",
+		"claude-3-5-haiku-latest":  "claude-haiku-4-5",
+	},
+	OpenAIClientType: {
+		"gpt-4.5-preview": "gpt-4.1",
+		"o1-preview":      "o3",
+		"o1-mini":         "o4-mini",
+	},
+	GeminiClientType: {
+		"gemini-1.5-pro":     "gemini-2.5-pro",
+		"gemini-1.5-flash":   "gemini-2.5-flash",
+		"gemini-2.0-pro-exp": "gemini-2.5-pro",
+	},
+	XAIClientType: {
+		"grok-beta": "grok-4",
+	},
+}
+
+// warnedAliases records which retired IDs have been reported, so a model
+// remapped on every client construction is only warned about once
+var warnedAliases sync.Map
+
+// ResolveModelAlias returns the current ID for a retired or renamed model,
+// printing a warning the first time it is remapped. Other IDs are returned
+// unchanged.
+func ResolveModelAlias(clientType ClientType, model string) string {
+	replacement, ok := retiredModels[clientType][model]
+	if !ok {
+		return model
+	}
+	if _, warned := warnedAliases.LoadOrStore(string(clientType)+"/"+model, true); !warned {
+		fmt.Printf("⚠️  %s model %s has been retired; using %s instead\n", GetProviderName(clientType), model, replacement)
+	}
+	return replacement
+}
@@ -27,6 +27,7 @@ type ModelInfo struct {
 	OutputCost    float64  `json:"output_cost,omitempty"`
 	ContextLength int      `json:"context_length,omitempty"`
 	Tags          []string `json:"tags,omitempty"`
+	Fit           ModelFit `json:"fit,omitempty"` // local models: how well it suits this machine
 }
 
 // ModelsListInterface defines methods for listing available models
@@ -36,19 +37,16 @@ type ModelsListInterface interface {
 	IsModelAvailable(modelID string) bool
 }
 
-// GetAvailableModels returns available models for the current provider
-func GetAvailableModels() ([]ModelInfo, error) {
-	clientType := GetClientTypeFromEnv()
-	return GetModelsForProvider(clientType)
-}
-
-// GetModelsForProvider returns available models for a specific provider
-func GetModelsForProvider(clientType ClientType) ([]ModelInfo, error) {
+// GetAvailableModels returns the models available from a provider. The
+// provider is always explicit, so listing one provider's models never depends
+// on which other API keys happen to be set.
+func GetAvailableModels(clientType ClientType) ([]ModelInfo, error) {
 	// Try to use the provider's ListModels method first
 	provider, err := createProviderForType(clientType)
 	if err == nil && provider != nil {
-		typesModels, listErr := provider.ListModels()
-		if listErr == nil {
+		var typesModels []types.ModelInfo
+		typesModels, err = provider.ListModels()
+		if err == nil {
 			// Convert from types.ModelInfo to api.ModelInfo
 			apiModels := make([]ModelInfo, len(typesModels))
 			for i, typesModel := range typesModels {
@@ -72,6 +70,9 @@ func GetModelsForProvider(clientType ClientType) ([]ModelInfo, error) {
 		return getGroqModels()
 	case DeepSeekClientType:
 		return getDeepSeekModels()
+	case AzureOpenAIClientType, BedrockClientType, MistralClientType, XAIClientType, HuggingFaceClientType, AnthropicClientType, OpenAIClientType, GeminiClientType:
+		// These providers only list models through their provider implementation
+		return nil, err
 	default:
 		return nil, fmt.Errorf("unknown client type: %s", clientType)
 	}
@@ -186,7 +187,7 @@ func getDeepInfraModels() ([]ModelInfo, error) {
 func getOllamaModels() ([]ModelInfo, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 	
-	resp, err := client.Get("http://localhost:11434/api/tags")
+	resp, err := client.Get(ollamaBaseURL() + "/api/tags")
 	if err != nil {
 		return nil, fmt.Errorf("Ollama is not running. Please start Ollama first")
 	}
@@ -221,6 +222,7 @@ func getOllamaModels() ([]ModelInfo, error) {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 	
+	mem := DetectLocalMemory()
 	models := make([]ModelInfo, len(response.Models))
 	for i, model := range response.Models {
 		sizeGB := float64(model.Size) / (1024 * 1024 * 1024)
@@ -230,6 +232,7 @@ func getOllamaModels() ([]ModelInfo, error) {
 			Provider: "Ollama (Local)",
 			Size:     fmt.Sprintf("%.1fGB", sizeGB),
 			Cost:     0.0, // Local models are free
+			Fit:      FitLocalModel(model.Size, mem),
 		}
 		
 		// Add descriptions for known models
@@ -385,6 +388,22 @@ func getOpenRouterModels() ([]ModelInfo, error) {
 	return addAvailabilityHints(models), nil
 }
 
+// FindModel looks a model up by ID, as providers.FindOpenRouterModel does:
+// OpenRouter variants missing from the list (":nitro", ":floor", ...) resolve
+// to their base model, and ":free" variants cost nothing.
+func FindModel(models []ModelInfo, model string) (ModelInfo, bool) {
+	i, free := providers.OpenRouterModelIndex(models, func(m ModelInfo) string { return m.ID }, model)
+	if i < 0 {
+		return ModelInfo{}, false
+	}
+	found := models[i]
+	found.ID = model
+	if free {
+		found.InputCost, found.OutputCost, found.Cost = 0, 0, 0
+	}
+	return found, true
+}
+
 // addAvailabilityHints adds availability information based on known working models
 func addAvailabilityHints(models []ModelInfo) []ModelInfo {
 	// Known working models based on our testing
@@ -651,6 +670,26 @@ func createProviderForType(clientType ClientType) (types.ProviderInterface, erro
 		return providers.NewCerebrasProvider()
 	case OpenRouterClientType:
 		return providers.NewOpenRouterProvider()
+	case AzureOpenAIClientType:
+		return providers.NewAzureOpenAIProvider()
+	case BedrockClientType:
+		return providers.NewBedrockProvider()
+	case MistralClientType:
+		return providers.NewMistralProvider()
+	case XAIClientType:
+		return providers.NewXAIProvider()
+	case HuggingFaceClientType:
+		return providers.NewHuggingFaceProvider()
+	case GroqClientType:
+		return providers.NewGroqProvider()
+	case DeepSeekClientType:
+		return providers.NewDeepSeekProvider()
+	case AnthropicClientType:
+		return providers.NewAnthropicProvider()
+	case OpenAIClientType:
+		return providers.NewOpenAIProvider()
+	case GeminiClientType:
+		return providers.NewGeminiProvider()
 	// DeepInfra provider is incomplete, will use fallback
 	case DeepInfraClientType:
 		return nil, fmt.Errorf("DeepInfra provider is incomplete, using fallback")
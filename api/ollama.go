@@ -2,13 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/alantheprice/coder/types"
 )
 
 const (
@@ -16,27 +22,61 @@ const (
 	OllamaModel = "gpt-oss:20b"
 )
 
+// ollamaBaseURL returns the Ollama server at OLLAMA_HOST (default
+// http://localhost:11434)
+func ollamaBaseURL() string {
+	baseURL := strings.TrimRight(os.Getenv("OLLAMA_HOST"), "/")
+	if baseURL == "" {
+		return "http://localhost:11434"
+	}
+	if !strings.HasPrefix(baseURL, "http") {
+		baseURL = "http://" + baseURL
+	}
+	return baseURL
+}
+
+// isHarmonyLocalModel reports whether a local model is a GPT-OSS model that
+// takes harmony-formatted prompts
+func isHarmonyLocalModel(model string) bool {
+	return strings.HasPrefix(model, "gpt-oss")
+}
+
 type LocalOllamaClient struct {
-	httpClient *http.Client
-	baseURL    string
-	model      string
-	debug      bool
+	httpClient    *http.Client
+	baseURL       string
+	model         string
+	debug         bool
+	requestParams map[string]interface{} // per-model profile merged into each request
+	keepAlive     string                 // how long the model stays loaded, "" for the server's default
 }
 
 // Using OpenAI-compatible endpoint, so we reuse existing ChatRequest and ChatResponse structs
 
-func NewOllamaClient() (*LocalOllamaClient, error) {
+func NewOllamaClient(model string) (*LocalOllamaClient, error) {
+	if model == "" {
+		model = OllamaModel
+	}
 	return &LocalOllamaClient{
 		httpClient: &http.Client{
 			Timeout: 300 * time.Second, // Longer timeout for local inference
 		},
-		baseURL: OllamaURL,
-		model:   OllamaModel,
+		baseURL: ollamaBaseURL() + "/v1/chat/completions",
+		model:   model,
 		debug:   false, // Will be set later via SetDebug
 	}, nil
 }
 
-func (c *LocalOllamaClient) SendChatRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+// SetRequestParameters sets the parameter profile merged into each request
+func (c *LocalOllamaClient) SetRequestParameters(params map[string]interface{}) bool {
+	c.requestParams = params
+	return true
+}
+
+func (c *LocalOllamaClient) SendChatRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+	if !isHarmonyLocalModel(c.model) {
+		return c.sendStandardRequest(ctx, messages, tools)
+	}
+
 	// Convert to ENHANCED harmony format
 	var formatter *HarmonyFormatter
 	if reasoning != "" {
@@ -68,13 +108,43 @@ func (c *LocalOllamaClient) SendChatRequest(messages []Message, tools []Tool, re
 	if reasoning != "" {
 		req["reasoning_effort"] = reasoning
 	}
+	types.MergeRequestParameters(req, c.requestParams)
+
+	chatResp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
+	// Strip return token from GPT-OSS model responses
+	for i, choice := range chatResp.Choices {
+		chatResp.Choices[i].Message.Content = formatter.StripReturnToken(choice.Message.Content)
+	}
+
+	return chatResp, nil
+}
+
+// sendStandardRequest sends messages and tools in the OpenAI format, which
+// Ollama templates for every model other than GPT-OSS
+func (c *LocalOllamaClient) sendStandardRequest(ctx context.Context, messages []Message, tools []Tool) (*ChatResponse, error) {
+	req := map[string]interface{}{
+		"model":    c.model,
+		"messages": messages,
+	}
+	if len(tools) > 0 {
+		req["tools"] = tools
+	}
+	types.MergeRequestParameters(req, c.requestParams)
+	return c.send(ctx, req)
+}
+
+// send posts a chat completion request to Ollama
+func (c *LocalOllamaClient) send(ctx context.Context, req map[string]interface{}) (*ChatResponse, error) {
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", c.baseURL, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -114,17 +184,84 @@ func (c *LocalOllamaClient) SendChatRequest(messages []Message, tools []Tool, re
 	// Set cost to 0 for local inference
 	chatResp.Usage.EstimatedCost = 0.0
 
-	// Strip return token from GPT-OSS model responses
-	for i, choice := range chatResp.Choices {
-		chatResp.Choices[i].Message.Content = formatter.StripReturnToken(choice.Message.Content)
+	// The OpenAI-compatible endpoint resets the model's expiry to the server's
+	// default, so extend it again
+	if c.keepAlive != "" {
+		if err := c.load(ctx, keepAliveValue(c.keepAlive)); err != nil && c.debug {
+			log.Printf("Ollama keep-alive failed: %v", err)
+		}
 	}
 
 	return &chatResp, nil
 }
 
+// SetKeepAlive sets how long the model stays loaded after each request
+func (c *LocalOllamaClient) SetKeepAlive(keepAlive string) {
+	c.keepAlive = strings.TrimSpace(keepAlive)
+}
+
+// WarmUp loads the model so the first real request doesn't wait for it
+func (c *LocalOllamaClient) WarmUp(ctx context.Context) error {
+	var keepAlive interface{}
+	if c.keepAlive != "" {
+		keepAlive = keepAliveValue(c.keepAlive)
+	}
+	return c.load(ctx, keepAlive)
+}
+
+// Unload frees the model's memory; the next request loads it again
+func (c *LocalOllamaClient) Unload(ctx context.Context) error {
+	return c.load(ctx, 0)
+}
+
+// load sends Ollama a generate request without a prompt, which loads the
+// model and sets its expiry without generating anything. A keepAlive of 0
+// unloads it and nil uses the server's default.
+func (c *LocalOllamaClient) load(ctx context.Context, keepAlive interface{}) error {
+	req := map[string]interface{}{"model": c.model}
+	if keepAlive != nil {
+		req["keep_alive"] = keepAlive
+	}
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", ollamaBaseURL()+"/api/generate", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to reach Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Ollama request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// keepAliveValue converts a keep-alive setting to what Ollama expects: plain
+// numbers are seconds (negative keeps the model loaded indefinitely), anything
+// else is a duration such as "30m"
+func keepAliveValue(keepAlive string) interface{} {
+	if seconds, err := strconv.ParseFloat(keepAlive, 64); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// SendChatRequestStream answers in one piece: harmony output is post-processed whole
+func (c *LocalOllamaClient) SendChatRequestStream(ctx context.Context, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error) {
+	return sendUnstreamed(ctx, c, messages, tools, reasoning, onToken)
+}
+
 func (c *LocalOllamaClient) CheckConnection() error {
-	// Check if Ollama is running and gpt-oss model is available
-	checkURL := "http://localhost:11434/api/tags"
+	// Check if Ollama is running and the model has been pulled
+	checkURL := ollamaBaseURL() + "/api/tags"
 
 	resp, err := c.httpClient.Get(checkURL)
 	if err != nil {
@@ -141,7 +278,6 @@ func (c *LocalOllamaClient) CheckConnection() error {
 		return fmt.Errorf("failed to read Ollama tags response: %w", err)
 	}
 
-	// Check if gpt-oss model is available
 	var tagsResp struct {
 		Models []struct {
 			Name string `json:"name"`
@@ -152,19 +288,32 @@ func (c *LocalOllamaClient) CheckConnection() error {
 		return fmt.Errorf("failed to parse Ollama tags response: %w", err)
 	}
 
-	hasGPTOSS := false
 	for _, model := range tagsResp.Models {
-		if model.Name == "gpt-oss:20b" || model.Name == "gpt-oss:latest" || model.Name == "gpt-oss" {
-			hasGPTOSS = true
-			break
+		if sameLocalModel(model.Name, c.model) {
+			return nil
 		}
 	}
 
-	if !hasGPTOSS {
-		return fmt.Errorf("gpt-oss:20b model not found. Please run: ollama pull gpt-oss:20b")
+	message := fmt.Sprintf("%s model not found. Please run: ollama pull %s", c.model, c.model)
+	if recommended := RecommendLocalModel(DetectLocalMemory()); recommended != c.model {
+		message += fmt.Sprintf(" (or ollama pull %s, recommended for this machine)", recommended)
 	}
+	return errors.New(message)
+}
 
-	return nil
+// sameLocalModel compares Ollama model names, where "name" means
+// "name:latest". gpt-oss:latest is the 20b model.
+func sameLocalModel(a, b string) bool {
+	normalize := func(name string) string {
+		if !strings.Contains(name, ":") {
+			name += ":latest"
+		}
+		if name == "gpt-oss:latest" {
+			return OllamaModel
+		}
+		return name
+	}
+	return normalize(a) == normalize(b)
 }
 
 func (c *LocalOllamaClient) SetDebug(debug bool) {
@@ -209,10 +358,10 @@ func (c *LocalOllamaClient) GetVisionModel() string {
 }
 
 // SendVisionRequest sends a vision-enabled chat request
-func (c *LocalOllamaClient) SendVisionRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+func (c *LocalOllamaClient) SendVisionRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
 	if !c.SupportsVision() {
 		// Fallback to regular chat request if no vision model available
-		return c.SendChatRequest(messages, tools, reasoning)
+		return c.SendChatRequest(ctx, messages, tools, reasoning)
 	}
 	
 	// Temporarily switch to vision model for this request
@@ -222,7 +371,7 @@ func (c *LocalOllamaClient) SendVisionRequest(messages []Message, tools []Tool,
 	c.model = visionModel
 	
 	// Send the vision request
-	response, err := c.SendChatRequest(messages, tools, reasoning)
+	response, err := c.SendChatRequest(ctx, messages, tools, reasoning)
 	
 	// Restore original model
 	c.model = originalModel
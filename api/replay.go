@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ReplayEnv names a replay script. When it is set, every client is a
+// ReplayClient, so the CLI can be tested end to end without a provider.
+const ReplayEnv = "CODER_REPLAY_FILE"
+
+// ReplayResponse is one scripted model turn
+type ReplayResponse struct {
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"tool_calls,omitempty"`
+	// Expect, if set, must appear in the last message of the request, e.g. a tool result
+	Expect string `json:"expect,omitempty"`
+}
+
+// ReplayClient answers chat requests with the responses of a script, in order
+type ReplayClient struct {
+	mu        sync.Mutex
+	path      string
+	responses []ReplayResponse
+	next      int
+	model     string
+	provider  string
+}
+
+// NewReplayClient loads a replay script: {"responses": [{"content": ..., "tool_calls": [{"name": ..., "arguments": {...}}]}]}
+func NewReplayClient(path string, clientType ClientType, model string) (*ReplayClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay script: %w", err)
+	}
+	var script struct {
+		Responses []ReplayResponse `json:"responses"`
+	}
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse replay script %s: %w", path, err)
+	}
+	return &ReplayClient{path: path, responses: script.Responses, model: model, provider: string(clientType)}, nil
+}
+
+// SendChatRequest returns the next scripted response
+func (c *ReplayClient) SendChatRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next >= len(c.responses) {
+		return nil, fmt.Errorf("replay script %s has no response left for request %d", c.path, c.next+1)
+	}
+	scripted := c.responses[c.next]
+	c.next++
+
+	if scripted.Expect != "" {
+		last := ""
+		if len(messages) > 0 {
+			last = messages[len(messages)-1].Content
+		}
+		if !strings.Contains(last, scripted.Expect) {
+			return nil, fmt.Errorf("replay request %d: expected the last message to contain %q, got:\n%s", c.next, scripted.Expect, last)
+		}
+	}
+
+	resp := &ChatResponse{ID: fmt.Sprintf("replay-%d", c.next), Object: "chat.completion", Model: c.model}
+	var choice Choice
+	choice.Message.Role = "assistant"
+	choice.Message.Content = scripted.Content
+	choice.FinishReason = "stop"
+	for i, call := range scripted.ToolCalls {
+		arguments, err := json.Marshal(call.Arguments)
+		if err != nil {
+			return nil, fmt.Errorf("replay request %d: bad arguments for %s: %w", c.next, call.Name, err)
+		}
+		var toolCall ToolCall
+		toolCall.ID = fmt.Sprintf("replay_%d_%d", c.next, i)
+		toolCall.Type = "function"
+		toolCall.Function.Name = call.Name
+		toolCall.Function.Arguments = string(arguments)
+		choice.Message.ToolCalls = append(choice.Message.ToolCalls, toolCall)
+		choice.FinishReason = "tool_calls"
+	}
+	resp.Choices = []Choice{choice}
+	resp.Usage.PromptTokens = 100
+	resp.Usage.CompletionTokens = 10
+	resp.Usage.TotalTokens = 110
+	return resp, nil
+}
+
+// SendChatRequestStream returns the next scripted response, streaming its
+// content a word at a time
+func (c *ReplayClient) SendChatRequestStream(ctx context.Context, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error) {
+	resp, err := c.SendChatRequest(ctx, messages, tools, reasoning)
+	if err != nil || onToken == nil {
+		return resp, err
+	}
+	content := resp.Choices[0].Message.Content
+	for len(content) > 0 {
+		end := strings.IndexAny(content[1:], " \n") + 1
+		if end == 0 {
+			end = len(content)
+		}
+		onToken(content[:end], "")
+		content = content[end:]
+	}
+	return resp, nil
+}
+
+// SendVisionRequest is answered from the script like any other request
+func (c *ReplayClient) SendVisionRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+	return c.SendChatRequest(ctx, messages, tools, reasoning)
+}
+
+// CheckConnection always succeeds
+func (c *ReplayClient) CheckConnection() error { return nil }
+
+// SetDebug is a no-op
+func (c *ReplayClient) SetDebug(debug bool) {}
+
+// SetModel sets the reported model
+func (c *ReplayClient) SetModel(model string) error {
+	c.model = model
+	return nil
+}
+
+// GetModel returns the reported model
+func (c *ReplayClient) GetModel() string { return c.model }
+
+// GetProvider returns the provider the replay stands in for
+func (c *ReplayClient) GetProvider() string { return c.provider }
+
+// GetModelContextLimit returns a typical context window
+func (c *ReplayClient) GetModelContextLimit() (int, error) { return 128000, nil }
+
+// SupportsVision reports false; vision requests would need scripted image analysis
+func (c *ReplayClient) SupportsVision() bool { return false }
+
+// GetVisionModel returns no vision model
+func (c *ReplayClient) GetVisionModel() string { return "" }
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// ResponseSchema describes the JSON document a structured-output request must return
+type ResponseSchema = types.ResponseSchema
+
+// StructuredOutputClient is implemented by clients that can constrain responses
+// to a JSON schema natively (response_format). SetResponseFormat reports whether
+// the current model honours the constraint; nil clears it.
+type StructuredOutputClient interface {
+	SetResponseFormat(format map[string]interface{}) bool
+}
+
+// ErrInvalidStructuredOutput is returned when the model did not produce a
+// document matching the schema, even after being asked to correct it
+var ErrInvalidStructuredOutput = errors.New("model response does not match the requested schema")
+
+// RequestStructured sends messages and decodes the model's answer into out after
+// validating it against schema. Providers with native JSON-schema support are
+// constrained via response_format; all others are instructed through the prompt.
+// An invalid answer is sent back once with the validation error for correction.
+// The returned response holds the last raw answer and the usage of all attempts.
+func RequestStructured(ctx context.Context, client ClientInterface, messages []Message, schema ResponseSchema, vision bool, out interface{}) (*ChatResponse, error) {
+	native := false
+	if structured, ok := client.(StructuredOutputClient); ok {
+		native = structured.SetResponseFormat(schema.ResponseFormat())
+		defer structured.SetResponseFormat(nil)
+	}
+
+	schemaJSON, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	// The instruction goes on the last message so image attachments stay with their prompt
+	conversation := make([]Message, len(messages))
+	copy(conversation, messages)
+	if len(conversation) > 0 {
+		last := &conversation[len(conversation)-1]
+		last.Content += fmt.Sprintf("\n\nRespond with only a JSON document (no prose, no code fences) matching this JSON schema:\n%s", schemaJSON)
+	}
+
+	send := client.SendChatRequest
+	if vision {
+		send = client.SendVisionRequest
+	}
+
+	var total *ChatResponse
+	const maxAttempts = 2
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		resp, err := send(ctx, conversation, nil, "")
+		if err != nil {
+			return total, err
+		}
+		total = addUsage(total, resp)
+		if len(resp.Choices) == 0 {
+			return total, fmt.Errorf("no response from model")
+		}
+
+		content := resp.Choices[0].Message.Content
+		document, err := ExtractJSON(content)
+		if err == nil {
+			err = ValidateJSON(document, schema.Schema)
+		}
+		if err == nil {
+			if err := json.Unmarshal(document, out); err != nil {
+				return total, fmt.Errorf("failed to decode %s: %w", schema.Name, err)
+			}
+			return total, nil
+		}
+
+		if attempt == maxAttempts {
+			return total, fmt.Errorf("%w (%s, native=%t): %v", ErrInvalidStructuredOutput, schema.Name, native, err)
+		}
+		conversation = append(conversation,
+			Message{Role: "assistant", Content: content},
+			Message{Role: "user", Content: fmt.Sprintf("That response is invalid: %v. Reply with only the corrected JSON document.", err)},
+		)
+	}
+	return total, nil
+}
+
+// addUsage returns resp with the token usage of earlier attempts added to it
+func addUsage(total, resp *ChatResponse) *ChatResponse {
+	if total == nil {
+		return resp
+	}
+	resp.Usage.PromptTokens += total.Usage.PromptTokens
+	resp.Usage.CompletionTokens += total.Usage.CompletionTokens
+	resp.Usage.TotalTokens += total.Usage.TotalTokens
+	resp.Usage.EstimatedCost += total.Usage.EstimatedCost
+	return resp
+}
+
+// ExtractJSON returns the JSON document in a model answer, tolerating code
+// fences and surrounding prose
+func ExtractJSON(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+	if json.Valid([]byte(text)) {
+		return []byte(text), nil
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start >= 0 {
+		closing := "}"
+		if text[start] == '[' {
+			closing = "]"
+		}
+		if end := strings.LastIndex(text, closing); end > start && json.Valid([]byte(text[start:end+1])) {
+			return []byte(text[start : end+1]), nil
+		}
+	}
+	return nil, fmt.Errorf("no JSON document found in response")
+}
+
+// ValidateJSON checks a document against the subset of JSON Schema used by our
+// internal flows: type, enum, properties, required, additionalProperties and items
+func ValidateJSON(document []byte, schema map[string]interface{}) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return validateValue(value, schema, "$")
+}
+
+// validateValue validates a decoded value against a schema node
+func validateValue(value interface{}, schema map[string]interface{}, path string) error {
+	if names := schemaTypes(schema["type"]); len(names) > 0 {
+		matched := false
+		for _, t := range names {
+			if jsonTypeMatches(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s must be of type %s", path, strings.Join(names, " or "))
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		allowed := false
+		for _, option := range toSlice(enum) {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%s must be one of %v", path, toSlice(enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range toSlice(schema["required"]) {
+			if _, ok := v[fmt.Sprint(name)]; !ok {
+				return fmt.Errorf("%s is missing required field %q", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, field := range v {
+			propertySchema, known := properties[name].(map[string]interface{})
+			if !known {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s has unexpected field %q", path, name)
+				}
+				continue
+			}
+			if err := validateValue(field, propertySchema, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateValue(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaTypes normalizes the "type" keyword, which may be a string or a list
+func schemaTypes(t interface{}) []string {
+	var names []string
+	for _, v := range toSlice(t) {
+		names = append(names, fmt.Sprint(v))
+	}
+	return names
+}
+
+// toSlice accepts the list shapes a schema written in Go or decoded from JSON may use
+func toSlice(v interface{}) []interface{} {
+	switch list := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return list
+	case []string:
+		result := make([]interface{}, len(list))
+		for i, s := range list {
+			result[i] = s
+		}
+		return result
+	default:
+		return []interface{}{list}
+	}
+}
+
+// jsonTypeMatches reports whether a decoded JSON value has the given schema type
+func jsonTypeMatches(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encodings a Tokenizer implements, named as in tiktoken
+const (
+	EncodingCL100K = "cl100k_base" // GPT-4 and GPT-3.5; the approximation for other models
+	EncodingO200K  = "o200k_base"  // GPT-4o and later, o-series and gpt-oss
+)
+
+const (
+	maxBPEPiece     = 512  // Longer pieces, such as base64 blobs, are encoded in chunks
+	minCachedText   = 256  // Counts of shorter texts aren't cached
+	maxCachedCounts = 4096 // The count cache is cleared when it grows past this
+	maxRank         = int(^uint(0) >> 1)
+)
+
+// Tokenizer counts tokens like tiktoken's byte-pair encodings. Text is split
+// into pieces by the encoding's pattern, then each piece is merged by rank.
+// Without rank data (see NewTokenizer) pieces are counted by a heuristic that
+// knows words, identifiers, CJK text and punctuation, which is much closer
+// than characters/4 but not exact.
+type Tokenizer struct {
+	encoding string
+	ranks    map[string]int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// EncodingForModel returns the encoding of a model. Models that don't use an
+// OpenAI encoding get cl100k_base, the closest general-purpose match.
+func EncodingForModel(model string) string {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-oss", "chatgpt", "o1", "o3", "o4"} {
+		if strings.HasPrefix(name, prefix) {
+			return EncodingO200K
+		}
+	}
+	return EncodingCL100K
+}
+
+// NewTokenizer creates a tokenizer for an encoding from its rank data, in
+// tiktoken's format: one base64 token and its rank per line. With nil ranks
+// the tokenizer approximates counts.
+func NewTokenizer(encoding string, ranks io.Reader) (*Tokenizer, error) {
+	if encoding != EncodingCL100K && encoding != EncodingO200K {
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	t := &Tokenizer{encoding: encoding, counts: make(map[string]int)}
+	if ranks == nil {
+		return t, nil
+	}
+
+	t.ranks = make(map[string]int)
+	scanner := bufio.NewScanner(ranks)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid %s rank on line %d", encoding, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s token on line %d: %w", encoding, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s rank on line %d: %w", encoding, line, err)
+		}
+		t.ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s ranks: %w", encoding, err)
+	}
+	if len(t.ranks) == 0 {
+		return nil, fmt.Errorf("no %s ranks found", encoding)
+	}
+	return t, nil
+}
+
+// Encoding returns the name of the tokenizer's encoding
+func (t *Tokenizer) Encoding() string {
+	return t.encoding
+}
+
+// Exact reports whether counts come from the encoding's ranks rather than
+// the heuristic
+func (t *Tokenizer) Exact() bool {
+	return t.ranks != nil
+}
+
+// Count returns the number of tokens in text. Special tokens such as
+// <|endoftext|> are counted as ordinary text.
+func (t *Tokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	cached := len(text) >= minCachedText
+	if cached {
+		t.mu.Lock()
+		n, ok := t.counts[text]
+		t.mu.Unlock()
+		if ok {
+			return n
+		}
+	}
+
+	runes := []rune(text)
+	var piece []byte
+	total := 0
+	for i := 0; i < len(runes); {
+		end := t.nextPiece(runes, i)
+		if t.ranks == nil {
+			total += approximatePieceTokens(runes[i:end])
+		} else {
+			piece = piece[:0]
+			for _, r := range runes[i:end] {
+				piece = utf8.AppendRune(piece, r)
+			}
+			for len(piece) > maxBPEPiece {
+				total += t.bpeCount(piece[:maxBPEPiece])
+				piece = piece[maxBPEPiece:]
+			}
+			total += t.bpeCount(piece)
+		}
+		i = end
+	}
+
+	if cached {
+		t.mu.Lock()
+		if len(t.counts) >= maxCachedCounts {
+			t.counts = make(map[string]int)
+		}
+		t.counts[text] = total
+		t.mu.Unlock()
+	}
+	return total
+}
+
+// bpeCount merges the bytes of a piece, lowest rank first, and returns the
+// number of tokens left
+func (t *Tokenizer) bpeCount(piece []byte) int {
+	if len(piece) <= 1 {
+		return len(piece)
+	}
+	if _, ok := t.ranks[string(piece)]; ok {
+		return 1
+	}
+
+	// bounds[i] is where part i starts; merging parts i and i+1 drops bounds[i+1]
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := maxRank, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && rank < best {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// nextPiece returns where the piece starting at i ends, following the
+// encoding's split pattern. Go's regexp has no lookahead for the pattern's
+// \s+(?!\S), so the alternatives are matched by hand, in the pattern's order.
+//
+// cl100k_base: (?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// o200k_base splits words at case changes and keeps contractions with them:
+// [^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|...)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|...)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func (t *Tokenizer) nextPiece(runes []rune, i int) int {
+	r := runes[i]
+	o200k := t.encoding == EncodingO200K
+	if !o200k && r == '\'' {
+		if end := contraction(runes, i); end > i {
+			return end
+		}
+	}
+
+	// Words, with an optional leading space or symbol
+	start := i
+	canPrefix := !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\r' && r != '\n'
+	if o200k {
+		if canPrefix && i+1 < len(runes) && isWordRune(runes[i+1]) {
+			start = i + 1
+		}
+		if end := o200kWord(runes, start); end > start {
+			return end
+		}
+	} else {
+		if canPrefix && i+1 < len(runes) && unicode.IsLetter(runes[i+1]) {
+			start = i + 1
+		}
+		if unicode.IsLetter(runes[start]) {
+			return scanRunes(runes, start, unicode.IsLetter)
+		}
+	}
+
+	if unicode.IsNumber(r) {
+		end := i
+		for end < len(runes) && end-i < 3 && unicode.IsNumber(runes[end]) {
+			end++
+		}
+		return end
+	}
+
+	// Symbols, with an optional leading space and trailing newlines
+	start = i
+	if r == ' ' && i+1 < len(runes) && isSymbolRune(runes[i+1]) {
+		start = i + 1
+	}
+	if isSymbolRune(runes[start]) {
+		end := scanRunes(runes, start, isSymbolRune)
+		return scanRunes(runes, end, func(r rune) bool { return r == '\r' || r == '\n' || (o200k && r == '/') })
+	}
+
+	// Whitespace: up to its last newline, else all but the space before the next word
+	end := scanRunes(runes, i, unicode.IsSpace)
+	for k := end - 1; k >= i; k-- {
+		if runes[k] == '\r' || runes[k] == '\n' {
+			return k + 1
+		}
+	}
+	if end == len(runes) || end-i == 1 {
+		return end
+	}
+	return end - 1
+}
+
+// o200kWord matches o200k_base's two word alternatives at start and returns
+// where the word ends, or start if there is none
+func o200kWord(runes []rune, start int) int {
+	upper := scanRunes(runes, start, isUpperWordRune)
+	// [upper]*[lower]+, giving back upper runes that are also lower (Lm, Lo, M)
+	for p := upper; p >= start; p-- {
+		if p < len(runes) && isLowerWordRune(runes[p]) {
+			return withContraction(runes, scanRunes(runes, p, isLowerWordRune))
+		}
+	}
+	// [upper]+[lower]*
+	if upper > start {
+		return withContraction(runes, scanRunes(runes, upper, isLowerWordRune))
+	}
+	return start
+}
+
+// withContraction extends a word ending at end by a contraction that follows it
+func withContraction(runes []rune, end int) int {
+	if end < len(runes) && runes[end] == '\'' {
+		if after := contraction(runes, end); after > end {
+			return after
+		}
+	}
+	return end
+}
+
+// contraction returns the end of 's, 't, 're, 've, 'm, 'll or 'd (in any
+// case) at i, or i if there is none
+func contraction(runes []rune, i int) int {
+	for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		end := i + 1
+		for _, c := range suffix {
+			if end >= len(runes) || unicode.ToLower(runes[end]) != c {
+				end = -1
+				break
+			}
+			end++
+		}
+		if end > 0 {
+			return end
+		}
+	}
+	return i
+}
+
+// scanRunes returns the index of the first rune from i that doesn't match
+func scanRunes(runes []rune, i int, match func(rune) bool) int {
+	for i < len(runes) && match(runes[i]) {
+		i++
+	}
+	return i
+}
+
+// isUpperWordRune is o200k_base's [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperWordRune(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerWordRune is o200k_base's [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerWordRune(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isWordRune reports whether r can start an o200k_base word
+func isWordRune(r rune) bool {
+	return isUpperWordRune(r) || isLowerWordRune(r)
+}
+
+// isSymbolRune is [^\s\p{L}\p{N}]
+func isSymbolRune(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// approximatePieceTokens estimates the tokens of one piece without ranks.
+// Common words are one token; identifiers cost about one token per camel-case
+// hump of up to 8 letters; CJK characters one each; other scripts one per two
+// letters; runs of symbols and digits one per three.
+func approximatePieceTokens(piece []rune) int {
+	tokens, hump, wide, letters, symbols, digits := 0, 0, 0, 0, 0, 0
+	for i, r := range piece {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			if hump > 0 && unicode.IsUpper(r) && unicode.IsLower(piece[i-1]) {
+				tokens += (hump + 7) / 8
+				hump = 0
+			}
+			hump++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			wide++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			letters++
+		case unicode.IsNumber(r):
+			digits++
+		case unicode.IsSpace(r):
+		default:
+			symbols++
+		}
+	}
+	tokens += (hump+7)/8 + wide + (letters+1)/2 + (symbols+2)/3 + (digits+2)/3
+	if tokens == 0 {
+		return 1 // whitespace
+	}
+	return tokens
+}
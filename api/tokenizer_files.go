@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// tokenizerURL is where tiktoken publishes the rank files of its encodings
+const tokenizerURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+// tokenizerHashes are the SHA-256 sums tiktoken checks its downloads against
+var tokenizerHashes = map[string]string{
+	EncodingCL100K: "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	EncodingO200K:  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+// tokenizers holds one tokenizer per encoding. An approximate tokenizer is
+// used until the encoding's rank file has loaded in the background.
+var tokenizers = struct {
+	sync.Mutex
+	dir        string // Where rank files are kept; "" only approximates
+	download   bool   // Fetch missing rank files from tokenizerURL
+	byEncoding map[string]*Tokenizer
+	loading    map[string]bool
+	errors     map[string]error
+}{byEncoding: make(map[string]*Tokenizer), loading: make(map[string]bool), errors: make(map[string]error)}
+
+// UseTokenizerFiles makes exact tokenizers load their rank files from dir,
+// as <encoding>.tiktoken, downloading missing ones if download is set
+func UseTokenizerFiles(dir string, download bool) {
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	tokenizers.dir, tokenizers.download = dir, download
+}
+
+// TokenizerForModel returns the tokenizer of a model's encoding. The first
+// call for an encoding starts loading its rank file; counts are approximate
+// until it is loaded, or if it can't be.
+func TokenizerForModel(model string) *Tokenizer {
+	encoding := EncodingForModel(model)
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	tokenizer, ok := tokenizers.byEncoding[encoding]
+	if !ok {
+		tokenizer, _ = NewTokenizer(encoding, nil)
+		tokenizers.byEncoding[encoding] = tokenizer
+	}
+	if tokenizers.dir != "" && !tokenizers.loading[encoding] {
+		tokenizers.loading[encoding] = true
+		go loadTokenizer(encoding, tokenizers.dir, tokenizers.download)
+	}
+	return tokenizer
+}
+
+// CountTokens counts the tokens of text with a model's tokenizer
+func CountTokens(model, text string) int {
+	return TokenizerForModel(model).Count(text)
+}
+
+// TokenizerStatus describes how a model's tokens are counted, e.g. for /info
+func TokenizerStatus(model string) string {
+	tokenizer := TokenizerForModel(model)
+	if tokenizer.Exact() {
+		return fmt.Sprintf("exact (%s)", tokenizer.Encoding())
+	}
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	if err := tokenizers.errors[tokenizer.Encoding()]; err != nil {
+		return fmt.Sprintf("approximate (%s: %v)", tokenizer.Encoding(), err)
+	}
+	return fmt.Sprintf("approximate (%s)", tokenizer.Encoding())
+}
+
+// loadTokenizer reads, or downloads, the rank file of an encoding and swaps
+// the exact tokenizer in
+func loadTokenizer(encoding, dir string, download bool) {
+	path := filepath.Join(dir, encoding+".tiktoken")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && download {
+		if data, err = downloadTokenizer(encoding); err == nil {
+			err = saveTokenizer(path, data)
+		}
+	}
+	var tokenizer *Tokenizer
+	if err == nil {
+		tokenizer, err = NewTokenizer(encoding, bytes.NewReader(data))
+	}
+
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	if err != nil {
+		tokenizers.errors[encoding] = err
+		return
+	}
+	tokenizers.byEncoding[encoding] = tokenizer
+}
+
+// downloadTokenizer fetches the rank file of an encoding and verifies it
+func downloadTokenizer(encoding string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", tokenizerURL+encoding+".tiktoken", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the %s tokenizer: %w", encoding, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download the %s tokenizer: %s", encoding, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the %s tokenizer: %w", encoding, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != tokenizerHashes[encoding] {
+		return nil, fmt.Errorf("the downloaded %s tokenizer doesn't match its checksum", encoding)
+	}
+	return data, nil
+}
+
+// saveTokenizer writes a rank file atomically, so an interrupted write
+// isn't read back later
+func saveTokenizer(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create the tokenizer directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save the tokenizer: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"github.com/alantheprice/coder/providers"
 	"github.com/alantheprice/coder/types"
 )
@@ -18,8 +19,30 @@ func NewUnifiedProviderWrapper(provider types.ProviderInterface) *UnifiedProvide
 }
 
 // SendChatRequest converts types and forwards to provider
-func (w *UnifiedProviderWrapper) SendChatRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
-	// Convert API types to shared types
+func (w *UnifiedProviderWrapper) SendChatRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+	response, err := w.provider.SendChatRequest(ctx, toProviderMessages(messages), toProviderTools(tools), reasoning)
+	if err != nil {
+		return nil, err
+	}
+	return fromProviderResponse(response), nil
+}
+
+// SendChatRequestStream streams the response from providers that support it.
+// Other providers answer in one piece, which is passed to onToken whole.
+func (w *UnifiedProviderWrapper) SendChatRequestStream(ctx context.Context, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error) {
+	streaming, ok := w.provider.(types.StreamingProvider)
+	if !ok {
+		return sendUnstreamed(ctx, w, messages, tools, reasoning, onToken)
+	}
+	response, err := streaming.SendChatRequestStream(ctx, toProviderMessages(messages), toProviderTools(tools), reasoning, onToken)
+	if err != nil {
+		return nil, err
+	}
+	return fromProviderResponse(response), nil
+}
+
+// toProviderMessages converts API messages to shared types
+func toProviderMessages(messages []Message) []types.Message {
 	typeMessages := make([]types.Message, len(messages))
 	for i, msg := range messages {
 		// Convert image data
@@ -39,7 +62,11 @@ func (w *UnifiedProviderWrapper) SendChatRequest(messages []Message, tools []Too
 			Images:           typeImages,
 		}
 	}
+	return typeMessages
+}
 
+// toProviderTools converts API tool definitions to shared types
+func toProviderTools(tools []Tool) []types.Tool {
 	typeTools := make([]types.Tool, len(tools))
 	for i, tool := range tools {
 		typeTools[i] = types.Tool{
@@ -55,14 +82,11 @@ func (w *UnifiedProviderWrapper) SendChatRequest(messages []Message, tools []Too
 			},
 		}
 	}
+	return typeTools
+}
 
-	// Call provider
-	response, err := w.provider.SendChatRequest(typeMessages, typeTools, reasoning)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert response back to API types
+// fromProviderResponse converts a provider response to API types
+func fromProviderResponse(response *types.ChatResponse) *ChatResponse {
 	apiResponse := &ChatResponse{
 		ID:      response.ID,
 		Object:  response.Object,
@@ -141,7 +165,25 @@ func (w *UnifiedProviderWrapper) SendChatRequest(messages []Message, tools []Too
 		}
 	}
 
-	return apiResponse, nil
+	return apiResponse
+}
+
+// SetResponseFormat forwards a JSON-schema constraint to providers that support it
+func (w *UnifiedProviderWrapper) SetResponseFormat(format map[string]interface{}) bool {
+	structured, ok := w.provider.(types.StructuredOutputProvider)
+	if ok {
+		structured.SetResponseFormat(format)
+	}
+	return ok
+}
+
+// SetRequestParameters forwards a parameter profile to providers that support it
+func (w *UnifiedProviderWrapper) SetRequestParameters(params map[string]interface{}) bool {
+	configurable, ok := w.provider.(types.RequestParametersProvider)
+	if ok {
+		configurable.SetRequestParameters(params)
+	}
+	return ok
 }
 
 // Forward all other methods to the provider
@@ -194,150 +236,129 @@ func (w *UnifiedProviderWrapper) GetVisionModel() string {
 		return GetVisionModelForProvider(GroqClientType)
 	case "deepseek":
 		return GetVisionModelForProvider(DeepSeekClientType)
+	case "azure":
+		return GetVisionModelForProvider(AzureOpenAIClientType)
+	case "bedrock":
+		return GetVisionModelForProvider(BedrockClientType)
+	case "mistral":
+		return GetVisionModelForProvider(MistralClientType)
+	case "xai":
+		return GetVisionModelForProvider(XAIClientType)
+	case "huggingface":
+		return GetVisionModelForProvider(HuggingFaceClientType)
+	case "anthropic":
+		return GetVisionModelForProvider(AnthropicClientType)
+	case "openai":
+		return GetVisionModelForProvider(OpenAIClientType)
+	case "gemini":
+		return GetVisionModelForProvider(GeminiClientType)
 	default:
 		return ""
 	}
 }
 
-func (w *UnifiedProviderWrapper) SendVisionRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
-	// Convert API types to shared types
-	typeMessages := make([]types.Message, len(messages))
-	for i, msg := range messages {
-		// Convert image data
-		typeImages := make([]types.ImageData, len(msg.Images))
-		for j, img := range msg.Images {
-			typeImages[j] = types.ImageData{
-				URL:    img.URL,
-				Base64: img.Base64,
-				Type:   img.Type,
-			}
-		}
-		
-		typeMessages[i] = types.Message{
-			Role:             msg.Role,
-			Content:          msg.Content,
-			ReasoningContent: msg.ReasoningContent,
-			Images:           typeImages,
-		}
+func (w *UnifiedProviderWrapper) SendVisionRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error) {
+	response, err := w.provider.SendVisionRequest(ctx, toProviderMessages(messages), toProviderTools(tools), reasoning)
+	if err != nil {
+		return nil, err
 	}
+	return fromProviderResponse(response), nil
+}
 
-	typeTools := make([]types.Tool, len(tools))
-	for i, tool := range tools {
-		typeTools[i] = types.Tool{
-			Type: tool.Type,
-			Function: struct {
-				Name        string      `json:"name"`
-				Description string      `json:"description"`
-				Parameters  interface{} `json:"parameters"`
-			}{
-				Name:        tool.Function.Name,
-				Description: tool.Function.Description,
-				Parameters:  tool.Function.Parameters,
-			},
-		}
+// Factory functions for creating providers
+func NewOpenRouterProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewOpenRouterProviderWithModel(model)
+	if err != nil {
+		return nil, err
 	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
 
-	// Call provider vision method
-	response, err := w.provider.SendVisionRequest(typeMessages, typeTools, reasoning)
+func NewGroqProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewGroqProviderWithModel(model)
 	if err != nil {
 		return nil, err
 	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
 
-	// Convert response back to API types (same as SendChatRequest)
-	apiResponse := &ChatResponse{
-		ID:      response.ID,
-		Object:  response.Object,
-		Created: response.Created,
-		Model:   response.Model,
-		Usage: struct {
-			PromptTokens     int     `json:"prompt_tokens"`
-			CompletionTokens int     `json:"completion_tokens"`
-			TotalTokens      int     `json:"total_tokens"`
-			EstimatedCost    float64 `json:"estimated_cost"`
-			PromptTokensDetails struct {
-				CachedTokens     int `json:"cached_tokens"`
-				CacheWriteTokens *int `json:"cache_write_tokens"`
-			} `json:"prompt_tokens_details,omitempty"`
-		}{
-			PromptTokens:     response.Usage.PromptTokens,
-			CompletionTokens: response.Usage.CompletionTokens,
-			TotalTokens:      response.Usage.TotalTokens,
-			EstimatedCost:    response.Usage.EstimatedCost,
-			PromptTokensDetails: struct {
-				CachedTokens     int `json:"cached_tokens"`
-				CacheWriteTokens *int `json:"cache_write_tokens"`
-			}{
-				CachedTokens:     response.Usage.PromptTokensDetails.CachedTokens,
-				CacheWriteTokens: response.Usage.PromptTokensDetails.CacheWriteTokens,
-			},
-		},
+func NewDeepSeekProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewDeepSeekProviderWithModel(model)
+	if err != nil {
+		return nil, err
 	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
 
-	// Convert choices
-	apiResponse.Choices = make([]Choice, len(response.Choices))
-	for i, choice := range response.Choices {
-		// Convert response message images
-		responseImages := make([]ImageData, len(choice.Message.Images))
-		for j, img := range choice.Message.Images {
-			responseImages[j] = ImageData{
-				URL:    img.URL,
-				Base64: img.Base64,
-				Type:   img.Type,
-			}
-		}
-		
-		apiResponse.Choices[i] = Choice{
-			Index: choice.Index,
-			Message: struct {
-				Role             string      `json:"role"`
-				Content          string      `json:"content"`
-				ReasoningContent string      `json:"reasoning_content,omitempty"`
-				Images           []ImageData `json:"images,omitempty"`
-				ToolCalls        []ToolCall  `json:"tool_calls,omitempty"`
-			}{
-				Role:             choice.Message.Role,
-				Content:          choice.Message.Content,
-				ReasoningContent: choice.Message.ReasoningContent,
-				Images:           responseImages,
-			},
-			FinishReason: choice.FinishReason,
-		}
+func NewCerebrasProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewCerebrasProviderWithModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
 
-		// Convert tool calls
-		if len(choice.Message.ToolCalls) > 0 {
-			apiResponse.Choices[i].Message.ToolCalls = make([]ToolCall, len(choice.Message.ToolCalls))
-			for j, toolCall := range choice.Message.ToolCalls {
-				apiResponse.Choices[i].Message.ToolCalls[j] = ToolCall{
-					ID:   toolCall.ID,
-					Type: toolCall.Type,
-					Function: struct {
-						Name      string `json:"name"`
-						Arguments string `json:"arguments"`
-					}{
-						Name:      toolCall.Function.Name,
-						Arguments: toolCall.Function.Arguments,
-					},
-				}
-			}
-		}
+func NewAzureOpenAIProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewAzureOpenAIProviderWithModel(model)
+	if err != nil {
+		return nil, err
 	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
 
-	return apiResponse, nil
+func NewBedrockProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewBedrockProviderWithModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnifiedProviderWrapper(provider), nil
 }
 
-// Factory functions for creating providers
-func NewOpenRouterProvider(model string) (ClientInterface, error) {
-	provider, err := providers.NewOpenRouterProviderWithModel(model)
+func NewMistralProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewMistralProviderWithModel(model)
 	if err != nil {
 		return nil, err
 	}
 	return NewUnifiedProviderWrapper(provider), nil
 }
 
-func NewCerebrasProvider(model string) (ClientInterface, error) {
-	provider, err := providers.NewCerebrasProviderWithModel(model)
+func NewXAIProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewXAIProviderWithModel(model)
 	if err != nil {
 		return nil, err
 	}
 	return NewUnifiedProviderWrapper(provider), nil
-}
\ No newline at end of file
+}
+
+func NewHuggingFaceProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewHuggingFaceProviderWithModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
+
+func NewAnthropicProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewAnthropicProviderWithModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
+
+func NewOpenAIProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewOpenAIProviderWithModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
+
+// NewGeminiProvider creates a Google Gemini provider wrapped to satisfy ClientInterface
+func NewGeminiProvider(model string) (ClientInterface, error) {
+	provider, err := providers.NewGeminiProviderWithModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewUnifiedProviderWrapper(provider), nil
+}
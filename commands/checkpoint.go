@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// CheckpointCommand implements the /checkpoint slash command
+type CheckpointCommand struct{}
+
+// Name returns the command name
+func (c *CheckpointCommand) Name() string {
+	return "checkpoint"
+}
+
+// Description returns the command description
+func (c *CheckpointCommand) Description() string {
+	return "List the working-tree checkpoints taken before each task, or roll back to one (/checkpoint restore <n>)"
+}
+
+// Execute lists, takes or restores checkpoints
+func (c *CheckpointCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	root, err := agent.GitRoot(".")
+	if err != nil {
+		return fmt.Errorf("checkpoints need a git repository")
+	}
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "list":
+		checkpoints, err := agent.ListCheckpoints(root)
+		if err != nil {
+			return err
+		}
+		if len(checkpoints) == 0 {
+			fmt.Println("No checkpoints yet; one is taken before each task")
+			return nil
+		}
+		fmt.Printf("📍 %d checkpoint(s), newest first:\n", len(checkpoints))
+		for i, checkpoint := range checkpoints {
+			fmt.Printf("  %2d. %s  %s  %s\n", i+1, checkpoint.Time.Format("2006-01-02 15:04:05"), checkpoint.Commit[:min(len(checkpoint.Commit), 10)], checkpoint.Label)
+		}
+		fmt.Println("Use /checkpoint restore <n> to put the working tree back to one")
+		return nil
+
+	case "save":
+		checkpoint, created, err := agent.CreateCheckpoint(root, "saved with /checkpoint save", chatAgent.CheckpointLimit())
+		if err != nil {
+			return err
+		}
+		if !created {
+			fmt.Printf("📍 Nothing changed since the checkpoint from %s\n", checkpoint.Time.Format("2006-01-02 15:04:05"))
+			return nil
+		}
+		fmt.Printf("📍 Checkpoint %s saved\n", checkpoint.Commit[:min(len(checkpoint.Commit), 10)])
+		return nil
+
+	case "restore":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: /checkpoint restore <n> (see /checkpoint list)")
+		}
+		n, err := strconv.Atoi(args[1])
+		checkpoints, listErr := agent.ListCheckpoints(root)
+		if listErr != nil {
+			return listErr
+		}
+		if err != nil || n < 1 || n > len(checkpoints) {
+			return fmt.Errorf("no checkpoint %s (see /checkpoint list)", args[1])
+		}
+		checkpoint := checkpoints[n-1]
+		paths, err := chatAgent.RestoreCheckpoint(checkpoint)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			fmt.Println("✅ The working tree already matches that checkpoint")
+			return nil
+		}
+		fmt.Printf("↩️  Restored %d file(s) to the checkpoint from %s:\n", len(paths), checkpoint.Time.Format("2006-01-02 15:04:05"))
+		for _, path := range paths {
+			fmt.Printf("  %s\n", path)
+		}
+		fmt.Println("💡 The state before the restore was checkpointed; /checkpoint restore 1 goes back to it")
+		return nil
+	}
+	return fmt.Errorf("usage: /checkpoint [list|save|restore <n>]")
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const choreUsage = "usage: /chore <name> [param=value ...] (/chore lists the chores)"
+
+// ChoreCommand implements the /chore slash command
+type ChoreCommand struct{}
+
+// Name returns the command name
+func (c *ChoreCommand) Name() string {
+	return "chore"
+}
+
+// Description returns the command description
+func (c *ChoreCommand) Description() string {
+	return "Run a built-in maintenance chore (copyright, go-version, mocks, deadcode) and verify it"
+}
+
+// Execute lists the chores or runs one
+func (c *ChoreCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 {
+		PrintChores()
+		return nil
+	}
+	return RunChore(args, chatAgent)
+}
+
+// PrintChores lists the built-in chores with their parameters
+func PrintChores() {
+	fmt.Println("🧹 Chores (run with coder chore <name> [param=value ...] or /chore):")
+	for _, chore := range agent.Chores() {
+		fmt.Printf("  %-12s %s\n", chore.Name, chore.Description)
+		for _, param := range chore.Params {
+			detail := param.Description
+			switch {
+			case param.Required:
+				detail += " (required)"
+			case param.Default != "":
+				detail += fmt.Sprintf(" (default %s)", param.Default)
+			}
+			fmt.Printf("  %-12s   %s: %s\n", "", param.Name, detail)
+		}
+	}
+}
+
+// RunChore runs the chore named by the first argument with the parameter
+// values of the others and prints the verification
+func RunChore(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 {
+		return fmt.Errorf(choreUsage)
+	}
+	chore, ok := agent.FindChore(args[0])
+	if !ok {
+		var names []string
+		for _, known := range agent.Chores() {
+			names = append(names, known.Name)
+		}
+		return fmt.Errorf("unknown chore '%s'. Chores are: %s", args[0], strings.Join(names, ", "))
+	}
+	values, err := choreValues(chore, args[1:])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🧹 Running chore %s: %s\n", chore.Name, chore.Description)
+	result, err := chatAgent.RunChore(chore, values)
+	if err != nil {
+		return fmt.Errorf("chore %s failed: %w", chore.Name, err)
+	}
+
+	fmt.Println("\n=====================================")
+	if result.Reply != "" {
+		fmt.Println(result.Reply)
+		fmt.Println()
+	}
+	for _, check := range result.Checks {
+		switch {
+		case check.Skipped:
+			fmt.Printf("⏭️  %s: %v\n", check.Command, check.Err)
+		case check.Err != nil:
+			fmt.Printf("❌ %s\n%s\n", check.Command, strings.TrimSpace(check.Output))
+		default:
+			fmt.Printf("✅ %s\n", check.Command)
+		}
+	}
+	if !result.Passed() {
+		return fmt.Errorf("chore %s is done but its verification still fails after %d fix round(s)", chore.Name, result.FixRounds)
+	}
+	fmt.Printf("✅ Chore %s finished and verified\n", chore.Name)
+	return nil
+}
+
+// choreValues reads name=value arguments. A value without a name belongs to
+// the first required parameter (or the only parameter); words following a
+// name=value continue its value, so "holder=Acme Inc" works unquoted.
+func choreValues(chore agent.Chore, args []string) (map[string]string, error) {
+	values := make(map[string]string)
+	last := ""
+	for _, arg := range args {
+		if name, value, ok := strings.Cut(arg, "="); ok && name != "" {
+			values[name], last = value, name
+			continue
+		}
+		if last != "" {
+			values[last] += " " + arg
+			continue
+		}
+		positional := ""
+		for _, param := range chore.Params {
+			if param.Required {
+				positional = param.Name
+				break
+			}
+		}
+		if positional == "" && len(chore.Params) == 1 {
+			positional = chore.Params[0].Name
+		}
+		if positional == "" {
+			return nil, fmt.Errorf("pass the parameters of chore %s as name=value, got %q", chore.Name, arg)
+		}
+		values[positional], last = arg, positional
+	}
+	return values, nil
+}
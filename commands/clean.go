@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/config"
+)
+
+// CleanCommand implements the /clean slash command
+type CleanCommand struct{}
+
+// Name returns the command name
+func (c *CleanCommand) Name() string {
+	return "clean"
+}
+
+// Description returns the command description
+func (c *CleanCommand) Description() string {
+	return "Remove the state and temp files coder wrote to this repository (shared files like policies stay)"
+}
+
+// Execute removes the local artifacts in the project directory and any left at the repository root
+func (c *CleanCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	removed, err := config.CleanProjectArtifacts(wd)
+	for _, path := range removed {
+		fmt.Printf("🗑️  Removed %s\n", path)
+	}
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		fmt.Println("✅ No coder artifacts in this repository")
+		return nil
+	}
+	fmt.Printf("✅ Removed %d artifact(s)\n", len(removed))
+	return nil
+}
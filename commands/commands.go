@@ -36,6 +36,27 @@ func NewCommandRegistry() *CommandRegistry {
 	registry.Register(&ExecCommand{})
 	registry.Register(&ShellCommand{})
 	registry.Register(&InfoCommand{})
+	registry.Register(&TicketCommand{})
+	registry.Register(&WhatChangedCommand{})
+	registry.Register(&OptimizeCommand{})
+	registry.Register(&CompactCommand{})
+	registry.Register(&JobsCommand{})
+	registry.Register(&VoiceCommand{})
+	registry.Register(&PipelineCommand{})
+	registry.Register(&ModeCommand{})
+	registry.Register(&CostCommand{})
+	registry.Register(&RecallCommand{})
+	registry.Register(&CleanCommand{})
+	registry.Register(&MigrateCommand{})
+	registry.Register(&DoctorCommand{})
+	registry.Register(&ToolsCommand{})
+	registry.Register(&UnloadCommand{})
+	registry.Register(&SessionCommand{})
+	registry.Register(&DiffCommand{})
+	registry.Register(&UndoCommand{})
+	registry.Register(&CheckpointCommand{})
+	registry.Register(&ChoreCommand{})
+	registry.Register(&ExplainCommand{})
 
 	return registry
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/api"
 )
 
 // CommitCommand implements the /commit slash command
@@ -30,6 +31,8 @@ func (c *CommitCommand) Execute(args []string, chatAgent *agent.Agent) error {
 		switch args[0] {
 		case "single", "one", "file":
 			return c.executeSingleFileCommit(args[1:], chatAgent)
+		case "session", "--from-session":
+			return c.executeMultiFileCommit(chatAgent, true)
 		case "help", "--help", "-h":
 			return c.showHelp()
 		default:
@@ -38,11 +41,18 @@ func (c *CommitCommand) Execute(args []string, chatAgent *agent.Agent) error {
 	}
 
 	// Default behavior: multi-file commit
-	return c.executeMultiFileCommit(chatAgent)
+	fromSession := chatAgent.GetConfigManager().GetConfig().GetBoolPreference(prefCommitFromSession, false)
+	return c.executeMultiFileCommit(chatAgent, fromSession)
 }
 
-// executeMultiFileCommit handles the original multi-file commit workflow
-func (c *CommitCommand) executeMultiFileCommit(chatAgent *agent.Agent) error {
+// prefCommitFromSession makes /commit describe the agent's changes from the
+// session's change records instead of a full staged diff
+const prefCommitFromSession = "commit_from_session"
+
+// executeMultiFileCommit handles the original multi-file commit workflow. With
+// fromSession, the message is written from the session's change records when
+// they account for everything staged.
+func (c *CommitCommand) executeMultiFileCommit(chatAgent *agent.Agent, fromSession bool) error {
 	fmt.Println("🚀 Starting interactive commit workflow...")
 	fmt.Println("=============================================")
 
@@ -182,9 +192,16 @@ Staged changes:
 %s
 
 Please generate only the commit message content, no additional commentary.`, string(diffOutput))
+	if fromSession {
+		if prompt, ok := sessionCommitPrompt(chatAgent); ok {
+			commitPrompt = prompt
+		} else {
+			fmt.Println("📝 The session's change records don't cover everything staged - using the staged diff")
+		}
+	}
 
 	fmt.Println("🤖 Generating commit message with AI...")
-	commitMessage, err := chatAgent.ProcessQuery(commitPrompt)
+	commitMessage, err := generateCommitMessage(chatAgent, commitPrompt)
 	if err != nil {
 		return fmt.Errorf("failed to generate commit message: %v", err)
 	}
@@ -209,17 +226,15 @@ Please generate only the commit message content, no additional commentary.`, str
 	fmt.Println("\n💾 Creating commit...")
 	
 	// Write commit message to temporary file
-	tempFile := "commit_msg.txt"
-	err = os.WriteFile(tempFile, []byte(commitMessage), 0644)
+	tempFile, err := writeTempMessageFile(commitMessage)
 	if err != nil {
 		return fmt.Errorf("failed to create temporary commit message file: %v", err)
 	}
 	defer os.Remove(tempFile)
 
-	cmd := exec.Command("git", "commit", "-F", tempFile)
-	output, err := cmd.CombinedOutput()
+	output, err := commitWithHooks(chatAgent, reader, tempFile)
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %v", err)
+		return err
 	}
 
 	fmt.Printf("✅ Commit created successfully!\n")
@@ -339,7 +354,7 @@ Staged changes for %s:
 Please generate only the commit message content, no additional commentary.`, fileToAdd, fileToAdd, string(diffOutput))
 
 	fmt.Println("🤖 Generating commit message with AI...")
-	commitMessage, err := chatAgent.ProcessQuery(commitPrompt)
+	commitMessage, err := generateCommitMessage(chatAgent, commitPrompt)
 	if err != nil {
 		return fmt.Errorf("failed to generate commit message: %v", err)
 	}
@@ -364,17 +379,15 @@ Please generate only the commit message content, no additional commentary.`, fil
 	fmt.Println("\n💾 Creating commit...")
 	
 	// Write commit message to temporary file
-	tempFile := "commit_msg.txt"
-	err = os.WriteFile(tempFile, []byte(commitMessage), 0644)
+	tempFile, err := writeTempMessageFile(commitMessage)
 	if err != nil {
 		return fmt.Errorf("failed to create temporary commit message file: %v", err)
 	}
 	defer os.Remove(tempFile)
 
-	cmd = exec.Command("git", "commit", "-F", tempFile)
-	output, err = cmd.CombinedOutput()
+	output, err = commitWithHooks(chatAgent, reader, tempFile)
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %v", err)
+		return err
 	}
 
 	fmt.Printf("✅ Commit created successfully for %s!\n", fileToAdd)
@@ -390,6 +403,8 @@ func (c *CommitCommand) showHelp() error {
 ========================
 
 /commit          - Interactive multi-file commit workflow
+/commit session  - Multi-file workflow, with the message written from the
+                   session's change records instead of the full staged diff
 /commit single   - Single file commit workflow
 /commit one      - Single file commit workflow (alias)
 /commit file     - Single file commit workflow (alias)
@@ -410,6 +425,37 @@ Multi-file workflow:
 	return nil
 }
 
+// sessionCommitPrompt builds the commit message prompt from what the agent
+// recorded doing to the staged files, when that accounts for all of them
+func sessionCommitPrompt(chatAgent *agent.Agent) (string, bool) {
+	output, err := exec.Command("git", "diff", "--staged", "--name-only", "--relative").Output()
+	if err != nil {
+		return "", false
+	}
+	files := strings.Fields(string(output))
+	context, ok := chatAgent.CommitContext(files)
+	if !ok {
+		return "", false
+	}
+	fmt.Printf("📝 Using the session's change records for %d staged file(s) instead of the full diff\n", len(files))
+	return fmt.Sprintf(`Generate a concise git commit message for the changes an agent made for the task below.
+
+IMPORTANT: Do NOT use any tools. Rely SOLELY on the task and the recorded changes provided below.
+
+Follow these exact rules:
+1. First, generate a short title starting with an action word (Adds, Updates, Deletes, Renames)
+2. Title must be under 72 characters, no colons, no markdown
+3. Title should not include filenames
+4. Then generate a description paragraph under 500 characters
+5. Description should not include code blocks or filenames
+6. No markdown formatting anywhere
+7. Format: [Title]\n\n[Description]
+
+%s
+
+Please generate only the commit message content, no additional commentary.`, context), true
+}
+
 // handleCommitConfirmation handles the commit message confirmation, editing, and retry logic
 func handleCommitConfirmation(commitMessage string, chatAgent *agent.Agent, reader *bufio.Reader, diffOutput []byte, contextInfo string) (string, bool, error) {
 	maxRetries := 3
@@ -432,7 +478,11 @@ func handleCommitConfirmation(commitMessage string, chatAgent *agent.Agent, read
 		}
 		fmt.Print("Choose an option: ")
 		
-		input, _ := reader.ReadString('\n')
+		input, err := reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			fmt.Println("\n❌ No answer (input ended); commit cancelled")
+			return "", false, nil
+		}
 		input = strings.TrimSpace(strings.ToLower(input))
 
 		switch input {
@@ -443,8 +493,7 @@ func handleCommitConfirmation(commitMessage string, chatAgent *agent.Agent, read
 			return "", false, nil
 			
 		case "e", "edit":
-			// Edit via default editor
-			editedMessage, err := editCommitMessageInEditor(commitMessage)
+			editedMessage, err := editCommitMessage(commitMessage, reader)
 			if err != nil {
 				fmt.Printf("❌ Failed to edit message: %v\n", err)
 				continue
@@ -501,7 +550,7 @@ Staged changes:
 Please generate only the commit message content, no additional commentary.`, string(diffOutput))
 			}
 			
-			newMessage, err := chatAgent.ProcessQuery(retryPrompt)
+			newMessage, err := generateCommitMessage(chatAgent, retryPrompt)
 			if err != nil {
 				fmt.Printf("❌ Failed to regenerate commit message: %v\n", err)
 				continue
@@ -515,47 +564,37 @@ Please generate only the commit message content, no additional commentary.`, str
 	}
 }
 
-// editCommitMessageInEditor opens the commit message in the user's default editor
-func editCommitMessageInEditor(initialMessage string) (string, error) {
-	// Create temporary file
-	tempFile := ".commit_msg_edit.txt"
-	err := os.WriteFile(tempFile, []byte(initialMessage), 0644)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temporary file: %v", err)
-	}
-	defer os.Remove(tempFile)
+// commitMessageSchema is the structured output requested for commit messages
+var commitMessageSchema = api.ResponseSchema{
+	Name: "commit_message",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title":       map[string]interface{}{"type": "string"},
+			"description": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"title", "description"},
+		"additionalProperties": false,
+	},
+}
 
-	// Determine editor (use $EDITOR or fallback to vim/nano)
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		// Try common editors
-		if _, err := exec.LookPath("vim"); err == nil {
-			editor = "vim"
-		} else if _, err := exec.LookPath("nano"); err == nil {
-			editor = "nano"
-		} else if _, err := exec.LookPath("code"); err == nil {
-			editor = "code"
-		} else {
-			return "", fmt.Errorf("no editor found. Please set $EDITOR environment variable")
-		}
+// generateCommitMessage asks the model for a commit message as a title and
+// description and joins them in git's format
+func generateCommitMessage(chatAgent *agent.Agent, prompt string) (string, error) {
+	var message struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
 	}
-
-	// Open editor
-	cmd := exec.Command(editor, tempFile)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	
-	err = cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("editor failed: %v", err)
+	if err := chatAgent.GenerateStructuredAs(agent.PromptCommit, prompt, commitMessageSchema, &message); err != nil {
+		return "", err
 	}
 
-	// Read edited content
-	editedContent, err := os.ReadFile(tempFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to read edited file: %v", err)
+	title := strings.TrimSpace(message.Title)
+	if title == "" {
+		return "", fmt.Errorf("model returned an empty commit title")
 	}
-
-	return strings.TrimSpace(string(editedContent)), nil
-}
\ No newline at end of file
+	if description := strings.TrimSpace(message.Description); description != "" {
+		return title + "\n\n" + description, nil
+	}
+	return title, nil
+}
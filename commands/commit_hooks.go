@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// commitHookNames are the hooks git runs during a commit that can reject it
+var commitHookNames = []string{"pre-commit", "prepare-commit-msg", "commit-msg"}
+
+// maxHookFixAttempts bounds how often the agent is asked to fix hook failures
+const maxHookFixAttempts = 3
+
+// activeCommitHooks returns the commit hooks installed in the repository,
+// including those of husky or the pre-commit framework, which git finds
+// through core.hooksPath or .git/hooks
+func activeCommitHooks() []string {
+	output, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return nil
+	}
+	dir := strings.TrimSpace(string(output))
+	if hooksPath, err := exec.Command("git", "config", "core.hooksPath").Output(); err == nil && strings.TrimSpace(string(hooksPath)) != "" {
+		dir = strings.TrimSpace(string(hooksPath))
+		if !filepath.IsAbs(dir) {
+			// A relative core.hooksPath is relative to the top of the work tree
+			if top, err := exec.Command("git", "rev-parse", "--show-toplevel").Output(); err == nil {
+				dir = filepath.Join(strings.TrimSpace(string(top)), dir)
+			}
+		}
+	}
+
+	var hooks []string
+	for _, name := range commitHookNames {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			hooks = append(hooks, name)
+		}
+	}
+	return hooks
+}
+
+// stagedFiles lists the files staged for the commit
+func stagedFiles() []string {
+	output, err := exec.Command("git", "diff", "--cached", "--name-only", "-z").Output()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, name := range strings.Split(string(output), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// restageFiles stages the files again after hooks or the agent changed them
+func restageFiles(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	output, err := exec.Command("git", append([]string{"add", "-A", "--"}, files...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to stage the fixed files: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// commitWithHooks creates the commit like createGitCommit. When the
+// repository's hooks reject it, their output is shown and the user can let
+// the agent fix the reported problems, fix them and retry, skip the hooks or
+// cancel. Files formatters rewrote are staged again before each retry.
+func commitWithHooks(chatAgent *agent.Agent, reader *bufio.Reader, messageFile string) ([]byte, error) {
+	staged := stagedFiles()
+	var extra []string
+	fixes := 0
+	for {
+		output, err := createGitCommit(chatAgent, messageFile, extra...)
+		if err == nil {
+			return output, nil
+		}
+		hooks := activeCommitHooks()
+		if len(hooks) == 0 || len(extra) > 0 || signingFailureHint(string(output), chatAgent) != "" {
+			return output, err
+		}
+
+		fmt.Printf("\n🪝 The commit was rejected by the repository's %s hook(s):\n", strings.Join(hooks, "/"))
+		fmt.Println(strings.TrimSpace(string(output)))
+		fmt.Println("\n💡 Options:")
+		if fixes < maxHookFixAttempts {
+			fmt.Println("  f - Let the agent fix the reported problems, then retry")
+		}
+		fmt.Println("  r - Retry (after fixing the problems yourself); changed files are staged again")
+		fmt.Println("  s - Skip the hooks for this commit (--no-verify)")
+		fmt.Println("  c - Cancel the commit")
+
+		action := ""
+		for action == "" {
+			fmt.Print("Choose an option: ")
+			input, readErr := reader.ReadString('\n')
+			if readErr != nil && strings.TrimSpace(input) == "" {
+				return output, fmt.Errorf("commit rejected by the %s hook(s)", strings.Join(hooks, "/"))
+			}
+			switch strings.TrimSpace(strings.ToLower(input)) {
+			case "f", "fix":
+				if fixes >= maxHookFixAttempts {
+					fmt.Println("❌ The agent already tried to fix the hook failures; fix them yourself and retry")
+					continue
+				}
+				action = "fix"
+			case "r", "retry":
+				action = "retry"
+			case "s", "skip":
+				action = "skip"
+			case "c", "cancel", "n", "no":
+				return output, fmt.Errorf("commit cancelled after the %s hook(s) rejected it", strings.Join(hooks, "/"))
+			default:
+				fmt.Println("❌ Invalid option. Please choose f, r, s or c")
+			}
+		}
+
+		switch action {
+		case "fix":
+			fixes++
+			fmt.Printf("🤖 Asking the agent to fix the hook failures (attempt %d/%d)...\n", fixes, maxHookFixAttempts)
+			if _, err := chatAgent.ProcessQuery(hookFixPrompt(hooks, staged, string(output))); err != nil {
+				fmt.Printf("❌ The agent couldn't fix the problems: %v\n", err)
+			}
+			if err := restageFiles(staged); err != nil {
+				return nil, err
+			}
+		case "retry":
+			if err := restageFiles(staged); err != nil {
+				return nil, err
+			}
+		case "skip":
+			extra = []string{"--no-verify"}
+		}
+	}
+}
+
+// hookFixPrompt asks the agent to fix what the commit hooks reported
+func hookFixPrompt(hooks, staged []string, output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > 6000 {
+		output = "...\n" + output[len(output)-6000:]
+	}
+	return fmt.Sprintf(`The repository's git %s hook(s) rejected a commit of these staged files:
+%s
+
+Hook output:
+%s
+
+Fix the problems the hooks report (formatting, lint, type errors, ...) in those files. Prefer the fixers the project already uses, such as the formatter the hook runs. Do not commit, stage files, or change, disable or bypass the hooks. Reply with a short summary of what you fixed.`,
+		strings.Join(hooks, "/"), strings.Join(staged, "\n"), output)
+}
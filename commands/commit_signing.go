@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const (
+	prefCommitSign       = "commit_sign"        // "" (git's commit.gpgsign decides), "gpg", "ssh", "x509" or "off"
+	prefCommitSigningKey = "commit_signing_key" // key ID or SSH public key path; "" uses git's user.signingkey
+	prefCommitAuthor     = "commit_author"      // "Name <email>" to commit as ("" = git's user.name and user.email)
+	prefCommitCommitter  = "commit_committer"   // "Name <email>" recorded as committer ("" = git's)
+)
+
+// signingFailureMarkers appear in git's output when signing a commit failed
+var signingFailureMarkers = []string{
+	"gpg failed to sign",
+	"failed to sign the data",
+	"signing failed",
+	"secret key not available",
+	"no secret key",
+	"ssh-keygen",
+	"couldn't load public key",
+	"gpgsm",
+}
+
+// gitCommitArgs builds the git commit arguments for a message file from the
+// signing and author preferences
+func gitCommitArgs(chatAgent *agent.Agent, messageFile string) ([]string, []string, error) {
+	cfg := chatAgent.GetConfigManager().GetConfig()
+	var env []string
+	args := []string{"commit"}
+	key := strings.TrimSpace(cfg.GetStringPreference(prefCommitSigningKey, ""))
+	switch sign := strings.ToLower(strings.TrimSpace(cfg.GetStringPreference(prefCommitSign, ""))); sign {
+	case "":
+		// commit.gpgsign and gpg.format in git's config decide
+		if key != "" {
+			args = []string{"-c", "user.signingkey=" + key, "commit"}
+		}
+	case "off":
+		args = append(args, "--no-gpg-sign")
+	case "gpg", "ssh", "x509":
+		format := sign
+		if sign == "gpg" {
+			format = "openpgp"
+		}
+		signFlag := "--gpg-sign"
+		if key != "" {
+			signFlag += "=" + key
+		}
+		args = []string{"-c", "gpg.format=" + format, "commit", signFlag}
+	default:
+		return nil, nil, fmt.Errorf("unknown %s %q (use gpg, ssh, x509 or off)", prefCommitSign, sign)
+	}
+
+	if author := strings.TrimSpace(cfg.GetStringPreference(prefCommitAuthor, "")); author != "" {
+		if _, err := mail.ParseAddress(author); err != nil {
+			return nil, nil, fmt.Errorf("%s must look like \"Name <email>\": %w", prefCommitAuthor, err)
+		}
+		args = append(args, "--author="+author)
+	}
+	if committer := strings.TrimSpace(cfg.GetStringPreference(prefCommitCommitter, "")); committer != "" {
+		address, err := mail.ParseAddress(committer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s must look like \"Name <email>\": %w", prefCommitCommitter, err)
+		}
+		if address.Name != "" {
+			env = append(env, "GIT_COMMITTER_NAME="+address.Name)
+		}
+		env = append(env, "GIT_COMMITTER_EMAIL="+address.Address)
+	}
+	return append(args, "-F", messageFile), env, nil
+}
+
+// createGitCommit commits the staged changes with the message in
+// messageFile, signing and attributing the commit as configured; extra
+// arguments go to git commit. Failures include git's output, and signing
+// failures a hint on how to fix them.
+func createGitCommit(chatAgent *agent.Agent, messageFile string, extra ...string) ([]byte, error) {
+	args, env, err := gitCommitArgs(chatAgent, messageFile)
+	if err != nil {
+		return nil, err
+	}
+	args = append(append(args[:len(args)-2:len(args)-2], extra...), "-F", messageFile)
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return output, nil
+	}
+	details := strings.TrimSpace(string(output))
+	if hint := signingFailureHint(details, chatAgent); hint != "" {
+		return output, fmt.Errorf("failed to sign the commit: %s\n%s", details, hint)
+	}
+	return output, fmt.Errorf("failed to create commit: %v\n%s", err, details)
+}
+
+// signingFailureHint suggests how to fix a failed commit signature, or
+// returns "" when git's output isn't about signing
+func signingFailureHint(output string, chatAgent *agent.Agent) string {
+	lower := strings.ToLower(output)
+	failed := false
+	for _, marker := range signingFailureMarkers {
+		if strings.Contains(lower, marker) {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return ""
+	}
+
+	format := strings.ToLower(chatAgent.GetConfigManager().GetConfig().GetStringPreference(prefCommitSign, ""))
+	if format == "" || format == "off" {
+		gitFormat, _ := exec.Command("git", "config", "gpg.format").Output()
+		format = strings.TrimSpace(string(gitFormat))
+	}
+	switch format {
+	case "ssh":
+		return "💡 Point user.signingkey (or the commit_signing_key preference) at your SSH public key, or load the key with ssh-add (check with ssh-add -L). SSH signing needs git 2.34 or newer."
+	case "x509":
+		return "💡 Check that gpgsm (or the program in gpg.x509.program) has your certificate: gpgsm --list-secret-keys."
+	}
+	hint := "💡 Check that the signing key is available with gpg --list-secret-keys and set user.signingkey (or the commit_signing_key preference) to its ID."
+	if os.Getenv("GPG_TTY") == "" {
+		hint += " gpg may also need to ask for your passphrase: export GPG_TTY=$(tty) before starting coder."
+	}
+	return hint
+}
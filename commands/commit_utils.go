@@ -65,7 +65,7 @@ Please generate only the commit message content, no additional commentary.`, str
 	}
 
 	fmt.Println("🤖 Generating commit message with AI...")
-	commitMessage, err := h.chatAgent.ProcessQuery(commitPrompt)
+	commitMessage, err := h.chatAgent.GenerateText(agent.PromptCommit, commitPrompt)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate commit message: %v", err)
 	}
@@ -89,7 +89,11 @@ func (h *CommitMessageHandler) HandleCommitConfirmation(commitMessage string, fi
 			fmt.Println("\n💡 Commit with this message? (y)es/(n)o/(e)dit/(r)etry:")
 		}
 		
-		input, _ := h.reader.ReadString('\n')
+		input, err := h.reader.ReadString('\n')
+		if err != nil && strings.TrimSpace(input) == "" {
+			fmt.Println("\n❌ No answer (input ended); commit cancelled")
+			return "", false, nil
+		}
 		input = strings.TrimSpace(strings.ToLower(input))
 
 		switch input {
@@ -114,69 +118,152 @@ func (h *CommitMessageHandler) HandleCommitConfirmation(commitMessage string, fi
 	}
 }
 
-// EditCommitMessage opens the default editor to edit the commit message
+// EditCommitMessage lets the user edit the commit message
 func (h *CommitMessageHandler) EditCommitMessage(commitMessage string) (string, error) {
-	// Write commit message to temporary file
-	tempFile := "commit_msg_edit.txt"
-	err := os.WriteFile(tempFile, []byte(commitMessage), 0644)
+	editedMessage, err := editCommitMessage(commitMessage, h.reader)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary commit message file: %v", err)
+		return "", err
 	}
-	defer os.Remove(tempFile)
+	fmt.Println("✅ Commit message edited successfully")
+	return editedMessage, nil
+}
 
-	// Determine default editor (use $EDITOR or fallback to vi)
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "vi"
+// editCommitMessage lets the user edit a commit message: in their editor
+// when there is a terminal to run it on, otherwise line by line from reader,
+// so piped and CI runs never wait on an editor nobody can see
+func editCommitMessage(message string, reader *bufio.Reader) (string, error) {
+	if !terminalAttached() {
+		return editMessageInline(message, reader)
+	}
+	editor, err := resolveEditor()
+	if err != nil {
+		fmt.Printf("⚠️  %v; editing inline instead\n", err)
+		return editMessageInline(message, reader)
+	}
+	if editor == ":" {
+		return message, nil // git's way of configuring no editor
 	}
 
-	// Open editor
-	cmd := exec.Command(editor, tempFile)
+	tempFile, err := writeTempMessageFile(message)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary commit message file: %w", err)
+	}
+	defer os.Remove(tempFile)
+
+	// Editors may come with arguments ("code --wait"), so the shell runs them like git does
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, tempFile)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	
 	fmt.Printf("📝 Opening %s to edit commit message...\n", editor)
 	fmt.Println("💡 Make your changes, save, and exit the editor to continue")
-	
-	err = cmd.Run()
-	if err != nil {
-		return "", fmt.Errorf("failed to edit commit message: %v", err)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor, err)
 	}
 
-	// Read edited message
-	editedContent, err := os.ReadFile(tempFile)
+	edited, err := os.ReadFile(tempFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to read edited commit message: %v", err)
+		return "", fmt.Errorf("failed to read edited commit message: %w", err)
 	}
-
-	editedMessage := strings.TrimSpace(string(editedContent))
-	if editedMessage == "" {
+	if strings.TrimSpace(string(edited)) == "" {
 		return "", fmt.Errorf("commit message cannot be empty")
 	}
+	return strings.TrimSpace(string(edited)), nil
+}
 
-	fmt.Println("✅ Commit message edited successfully")
-	return editedMessage, nil
+// resolveEditor picks the editor the way git does: GIT_EDITOR, then
+// core.editor, then VISUAL and EDITOR, then a common terminal editor
+func resolveEditor() (string, error) {
+	if editor := strings.TrimSpace(os.Getenv("GIT_EDITOR")); editor != "" {
+		return editor, nil
+	}
+	if output, err := exec.Command("git", "config", "core.editor").Output(); err == nil {
+		if editor := strings.TrimSpace(string(output)); editor != "" {
+			return editor, nil
+		}
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(name)); editor != "" {
+			return editor, nil
+		}
+	}
+	for _, editor := range []string{"vim", "nano", "vi"} {
+		if _, err := exec.LookPath(editor); err == nil {
+			return editor, nil
+		}
+	}
+	return "", fmt.Errorf("no editor found (set GIT_EDITOR, git's core.editor or $EDITOR)")
+}
+
+// terminalAttached reports whether stdin and stdout are a terminal an editor
+// can run on
+func terminalAttached() bool {
+	for _, file := range []*os.File{os.Stdin, os.Stdout} {
+		if stat, err := file.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// editMessageInline reads a replacement message line by line, up to a line
+// holding only "." or the end of input. Entering nothing keeps the message.
+func editMessageInline(message string, reader *bufio.Reader) (string, error) {
+	fmt.Println("📝 No terminal for an editor; type the new commit message below")
+	fmt.Println("💡 End it with a line holding only \".\"; an empty message keeps the current one")
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			break
+		}
+		if line != "" || err == nil {
+			lines = append(lines, line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	edited := strings.TrimSpace(strings.Join(lines, "\n"))
+	if edited == "" {
+		fmt.Println("ℹ️  No new message entered; keeping the current one")
+		return message, nil
+	}
+	return edited, nil
 }
 
 // CreateCommit creates the git commit with the given message
 func (h *CommitMessageHandler) CreateCommit(commitMessage string) error {
 	// Write commit message to temporary file
-	tempFile := "commit_msg.txt"
-	err := os.WriteFile(tempFile, []byte(commitMessage), 0644)
+	tempFile, err := writeTempMessageFile(commitMessage)
 	if err != nil {
 		return fmt.Errorf("failed to create temporary commit message file: %v", err)
 	}
 	defer os.Remove(tempFile)
 
 	fmt.Println("\n💾 Creating commit...")
-	cmd := exec.Command("git", "commit", "-F", tempFile)
-	output, err := cmd.CombinedOutput()
+	output, err := commitWithHooks(h.chatAgent, h.reader, tempFile)
 	if err != nil {
-		return fmt.Errorf("failed to create commit: %v", err)
+		return err
 	}
 
 	fmt.Printf("✅ Commit created successfully!\n")
 	fmt.Printf("Output: %s\n", string(output))
 	return nil
-}
\ No newline at end of file
+}
+
+// writeTempMessageFile writes a commit message to a new file in the system
+// temp directory, so nothing is left in the repository; callers remove it
+func writeTempMessageFile(message string) (string, error) {
+	file, err := os.CreateTemp("", "coder-commit-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(message); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
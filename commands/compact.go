@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// CompactCommand implements the /compact slash command
+type CompactCommand struct{}
+
+// Name returns the command name
+func (c *CompactCommand) Name() string {
+	return "compact"
+}
+
+// Description returns the command description
+func (c *CompactCommand) Description() string {
+	return "Summarize older turns of the conversation to free context, keeping recent ones verbatim"
+}
+
+// Execute summarizes the older turns of the current conversation
+func (c *CompactCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	fmt.Println("🗜️  Summarizing earlier turns...")
+	result, err := chatAgent.CompactConversation()
+	if errors.Is(err, agent.ErrNothingToCompact) {
+		fmt.Println("Nothing to compact: the conversation only has its latest turns")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Summarized %d earlier messages: %d → %d context tokens\n", result.Messages, result.TokensBefore, result.TokensAfter)
+	return nil
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/api"
+)
+
+// CostCommand implements the /cost slash command
+type CostCommand struct{}
+
+// Name returns the command name
+func (c *CostCommand) Name() string {
+	return "cost"
+}
+
+// Description returns the command description
+func (c *CostCommand) Description() string {
+	return "Show session spend, the spend cap and the provider's remaining balance"
+}
+
+// Execute shows what has been spent and what is left
+func (c *CostCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	fmt.Println("\n💰 Spend:")
+	fmt.Printf("   Session total: $%.4f\n", chatAgent.GetTotalCost())
+	fmt.Printf("   Last task:     $%.4f\n", chatAgent.GetLastTaskCost())
+	if spendCap := chatAgent.GetSpendCap(); spendCap > 0 {
+		fmt.Printf("   Spend cap:     $%.2f ($%.4f left)\n", spendCap, spendCap-chatAgent.GetTotalCost())
+	} else {
+		fmt.Println("   Spend cap:     none (set the spend_cap preference to add one)")
+	}
+	printBalance(chatAgent)
+	return nil
+}
+
+// printBalance shows the active provider's remaining credit, when it reports one
+func printBalance(chatAgent *agent.Agent) {
+	balance, err := chatAgent.GetBalance(true)
+	switch {
+	case errors.Is(err, api.ErrBalanceUnavailable):
+		fmt.Printf("   Balance:       not reported by %s\n", chatAgent.GetProvider())
+	case err != nil:
+		fmt.Printf("   Balance:       unavailable (%v)\n", err)
+	default:
+		fmt.Printf("   Balance:       $%.4f remaining on %s\n", balance.Remaining, balance.Provider)
+	}
+}
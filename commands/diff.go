@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// DiffCommand implements the /diff slash command
+type DiffCommand struct{}
+
+// Name returns the command name
+func (d *DiffCommand) Name() string {
+	return "diff"
+}
+
+// Description returns the command description
+func (d *DiffCommand) Description() string {
+	return "Show the changes the agent's tools made this session (/diff --stat, /diff <file>...)"
+}
+
+// Execute prints the recorded file changes as diffs, or totals per file
+func (d *DiffCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	totals := chatAgent.ChangedFiles()
+	if len(totals) == 0 {
+		fmt.Println("No files changed by write_file, edit_file or edit_cell this session (see /whatchanged for other changes)")
+		return nil
+	}
+
+	if len(args) > 0 && args[0] == "--stat" {
+		fmt.Printf("📝 %d file(s) changed:\n", len(totals))
+		for _, total := range totals {
+			fmt.Printf("  %-8s %s  +%d/-%d lines, %d change(s), %d → %d bytes\n",
+				total.Kind, total.Path, total.LinesAdded, total.LinesRemoved, total.Changes, total.BytesBefore, total.BytesAfter)
+		}
+		return nil
+	}
+
+	diff := chatAgent.SessionDiff(args...)
+	if diff == "" {
+		return fmt.Errorf("no recorded changes to %s", strings.Join(args, ", "))
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Printf("\033[1m%s\033[0m\n", line)
+		case strings.HasPrefix(line, "@@"):
+			fmt.Printf("\033[36m%s\033[0m\n", line)
+		case strings.HasPrefix(line, "+"):
+			fmt.Printf("\033[32m%s\033[0m\n", line)
+		case strings.HasPrefix(line, "-"):
+			fmt.Printf("\033[31m%s\033[0m\n", line)
+		default:
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
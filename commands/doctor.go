@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/tools"
+)
+
+const doctorUsage = "usage: /doctor [--fix]"
+
+// DoctorCommand implements the /doctor slash command
+type DoctorCommand struct{}
+
+// Name returns the command name
+func (d *DoctorCommand) Name() string {
+	return "doctor"
+}
+
+// Description returns the command description
+func (d *DoctorCommand) Description() string {
+	return "Check the project's required tools and versions, and optionally install what's missing"
+}
+
+// Execute checks the project's prerequisites and offers to fix the failures
+func (d *DoctorCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	fix := false
+	for _, arg := range args {
+		if arg != "--fix" {
+			return fmt.Errorf(doctorUsage)
+		}
+		fix = true
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	prerequisites, err := tools.LoadPrerequisites(wd)
+	if err != nil {
+		return err
+	}
+	if len(prerequisites) == 0 {
+		fmt.Printf("🩺 No prerequisites found - declare them in %s, e.g. {\"tools\": [{\"name\": \"go\", \"version\": \">=1.22\"}]}\n", tools.PrerequisitesFile)
+		return nil
+	}
+
+	fmt.Println("🩺 Checking project prerequisites...")
+	var failures []tools.PrerequisiteStatus
+	for _, p := range prerequisites {
+		status := tools.CheckPrerequisite(context.Background(), p, func(command string) (string, error) {
+			return chatAgent.RunProjectCommand(command, fmt.Sprintf("check the %s version declared in %s", p.Name, p.Source))
+		})
+		required := p.Version
+		if required == "" {
+			required = "any version"
+		}
+		switch {
+		case status.OK:
+			fmt.Printf("✅ %s %s (%s, from %s)\n", p.Name, status.Installed, required, p.Source)
+		case status.Missing:
+			fmt.Printf("❌ %s: not installed (%s, from %s)\n", p.Name, required, p.Source)
+			failures = append(failures, status)
+		default:
+			fmt.Printf("⚠️  %s: %s (from %s)\n", p.Name, status.Problem, p.Source)
+			failures = append(failures, status)
+		}
+	}
+	if len(failures) == 0 {
+		fmt.Println("✅ Environment looks good")
+		return nil
+	}
+
+	if !fix {
+		fmt.Print("Let the agent install or upgrade them? Each install needs your approval. (y/N): ")
+		input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(input)) != "y" {
+			return nil
+		}
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("The project's prerequisites aren't met on this machine:\n")
+	for _, status := range failures {
+		fmt.Fprintf(&prompt, "- %s: %s", status.Name, status.Problem)
+		if status.Version != "" {
+			fmt.Fprintf(&prompt, " (needs %s, declared in %s)", status.Version, status.Source)
+		}
+		if status.Install != "" {
+			fmt.Fprintf(&prompt, "; the project says to install it with: %s", status.Install)
+		}
+		prompt.WriteString("\n")
+	}
+	prompt.WriteString("\nInstall or upgrade them with the package manager this machine uses (check the OS and what is available first), then confirm the versions. Don't change the project's files.")
+	response, err := chatAgent.ProcessQuery(prompt.String())
+	if err != nil {
+		return fmt.Errorf("agent failed to fix the environment: %w", err)
+	}
+	fmt.Println(response)
+	return nil
+}
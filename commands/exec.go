@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -27,8 +28,10 @@ func (c *ExecCommand) Execute(args []string, chatAgent *agent.Agent) error {
 
 	command := strings.Join(args, " ")
 	
-	// Execute the shell command
-	result, err := tools.ExecuteShellCommand(command)
+	// Execute the shell command; Ctrl+C cancels it
+	ctx, end := chatAgent.BeginOperation()
+	defer end()
+	result, err := tools.ExecuteShellCommand(ctx, command)
 	if err != nil {
 		return fmt.Errorf("command failed: %v\nOutput: %s", err, result)
 	}
@@ -65,6 +68,6 @@ func IsShellCommand(prompt string) bool {
 }
 
 // ExecuteShellCommandDirectly executes a shell command directly and returns the result
-func ExecuteShellCommandDirectly(command string) (string, error) {
-	return tools.ExecuteShellCommand(command)
+func ExecuteShellCommandDirectly(ctx context.Context, command string) (string, error) {
+	return tools.ExecuteShellCommand(ctx, command)
 }
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/tools"
 )
 
 // ExitCommand implements the /exit slash command
@@ -26,6 +27,9 @@ func (e *ExitCommand) Execute(args []string, chatAgent *agent.Agent) error {
 	fmt.Println("\n📊 Session Cost Summary:")
 	fmt.Println("=====================================")
 	chatAgent.PrintConversationSummary(false)
+	tools.StopDevServer()
+	tools.StopJobs()
+	chatAgent.CloseInstance()
 	fmt.Println("👋 Goodbye!")
 	os.Exit(0)
 	return nil // This line won't be reached due to os.Exit
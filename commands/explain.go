@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// ExplainCommand implements the /explain slash command
+type ExplainCommand struct{}
+
+// Name returns the command name
+func (e *ExplainCommand) Name() string {
+	return "explain"
+}
+
+// Description returns the command description
+func (e *ExplainCommand) Description() string {
+	return "Explain and review uncommitted changes, or a commit (/explain <sha>), from the diff alone"
+}
+
+// Execute explains the working tree changes, or the commit given as argument
+func (e *ExplainCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) > 0 {
+		return RunExplainCommit(args[0], chatAgent)
+	}
+	diff, err := exec.Command("git", "diff", "HEAD").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to get the diff: %s", strings.TrimSpace(string(diff)))
+	}
+	if strings.TrimSpace(string(diff)) == "" {
+		fmt.Println("No uncommitted changes to explain (use /explain <sha> for a commit)")
+		return nil
+	}
+	return RunExplainDiff(string(diff), "", chatAgent)
+}
+
+// RunExplainCommit explains a commit from its message and diff
+func RunExplainCommit(rev string, chatAgent *agent.Agent) error {
+	output, err := exec.Command("git", "show", "--format=commit %H%nAuthor: %an%n%n%B", "--no-color", rev, "--").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to show commit %s: %s", rev, strings.TrimSpace(string(output)))
+	}
+	header, diff, _ := strings.Cut(string(output), "\ndiff --git ")
+	if diff == "" {
+		return fmt.Errorf("commit %s has no file changes to explain", rev)
+	}
+	fmt.Printf("🔍 Explaining commit %s\n", rev)
+	return RunExplainDiff("diff --git "+diff, "Explain this commit.\n\n"+strings.TrimSpace(header), chatAgent)
+}
+
+// RunExplainDiff explains a diff in one request, without exploring the
+// project, and prints the explanation with its cost
+func RunExplainDiff(diff, note string, chatAgent *agent.Agent) error {
+	spent := chatAgent.GetTotalCost()
+	explanation, err := chatAgent.ExplainDiff(diff, note)
+	if err != nil {
+		return err
+	}
+	if !chatAgent.StreamedResult(explanation) {
+		fmt.Println(explanation)
+	}
+	fmt.Printf("\n💰 Explanation cost: $%.4f\n", chatAgent.GetTotalCost()-spent)
+	return nil
+}
@@ -34,7 +34,7 @@ Available slash commands:`)
 		fmt.Printf("  /%s - %s\n", cmd.Name(), cmd.Description())
 	}
 
-	fmt.Println(`
+	fmt.Print(`
 🤖 Coder Agent
 
 A command-line coding assistant using OpenAI's gpt-oss-120b model with 7 core tools:
@@ -84,7 +84,7 @@ ENVIRONMENT:
   DEEPINFRA_API_KEY: API token for DeepInfra (if not set, uses local Ollama)
 
 MODEL OPTIONS:
-  🏠 Local (Ollama):    gpt-oss:20b - FREE, runs locally (14GB VRAM)
+  🏠 Local (Ollama):    FREE - /models on the ollama provider recommends a model for this machine
   ☁️  Remote (DeepInfra): Multiple models available:
      • openai/gpt-oss-120b (default) - Uses harmony syntax
      • meta-llama/Meta-Llama-3.1-70B-Instruct - Standard format
@@ -92,7 +92,7 @@ MODEL OPTIONS:
      • And many others - check DeepInfra docs for full list
 
 SETUP:
-  Local:  ollama pull gpt-oss:20b
+  Local:  ollama pull <model>
   Remote: export DEEPINFRA_API_KEY="your_api_key_here"
 
 The agent follows a systematic exploration process and will autonomously:
@@ -105,6 +105,7 @@ The agent follows a systematic exploration process and will autonomously:
 
 Type 'help' during interactive mode for this help message.
 Type 'exit' or 'quit' to end the session.
+
 `)
 
 	return nil
@@ -1,10 +1,8 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"time"
 
 	"github.com/alantheprice/coder/agent"
 )
@@ -19,117 +17,33 @@ func (i *InitCommand) Name() string {
 
 // Description returns the command description
 func (i *InitCommand) Description() string {
-	return "Generate or regenerate the project context documentation"
+	return "Describe the project in " + agent.ProjectContextFile + " (--regenerate to replace it)"
 }
 
-// Execute runs the init command to generate project context
+// Execute scans the project, has the agent explore it and writes the
+// project context file every task starts from
 func (i *InitCommand) Execute(args []string, chatAgent *agent.Agent) error {
-	fmt.Println("🔧 Generating project context...")
-
-	// Get current directory info
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get working directory: %v", err)
+	regenerate := false
+	for _, arg := range args {
+		switch arg {
+		case "--regenerate", "regenerate", "-f", "--force":
+			regenerate = true
+		default:
+			return fmt.Errorf("usage: /init [--regenerate]")
+		}
 	}
 
-	// Analyze project structure
-	projectName := filepath.Base(wd)
-	goModContent, _ := os.ReadFile("go.mod")
-	goModInfo := string(goModContent)
-
-	// Get current timestamp with local timezone
-	timestamp := time.Now().Format("2006-01-02 15:04:05 MST")
-
-	// Generate comprehensive project context
-	context := fmt.Sprintf(`# Project Context: %s
-
-## Overview
-This is a command-line coding assistant written in Go that uses OpenAI's gpt-oss-120b model (via DeepInfra) or local gpt-oss:20b (via Ollama). The agent operates autonomously with core tools and follows systematic exploration patterns.
-
-## Project Structure
-- **main.go**: CLI interface with dual-mode support (local/remote)
-- **agent/agent.go**: Core agent logic with embedded system prompt and tool execution
-- **api/**: Unified client interface supporting both DeepInfra and Ollama
-- **tools/**: Essential coding tools (shell, read, write, edit, todo management)
-- **commands/**: Interactive slash command system
-
-## Key Features
-- Autonomous operation with systematic workflow
-- Dual-mode support (cloud DeepInfra + local Ollama)
-- Interactive mode with slash commands (/help, /models, /init)
-- Tool integration for shell, file operations, and task management
-- Context management with conversation history tracking
-- Multi-model support across different providers
-
-## Development Commands
-- Build: go build -o coder .
-- Run interactively: ./coder
-- Run with query: ./coder "your query"
-- Local inference: ./coder --local "your query"
-- Testing: ./validate.sh, ./test_e2e.sh, ./test.sh
-
-## Environment Setup
-- Remote: export DEEPINFRA_API_KEY="your_api_key"
-- Local: ollama pull gpt-oss:20b
-- Debug: export DEBUG=true
-
-## Model Configuration
-- Remote: openai/gpt-oss-120b via DeepInfra (pay per use)
-- Local: gpt-oss:20b via Ollama (free, requires 14GB VRAM)
-- System Prompt: v2_structured (systematically tested and optimized)
-
-## Tool Usage Patterns
-The agent uses tools systematically:
-1. **Shell commands** for exploration (ls, find, grep, go build, go test)
-2. **Read file** to understand existing code structure
-3. **Write/Edit file** to implement changes
-4. **Todo tools** for complex task tracking
-5. **Ask user** for clarification when requirements are ambiguous
-
-## Architecture Notes
-- Uses Go 1.24 with minimal dependencies (only readline)
-- Harmony message formatting for gpt-oss model compatibility
-- Conversation history tracking for cost analysis
-- Comprehensive test suite with prompt engineering evaluation
-- Performance metrics tracking across different system prompts
-
-## Error Handling
-- Tool execution errors are captured and reported
-- API failures include retry logic
-- Malformed tool calls are detected and corrected
-- Connection checks validate client setup
-
-## Interactive Commands
-- /help - Show available commands
-- /models - List available models
-- /models select - Interactive model picker
-- /init - Generate or regenerate project context
-- exit - End session
-
-## Development Guidelines
-- Follow systematic exploration patterns
-- Use tools efficiently and minimize unnecessary exploration
-- Test changes thoroughly before completing tasks
-- Maintain conversation continuity across sessions
-- Track costs and token usage for optimization
-
-## Go Module Info
-%s
-
-## Generated
-Context generated on %s by /init command
-`, projectName, goModInfo, timestamp)
-
-	// Write to .project_context.md file
-	err = os.WriteFile(".project_context.md", []byte(context), 0644)
+	fmt.Println("🔧 Generating project context...")
+	path, err := chatAgent.InitProjectContext(regenerate)
+	if errors.Is(err, agent.ErrProjectContextExists) {
+		fmt.Printf("📄 %s already exists - use /init --regenerate to replace it\n", agent.ProjectContextFile)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to write project context: %v", err)
+		return err
 	}
 
-	fmt.Printf("✅ Project context generated successfully!\n")
-	fmt.Printf("📄 File: .project_context.md\n")
-	fmt.Printf("🕒 Generated: %s\n", timestamp)
-	fmt.Printf("\nUse \"/help\" to see all available commands\n")
-
+	fmt.Printf("✅ Project context written to %s\n", path)
+	fmt.Println("📌 New tasks start from it; edit it freely or run /init --regenerate to refresh it")
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/tools"
+)
+
+// JobsCommand implements the /jobs slash command
+type JobsCommand struct{}
+
+// Name returns the command name
+func (j *JobsCommand) Name() string {
+	return "jobs"
+}
+
+// Description returns the command description
+func (j *JobsCommand) Description() string {
+	return "List processes shell commands left running, or kill them (/jobs kill <id>|all)"
+}
+
+// Execute lists the session's running jobs or kills them
+func (j *JobsCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 || args[0] == "list" {
+		listJobs()
+		return nil
+	}
+	if args[0] != "kill" || len(args) != 2 {
+		return fmt.Errorf("usage: /jobs [list | kill <id> | kill all]")
+	}
+
+	if args[1] == "all" {
+		fmt.Printf("🧹 Killed %d job(s)\n", tools.KillJobs())
+		return nil
+	}
+	id, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid job id '%s': use a number from /jobs or 'all'", args[1])
+	}
+	if err := tools.KillJob(id); err != nil {
+		return err
+	}
+	fmt.Printf("🧹 Killed job %d\n", id)
+	return nil
+}
+
+// listJobs prints the running jobs, oldest first
+func listJobs() {
+	jobs := tools.Jobs()
+	if len(jobs) == 0 {
+		fmt.Println("No shell commands of this session are running")
+		return
+	}
+	fmt.Println("⚙️  Running jobs:")
+	for _, job := range jobs {
+		state := "running"
+		if job.Done {
+			state = "left in the background"
+		}
+		fmt.Printf("  [%d] pgid %d, %s for %s: %s\n", job.ID, job.PGID, state, time.Since(job.Started).Round(time.Second), job.Command)
+	}
+	fmt.Println("Kill one with /jobs kill <id>, or all with /jobs kill all")
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const migrateUsage = "usage: /migrate [--no-verify] <schema change> | /migrate verify"
+
+// MigrateCommand implements the /migrate slash command
+type MigrateCommand struct{}
+
+// Name returns the command name
+func (m *MigrateCommand) Name() string {
+	return "migrate"
+}
+
+// Description returns the command description
+func (m *MigrateCommand) Description() string {
+	return "Generate a database migration and verify it against a disposable database"
+}
+
+// Execute generates a migration for the described schema change and verifies it
+func (m *MigrateCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	verify := true
+	if len(args) > 0 && args[0] == "--no-verify" {
+		verify, args = false, args[1:]
+	}
+	description := strings.TrimSpace(strings.Join(args, " "))
+	if description == "" {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	tool, err := agent.DetectMigrationTool(wd)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🗄️  Migrations: %s in %s (%s)\n", tool.Name, tool.Dir, tool.Dialect)
+
+	if description != "verify" {
+		migration, err := chatAgent.GenerateMigration(tool, description)
+		if err != nil {
+			return fmt.Errorf("failed to generate migration: %w", err)
+		}
+		files := make([]string, 0, len(migration.Files))
+		for path := range migration.Files {
+			files = append(files, path)
+		}
+		sort.Strings(files)
+		fmt.Printf("📝 %s\n", migration.Summary)
+		for _, path := range files {
+			fmt.Printf("   created %s\n", path)
+		}
+	}
+	if !verify {
+		return nil
+	}
+
+	fmt.Println("🐳 Verifying against a disposable database...")
+	checks := chatAgent.VerifyMigration(tool, "")
+	var failed *agent.MigrationCheck
+	for i, check := range checks {
+		switch {
+		case check.Skipped:
+			fmt.Printf("⏭️  %s: skipped - %v\n", check.Step, check.Err)
+		case check.Err != nil:
+			fmt.Printf("❌ %s: %s\n%v\n", check.Step, check.Command, check.Err)
+			failed = &checks[i]
+		default:
+			fmt.Printf("✅ %s\n", check.Step)
+		}
+	}
+	if failed == nil {
+		return nil
+	}
+
+	fmt.Print("Let the agent fix the migration? (y/N): ")
+	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(input)) != "y" {
+		return nil
+	}
+	prompt := fmt.Sprintf("The %s migrations in %s fail verification against an empty %s database.\nStep: %s\nCommand: %s\nError:\n%v\n\nFix the newest migration (or the models, if they no longer match the schema) so that applying all migrations, reverting the newest and re-applying it succeeds and the code builds. Re-run /migrate verify afterwards to check.",
+		tool.Name, tool.Dir, tool.Dialect, failed.Step, failed.Command, failed.Err)
+	response, err := chatAgent.ProcessQuery(prompt)
+	if err != nil {
+		return fmt.Errorf("agent failed to fix the migration: %w", err)
+	}
+	fmt.Println(response)
+	return nil
+}
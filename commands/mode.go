@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const modeUsage = "usage: /mode [paired [N]|auto]"
+
+// ModeCommand implements the /mode slash command
+type ModeCommand struct{}
+
+// Name returns the command name
+func (m *ModeCommand) Name() string {
+	return "mode"
+}
+
+// Description returns the command description
+func (m *ModeCommand) Description() string {
+	return "Switch between autonomous and paired mode (pause after N tool calls for a go-ahead)"
+}
+
+// Execute switches the agent's autonomy mode
+func (m *ModeCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 {
+		if turns := chatAgent.GetTurnLimit(); turns > 0 {
+			fmt.Printf("🤝 Paired mode: pausing after %d tool calls\n", turns)
+		} else {
+			fmt.Println("🚀 Autonomous mode: the agent works until the task is done")
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "paired":
+		turns := agent.DefaultPairedTurns
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf(modeUsage)
+			}
+			turns = n
+		}
+		chatAgent.SetTurnLimit(turns)
+		fmt.Printf("🤝 Paired mode: the agent pauses after %d tool calls to summarize and wait for your go-ahead\n", turns)
+	case "auto":
+		chatAgent.SetTurnLimit(0)
+		fmt.Println("🚀 Autonomous mode: the agent works until the task is done")
+	default:
+		return fmt.Errorf(modeUsage)
+	}
+	return nil
+}
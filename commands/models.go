@@ -52,7 +52,7 @@ func (m *ModelsCommand) listModels(chatAgent *agent.Agent) error {
 	fmt.Printf("\n📋 Available Models (%s):\n", providerName)
 	fmt.Println("====================")
 
-	models, err := api.GetModelsForProvider(clientType)
+	models, err := api.GetAvailableModels(clientType)
 	if err != nil {
 		return fmt.Errorf("failed to get available models: %w", err)
 	}
@@ -81,6 +81,9 @@ func (m *ModelsCommand) listModels(chatAgent *agent.Agent) error {
 		if model.Size != "" {
 			fmt.Printf("   Size: %s\n", model.Size)
 		}
+		if model.Fit != "" {
+			fmt.Printf("   Fit: %s %s\n", fitIcon(model.Fit), model.Fit)
+		}
 		if model.InputCost > 0 || model.OutputCost > 0 {
 			if model.InputCost > 0 && model.OutputCost > 0 {
 				fmt.Printf("   Cost: $%.3f/M input, $%.3f/M output tokens\n", model.InputCost, model.OutputCost)
@@ -138,6 +141,10 @@ func (m *ModelsCommand) listModels(chatAgent *agent.Agent) error {
 		fmt.Println()
 	}
 
+	if clientType == api.OllamaClientType {
+		fmt.Printf("💡 Recommended for this machine: %s\n\n", api.LocalModelSuggestion())
+	}
+
 	fmt.Println("Usage:")
 	fmt.Println("  /models select          - Interactive model selection (current provider)")
 	fmt.Println("  /models <model_id>      - Set model directly")
@@ -147,6 +154,18 @@ func (m *ModelsCommand) listModels(chatAgent *agent.Agent) error {
 	return nil
 }
 
+// fitIcon marks how well a local model suits this machine
+func fitIcon(fit api.ModelFit) string {
+	switch fit {
+	case api.ModelFits:
+		return "✅"
+	case api.ModelSlow:
+		return "🐢"
+	default:
+		return "❌"
+	}
+}
+
 // findFeaturedModels identifies indices of featured models
 func (m *ModelsCommand) findFeaturedModels(models []api.ModelInfo) []int {
 	featuredPatterns := []string{
@@ -179,7 +198,7 @@ func (m *ModelsCommand) selectModel(chatAgent *agent.Agent) error {
 	clientType := chatAgent.GetProviderType()
 	providerName := api.GetProviderName(clientType)
 	
-	models, err := api.GetModelsForProvider(clientType)
+	models, err := api.GetAvailableModels(clientType)
 	if err != nil {
 		return fmt.Errorf("failed to get available models: %w", err)
 	}
@@ -268,7 +287,7 @@ func (m *ModelsCommand) selectModel(chatAgent *agent.Agent) error {
 func (m *ModelsCommand) setModel(modelID string, chatAgent *agent.Agent) error {
 	// Validate that the model exists in the current provider
 	clientType := chatAgent.GetProviderType()
-	models, err := api.GetModelsForProvider(clientType)
+	models, err := api.GetAvailableModels(clientType)
 	if err != nil {
 		return fmt.Errorf("failed to validate model: %w", err)
 	}
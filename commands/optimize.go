@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const optimizeUsage = "usage: /optimize on|off|stats"
+
+// OptimizeCommand implements the /optimize slash command
+type OptimizeCommand struct{}
+
+// Name returns the command name
+func (o *OptimizeCommand) Name() string {
+	return "optimize"
+}
+
+// Description returns the command description
+func (o *OptimizeCommand) Description() string {
+	return "Toggle conversation optimization for this session or show what it dropped (on|off|stats)"
+}
+
+// Execute toggles the optimizer or prints its statistics
+func (o *OptimizeCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 {
+		return o.printStats(chatAgent)
+	}
+
+	switch args[0] {
+	case "on":
+		chatAgent.SetConversationOptimization(true)
+		fmt.Println("🔄 Conversation optimization enabled for this session")
+	case "off":
+		chatAgent.SetConversationOptimization(false)
+		fmt.Println("⏸️  Conversation optimization disabled for this session - full tool output will be sent")
+	case "stats":
+		return o.printStats(chatAgent)
+	default:
+		return fmt.Errorf(optimizeUsage)
+	}
+	return nil
+}
+
+// printStats shows the optimizer settings, tracked state and last-pass drops
+func (o *OptimizeCommand) printStats(chatAgent *agent.Agent) error {
+	settings := chatAgent.GetOptimizerSettings()
+	stats := chatAgent.GetOptimizationStats()
+
+	fmt.Println("\n🔄 Conversation Optimization:")
+	fmt.Println("=====================================")
+	fmt.Printf("Settings:         %s\n", settings)
+	fmt.Printf("Tracked files:    %v\n", stats["tracked_files"])
+	fmt.Printf("Tracked commands: %v\n", stats["tracked_commands"])
+
+	drops := chatAgent.GetLastOptimizationDrops()
+	if len(drops) == 0 {
+		fmt.Println("\nNothing was dropped in the last request.")
+		return nil
+	}
+
+	totalSaved := 0
+	fmt.Printf("\nDropped in the last request (%d message(s)):\n", len(drops))
+	for _, drop := range drops {
+		target := drop.Target
+		if target == "" {
+			target = "-"
+		}
+		fmt.Printf("  #%-4d %-18s %-40s %6d chars\n", drop.MessageIndex, drop.Reason, target, drop.CharsSaved)
+		totalSaved += drop.CharsSaved
+	}
+	fmt.Printf("Total saved: %d chars (~%d tokens)\n", totalSaved, totalSaved/4)
+	return nil
+}
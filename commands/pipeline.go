@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const pipelineUsage = "usage: /pipeline <task description>"
+
+// PipelineCommand implements the /pipeline slash command
+type PipelineCommand struct{}
+
+// Name returns the command name
+func (p *PipelineCommand) Name() string {
+	return "pipeline"
+}
+
+// Description returns the command description
+func (p *PipelineCommand) Description() string {
+	return "Run a task through separate planner, implementer and reviewer agents"
+}
+
+// Execute runs the planner -> implementer -> reviewer pipeline for a task
+func (p *PipelineCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	task := strings.TrimSpace(strings.Join(args, " "))
+	if task == "" {
+		return fmt.Errorf(pipelineUsage)
+	}
+	return RunPipeline(task, chatAgent)
+}
+
+// RunPipeline runs the pipeline with the configured models and prints the outcome
+func RunPipeline(task string, chatAgent *agent.Agent) error {
+	cfg := agent.LoadPipelineConfig(chatAgent.GetConfigManager().GetConfig())
+	cfg.Provider, cfg.Model = chatAgent.GetProviderType(), chatAgent.GetModel()
+	cfg.AutoApprove = chatAgent.GetAutoApprove()
+	cfg.Sandbox = chatAgent.GetSandboxMode()
+	fmt.Printf("🔗 Pipeline: planner=%s implementer=%s reviewer=%s, up to %d fix round(s)\n",
+		modelOrDefault(cfg.PlannerModel, cfg.Model), modelOrDefault(cfg.ImplementerModel, cfg.Model), modelOrDefault(cfg.ReviewerModel, cfg.Model), cfg.ReviewRounds)
+
+	result, err := agent.RunPipeline(task, cfg)
+	if err != nil {
+		return err
+	}
+
+	final := result.Reviews[len(result.Reviews)-1]
+	fmt.Println("\n=====================================")
+	if final.Approved {
+		fmt.Printf("✅ Pipeline finished - approved after %d review(s)\n", len(result.Reviews))
+	} else {
+		fmt.Printf("⚠️  Pipeline finished with %d unresolved finding(s) - please check them\n", len(final.Findings))
+	}
+	fmt.Printf("💵 Pipeline cost: $%.6f\n", result.TotalCost)
+	return nil
+}
+
+// modelOrDefault labels a stage model, which defaults to the session's
+func modelOrDefault(model, sessionModel string) string {
+	if model == "" {
+		return sessionModel
+	}
+	return model
+}
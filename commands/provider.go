@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alantheprice/coder/agent"
 	"github.com/alantheprice/coder/api"
 	"github.com/alantheprice/coder/config"
 )
 
+// providerCheckTimeout bounds the connectivity check of each provider
+const providerCheckTimeout = 10 * time.Second
+
 // ProviderCommand implements the /provider slash command
 type ProviderCommand struct{}
 
@@ -56,14 +60,20 @@ func (p *ProviderCommand) showProviderStatus(configManager *config.Manager, chat
 	currentModel := chatAgent.GetModel()
 	fmt.Printf("✅ **Active Provider**: %s\n", api.GetProviderName(currentProvider))
 	fmt.Printf("🤖 **Current Model**: %s\n", currentModel)
+	printBalance(chatAgent)
+	if routing, ok := chatAgent.GetModelProfile()["provider"].(map[string]interface{}); ok && currentProvider == api.OpenRouterClientType {
+		fmt.Printf("🧭 **Routing**: %v\n", routing)
+	}
 	fmt.Println()
 
 	// Show status of all providers
 	status := configManager.GetProviderStatus()
+	checks := checkConnectivity(configManager.ListAvailableProviders())
 	fmt.Println("📋 All Providers:")
 	fmt.Println("------------------")
 
-	for providerType, info := range status {
+	for _, providerType := range config.AllProviders() {
+		info := status[providerType]
 		icon := "❌"
 		if info.Available {
 			icon = "✅"
@@ -87,11 +97,15 @@ func (p *ProviderCommand) showProviderStatus(configManager *config.Manager, chat
 			if providerType == api.OllamaClientType {
 				if info.Available {
 					fmt.Printf("   Status: ✅ Running\n")
+					fmt.Printf("   Recommended for this machine: %s\n", api.LocalModelSuggestion())
 				} else {
 					fmt.Printf("   Status: ❌ Not running\n")
 				}
 			}
 		}
+		if check, ok := checks[providerType]; ok {
+			fmt.Printf("   Connection: %s\n", formatProviderCheck(check))
+		}
 		fmt.Println()
 	}
 
@@ -114,6 +128,7 @@ func (p *ProviderCommand) listProviders(configManager *config.Manager) error {
 		return nil
 	}
 
+	checks := checkConnectivity(available)
 	fmt.Println("\n✅ Available Providers:")
 	fmt.Println("=======================")
 
@@ -121,11 +136,33 @@ func (p *ProviderCommand) listProviders(configManager *config.Manager) error {
 		name := api.GetProviderName(provider)
 		model := configManager.GetModelForProvider(provider)
 		fmt.Printf("%d. **%s** - %s\n", i+1, name, model)
+		fmt.Printf("   Connection: %s\n", formatProviderCheck(checks[provider]))
 	}
 
 	return nil
 }
 
+// checkConnectivity checks the providers concurrently, keyed by provider
+func checkConnectivity(providers []api.ClientType) map[api.ClientType]api.ProviderCheck {
+	checks := make(map[api.ClientType]api.ProviderCheck)
+	if len(providers) == 0 {
+		return checks
+	}
+	fmt.Printf("🔌 Checking connectivity of %d provider(s)...\n", len(providers))
+	for _, check := range api.CheckProviders(providers, providerCheckTimeout) {
+		checks[check.Provider] = check
+	}
+	return checks
+}
+
+// formatProviderCheck describes the outcome of a connectivity check
+func formatProviderCheck(check api.ProviderCheck) string {
+	if check.Err != nil {
+		return fmt.Sprintf("❌ %v", check.Err)
+	}
+	return fmt.Sprintf("✅ Reachable (%d models, %v)", check.Models, check.Latency.Round(time.Millisecond))
+}
+
 // selectProvider allows interactive provider selection
 func (p *ProviderCommand) selectProvider(configManager *config.Manager, chatAgent *agent.Agent) error {
 	available := configManager.ListAvailableProviders()
@@ -177,7 +214,11 @@ func (p *ProviderCommand) setProvider(providerName string, configManager *config
 	// Convert name to provider type
 	provider, err := config.GetProviderFromConfigName(strings.ToLower(providerName))
 	if err != nil {
-		return fmt.Errorf("unknown provider '%s'. Available: deepinfra, ollama, cerebras, openrouter, groq, deepseek", providerName)
+		var names []string
+		for _, provider := range config.AllProviders() {
+			names = append(names, string(provider))
+		}
+		return fmt.Errorf("unknown provider '%s'. Available: %s", providerName, strings.Join(names, ", "))
 	}
 
 	// Check if provider is available
@@ -204,15 +245,9 @@ func (p *ProviderCommand) switchToProvider(provider api.ClientType, configManage
 
 	fmt.Printf("🔄 Switching to %s with model %s...\n", api.GetProviderName(provider), model)
 
-	// Persist the provider selection to configuration
-	err := configManager.SetProviderAndModel(provider, model)
-	if err != nil {
-		return fmt.Errorf("failed to persist provider selection: %w", err)
-	}
-
-	// Switch the agent to use the new provider and model immediately
-	err = chatAgent.SetModel(model)
-	if err != nil {
+	// Recreate the client for this provider; the model alone could resolve to
+	// another provider that serves a model of the same name
+	if err := chatAgent.SetProvider(provider, model); err != nil {
 		return fmt.Errorf("failed to switch to provider %s: %w", api.GetProviderName(provider), err)
 	}
 
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const recallUsage = `usage: /recall "<question>" (e.g. /recall "what did we change in the auth flow last week?")`
+
+// recallDiffLines is how much of each cited task's diff is shown
+const recallDiffLines = 40
+
+// RecallCommand implements the /recall slash command
+type RecallCommand struct{}
+
+// Name returns the command name
+func (r *RecallCommand) Name() string {
+	return "recall"
+}
+
+// Description returns the command description
+func (r *RecallCommand) Description() string {
+	return "Answer a question about earlier sessions, citing the tasks and diffs it came from"
+}
+
+// Execute searches the archived tasks and answers from the best matches
+func (r *RecallCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	question := strings.Trim(strings.Join(args, " "), `"' `)
+	if question == "" {
+		return fmt.Errorf(recallUsage)
+	}
+
+	fmt.Println("🔎 Searching earlier sessions...")
+	recall, err := chatAgent.Recall(question)
+	if err != nil {
+		return fmt.Errorf("recall failed: %w", err)
+	}
+
+	fmt.Printf("\n%s\n", recall.Answer)
+	if len(recall.Sources) == 0 {
+		return nil
+	}
+
+	fmt.Println("\n📎 Sources:")
+	for _, source := range recall.Sources {
+		task := strings.Join(strings.Fields(source.Task), " ")
+		if len(task) > 80 {
+			task = task[:77] + "..."
+		}
+		fmt.Printf("  [%s] %s  %s\n", source.ID, source.Time.Format("2006-01-02 15:04"), task)
+		if len(source.Files) > 0 {
+			fmt.Printf("      files: %s\n", strings.Join(source.Files, ", "))
+		}
+		if source.Diff != "" {
+			lines := strings.Split(strings.TrimRight(source.Diff, "\n"), "\n")
+			if len(lines) > recallDiffLines {
+				lines = append(lines[:recallDiffLines], fmt.Sprintf("... (%d more lines)", len(lines)-recallDiffLines))
+			}
+			for _, line := range lines {
+				fmt.Printf("      %s\n", line)
+			}
+		}
+	}
+	return nil
+}
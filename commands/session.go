@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// SessionCommand implements the /session slash command
+type SessionCommand struct{}
+
+// Name returns the command name
+func (s *SessionCommand) Name() string {
+	return "session"
+}
+
+// Description returns the command description
+func (s *SessionCommand) Description() string {
+	return "Save, list and resume named sessions (/session save|list|resume <name>)"
+}
+
+// Execute saves, lists or resumes named sessions
+func (s *SessionCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: /session save <name> | list | resume <name>")
+	}
+
+	switch args[0] {
+	case "save":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: /session save <name>")
+		}
+		path, err := chatAgent.SaveNamedSession(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("💾 Session %s saved to %s\n", args[1], path)
+		fmt.Printf("   Resume it with /session resume %s or ./coder --resume=%s\n", args[1], args[1])
+		return nil
+	case "list":
+		sessions, err := agent.ListNamedSessions()
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No saved sessions yet - save one with /session save <name>")
+			return nil
+		}
+		fmt.Println("💾 Saved sessions:")
+		for _, session := range sessions {
+			fmt.Printf("  %-20s %s  %3d messages  $%.4f  %s  %s\n", session.Name,
+				session.LastUpdated.Format("2006-01-02 15:04"), len(session.Messages), session.TotalCost, session.Model, session.Project)
+		}
+		return nil
+	case "resume":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: /session resume <name>")
+		}
+		session, err := chatAgent.ResumeNamedSession(args[1])
+		if err != nil {
+			return err
+		}
+		PrintResumedSession(session)
+		return nil
+	default:
+		return fmt.Errorf("unknown subcommand: %s. Use: save, list, resume", args[0])
+	}
+}
+
+// PrintResumedSession reports what a resumed session restored
+func PrintResumedSession(session *agent.NamedSession) {
+	fmt.Printf("▶️  Resumed session %s from %s: %d messages, %d todos, %d tokens, $%.4f\n", session.Name,
+		session.LastUpdated.Format("2006-01-02 15:04"), len(session.Messages), len(session.Todos), session.TotalTokens, session.TotalCost)
+	if session.Model != "" {
+		fmt.Printf("   Saved with %s via %s\n", session.Model, session.Provider)
+	}
+	fmt.Println("   Your next message continues the conversation")
+}
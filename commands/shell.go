@@ -94,8 +94,10 @@ If the description is "show disk usage", output: "df -h"
 	fmt.Printf("✅ Executing command...\n")
 	fmt.Printf("=====================================\n")
 
-	// Execute the shell command
-	resultOutput, err := tools.ExecuteShellCommand(generatedCommand)
+	// Execute the shell command; Ctrl+C cancels it
+	ctx, end := chatAgent.BeginOperation()
+	defer end()
+	resultOutput, err := tools.ExecuteShellCommand(ctx, generatedCommand)
 	if err != nil {
 		return fmt.Errorf("command failed: %v\nOutput: %s", err, resultOutput)
 	}
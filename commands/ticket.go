@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/integrations"
+	"github.com/chzyer/readline"
+)
+
+const ticketUsage = "usage: /ticket [show|start|comment|done] <KEY> | /ticket login <jira|linear>"
+
+// TicketCommand implements the /ticket slash command for Jira and Linear
+type TicketCommand struct{}
+
+// Name returns the command name
+func (t *TicketCommand) Name() string {
+	return "ticket"
+}
+
+// Description returns the command description
+func (t *TicketCommand) Description() string {
+	return "Work on Jira/Linear tickets - show, start, comment, done, login"
+}
+
+// Execute runs the ticket command
+func (t *TicketCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) < 2 {
+		return fmt.Errorf(ticketUsage)
+	}
+
+	if args[0] == "login" {
+		return t.login(args[1])
+	}
+
+	tracker, err := integrations.NewTicketTracker(t.preferredTracker(chatAgent))
+	if err != nil {
+		return err
+	}
+
+	key := strings.ToUpper(args[1])
+	switch args[0] {
+	case "show":
+		return t.show(tracker, key)
+	case "start":
+		return t.start(tracker, key, chatAgent)
+	case "comment":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: /ticket comment <KEY> <text>")
+		}
+		if err := tracker.PostComment(key, strings.Join(args[2:], " ")); err != nil {
+			return fmt.Errorf("failed to post comment: %w", err)
+		}
+		fmt.Printf("💬 Comment posted to %s\n", key)
+		return nil
+	case "done":
+		if err := tracker.CompleteTicket(key); err != nil {
+			return fmt.Errorf("failed to transition ticket: %w", err)
+		}
+		fmt.Printf("✅ %s marked as done\n", key)
+		return nil
+	default:
+		return fmt.Errorf(ticketUsage)
+	}
+}
+
+// preferredTracker returns the tracker configured via the "ticket_tracker" preference
+func (t *TicketCommand) preferredTracker(chatAgent *agent.Agent) string {
+	if chatAgent == nil || chatAgent.GetConfigManager() == nil {
+		return ""
+	}
+	return chatAgent.GetConfigManager().GetConfig().GetStringPreference("ticket_tracker", "")
+}
+
+// show prints the ticket as it would be given to the agent
+func (t *TicketCommand) show(tracker integrations.TicketTracker, key string) error {
+	ticket, err := tracker.FetchTicket(key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+
+	fmt.Printf("🎫 %s [%s]\n\n", ticket.Key, ticket.Status)
+	fmt.Println(integrations.FormatTicketPrompt(ticket))
+	return nil
+}
+
+// start fetches the ticket, runs the agent on it and reports progress back
+func (t *TicketCommand) start(tracker integrations.TicketTracker, key string, chatAgent *agent.Agent) error {
+	ticket, err := tracker.FetchTicket(key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ticket: %w", err)
+	}
+
+	fmt.Printf("🎫 Starting %s: %s\n", ticket.Key, ticket.Title)
+	if err := tracker.PostComment(key, "🤖 coder started working on this ticket."); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to post progress comment: %v\n", err)
+	}
+
+	response, err := chatAgent.ProcessQuery(integrations.FormatTicketPrompt(ticket))
+	if err != nil {
+		comment := fmt.Sprintf("🤖 coder stopped with an error: %v", err)
+		if postErr := tracker.PostComment(key, comment); postErr != nil {
+			fmt.Printf("⚠️  Warning: Failed to post progress comment: %v\n", postErr)
+		}
+		return fmt.Errorf("agent failed on %s: %w", key, err)
+	}
+
+	fmt.Println(response)
+
+	comment := fmt.Sprintf("🤖 coder finished:\n\n%s\n\n%s", response, chatAgent.GenerateActionSummary())
+	if err := tracker.PostComment(key, comment); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to post progress comment: %v\n", err)
+	}
+
+	fmt.Printf("Mark %s as done? (y/N): ", key)
+	reader := bufio.NewReader(os.Stdin)
+	input, _ := reader.ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(input)) != "y" {
+		fmt.Println("Ticket status left unchanged.")
+		return nil
+	}
+
+	if err := tracker.CompleteTicket(key); err != nil {
+		return fmt.Errorf("failed to transition ticket: %w", err)
+	}
+	fmt.Printf("✅ %s marked as done\n", key)
+	return nil
+}
+
+// readSecret reads a line from stdin, without echoing it when stdin is a terminal
+func readSecret() string {
+	if fd := int(os.Stdin.Fd()); readline.IsTerminal(fd) {
+		secret, _ := readline.ReadPassword(fd)
+		fmt.Println()
+		return string(secret)
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return line
+}
+
+// login stores a tracker API token in the OS keyring
+func (t *TicketCommand) login(trackerName string) error {
+	trackerName = strings.ToLower(trackerName)
+	if trackerName != "jira" && trackerName != "linear" {
+		return fmt.Errorf("unknown ticket tracker: %s (supported: jira, linear)", trackerName)
+	}
+
+	fmt.Printf("Enter %s API token: ", trackerName)
+	token := strings.TrimSpace(readSecret())
+	if token == "" {
+		return fmt.Errorf("no token provided")
+	}
+
+	if err := config.SetSecret(trackerName, token); err != nil {
+		return err
+	}
+	fmt.Printf("🔑 %s token saved to keyring\n", trackerName)
+	return nil
+}
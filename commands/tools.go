@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+const toolsUsage = "usage: /tools [list|enable <name>...|disable <name>...|preset [name]]"
+
+// ToolsCommand implements the /tools slash command
+type ToolsCommand struct{}
+
+// Name returns the command name
+func (t *ToolsCommand) Name() string {
+	return "tools"
+}
+
+// Description returns the command description
+func (t *ToolsCommand) Description() string {
+	return "List the model's tools, enable/disable them or apply a preset for this session"
+}
+
+// Execute lists, enables or disables tools
+func (t *ToolsCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if len(args) == 0 || args[0] == "list" {
+		if preset := chatAgent.ToolPreset(); preset != "" {
+			fmt.Printf("🧰 Tools (preset %s):\n", preset)
+		} else {
+			fmt.Println("🧰 Tools:")
+		}
+		for _, name := range agent.ToolNames() {
+			if chatAgent.IsToolEnabled(name) {
+				fmt.Printf("  ✅ %s\n", name)
+			} else {
+				fmt.Printf("  🚫 %s (disabled)\n", name)
+			}
+		}
+		return nil
+	}
+
+	if args[0] == "preset" {
+		if len(args) == 1 {
+			cfg := chatAgent.GetConfigManager().GetConfig()
+			fmt.Println("🧰 Tool presets:")
+			for _, name := range cfg.ToolPresetNames() {
+				preset, _ := cfg.GetToolPreset(name)
+				fmt.Printf("  %s: %s\n", name, strings.Join(preset, " "))
+			}
+			return nil
+		}
+		if err := chatAgent.ApplyToolPreset(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("🧰 Tool preset %s applied: %d of %d tools enabled\n", args[1], len(agent.ToolNames())-len(chatAgent.DisabledTools()), len(agent.ToolNames()))
+		return nil
+	}
+
+	if len(args) < 2 || (args[0] != "enable" && args[0] != "disable") {
+		return fmt.Errorf(toolsUsage)
+	}
+	enabled := args[0] == "enable"
+	for _, name := range args[1:] {
+		if err := chatAgent.SetToolEnabled(name, enabled); err != nil {
+			return err
+		}
+		if enabled {
+			fmt.Printf("✅ %s enabled\n", name)
+		} else {
+			fmt.Printf("🚫 %s disabled for this session\n", name)
+		}
+	}
+	return nil
+}
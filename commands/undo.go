@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// UndoCommand implements the /undo slash command
+type UndoCommand struct{}
+
+// Name returns the command name
+func (u *UndoCommand) Name() string {
+	return "undo"
+}
+
+// Description returns the command description
+func (u *UndoCommand) Description() string {
+	return "Revert the agent's last file change (/undo list, /undo <n> for a specific change)"
+}
+
+// Execute reverts a journaled file change or lists the journal
+func (u *UndoCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	journal := chatAgent.ChangeJournal()
+	if len(args) > 0 && args[0] == "list" {
+		if len(journal) == 0 {
+			fmt.Println("No file changes to undo this session")
+			return nil
+		}
+		fmt.Printf("📜 %d file change(s) this session:\n", len(journal))
+		for _, entry := range journal {
+			fmt.Printf("  %s\n", agent.FormatJournalEntry(entry))
+		}
+		fmt.Println("Use /undo to revert the most recent change, or /undo <n> for change #n")
+		return nil
+	}
+
+	index := 0
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return fmt.Errorf("usage: /undo [list|<n>]")
+		}
+		index = n
+	}
+	entry, err := chatAgent.UndoChange(index)
+	if err != nil {
+		return err
+	}
+	if entry.Existed {
+		fmt.Printf("↩️  Undid change #%d: restored %s\n", entry.Index, entry.Path)
+	} else {
+		fmt.Printf("↩️  Undid change #%d: removed %s, which it created\n", entry.Index, entry.Path)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// UnloadCommand implements the /unload slash command
+type UnloadCommand struct{}
+
+// Name returns the command name
+func (u *UnloadCommand) Name() string {
+	return "unload"
+}
+
+// Description returns the command description
+func (u *UnloadCommand) Description() string {
+	return "Unload the local model to free GPU memory (it reloads on the next request)"
+}
+
+// Execute unloads the current local model
+func (u *UnloadCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	if err := chatAgent.UnloadLocalModel(); err != nil {
+		return err
+	}
+	fmt.Printf("🧹 Unloaded %s; it will load again on the next request\n", chatAgent.GetModel())
+	return nil
+}
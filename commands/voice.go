@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/tools"
+)
+
+const voiceUsage = "usage: /voice [on|off|status]"
+
+// prefVoiceInput is the preference that turns voice prompting on
+const prefVoiceInput = "voice_input"
+
+// VoiceCommand implements the /voice slash command
+type VoiceCommand struct{}
+
+// Name returns the command name
+func (v *VoiceCommand) Name() string {
+	return "voice"
+}
+
+// Description returns the command description
+func (v *VoiceCommand) Description() string {
+	return "Toggle voice input - press Enter on an empty line to speak a query (on|off|status)"
+}
+
+// Execute toggles voice input or shows its configuration
+func (v *VoiceCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	configManager := chatAgent.GetConfigManager()
+	enabled := VoiceInputEnabled(chatAgent)
+
+	action := "toggle"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "toggle":
+		enabled = !enabled
+	case "on":
+		enabled = true
+	case "off":
+		enabled = false
+	case "status":
+		cfg := tools.LoadVoiceConfig(configManager.GetConfig())
+		fmt.Printf("🎤 Voice input: %s\n", onOff(enabled))
+		fmt.Printf("   Transcription: %s (model %s)\n", cfg.Provider, cfg.Model)
+		fmt.Printf("   Max recording: %ds, stop on silence: %t\n", cfg.MaxSeconds, cfg.SilenceStops)
+		return nil
+	default:
+		return fmt.Errorf(voiceUsage)
+	}
+
+	if err := configManager.SetPreference(prefVoiceInput, enabled); err != nil {
+		return fmt.Errorf("failed to save voice setting: %w", err)
+	}
+	if enabled {
+		fmt.Println("🎤 Voice input enabled - press Enter on an empty line to speak")
+	} else {
+		fmt.Println("⌨️  Voice input disabled")
+	}
+	return nil
+}
+
+// VoiceInputEnabled reports whether empty input lines should start a recording
+func VoiceInputEnabled(chatAgent *agent.Agent) bool {
+	return chatAgent.GetConfigManager().GetConfig().GetBoolPreference(prefVoiceInput, false)
+}
+
+// onOff formats a toggle state
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
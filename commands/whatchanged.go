@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/alantheprice/coder/agent"
+)
+
+// WhatChangedCommand implements the /whatchanged slash command
+type WhatChangedCommand struct{}
+
+// Name returns the command name
+func (w *WhatChangedCommand) Name() string {
+	return "whatchanged"
+}
+
+// Description returns the command description
+func (w *WhatChangedCommand) Description() string {
+	return "List every file changed since the session started (including shell-made changes)"
+}
+
+// Execute compares the workspace with the snapshot taken at the first task
+func (w *WhatChangedCommand) Execute(args []string, chatAgent *agent.Agent) error {
+	snapshotTime := chatAgent.GetWorkspaceSnapshotTime()
+	if snapshotTime.IsZero() {
+		fmt.Println("No workspace snapshot yet - it is captured when the first task starts.")
+		return nil
+	}
+
+	changes, err := chatAgent.GetWorkspaceChanges()
+	if err != nil {
+		return fmt.Errorf("failed to compare workspace: %w", err)
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("✅ No files changed since %s\n", snapshotTime.Format("15:04:05"))
+		return nil
+	}
+
+	fmt.Printf("📂 %d file(s) changed since %s:\n", len(changes), snapshotTime.Format("15:04:05"))
+	icons := map[string]string{"added": "➕", "modified": "✏️ ", "deleted": "➖"}
+	outsideTools := 0
+	for _, change := range changes {
+		source := ""
+		if !change.ViaTools {
+			source = "  (outside write_file/edit_file)"
+			outsideTools++
+		}
+		fmt.Printf("  %s %-8s %s%s\n", icons[change.Status], change.Status, change.Path, source)
+	}
+
+	if outsideTools > 0 {
+		fmt.Printf("\n💡 %d change(s) came from shell commands or other processes\n", outsideTools)
+	}
+	return nil
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/alantheprice/coder/api"
 )
@@ -15,6 +18,9 @@ type Config struct {
 	ProviderModels   map[string]string         `json:"provider_models"`
 	ProviderPriority []string                  `json:"provider_priority"`
 	Preferences      map[string]interface{}    `json:"preferences"`
+	ModelProfiles    map[string]map[string]interface{} `json:"model_profiles,omitempty"`
+	ToolPresets      map[string][]string       `json:"tool_presets,omitempty"`
+	ShellPostProcessors []ShellPostProcessor `json:"shell_post_processors,omitempty"`
 	Version          string                    `json:"version"`
 }
 
@@ -36,7 +42,7 @@ func NewConfig() *Config {
 			"groq":       api.GetDefaultModelForProvider(api.GroqClientType),
 			"deepseek":   api.GetDefaultModelForProvider(api.DeepSeekClientType),
 		},
-		ProviderPriority: []string{"openrouter", "deepinfra", "ollama", "cerebras", "groq", "deepseek"},
+		ProviderPriority: []string{"openrouter", "deepinfra", "ollama", "cerebras", "groq", "deepseek", "mistral", "xai", "azure", "huggingface", "anthropic", "openai", "gemini"},
 		Preferences:      make(map[string]interface{}),
 		Version:          ConfigVersion,
 	}
@@ -114,7 +120,10 @@ func (c *Config) Save() error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	
-	return os.WriteFile(configPath, data, 0600)
+	// Other instances may be saving their provider selection at the same time
+	return WithFileLock(configPath, func() error {
+		return WriteFileAtomic(configPath, data, 0600)
+	})
 }
 
 // Validate validates the configuration and migrates if necessary
@@ -138,6 +147,14 @@ func (c *Config) Validate() error {
 		{"openrouter", api.OpenRouterClientType},
 		{"groq", api.GroqClientType},
 		{"deepseek", api.DeepSeekClientType},
+		{"azure", api.AzureOpenAIClientType},
+		{"bedrock", api.BedrockClientType},
+		{"mistral", api.MistralClientType},
+		{"xai", api.XAIClientType},
+		{"huggingface", api.HuggingFaceClientType},
+		{"anthropic", api.AnthropicClientType},
+		{"openai", api.OpenAIClientType},
+		{"gemini", api.GeminiClientType},
 	}
 	
 	for _, provider := range providers {
@@ -148,7 +165,7 @@ func (c *Config) Validate() error {
 	
 	// Set default priority if empty
 	if len(c.ProviderPriority) == 0 {
-		c.ProviderPriority = []string{"deepinfra", "ollama", "cerebras", "openrouter", "groq", "deepseek"}
+		c.ProviderPriority = []string{"deepinfra", "ollama", "cerebras", "openrouter", "groq", "deepseek", "mistral", "xai", "azure", "huggingface", "anthropic", "openai", "gemini"}
 	}
 	
 	return nil
@@ -199,6 +216,22 @@ func getProviderConfigName(clientType api.ClientType) string {
 		return "groq"
 	case api.DeepSeekClientType:
 		return "deepseek"
+	case api.AzureOpenAIClientType:
+		return "azure"
+	case api.BedrockClientType:
+		return "bedrock"
+	case api.MistralClientType:
+		return "mistral"
+	case api.XAIClientType:
+		return "xai"
+	case api.HuggingFaceClientType:
+		return "huggingface"
+	case api.AnthropicClientType:
+		return "anthropic"
+	case api.OpenAIClientType:
+		return "openai"
+	case api.GeminiClientType:
+		return "gemini"
 	default:
 		return string(clientType)
 	}
@@ -219,7 +252,156 @@ func GetProviderFromConfigName(name string) (api.ClientType, error) {
 		return api.GroqClientType, nil
 	case "deepseek":
 		return api.DeepSeekClientType, nil
+	case "azure":
+		return api.AzureOpenAIClientType, nil
+	case "bedrock":
+		return api.BedrockClientType, nil
+	case "mistral":
+		return api.MistralClientType, nil
+	case "xai":
+		return api.XAIClientType, nil
+	case "huggingface":
+		return api.HuggingFaceClientType, nil
+	case "anthropic":
+		return api.AnthropicClientType, nil
+	case "openai":
+		return api.OpenAIClientType, nil
+	case "gemini":
+		return api.GeminiClientType, nil
 	default:
 		return "", fmt.Errorf("unknown provider: %s", name)
 	}
-}
\ No newline at end of file
+}
+
+// GetStringPreference returns a string preference, or def if unset
+func (c *Config) GetStringPreference(key, def string) string {
+	if value, ok := c.Preferences[key].(string); ok && value != "" {
+		return value
+	}
+	return def
+}
+
+// GetBoolPreference returns a boolean preference, or def if unset
+func (c *Config) GetBoolPreference(key string, def bool) bool {
+	if value, ok := c.Preferences[key].(bool); ok {
+		return value
+	}
+	return def
+}
+
+// GetIntPreference returns an integer preference, or def if unset
+func (c *Config) GetIntPreference(key string, def int) int {
+	// JSON numbers decode as float64
+	switch value := c.Preferences[key].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	}
+	return def
+}
+
+// GetFloatPreference returns a numeric preference, or def if unset
+func (c *Config) GetFloatPreference(key string, def float64) float64 {
+	switch value := c.Preferences[key].(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	}
+	return def
+}
+
+// GetModelProfile returns the request parameters configured for a model.
+// Profile keys are model IDs or patterns where * matches anything ("deepseek/*"); matching
+// patterns apply in key order and an exact model ID is applied last.
+func (c *Config) GetModelProfile(model string) map[string]interface{} {
+	var patterns []string
+	for key := range c.ModelProfiles {
+		if key != model {
+			if matchesModelPattern(key, model) {
+				patterns = append(patterns, key)
+			}
+		}
+	}
+	sort.Strings(patterns)
+	if _, ok := c.ModelProfiles[model]; ok {
+		patterns = append(patterns, model)
+	}
+
+	if len(patterns) == 0 {
+		return nil
+	}
+	profile := make(map[string]interface{})
+	for _, key := range patterns {
+		for name, value := range c.ModelProfiles[key] {
+			profile[name] = value
+		}
+	}
+	return profile
+}
+
+// matchesModelPattern reports whether a model ID matches a profile pattern
+func matchesModelPattern(pattern, model string) bool {
+	if !strings.Contains(pattern, "*") {
+		return false
+	}
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, model)
+	return matched
+}
+
+// DefaultToolPresets are the tool presets available without configuration.
+// Entries are tool names; "*" adds every tool and "-name" removes one.
+var DefaultToolPresets = map[string][]string{
+	"full":    {"*"},
+	"explore": {"shell_command", "read_file", "read_notebook", "summarize_schema", "list_targets", "list_directory", "glob", "search_code", "fetch_full_result", "analyze_image_content", "analyze_ui_screenshot"},
+	"ci":      {"*", "-verify_frontend", "-compare_images", "-analyze_ui_screenshot"},
+}
+
+// GetToolPreset returns a named tool preset, preferring the configured
+// tool_presets over the defaults
+func (c *Config) GetToolPreset(name string) ([]string, bool) {
+	if preset, ok := c.ToolPresets[name]; ok {
+		return preset, true
+	}
+	preset, ok := DefaultToolPresets[name]
+	return preset, ok
+}
+
+// ToolPresetNames returns the names of the configured and default tool presets, sorted
+func (c *Config) ToolPresetNames() []string {
+	var names []string
+	for name := range DefaultToolPresets {
+		names = append(names, name)
+	}
+	for name := range c.ToolPresets {
+		if _, ok := DefaultToolPresets[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ShellPostProcessor rewrites the output of matching shell commands before
+// it is added to the conversation
+type ShellPostProcessor struct {
+	Match   string   `json:"match"`             // regular expression on the command line; empty matches every command
+	Filters []string `json:"filters,omitempty"` // built-in filters, applied in order
+	Pipe    string   `json:"pipe,omitempty"`    // shell command the filtered output is piped through
+}
+
+// DefaultShellPostProcessors apply when shell_post_processors isn't configured
+var DefaultShellPostProcessors = []ShellPostProcessor{
+	{Match: `\bgo test\b`, Filters: []string{"go_test_failures"}},
+}
+
+// GetShellPostProcessors returns the configured shell post-processors, or the
+// defaults when none are configured
+func (c *Config) GetShellPostProcessors() []ShellPostProcessor {
+	if c.ShellPostProcessors != nil {
+		return c.ShellPostProcessors
+	}
+	return DefaultShellPostProcessors
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeyringService is the service name under which coder stores its secrets
+const KeyringService = "coder"
+
+// GetSecret looks up a secret for the given account. The environment variable
+// (if provided) takes precedence, then the OS keyring is consulted.
+func GetSecret(account, envVar string) (string, error) {
+	if envVar != "" {
+		if value := os.Getenv(envVar); value != "" {
+			return value, nil
+		}
+	}
+
+	value, err := keyringGet(account)
+	if err != nil {
+		if envVar != "" {
+			return "", fmt.Errorf("%s not set and no keyring entry for %q: %w", envVar, account, err)
+		}
+		return "", fmt.Errorf("no keyring entry for %q: %w", account, err)
+	}
+	return value, nil
+}
+
+// SetSecret stores a secret for the given account in the OS keyring. The
+// secret is passed on stdin, never as an argument other processes could see.
+func SetSecret(account, value string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// security's interactive mode reads the command from stdin; -X takes the secret hex encoded, so it needs no quoting
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", KeyringService, account, hex.EncodeToString([]byte(value))))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", KeyringService+" "+account, "service", KeyringService, "account", account)
+		cmd.Stdin = strings.NewReader(value)
+	default:
+		return fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to store secret: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// keyringGet reads a secret from the OS keyring using the platform CLI tools
+func keyringGet(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", KeyringService, "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", KeyringService, "account", account)
+	default:
+		return "", fmt.Errorf("keyring not supported on %s", runtime.GOOS)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSpace(string(output))
+	if value == "" {
+		return "", fmt.Errorf("empty keyring entry")
+	}
+	return value, nil
+}
@@ -9,7 +9,8 @@ import (
 
 // Manager handles configuration operations with intelligent fallbacks
 type Manager struct {
-	config *Config
+	config  *Config
+	project *ProjectConfig // pinned by the repository, nil when there is none
 }
 
 // NewManager creates a new configuration manager
@@ -19,9 +20,24 @@ func NewManager() (*Manager, error) {
 		return nil, err
 	}
 	
-	return &Manager{
-		config: config,
-	}, nil
+	manager := &Manager{config: config}
+	if wd, err := os.Getwd(); err == nil {
+		if manager.project, err = LoadProjectConfig(wd); err != nil {
+			return nil, err
+		}
+	}
+	return manager, nil
+}
+
+// ProjectConfig returns the repository's pinned selection, or nil
+func (m *Manager) ProjectConfig() *ProjectConfig {
+	return m.project
+}
+
+// IsPinned reports whether the project config pins the provider, in which case
+// the selection shouldn't replace the machine-wide last-used provider
+func (m *Manager) IsPinned(provider api.ClientType) bool {
+	return m.project != nil && m.project.Provider != "" && m.project.Provider == provider
 }
 
 // GetConfig returns the current configuration
@@ -30,11 +46,24 @@ func (m *Manager) GetConfig() *Config {
 }
 
 // GetBestProvider determines the best provider to use, considering:
-// 1. Last used provider (if still available)
-// 2. Environment variables
-// 3. Availability checks
-// 4. User preferences
+// 1. The provider pinned by the project config (if available)
+// 2. Last used provider (if still available)
+// 3. Environment variables
+// 4. Availability checks
+// 5. User preferences
 func (m *Manager) GetBestProvider() (api.ClientType, string, error) {
+	if m.project != nil && m.project.Provider != "" {
+		pinned := m.project.Provider
+		if m.isProviderAvailable(pinned) {
+			model := m.project.Model
+			if model == "" {
+				model = m.config.GetModelForProvider(pinned)
+			}
+			return pinned, model, nil
+		}
+		fmt.Printf("⚠️  %s pins %s, which is not available; falling back to your own selection\n", ProjectConfigFile, api.GetProviderName(pinned))
+	}
+
 	// Try last used provider first if it's available
 	lastProvider := m.config.GetLastUsedProvider()
 	if lastProvider != "" {
@@ -87,20 +116,31 @@ func (m *Manager) GetModelForProvider(provider api.ClientType) string {
 	return m.config.GetModelForProvider(provider)
 }
 
-// ListAvailableProviders returns all currently available providers
-func (m *Manager) ListAvailableProviders() []api.ClientType {
-	var available []api.ClientType
-	
-	allProviders := []api.ClientType{
+// AllProviders returns every provider, in the order they are listed
+func AllProviders() []api.ClientType {
+	return []api.ClientType{
 		api.DeepInfraClientType,
 		api.OllamaClientType,
 		api.CerebrasClientType,
 		api.OpenRouterClientType,
 		api.GroqClientType,
 		api.DeepSeekClientType,
+		api.AzureOpenAIClientType,
+		api.BedrockClientType,
+		api.MistralClientType,
+		api.XAIClientType,
+		api.HuggingFaceClientType,
+		api.AnthropicClientType,
+		api.OpenAIClientType,
+		api.GeminiClientType,
 	}
+}
+
+// ListAvailableProviders returns all currently available providers
+func (m *Manager) ListAvailableProviders() []api.ClientType {
+	var available []api.ClientType
 	
-	for _, provider := range allProviders {
+	for _, provider := range AllProviders() {
 		if m.isProviderAvailable(provider) {
 			available = append(available, provider)
 		}
@@ -142,6 +182,22 @@ func (m *Manager) getProviderEnvVar(provider api.ClientType) string {
 		return "GROQ_API_KEY"
 	case api.DeepSeekClientType:
 		return "DEEPSEEK_API_KEY"
+	case api.AzureOpenAIClientType:
+		return "AZURE_OPENAI_API_KEY"
+	case api.BedrockClientType:
+		return "AWS_ACCESS_KEY_ID"
+	case api.MistralClientType:
+		return "MISTRAL_API_KEY"
+	case api.XAIClientType:
+		return "XAI_API_KEY"
+	case api.HuggingFaceClientType:
+		return "HF_ENDPOINT_URL" // the token is optional for self-hosted TGI
+	case api.AnthropicClientType:
+		return "ANTHROPIC_API_KEY"
+	case api.OpenAIClientType:
+		return "OPENAI_API_KEY"
+	case api.GeminiClientType:
+		return "GEMINI_API_KEY"
 	case api.OllamaClientType:
 		return "" // Ollama doesn't use an API key
 	default:
@@ -153,16 +209,7 @@ func (m *Manager) getProviderEnvVar(provider api.ClientType) string {
 func (m *Manager) GetProviderStatus() map[api.ClientType]ProviderStatus {
 	status := make(map[api.ClientType]ProviderStatus)
 	
-	allProviders := []api.ClientType{
-		api.DeepInfraClientType,
-		api.OllamaClientType,
-		api.CerebrasClientType,
-		api.OpenRouterClientType,
-		api.GroqClientType,
-		api.DeepSeekClientType,
-	}
-	
-	for _, provider := range allProviders {
+	for _, provider := range AllProviders() {
 		status[provider] = ProviderStatus{
 			Available:     m.isProviderAvailable(provider),
 			Name:          api.GetProviderName(provider),
@@ -201,4 +248,13 @@ func (m *Manager) UpdateProviderPriority(priority []string) error {
 func (m *Manager) Reset() error {
 	m.config = NewConfig()
 	return m.config.Save()
-}
\ No newline at end of file
+}
+
+// SetPreference stores a user preference and persists the configuration
+func (m *Manager) SetPreference(key string, value interface{}) error {
+	if m.config.Preferences == nil {
+		m.config.Preferences = make(map[string]interface{})
+	}
+	m.config.Preferences[key] = value
+	return m.config.Save()
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// ProjectConfigFile pins the provider and model for a repository, relative to
+// the project root. It is committed with the project so every contributor gets
+// the same behavior.
+const ProjectConfigFile = ".coder.yaml"
+
+// ProjectConfig is the per-repository selection read from ProjectConfigFile.
+// It overrides the machine-wide last-used provider without replacing it.
+type ProjectConfig struct {
+	Provider    api.ClientType
+	Model       string
+	Temperature *float64
+}
+
+// LoadProjectConfig reads the project config from root. A missing file is not
+// an error and returns nil.
+func LoadProjectConfig(root string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(filepath.Join(root, ProjectConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ProjectConfigFile, err)
+	}
+	return ParseProjectConfig(string(data))
+}
+
+// ParseProjectConfig parses a flat YAML document of provider, model and
+// temperature keys. Comments and quoted values are supported; nested keys are
+// not, since nothing in the file needs them.
+func ParseProjectConfig(data string) (*ProjectConfig, error) {
+	project := &ProjectConfig{}
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("%s line %d: nested values are not supported", ProjectConfigFile, i+1)
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: expected key: value", ProjectConfigFile, i+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("%s line %d: %q has no value (nested values are not supported)", ProjectConfigFile, i+1, key)
+		}
+		value = unquoteYAML(value)
+
+		switch key {
+		case "provider":
+			provider, err := GetProviderFromConfigName(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", ProjectConfigFile, i+1, err)
+			}
+			project.Provider = provider
+		case "model":
+			project.Model = value
+		case "temperature":
+			temperature, err := strconv.ParseFloat(value, 64)
+			if err != nil || temperature < 0 || temperature > 2 {
+				return nil, fmt.Errorf("%s line %d: temperature must be a number between 0 and 2", ProjectConfigFile, i+1)
+			}
+			project.Temperature = &temperature
+		default:
+			return nil, fmt.Errorf("%s line %d: unknown key %q", ProjectConfigFile, i+1, key)
+		}
+	}
+
+	// A model name only means something for the provider that serves it
+	if project.Model != "" && project.Provider == "" {
+		return nil, fmt.Errorf("%s: model %q is pinned without a provider", ProjectConfigFile, project.Model)
+	}
+	return project, nil
+}
+
+// stripYAMLComment removes a trailing # comment outside of quotes
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteYAML removes matching single or double quotes around a scalar
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
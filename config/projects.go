@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// projectsDirName is the directory under the config directory holding one
+// state directory per project
+const projectsDirName = "projects"
+
+// projectRootFile records which project a state directory belongs to
+const projectRootFile = "root"
+
+// legacyCacheDirName held per-project data before project state directories
+const legacyCacheDirName = "cache"
+
+// movedProjectArtifacts are the local files earlier versions kept in the
+// repository's .coder directory. Anything else there may be the team's and
+// stays.
+var movedProjectArtifacts = []string{"state.json", "instances"}
+
+// projectStateDirs remembers the state directories resolved in this process,
+// so each project is migrated once
+var projectStateDirs sync.Map
+
+// ProjectStateDir returns (and creates) the state directory for the project
+// rooted at root: ~/.coder/projects/<hash of the path>. State that earlier
+// versions kept in the repository or in ~/.coder/cache is moved there the
+// first time.
+func ProjectStateDir(root string) (string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve project root: %w", err)
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	key := projectKey(root)
+	dir := filepath.Join(configDir, projectsDirName, key)
+	if _, ok := projectStateDirs.Load(dir); ok {
+		return dir, nil
+	}
+
+	if err := migrateProjectState(root, filepath.Join(configDir, legacyCacheDirName, key), dir); err != nil {
+		fmt.Printf("⚠️  Failed to move earlier project state to %s: %v\n", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create project state directory: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, projectRootFile)); os.IsNotExist(err) {
+		os.WriteFile(filepath.Join(dir, projectRootFile), []byte(root+"\n"), 0600)
+	}
+	projectStateDirs.Store(dir, true)
+	return dir, nil
+}
+
+// projectKey names a project's state directory after its path
+func projectKey(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ProjectPath returns the path of a local artifact in the state directory of
+// the project in the working directory
+func ProjectPath(name string) (string, error) {
+	dir, err := ProjectStateDir(".")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// migrateProjectState moves what earlier versions stored for a project into
+// its state directory: the per-project cache directory, the local artifacts
+// in the repository's .coder directory and the legacy state file at the
+// repository root. Files already in the state directory are kept.
+func migrateProjectState(root, legacyCache, dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if _, err := os.Stat(legacyCache); err == nil {
+			if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+				return err
+			}
+			if err := os.Rename(legacyCache, dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	repoDir := filepath.Join(root, ProjectDirName)
+	moved := false
+	for _, name := range movedProjectArtifacts {
+		from := filepath.Join(repoDir, name)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		if err := movePath(from, filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to move %s: %w", from, err)
+		}
+		moved = true
+	}
+	if moved {
+		os.Remove(repoDir) // only succeeds when nothing else is left
+	}
+
+	// The oldest versions wrote their state to the repository root
+	legacyState := filepath.Join(root, ".coder_state.json")
+	if _, err := os.Stat(legacyState); err == nil {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		if err := movePath(legacyState, filepath.Join(dir, "state.json")); err != nil {
+			return fmt.Errorf("failed to move %s: %w", legacyState, err)
+		}
+	}
+	return nil
+}
+
+// movePath moves a file or directory, copying files across file systems. A
+// destination that already exists wins and the source is removed.
+func movePath(from, to string) error {
+	if _, err := os.Stat(to); err == nil {
+		return os.RemoveAll(from)
+	}
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		// Directories hold transient data, like instance records
+		return os.RemoveAll(from)
+	}
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
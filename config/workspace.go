@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ProjectDirName is the directory in a repository for the coder files a team
+// shares. Local state lives in the project state directory (see
+// ProjectStateDir); earlier versions kept it here, next to SharedProjectFiles.
+const ProjectDirName = ".coder"
+
+// SharedProjectFiles are the files in the project directory that are meant to
+// be committed, e.g. policies, prerequisites and the project description shared by a team
+var SharedProjectFiles = []string{"policies.json", "prerequisites.json", "project.md"}
+
+// legacyArtifacts are files earlier versions wrote to the repository root
+var legacyArtifacts = []string{".coder_state.json", "commit_msg.txt", "commit_msg_edit.txt", ".commit_msg_edit.txt"}
+
+// CleanProjectArtifacts removes the local artifacts coder wrote to the
+// repository in dir, keeping the shared project files, and returns what was removed
+func CleanProjectArtifacts(dir string) ([]string, error) {
+	var removed []string
+	shared := make(map[string]bool)
+	for _, name := range SharedProjectFiles {
+		shared[name] = true
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, ProjectDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", ProjectDirName, err)
+	}
+	for _, entry := range entries {
+		if shared[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(ProjectDirName, entry.Name())
+		if err := os.RemoveAll(filepath.Join(dir, path)); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	if len(entries) > 0 && len(removed) == len(entries) {
+		os.Remove(filepath.Join(dir, ProjectDirName)) // only succeeds when empty
+	}
+
+	for _, name := range legacyArtifacts {
+		if err := os.Remove(filepath.Join(dir, name)); err == nil {
+			removed = append(removed, name)
+		} else if !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return removed, nil
+}
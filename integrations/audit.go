@@ -0,0 +1,151 @@
+package integrations
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// AuditFileName is the append-only audit log stored in the config directory
+const AuditFileName = "audit.log"
+
+// Audit event types
+const (
+	AuditTaskStarted       = "task_started"
+	AuditTaskCompleted     = "task_completed"
+	AuditTaskFailed        = "task_failed"
+	AuditApprovalRequested = "approval_requested"
+	AuditApprovalGranted   = "approval_granted"
+	AuditApprovalDenied    = "approval_denied"
+	AuditApprovalRejected  = "approval_rejected" // a click from a user outside the allow-list
+)
+
+// AuditEntry is a single line in the audit log. Each entry carries the hash of
+// the previous one so that edits or deletions can be detected.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Event     string    `json:"event"`
+	Service   string    `json:"service"`
+	Principal string    `json:"principal"`
+	Session   string    `json:"session"`
+	Detail    string    `json:"detail,omitempty"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// AuditLog appends hash-chained entries to a JSON-lines file
+type AuditLog struct {
+	mu       sync.Mutex
+	path     string
+	lastHash string
+}
+
+// OpenAuditLog opens the audit log in the config directory
+func OpenAuditLog() (*AuditLog, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return OpenAuditLogAt(filepath.Join(configDir, AuditFileName))
+}
+
+// OpenAuditLogAt opens (or creates) an audit log at the given path
+func OpenAuditLogAt(path string) (*AuditLog, error) {
+	entries, err := ReadAuditLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	log := &AuditLog{path: path}
+	if len(entries) > 0 {
+		log.lastHash = entries[len(entries)-1].Hash
+	}
+	return log, nil
+}
+
+// Record appends an event to the log
+func (l *AuditLog) Record(event, service, principal, session, detail string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Event:     event,
+		Service:   service,
+		Principal: principal,
+		Session:   session,
+		Detail:    detail,
+		PrevHash:  l.lastHash,
+	}
+	entry.Hash = hashAuditEntry(entry)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	l.lastHash = entry.Hash
+	return nil
+}
+
+// ReadAuditLog reads all entries from an audit log
+func ReadAuditLog(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry on line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// VerifyAuditLog checks the hash chain and returns the first broken line, if any
+func VerifyAuditLog(entries []AuditEntry) error {
+	prev := ""
+	for i, entry := range entries {
+		if entry.PrevHash != prev {
+			return fmt.Errorf("audit chain broken at entry %d: previous hash mismatch", i+1)
+		}
+		if hashAuditEntry(entry) != entry.Hash {
+			return fmt.Errorf("audit chain broken at entry %d: entry was modified", i+1)
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
+
+// hashAuditEntry computes the chained hash of an entry (excluding its own hash)
+func hashAuditEntry(entry AuditEntry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
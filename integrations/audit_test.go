@@ -0,0 +1,68 @@
+package integrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestAuditLog records four events, reopening the log halfway so the
+// chain has to continue across opens, and returns the log's lines
+func writeTestAuditLog(t *testing.T, path string) []string {
+	t.Helper()
+	for i, event := range []string{AuditTaskStarted, AuditApprovalRequested, AuditApprovalGranted, AuditTaskCompleted} {
+		audit, err := OpenAuditLogAt(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := audit.Record(event, "slack", "U1", "s1", strings.Repeat("x", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// verifyAuditLines writes lines as an audit log and verifies it
+func verifyAuditLines(t *testing.T, lines []string) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), AuditFileName)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ReadAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return VerifyAuditLog(entries)
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	lines := writeTestAuditLog(t, filepath.Join(t.TempDir(), AuditFileName))
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(lines))
+	}
+	if err := verifyAuditLines(t, lines); err != nil {
+		t.Errorf("expected the intact chain to verify, got %v", err)
+	}
+
+	edited := append([]string(nil), lines...)
+	edited[2] = strings.Replace(edited[2], `"principal":"U1"`, `"principal":"U2"`, 1)
+	if err := verifyAuditLines(t, edited); err == nil || !strings.Contains(err.Error(), "entry 3: entry was modified") {
+		t.Errorf("expected the edited line to be detected, got %v", err)
+	}
+
+	deleted := append(append([]string(nil), lines[:1]...), lines[2:]...)
+	if err := verifyAuditLines(t, deleted); err == nil || !strings.Contains(err.Error(), "entry 2: previous hash mismatch") {
+		t.Errorf("expected the deleted line to be detected, got %v", err)
+	}
+
+	reordered := []string{lines[0], lines[2], lines[1], lines[3]}
+	if err := verifyAuditLines(t, reordered); err == nil || !strings.Contains(err.Error(), "entry 2: previous hash mismatch") {
+		t.Errorf("expected the reordered lines to be detected, got %v", err)
+	}
+}
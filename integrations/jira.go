@@ -0,0 +1,182 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// jiraKeyRe matches an issue key such as PROJ-123
+var jiraKeyRe = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-\d+$`)
+
+// JiraClient talks to the Jira REST API (v2)
+type JiraClient struct {
+	httpClient     *http.Client
+	baseURL        string
+	email          string
+	apiToken       string
+	doneTransition string
+}
+
+// NewJiraClient creates a Jira client from JIRA_BASE_URL, JIRA_EMAIL and a token
+// from JIRA_API_TOKEN or the keyring entry "jira"
+func NewJiraClient() (*JiraClient, error) {
+	baseURL := os.Getenv("JIRA_BASE_URL")
+	if baseURL == "" {
+		return nil, fmt.Errorf("JIRA_BASE_URL environment variable not set")
+	}
+	email := os.Getenv("JIRA_EMAIL")
+	if email == "" {
+		return nil, fmt.Errorf("JIRA_EMAIL environment variable not set")
+	}
+	token, err := config.GetSecret("jira", "JIRA_API_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	return &JiraClient{
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		email:          email,
+		apiToken:       token,
+		doneTransition: getEnvDefault("JIRA_DONE_TRANSITION", "Done"),
+	}, nil
+}
+
+// Name returns the tracker name
+func (c *JiraClient) Name() string {
+	return "jira"
+}
+
+// FetchTicket retrieves the summary, description and status of an issue
+func (c *JiraClient) FetchTicket(key string) (*Ticket, error) {
+	var issue struct {
+		ID     string `json:"id"`
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+			Status      struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := validateJiraKey(key); err != nil {
+		return nil, err
+	}
+
+	if err := c.do("GET", "/rest/api/2/issue/"+key+"?fields=summary,description,status", nil, &issue); err != nil {
+		return nil, err
+	}
+
+	description, criteria := splitAcceptanceCriteria(issue.Fields.Description)
+	return &Ticket{
+		ID:                 issue.ID,
+		Key:                issue.Key,
+		Title:              issue.Fields.Summary,
+		Description:        description,
+		AcceptanceCriteria: criteria,
+		Status:             issue.Fields.Status.Name,
+		URL:                c.baseURL + "/browse/" + issue.Key,
+		Tracker:            c.Name(),
+	}, nil
+}
+
+// PostComment adds a comment to an issue
+func (c *JiraClient) PostComment(key, body string) error {
+	if err := validateJiraKey(key); err != nil {
+		return err
+	}
+	return c.do("POST", "/rest/api/2/issue/"+key+"/comment", map[string]string{"body": body}, nil)
+}
+
+// CompleteTicket moves the issue through the configured "done" transition
+func (c *JiraClient) CompleteTicket(key string) error {
+	if err := validateJiraKey(key); err != nil {
+		return err
+	}
+	var transitions struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := c.do("GET", "/rest/api/2/issue/"+key+"/transitions", nil, &transitions); err != nil {
+		return err
+	}
+
+	for _, t := range transitions.Transitions {
+		if strings.EqualFold(t.Name, c.doneTransition) || strings.EqualFold(t.To.Name, c.doneTransition) {
+			payload := map[string]interface{}{
+				"transition": map[string]string{"id": t.ID},
+			}
+			return c.do("POST", "/rest/api/2/issue/"+key+"/transitions", payload, nil)
+		}
+	}
+
+	return fmt.Errorf("no transition named %q available for %s", c.doneTransition, key)
+}
+
+// validateJiraKey rejects anything but an issue key, since the key is part of
+// the request path
+func validateJiraKey(key string) error {
+	if !jiraKeyRe.MatchString(key) {
+		return fmt.Errorf("invalid Jira issue key %q (expected e.g. PROJ-123)", key)
+	}
+	return nil
+}
+
+// do performs an authenticated request and decodes the JSON response into out
+func (c *JiraClient) do(method, path string, payload interface{}, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(c.email, c.apiToken)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Jira request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Jira API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
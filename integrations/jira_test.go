@@ -0,0 +1,113 @@
+package integrations
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestJiraClient returns a client for a stub Jira server that records the
+// requests it receives
+func newTestJiraClient(t *testing.T, requests *[]string) *JiraClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, token, ok := r.BasicAuth(); !ok || user != "dev@example.com" || token != "test-token" {
+			http.Error(w, `{"errorMessages": ["unauthorized"]}`, http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		encoded, _ := json.Marshal(body)
+		*requests = append(*requests, r.Method+" "+r.URL.RequestURI()+" "+string(encoded))
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/rest/api/2/issue/PROJ-12":
+			w.Write([]byte(`{"id": "10012", "key": "PROJ-12", "fields": {"summary": "Add login", "status": {"name": "To Do"},
+				"description": "Users sign in.\n\nh3. Acceptance Criteria\n* Wrong passwords are rejected"}}`))
+		case r.Method == "GET" && r.URL.Path == "/rest/api/2/issue/PROJ-12/transitions":
+			w.Write([]byte(`{"transitions": [{"id": "11", "name": "Start", "to": {"name": "In Progress"}}, {"id": "31", "name": "Resolve", "to": {"name": "Done"}}]}`))
+		case r.Method == "POST":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, `{"errorMessages": ["Issue does not exist"]}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &JiraClient{
+		httpClient:     server.Client(),
+		baseURL:        server.URL,
+		email:          "dev@example.com",
+		apiToken:       "test-token",
+		doneTransition: "Done",
+	}
+}
+
+func TestJiraFetchTicket(t *testing.T) {
+	var requests []string
+	client := newTestJiraClient(t, &requests)
+
+	ticket, err := client.FetchTicket("PROJ-12")
+	if err != nil {
+		t.Fatalf("FetchTicket: %v", err)
+	}
+	if ticket.ID != "10012" || ticket.Title != "Add login" || ticket.Status != "To Do" || ticket.Tracker != "jira" {
+		t.Errorf("unexpected ticket %+v", ticket)
+	}
+	if ticket.Description != "Users sign in." || ticket.AcceptanceCriteria != "* Wrong passwords are rejected" {
+		t.Errorf("expected the acceptance criteria to be split out, got %q / %q", ticket.Description, ticket.AcceptanceCriteria)
+	}
+	if !strings.HasSuffix(ticket.URL, "/browse/PROJ-12") {
+		t.Errorf("unexpected ticket URL %q", ticket.URL)
+	}
+
+	if _, err := client.FetchTicket("PROJ-99"); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("expected Jira's error for a missing issue, got %v", err)
+	}
+}
+
+func TestJiraCommentAndComplete(t *testing.T) {
+	var requests []string
+	client := newTestJiraClient(t, &requests)
+
+	if err := client.PostComment("PROJ-12", "Implemented in abc123"); err != nil {
+		t.Fatalf("PostComment: %v", err)
+	}
+	if err := client.CompleteTicket("PROJ-12"); err != nil {
+		t.Fatalf("CompleteTicket: %v", err)
+	}
+	want := []string{
+		`POST /rest/api/2/issue/PROJ-12/comment {"body":"Implemented in abc123"}`,
+		`GET /rest/api/2/issue/PROJ-12/transitions null`,
+		`POST /rest/api/2/issue/PROJ-12/transitions {"transition":{"id":"31"}}`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected requests:\n%s", strings.Join(requests, "\n"))
+	}
+
+	client.doneTransition = "Closed"
+	if err := client.CompleteTicket("PROJ-12"); err == nil || !strings.Contains(err.Error(), `no transition named "Closed"`) {
+		t.Errorf("expected a missing transition error, got %v", err)
+	}
+}
+
+func TestJiraRejectsInvalidKeys(t *testing.T) {
+	var requests []string
+	client := newTestJiraClient(t, &requests)
+
+	for _, key := range []string{"PROJ-12/../../myself", "PROJ-12?expand=changelog", "proj-12", "12", ""} {
+		if _, err := client.FetchTicket(key); err == nil || !strings.Contains(err.Error(), "invalid Jira issue key") {
+			t.Errorf("expected %q to be rejected, got %v", key, err)
+		}
+		if err := client.PostComment(key, "hi"); err == nil {
+			t.Errorf("expected a comment on %q to be rejected", key)
+		}
+		if err := client.CompleteTicket(key); err == nil {
+			t.Errorf("expected completing %q to be rejected", key)
+		}
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no requests for invalid keys, got %v", requests)
+	}
+}
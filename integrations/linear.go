@@ -0,0 +1,188 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+const linearAPIURL = "https://api.linear.app/graphql"
+
+// LinearClient talks to the Linear GraphQL API
+type LinearClient struct {
+	httpClient *http.Client
+	apiKey     string
+}
+
+// linearIssue is the subset of the Linear issue object we query
+type linearIssue struct {
+	ID          string `json:"id"`
+	Identifier  string `json:"identifier"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	State       struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Team struct {
+		States struct {
+			Nodes []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+				Type string `json:"type"`
+			} `json:"nodes"`
+		} `json:"states"`
+	} `json:"team"`
+}
+
+// NewLinearClient creates a Linear client using LINEAR_API_KEY or the keyring entry "linear"
+func NewLinearClient() (*LinearClient, error) {
+	apiKey, err := config.GetSecret("linear", "LINEAR_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	return &LinearClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		apiKey:     apiKey,
+	}, nil
+}
+
+// Name returns the tracker name
+func (c *LinearClient) Name() string {
+	return "linear"
+}
+
+// FetchTicket retrieves an issue by its identifier (e.g. ENG-123)
+func (c *LinearClient) FetchTicket(key string) (*Ticket, error) {
+	issue, err := c.getIssue(key)
+	if err != nil {
+		return nil, err
+	}
+
+	description, criteria := splitAcceptanceCriteria(issue.Description)
+	return &Ticket{
+		ID:                 issue.ID,
+		Key:                issue.Identifier,
+		Title:              issue.Title,
+		Description:        description,
+		AcceptanceCriteria: criteria,
+		Status:             issue.State.Name,
+		URL:                issue.URL,
+		Tracker:            c.Name(),
+	}, nil
+}
+
+// PostComment adds a comment to an issue
+func (c *LinearClient) PostComment(key, body string) error {
+	issue, err := c.getIssue(key)
+	if err != nil {
+		return err
+	}
+
+	query := `mutation($input: CommentCreateInput!) { commentCreate(input: $input) { success } }`
+	variables := map[string]interface{}{
+		"input": map[string]string{"issueId": issue.ID, "body": body},
+	}
+	return c.graphql(query, variables, nil)
+}
+
+// CompleteTicket moves the issue to the team's first "completed" workflow state
+func (c *LinearClient) CompleteTicket(key string) error {
+	issue, err := c.getIssue(key)
+	if err != nil {
+		return err
+	}
+
+	stateID := ""
+	for _, state := range issue.Team.States.Nodes {
+		if state.Type == "completed" {
+			stateID = state.ID
+			break
+		}
+	}
+	if stateID == "" {
+		return fmt.Errorf("no completed workflow state found for %s", key)
+	}
+
+	query := `mutation($id: String!, $input: IssueUpdateInput!) { issueUpdate(id: $id, input: $input) { success } }`
+	variables := map[string]interface{}{
+		"id":    issue.ID,
+		"input": map[string]string{"stateId": stateID},
+	}
+	return c.graphql(query, variables, nil)
+}
+
+// getIssue queries a single issue with its team's workflow states
+func (c *LinearClient) getIssue(key string) (*linearIssue, error) {
+	query := `query($id: String!) { issue(id: $id) { id identifier title description url state { name } team { states { nodes { id name type } } } } }`
+
+	var data struct {
+		Issue *linearIssue `json:"issue"`
+	}
+	if err := c.graphql(query, map[string]interface{}{"id": key}, &data); err != nil {
+		return nil, err
+	}
+	if data.Issue == nil {
+		return nil, fmt.Errorf("Linear issue %s not found", key)
+	}
+	return data.Issue, nil
+}
+
+// graphql executes a GraphQL request and decodes the data field into out
+func (c *LinearClient) graphql(query string, variables map[string]interface{}, out interface{}) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", linearAPIURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Linear request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Linear API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("Linear API error: %s", result.Errors[0].Message)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return fmt.Errorf("failed to parse response data: %w", err)
+		}
+	}
+	return nil
+}
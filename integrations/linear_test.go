@@ -0,0 +1,88 @@
+package integrations
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// linearIssueJSON is ENG-7 with a team that has a completed state
+const linearIssueJSON = `{"data": {"issue": {"id": "issue-uuid", "identifier": "ENG-7", "title": "Fix signup", "url": "https://linear.app/acme/issue/ENG-7",
+	"description": "Signup fails.\n\n## Acceptance Criteria\n- Signup works", "state": {"name": "Todo"},
+	"team": {"states": {"nodes": [{"id": "state-todo", "name": "Todo", "type": "unstarted"}, {"id": "state-done", "name": "Done", "type": "completed"}]}}}}}`
+
+// newTestLinearClient returns a client whose GraphQL requests are answered by
+// reply and recorded as their query and variables
+func newTestLinearClient(t *testing.T, requests *[]map[string]interface{}, reply func(query string) string) *LinearClient {
+	t.Helper()
+	return &LinearClient{
+		apiKey: "lin_test",
+		httpClient: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.String() != linearAPIURL || r.Header.Get("Authorization") != "lin_test" {
+				t.Errorf("unexpected request to %s with authorization %q", r.URL, r.Header.Get("Authorization"))
+			}
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			*requests = append(*requests, body)
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(reply(body["query"].(string)))), Header: http.Header{}}, nil
+		})},
+	}
+}
+
+func TestLinearFetchTicket(t *testing.T) {
+	var requests []map[string]interface{}
+	client := newTestLinearClient(t, &requests, func(string) string { return linearIssueJSON })
+
+	ticket, err := client.FetchTicket("ENG-7")
+	if err != nil {
+		t.Fatalf("FetchTicket: %v", err)
+	}
+	if ticket.ID != "issue-uuid" || ticket.Key != "ENG-7" || ticket.Status != "Todo" || ticket.Tracker != "linear" {
+		t.Errorf("unexpected ticket %+v", ticket)
+	}
+	if ticket.Description != "Signup fails." || ticket.AcceptanceCriteria != "- Signup works" {
+		t.Errorf("expected the acceptance criteria to be split out, got %q / %q", ticket.Description, ticket.AcceptanceCriteria)
+	}
+	if variables := requests[0]["variables"].(map[string]interface{}); variables["id"] != "ENG-7" {
+		t.Errorf("expected the key as a GraphQL variable, got %v", variables)
+	}
+
+	client = newTestLinearClient(t, &requests, func(string) string { return `{"data": {"issue": null}}` })
+	if _, err := client.FetchTicket("ENG-8"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	client = newTestLinearClient(t, &requests, func(string) string { return `{"errors": [{"message": "Entity not found"}]}` })
+	if _, err := client.FetchTicket("ENG-8"); err == nil || !strings.Contains(err.Error(), "Entity not found") {
+		t.Errorf("expected the GraphQL error, got %v", err)
+	}
+}
+
+func TestLinearCommentAndComplete(t *testing.T) {
+	var requests []map[string]interface{}
+	client := newTestLinearClient(t, &requests, func(query string) string {
+		if strings.HasPrefix(query, "mutation") {
+			return `{"data": {"success": true}}`
+		}
+		return linearIssueJSON
+	})
+
+	if err := client.PostComment("ENG-7", "Fixed in abc123"); err != nil {
+		t.Fatalf("PostComment: %v", err)
+	}
+	if err := client.CompleteTicket("ENG-7"); err != nil {
+		t.Fatalf("CompleteTicket: %v", err)
+	}
+	if len(requests) != 4 {
+		t.Fatalf("expected a lookup and a mutation for each call, got %d requests", len(requests))
+	}
+	comment, _ := json.Marshal(requests[1]["variables"])
+	if string(comment) != `{"input":{"body":"Fixed in abc123","issueId":"issue-uuid"}}` {
+		t.Errorf("unexpected comment variables %s", comment)
+	}
+	update, _ := json.Marshal(requests[3]["variables"])
+	if string(update) != `{"id":"issue-uuid","input":{"stateId":"state-done"}}` {
+		t.Errorf("expected the issue to move to the completed state, got %s", update)
+	}
+}
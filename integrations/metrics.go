@@ -0,0 +1,174 @@
+package integrations
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricDef describes one exported metric family
+type metricDef struct {
+	name   string
+	help   string
+	kind   string // "counter" or "gauge"
+	labels []string
+}
+
+var (
+	metricRequests       = metricDef{"coder_requests_total", "Provider chat requests.", "counter", []string{"provider", "model", "status"}}
+	metricTokens         = metricDef{"coder_tokens_total", "Tokens used by provider requests.", "counter", []string{"provider", "model", "type"}}
+	metricCost           = metricDef{"coder_cost_dollars_total", "Estimated provider cost in US dollars.", "counter", []string{"provider", "model"}}
+	metricToolExecutions = metricDef{"coder_tool_executions_total", "Tool executions.", "counter", []string{"provider", "model", "tool", "status"}}
+	metricTasks          = metricDef{"coder_tasks_total", "Tasks handled by the service.", "counter", []string{"service", "status"}}
+	metricStartTime      = metricDef{"coder_start_time_seconds", "Unix time the service started.", "gauge", nil}
+
+	exportedMetrics = []metricDef{metricRequests, metricTokens, metricCost, metricToolExecutions, metricTasks, metricStartTime}
+)
+
+// Metrics collects usage counters for a long-running service and serves them
+// in the Prometheus text exposition format. It implements agent.MetricsRecorder.
+type Metrics struct {
+	mu      sync.Mutex
+	values  map[string]map[string]float64 // metric name -> encoded label values -> value
+	started time.Time
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		values:  make(map[string]map[string]float64),
+		started: time.Now(),
+	}
+}
+
+// RecordRequest counts a provider request and the tokens and cost it used
+func (m *Metrics) RecordRequest(provider, model string, promptTokens, completionTokens int, cost float64, err error) {
+	m.add(metricRequests, 1, provider, model, statusLabel(err))
+	if err != nil {
+		return
+	}
+	m.add(metricTokens, float64(promptTokens), provider, model, "prompt")
+	m.add(metricTokens, float64(completionTokens), provider, model, "completion")
+	m.add(metricCost, cost, provider, model)
+}
+
+// RecordToolExecution counts a tool execution
+func (m *Metrics) RecordToolExecution(provider, model, tool string, err error) {
+	m.add(metricToolExecutions, 1, provider, model, tool, statusLabel(err))
+}
+
+// RecordTask counts a task handled by a service
+func (m *Metrics) RecordTask(service string, err error) {
+	m.add(metricTasks, 1, service, statusLabel(err))
+}
+
+// add increments a metric for the given label values
+func (m *Metrics) add(def metricDef, value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, ok := m.values[def.name]
+	if !ok {
+		series = make(map[string]float64)
+		m.values[def.name] = series
+	}
+	series[formatLabels(def.labels, labelValues)] += value
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, m.Render())
+}
+
+// Render returns all metrics in the Prometheus text format
+func (m *Metrics) Render() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out strings.Builder
+	for _, def := range exportedMetrics {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", def.name, def.help, def.name, def.kind)
+
+		if def.name == metricStartTime.name {
+			fmt.Fprintf(&out, "%s %d\n", def.name, m.started.Unix())
+			continue
+		}
+
+		series := m.values[def.name]
+		keys := make([]string, 0, len(series))
+		for key := range series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&out, "%s%s %s\n", def.name, key, formatValue(series[key]))
+		}
+	}
+	return out.String()
+}
+
+// ServeMetrics exposes /metrics on addr in the background
+func (m *Metrics) ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	// Surface immediate failures such as the port being in use
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve metrics on %s: %w", addr, err)
+	case <-time.After(200 * time.Millisecond):
+		return nil
+	}
+}
+
+// statusLabel turns an error into a status label value
+func statusLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// formatLabels encodes label names and values as {name="value",...}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", name, escapeLabelValue(value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabelValue strips characters that %q would escape differently from Prometheus
+func escapeLabelValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, value)
+}
+
+// formatValue prints integral values without a decimal point
+func formatValue(value float64) string {
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return fmt.Sprintf("%g", value)
+}
@@ -0,0 +1,475 @@
+package integrations
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alantheprice/coder/agent"
+	"github.com/alantheprice/coder/config"
+)
+
+const (
+	slackAPIURL          = "https://slack.com/api/"
+	slackApprovalTimeout = 10 * time.Minute
+)
+
+// SlackBot runs the agent behind a Slack app using Socket Mode.
+// Each DM thread is its own agent session.
+type SlackBot struct {
+	httpClient   *http.Client
+	appToken     string
+	botToken     string
+	allowedUsers map[string]bool
+	debug        bool
+	audit        *AuditLog
+	metrics      *Metrics
+
+	mu        sync.Mutex
+	sessions  map[string]*slackSession
+	approvals map[string]*slackApproval
+}
+
+// slackApproval is an approval request waiting for a button click
+type slackApproval struct {
+	session  *slackSession
+	action   string // tool and detail, for the audit log
+	decision chan slackDecision
+}
+
+// slackDecision is the answer to an approval request and who gave it
+type slackDecision struct {
+	approved bool
+	user     string
+}
+
+// slackSession is a single thread-scoped conversation with the agent
+type slackSession struct {
+	mu      sync.Mutex
+	agent   *agent.Agent
+	channel string
+	thread  string
+	user    string
+}
+
+// slackEnvelope is the Socket Mode message wrapper
+type slackEnvelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// slackMessageEvent is the subset of a message event we use
+type slackMessageEvent struct {
+	Type        string `json:"type"`
+	ChannelType string `json:"channel_type"`
+	Channel     string `json:"channel"`
+	User        string `json:"user"`
+	Text        string `json:"text"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+	BotID       string `json:"bot_id"`
+	Subtype     string `json:"subtype"`
+}
+
+// slackBlockActions is the interactive payload sent when a button is clicked
+type slackBlockActions struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// NewSlackBot creates a Slack bot using SLACK_APP_TOKEN (xapp-) and SLACK_BOT_TOKEN (xoxb-),
+// falling back to the keyring entries "slack-app" and "slack-bot"
+func NewSlackBot() (*SlackBot, error) {
+	appToken, err := config.GetSecret("slack-app", "SLACK_APP_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	botToken, err := config.GetSecret("slack-bot", "SLACK_BOT_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool)
+	for _, user := range strings.Split(os.Getenv("SLACK_ALLOWED_USERS"), ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			allowed[user] = true
+		}
+	}
+
+	audit, err := OpenAuditLog()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &SlackBot{
+		audit:        audit,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		appToken:     appToken,
+		botToken:     botToken,
+		allowedUsers: allowed,
+		debug:        os.Getenv("DEBUG") == "true" || os.Getenv("DEBUG") == "1",
+		sessions:     make(map[string]*slackSession),
+		approvals:    make(map[string]*slackApproval),
+	}, nil
+}
+
+// SetMetrics makes every session report usage to the given metrics registry
+func (b *SlackBot) SetMetrics(metrics *Metrics) {
+	b.metrics = metrics
+}
+
+// Run connects to Slack and processes events until a fatal error occurs.
+// Dropped connections are re-established automatically.
+func (b *SlackBot) Run() error {
+	for {
+		wsURL, err := b.openConnection()
+		if err != nil {
+			return err
+		}
+
+		conn, err := DialWebSocket(wsURL)
+		if err != nil {
+			fmt.Printf("⚠️  Slack connection failed: %v (retrying in 5s)\n", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		fmt.Println("💬 Connected to Slack - waiting for direct messages")
+		if err := b.readLoop(conn); err != nil {
+			fmt.Printf("⚠️  Slack connection lost: %v (reconnecting)\n", err)
+		}
+		conn.Close()
+	}
+}
+
+// openConnection requests a Socket Mode websocket URL
+func (b *SlackBot) openConnection() (string, error) {
+	var resp struct {
+		OK    bool   `json:"ok"`
+		URL   string `json:"url"`
+		Error string `json:"error"`
+	}
+	if err := b.call("apps.connections.open", b.appToken, nil, &resp); err != nil {
+		return "", err
+	}
+	if !resp.OK {
+		return "", fmt.Errorf("apps.connections.open failed: %s", resp.Error)
+	}
+	return resp.URL, nil
+}
+
+// readLoop reads envelopes, acknowledges them and dispatches their payloads
+func (b *SlackBot) readLoop(conn *WebSocketConn) error {
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var envelope slackEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			continue
+		}
+
+		if envelope.EnvelopeID != "" {
+			ack, _ := json.Marshal(map[string]string{"envelope_id": envelope.EnvelopeID})
+			if err := conn.WriteMessage(ack); err != nil {
+				return err
+			}
+		}
+
+		switch envelope.Type {
+		case "hello":
+			if b.debug {
+				fmt.Println("🔍 Slack hello received")
+			}
+		case "disconnect":
+			return fmt.Errorf("server requested reconnect")
+		case "events_api":
+			var payload struct {
+				Event slackMessageEvent `json:"event"`
+			}
+			if err := json.Unmarshal(envelope.Payload, &payload); err == nil {
+				b.handleMessage(payload.Event)
+			}
+		case "interactive":
+			var payload slackBlockActions
+			if err := json.Unmarshal(envelope.Payload, &payload); err == nil {
+				b.handleAction(payload)
+			}
+		}
+	}
+}
+
+// handleMessage starts or continues the thread session for a direct message
+func (b *SlackBot) handleMessage(event slackMessageEvent) {
+	if event.Type != "message" || event.ChannelType != "im" || event.BotID != "" || event.Subtype != "" {
+		return
+	}
+	text := strings.TrimSpace(event.Text)
+	if text == "" {
+		return
+	}
+
+	thread := event.ThreadTS
+	if thread == "" {
+		thread = event.TS
+	}
+
+	if !b.userAllowed(event.User) {
+		b.postMessage(event.Channel, thread, "⛔ You are not allowed to run tasks with this bot.", nil)
+		return
+	}
+
+	session, err := b.getSession(event.Channel, thread, event.User)
+	if err != nil {
+		b.postMessage(event.Channel, thread, fmt.Sprintf("❌ Failed to start agent: %v", err), nil)
+		return
+	}
+
+	go b.runTask(session, event.User, text)
+}
+
+// userAllowed reports whether a user may run tasks and answer approvals
+func (b *SlackBot) userAllowed(user string) bool {
+	return len(b.allowedUsers) == 0 || b.allowedUsers[user]
+}
+
+// getSession returns the session for a thread, creating an agent if needed
+func (b *SlackBot) getSession(channel, thread, user string) (*slackSession, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if session, ok := b.sessions[thread]; ok {
+		return session, nil
+	}
+
+	chatAgent, err := agent.NewAgent()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &slackSession{agent: chatAgent, channel: channel, thread: thread, user: user}
+	chatAgent.SetApprovalHandler(func(toolName, detail string) bool {
+		return b.requestApproval(session, toolName, detail)
+	})
+	if b.metrics != nil {
+		chatAgent.SetMetricsRecorder(b.metrics)
+	}
+	b.sessions[thread] = session
+	return session, nil
+}
+
+// runTask runs a single query in the session, serialised per thread
+func (b *SlackBot) runTask(session *slackSession, user, text string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	b.postMessage(session.channel, session.thread, "🤖 Working on it...", nil)
+	b.recordAudit(AuditTaskStarted, user, session.thread, text)
+
+	result, err := session.agent.ProcessQueryWithContinuity(text)
+	if b.metrics != nil {
+		b.metrics.RecordTask("slack", err)
+	}
+	if err != nil {
+		b.recordAudit(AuditTaskFailed, user, session.thread, err.Error())
+		b.postMessage(session.channel, session.thread, fmt.Sprintf("❌ Error: %v", err), nil)
+		return
+	}
+
+	b.recordAudit(AuditTaskCompleted, user, session.thread,
+		fmt.Sprintf("cost=$%.4f\n%s", session.agent.GetTotalCost(), session.agent.GenerateActionSummary()))
+
+	// Carry the thread's history into the next message
+	session.agent.SetPreviousSummary(session.agent.GenerateCompactSummary())
+
+	if err := session.agent.RecordTranscript(result); err != nil {
+		fmt.Printf("⚠️  Failed to archive task transcript: %v\n", err)
+	}
+	if _, err := session.agent.DistillKnowledge(result); err != nil {
+		fmt.Printf("⚠️  Failed to distill project knowledge: %v\n", err)
+	}
+
+	b.postMessage(session.channel, session.thread,
+		fmt.Sprintf("✅ %s\n\n_Cost so far: $%.4f_", result, session.agent.GetTotalCost()), nil)
+}
+
+// requestApproval posts approval buttons and blocks until an allowed user answers
+func (b *SlackBot) requestApproval(session *slackSession, toolName, detail string) bool {
+	action := toolName + ": " + detail
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		// Without a random ID the buttons could answer another approval
+		b.recordAudit(AuditApprovalDenied, "system", session.thread, action+" (no approval ID: "+err.Error()+")")
+		b.postMessage(session.channel, session.thread, fmt.Sprintf("❌ Could not create an approval request (%v) - action denied.", err), nil)
+		return false
+	}
+	approvalID := hex.EncodeToString(idBytes)
+
+	decision := make(chan slackDecision, 1)
+	b.mu.Lock()
+	b.approvals[approvalID] = &slackApproval{session: session, action: action, decision: decision}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.approvals, approvalID)
+		b.mu.Unlock()
+	}()
+
+	text := fmt.Sprintf("⚠️ The agent wants to run a risky `%s`:\n```%s```", toolName, detail)
+	blocks := []map[string]interface{}{
+		{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text},
+		},
+		{
+			"type": "actions",
+			"elements": []map[string]interface{}{
+				slackButton("Approve", "coder_approve", approvalID, "primary"),
+				slackButton("Deny", "coder_deny", approvalID, "danger"),
+			},
+		},
+	}
+	b.postMessage(session.channel, session.thread, text, blocks)
+	b.recordAudit(AuditApprovalRequested, session.user, session.thread, action)
+
+	select {
+	case answer := <-decision:
+		event, verb := AuditApprovalDenied, "denied"
+		if answer.approved {
+			event, verb = AuditApprovalGranted, "approved"
+		}
+		b.recordAudit(event, answer.user, session.thread, fmt.Sprintf("%s (%s by %s, requested by %s)", action, verb, answer.user, session.user))
+		return answer.approved
+	case <-time.After(slackApprovalTimeout):
+		b.recordAudit(AuditApprovalDenied, "timeout", session.thread, action)
+		b.postMessage(session.channel, session.thread, "⌛ Approval timed out - action denied.", nil)
+		return false
+	}
+}
+
+// handleAction resolves a pending approval from a button click. Clicks from
+// users who may not run tasks are logged and ignored; the approval keeps waiting.
+func (b *SlackBot) handleAction(payload slackBlockActions) {
+	if payload.Type != "block_actions" {
+		return
+	}
+	for _, action := range payload.Actions {
+		if action.ActionID != "coder_approve" && action.ActionID != "coder_deny" {
+			continue
+		}
+
+		b.mu.Lock()
+		approval, ok := b.approvals[action.Value]
+		b.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		if !b.userAllowed(payload.User.ID) {
+			b.recordAudit(AuditApprovalRejected, payload.User.ID, approval.session.thread, approval.action)
+			b.postMessage(approval.session.channel, approval.session.thread,
+				fmt.Sprintf("⛔ <@%s> is not allowed to answer approvals - still waiting.", payload.User.ID), nil)
+			continue
+		}
+
+		select {
+		case approval.decision <- slackDecision{approved: action.ActionID == "coder_approve", user: payload.User.ID}:
+		default:
+		}
+	}
+}
+
+// recordAudit writes an audit entry, warning (but not failing) on errors
+func (b *SlackBot) recordAudit(event, principal, session, detail string) {
+	if err := b.audit.Record(event, "slack", principal, session, detail); err != nil {
+		fmt.Printf("⚠️  Failed to write audit entry: %v\n", err)
+	}
+}
+
+// slackButton builds a Block Kit button element
+func slackButton(label, actionID, value, style string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "button",
+		"text":      map[string]string{"type": "plain_text", "text": label},
+		"action_id": actionID,
+		"value":     value,
+		"style":     style,
+	}
+}
+
+// postMessage posts a message into a thread
+func (b *SlackBot) postMessage(channel, thread, text string, blocks []map[string]interface{}) {
+	payload := map[string]interface{}{
+		"channel":   channel,
+		"thread_ts": thread,
+		"text":      text,
+	}
+	if blocks != nil {
+		payload["blocks"] = blocks
+	}
+
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := b.call("chat.postMessage", b.botToken, payload, &resp); err != nil {
+		fmt.Printf("⚠️  Failed to post Slack message: %v\n", err)
+	} else if !resp.OK {
+		fmt.Printf("⚠️  Failed to post Slack message: %s\n", resp.Error)
+	}
+}
+
+// call invokes a Slack Web API method
+func (b *SlackBot) call(method, token string, payload interface{}, out interface{}) error {
+	var body io.Reader = http.NoBody
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest("POST", slackAPIURL+method, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Slack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Slack API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package integrations
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc stubs the Slack Web API
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// newTestSlackBot returns a bot that allows only U1, answers every Web API
+// call with ok and records what it posts
+func newTestSlackBot(t *testing.T, posted *[]string) *SlackBot {
+	t.Helper()
+	audit, err := OpenAuditLogAt(filepath.Join(t.TempDir(), AuditFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		*posted = append(*posted, string(body))
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"ok": true}`)), Header: http.Header{}}, nil
+	})}
+	return &SlackBot{
+		httpClient:   client,
+		allowedUsers: map[string]bool{"U1": true},
+		audit:        audit,
+		sessions:     make(map[string]*slackSession),
+		approvals:    make(map[string]*slackApproval),
+	}
+}
+
+// click builds a button click payload
+func click(user, actionID, approvalID string) slackBlockActions {
+	var payload slackBlockActions
+	payload.Type = "block_actions"
+	payload.User.ID = user
+	payload.Actions = append(payload.Actions, struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	}{ActionID: actionID, Value: approvalID})
+	return payload
+}
+
+func TestSlackApprovalIgnoresUsersOutsideAllowList(t *testing.T) {
+	var posted []string
+	bot := newTestSlackBot(t, &posted)
+	decision := make(chan slackDecision, 1)
+	session := &slackSession{channel: "D1", thread: "1.0", user: "U1"}
+	bot.approvals["abc"] = &slackApproval{session: session, action: "shell_command: rm -rf build", decision: decision}
+
+	bot.handleAction(click("U2", "coder_approve", "abc"))
+	select {
+	case answer := <-decision:
+		t.Fatalf("expected the click of U2 ignored, got %+v", answer)
+	default:
+	}
+	entries, err := ReadAuditLog(bot.audit.path)
+	if err != nil || len(entries) != 1 || entries[0].Event != AuditApprovalRejected || entries[0].Principal != "U2" {
+		t.Fatalf("expected the rejected click audited, got %+v (%v)", entries, err)
+	}
+	if len(posted) != 1 || !strings.Contains(posted[0], "not allowed to answer approvals") {
+		t.Errorf("expected the thread told, got %v", posted)
+	}
+
+	bot.handleAction(click("U1", "coder_approve", "abc"))
+	if answer := <-decision; !answer.approved || answer.user != "U1" {
+		t.Errorf("expected U1's approval, got %+v", answer)
+	}
+}
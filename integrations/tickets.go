@@ -0,0 +1,89 @@
+package integrations
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Ticket represents an issue fetched from an external task tracker
+type Ticket struct {
+	ID                 string `json:"id"`
+	Key                string `json:"key"`
+	Title              string `json:"title"`
+	Description        string `json:"description"`
+	AcceptanceCriteria string `json:"acceptance_criteria"`
+	Status             string `json:"status"`
+	URL                string `json:"url"`
+	Tracker            string `json:"tracker"`
+}
+
+// TicketTracker is implemented by each supported issue tracker
+type TicketTracker interface {
+	Name() string
+	FetchTicket(key string) (*Ticket, error)
+	PostComment(key, body string) error
+	CompleteTicket(key string) error
+}
+
+// NewTicketTracker creates a tracker by name ("jira" or "linear"). An empty
+// name picks whichever tracker has credentials configured, preferring Linear.
+func NewTicketTracker(name string) (TicketTracker, error) {
+	switch strings.ToLower(name) {
+	case "jira":
+		return NewJiraClient()
+	case "linear":
+		return NewLinearClient()
+	case "":
+		if linear, err := NewLinearClient(); err == nil {
+			return linear, nil
+		}
+		if jira, err := NewJiraClient(); err == nil {
+			return jira, nil
+		}
+		return nil, fmt.Errorf("no ticket tracker configured - set LINEAR_API_KEY or JIRA_BASE_URL/JIRA_EMAIL/JIRA_API_TOKEN (tokens may also be stored in the keyring)")
+	default:
+		return nil, fmt.Errorf("unknown ticket tracker: %s (supported: jira, linear)", name)
+	}
+}
+
+// FormatTicketPrompt builds the task prompt the agent receives for a ticket
+func FormatTicketPrompt(ticket *Ticket) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Work on %s ticket %s: %s\n", ticket.Tracker, ticket.Key, ticket.Title))
+	if ticket.URL != "" {
+		sb.WriteString(fmt.Sprintf("Ticket URL: %s\n", ticket.URL))
+	}
+	if ticket.Description != "" {
+		sb.WriteString("\n## Description\n")
+		sb.WriteString(strings.TrimSpace(ticket.Description))
+		sb.WriteString("\n")
+	}
+	if ticket.AcceptanceCriteria != "" {
+		sb.WriteString("\n## Acceptance Criteria\n")
+		sb.WriteString(strings.TrimSpace(ticket.AcceptanceCriteria))
+		sb.WriteString("\n\nMake sure every acceptance criterion is satisfied before finishing.\n")
+	}
+	return sb.String()
+}
+
+// acceptanceHeadingRe matches the common ways acceptance criteria are introduced
+var acceptanceHeadingRe = regexp.MustCompile(`(?im)^\s*(?:#+\s*|h\d\.\s*|\*+\s*)?acceptance criteria\s*:?\**\s*$`)
+
+// splitAcceptanceCriteria separates an "Acceptance Criteria" section from a description
+func splitAcceptanceCriteria(description string) (string, string) {
+	loc := acceptanceHeadingRe.FindStringIndex(description)
+	if loc == nil {
+		return description, ""
+	}
+	return strings.TrimSpace(description[:loc[0]]), strings.TrimSpace(description[loc[1]:])
+}
+
+// getEnvDefault returns the environment value or a default
+func getEnvDefault(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/alantheprice/coder/api"
 	"github.com/alantheprice/coder/commands"
 	"github.com/alantheprice/coder/config"
+	"github.com/alantheprice/coder/integrations"
 	"github.com/alantheprice/coder/tools"
 	"github.com/chzyer/readline"
 )
@@ -30,10 +35,71 @@ func main() {
 	useLocal := false
 	model := ""
 	provider := ""
+	pipeline := false
+	autoApprove := false
+	sandbox := ""
+	turns := 0
+	toolPreset := ""
+	resume, resumeLast := false, false
+	resumeSession := ""
+	mode := ""
 	debug := os.Getenv("DEBUG") == "true" || os.Getenv("DEBUG") == "1"
 
 	args := os.Args[1:] // Skip program name
 
+	// Service modes
+	if len(args) > 0 && args[0] == "slack" {
+		runSlackBot(args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "audit" {
+		runAuditReport()
+		return
+	}
+
+	// "coder chore <name> [param=value ...]" runs a maintenance chore; the
+	// remaining flags still apply
+	var choreArgs []string
+	chore := len(args) > 0 && args[0] == "chore"
+	if chore {
+		var flags []string
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") {
+				flags = append(flags, arg)
+			} else {
+				choreArgs = append(choreArgs, arg)
+			}
+		}
+		if len(choreArgs) == 0 {
+			commands.PrintChores()
+			return
+		}
+		args = flags
+	}
+
+	// "coder explain-commit <sha>" explains a commit from its diff alone
+	explainCommit := ""
+	if len(args) > 0 && args[0] == "explain-commit" {
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			log.Fatalf("Error: explain-commit expects a commit, e.g. ./coder explain-commit HEAD~1")
+		}
+		explainCommit = args[1]
+		args = args[2:]
+	}
+
+	// "--mode explain-diff" is the same as "--mode=explain-diff"
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--mode" {
+			args = append(append(args[:i:i], "--mode="+args[i+1]), args[i+2:]...)
+		}
+	}
+
+	// "coder resume --last" or "coder resume <session-id>" continues an aborted task
+	if len(args) > 0 && args[0] == "resume" {
+		resume = true
+		args = args[1:]
+	}
+
 	// Process flags and positional arguments
 	for i, arg := range args {
 		switch {
@@ -47,6 +113,31 @@ func main() {
 			model = strings.TrimPrefix(arg, "--model=")
 		case strings.HasPrefix(arg, "--provider="):
 			provider = strings.TrimPrefix(arg, "--provider=")
+		case arg == "--pipeline":
+			pipeline = true
+		case arg == "--yes" || arg == "-y":
+			autoApprove = true
+		case arg == "--sandbox":
+			sandbox = tools.SandboxAuto
+		case strings.HasPrefix(arg, "--sandbox="):
+			sandbox = strings.TrimPrefix(arg, "--sandbox=")
+		case strings.HasPrefix(arg, "--turns="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--turns="))
+			if err != nil || n < 1 {
+				log.Fatalf("Error: --turns expects a positive number of tool calls, got %q", strings.TrimPrefix(arg, "--turns="))
+			}
+			turns = n
+		case strings.HasPrefix(arg, "--resume="):
+			resumeSession = strings.TrimPrefix(arg, "--resume=")
+		case strings.HasPrefix(arg, "--tools="):
+			toolPreset = strings.TrimPrefix(arg, "--tools=")
+		case strings.HasPrefix(arg, "--mode="):
+			mode = strings.TrimPrefix(arg, "--mode=")
+			if mode != "explain-diff" {
+				log.Fatalf("Error: unknown mode %q (the only mode is explain-diff)", mode)
+			}
+		case resume && arg == "--last":
+			resumeLast = true
 		case !strings.HasPrefix(arg, "-"):
 			// This is a positional argument - join all remaining args as the prompt
 			prompt = strings.Join(args[i:], " ")
@@ -54,9 +145,15 @@ func main() {
 		}
 	}
 
-	// Handle provider override if specified
+	if resume && (resumeLast == (prompt != "")) {
+		log.Fatalf("Error: resume expects either --last or the session ID of an aborted task")
+	}
+
+	// Resolve the provider override if specified
+	var clientType api.ClientType
 	if provider != "" {
-		if err := setProviderOverride(provider, useLocal); err != nil {
+		var err error
+		if clientType, err = parseProviderFlag(provider, useLocal); err != nil {
 			log.Fatalf("Failed to set provider: %v", err)
 		}
 	}
@@ -70,17 +167,41 @@ func main() {
 		log.Fatalf("Error: When specifying a model with --model, you must also specify --provider.\nExample: ./coder --provider=openrouter --model=deepseek/deepseek-chat-v3.1:free \"your query\"")
 	}
 
-	if model != "" {
-		chatAgent, err = agent.NewAgentWithModel(model)
-	} else {
-		chatAgent, err = agent.NewAgent()
-	}
+	chatAgent, err = agent.NewAgentWithProvider(clientType, model)
 	if err != nil {
 		log.Fatalf("Failed to initialize agent: %v", err)
 	}
 
 	debugLog(debug, "🤖 Coder initialized successfully!\n")
 
+	// Each instance keeps its own session files; others in the repository are announced
+	chatAgent.SetSessionID(agent.NewSessionID())
+	defer chatAgent.CloseInstance()
+	if others := chatAgent.OtherInstances(); len(others) > 0 {
+		fmt.Printf("👥 %d other coder instance(s) working in this repository - edits to the same files will be flagged\n", len(others))
+	}
+
+	if toolPreset != "" {
+		if err := chatAgent.ApplyToolPreset(toolPreset); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("🧰 Tool preset %s: %d of %d tools enabled\n", toolPreset, len(agent.ToolNames())-len(chatAgent.DisabledTools()), len(agent.ToolNames()))
+	}
+
+	chatAgent.SetAutoApprove(autoApprove)
+	if sandbox != "" {
+		if err := chatAgent.SetSandbox(sandbox); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	if active := chatAgent.Sandbox(); active != nil {
+		fmt.Printf("🔒 Sandboxed shell commands: %s\n", active)
+	}
+	if turns > 0 {
+		chatAgent.SetTurnLimit(turns)
+		fmt.Printf("🤝 Paired mode: pausing after %d tool calls for your go-ahead\n", turns)
+	}
+
 	// Initialize command registry for slash commands
 	cmdRegistry := commands.NewCommandRegistry()
 
@@ -91,7 +212,10 @@ func main() {
 
 	if providerType == api.OllamaClientType {
 		fmt.Printf("🤖 Selected model: %s via %s\n", modelName, providerName)
-		debugLog(debug, "🏠 Using local gpt-oss:20b model via Ollama\n")
+		debugLog(debug, "🏠 Using local %s model via Ollama\n", modelName)
+		if warning := api.LocalModelWarning(modelName); warning != "" {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
 		debugLog(debug, "💰 Cost: FREE (local inference)\n")
 	} else {
 		if api.IsGPTOSSModel(modelName) {
@@ -107,12 +231,62 @@ func main() {
 		debugLog(debug, "📍 Local mode forced by --local flag\n")
 	}
 
+	if resumeSession != "" {
+		session, err := chatAgent.ResumeNamedSession(resumeSession)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		commands.PrintResumedSession(session)
+	}
+
+	if chore {
+		if err := commands.RunChore(choreArgs, chatAgent); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if explainCommit != "" {
+		if err := commands.RunExplainCommit(explainCommit, chatAgent); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if mode == "explain-diff" {
+		// The diff comes from stdin; a prompt is a question to focus on
+		stat, _ := os.Stdin.Stat()
+		if stat.Mode()&os.ModeCharDevice != 0 {
+			log.Fatalf("Error: --mode explain-diff reads a diff from stdin, e.g. git diff | ./coder --mode explain-diff")
+		}
+		diff, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Error reading piped input: %v", err)
+		}
+		if err := commands.RunExplainDiff(string(diff), prompt, chatAgent); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if resume {
+		result, err := chatAgent.ResumeAbortedTask(prompt)
+		reportResult(chatAgent, result, err, debug)
+		return
+	}
+
 	// Handle different input modes
 	if prompt != "" {
 		// Non-interactive mode: execute the provided prompt and exit
 		debugLog(debug, "🔍 Processing your query...\n")
 		debugLog(debug, "Query: %s\n", prompt)
 		debugLog(debug, "=====================================\n")
+		if pipeline {
+			if err := commands.RunPipeline(prompt, chatAgent); err != nil {
+				log.Fatalf("Pipeline failed: %v", err)
+			}
+			return
+		}
 		processQuery(chatAgent, prompt, debug)
 		return
 	}
@@ -143,6 +317,7 @@ func main() {
 	}
 
 	// Interactive mode
+	chatAgent.SetStreaming(true)
 	debugLog(debug, "Type your query or press Ctrl+C to exit\n")
 	debugLog(debug, "=====================================\n")
 
@@ -166,11 +341,21 @@ func main() {
 	interruptChannel := make(chan os.Signal, 1)
 	signal.Notify(interruptChannel, syscall.SIGINT, syscall.SIGTERM)
 
-	// Goroutine to handle graceful shutdown
+	// Ctrl+C cancels the running request or command and returns to the
+	// prompt; when nothing is running, or on a second Ctrl+C, it shuts down
 	go func() {
-		<-interruptChannel
+		for sig := range interruptChannel {
+			if sig == syscall.SIGINT && chatAgent.CancelCurrent() {
+				fmt.Println("\n🛑 Cancelling the current operation (Ctrl+C again to quit)...")
+				continue
+			}
+			break
+		}
 		fmt.Println("\n🛑 Interrupt received! Shutting down gracefully...")
 		chatAgent.PrintConciseSummary()
+		tools.StopDevServer()
+		tools.StopJobs()
+		chatAgent.CloseInstance()
 		os.Exit(0)
 	}()
 
@@ -187,6 +372,21 @@ func main() {
 
 		query = strings.TrimSpace(query)
 
+		// With voice input on, an empty line starts a recording
+		if query == "" && commands.VoiceInputEnabled(chatAgent) {
+			spoken, err := tools.ListenForQuery(tools.LoadVoiceConfig(chatAgent.GetConfigManager().GetConfig()))
+			if err != nil {
+				fmt.Printf("❌ Voice input failed: %v\n", err)
+				continue
+			}
+			if spoken == "" {
+				fmt.Println("🔇 Nothing was heard")
+				continue
+			}
+			fmt.Printf("🎤 You said: %s\n", spoken)
+			query = spoken
+		}
+
 		if query == "" {
 			continue
 		}
@@ -194,6 +394,8 @@ func main() {
 		if query == "exit" || query == "quit" {
 			fmt.Println("👋 Goodbye! Here's your session summary:")
 			chatAgent.PrintConciseSummary()
+			tools.StopDevServer()
+			tools.StopJobs()
 			break
 		}
 
@@ -215,6 +417,76 @@ func main() {
 	}
 }
 
+// runSlackBot starts the Slack Socket Mode bot against the configured repository
+func runSlackBot(args []string) {
+	repoDir := os.Getenv("CODER_SLACK_REPO")
+	metricsAddr := os.Getenv("CODER_METRICS_ADDR")
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--repo="):
+			repoDir = strings.TrimPrefix(arg, "--repo=")
+		case strings.HasPrefix(arg, "--metrics-addr="):
+			metricsAddr = strings.TrimPrefix(arg, "--metrics-addr=")
+		}
+	}
+
+	if repoDir != "" {
+		if err := os.Chdir(repoDir); err != nil {
+			log.Fatalf("Failed to switch to repo %s: %v", repoDir, err)
+		}
+	}
+
+	bot, err := integrations.NewSlackBot()
+	if err != nil {
+		log.Fatalf("Failed to initialize Slack bot: %v", err)
+	}
+
+	if metricsAddr != "" {
+		metrics := integrations.NewMetrics()
+		if err := metrics.ServeMetrics(metricsAddr); err != nil {
+			log.Fatalf("Failed to start metrics endpoint: %v", err)
+		}
+		bot.SetMetrics(metrics)
+		fmt.Printf("📈 Prometheus metrics available at http://%s/metrics\n", metricsAddr)
+	}
+
+	wd, _ := os.Getwd()
+	fmt.Printf("🤖 Starting Slack bot for repository: %s\n", wd)
+	if err := bot.Run(); err != nil {
+		log.Fatalf("Slack bot stopped: %v", err)
+	}
+}
+
+// runAuditReport prints the service audit log and verifies its hash chain
+func runAuditReport() {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		log.Fatalf("Failed to locate config directory: %v", err)
+	}
+
+	auditPath := filepath.Join(configDir, integrations.AuditFileName)
+	entries, err := integrations.ReadAuditLog(auditPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("No audit entries recorded yet.")
+			return
+		}
+		log.Fatalf("Failed to read audit log: %v", err)
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s  %-18s %-6s %-12s %s  %s\n",
+			entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Event, entry.Service,
+			entry.Principal, entry.Session, strings.SplitN(entry.Detail, "\n", 2)[0])
+	}
+
+	if err := integrations.VerifyAuditLog(entries); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ %d audit entries verified\n", len(entries))
+}
+
 // isShellCommand checks if the input looks like a shell command
 func isShellCommand(input string) bool {
 	input = strings.TrimSpace(input)
@@ -257,11 +529,13 @@ func isShellCommand(input string) bool {
 }
 
 // executeShellCommandDirectly executes a shell command directly and prints output
-func executeShellCommandDirectly(command string, debug bool) {
+func executeShellCommandDirectly(chatAgent *agent.Agent, command string, debug bool) {
 	debugLog(debug, "⚡ Direct shell command detected: %s\n", command)
 	debugLog(debug, "=====================================\n")
 
-	result, err := tools.ExecuteShellCommand(command)
+	ctx, end := chatAgent.BeginOperation()
+	defer end()
+	result, err := tools.ExecuteShellCommand(ctx, command)
 	if err != nil {
 		fmt.Printf("❌ Command failed: %v\n", err)
 		fmt.Printf("Output: %s\n", result)
@@ -276,12 +550,12 @@ func executeShellCommandDirectly(command string, debug bool) {
 func processQuery(chatAgent *agent.Agent, query string, debug bool) {
 	// Check if this is a shell command that should be executed directly
 	if isShellCommand(query) {
-		executeShellCommandDirectly(query, debug)
+		executeShellCommandDirectly(chatAgent, query, debug)
 		return
 	}
 
 	// Validate input length before sending to LLM
-	if !validateQueryLength(query) {
+	if !validateQueryLength(query) || !confirmCostEstimate(chatAgent, query) {
 		return
 	}
 
@@ -290,21 +564,53 @@ func processQuery(chatAgent *agent.Agent, query string, debug bool) {
 	debugLog(debug, "=====================================\n")
 
 	result, err := chatAgent.ProcessQuery(query)
+	reportResult(chatAgent, result, err, debug)
+}
+
+// reportResult prints the outcome of a task and saves what it learned and changed
+func reportResult(chatAgent *agent.Agent, result string, err error, debug bool) {
+	if errors.Is(err, agent.ErrTaskAborted) {
+		fmt.Println("⏹️  Task aborted")
+		return
+	}
+	if errors.Is(err, agent.ErrCancelled) {
+		fmt.Println("🛑 Cancelled")
+		return
+	}
+	chatAgent.AnnounceTaskResult(result, err)
 	if err != nil {
 		fmt.Printf("❌ Error: %v\n", err)
+		if result != "" {
+			// A partial-result report of a task that had to stop
+			fmt.Println(result)
+		}
 		return
 	}
 
 	fmt.Println("\n✅ Task completed!")
-	fmt.Println("=====================================")
-	fmt.Println(result)
-	fmt.Println("=====================================")
+	if !chatAgent.StreamedResult(result) {
+		fmt.Println("=====================================")
+		fmt.Println(result)
+		fmt.Println("=====================================")
+	}
 
 	// Print concise summary after task completion
 	chatAgent.PrintConciseSummary()
 
+	// Archive the task so /recall can find it later
+	if err := chatAgent.RecordTranscript(result); err != nil {
+		debugLog(debug, "Warning: Failed to archive task transcript: %v\n", err)
+	}
+
+	// Remember what this task taught us about the project
+	if added, err := chatAgent.DistillKnowledge(result); err != nil {
+		debugLog(debug, "Warning: Failed to distill project knowledge: %v\n", err)
+	} else if added > 0 {
+		fmt.Printf("🧠 Learned %d new project fact(s)\n", added)
+	}
+
 	// Save conversation state for continuity
-	if err := chatAgent.SaveState("default"); err != nil {
+	if err := chatAgent.SaveState(chatAgent.GetSessionID()); err != nil {
 		debugLog(debug, "Warning: Failed to save conversation state: %v\n", err)
 	}
 
@@ -314,7 +620,7 @@ func processQuery(chatAgent *agent.Agent, query string, debug bool) {
 	}
 }
 
-// validateQueryLength validates query length and prompts for confirmation if needed
+// validateQueryLength rejects queries too short to be meaningful
 func validateQueryLength(query string) bool {
 	queryLen := len(strings.TrimSpace(query))
 
@@ -323,29 +629,40 @@ func validateQueryLength(query string) bool {
 		fmt.Printf("❌ Query too short (%d characters). Minimum 3 characters required.\n", queryLen)
 		return false
 	}
+	return true
+}
 
-	// For queries under 20 characters, ask for confirmation
-	if queryLen < 20 {
-		fmt.Printf("⚠️  Short query detected (%d characters): \"%s\"\n", queryLen, query)
-		fmt.Print("Are you sure you want to process this? (y/N): ")
-
-		var response string
-		fmt.Scanln(&response)
-		response = strings.ToLower(strings.TrimSpace(response))
-
-		if response != "y" && response != "yes" {
-			fmt.Println("❌ Query cancelled.")
-			return false
-		}
+// confirmCostEstimate shows the estimated cost of a big task and asks before
+// starting it. Tasks estimated below cost_preview_threshold (USD) start
+// without asking; set cost_preview to false to never ask.
+func confirmCostEstimate(chatAgent *agent.Agent, query string) bool {
+	cfg := chatAgent.GetConfigManager().GetConfig()
+	if !cfg.GetBoolPreference("cost_preview", true) {
+		return true
+	}
+	estimate, err := chatAgent.EstimateTaskCost(query)
+	if err != nil || estimate.High < cfg.GetFloatPreference("cost_preview_threshold", 0.10) {
+		return true
+	}
 
-		fmt.Println("✅ Proceeding with short query...")
+	fmt.Printf("💰 %s\n", estimate)
+	if stat, err := os.Stdin.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return true // nobody to ask in piped or scripted runs
 	}
+	fmt.Print("Proceed? (Y/n): ")
 
+	var response string
+	fmt.Scanln(&response)
+	response = strings.ToLower(strings.TrimSpace(response))
+	if response == "n" || response == "no" {
+		fmt.Println("❌ Query cancelled.")
+		return false
+	}
 	return true
 }
 
 func printHelp() {
-	fmt.Println(`
+	fmt.Print(`
 🤖 Coding agent
 
 A command-line coding assistant using different providers with 4 core tools:
@@ -360,7 +677,19 @@ USAGE:
   Local inference:      ./coder --local "your query"
   Custom model:         ./coder --provider=deepinfra --model=deepseek-ai/ "your query"
   Custom provider:      ./coder --provider=ollama "your query"
+  Review pipeline:      ./coder --pipeline "your query"
+  Paired mode:          ./coder --turns=5 (pause after 5 tool calls for a go-ahead)
+  Auto-approve:         ./coder --yes "your query" (approve high-risk actions without asking)
+  Sandboxed:            ./coder --sandbox "your query" (shell commands stay in the project, no network)
+  Tool preset:          ./coder --tools=explore (full, explore, ci or a configured preset)
+  Maintenance chore:    ./coder chore go-version 1.24 (./coder chore lists them)
+  Explain a diff:       git diff | ./coder --mode explain-diff ["what to focus on"]
+  Explain a commit:     ./coder explain-commit <sha> (diff only, no exploration)
+  Resume aborted task:  ./coder resume --last (or ./coder resume <session-id>)
+  Resume named session: ./coder --resume=<name> (saved with /session save <name>)
   Piped input:         echo "your query" | ./coder
+  Slack bot:           ./coder slack --repo=/path/to/repo [--metrics-addr=:9090]
+  Audit log:           ./coder audit
   Help:                ./coder --help
 
 SLASH COMMANDS (Interactive Mode):
@@ -368,19 +697,37 @@ SLASH COMMANDS (Interactive Mode):
   /models              List available models and select model to use
   /models select       Interactive model selection
   /models <model_id>   Set model directly
-  /provider            Show provider status and switch providers
+  /provider            Show provider status, key status and connectivity
+  /provider list       List available providers and check their connectivity
   /provider select     Interactive provider selection
   /provider <name>     Switch to specific provider
-  /init                Generate or regenerate project context
+  /init                Explore the project and describe it in .coder/project.md
+  /init --regenerate   Replace an existing .coder/project.md
   /commit              Interactive commit workflow - select files and generate commit messages
   /continuity          Show conversation continuity information
+  /session save <name> Save the conversation, todos and token stats under a name
+  /session list        List saved sessions
+  /session resume <name>  Continue a saved session (also ./coder --resume=<name>)
   /info                Show detailed conversation summary and token usage
+  /compact             Summarize older turns of the conversation to free context
+  /jobs                List processes shell commands left running (/jobs kill <id>|all)
+  /diff [--stat|file]  Show the changes the agent's tools made this session
+  /undo [list|n]       Revert the agent's last file change, or change n
+  /checkpoint [list|save|restore n]  Roll the working tree back to before a task
+  /chore <name> [param=value]  Run a maintenance chore (copyright, go-version, mocks, deadcode)
+  /explain [sha]       Explain and review uncommitted changes or a commit from the diff alone
+  /cost                Show session spend, spend cap and remaining provider balance
+  /pipeline <task>     Run a task through planner, implementer and reviewer agents
+  /mode paired [N]     Pause after N tool calls to summarize and wait for a go-ahead
+  /mode auto           Let the agent work until the task is done
+  /voice               Toggle voice input (Enter on an empty line to speak)
   /exit                Exit the interactive session
 
 INPUT FEATURES:
   - Arrow keys for navigation and command history
   - Backspace/Delete for editing
   - Tab for completion (where available)
+  - Esc during a task to pause it, add instructions, or 'abort' it for ./coder resume --last
   - Ctrl+C to exit
 
 EXAMPLES:
@@ -407,7 +754,7 @@ ENVIRONMENT:
   DEEPINFRA_API_KEY: API token for DeepInfra (if not set, uses local Ollama)
 
 MODEL OPTIONS:
-  🏠 Local (Ollama):    gpt-oss:20b - FREE, runs locally (14GB VRAM)
+  🏠 Local (Ollama):    FREE - /models on the ollama provider recommends a model for this machine
   ☁️  Remote (DeepInfra): Multiple models available:
      • openai/gpt-oss-120b (default) - Uses harmony syntax
      • meta-llama/Meta-Llama-3.1-70B-Instruct - Standard format
@@ -415,7 +762,7 @@ MODEL OPTIONS:
      • And many others - check DeepInfra docs for full list
 
 SETUP:
-  Local:  ollama pull gpt-oss:20b
+  Local:  ollama pull <model>
   Remote: export DEEPINFRA_API_KEY="your_api_key_here"
 
 The agent follows a systematic exploration process and will autonomously:
@@ -428,67 +775,27 @@ The agent follows a systematic exploration process and will autonomously:
 
 Type 'help' during interactive mode for this help message.
 Type 'exit' or 'quit' to end the session.
+
 `)
 }
 
-// setProviderOverride temporarily overrides the provider for this session
-func setProviderOverride(providerName string, useLocal bool) error {
-	// Convert provider name to ClientType
+// parseProviderFlag resolves the --provider or --local selection for this
+// session. The provider is passed to the agent explicitly, so other API keys
+// stay untouched.
+func parseProviderFlag(providerName string, useLocal bool) (api.ClientType, error) {
+	if useLocal {
+		fmt.Printf("📍 Using local inference (Ollama)\n")
+		return api.OllamaClientType, nil
+	}
+
 	provider, err := config.GetProviderFromConfigName(strings.ToLower(providerName))
 	if err != nil {
-		return fmt.Errorf("unknown provider '%s'. Available: deepinfra, ollama, cerebras, openrouter, groq, deepseek", providerName)
+		return "", fmt.Errorf("unknown provider '%s'. Available: deepinfra, ollama, cerebras, openrouter, groq, deepseek, mistral, xai, azure, bedrock, huggingface, anthropic, openai, gemini", providerName)
 	}
-
-	// For local flag, force to Ollama and disable API keys temporarily
-	if useLocal || provider == api.OllamaClientType {
-		// Backup and unset API keys to force Ollama
-		if os.Getenv("DEEPINFRA_API_KEY") != "" {
-			os.Setenv("DEEPINFRA_API_KEY_BACKUP", os.Getenv("DEEPINFRA_API_KEY"))
-			os.Unsetenv("DEEPINFRA_API_KEY")
-		}
-		if os.Getenv("CEREBRAS_API_KEY") != "" {
-			os.Setenv("CEREBRAS_API_KEY_BACKUP", os.Getenv("CEREBRAS_API_KEY"))
-			os.Unsetenv("CEREBRAS_API_KEY")
-		}
-		if os.Getenv("OPENROUTER_API_KEY") != "" {
-			os.Setenv("OPENROUTER_API_KEY_BACKUP", os.Getenv("OPENROUTER_API_KEY"))
-			os.Unsetenv("OPENROUTER_API_KEY")
-		}
-		if os.Getenv("GROQ_API_KEY") != "" {
-			os.Setenv("GROQ_API_KEY_BACKUP", os.Getenv("GROQ_API_KEY"))
-			os.Unsetenv("GROQ_API_KEY")
-		}
-		if os.Getenv("DEEPSEEK_API_KEY") != "" {
-			os.Setenv("DEEPSEEK_API_KEY_BACKUP", os.Getenv("DEEPSEEK_API_KEY"))
-			os.Unsetenv("DEEPSEEK_API_KEY")
-		}
+	if provider == api.OllamaClientType {
 		fmt.Printf("📍 Using local inference (Ollama)\n")
-		return nil
-	}
-
-	// For other providers, temporarily unset other API keys to force selection
-	switch provider {
-	case api.DeepInfraClientType:
-		// Keep DEEPINFRA_API_KEY, unset others
-		if os.Getenv("CEREBRAS_API_KEY") != "" {
-			os.Setenv("CEREBRAS_API_KEY_BACKUP", os.Getenv("CEREBRAS_API_KEY"))
-			os.Unsetenv("CEREBRAS_API_KEY")
-		}
-		if os.Getenv("OPENROUTER_API_KEY") != "" {
-			os.Setenv("OPENROUTER_API_KEY_BACKUP", os.Getenv("OPENROUTER_API_KEY"))
-			os.Unsetenv("OPENROUTER_API_KEY")
-		}
-		if os.Getenv("GROQ_API_KEY") != "" {
-			os.Setenv("GROQ_API_KEY_BACKUP", os.Getenv("GROQ_API_KEY"))
-			os.Unsetenv("GROQ_API_KEY")
-		}
-		if os.Getenv("DEEPSEEK_API_KEY") != "" {
-			os.Setenv("DEEPSEEK_API_KEY_BACKUP", os.Getenv("DEEPSEEK_API_KEY"))
-			os.Unsetenv("DEEPSEEK_API_KEY")
-		}
-		// Add similar cases for other providers as needed
+	} else {
+		fmt.Printf("📍 Using provider: %s\n", api.GetProviderName(provider))
 	}
-
-	fmt.Printf("📍 Using provider: %s\n", api.GetProviderName(provider))
-	return nil
+	return provider, nil
 }
@@ -0,0 +1,136 @@
+// Package policy evaluates declarative project policies against tool calls.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PolicyFile is where a project keeps its shared policies, relative to the project root
+const PolicyFile = ".coder/policies.json"
+
+// Actions a policy rule can take
+const (
+	Allow           = "allow"            // skip the remaining rules; high-risk calls are still confirmed
+	Deny            = "deny"             // refuse the tool call
+	RequireApproval = "require_approval" // confirm before running
+)
+
+// Rule is one policy: when its condition matches a tool call, its action applies
+type Rule struct {
+	Name    string `json:"name"`
+	When    string `json:"when"`
+	Action  string `json:"action"`
+	Message string `json:"message,omitempty"`
+
+	condition *Expr
+}
+
+// Set is an ordered list of rules; the first matching rule decides
+type Set struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Input describes a tool call for policy evaluation
+type Input struct {
+	Tool     string
+	Path     string
+	Command  string
+	Size     int // lines written or replaced
+	Provider string
+	Model    string
+}
+
+// vars exposes the input to policy conditions
+func (in Input) vars() map[string]interface{} {
+	return map[string]interface{}{
+		"tool":     in.Tool,
+		"path":     in.Path,
+		"command":  in.Command,
+		"size":     in.Size,
+		"provider": in.Provider,
+		"model":    in.Model,
+	}
+}
+
+// Decision is the outcome of evaluating a tool call
+type Decision struct {
+	Action string // empty when no rule matched
+	Rule   *Rule
+}
+
+// Reason explains the decision for logs and error messages
+func (d Decision) Reason() string {
+	if d.Rule == nil {
+		return ""
+	}
+	if d.Rule.Message != "" {
+		return fmt.Sprintf("policy %q: %s", d.Rule.Name, d.Rule.Message)
+	}
+	return fmt.Sprintf("policy %q", d.Rule.Name)
+}
+
+// Parse compiles a policy document
+func Parse(data []byte) (*Set, error) {
+	var set Set
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse policies: %w", err)
+	}
+	for i, rule := range set.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("policy rule %d is empty", i+1)
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		switch rule.Action {
+		case Allow, Deny, RequireApproval:
+		default:
+			return nil, fmt.Errorf("policy %q: unknown action %q (use allow, deny or require_approval)", rule.Name, rule.Action)
+		}
+		condition, err := Compile(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %w", rule.Name, err)
+		}
+		// A dry run catches misspelled variables before any tool call depends on them
+		if _, err := condition.Eval(Input{}.vars()); err != nil {
+			return nil, fmt.Errorf("policy %q: %w", rule.Name, err)
+		}
+		rule.condition = condition
+	}
+	return &set, nil
+}
+
+// Load reads the project's policies from root. A project without a policy
+// file has no policies, which is not an error.
+func Load(root string) (*Set, error) {
+	data, err := os.ReadFile(filepath.Join(root, PolicyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", PolicyFile, err)
+	}
+	return Parse(data)
+}
+
+// Evaluate returns the action of the first rule matching the input. A rule
+// that fails to evaluate denies the call, so a broken policy fails closed.
+func (s *Set) Evaluate(in Input) Decision {
+	if s == nil {
+		return Decision{}
+	}
+	vars := in.vars()
+	for _, rule := range s.Rules {
+		matched, err := rule.condition.Eval(vars)
+		if err != nil {
+			return Decision{Action: Deny, Rule: &Rule{Name: rule.Name, Message: err.Error()}}
+		}
+		if matched {
+			return Decision{Action: rule.Action, Rule: rule}
+		}
+	}
+	return Decision{}
+}
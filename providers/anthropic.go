@@ -0,0 +1,577 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/types"
+)
+
+const (
+	// anthropicBaseURL is the Messages API root; ANTHROPIC_BASE_URL overrides it
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	// anthropicVersion is the API version sent with every request
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTokens is the output cap sent when no profile lowers it; the
+	// Messages API requires one
+	anthropicMaxTokens = 16384
+)
+
+// anthropicPricing lists USD per million input and output tokens by model
+// family, matched as a prefix so dated snapshots share their family's price.
+// Cache reads cost a tenth of input and cache writes a quarter more.
+var anthropicPricing = []struct {
+	prefix string
+	price  [2]float64
+}{
+	{"claude-opus-4", [2]float64{15, 75}},
+	{"claude-sonnet-4", [2]float64{3, 15}},
+	{"claude-3-7-sonnet", [2]float64{3, 15}},
+	{"claude-haiku-4", [2]float64{1, 5}},
+	{"claude-3-5-haiku", [2]float64{0.8, 4}},
+	{"claude-3-haiku", [2]float64{0.25, 1.25}},
+}
+
+// AnthropicProvider implements Anthropic's Messages API
+type AnthropicProvider struct {
+	httpClient *http.Client
+	apiToken   string
+	baseURL    string
+	debug      bool
+	model      string
+
+	requestParams map[string]interface{} // per-model profile merged into each request
+}
+
+// NewAnthropicProvider creates a new Anthropic provider instance
+func NewAnthropicProvider() (*AnthropicProvider, error) {
+	token := os.Getenv("ANTHROPIC_API_KEY")
+	if token == "" {
+		return nil, fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+	}
+	baseURL := strings.TrimRight(os.Getenv("ANTHROPIC_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = anthropicBaseURL
+	}
+
+	return &AnthropicProvider{
+		httpClient: &http.Client{
+			Timeout: 300 * time.Second,
+		},
+		apiToken: token,
+		baseURL:  baseURL,
+		debug:    false,
+		model:    "claude-sonnet-4-5",
+	}, nil
+}
+
+// NewAnthropicProviderWithModel creates an Anthropic provider with a specific
+// model, or the default model when model is empty
+func NewAnthropicProviderWithModel(model string) (*AnthropicProvider, error) {
+	provider, err := NewAnthropicProvider()
+	if err != nil {
+		return nil, err
+	}
+	if model != "" {
+		provider.model = model
+	}
+	return provider, nil
+}
+
+// SetRequestParameters sets the parameter profile merged into each request
+func (p *AnthropicProvider) SetRequestParameters(params map[string]interface{}) {
+	p.requestParams = params
+}
+
+// SendChatRequest sends a Messages API request to Anthropic
+func (p *AnthropicProvider) SendChatRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newMessagesRequest(messages, tools, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.send(httpReq.WithContext(ctx), reqBody, nil)
+}
+
+// SendChatRequestStream sends a Messages API request and streams the response to onToken
+func (p *AnthropicProvider) SendChatRequestStream(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newMessagesRequest(messages, tools, true)
+	if err != nil {
+		return nil, err
+	}
+	return p.send(httpReq.WithContext(ctx), reqBody, onToken)
+}
+
+// newMessagesRequest converts chat messages and tools into a Messages API
+// request. System messages move to the system field, which is marked for
+// prompt caching, and consecutive messages with the same role are merged
+// since user and assistant turns must alternate.
+func (p *AnthropicProvider) newMessagesRequest(messages []types.Message, tools []types.Tool, stream bool) (*http.Request, []byte, error) {
+	var system []map[string]interface{}
+	var anthropicMessages []map[string]interface{}
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			if msg.Content != "" {
+				system = append(system, map[string]interface{}{"type": "text", "text": msg.Content})
+			}
+			continue
+		}
+
+		var content []map[string]interface{}
+		for _, img := range msg.Images {
+			source := map[string]interface{}{"type": "url", "url": img.URL}
+			if img.Base64 != "" {
+				mediaType := img.Type
+				if mediaType == "" {
+					mediaType = "image/jpeg"
+				}
+				source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": img.Base64}
+			} else if img.URL == "" {
+				continue
+			}
+			content = append(content, map[string]interface{}{"type": "image", "source": source})
+		}
+		if msg.Content != "" {
+			content = append(content, map[string]interface{}{"type": "text", "text": msg.Content})
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "assistant"
+		}
+		if n := len(anthropicMessages); n > 0 && anthropicMessages[n-1]["role"] == role {
+			previous := anthropicMessages[n-1]["content"].([]map[string]interface{})
+			anthropicMessages[n-1]["content"] = append(previous, content...)
+			continue
+		}
+		anthropicMessages = append(anthropicMessages, map[string]interface{}{
+			"role":    role,
+			"content": content,
+		})
+	}
+
+	maxTokens := anthropicMaxTokens
+	if strings.HasPrefix(p.model, "claude-3-") {
+		maxTokens = 8192 // the Claude 3 models' output limit
+	}
+	requestBody := map[string]interface{}{
+		"model":       p.model,
+		"messages":    anthropicMessages,
+		"max_tokens":  maxTokens,
+		"temperature": 0.7,
+	}
+	if len(system) > 0 {
+		system[len(system)-1]["cache_control"] = map[string]interface{}{"type": "ephemeral"}
+		requestBody["system"] = system
+	}
+	if len(tools) > 0 {
+		anthropicTools := make([]map[string]interface{}, len(tools))
+		for i, tool := range tools {
+			anthropicTools[i] = map[string]interface{}{
+				"name":         tool.Function.Name,
+				"description":  tool.Function.Description,
+				"input_schema": tool.Function.Parameters,
+			}
+		}
+		requestBody["tools"] = anthropicTools
+	}
+
+	// Per-model parameter profile from the config; stop uses Anthropic's name
+	types.MergeRequestParameters(requestBody, p.requestParams)
+	if stop, ok := requestBody["stop"]; ok {
+		requestBody["stop_sequences"] = stop
+		delete(requestBody, "stop")
+	}
+	// Extended thinking only runs at the default temperature
+	if _, thinking := requestBody["thinking"]; thinking {
+		delete(requestBody, "temperature")
+	}
+
+	if stream {
+		requestBody["stream"] = true
+	}
+
+	reqBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", p.baseURL+"/messages", nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	p.setHeaders(httpReq)
+
+	if p.debug {
+		fmt.Printf("🔍 Using Anthropic model: %s\n", p.model)
+		fmt.Printf("🔍 Anthropic Request Body: %s\n", string(reqBody))
+	}
+
+	return httpReq, reqBody, nil
+}
+
+// setHeaders adds the API key and version headers
+func (p *AnthropicProvider) setHeaders(httpReq *http.Request) {
+	httpReq.Header.Set("x-api-key", p.apiToken)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+}
+
+// send sends a Messages API request, backing off on rate limits and
+// overload, and decodes the response; with onToken set the response is
+// streamed
+func (p *AnthropicProvider) send(httpReq *http.Request, reqBody []byte, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	maxRetries := 3
+	baseDelay := 1 * time.Second
+
+	if onToken != nil {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		httpReq.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+		resp, err := p.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		if p.debug {
+			fmt.Printf("🔍 Anthropic Response Status (attempt %d): %s\n", attempt+1, resp.Status)
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			var message *anthropicMessage
+			if onToken != nil {
+				message, err = readAnthropicStream(resp.Body, onToken)
+			} else {
+				message = &anthropicMessage{}
+				err = json.NewDecoder(resp.Body).Decode(message)
+			}
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read response: %w", err)
+			}
+			return message.toChatResponse(p.model), nil
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		// 529 means the API is overloaded
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == 529) && attempt < maxRetries {
+			waitTime := baseDelay * time.Duration(math.Pow(2, float64(attempt)))
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 && seconds <= 60 {
+				waitTime = time.Duration(seconds) * time.Second
+			}
+			fmt.Printf("⏳ Rate limit hit (attempt %d/%d), waiting %v before retry...\n",
+				attempt+1, maxRetries+1, waitTime)
+			if err := sleepContext(httpReq.Context(), waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("Anthropic API request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded")
+}
+
+// anthropicBlock is a content block of a Messages API response
+type anthropicBlock struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	Thinking string          `json:"thinking"`
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Input    json.RawMessage `json:"input"`
+}
+
+// anthropicUsage is the token usage of a Messages API response
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// anthropicMessage is the subset of a Messages API response the agent uses
+type anthropicMessage struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// toChatResponse converts a Messages API response into the shared chat
+// format. Anthropic counts cached input separately, so the prompt tokens are
+// the sum and the cost charges each part at its own rate.
+func (m *anthropicMessage) toChatResponse(model string) *types.ChatResponse {
+	var choice types.Choice
+	choice.Message.Role = "assistant"
+
+	var text, thinking []string
+	for _, block := range m.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "thinking":
+			thinking = append(thinking, block.Thinking)
+		case "tool_use":
+			var toolCall types.ToolCall
+			toolCall.ID = block.ID
+			toolCall.Type = "function"
+			toolCall.Function.Name = block.Name
+			toolCall.Function.Arguments = string(block.Input)
+			if len(block.Input) == 0 {
+				toolCall.Function.Arguments = "{}"
+			}
+			choice.Message.ToolCalls = append(choice.Message.ToolCalls, toolCall)
+		}
+	}
+	choice.Message.Content = strings.Join(text, "")
+	choice.Message.ReasoningContent = strings.Join(thinking, "\n")
+
+	switch m.StopReason {
+	case "tool_use":
+		choice.FinishReason = "tool_calls"
+	case "max_tokens":
+		choice.FinishReason = "length"
+	default:
+		choice.FinishReason = "stop"
+	}
+
+	response := &types.ChatResponse{
+		ID:      m.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []types.Choice{choice},
+	}
+	usage := m.Usage
+	response.Usage.PromptTokens = usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	response.Usage.CompletionTokens = usage.OutputTokens
+	response.Usage.TotalTokens = response.Usage.PromptTokens + usage.OutputTokens
+	response.Usage.PromptTokensDetails.CachedTokens = usage.CacheReadInputTokens
+	if usage.CacheCreationInputTokens > 0 {
+		written := usage.CacheCreationInputTokens
+		response.Usage.PromptTokensDetails.CacheWriteTokens = &written
+	}
+	input, output := AnthropicPricing(model)
+	response.Usage.EstimatedCost = (float64(usage.InputTokens)*input +
+		float64(usage.CacheReadInputTokens)*input*0.1 +
+		float64(usage.CacheCreationInputTokens)*input*1.25 +
+		float64(usage.OutputTokens)*output) / 1e6
+	return response
+}
+
+// anthropicEvent is one server-sent event of a Messages API stream
+type anthropicEvent struct {
+	Type         string           `json:"type"`
+	Index        int              `json:"index"`
+	Message      anthropicMessage `json:"message"`
+	ContentBlock anthropicBlock   `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readAnthropicStream assembles a response from a Messages API stream,
+// passing text and thinking to onToken as they arrive. Tool inputs are
+// assembled from their JSON fragments.
+func readAnthropicStream(body io.Reader, onToken types.StreamCallback) (*anthropicMessage, error) {
+	var message anthropicMessage
+	var inputs []strings.Builder
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // event names repeat the type field
+		}
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			message = event.Message
+			message.Content = nil
+		case "content_block_start":
+			if event.Index < 0 || event.Index >= maxStreamToolCalls {
+				return nil, fmt.Errorf("stream has an invalid content block index %d", event.Index)
+			}
+			for len(message.Content) <= event.Index {
+				message.Content = append(message.Content, anthropicBlock{})
+				inputs = append(inputs, strings.Builder{})
+			}
+			message.Content[event.Index] = event.ContentBlock
+		case "content_block_delta":
+			if event.Index < 0 || event.Index >= len(message.Content) {
+				return nil, fmt.Errorf("stream has a delta for unknown content block %d", event.Index)
+			}
+			block := &message.Content[event.Index]
+			switch event.Delta.Type {
+			case "text_delta":
+				block.Text += event.Delta.Text
+				if onToken != nil {
+					onToken(event.Delta.Text, "")
+				}
+			case "thinking_delta":
+				block.Thinking += event.Delta.Thinking
+				if onToken != nil {
+					onToken("", event.Delta.Thinking)
+				}
+			case "input_json_delta":
+				inputs[event.Index].WriteString(event.Delta.PartialJSON)
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				message.StopReason = event.Delta.StopReason
+			}
+			if event.Usage != nil {
+				message.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("stream failed: %s", event.Error.Message)
+			}
+			return nil, fmt.Errorf("stream failed")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	for i := range message.Content {
+		if message.Content[i].Type == "tool_use" && inputs[i].Len() > 0 {
+			message.Content[i].Input = json.RawMessage(inputs[i].String())
+		}
+	}
+	return &message, nil
+}
+
+// CheckConnection checks if the Anthropic connection is valid
+func (p *AnthropicProvider) CheckConnection() error {
+	if p.apiToken == "" {
+		return fmt.Errorf("ANTHROPIC_API_KEY environment variable not set")
+	}
+	return nil
+}
+
+// SetDebug enables or disables debug mode
+func (p *AnthropicProvider) SetDebug(debug bool) {
+	p.debug = debug
+}
+
+// SetModel sets the model to use
+func (p *AnthropicProvider) SetModel(model string) error {
+	if model == "" {
+		return fmt.Errorf("model name cannot be empty")
+	}
+	p.model = model
+	return nil
+}
+
+// GetModel returns the current model
+func (p *AnthropicProvider) GetModel() string {
+	return p.model
+}
+
+// GetProvider returns the provider name
+func (p *AnthropicProvider) GetProvider() string {
+	return "anthropic"
+}
+
+// ListModels returns the models available to the account with their prices
+func (p *AnthropicProvider) ListModels() ([]types.ModelInfo, error) {
+	httpReq, err := http.NewRequest("GET", p.baseURL+"/models?limit=100", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list models, status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	models := make([]types.ModelInfo, len(result.Data))
+	for i, model := range result.Data {
+		input, output := AnthropicPricing(model.ID)
+		models[i] = types.ModelInfo{
+			ID:            model.ID,
+			Name:          model.DisplayName,
+			Provider:      "anthropic",
+			Description:   model.DisplayName,
+			ContextLength: 200000,
+			InputCost:     input,
+			OutputCost:    output,
+			Cost:          (input + output) / 2,
+		}
+	}
+	return models, nil
+}
+
+// AnthropicPricing returns USD per million input and output tokens for a
+// model (zero if unknown)
+func AnthropicPricing(model string) (float64, float64) {
+	for _, family := range anthropicPricing {
+		if strings.HasPrefix(model, family.prefix) {
+			return family.price[0], family.price[1]
+		}
+	}
+	return 0, 0
+}
+
+// GetModelContextLimit returns the context limit for the current model
+func (p *AnthropicProvider) GetModelContextLimit() (int, error) {
+	return 200000, nil // every current Claude model serves 200K
+}
+
+// SupportsVision checks if the current model supports vision
+func (p *AnthropicProvider) SupportsVision() bool {
+	return true // every current Claude model accepts images
+}
+
+// SendVisionRequest sends a vision-enabled chat request
+func (p *AnthropicProvider) SendVisionRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	return p.SendChatRequest(ctx, messages, tools, reasoning)
+}
@@ -0,0 +1,301 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// DefaultAzureOpenAIAPIVersion is used when AZURE_OPENAI_API_VERSION is unset
+const DefaultAzureOpenAIAPIVersion = "2024-10-21"
+
+// AzureOpenAIProvider implements Azure OpenAI. Requests go to a deployment
+// rather than a model, so the model name is the deployment name.
+type AzureOpenAIProvider struct {
+	httpClient *http.Client
+	apiKey     string
+	endpoint   string // e.g. https://my-resource.openai.azure.com
+	apiVersion string
+	debug      bool
+	model      string // deployment name
+
+	responseFormat map[string]interface{} // set while a structured-output request is in flight
+	requestParams  map[string]interface{} // per-model profile merged into each request
+}
+
+// NewAzureOpenAIProvider creates an Azure OpenAI provider from AZURE_OPENAI_API_KEY,
+// AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT and AZURE_OPENAI_API_VERSION
+func NewAzureOpenAIProvider() (*AzureOpenAIProvider, error) {
+	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_API_KEY environment variable not set")
+	}
+	endpoint := strings.TrimRight(os.Getenv("AZURE_OPENAI_ENDPOINT"), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT environment variable not set (e.g. https://my-resource.openai.azure.com)")
+	}
+	apiVersion := os.Getenv("AZURE_OPENAI_API_VERSION")
+	if apiVersion == "" {
+		apiVersion = DefaultAzureOpenAIAPIVersion
+	}
+
+	return &AzureOpenAIProvider{
+		httpClient: &http.Client{
+			Timeout: 300 * time.Second,
+		},
+		apiKey:     apiKey,
+		endpoint:   endpoint,
+		apiVersion: apiVersion,
+		debug:      false,
+		model:      os.Getenv("AZURE_OPENAI_DEPLOYMENT"),
+	}, nil
+}
+
+// NewAzureOpenAIProviderWithModel creates an Azure OpenAI provider for a specific deployment
+func NewAzureOpenAIProviderWithModel(model string) (*AzureOpenAIProvider, error) {
+	provider, err := NewAzureOpenAIProvider()
+	if err != nil {
+		return nil, err
+	}
+	if model != "" {
+		provider.model = model
+	}
+	return provider, nil
+}
+
+// SetResponseFormat constrains subsequent responses to a JSON schema (nil clears it)
+func (p *AzureOpenAIProvider) SetResponseFormat(format map[string]interface{}) {
+	p.responseFormat = format
+}
+
+// SetRequestParameters sets the parameter profile merged into each request
+func (p *AzureOpenAIProvider) SetRequestParameters(params map[string]interface{}) {
+	p.requestParams = params
+}
+
+// chatCompletionsURL returns the deployment's chat completions endpoint
+func (p *AzureOpenAIProvider) chatCompletionsURL() string {
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		p.endpoint, url.PathEscape(p.model), url.QueryEscape(p.apiVersion))
+}
+
+// SendChatRequest sends a chat completion request to the configured deployment
+func (p *AzureOpenAIProvider) SendChatRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newChatRequest(messages, tools, reasoning, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.sendRequestWithRetry(httpReq.WithContext(ctx), reqBody)
+}
+
+// SendChatRequestStream sends a chat completion request and streams the response to onToken
+func (p *AzureOpenAIProvider) SendChatRequestStream(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newChatRequest(messages, tools, reasoning, true)
+	if err != nil {
+		return nil, err
+	}
+	return sendOpenAIStyleStreamRequest(p.httpClient, httpReq.WithContext(ctx), reqBody, "Azure OpenAI", p.debug, onToken)
+}
+
+// newChatRequest builds a chat completion request, streamed if stream is set
+func (p *AzureOpenAIProvider) newChatRequest(messages []types.Message, tools []types.Tool, reasoning string, stream bool) (*http.Request, []byte, error) {
+	if p.model == "" {
+		return nil, nil, fmt.Errorf("no Azure OpenAI deployment selected; set AZURE_OPENAI_DEPLOYMENT or choose one with /models")
+	}
+
+	azureMessages := make([]map[string]interface{}, len(messages))
+	for i, msg := range messages {
+		azureMessages[i] = map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		}
+	}
+
+	requestBody := map[string]interface{}{
+		"messages": azureMessages,
+	}
+	if len(tools) > 0 {
+		requestBody["tools"] = tools
+		requestBody["tool_choice"] = "auto"
+	}
+	if p.responseFormat != nil {
+		requestBody["response_format"] = p.responseFormat
+	}
+
+	// Per-model parameter profile from the config
+	types.MergeRequestParameters(requestBody, p.requestParams)
+
+	if stream {
+		EnableStreaming(requestBody, true)
+	}
+
+	reqBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", p.chatCompletionsURL(), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("api-key", p.apiKey)
+
+	if p.debug {
+		fmt.Printf("🔍 Azure OpenAI Request URL: %s\n", p.chatCompletionsURL())
+		fmt.Printf("🔍 Azure OpenAI Request Body: %s\n", string(reqBody))
+	}
+
+	return httpReq, reqBody, nil
+}
+
+// sendRequestWithRetry retries rate-limited requests, honouring Retry-After
+func (p *AzureOpenAIProvider) sendRequestWithRetry(httpReq *http.Request, reqBody []byte) (*types.ChatResponse, error) {
+	maxRetries := 3
+	baseDelay := 1 * time.Second
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		httpReq.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+		resp, err := p.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		respBody, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", readErr)
+		}
+
+		if p.debug {
+			fmt.Printf("🔍 Azure OpenAI Response Status (attempt %d): %s\n", attempt+1, resp.Status)
+			fmt.Printf("🔍 Azure OpenAI Response Body: %s\n", string(respBody))
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			var chatResp types.ChatResponse
+			if err := json.Unmarshal(respBody, &chatResp); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+			}
+			return &chatResp, nil
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			waitTime := baseDelay * time.Duration(math.Pow(2, float64(attempt)))
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+				waitTime = time.Duration(seconds) * time.Second
+			}
+			if waitTime > 60*time.Second {
+				waitTime = 60 * time.Second
+			}
+			fmt.Printf("⏳ Rate limit hit (attempt %d/%d), waiting %v before retry...\n",
+				attempt+1, maxRetries+1, waitTime)
+			if err := sleepContext(httpReq.Context(), waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("Azure OpenAI request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded")
+}
+
+// CheckConnection checks that the endpoint and key are configured
+func (p *AzureOpenAIProvider) CheckConnection() error {
+	if p.apiKey == "" {
+		return fmt.Errorf("AZURE_OPENAI_API_KEY environment variable not set")
+	}
+	if p.endpoint == "" {
+		return fmt.Errorf("AZURE_OPENAI_ENDPOINT environment variable not set")
+	}
+	return nil
+}
+
+// SetDebug enables or disables debug mode
+func (p *AzureOpenAIProvider) SetDebug(debug bool) {
+	p.debug = debug
+}
+
+// SetModel sets the deployment to use
+func (p *AzureOpenAIProvider) SetModel(model string) error {
+	p.model = model
+	return nil
+}
+
+// GetModel returns the current deployment
+func (p *AzureOpenAIProvider) GetModel() string {
+	return p.model
+}
+
+// GetProvider returns the provider name
+func (p *AzureOpenAIProvider) GetProvider() string {
+	return "azure"
+}
+
+// ListModels returns the configured deployments. Listing deployments needs
+// management-plane credentials, so they come from AZURE_OPENAI_DEPLOYMENTS
+// (comma-separated) and AZURE_OPENAI_DEPLOYMENT.
+func (p *AzureOpenAIProvider) ListModels() ([]types.ModelInfo, error) {
+	seen := make(map[string]bool)
+	var models []types.ModelInfo
+	names := append(strings.Split(os.Getenv("AZURE_OPENAI_DEPLOYMENTS"), ","), os.Getenv("AZURE_OPENAI_DEPLOYMENT"), p.model)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		models = append(models, types.ModelInfo{
+			ID:          name,
+			Name:        name,
+			Provider:    "azure",
+			Description: "Azure OpenAI deployment",
+		})
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no Azure OpenAI deployments configured; set AZURE_OPENAI_DEPLOYMENT")
+	}
+	return models, nil
+}
+
+// GetModelContextLimit returns the context limit for the current deployment.
+// Deployment names usually carry the model name, which is used as a hint.
+func (p *AzureOpenAIProvider) GetModelContextLimit() (int, error) {
+	model := strings.ToLower(p.model)
+
+	switch {
+	case strings.Contains(model, "gpt-4.1"):
+		return 1047576, nil // GPT-4.1 family supports ~1M context
+	case strings.Contains(model, "gpt-35"), strings.Contains(model, "gpt-3.5"):
+		return 16385, nil
+	case strings.Contains(model, "o1"), strings.Contains(model, "o3"), strings.Contains(model, "o4"):
+		return 200000, nil
+	default:
+		return 128000, nil // GPT-4o and GPT-4 Turbo
+	}
+}
+
+// SupportsVision checks if the current deployment supports vision
+func (p *AzureOpenAIProvider) SupportsVision() bool {
+	// Deployment names are chosen by the user, so vision support can't be inferred
+	return false
+}
+
+// SendVisionRequest sends a vision-enabled chat request
+func (p *AzureOpenAIProvider) SendVisionRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	return p.SendChatRequest(ctx, messages, tools, reasoning)
+}
@@ -0,0 +1,446 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// BedrockProvider implements AWS Bedrock through the model-agnostic Converse
+// API, which serves both the Claude and Llama families
+type BedrockProvider struct {
+	httpClient *http.Client
+	creds      AWSCredentials
+	region     string
+	debug      bool
+	model      string
+
+	requestParams map[string]interface{} // per-model profile merged into each request
+}
+
+// NewBedrockProvider creates a Bedrock provider from the standard AWS environment variables
+func NewBedrockProvider() (*BedrockProvider, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables must be set")
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &BedrockProvider{
+		httpClient: &http.Client{
+			Timeout: 300 * time.Second,
+		},
+		creds:  creds,
+		region: region,
+		debug:  false,
+		model:  "anthropic.claude-3-5-sonnet-20240620-v1:0",
+	}, nil
+}
+
+// NewBedrockProviderWithModel creates a Bedrock provider with a specific model
+func NewBedrockProviderWithModel(model string) (*BedrockProvider, error) {
+	provider, err := NewBedrockProvider()
+	if err != nil {
+		return nil, err
+	}
+	provider.model = model
+	return provider, nil
+}
+
+// SetRequestParameters sets the parameter profile merged into each request
+func (p *BedrockProvider) SetRequestParameters(params map[string]interface{}) {
+	p.requestParams = params
+}
+
+// SendChatRequest sends a Converse request to Bedrock
+func (p *BedrockProvider) SendChatRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	requestBody := p.buildConverseRequest(messages, tools)
+
+	reqBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/converse", p.region, awsURIEscape(p.model))
+	httpReq, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if p.debug {
+		fmt.Printf("🔍 Using Bedrock model: %s (%s)\n", p.model, p.region)
+		fmt.Printf("🔍 Bedrock Request Body: %s\n", string(reqBody))
+	}
+
+	return p.sendRequestWithRetry(httpReq.WithContext(ctx), reqBody)
+}
+
+// buildConverseRequest converts chat messages and tools into a Converse request.
+// System messages move to the system field and consecutive messages with the
+// same role are merged, since Converse requires user and assistant to alternate.
+func (p *BedrockProvider) buildConverseRequest(messages []types.Message, tools []types.Tool) map[string]interface{} {
+	var system []map[string]interface{}
+	var converseMessages []map[string]interface{}
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			if msg.Content != "" {
+				system = append(system, map[string]interface{}{"text": msg.Content})
+			}
+			continue
+		}
+
+		var content []map[string]interface{}
+		if msg.Content != "" {
+			content = append(content, map[string]interface{}{"text": msg.Content})
+		}
+		for _, img := range msg.Images {
+			if img.Base64 == "" {
+				continue // Converse only accepts inline image bytes
+			}
+			content = append(content, map[string]interface{}{
+				"image": map[string]interface{}{
+					"format": strings.TrimPrefix(img.Type, "image/"),
+					"source": map[string]interface{}{"bytes": img.Base64},
+				},
+			})
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "assistant"
+		}
+		if n := len(converseMessages); n > 0 && converseMessages[n-1]["role"] == role {
+			previous := converseMessages[n-1]["content"].([]map[string]interface{})
+			converseMessages[n-1]["content"] = append(previous, content...)
+			continue
+		}
+		converseMessages = append(converseMessages, map[string]interface{}{
+			"role":    role,
+			"content": content,
+		})
+	}
+
+	// Inference settings use the same names as the other providers so model
+	// profiles apply unchanged; anything else goes to the model itself
+	params := map[string]interface{}{
+		"max_tokens":  p.calculateMaxTokens(messages, tools),
+		"temperature": 0.7,
+	}
+	types.MergeRequestParameters(params, p.requestParams)
+	inferenceConfig := make(map[string]interface{})
+	additional := make(map[string]interface{})
+	for key, value := range params {
+		switch key {
+		case "max_tokens":
+			inferenceConfig["maxTokens"] = value
+		case "temperature":
+			inferenceConfig["temperature"] = value
+		case "top_p":
+			inferenceConfig["topP"] = value
+		case "stop":
+			inferenceConfig["stopSequences"] = value
+		default:
+			additional[key] = value
+		}
+	}
+
+	requestBody := map[string]interface{}{
+		"messages":        converseMessages,
+		"inferenceConfig": inferenceConfig,
+	}
+	if len(system) > 0 {
+		requestBody["system"] = system
+	}
+	if len(additional) > 0 {
+		requestBody["additionalModelRequestFields"] = additional
+	}
+
+	if len(tools) > 0 {
+		toolSpecs := make([]map[string]interface{}, len(tools))
+		for i, tool := range tools {
+			toolSpecs[i] = map[string]interface{}{
+				"toolSpec": map[string]interface{}{
+					"name":        tool.Function.Name,
+					"description": tool.Function.Description,
+					"inputSchema": map[string]interface{}{"json": tool.Function.Parameters},
+				},
+			}
+		}
+		requestBody["toolConfig"] = map[string]interface{}{"tools": toolSpecs}
+	}
+
+	return requestBody
+}
+
+// converseResponse is the subset of the Converse response the agent uses
+type converseResponse struct {
+	Output struct {
+		Message struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Text    string `json:"text"`
+				ToolUse *struct {
+					ToolUseID string          `json:"toolUseId"`
+					Name      string          `json:"name"`
+					Input     json.RawMessage `json:"input"`
+				} `json:"toolUse"`
+				ReasoningContent *struct {
+					ReasoningText struct {
+						Text string `json:"text"`
+					} `json:"reasoningText"`
+				} `json:"reasoningContent"`
+			} `json:"content"`
+		} `json:"message"`
+	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+		TotalTokens  int `json:"totalTokens"`
+	} `json:"usage"`
+}
+
+// toChatResponse converts a Converse response into the shared chat format
+func (r *converseResponse) toChatResponse(model string) *types.ChatResponse {
+	var choice types.Choice
+	choice.Message.Role = "assistant"
+
+	var text, thinking []string
+	for _, block := range r.Output.Message.Content {
+		switch {
+		case block.ToolUse != nil:
+			var toolCall types.ToolCall
+			toolCall.ID = block.ToolUse.ToolUseID
+			toolCall.Type = "function"
+			toolCall.Function.Name = block.ToolUse.Name
+			toolCall.Function.Arguments = string(block.ToolUse.Input)
+			choice.Message.ToolCalls = append(choice.Message.ToolCalls, toolCall)
+		case block.ReasoningContent != nil:
+			thinking = append(thinking, block.ReasoningContent.ReasoningText.Text)
+		case block.Text != "":
+			text = append(text, block.Text)
+		}
+	}
+	choice.Message.Content = strings.Join(text, "\n")
+	choice.Message.ReasoningContent = strings.Join(thinking, "\n")
+
+	switch r.StopReason {
+	case "tool_use":
+		choice.FinishReason = "tool_calls"
+	case "max_tokens":
+		choice.FinishReason = "length"
+	default:
+		choice.FinishReason = "stop"
+	}
+
+	response := &types.ChatResponse{
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []types.Choice{choice},
+	}
+	response.Usage.PromptTokens = r.Usage.InputTokens
+	response.Usage.CompletionTokens = r.Usage.OutputTokens
+	response.Usage.TotalTokens = r.Usage.TotalTokens
+	return response
+}
+
+// sendRequestWithRetry signs and sends the request, backing off on throttling
+func (p *BedrockProvider) sendRequestWithRetry(httpReq *http.Request, reqBody []byte) (*types.ChatResponse, error) {
+	maxRetries := 3
+	baseDelay := 1 * time.Second
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		// Each attempt needs a fresh signature and body
+		httpReq.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+		httpReq.ContentLength = int64(len(reqBody))
+		SignSigV4(httpReq, reqBody, p.creds, p.region, "bedrock", time.Now())
+
+		resp, err := p.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		respBody, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", readErr)
+		}
+
+		if p.debug {
+			fmt.Printf("🔍 Bedrock Response Status (attempt %d): %s\n", attempt+1, resp.Status)
+			fmt.Printf("🔍 Bedrock Response Body: %s\n", string(respBody))
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			var converse converseResponse
+			if err := json.Unmarshal(respBody, &converse); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+			}
+			return converse.toChatResponse(p.model), nil
+		}
+
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && attempt < maxRetries {
+			waitTime := baseDelay * time.Duration(math.Pow(2, float64(attempt)))
+			fmt.Printf("⏳ Bedrock throttled the request (attempt %d/%d), waiting %v before retry...\n",
+				attempt+1, maxRetries+1, waitTime)
+			if err := sleepContext(httpReq.Context(), waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("Bedrock API request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded")
+}
+
+// CheckConnection checks that AWS credentials are configured
+func (p *BedrockProvider) CheckConnection() error {
+	if p.creds.AccessKeyID == "" || p.creds.SecretAccessKey == "" {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables must be set")
+	}
+	return nil
+}
+
+// SetDebug enables or disables debug mode
+func (p *BedrockProvider) SetDebug(debug bool) {
+	p.debug = debug
+}
+
+// SetModel sets the model to use
+func (p *BedrockProvider) SetModel(model string) error {
+	p.model = model
+	return nil
+}
+
+// GetModel returns the current model
+func (p *BedrockProvider) GetModel() string {
+	return p.model
+}
+
+// GetProvider returns the provider name
+func (p *BedrockProvider) GetProvider() string {
+	return "bedrock"
+}
+
+// ListModels returns the Claude and Llama text models offered in the region
+func (p *BedrockProvider) ListModels() ([]types.ModelInfo, error) {
+	endpoint := fmt.Sprintf("https://bedrock.%s.amazonaws.com/foundation-models?%s", p.region,
+		url.Values{"byOutputModality": {"TEXT"}}.Encode())
+	httpReq, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	SignSigV4(httpReq, nil, p.creds, p.region, "bedrock", time.Now())
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list models, status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		ModelSummaries []struct {
+			ModelID      string `json:"modelId"`
+			ModelName    string `json:"modelName"`
+			ProviderName string `json:"providerName"`
+		} `json:"modelSummaries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var models []types.ModelInfo
+	for _, model := range result.ModelSummaries {
+		if !strings.HasPrefix(model.ModelID, "anthropic.") && !strings.HasPrefix(model.ModelID, "meta.") {
+			continue
+		}
+		models = append(models, types.ModelInfo{
+			ID:          model.ModelID,
+			Name:        model.ModelName,
+			Provider:    "bedrock",
+			Description: fmt.Sprintf("%s %s via AWS Bedrock", model.ProviderName, model.ModelName),
+		})
+	}
+	return models, nil
+}
+
+// GetModelContextLimit returns the context limit for the current model
+func (p *BedrockProvider) GetModelContextLimit() (int, error) {
+	model := p.model
+
+	switch {
+	case strings.Contains(model, "anthropic.claude"):
+		return 200000, nil // Claude 3 and later support 200K context
+	case strings.Contains(model, "llama3-1"), strings.Contains(model, "llama3-2"), strings.Contains(model, "llama3-3"):
+		return 128000, nil // Llama 3.1+ support 128K context
+	case strings.Contains(model, "llama3"):
+		return 8000, nil // Original Llama 3 models support 8K context
+	default:
+		return 32000, nil // Conservative default for other models
+	}
+}
+
+// calculateMaxTokens calculates appropriate max_tokens based on input size and model limits
+func (p *BedrockProvider) calculateMaxTokens(messages []types.Message, tools []types.Tool) int {
+	contextLimit, err := p.GetModelContextLimit()
+	if err != nil || contextLimit == 0 {
+		contextLimit = 32000
+	}
+
+	// Rough estimation: 1 token ≈ 4 characters
+	inputTokens := len(tools) * 200
+	for _, msg := range messages {
+		inputTokens += len(msg.Content) / 4
+	}
+
+	maxOutput := contextLimit - inputTokens - 1000
+	if maxOutput > 8192 {
+		maxOutput = 8192 // Bedrock caps output at 8K for most Claude and Llama models
+	} else if maxOutput < 1000 {
+		maxOutput = 1000
+	}
+	return maxOutput
+}
+
+// SupportsVision checks if the current model supports vision
+func (p *BedrockProvider) SupportsVision() bool {
+	return strings.Contains(p.model, "anthropic.claude-3") || strings.Contains(p.model, "llama3-2-11b") || strings.Contains(p.model, "llama3-2-90b")
+}
+
+// SendVisionRequest sends a vision-enabled chat request; images are sent as inline bytes
+func (p *BedrockProvider) SendVisionRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	return p.SendChatRequest(ctx, messages, tools, reasoning)
+}
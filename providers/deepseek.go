@@ -0,0 +1,54 @@
+package providers
+
+// deepSeekPricing lists USD per million cache-miss input, cache-hit input and
+// output tokens, since the models endpoint doesn't report prices
+var deepSeekPricing = map[string][3]float64{
+	"deepseek-chat":     {0.28, 0.028, 0.42},
+	"deepseek-reasoner": {0.28, 0.028, 0.42},
+}
+
+// deepSeekConfig describes DeepSeek's OpenAI-compatible API. Context-cache
+// hits are reported as prompt_cache_hit_tokens and charged at the cache rate.
+// Earlier reasoning isn't sent back: deepseek-reasoner rejects
+// reasoning_content in input messages.
+var deepSeekConfig = ProviderConfig{
+	Name:         "deepseek",
+	Label:        "DeepSeek",
+	BaseURL:      "https://api.deepseek.com",
+	BaseURLEnv:   "DEEPSEEK_BASE_URL",
+	APIKeyEnv:    "DEEPSEEK_API_KEY",
+	DefaultModel: "deepseek-chat",
+	StreamUsage:  true,
+	Prepare: func(model, reasoning string, body map[string]interface{}) {
+		// The reasoner ignores sampling parameters
+		if model == "deepseek-reasoner" {
+			delete(body, "temperature")
+		}
+	},
+	Pricing:      DeepSeekPricing,
+	ContextLimit: func(string) int { return 131072 }, // both deepseek-chat and deepseek-reasoner serve 128K
+	Describe: func(id string) string {
+		if id == "deepseek-reasoner" {
+			return "DeepSeek thinking mode"
+		}
+		return "DeepSeek chat model"
+	},
+}
+
+// NewDeepSeekProvider creates a new DeepSeek provider instance
+func NewDeepSeekProvider() (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProvider(deepSeekConfig)
+}
+
+// NewDeepSeekProviderWithModel creates a DeepSeek provider with a specific
+// model, or the default model when model is empty
+func NewDeepSeekProviderWithModel(model string) (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProviderWithModel(deepSeekConfig, model)
+}
+
+// DeepSeekPricing returns USD per million cache-miss input, cache-hit input
+// and output tokens for a model (zero if unknown)
+func DeepSeekPricing(model string) (float64, float64, float64) {
+	price := deepSeekPricing[model]
+	return price[0], price[1], price[2]
+}
@@ -0,0 +1,612 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// geminiBaseURL is the Gemini API root; GEMINI_BASE_URL overrides it
+const geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// geminiPricing lists USD per million input and output tokens by model
+// prefix, longest first so lite models match before their family. Cached
+// input is charged at a quarter of the input price.
+var geminiPricing = []struct {
+	prefix string
+	price  [2]float64
+}{
+	{"gemini-2.5-flash-lite", [2]float64{0.10, 0.40}},
+	{"gemini-2.5-flash", [2]float64{0.30, 2.50}},
+	{"gemini-2.5-pro", [2]float64{1.25, 10.00}},
+	{"gemini-2.0-flash-lite", [2]float64{0.075, 0.30}},
+	{"gemini-2.0-flash", [2]float64{0.10, 0.40}},
+}
+
+// geminiSchemaKeys are the JSON schema keywords Gemini's OpenAPI subset accepts
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "description": true, "properties": true, "required": true,
+	"items": true, "enum": true, "format": true, "nullable": true,
+	"minimum": true, "maximum": true, "minItems": true, "maxItems": true,
+}
+
+// GeminiProvider implements Google's Gemini API with native function calling
+type GeminiProvider struct {
+	httpClient *http.Client
+	apiToken   string
+	baseURL    string
+	debug      bool
+	model      string
+
+	responseFormat map[string]interface{} // set while a structured-output request is in flight
+	requestParams  map[string]interface{} // per-model profile merged into each request
+	contextLimits  map[string]int         // inputTokenLimit reported by ListModels
+}
+
+// NewGeminiProvider creates a new Gemini provider instance from GEMINI_API_KEY
+// (or GOOGLE_API_KEY)
+func NewGeminiProvider() (*GeminiProvider, error) {
+	token := os.Getenv("GEMINI_API_KEY")
+	if token == "" {
+		token = os.Getenv("GOOGLE_API_KEY")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY environment variable not set")
+	}
+	baseURL := strings.TrimRight(os.Getenv("GEMINI_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = geminiBaseURL
+	}
+
+	return &GeminiProvider{
+		httpClient: &http.Client{
+			Timeout: 300 * time.Second,
+		},
+		apiToken: token,
+		baseURL:  baseURL,
+		debug:    false,
+		model:    "gemini-2.5-flash",
+	}, nil
+}
+
+// NewGeminiProviderWithModel creates a Gemini provider with a specific model,
+// or the default model when model is empty
+func NewGeminiProviderWithModel(model string) (*GeminiProvider, error) {
+	provider, err := NewGeminiProvider()
+	if err != nil {
+		return nil, err
+	}
+	if model != "" {
+		provider.model = strings.TrimPrefix(model, "models/")
+	}
+	return provider, nil
+}
+
+// SetResponseFormat constrains subsequent responses to a JSON schema (nil clears it)
+func (p *GeminiProvider) SetResponseFormat(format map[string]interface{}) {
+	p.responseFormat = format
+}
+
+// SetRequestParameters sets the parameter profile merged into each request
+func (p *GeminiProvider) SetRequestParameters(params map[string]interface{}) {
+	p.requestParams = params
+}
+
+// SendChatRequest sends a generateContent request to Gemini
+func (p *GeminiProvider) SendChatRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newGenerateRequest(messages, tools, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.send(httpReq.WithContext(ctx), reqBody, nil)
+}
+
+// SendChatRequestStream sends a streamGenerateContent request and streams the response to onToken
+func (p *GeminiProvider) SendChatRequestStream(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newGenerateRequest(messages, tools, true)
+	if err != nil {
+		return nil, err
+	}
+	return p.send(httpReq.WithContext(ctx), reqBody, onToken)
+}
+
+// newGenerateRequest converts chat messages and tools into a Gemini request.
+// System messages become the system instruction, assistant messages use the
+// model role, and consecutive messages with the same role are merged.
+func (p *GeminiProvider) newGenerateRequest(messages []types.Message, tools []types.Tool, stream bool) (*http.Request, []byte, error) {
+	var system []map[string]interface{}
+	var contents []map[string]interface{}
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			if msg.Content != "" {
+				system = append(system, map[string]interface{}{"text": msg.Content})
+			}
+			continue
+		}
+
+		var parts []map[string]interface{}
+		if msg.Content != "" {
+			parts = append(parts, map[string]interface{}{"text": msg.Content})
+		}
+		for _, img := range msg.Images {
+			mimeType := img.Type
+			if mimeType == "" {
+				mimeType = "image/jpeg"
+			}
+			if img.Base64 != "" {
+				parts = append(parts, map[string]interface{}{"inlineData": map[string]interface{}{"mimeType": mimeType, "data": img.Base64}})
+			} else if img.URL != "" {
+				parts = append(parts, map[string]interface{}{"fileData": map[string]interface{}{"mimeType": mimeType, "fileUri": img.URL}})
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		if n := len(contents); n > 0 && contents[n-1]["role"] == role {
+			previous := contents[n-1]["parts"].([]map[string]interface{})
+			contents[n-1]["parts"] = append(previous, parts...)
+			continue
+		}
+		contents = append(contents, map[string]interface{}{
+			"role":  role,
+			"parts": parts,
+		})
+	}
+
+	// Generation settings use the same names as the other providers so model
+	// profiles apply unchanged; Gemini-specific ones (thinkingConfig) pass through
+	params := map[string]interface{}{"temperature": 0.7}
+	types.MergeRequestParameters(params, p.requestParams)
+	generationConfig := make(map[string]interface{})
+	for key, value := range params {
+		switch key {
+		case "max_tokens":
+			generationConfig["maxOutputTokens"] = value
+		case "top_p":
+			generationConfig["topP"] = value
+		case "top_k":
+			generationConfig["topK"] = value
+		case "stop":
+			generationConfig["stopSequences"] = value
+		default:
+			generationConfig[key] = value
+		}
+	}
+	if p.responseFormat != nil {
+		generationConfig["responseMimeType"] = "application/json"
+		if jsonSchema, ok := p.responseFormat["json_schema"].(map[string]interface{}); ok {
+			if schema, ok := jsonSchema["schema"]; ok {
+				generationConfig["responseSchema"] = geminiSchema(schema)
+			}
+		}
+	}
+
+	requestBody := map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": generationConfig,
+	}
+	if len(system) > 0 {
+		requestBody["systemInstruction"] = map[string]interface{}{"parts": system}
+	}
+	if len(tools) > 0 {
+		declarations := make([]map[string]interface{}, len(tools))
+		for i, tool := range tools {
+			declaration := map[string]interface{}{
+				"name":        tool.Function.Name,
+				"description": tool.Function.Description,
+			}
+			// Gemini rejects object schemas without properties
+			if schema, ok := geminiSchema(tool.Function.Parameters).(map[string]interface{}); ok {
+				if properties, _ := schema["properties"].(map[string]interface{}); len(properties) > 0 {
+					declaration["parameters"] = schema
+				}
+			}
+			declarations[i] = declaration
+		}
+		requestBody["tools"] = []map[string]interface{}{{"functionDeclarations": declarations}}
+	}
+
+	reqBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, p.model)
+	if stream {
+		endpoint = fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.baseURL, p.model)
+	}
+	httpReq, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", p.apiToken)
+
+	if p.debug {
+		fmt.Printf("🔍 Using Gemini model: %s\n", p.model)
+		fmt.Printf("🔍 Gemini Request Body: %s\n", string(reqBody))
+	}
+
+	return httpReq, reqBody, nil
+}
+
+// geminiSchema copies a JSON schema keeping only the keywords Gemini accepts
+func geminiSchema(schema interface{}) interface{} {
+	switch value := schema.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{})
+		for key, field := range value {
+			if !geminiSchemaKeys[key] {
+				continue
+			}
+			if key == "properties" {
+				properties := make(map[string]interface{})
+				if fields, ok := field.(map[string]interface{}); ok {
+					for name, property := range fields {
+						properties[name] = geminiSchema(property)
+					}
+				}
+				converted[key] = properties
+				continue
+			}
+			if key == "required" {
+				if required, ok := field.([]interface{}); ok && len(required) == 0 {
+					continue
+				}
+				if required, ok := field.([]string); ok && len(required) == 0 {
+					continue
+				}
+			}
+			converted[key] = geminiSchema(field)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			converted[i] = geminiSchema(item)
+		}
+		return converted
+	}
+	return schema
+}
+
+// send sends a Gemini request, backing off on rate limits and overload, and
+// decodes the response; with onToken set the response is streamed
+func (p *GeminiProvider) send(httpReq *http.Request, reqBody []byte, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	maxRetries := 3
+	baseDelay := 1 * time.Second
+
+	if onToken != nil {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		httpReq.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+		resp, err := p.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		if p.debug {
+			fmt.Printf("🔍 Gemini Response Status (attempt %d): %s\n", attempt+1, resp.Status)
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			var generated *geminiResponse
+			if onToken != nil {
+				generated, err = readGeminiStream(resp.Body, onToken)
+			} else {
+				generated = &geminiResponse{}
+				err = json.NewDecoder(resp.Body).Decode(generated)
+			}
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read response: %w", err)
+			}
+			return generated.toChatResponse(p.model), nil
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) && attempt < maxRetries {
+			waitTime := baseDelay * time.Duration(math.Pow(2, float64(attempt)))
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 && seconds <= 60 {
+				waitTime = time.Duration(seconds) * time.Second
+			}
+			fmt.Printf("⏳ Rate limit hit (attempt %d/%d), waiting %v before retry...\n",
+				attempt+1, maxRetries+1, waitTime)
+			if err := sleepContext(httpReq.Context(), waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("Gemini API request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded")
+}
+
+// geminiPart is one part of a Gemini content
+type geminiPart struct {
+	Text         string `json:"text"`
+	Thought      bool   `json:"thought"`
+	FunctionCall *struct {
+		ID   string          `json:"id"`
+		Name string          `json:"name"`
+		Args json.RawMessage `json:"args"`
+	} `json:"functionCall"`
+}
+
+// geminiResponse is the subset of a generateContent response the agent uses
+type geminiResponse struct {
+	ResponseID   string `json:"responseId"`
+	ModelVersion string `json:"modelVersion"`
+	Candidates   []struct {
+		Content struct {
+			Parts []geminiPart `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// toChatResponse converts a Gemini response into the shared chat format.
+// Function calls carry no IDs on older models, so IDs are made up from their
+// position. Thinking tokens are billed as output.
+func (r *geminiResponse) toChatResponse(model string) *types.ChatResponse {
+	var choice types.Choice
+	choice.Message.Role = "assistant"
+	choice.FinishReason = "stop"
+
+	if len(r.Candidates) > 0 {
+		candidate := r.Candidates[0]
+		var text, thinking strings.Builder
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				var toolCall types.ToolCall
+				toolCall.ID = part.FunctionCall.ID
+				if toolCall.ID == "" {
+					toolCall.ID = fmt.Sprintf("call_%d", len(choice.Message.ToolCalls)+1)
+				}
+				toolCall.Type = "function"
+				toolCall.Function.Name = part.FunctionCall.Name
+				toolCall.Function.Arguments = string(part.FunctionCall.Args)
+				if len(part.FunctionCall.Args) == 0 {
+					toolCall.Function.Arguments = "{}"
+				}
+				choice.Message.ToolCalls = append(choice.Message.ToolCalls, toolCall)
+			case part.Thought:
+				thinking.WriteString(part.Text)
+			default:
+				text.WriteString(part.Text)
+			}
+		}
+		choice.Message.Content = text.String()
+		choice.Message.ReasoningContent = thinking.String()
+
+		switch {
+		case len(choice.Message.ToolCalls) > 0:
+			choice.FinishReason = "tool_calls"
+		case candidate.FinishReason == "MAX_TOKENS":
+			choice.FinishReason = "length"
+		}
+	}
+
+	response := &types.ChatResponse{
+		ID:      r.ResponseID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []types.Choice{choice},
+	}
+	usage := r.UsageMetadata
+	response.Usage.PromptTokens = usage.PromptTokenCount
+	response.Usage.CompletionTokens = usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+	response.Usage.TotalTokens = response.Usage.PromptTokens + response.Usage.CompletionTokens
+	response.Usage.PromptTokensDetails.CachedTokens = usage.CachedContentTokenCount
+	input, output := GeminiPricing(model)
+	cached := usage.CachedContentTokenCount
+	response.Usage.EstimatedCost = (float64(usage.PromptTokenCount-cached)*input + float64(cached)*input*0.25 +
+		float64(response.Usage.CompletionTokens)*output) / 1e6
+	return response
+}
+
+// readGeminiStream assembles a response from a streamGenerateContent event
+// stream. Each event holds the next parts of the first candidate and the
+// usage so far; text and thoughts go to onToken as they arrive.
+func readGeminiStream(body io.Reader, onToken types.StreamCallback) (*geminiResponse, error) {
+	var assembled geminiResponse
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("stream failed: %s", chunk.Error.Message)
+		}
+		if chunk.ResponseID != "" {
+			assembled.ResponseID = chunk.ResponseID
+		}
+		assembled.UsageMetadata = chunk.UsageMetadata
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		parts := chunk.Candidates[0].Content.Parts
+		if len(assembled.Candidates) == 0 {
+			assembled.Candidates = chunk.Candidates[:1:1]
+			assembled.Candidates[0].Content.Parts = nil
+		}
+		candidate := &assembled.Candidates[0]
+		for _, part := range parts {
+			if part.FunctionCall == nil && part.Text != "" && onToken != nil {
+				if part.Thought {
+					onToken("", part.Text)
+				} else {
+					onToken(part.Text, "")
+				}
+			}
+			candidate.Content.Parts = append(candidate.Content.Parts, part)
+		}
+		if reason := chunk.Candidates[0].FinishReason; reason != "" {
+			candidate.FinishReason = reason
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return &assembled, nil
+}
+
+// CheckConnection checks if the Gemini connection is valid
+func (p *GeminiProvider) CheckConnection() error {
+	if p.apiToken == "" {
+		return fmt.Errorf("GEMINI_API_KEY environment variable not set")
+	}
+	return nil
+}
+
+// SetDebug enables or disables debug mode
+func (p *GeminiProvider) SetDebug(debug bool) {
+	p.debug = debug
+}
+
+// SetModel sets the model to use
+func (p *GeminiProvider) SetModel(model string) error {
+	if model == "" {
+		return fmt.Errorf("model name cannot be empty")
+	}
+	p.model = strings.TrimPrefix(model, "models/")
+	return nil
+}
+
+// GetModel returns the current model
+func (p *GeminiProvider) GetModel() string {
+	return p.model
+}
+
+// GetProvider returns the provider name
+func (p *GeminiProvider) GetProvider() string {
+	return "gemini"
+}
+
+// ListModels returns the Gemini models that can generate content, with their
+// context windows and prices
+func (p *GeminiProvider) ListModels() ([]types.ModelInfo, error) {
+	httpReq, err := http.NewRequest("GET", p.baseURL+"/models?pageSize=1000", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("x-goog-api-key", p.apiToken)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list models, status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			DisplayName                string   `json:"displayName"`
+			Description                string   `json:"description"`
+			InputTokenLimit            int      `json:"inputTokenLimit"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	p.contextLimits = make(map[string]int)
+	var models []types.ModelInfo
+	for _, model := range result.Models {
+		id := strings.TrimPrefix(model.Name, "models/")
+		generates := false
+		for _, method := range model.SupportedGenerationMethods {
+			generates = generates || method == "generateContent"
+		}
+		// Embedding, image and speech models can't chat
+		if !generates || !strings.HasPrefix(id, "gemini-") || strings.Contains(id, "image") || strings.Contains(id, "tts") {
+			continue
+		}
+		p.contextLimits[id] = model.InputTokenLimit
+		input, output := GeminiPricing(id)
+		models = append(models, types.ModelInfo{
+			ID:            id,
+			Name:          model.DisplayName,
+			Provider:      "gemini",
+			Description:   model.Description,
+			ContextLength: model.InputTokenLimit,
+			InputCost:     input,
+			OutputCost:    output,
+			Cost:          (input + output) / 2,
+		})
+	}
+	return models, nil
+}
+
+// GeminiPricing returns USD per million input and output tokens for a model
+// (zero if unknown)
+func GeminiPricing(model string) (float64, float64) {
+	for _, family := range geminiPricing {
+		if strings.HasPrefix(model, family.prefix) {
+			return family.price[0], family.price[1]
+		}
+	}
+	return 0, 0
+}
+
+// GetModelContextLimit returns the context limit for the current model
+func (p *GeminiProvider) GetModelContextLimit() (int, error) {
+	if limit := p.contextLimits[p.model]; limit > 0 {
+		return limit, nil
+	}
+	return 1048576, nil // current Gemini models serve 1M tokens
+}
+
+// SupportsVision checks if the current model supports vision
+func (p *GeminiProvider) SupportsVision() bool {
+	return true // every Gemini chat model accepts images
+}
+
+// SendVisionRequest sends a vision-enabled chat request
+func (p *GeminiProvider) SendVisionRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	return p.SendChatRequest(ctx, messages, tools, reasoning)
+}
@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"strings"
+)
+
+// groqPricing lists USD per million input and output tokens, since the models
+// endpoint doesn't report prices
+var groqPricing = map[string][2]float64{
+	"llama-3.3-70b-versatile":                       {0.59, 0.79},
+	"llama-3.1-8b-instant":                          {0.05, 0.08},
+	"meta-llama/llama-4-scout-17b-16e-instruct":     {0.11, 0.34},
+	"meta-llama/llama-4-maverick-17b-128e-instruct": {0.20, 0.60},
+	"openai/gpt-oss-120b":                           {0.15, 0.75},
+	"openai/gpt-oss-20b":                            {0.10, 0.50},
+	"moonshotai/kimi-k2-instruct":                   {1.00, 3.00},
+	"qwen/qwen3-32b":                                {0.29, 0.59},
+}
+
+// groqConfig describes Groq's OpenAI-compatible API, which names the output
+// cap max_completion_tokens and lists each model's context window
+var groqConfig = ProviderConfig{
+	Name:           "groq",
+	Label:          "Groq",
+	BaseURL:        "https://api.groq.com/openai/v1",
+	BaseURLEnv:     "GROQ_BASE_URL",
+	APIKeyEnv:      "GROQ_API_KEY",
+	DefaultModel:   "llama-3.3-70b-versatile",
+	MaxTokensParam: "max_completion_tokens",
+	Pricing: func(model string) (float64, float64, float64) {
+		input, output := GroqPricing(model)
+		return input, input, output
+	},
+	ContextLimit: func(string) int { return 131072 }, // Groq's current chat models all serve 128K
+	KeepModel: func(id string) bool {
+		// Speech and moderation models can't chat
+		return !strings.Contains(id, "whisper") && !strings.Contains(id, "guard") && !strings.Contains(id, "tts")
+	},
+}
+
+// NewGroqProvider creates a new Groq provider instance
+func NewGroqProvider() (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProvider(groqConfig)
+}
+
+// NewGroqProviderWithModel creates a Groq provider with a specific model, or
+// the default model when model is empty
+func NewGroqProviderWithModel(model string) (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProviderWithModel(groqConfig, model)
+}
+
+// GroqPricing returns USD per million input and output tokens for a model
+// (zero if unknown)
+func GroqPricing(model string) (float64, float64) {
+	price := groqPricing[model]
+	return price[0], price[1]
+}
@@ -0,0 +1,161 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// huggingFaceDefaultModel names the HF_ENDPOINT_URL endpoint
+const huggingFaceDefaultModel = "tgi"
+
+// huggingFaceConfig describes Hugging Face Inference Endpoints and self-hosted
+// TGI servers, through TGI's OpenAI-compatible Messages API. Each endpoint
+// serves a single model, so the model name selects an endpoint: a name from
+// endpoints, or defaultURL for any other. The token is optional for
+// self-hosted TGI.
+func huggingFaceConfig(defaultURL string, endpoints map[string]string) ProviderConfig {
+	endpointURL := func(model string) string {
+		if url, ok := endpoints[model]; ok {
+			return url
+		}
+		return defaultURL
+	}
+
+	return ProviderConfig{
+		Name:            "huggingface",
+		Label:           "Hugging Face",
+		APIKeyEnv:       "HF_TOKEN",
+		APIKeyAltEnv:    "HUGGING_FACE_HUB_TOKEN",
+		APIKeyOptional:  true,
+		DefaultModel:    huggingFaceDefaultModel,
+		FitMaxTokens:    true,
+		MaxOutputTokens: 4096,
+		ListedContext:   true,
+		BaseURLFor: func(model string) string {
+			return endpointURL(model) + "/v1"
+		},
+		Prepare: func(model, reasoning string, body map[string]interface{}) {
+			// TGI serves one model and ignores the name, so send the conventional "tgi"
+			body["model"] = huggingFaceDefaultModel
+		},
+		ContextLimit: func(string) int { return 8192 }, // TGI's default max input length
+		FindModel: func(models []types.ModelInfo, model string) (types.ModelInfo, bool) {
+			if _, named := endpoints[model]; !named {
+				model = huggingFaceDefaultModel // other names go to the default endpoint
+			}
+			return findModel(models, model)
+		},
+		ListModels: func(p *OpenAICompatibleProvider) ([]types.ModelInfo, error) {
+			names := make([]string, 0, len(endpoints))
+			for name := range endpoints {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if _, named := endpoints[huggingFaceDefaultModel]; !named {
+				names = append([]string{huggingFaceDefaultModel}, names...)
+			}
+
+			models := make([]types.ModelInfo, len(names))
+			for i, name := range names {
+				url := endpointURL(name)
+				models[i] = types.ModelInfo{ID: name, Name: name, Provider: "huggingface", Description: url}
+				if info, err := fetchEndpointInfo(p, url); err == nil {
+					models[i].Description = fmt.Sprintf("%s at %s", info.ModelID, url)
+					models[i].ContextLength = info.MaxInputTokens
+				}
+			}
+			return models, nil
+		},
+		CheckConnection: func(p *OpenAICompatibleProvider) error {
+			_, err := fetchEndpointInfo(p, endpointURL(p.model))
+			return err
+		},
+		WrapError: func(p *OpenAICompatibleProvider, err error) error {
+			// Scale-to-zero endpoints return 503 while they start
+			if strings.Contains(err.Error(), "status 503") {
+				return fmt.Errorf("endpoint %s is starting up or paused, try again shortly: %w", endpointURL(p.model), err)
+			}
+			return err
+		},
+	}
+}
+
+// NewHuggingFaceProvider creates a provider from HF_ENDPOINT_URL (the default
+// endpoint), HF_ENDPOINTS (extra "name=url" pairs, comma-separated) and HF_TOKEN
+func NewHuggingFaceProvider() (*OpenAICompatibleProvider, error) {
+	return NewHuggingFaceProviderWithModel("")
+}
+
+// NewHuggingFaceProviderWithModel creates a provider for a named endpoint, or
+// the HF_ENDPOINT_URL endpoint when model is empty
+func NewHuggingFaceProviderWithModel(model string) (*OpenAICompatibleProvider, error) {
+	defaultURL := strings.TrimRight(os.Getenv("HF_ENDPOINT_URL"), "/")
+	if defaultURL == "" {
+		return nil, fmt.Errorf("HF_ENDPOINT_URL environment variable not set")
+	}
+	endpoints, err := ParseHuggingFaceEndpoints(os.Getenv("HF_ENDPOINTS"))
+	if err != nil {
+		return nil, err
+	}
+	return NewOpenAICompatibleProviderWithModel(huggingFaceConfig(defaultURL, endpoints), model)
+}
+
+// ParseHuggingFaceEndpoints parses "name=url" pairs separated by commas
+func ParseHuggingFaceEndpoints(value string) (map[string]string, error) {
+	endpoints := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(pair, "=")
+		name, url = strings.TrimSpace(name), strings.TrimRight(strings.TrimSpace(url), "/")
+		if !ok || name == "" || !strings.HasPrefix(url, "http") {
+			return nil, fmt.Errorf("invalid HF_ENDPOINTS entry %q (expected name=https://...)", pair)
+		}
+		endpoints[name] = url
+	}
+	return endpoints, nil
+}
+
+// endpointInfo is the subset of TGI's /info response used for context limits
+type endpointInfo struct {
+	ModelID        string `json:"model_id"`
+	MaxInputTokens int    `json:"max_input_tokens"`
+	MaxInputLength int    `json:"max_input_length"` // older TGI versions
+}
+
+// fetchEndpointInfo queries an endpoint's /info route
+func fetchEndpointInfo(p *OpenAICompatibleProvider, url string) (*endpointInfo, error) {
+	httpReq, err := p.newRequestURL("GET", url+"/info", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("endpoint info failed, status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var info endpointInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode endpoint info: %w", err)
+	}
+	if info.MaxInputTokens == 0 {
+		info.MaxInputTokens = info.MaxInputLength
+	}
+	return &info, nil
+}
@@ -0,0 +1,107 @@
+package providers
+
+import (
+	"strings"
+)
+
+// mistralPricing lists USD per million input and output tokens for the
+// La Plateforme models, since the models endpoint doesn't report prices
+var mistralPricing = map[string][2]float64{
+	"mistral-large":     {2.00, 6.00},
+	"mistral-medium":    {0.40, 2.00},
+	"mistral-small":     {0.10, 0.30},
+	"magistral-medium":  {2.00, 5.00},
+	"magistral-small":   {0.50, 1.50},
+	"codestral":         {0.30, 0.90},
+	"devstral-medium":   {0.40, 2.00},
+	"devstral-small":    {0.10, 0.30},
+	"pixtral-large":     {2.00, 6.00},
+	"pixtral-12b":       {0.15, 0.15},
+	"ministral-8b":      {0.10, 0.10},
+	"ministral-3b":      {0.04, 0.04},
+	"open-mistral-nemo": {0.15, 0.15},
+}
+
+// mistralConfig describes Mistral's La Plateforme API. It rejects requests
+// whose prompt plus max_tokens exceed the context window, and differs from
+// OpenAI in a few names: "seed" is "random_seed" and a forced tool call is
+// tool_choice "any".
+var mistralConfig = ProviderConfig{
+	Name:         "mistral",
+	Label:        "Mistral",
+	BaseURL:      "https://api.mistral.ai/v1",
+	BaseURLEnv:   "MISTRAL_BASE_URL",
+	APIKeyEnv:    "MISTRAL_API_KEY",
+	DefaultModel: "devstral-medium-latest",
+	FitMaxTokens: true,
+	Prepare: func(model, reasoning string, body map[string]interface{}) {
+		// Mistral rejects assistant messages without content or tool calls
+		messages, _ := body["messages"].([]map[string]interface{})
+		kept := messages[:0]
+		for _, msg := range messages {
+			if content, ok := msg["content"].(string); msg["role"] == "assistant" && ok && strings.TrimSpace(content) == "" {
+				continue
+			}
+			kept = append(kept, msg)
+		}
+		body["messages"] = kept
+	},
+	Finish: func(model string, body map[string]interface{}) {
+		if seed, ok := body["seed"]; ok {
+			body["random_seed"] = seed
+			delete(body, "seed")
+		}
+		if body["tool_choice"] == "required" {
+			body["tool_choice"] = "any"
+		}
+	},
+	Pricing: func(model string) (float64, float64, float64) {
+		input, output := MistralPricing(model)
+		return input, input, output
+	},
+	ContextLimit: mistralContextLimit,
+}
+
+// NewMistralProvider creates a new Mistral provider instance
+func NewMistralProvider() (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProvider(mistralConfig)
+}
+
+// NewMistralProviderWithModel creates a Mistral provider with a specific
+// model, or the default model when model is empty
+func NewMistralProviderWithModel(model string) (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProviderWithModel(mistralConfig, model)
+}
+
+// MistralPricing returns USD per million input and output tokens for a model,
+// matching dated and -latest names by family (zero if unknown)
+func MistralPricing(model string) (float64, float64) {
+	best := ""
+	for family := range mistralPricing {
+		if strings.HasPrefix(model, family) && len(family) > len(best) {
+			best = family
+		}
+	}
+	if best == "" {
+		return 0, 0
+	}
+	return mistralPricing[best][0], mistralPricing[best][1]
+}
+
+// mistralContextLimit returns a model's context window when the model list
+// hasn't reported it
+func mistralContextLimit(model string) int {
+	switch {
+	case strings.HasPrefix(model, "codestral"):
+		return 256000
+	case strings.HasPrefix(model, "magistral"):
+		return 40000 // Magistral reasoning models support 40K context
+	case strings.HasPrefix(model, "mistral-large"), strings.HasPrefix(model, "mistral-medium"),
+		strings.HasPrefix(model, "mistral-small"), strings.HasPrefix(model, "devstral"),
+		strings.HasPrefix(model, "pixtral"), strings.HasPrefix(model, "ministral"),
+		strings.HasPrefix(model, "open-mistral-nemo"):
+		return 128000
+	default:
+		return 32000 // Conservative default for other models
+	}
+}
@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// openAIPricing lists USD per million input, cached input and output tokens
+// by model prefix, longest first so mini and nano models match before their
+// family; dated snapshots share their model's price
+var openAIPricing = []struct {
+	prefix string
+	price  [3]float64
+}{
+	{"gpt-5-nano", [3]float64{0.05, 0.005, 0.40}},
+	{"gpt-5-mini", [3]float64{0.25, 0.025, 2.00}},
+	{"gpt-5", [3]float64{1.25, 0.125, 10.00}},
+	{"gpt-4.1-nano", [3]float64{0.10, 0.025, 0.40}},
+	{"gpt-4.1-mini", [3]float64{0.40, 0.10, 1.60}},
+	{"gpt-4.1", [3]float64{2.00, 0.50, 8.00}},
+	{"gpt-4o-mini", [3]float64{0.15, 0.075, 0.60}},
+	{"gpt-4o", [3]float64{2.50, 1.25, 10.00}},
+	{"o4-mini", [3]float64{1.10, 0.275, 4.40}},
+	{"o3-mini", [3]float64{1.10, 0.55, 4.40}},
+	{"o3", [3]float64{2.00, 0.50, 8.00}},
+}
+
+// openAIReasoningModel matches the o-series and GPT-5 models, which take
+// reasoning effort instead of a sampling temperature
+var openAIReasoningModel = regexp.MustCompile(`^(o\d|gpt-5)`)
+
+// openAIConfig describes OpenAI's chat completions API. The model list has
+// neither prices nor context windows, so both come from built-in tables.
+// max_tokens is deprecated and reasoning models reject it, so it goes by
+// max_completion_tokens.
+var openAIConfig = ProviderConfig{
+	Name:           "openai",
+	Label:          "OpenAI",
+	BaseURL:        "https://api.openai.com/v1",
+	BaseURLEnv:     "OPENAI_BASE_URL",
+	APIKeyEnv:      "OPENAI_API_KEY",
+	DefaultModel:   "gpt-4.1",
+	StreamUsage:    true,
+	Images:         true,
+	MaxTokensParam: "max_completion_tokens",
+	VisionModel:    "gpt-4.1",
+	Prepare: func(model, reasoning string, body map[string]interface{}) {
+		if openAIReasoningModel.MatchString(model) {
+			delete(body, "temperature")
+		}
+	},
+	VisionCapable: func(model string) bool {
+		return !strings.HasPrefix(model, "gpt-3.5") && !strings.HasPrefix(model, "o3-mini")
+	},
+	Pricing:      OpenAIPricing,
+	ContextLimit: openAIContextLimit,
+	KeepModel:    isOpenAIChatModel,
+}
+
+// NewOpenAIProvider creates a new OpenAI provider instance
+func NewOpenAIProvider() (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProvider(openAIConfig)
+}
+
+// NewOpenAIProviderWithModel creates an OpenAI provider with a specific
+// model, or the default model when model is empty
+func NewOpenAIProviderWithModel(model string) (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProviderWithModel(openAIConfig, model)
+}
+
+// isOpenAIChatModel reports whether a listed model can chat; the list also
+// holds embedding, image, speech and realtime models
+func isOpenAIChatModel(model string) bool {
+	if !strings.HasPrefix(model, "gpt-") && !openAIReasoningModel.MatchString(model) {
+		return false
+	}
+	for _, other := range []string{"audio", "realtime", "tts", "transcribe", "image", "instruct", "search"} {
+		if strings.Contains(model, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenAIPricing returns USD per million input, cached input and output tokens
+// for a model (zero if unknown)
+func OpenAIPricing(model string) (float64, float64, float64) {
+	for _, family := range openAIPricing {
+		if strings.HasPrefix(model, family.prefix) {
+			return family.price[0], family.price[1], family.price[2]
+		}
+	}
+	return 0, 0, 0
+}
+
+// openAIContextLimit returns a model's context window
+func openAIContextLimit(model string) int {
+	switch {
+	case strings.HasPrefix(model, "gpt-4.1"):
+		return 1047576
+	case strings.HasPrefix(model, "gpt-5"):
+		return 400000
+	case openAIReasoningModel.MatchString(model):
+		return 200000
+	case strings.HasPrefix(model, "gpt-3.5"):
+		return 16385
+	default:
+		return 128000 // GPT-4o and GPT-4 Turbo
+	}
+}
@@ -0,0 +1,559 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// ProviderConfig describes an OpenAI-compatible provider. Requests, retries,
+// streaming and model listing are shared, so a provider is only the ways it
+// differs from OpenAI.
+type ProviderConfig struct {
+	Name           string            // provider name, e.g. "groq"
+	Label          string            // display name in logs and errors, e.g. "Groq"
+	BaseURL        string            // API root that /chat/completions and /models hang off
+	BaseURLEnv     string            // environment variable that overrides BaseURL
+	APIKeyEnv      string            // environment variable holding the API key
+	APIKeyAltEnv   string            // environment variable read when APIKeyEnv is unset
+	APIKeyOptional bool              // the server may run without authentication
+	DefaultModel   string            // model used when none is given
+	Headers        map[string]string // sent with every request besides the bearer token
+	ModelsPath     string            // path of the model list (default /models)
+
+	StreamUsage     bool   // ask for the usage chunk at the end of a stream
+	Images          bool   // send message images as image_url parts
+	FitMaxTokens    bool   // set max_tokens to the room left in the context window
+	MaxOutputTokens int    // cap on the fitted max_tokens (default 16000)
+	MaxTokensParam  string // the server's name for max_tokens, if different
+	VisionModel     string // model vision requests switch to, if any
+	ListedContext   bool   // fetch the model list to look up context windows
+
+	// Prepare adjusts the request body before the model profile is merged
+	// in, given the requested reasoning effort
+	Prepare func(model, reasoning string, body map[string]interface{})
+	// Finish adjusts the request body after the model profile is merged in,
+	// for parameters the server names differently or rejects
+	Finish func(model string, body map[string]interface{})
+	// BaseURLFor returns the API root for a model, for servers that run one
+	// endpoint per model; it replaces BaseURL
+	BaseURLFor func(model string) string
+	// VisionCapable reports whether a model takes images itself, so vision
+	// requests stay on it instead of switching to VisionModel
+	VisionCapable func(model string) bool
+	// Pricing returns USD per million fresh input, cached input and output
+	// tokens; without it, the cost the server reports is used
+	Pricing func(model string) (input, cached, output float64)
+	// ContextLimit returns a model's context window when the model list
+	// doesn't report it
+	ContextLimit func(model string) int
+	// KeepModel reports whether a listed model can chat
+	KeepModel func(id string) bool
+	// FindModel looks a model up in the model list (exact match by default)
+	FindModel func(models []types.ModelInfo, model string) (types.ModelInfo, bool)
+	// Describe describes a listed model the server gives no description for
+	Describe func(id string) string
+	// ListModels replaces fetching the model list, for servers without one
+	ListModels func(p *OpenAICompatibleProvider) ([]types.ModelInfo, error)
+	// CheckConnection replaces checking that the API key is set
+	CheckConnection func(p *OpenAICompatibleProvider) error
+	// WrapError explains a failed chat request
+	WrapError func(p *OpenAICompatibleProvider, err error) error
+}
+
+// OpenAICompatibleProvider implements a chat completions API described by a ProviderConfig
+type OpenAICompatibleProvider struct {
+	config     ProviderConfig
+	httpClient *http.Client
+	apiToken   string
+	baseURL    string
+	debug      bool
+	model      string
+
+	responseFormat map[string]interface{} // set while a structured-output request is in flight
+	requestParams  map[string]interface{} // per-model profile merged into each request
+	models         []types.ModelInfo      // model list, cached after the first ListModels
+	modelsListed   bool
+}
+
+// NewOpenAICompatibleProvider creates a provider from its config, reading the
+// API key and base URL override from the environment
+func NewOpenAICompatibleProvider(config ProviderConfig) (*OpenAICompatibleProvider, error) {
+	token := os.Getenv(config.APIKeyEnv)
+	if token == "" && config.APIKeyAltEnv != "" {
+		token = os.Getenv(config.APIKeyAltEnv)
+	}
+	if token == "" && !config.APIKeyOptional {
+		return nil, fmt.Errorf("%s environment variable not set", config.APIKeyEnv)
+	}
+	baseURL := config.BaseURL
+	if config.BaseURLEnv != "" {
+		if override := os.Getenv(config.BaseURLEnv); override != "" {
+			baseURL = override
+		}
+	}
+
+	return &OpenAICompatibleProvider{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 300 * time.Second,
+		},
+		apiToken: token,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		debug:    false,
+		model:    config.DefaultModel,
+	}, nil
+}
+
+// NewOpenAICompatibleProviderWithModel creates a provider with a specific
+// model, or the config's default model when model is empty
+func NewOpenAICompatibleProviderWithModel(config ProviderConfig, model string) (*OpenAICompatibleProvider, error) {
+	provider, err := NewOpenAICompatibleProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	if model != "" {
+		provider.model = model
+	}
+	return provider, nil
+}
+
+// SetResponseFormat constrains subsequent responses to a JSON schema (nil clears it)
+func (p *OpenAICompatibleProvider) SetResponseFormat(format map[string]interface{}) {
+	p.responseFormat = format
+}
+
+// SetRequestParameters sets the parameter profile merged into each request
+func (p *OpenAICompatibleProvider) SetRequestParameters(params map[string]interface{}) {
+	p.requestParams = params
+}
+
+// SendChatRequest sends a chat completion request
+func (p *OpenAICompatibleProvider) SendChatRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newChatRequest(messages, tools, reasoning, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sendOpenAIStyleRequest(p.httpClient, httpReq.WithContext(ctx), reqBody, p.config.Label, p.debug)
+	if err != nil {
+		return nil, p.wrapError(err)
+	}
+	p.applyUsage(resp)
+	return resp, nil
+}
+
+// SendChatRequestStream sends a chat completion request and streams the response to onToken
+func (p *OpenAICompatibleProvider) SendChatRequestStream(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	httpReq, reqBody, err := p.newChatRequest(messages, tools, reasoning, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sendOpenAIStyleStreamRequest(p.httpClient, httpReq.WithContext(ctx), reqBody, p.config.Label, p.debug, onToken)
+	if err != nil {
+		return nil, p.wrapError(err)
+	}
+	p.applyUsage(resp)
+	return resp, nil
+}
+
+// wrapError lets the config explain a failed chat request
+func (p *OpenAICompatibleProvider) wrapError(err error) error {
+	if p.config.WrapError != nil {
+		return p.config.WrapError(p, err)
+	}
+	return err
+}
+
+// newChatRequest builds a chat completion request, streamed if stream is set
+func (p *OpenAICompatibleProvider) newChatRequest(messages []types.Message, tools []types.Tool, reasoning string, stream bool) (*http.Request, []byte, error) {
+	requestBody := map[string]interface{}{
+		"model":       p.model,
+		"messages":    p.convertMessages(messages),
+		"temperature": 0.7,
+	}
+	if p.config.FitMaxTokens {
+		contextLimit, _ := p.GetModelContextLimit()
+		requestBody["max_tokens"] = fitMaxTokens(contextLimit, p.config.MaxOutputTokens, messages, tools)
+	}
+	if len(tools) > 0 {
+		requestBody["tools"] = tools
+		requestBody["tool_choice"] = "auto"
+	}
+	if p.responseFormat != nil {
+		requestBody["response_format"] = p.responseFormat
+	}
+	if p.config.Prepare != nil {
+		p.config.Prepare(p.model, reasoning, requestBody)
+	}
+
+	// Per-model parameter profile from the config
+	types.MergeRequestParameters(requestBody, p.requestParams)
+
+	if p.config.Finish != nil {
+		p.config.Finish(p.model, requestBody)
+	}
+
+	if name := p.config.MaxTokensParam; name != "" {
+		if maxTokens, ok := requestBody["max_tokens"]; ok {
+			requestBody[name] = maxTokens
+			delete(requestBody, "max_tokens")
+		}
+	}
+	if stream {
+		EnableStreaming(requestBody, p.config.StreamUsage)
+	}
+
+	reqBody, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := p.newRequest("POST", "/chat/completions", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if p.debug {
+		fmt.Printf("🔍 Using %s model: %s\n", p.config.Label, p.model)
+		fmt.Printf("🔍 %s Request Body: %s\n", p.config.Label, string(reqBody))
+	}
+
+	return httpReq, reqBody, nil
+}
+
+// newRequest creates an authenticated request for a path under the current
+// model's base URL
+func (p *OpenAICompatibleProvider) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	return p.newRequestURL(method, p.modelBaseURL(p.model)+path, body)
+}
+
+// modelBaseURL returns the API root requests for a model go to
+func (p *OpenAICompatibleProvider) modelBaseURL(model string) string {
+	if p.config.BaseURLFor != nil {
+		return strings.TrimRight(p.config.BaseURLFor(model), "/")
+	}
+	return p.baseURL
+}
+
+// newRequestURL creates a request with the provider's authentication and headers
+func (p *OpenAICompatibleProvider) newRequestURL(method, url string, body io.Reader) (*http.Request, error) {
+	httpReq, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if p.apiToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+	}
+	for name, value := range p.config.Headers {
+		httpReq.Header.Set(name, value)
+	}
+	return httpReq, nil
+}
+
+// convertMessages converts chat messages to the request format. Images are
+// sent as image_url parts when the provider accepts them and dropped otherwise.
+func (p *OpenAICompatibleProvider) convertMessages(messages []types.Message) []map[string]interface{} {
+	converted := make([]map[string]interface{}, len(messages))
+	for i, msg := range messages {
+		converted[i] = map[string]interface{}{
+			"role":    msg.Role,
+			"content": msg.Content,
+		}
+		if !p.config.Images || len(msg.Images) == 0 {
+			continue
+		}
+
+		parts := []map[string]interface{}{{"type": "text", "text": msg.Content}}
+		for _, img := range msg.Images {
+			url := img.URL
+			if url == "" && img.Base64 != "" {
+				mimeType := img.Type
+				if mimeType == "" {
+					mimeType = "image/jpeg"
+				}
+				url = fmt.Sprintf("data:%s;base64,%s", mimeType, img.Base64)
+			}
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": url},
+			})
+		}
+		converted[i]["content"] = parts
+	}
+	return converted
+}
+
+// applyUsage reports cache hits as cached tokens and estimates the cost from
+// the config's prices, or records the cost the server charged
+func (p *OpenAICompatibleProvider) applyUsage(resp *types.ChatResponse) {
+	usage := &resp.Usage
+	if usage.PromptTokensDetails.CachedTokens == 0 {
+		usage.PromptTokensDetails.CachedTokens = usage.PromptCacheHitTokens
+	}
+	if p.config.Pricing == nil {
+		if usage.EstimatedCost == 0 {
+			usage.EstimatedCost = usage.Cost
+		}
+		return
+	}
+	input, cachedInput, output := p.config.Pricing(p.model)
+	cached := usage.PromptTokensDetails.CachedTokens
+	usage.EstimatedCost = (float64(usage.PromptTokens-cached)*input + float64(cached)*cachedInput + float64(usage.CompletionTokens)*output) / 1e6
+}
+
+// CheckConnection checks if the provider's API key is set, or runs the
+// config's own check
+func (p *OpenAICompatibleProvider) CheckConnection() error {
+	if p.config.CheckConnection != nil {
+		return p.config.CheckConnection(p)
+	}
+	if p.apiToken == "" && !p.config.APIKeyOptional {
+		return fmt.Errorf("%s environment variable not set", p.config.APIKeyEnv)
+	}
+	return nil
+}
+
+// SetDebug enables or disables debug mode
+func (p *OpenAICompatibleProvider) SetDebug(debug bool) {
+	p.debug = debug
+}
+
+// SetModel sets the model to use
+func (p *OpenAICompatibleProvider) SetModel(model string) error {
+	if model == "" {
+		return fmt.Errorf("model name cannot be empty")
+	}
+	p.model = model
+	return nil
+}
+
+// GetModel returns the current model
+func (p *OpenAICompatibleProvider) GetModel() string {
+	return p.model
+}
+
+// GetProvider returns the provider name
+func (p *OpenAICompatibleProvider) GetProvider() string {
+	return p.config.Name
+}
+
+// listedModel is an entry of a model list. Servers extend OpenAI's model
+// object differently; this covers the fields any of them use for names,
+// context windows and prices.
+type listedModel struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	OwnedBy          string   `json:"owned_by"`
+	Aliases          []string `json:"aliases"`
+	Active           *bool    `json:"active"`
+	ContextLength    int      `json:"context_length"`
+	ContextWindow    int      `json:"context_window"`
+	MaxContextLength int      `json:"max_context_length"`
+	Capabilities     *struct {
+		CompletionChat *bool `json:"completion_chat"`
+	} `json:"capabilities"`
+	Pricing *struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+	// xAI lists prices in ten-thousandths of a dollar per million tokens
+	PromptTextTokenPrice     float64 `json:"prompt_text_token_price"`
+	CompletionTextTokenPrice float64 `json:"completion_text_token_price"`
+}
+
+// canChat reports whether a listed model is active and can chat
+func (m listedModel) canChat() bool {
+	if m.Active != nil && !*m.Active {
+		return false
+	}
+	return m.Capabilities == nil || m.Capabilities.CompletionChat == nil || *m.Capabilities.CompletionChat
+}
+
+// ListModels returns the provider's chat models with their context windows
+// and prices. The list is fetched once per provider.
+func (p *OpenAICompatibleProvider) ListModels() ([]types.ModelInfo, error) {
+	if p.modelsListed {
+		return p.models, nil
+	}
+	if p.config.ListModels != nil {
+		models, err := p.config.ListModels(p)
+		if err != nil {
+			return nil, err
+		}
+		p.models, p.modelsListed = models, true
+		return models, nil
+	}
+
+	path := p.config.ModelsPath
+	if path == "" {
+		path = "/models"
+	}
+	httpReq, err := p.newRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to list models, status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Data   []listedModel `json:"data"`
+		Models []listedModel `json:"models"` // xAI's language-models list
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var models []types.ModelInfo
+	for _, model := range append(result.Data, result.Models...) {
+		if !model.canChat() || (p.config.KeepModel != nil && !p.config.KeepModel(model.ID)) {
+			continue
+		}
+		info := types.ModelInfo{
+			ID:            model.ID,
+			Name:          model.Name,
+			Provider:      p.config.Name,
+			Description:   model.Description,
+			ContextLength: max(model.ContextLength, model.ContextWindow, model.MaxContextLength),
+		}
+		if info.Name == "" {
+			info.Name = model.ID
+		}
+		if info.Description == "" && p.config.Describe != nil {
+			info.Description = p.config.Describe(model.ID)
+		}
+		if info.Description == "" {
+			info.Description = strings.Join(model.Aliases, ", ")
+		}
+		if info.Description == "" {
+			info.Description = model.OwnedBy
+		}
+		if info.ContextLength == 0 && p.config.ContextLimit != nil {
+			info.ContextLength = p.config.ContextLimit(model.ID)
+		}
+
+		if model.Pricing != nil {
+			// Listed prices are per token
+			if prompt, err := strconv.ParseFloat(model.Pricing.Prompt, 64); err == nil {
+				info.InputCost = prompt * 1e6
+			}
+			if completion, err := strconv.ParseFloat(model.Pricing.Completion, 64); err == nil {
+				info.OutputCost = completion * 1e6
+			}
+		} else if model.PromptTextTokenPrice > 0 || model.CompletionTextTokenPrice > 0 {
+			info.InputCost = model.PromptTextTokenPrice / 10000
+			info.OutputCost = model.CompletionTextTokenPrice / 10000
+		} else if p.config.Pricing != nil {
+			info.InputCost, _, info.OutputCost = p.config.Pricing(model.ID)
+		}
+		info.Cost = (info.InputCost + info.OutputCost) / 2
+		models = append(models, info)
+	}
+
+	p.models = models
+	p.modelsListed = true
+	return models, nil
+}
+
+// GetModelContextLimit returns the context limit for the current model, from
+// the model list when it has been fetched
+func (p *OpenAICompatibleProvider) GetModelContextLimit() (int, error) {
+	if p.config.ListedContext && !p.modelsListed {
+		p.ListModels() // without the list, the config's limit applies
+	}
+	if p.modelsListed {
+		find := p.config.FindModel
+		if find == nil {
+			find = findModel
+		}
+		if model, ok := find(p.models, p.model); ok && model.ContextLength > 0 {
+			return model.ContextLength, nil
+		}
+	}
+	if p.config.ContextLimit != nil {
+		return p.config.ContextLimit(p.model), nil
+	}
+	return 128000, nil
+}
+
+// findModel looks a model up in a model list by ID
+func findModel(models []types.ModelInfo, model string) (types.ModelInfo, bool) {
+	for _, m := range models {
+		if m.ID == model {
+			return m, true
+		}
+	}
+	return types.ModelInfo{}, false
+}
+
+// SupportsVision reports whether vision requests can be sent
+func (p *OpenAICompatibleProvider) SupportsVision() bool {
+	return p.config.Images && (p.config.VisionModel != "" || p.visionCapable())
+}
+
+// visionCapable reports whether the current model takes images itself
+func (p *OpenAICompatibleProvider) visionCapable() bool {
+	return p.config.VisionCapable != nil && p.config.VisionCapable(p.model)
+}
+
+// GetVisionModel returns the model vision requests use
+func (p *OpenAICompatibleProvider) GetVisionModel() string {
+	return p.config.VisionModel
+}
+
+// SendVisionRequest sends a chat request with images, on the vision model
+// when the provider has one
+func (p *OpenAICompatibleProvider) SendVisionRequest(ctx context.Context, messages []types.Message, tools []types.Tool, reasoning string) (*types.ChatResponse, error) {
+	if !p.SupportsVision() || p.visionCapable() {
+		return p.SendChatRequest(ctx, messages, tools, reasoning)
+	}
+
+	originalModel := p.model
+	p.model = p.config.VisionModel
+	defer func() { p.model = originalModel }()
+	return p.SendChatRequest(ctx, messages, tools, reasoning)
+}
+
+// fitMaxTokens sizes max_tokens to the room a request leaves in the context
+// window, estimating 4 characters per token and 200 tokens per tool, up to
+// maxOutputTokens (16000 when 0)
+func fitMaxTokens(contextLimit, maxOutputTokens int, messages []types.Message, tools []types.Tool) int {
+	if contextLimit == 0 {
+		contextLimit = 32000 // Conservative default
+	}
+	if maxOutputTokens == 0 {
+		maxOutputTokens = 16000
+	}
+
+	inputTokens := len(tools) * 200
+	for _, msg := range messages {
+		inputTokens += len(msg.Content) / 4
+	}
+
+	// Leave a 1000 token safety buffer, and keep the cap at least 1K
+	maxOutput := contextLimit - inputTokens - 1000
+	if maxOutput > maxOutputTokens {
+		maxOutput = maxOutputTokens
+	} else if maxOutput < 1000 {
+		maxOutput = 1000
+	}
+	return maxOutput
+}
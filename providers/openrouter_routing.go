@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// openRouterVariants are the model ID suffixes OpenRouter accepts on any model.
+// Only :free variants appear in the model list; the rest route the base model.
+var openRouterVariants = []string{":free", ":nitro", ":floor", ":online", ":extended", ":thinking", ":beta"}
+
+// SplitOpenRouterVariant separates a model ID into its base model and variant
+// suffix, e.g. "deepseek/deepseek-chat-v3.1:nitro" -> ("deepseek/deepseek-chat-v3.1", ":nitro")
+func SplitOpenRouterVariant(model string) (string, string) {
+	for _, variant := range openRouterVariants {
+		if strings.HasSuffix(model, variant) {
+			return strings.TrimSuffix(model, variant), variant
+		}
+	}
+	return model, ""
+}
+
+// FindOpenRouterModel looks a model up in the model list, falling back to the
+// base model for variants the list does not include. :free variants cost nothing.
+func FindOpenRouterModel(models []types.ModelInfo, model string) (types.ModelInfo, bool) {
+	i, free := OpenRouterModelIndex(models, func(m types.ModelInfo) string { return m.ID }, model)
+	if i < 0 {
+		return types.ModelInfo{}, false
+	}
+	found := models[i]
+	found.ID = model
+	if free {
+		found.InputCost, found.OutputCost, found.Cost = 0, 0, 0
+	}
+	return found, true
+}
+
+// OpenRouterModelIndex finds a model in a model list of any type, given each
+// entry's ID: the model itself, or its base model for a variant the list does
+// not include. It returns -1 when neither is listed, and whether the model is
+// a :free variant, which costs nothing.
+func OpenRouterModelIndex[M any](models []M, id func(M) string, model string) (int, bool) {
+	base, variant := SplitOpenRouterVariant(model)
+	for _, want := range []string{model, base} {
+		for i, m := range models {
+			if id(m) == want {
+				return i, variant == ":free"
+			}
+		}
+	}
+	return -1, false
+}
+
+// ValidateOpenRouterRouting checks OpenRouter provider routing preferences
+// (the request's "provider" object) so typos fail loudly instead of being ignored
+func ValidateOpenRouterRouting(routing map[string]interface{}) error {
+	for key, value := range routing {
+		switch key {
+		case "order", "only", "ignore", "quantizations":
+			if !isStringList(value) {
+				return fmt.Errorf("%s must be a list of provider names", key)
+			}
+		case "allow_fallbacks", "require_parameters":
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("%s must be true or false", key)
+			}
+		case "data_collection":
+			if value != "allow" && value != "deny" {
+				return fmt.Errorf("data_collection must be \"allow\" or \"deny\"")
+			}
+		case "sort":
+			if value != "price" && value != "throughput" && value != "latency" {
+				return fmt.Errorf("sort must be \"price\", \"throughput\" or \"latency\"")
+			}
+		case "max_price":
+			if _, ok := value.(map[string]interface{}); !ok {
+				return fmt.Errorf("max_price must be an object")
+			}
+		default:
+			return fmt.Errorf("unknown routing preference %q", key)
+		}
+	}
+	return nil
+}
+
+// isStringList reports whether a decoded JSON value is a list of strings
+func isStringList(value interface{}) bool {
+	switch list := value.(type) {
+	case []string:
+		return true
+	case []interface{}:
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
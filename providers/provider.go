@@ -1,29 +1,39 @@
 package providers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/alantheprice/coder/types"
 )
 
 // Provider represents an OpenAI-compatible API provider
 type Provider interface {
 	// GetName returns the provider name
 	GetName() string
-	
+
 	// GetEndpoint returns the API endpoint URL
 	GetEndpoint() string
-	
+
 	// GetAPIKey returns the API key
 	GetAPIKey() string
-	
+
 	// GetDefaultModel returns the default model for this provider
 	GetDefaultModel() string
-	
+
 	// IsAvailable checks if the provider is available (API key set)
 	IsAvailable() bool
-	
-	// CreateClient creates a new API client for this provider  
+
+	// CreateClient creates a new API client for this provider
 	// This method is deprecated in favor of the unified provider pattern
 	CreateClient(model string) error
 }
@@ -78,4 +88,102 @@ func (p *BaseProvider) IsAvailable() bool {
 // GetHTTPClient returns the HTTP client
 func (p *BaseProvider) GetHTTPClient() *http.Client {
 	return p.HTTPClient
-}
\ No newline at end of file
+}
+
+// sendOpenAIStyleRequest sends an OpenAI-compatible chat request, backing off
+// on rate limits, and decodes the response
+func sendOpenAIStyleRequest(client *http.Client, httpReq *http.Request, reqBody []byte, label string, debug bool) (*types.ChatResponse, error) {
+	maxRetries := 3
+	baseDelay := 1 * time.Second
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		httpReq.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		respBody, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", readErr)
+		}
+
+		if debug {
+			fmt.Printf("🔍 %s Response Status (attempt %d): %s\n", label, attempt+1, resp.Status)
+			fmt.Printf("🔍 %s Response Body: %s\n", label, string(respBody))
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			var chatResp types.ChatResponse
+			if err := json.Unmarshal(respBody, &chatResp); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+			}
+			return &chatResp, nil
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if message := dailyLimitMessage(respBody); message != "" {
+				return nil, fmt.Errorf("daily limit exceeded: %s", message)
+			}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			waitTime := rateLimitDelay(resp, attempt, baseDelay)
+			fmt.Printf("⏳ Rate limit hit (attempt %d/%d), waiting %v before retry...\n",
+				attempt+1, maxRetries+1, waitTime)
+			if err := sleepContext(httpReq.Context(), waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("%s API request failed with status %d: %s", label, resp.StatusCode, string(respBody))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded")
+}
+
+// sleepContext waits for d, or returns the context's error if it is
+// cancelled first, so a cancelled request doesn't sit out a backoff
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitDelay returns how long to wait before retrying a rate-limited
+// request: until the time Retry-After or X-RateLimit-Reset (epoch
+// milliseconds) names, else exponential backoff, capped at a minute
+func rateLimitDelay(resp *http.Response, attempt int, baseDelay time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 && seconds <= 60 {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// A small buffer covers clock skew
+		waitTime := time.Until(time.UnixMilli(reset)) + 2*time.Second
+		if waitTime > 0 {
+			return min(waitTime, 60*time.Second)
+		}
+	}
+	return min(baseDelay*time.Duration(math.Pow(2, float64(attempt))), 60*time.Second)
+}
+
+// dailyLimitMessage returns the error message of a rate-limit response that
+// reports a daily limit, which retrying won't get past
+func dailyLimitMessage(body []byte) string {
+	var errorResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errorResp) != nil || !strings.Contains(strings.ToLower(errorResp.Error.Message), "daily limit") {
+		return ""
+	}
+	return errorResp.Error.Message
+}
@@ -0,0 +1,224 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/types"
+)
+
+// maxStreamToolCalls bounds the tool call index a stream may use
+const maxStreamToolCalls = 64
+
+// streamChunk is one server-sent event of an OpenAI-compatible stream
+type streamChunk struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role             string `json:"role"`
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"` // OpenRouter's name for reasoning_content
+			ToolCalls        []struct {
+				Index    *int   `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *types.Usage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// EnableStreaming asks an OpenAI-compatible request body for a stream;
+// includeUsage requests the final usage chunk from servers that only send it on request
+func EnableStreaming(body map[string]interface{}, includeUsage bool) {
+	body["stream"] = true
+	if includeUsage {
+		body["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+}
+
+// ReadChatStream assembles a chat response from an OpenAI-compatible
+// server-sent event stream, passing content and reasoning to onToken as they
+// arrive. Tool calls are assembled from their fragments.
+func ReadChatStream(body io.Reader, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	var resp types.ChatResponse
+	var choice types.Choice
+	var content, reasoning strings.Builder
+	choiceSeen := false
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		// Skip comments (OpenRouter sends ": OPENROUTER PROCESSING" keep-alives), event and id lines
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("stream failed: %s", chunk.Error.Message)
+		}
+		if chunk.ID != "" {
+			resp.ID, resp.Object, resp.Created = chunk.ID, chunk.Object, chunk.Created
+		}
+		if chunk.Model != "" {
+			resp.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			resp.Usage = *chunk.Usage
+		}
+
+		for _, c := range chunk.Choices {
+			if c.Index != 0 {
+				continue // only the first choice is used
+			}
+			choiceSeen = true
+			delta := c.Delta
+			if delta.Role != "" {
+				choice.Message.Role = delta.Role
+			}
+			reasoningDelta := delta.ReasoningContent
+			if reasoningDelta == "" {
+				reasoningDelta = delta.Reasoning
+			}
+			if delta.Content != "" || reasoningDelta != "" {
+				content.WriteString(delta.Content)
+				reasoning.WriteString(reasoningDelta)
+				if onToken != nil {
+					onToken(delta.Content, reasoningDelta)
+				}
+			}
+
+			for _, fragment := range delta.ToolCalls {
+				calls := choice.Message.ToolCalls
+				index := len(calls) - 1
+				switch {
+				case fragment.Index != nil:
+					index = *fragment.Index
+				case fragment.ID != "" && (index < 0 || calls[index].ID != fragment.ID):
+					// Without an index, a new ID starts the next call
+					index = len(calls)
+				case index < 0:
+					index = 0
+				}
+				if index < 0 || index >= maxStreamToolCalls {
+					return nil, fmt.Errorf("stream has an invalid tool call index %d", index)
+				}
+				for len(choice.Message.ToolCalls) <= index {
+					choice.Message.ToolCalls = append(choice.Message.ToolCalls, types.ToolCall{Type: "function"})
+				}
+				call := &choice.Message.ToolCalls[index]
+				if fragment.ID != "" {
+					call.ID = fragment.ID
+				}
+				if fragment.Type != "" {
+					call.Type = fragment.Type
+				}
+				// The name comes whole; some servers repeat it in every fragment
+				if fragment.Function.Name != "" && call.Function.Name == "" {
+					call.Function.Name = fragment.Function.Name
+				}
+				call.Function.Arguments += fragment.Function.Arguments
+			}
+
+			if c.FinishReason != "" {
+				choice.FinishReason = c.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if choiceSeen {
+		if choice.Message.Role == "" {
+			choice.Message.Role = "assistant"
+		}
+		choice.Message.Content = content.String()
+		choice.Message.ReasoningContent = reasoning.String()
+		// Drop slots a sparse index left empty
+		calls := choice.Message.ToolCalls[:0]
+		for _, call := range choice.Message.ToolCalls {
+			if call.Function.Name != "" {
+				calls = append(calls, call)
+			}
+		}
+		choice.Message.ToolCalls = calls
+		resp.Choices = []types.Choice{choice}
+	}
+	return &resp, nil
+}
+
+// sendOpenAIStyleStreamRequest sends a streaming chat request, backing off on
+// rate limits before the stream starts, and assembles the streamed response
+func sendOpenAIStyleStreamRequest(client *http.Client, httpReq *http.Request, reqBody []byte, label string, debug bool, onToken types.StreamCallback) (*types.ChatResponse, error) {
+	maxRetries := 3
+	baseDelay := 1 * time.Second
+
+	httpReq.Header.Set("Accept", "text/event-stream")
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		httpReq.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		if debug {
+			fmt.Printf("🔍 %s Stream Status (attempt %d): %s\n", label, attempt+1, resp.Status)
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			chatResp, err := ReadChatStream(resp.Body, onToken)
+			resp.Body.Close()
+			return chatResp, err
+		}
+
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if message := dailyLimitMessage(respBody); message != "" {
+				return nil, fmt.Errorf("daily limit exceeded: %s", message)
+			}
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRetries {
+			waitTime := rateLimitDelay(resp, attempt, baseDelay)
+			fmt.Printf("⏳ Rate limit hit (attempt %d/%d), waiting %v before retry...\n",
+				attempt+1, maxRetries+1, waitTime)
+			if err := sleepContext(httpReq.Context(), waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		return nil, fmt.Errorf("%s API request failed with status %d: %s", label, resp.StatusCode, string(respBody))
+	}
+
+	return nil, fmt.Errorf("max retries exceeded")
+}
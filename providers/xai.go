@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"strings"
+)
+
+// xaiPricing lists USD per million input and output tokens, used for cost
+// estimates and when the language-models list reports no price
+var xaiPricing = map[string][2]float64{
+	"grok-4":           {3.00, 15.00},
+	"grok-code-fast-1": {0.20, 1.50},
+	"grok-3":           {3.00, 15.00},
+	"grok-3-mini":      {0.30, 0.50},
+	"grok-2-vision":    {2.00, 10.00},
+}
+
+// xaiUnsupportedReasoningParams are rejected by Grok's reasoning models
+var xaiUnsupportedReasoningParams = []string{"presence_penalty", "frequency_penalty", "stop"}
+
+// xaiConfig describes the xAI Grok API. Its language-models list has prices
+// but no context windows. Grok's reasoning models count reasoning against
+// max_completion_tokens, reject sampling, penalty and stop parameters, and
+// only grok-3-mini accepts reasoning_effort.
+var xaiConfig = ProviderConfig{
+	Name:         "xai",
+	Label:        "xAI",
+	BaseURL:      "https://api.x.ai/v1",
+	BaseURLEnv:   "XAI_BASE_URL",
+	APIKeyEnv:    "XAI_API_KEY",
+	DefaultModel: "grok-code-fast-1",
+	ModelsPath:   "/language-models",
+	StreamUsage:  true,
+	Prepare: func(model, reasoning string, body map[string]interface{}) {
+		if !isXAIReasoningModel(model) {
+			return
+		}
+		delete(body, "temperature")
+		if strings.HasPrefix(model, "grok-3-mini") && reasoning == "high" {
+			body["reasoning_effort"] = "high"
+		}
+	},
+	Finish: func(model string, body map[string]interface{}) {
+		if !isXAIReasoningModel(model) {
+			return
+		}
+		for _, key := range xaiUnsupportedReasoningParams {
+			delete(body, key)
+		}
+		if !strings.HasPrefix(model, "grok-3-mini") {
+			delete(body, "reasoning_effort")
+		}
+		if maxTokens, ok := body["max_tokens"]; ok {
+			body["max_completion_tokens"] = maxTokens
+			delete(body, "max_tokens")
+		}
+	},
+	Pricing: func(model string) (float64, float64, float64) {
+		input, output := XAIPricing(model)
+		return input, input, output
+	},
+	ContextLimit: xaiContextLimit,
+}
+
+// NewXAIProvider creates a new xAI provider instance
+func NewXAIProvider() (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProvider(xaiConfig)
+}
+
+// NewXAIProviderWithModel creates an xAI provider with a specific model, or
+// the default model when model is empty
+func NewXAIProviderWithModel(model string) (*OpenAICompatibleProvider, error) {
+	return NewOpenAICompatibleProviderWithModel(xaiConfig, model)
+}
+
+// isXAIReasoningModel reports whether a Grok model always reasons
+func isXAIReasoningModel(model string) bool {
+	return strings.HasPrefix(model, "grok-4") || strings.HasPrefix(model, "grok-3-mini") || strings.HasPrefix(model, "grok-code")
+}
+
+// XAIPricing returns USD per million input and output tokens for a model from
+// the built-in table, matching the longest model family prefix (zero if unknown)
+func XAIPricing(model string) (float64, float64) {
+	best := ""
+	for family := range xaiPricing {
+		if strings.HasPrefix(model, family) && len(family) > len(best) {
+			best = family
+		}
+	}
+	if best == "" {
+		return 0, 0
+	}
+	return xaiPricing[best][0], xaiPricing[best][1]
+}
+
+// xaiContextLimit returns the context window for a Grok model
+func xaiContextLimit(model string) int {
+	switch {
+	case strings.HasPrefix(model, "grok-4"), strings.HasPrefix(model, "grok-code"):
+		return 256000
+	case strings.HasPrefix(model, "grok-3"):
+		return 131072
+	default:
+		return 32768
+	}
+}
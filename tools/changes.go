@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind says what a file change did to the file
+type ChangeKind string
+
+const (
+	ChangeCreated  ChangeKind = "created"
+	ChangeModified ChangeKind = "modified"
+	ChangeDeleted  ChangeKind = "deleted"
+)
+
+const (
+	// maxChangeDiffCells bounds the line diff; larger rewrites are recorded
+	// as a single hunk spanning everything between the unchanged ends
+	maxChangeDiffCells = 4_000_000
+	// maxChangeHunkLines bounds the diff lines kept per change record
+	maxChangeHunkLines = 400
+)
+
+// ChangeHunk is one changed region of a file. Starts are 1-based line
+// numbers; Lines holds the removed ("-") and added ("+") lines.
+type ChangeHunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines,omitempty"`
+}
+
+// FileChange is the structured record of one change a tool made to a file
+type FileChange struct {
+	Path         string       `json:"path"`
+	Kind         ChangeKind   `json:"kind"`
+	BytesBefore  int          `json:"bytes_before"`
+	BytesAfter   int          `json:"bytes_after"`
+	LinesAdded   int          `json:"lines_added"`
+	LinesRemoved int          `json:"lines_removed"`
+	Hunks        []ChangeHunk `json:"hunks,omitempty"`
+	Truncated    bool         `json:"truncated,omitempty"` // Hunk lines were cut at maxChangeHunkLines
+}
+
+// Summary describes the change in one line, e.g. "modified a.go (+3/-1 lines, 2 hunks)"
+func (c FileChange) Summary() string {
+	hunks := "1 hunk"
+	if len(c.Hunks) != 1 {
+		hunks = fmt.Sprintf("%d hunks", len(c.Hunks))
+	}
+	return fmt.Sprintf("%s %s (+%d/-%d lines, %s)", c.Kind, c.Path, c.LinesAdded, c.LinesRemoved, hunks)
+}
+
+// Diff renders the change's hunks as a unified diff without context lines
+func (c FileChange) Diff() string {
+	var diff strings.Builder
+	oldName, newName := "a/"+c.Path, "b/"+c.Path
+	if c.Kind == ChangeCreated {
+		oldName = "/dev/null"
+	} else if c.Kind == ChangeDeleted {
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range c.Hunks {
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+		for _, line := range hunk.Lines {
+			diff.WriteString(line + "\n")
+		}
+	}
+	if c.Truncated {
+		diff.WriteString("... (diff truncated)\n")
+	}
+	return diff.String()
+}
+
+// DescribeChange builds the change record for a file going from before to
+// after. existed says whether the file was there before the change; a nil
+// after means it was deleted.
+func DescribeChange(path string, before, after []byte, existed bool) FileChange {
+	change := FileChange{Path: path, Kind: ChangeModified, BytesBefore: len(before), BytesAfter: len(after)}
+	switch {
+	case !existed:
+		change.Kind = ChangeCreated
+	case after == nil:
+		change.Kind = ChangeDeleted
+	}
+
+	change.Hunks = diffHunks(splitChangeLines(string(before)), splitChangeLines(string(after)))
+	kept := 0
+	for i := range change.Hunks {
+		hunk := &change.Hunks[i]
+		change.LinesRemoved += hunk.OldLines
+		change.LinesAdded += hunk.NewLines
+		if kept+len(hunk.Lines) > maxChangeHunkLines {
+			hunk.Lines = hunk.Lines[:max(0, maxChangeHunkLines-kept)]
+			change.Truncated = true
+		}
+		kept += len(hunk.Lines)
+	}
+	return change
+}
+
+// splitChangeLines splits content into lines without their newlines
+func splitChangeLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffHunks finds the changed regions between two versions of a file with a
+// longest-common-subsequence line diff
+func diffHunks(oldLines, newLines []string) []ChangeHunk {
+	// Unchanged ends don't need diffing
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	oldMid, newMid := oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix]
+	if len(oldMid) == 0 && len(newMid) == 0 {
+		return nil
+	}
+	if (len(oldMid)+1)*(len(newMid)+1) > maxChangeDiffCells {
+		return []ChangeHunk{newChangeHunk(oldMid, newMid, prefix, prefix)}
+	}
+
+	// lcs[i][j] is the common subsequence length of oldMid[i:] and newMid[j:]
+	lcs := make([][]int, len(oldMid)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newMid)+1)
+	}
+	for i := len(oldMid) - 1; i >= 0; i-- {
+		for j := len(newMid) - 1; j >= 0; j-- {
+			if oldMid[i] == newMid[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var hunks []ChangeHunk
+	i, j := 0, 0
+	for i < len(oldMid) || j < len(newMid) {
+		if i < len(oldMid) && j < len(newMid) && oldMid[i] == newMid[j] {
+			i, j = i+1, j+1
+			continue
+		}
+		// Collect a run of removals and additions up to the next common line
+		startI, startJ := i, j
+		for i < len(oldMid) || j < len(newMid) {
+			if i < len(oldMid) && j < len(newMid) && oldMid[i] == newMid[j] {
+				break
+			}
+			if j == len(newMid) || (i < len(oldMid) && lcs[i+1][j] >= lcs[i][j+1]) {
+				i++
+			} else {
+				j++
+			}
+		}
+		hunks = append(hunks, newChangeHunk(oldMid[startI:i], newMid[startJ:j], prefix+startI, prefix+startJ))
+	}
+	return hunks
+}
+
+// newChangeHunk builds a hunk replacing removed lines with added ones at the
+// given 0-based offsets
+func newChangeHunk(removed, added []string, oldOffset, newOffset int) ChangeHunk {
+	hunk := ChangeHunk{OldStart: oldOffset + 1, OldLines: len(removed), NewStart: newOffset + 1, NewLines: len(added)}
+	// Like diff -u, an empty side starts at the line before it
+	if len(removed) == 0 {
+		hunk.OldStart = oldOffset
+	}
+	if len(added) == 0 {
+		hunk.NewStart = newOffset
+	}
+	for _, line := range removed {
+		hunk.Lines = append(hunk.Lines, "-"+line)
+	}
+	for _, line := range added {
+		hunk.Lines = append(hunk.Lines, "+"+line)
+	}
+	return hunk
+}
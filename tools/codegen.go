@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generatedHeader matches the standard marker of generated code
+// (https://go.dev/s/generatedcode), which most generators in other languages
+// use too, and the @generated marker
+var generatedHeader = regexp.MustCompile(`Code generated .* DO NOT EDIT|@generated|DO NOT EDIT! GENERATED|(?i:auto[- ]?generated by openapi[- ]generator)`)
+
+// generatedSuffixes are the file names protoc plugins produce
+var generatedSuffixes = []string{".pb.go", ".pb.gw.go", "_pb2.py", "_pb2_grpc.py", "_pb2.pyi", "_pb.js", "_pb.d.ts", "_grpc_pb.js", ".pb.ts", ".pb.cc", ".pb.h"}
+
+// generatedHeaderLines is how many lines are searched for the marker
+const generatedHeaderLines = 15
+
+// IsGeneratedFile reports whether a file was produced by a code generator and
+// must be regenerated rather than edited: it carries a generated-code marker,
+// has a protoc output name, or is listed in an openapi-generator FILES manifest
+func IsGeneratedFile(filePath string) bool {
+	base := filepath.Base(filePath)
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+
+	if file, err := os.Open(filePath); err == nil {
+		scanner := bufio.NewScanner(file)
+		for i := 0; i < generatedHeaderLines && scanner.Scan(); i++ {
+			if generatedHeader.MatchString(scanner.Text()) {
+				file.Close()
+				return true
+			}
+		}
+		file.Close()
+	}
+	return inOpenAPIGeneratorManifest(filePath)
+}
+
+// inOpenAPIGeneratorManifest reports whether an .openapi-generator/FILES
+// manifest in one of the file's parent directories lists it
+func inOpenAPIGeneratorManifest(filePath string) bool {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return false
+	}
+	wd, _ := os.Getwd()
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		if data, err := os.ReadFile(filepath.Join(dir, ".openapi-generator", "FILES")); err == nil {
+			rel, _ := filepath.Rel(dir, abs)
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.TrimSpace(line) == filepath.ToSlash(rel) {
+					return true
+				}
+			}
+		}
+		if dir == wd || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// DetectCodegenCommand returns the command that regenerates code in root and
+// what it was chosen from. A configured command wins; otherwise a buf.gen.yaml
+// means buf generate and an openapitools.json means openapi-generator.
+func DetectCodegenCommand(root, configured string) (command, source string) {
+	if configured != "" {
+		return configured, "codegen_command preference"
+	}
+	for _, candidate := range []struct{ file, command string }{
+		{"buf.gen.yaml", "buf generate"},
+		{"buf.gen.yml", "buf generate"},
+		{"openapitools.json", "npx @openapitools/openapi-generator-cli generate"},
+	} {
+		if _, err := os.Stat(filepath.Join(root, candidate.file)); err == nil {
+			return candidate.command, candidate.file
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,206 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File content compression modes
+const (
+	CompressNone       = "off"
+	CompressWhitespace = "whitespace" // strip license headers and collapse blank lines
+	CompressOutline    = "outline"    // outline of declarations plus the regions relevant to the task
+)
+
+// outlineContext is how many lines around a relevant match are kept in outline mode
+const outlineContext = 5
+
+// maxOutlineRegionLines caps how much of a single declaration is kept in outline mode
+const maxOutlineRegionLines = 80
+
+// declarationPatterns match top-level declarations for languages without a LanguagePack
+var declarationPatterns = map[string]*regexp.Regexp{
+	".java": regexp.MustCompile(`^\s*(public|private|protected)?\s*(static\s+)?(class|interface|enum|[A-Za-z<>\[\]]+\s+[a-zA-Z_]+\s*\()`),
+}
+
+// identifierPattern extracts identifier-like words
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{3,}`)
+
+// CompressFileContent shrinks a large file for the model's context. The result
+// starts with a note explaining the transformation, since compressed content
+// cannot be used as old_string for edit_file.
+func CompressFileContent(filePath, content, mode string, focusTerms []string) string {
+	switch mode {
+	case CompressWhitespace:
+		compressed := collapseBlankLines(stripLicenseHeader(content))
+		return fmt.Sprintf("[COMPRESSED: license header and extra blank lines removed, %d of %d chars kept - read with full=true before editing]\n%s",
+			len(compressed), len(content), compressed)
+	case CompressOutline:
+		compressed := outlineWithRegions(filePath, stripLicenseHeader(content), focusTerms)
+		return fmt.Sprintf("[COMPRESSED: outline plus relevant regions, %d of %d chars kept - read with full=true for the whole file]\n%s",
+			len(compressed), len(content), compressed)
+	default:
+		return content
+	}
+}
+
+// ValidCompressionMode reports whether mode is a known compression mode
+func ValidCompressionMode(mode string) bool {
+	return mode == CompressNone || mode == CompressWhitespace || mode == CompressOutline
+}
+
+// stripLicenseHeader removes a leading comment block that mentions a copyright or license
+func stripLicenseHeader(content string) string {
+	lines := strings.Split(content, "\n")
+	end := 0
+	inBlock := false
+scan:
+	for end < len(lines) {
+		trimmed := strings.TrimSpace(lines[end])
+		switch {
+		case inBlock:
+			if strings.Contains(trimmed, "*/") {
+				inBlock = false
+			}
+		case strings.HasPrefix(trimmed, "/*"):
+			inBlock = !strings.Contains(trimmed, "*/")
+		case strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "#!"):
+		case trimmed == "" && end > 0:
+		default:
+			break scan
+		}
+		end++
+	}
+
+	header := strings.ToLower(strings.Join(lines[:end], "\n"))
+	if end == 0 || !(strings.Contains(header, "copyright") || strings.Contains(header, "license")) {
+		return content
+	}
+	return "[license header omitted]\n" + strings.Join(lines[end:], "\n")
+}
+
+// collapseBlankLines trims trailing whitespace and collapses runs of blank lines
+func collapseBlankLines(content string) string {
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// outlineWithRegions lists every declaration with its line number and includes
+// the full text of declarations (or lines) that mention one of the focus terms
+func outlineWithRegions(filePath, content string, focusTerms []string) string {
+	lines := strings.Split(content, "\n")
+	var pattern *regexp.Regexp
+	if pack := LanguagePackFor(filePath); pack != nil {
+		pattern = pack.OutlinePattern()
+	} else if known, ok := declarationPatterns[strings.ToLower(filepath.Ext(filePath))]; ok {
+		pattern = known
+	} else {
+		pattern = regexp.MustCompile(`^(func|function|def|class|type|interface|struct)\s`)
+	}
+
+	var declarations []int
+	for i, line := range lines {
+		if pattern.MatchString(line) {
+			declarations = append(declarations, i)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("OUTLINE:\n")
+	for _, i := range declarations {
+		fmt.Fprintf(&out, "L%d: %s\n", i+1, strings.TrimSpace(lines[i]))
+	}
+
+	keep := make([]bool, len(lines))
+	for _, term := range focusTerms {
+		for i, line := range lines {
+			if !strings.Contains(line, term) {
+				continue
+			}
+			start, end := i-outlineContext, i+outlineContext
+			// A declaration of the term is kept whole, up to the next declaration
+			if containsIndex(declarations, i) {
+				end = nextDeclaration(declarations, i, len(lines)) - 1
+				if end-i > maxOutlineRegionLines {
+					end = i + maxOutlineRegionLines
+				}
+			}
+			for j := max(start, 0); j <= end && j < len(lines); j++ {
+				keep[j] = true
+			}
+		}
+	}
+
+	out.WriteString("\nRELEVANT REGIONS:\n")
+	inRegion := false
+	for i, line := range lines {
+		if !keep[i] {
+			inRegion = false
+			continue
+		}
+		if !inRegion {
+			fmt.Fprintf(&out, "--- L%d ---\n", i+1)
+			inRegion = true
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+// FocusTerms returns identifiers from text that are declared or used in content,
+// which drives which regions outline mode keeps
+func FocusTerms(text, content string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range identifierPattern.FindAllString(text, -1) {
+		if seen[word] || !strings.Contains(content, word) || commonWords[strings.ToLower(word)] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// commonWords are frequent English words that make poor focus terms
+var commonWords = map[string]bool{
+	"this": true, "that": true, "with": true, "from": true, "file": true, "function": true,
+	"should": true, "make": true, "when": true, "what": true, "there": true, "into": true,
+	"have": true, "then": true, "return": true, "error": true, "string": true, "please": true,
+}
+
+// containsIndex reports whether sorted contains i
+func containsIndex(sorted []int, i int) bool {
+	for _, v := range sorted {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
+
+// nextDeclaration returns the line index of the declaration after i, or total
+func nextDeclaration(declarations []int, i, total int) int {
+	for _, d := range declarations {
+		if d > i {
+			return d
+		}
+	}
+	return total
+}
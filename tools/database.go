@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// disposableDatabaseReadyTimeout is how long a new database container may take to accept connections
+const disposableDatabaseReadyTimeout = 90 * time.Second
+
+// DisposableDatabase is a throwaway database server in a docker container,
+// removed again by Stop
+type DisposableDatabase struct {
+	Dialect     string // "postgres" or "mysql"
+	ContainerID string
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	Name        string // database name
+}
+
+// databaseImages are the container settings per dialect
+var databaseImages = map[string]struct {
+	image, port, user, name string
+	env                     []string
+	ready                   []string // command run in the container until it succeeds
+}{
+	"postgres": {
+		image: "postgres:16-alpine", port: "5432", user: "postgres", name: "postgres",
+		env:   []string{"POSTGRES_PASSWORD=coder"},
+		ready: []string{"pg_isready", "-U", "postgres", "-h", "127.0.0.1"},
+	},
+	"mysql": {
+		image: "mysql:8", port: "3306", user: "root", name: "coder",
+		env:   []string{"MYSQL_ROOT_PASSWORD=coder", "MYSQL_DATABASE=coder"},
+		ready: []string{"mysql", "-h", "127.0.0.1", "-uroot", "-pcoder", "-e", "SELECT 1"},
+	},
+}
+
+// StartDisposableDatabase starts an empty database of the given dialect in
+// docker and waits until it accepts connections
+func StartDisposableDatabase(dialect string) (*DisposableDatabase, error) {
+	settings, ok := databaseImages[dialect]
+	if !ok {
+		return nil, fmt.Errorf("no disposable database for dialect %q (supported: postgres, mysql)", dialect)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, fmt.Errorf("docker is not installed, so migrations can't be run against a disposable database")
+	}
+
+	args := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + settings.port}
+	for _, env := range settings.env {
+		args = append(args, "-e", env)
+	}
+	output, err := exec.Command("docker", append(args, settings.image)...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s container: %w\n%s", dialect, err, strings.TrimSpace(string(output)))
+	}
+	db := &DisposableDatabase{
+		Dialect:     dialect,
+		ContainerID: strings.TrimSpace(string(output)),
+		User:        settings.user,
+		Password:    "coder",
+		Name:        settings.name,
+	}
+
+	mapping, err := exec.Command("docker", "port", db.ContainerID, settings.port+"/tcp").Output()
+	if err != nil {
+		db.Stop()
+		return nil, fmt.Errorf("failed to find the %s container's port: %w", dialect, err)
+	}
+	// "127.0.0.1:49153", possibly followed by an IPv6 mapping
+	address := strings.TrimSpace(strings.Split(string(mapping), "\n")[0])
+	host, port, found := strings.Cut(address, ":")
+	if !found {
+		db.Stop()
+		return nil, fmt.Errorf("unexpected port mapping %q", address)
+	}
+	db.Host, db.Port = host, port
+
+	deadline := time.Now().Add(disposableDatabaseReadyTimeout)
+	for {
+		if exec.Command("docker", append([]string{"exec", db.ContainerID}, settings.ready...)...).Run() == nil {
+			return db, nil
+		}
+		if time.Now().After(deadline) {
+			db.Stop()
+			return nil, fmt.Errorf("%s container didn't become ready within %v", dialect, disposableDatabaseReadyTimeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// URL returns the database URL in the scheme a tool expects: "postgres",
+// "postgresql" (SQLAlchemy) or "mysql"
+func (db *DisposableDatabase) URL(scheme string) string {
+	if db.Dialect == "mysql" {
+		if scheme == "mysql+pymysql" {
+			return fmt.Sprintf("mysql+pymysql://%s:%s@%s:%s/%s", db.User, db.Password, db.Host, db.Port, db.Name)
+		}
+		return fmt.Sprintf("mysql://%s:%s@tcp(%s:%s)/%s?multiStatements=true", db.User, db.Password, db.Host, db.Port, db.Name)
+	}
+	return fmt.Sprintf("%s://%s:%s@%s:%s/%s?sslmode=disable", scheme, db.User, db.Password, db.Host, db.Port, db.Name)
+}
+
+// DSN returns the driver connection string Go's database/sql drivers take
+func (db *DisposableDatabase) DSN() string {
+	if db.Dialect == "mysql" {
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&multiStatements=true", db.User, db.Password, db.Host, db.Port, db.Name)
+	}
+	return db.URL("postgres")
+}
+
+// Stop removes the container and its data
+func (db *DisposableDatabase) Stop() {
+	if db.ContainerID != "" {
+		exec.Command("docker", "stop", db.ContainerID).Run()
+	}
+}
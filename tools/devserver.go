@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// devServerStartTimeout is how long to wait for the dev server to answer
+const devServerStartTimeout = 90 * time.Second
+
+// screenshotWindowSize is the browser viewport used for route screenshots
+const screenshotWindowSize = "1280,800"
+
+// browserBinaries are headless-capable browsers, in order of preference
+var browserBinaries = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+}
+
+// DevServerConfig describes how to run and reach the project's frontend dev server
+type DevServerConfig struct {
+	Command string   // e.g. "npm run dev"
+	URL     string   // e.g. "http://localhost:5173"
+	Routes  []string // routes screenshotted when none are given
+}
+
+// LoadDevServerConfig reads the dev server settings from the config preferences
+// (dev_server_command, dev_server_url and comma-separated dev_server_routes)
+func LoadDevServerConfig(cfg *config.Config) DevServerConfig {
+	devServer := DevServerConfig{URL: "http://localhost:3000", Routes: []string{"/"}}
+	if cfg == nil {
+		return devServer
+	}
+	devServer.Command = cfg.GetStringPreference("dev_server_command", "")
+	devServer.URL = strings.TrimSuffix(cfg.GetStringPreference("dev_server_url", devServer.URL), "/")
+	if routes := cfg.GetStringPreference("dev_server_routes", ""); routes != "" {
+		devServer.Routes = nil
+		for _, route := range strings.Split(routes, ",") {
+			if route = strings.TrimSpace(route); route != "" {
+				devServer.Routes = append(devServer.Routes, route)
+			}
+		}
+	}
+	return devServer
+}
+
+// devServer is the background dev server started by this session
+var devServer struct {
+	sync.Mutex
+	cmd     *exec.Cmd
+	command string
+	logPath string
+}
+
+// EnsureDevServer starts the dev server in the background unless something is
+// already answering at its URL, and waits until it responds
+func EnsureDevServer(cfg DevServerConfig) error {
+	if devServerResponds(cfg.URL) {
+		return nil
+	}
+	if cfg.Command == "" {
+		return fmt.Errorf("nothing is listening on %s and no dev_server_command is configured", cfg.URL)
+	}
+
+	devServer.Lock()
+	if devServer.cmd == nil {
+		logFile, err := os.CreateTemp("", "coder-devserver-*.log")
+		if err != nil {
+			devServer.Unlock()
+			return fmt.Errorf("failed to create dev server log: %w", err)
+		}
+		cmd := exec.Command("sh", "-c", cfg.Command)
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		// npm and friends start the server as a grandchild; stopping kills the group
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			logFile.Close()
+			devServer.Unlock()
+			return fmt.Errorf("failed to start dev server: %w", err)
+		}
+		fmt.Printf("🌐 Started dev server: %s (log: %s)\n", cfg.Command, logFile.Name())
+		devServer.cmd, devServer.command, devServer.logPath = cmd, cfg.Command, logFile.Name()
+		go func() {
+			cmd.Wait()
+			logFile.Close()
+			devServer.Lock()
+			if devServer.cmd == cmd {
+				devServer.cmd = nil
+			}
+			devServer.Unlock()
+		}()
+	}
+	logPath := devServer.logPath
+	devServer.Unlock()
+
+	deadline := time.Now().Add(devServerStartTimeout)
+	for time.Now().Before(deadline) {
+		if devServerResponds(cfg.URL) {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("dev server did not respond at %s within %s (see %s)", cfg.URL, devServerStartTimeout, logPath)
+}
+
+// StopDevServer stops the dev server started by this session, if any
+func StopDevServer() {
+	devServer.Lock()
+	defer devServer.Unlock()
+	if devServer.cmd != nil && devServer.cmd.Process != nil {
+		killProcessGroup(devServer.cmd.Process.Pid)
+		fmt.Printf("🌐 Stopped dev server: %s\n", devServer.command)
+	}
+	devServer.cmd = nil
+}
+
+// devServerResponds reports whether anything answers HTTP at url
+func devServerResponds(url string) bool {
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// findBrowser returns a headless-capable browser binary
+func findBrowser() (string, error) {
+	for _, candidate := range browserBinaries {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no Chrome or Chromium found for screenshots (tried %s)", strings.Join(browserBinaries[:5], ", "))
+}
+
+// routeFilePattern replaces characters that are awkward in file names
+var routeFilePattern = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ScreenshotRoute renders a page with headless Chrome and saves a PNG screenshot
+func ScreenshotRoute(baseURL, route, dir string) (string, error) {
+	browser, err := findBrowser()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create screenshot directory: %w", err)
+	}
+
+	name := strings.Trim(routeFilePattern.ReplaceAllString(route, "_"), "_")
+	if name == "" {
+		name = "index"
+	}
+	path := filepath.Join(dir, name+".png")
+	url := baseURL + "/" + strings.TrimPrefix(route, "/")
+
+	cmd := exec.Command(browser, "--headless", "--disable-gpu", "--hide-scrollbars",
+		"--window-size="+screenshotWindowSize, "--screenshot="+path, url)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("screenshot of %s failed: %w\n%s", url, err, output)
+	}
+	return path, nil
+}
+
+// VerifyFrontend starts the dev server if needed, screenshots each route and
+// runs the screenshots through vision analysis. The returned report lists the
+// screenshot paths so they can be compared against mockups with compare_images.
+func VerifyFrontend(cfg DevServerConfig, routes []string, focus string) (string, error) {
+	if len(routes) == 0 {
+		routes = cfg.Routes
+	}
+	if err := EnsureDevServer(cfg); err != nil {
+		return "", err
+	}
+
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, "screenshots", time.Now().Format("20060102-150405"))
+
+	prompt := ""
+	if focus != "" {
+		prompt = generatePromptForMode("frontend") + "\n\nPay particular attention to: " + focus
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "# Frontend verification (%s)\n\n", cfg.URL)
+	var usage VisionUsageInfo
+	for _, route := range routes {
+		path, err := ScreenshotRoute(cfg.URL, route, dir)
+		if err != nil {
+			fmt.Fprintf(&report, "## %s\n❌ %v\n\n", route, err)
+			continue
+		}
+		fmt.Fprintf(&report, "## %s\nScreenshot: %s\n\n", route, path)
+
+		ClearLastVisionUsage()
+		analysis, err := AnalyzeImage(path, prompt, "frontend", false)
+		if err != nil {
+			fmt.Fprintf(&report, "⚠️ Vision analysis unavailable: %v\n\n", err)
+			continue
+		}
+		if routeUsage := GetLastVisionUsage(); routeUsage != nil {
+			usage.PromptTokens += routeUsage.PromptTokens
+			usage.CompletionTokens += routeUsage.CompletionTokens
+			usage.TotalTokens += routeUsage.TotalTokens
+			usage.EstimatedCost += routeUsage.EstimatedCost
+		}
+		report.WriteString(analysis + "\n")
+	}
+
+	// Report the combined cost of all routes to the caller
+	lastVisionUsage = nil
+	if usage.TotalTokens > 0 {
+		lastVisionUsage = &usage
+	}
+	return report.String(), nil
+}
+
+// IsFrontendFile reports whether a file is likely to change what the dev server renders
+func IsFrontendFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".css", ".scss", ".sass", ".less", ".jsx", ".tsx", ".vue", ".svelte", ".astro":
+		return true
+	}
+	return false
+}
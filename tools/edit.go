@@ -8,11 +8,18 @@ import (
 )
 
 func EditFile(filePath, oldString, newString string) (string, error) {
+	result, _, err := EditFileChange(filePath, oldString, newString)
+	return result, err
+}
+
+// EditFileChange edits a file like EditFile and returns the record of what
+// the edit changed
+func EditFileChange(filePath, oldString, newString string) (string, FileChange, error) {
 	if filePath == "" {
-		return "", fmt.Errorf("empty file path provided")
+		return "", FileChange{}, fmt.Errorf("empty file path provided")
 	}
 	if oldString == "" {
-		return "", fmt.Errorf("empty old string provided")
+		return "", FileChange{}, fmt.Errorf("empty old string provided")
 	}
 
 	// Clean the path
@@ -20,26 +27,26 @@ func EditFile(filePath, oldString, newString string) (string, error) {
 
 	// Check if file exists
 	if _, err := os.Stat(cleanPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("file does not exist: %s", cleanPath)
+		return "", FileChange{}, fmt.Errorf("file does not exist: %s", cleanPath)
 	}
 
 	// Read current content
 	content, err := os.ReadFile(cleanPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file %s: %w", cleanPath, err)
+		return "", FileChange{}, fmt.Errorf("failed to read file %s: %w", cleanPath, err)
 	}
 
 	contentStr := string(content)
 
 	// Check if old string exists
 	if !strings.Contains(contentStr, oldString) {
-		return "", fmt.Errorf("old string not found in file %s", cleanPath)
+		return "", FileChange{}, fmt.Errorf("old string not found in file %s", cleanPath)
 	}
 
 	// Count occurrences to warn about multiple matches
 	count := strings.Count(contentStr, oldString)
 	if count > 1 {
-		return "", fmt.Errorf("old string appears %d times in file %s - please use a more specific string", count, cleanPath)
+		return "", FileChange{}, fmt.Errorf("old string appears %d times in file %s - please use a more specific string", count, cleanPath)
 	}
 
 	// Replace the string
@@ -48,9 +55,55 @@ func EditFile(filePath, oldString, newString string) (string, error) {
 	// Write back to file
 	err = os.WriteFile(cleanPath, []byte(newContent), 0644)
 	if err != nil {
-		return "", fmt.Errorf("failed to write file %s: %w", cleanPath, err)
+		return "", FileChange{}, fmt.Errorf("failed to write file %s: %w", cleanPath, err)
 	}
 
+	change := DescribeChange(cleanPath, content, []byte(newContent), true)
 	return fmt.Sprintf("File %s edited successfully - replaced %d characters with %d characters",
-		cleanPath, len(oldString), len(newString)), nil
+		cleanPath, len(oldString), len(newString)), change, nil
+}
+
+// Replacement is one old_string -> new_string edit of a multi-edit
+type Replacement struct {
+	OldString string
+	NewString string
+}
+
+// EditFileMultiChange applies replacements to one file in order, each to the
+// content the earlier ones produced. Every old string must appear exactly
+// once; if any replacement fails, the file is left unchanged.
+func EditFileMultiChange(filePath string, edits []Replacement) (string, FileChange, error) {
+	if filePath == "" {
+		return "", FileChange{}, fmt.Errorf("empty file path provided")
+	}
+	if len(edits) == 0 {
+		return "", FileChange{}, fmt.Errorf("no edits provided")
+	}
+	cleanPath := filepath.Clean(filePath)
+	content, err := os.ReadFile(cleanPath)
+	if err != nil {
+		return "", FileChange{}, fmt.Errorf("failed to read file %s: %w", cleanPath, err)
+	}
+
+	newContent := string(content)
+	for i, edit := range edits {
+		switch count := strings.Count(newContent, edit.OldString); {
+		case edit.OldString == "":
+			return "", FileChange{}, fmt.Errorf("edit %d: empty old string provided", i+1)
+		case edit.OldString == edit.NewString:
+			return "", FileChange{}, fmt.Errorf("edit %d: old_string and new_string are identical", i+1)
+		case count == 0:
+			return "", FileChange{}, fmt.Errorf("edit %d: old string not found in file %s (after the edits before it) - no edits were applied", i+1, cleanPath)
+		case count > 1:
+			return "", FileChange{}, fmt.Errorf("edit %d: old string appears %d times in file %s - please use a more specific string; no edits were applied", i+1, count, cleanPath)
+		}
+		newContent = strings.Replace(newContent, edit.OldString, edit.NewString, 1)
+	}
+
+	if err := os.WriteFile(cleanPath, []byte(newContent), 0644); err != nil {
+		return "", FileChange{}, fmt.Errorf("failed to write file %s: %w", cleanPath, err)
+	}
+	change := DescribeChange(cleanPath, content, []byte(newContent), true)
+	return fmt.Sprintf("File %s edited successfully - applied %d edits (+%d/-%d lines)",
+		cleanPath, len(edits), change.LinesAdded, change.LinesRemoved), change, nil
 }
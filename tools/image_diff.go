@@ -0,0 +1,336 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register decoders for image.Decode
+	_ "image/jpeg"
+	"image/png"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alantheprice/coder/api"
+)
+
+// DefaultDiffThreshold is the per-pixel color distance (0-1) above which a pixel counts as changed
+const DefaultDiffThreshold = 0.1
+
+// diffCellSize is the grid used to report changed regions
+const diffCellSize = 32
+
+// maxReportedRegions caps how many changed regions are listed
+const maxReportedRegions = 10
+
+// ImageDiffResult describes how a screenshot differs from a target image
+type ImageDiffResult struct {
+	ActualPath        string
+	ExpectedPath      string
+	OverlayPath       string
+	Width             int
+	Height            int
+	SizeMismatch      bool // the actual image was scaled to the expected size
+	ChangedPixels     int
+	ChangedPercent    float64
+	PerceptualSimilar float64 // 0-1, from a difference hash of both images
+	Regions           []image.Rectangle
+	Commentary        *ImageComparison
+}
+
+// ImageComparison is the vision model's account of the differences
+type ImageComparison struct {
+	Matches     bool     `json:"matches"`
+	Differences []string `json:"differences"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// imageComparisonSchema is the structured output requested for vision commentary
+var imageComparisonSchema = api.ResponseSchema{
+	Name: "image_comparison",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"matches":     map[string]interface{}{"type": "boolean"},
+			"differences": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"suggestions": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+		"required":             []string{"matches", "differences", "suggestions"},
+		"additionalProperties": false,
+	},
+}
+
+// CompareImages diffs a fresh screenshot against a target image pixel by pixel
+// and writes an overlay that highlights changed pixels in red over a faded copy
+// of the screenshot. An empty overlayPath writes <actual>_diff.png.
+func CompareImages(actualPath, expectedPath, overlayPath string, threshold float64) (*ImageDiffResult, error) {
+	actual, err := decodeImageFile(actualPath)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := decodeImageFile(expectedPath)
+	if err != nil {
+		return nil, err
+	}
+	if threshold <= 0 {
+		threshold = DefaultDiffThreshold
+	}
+	if overlayPath == "" {
+		overlayPath = strings.TrimSuffix(actualPath, filepath.Ext(actualPath)) + "_diff.png"
+	}
+
+	bounds := expected.Bounds()
+	result := &ImageDiffResult{
+		ActualPath:   actualPath,
+		ExpectedPath: expectedPath,
+		OverlayPath:  overlayPath,
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+		SizeMismatch: actual.Bounds().Size() != bounds.Size(),
+	}
+	if result.SizeMismatch {
+		actual = resizeNearest(actual, bounds.Dx(), bounds.Dy())
+	}
+
+	overlay := image.NewRGBA(image.Rect(0, 0, result.Width, result.Height))
+	changedCells := make(map[image.Point]bool)
+	for y := 0; y < result.Height; y++ {
+		for x := 0; x < result.Width; x++ {
+			a := actual.At(actual.Bounds().Min.X+x, actual.Bounds().Min.Y+y)
+			e := expected.At(bounds.Min.X+x, bounds.Min.Y+y)
+			if colorDistance(a, e) > threshold {
+				result.ChangedPixels++
+				changedCells[image.Pt(x/diffCellSize, y/diffCellSize)] = true
+				overlay.Set(x, y, color.RGBA{R: 255, A: 255})
+				continue
+			}
+			gray := uint8(luminance(a)*255*0.3 + 255*0.7) // faded so the red stands out
+			overlay.Set(x, y, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+		}
+	}
+
+	if total := result.Width * result.Height; total > 0 {
+		result.ChangedPercent = float64(result.ChangedPixels) * 100 / float64(total)
+	}
+	result.PerceptualSimilar = 1 - float64(bits.OnesCount64(differenceHash(actual)^differenceHash(expected)))/64
+	result.Regions = mergeCells(changedCells, result.Width, result.Height)
+
+	if err := writePNG(overlayPath, overlay); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CommentOnDifferences asks the vision model to explain how the screenshot
+// differs from the target, given both images and the diff overlay
+func CommentOnDifferences(result *ImageDiffResult) error {
+	processor, err := NewVisionProcessorWithMode(false, "frontend")
+	if err != nil {
+		return fmt.Errorf("failed to create vision processor: %w", err)
+	}
+
+	var images []api.ImageData
+	for _, path := range []string{result.ExpectedPath, result.ActualPath, result.OverlayPath} {
+		data, err := processor.getImageData(path)
+		if err != nil {
+			return fmt.Errorf("failed to get image data: %w", err)
+		}
+		images = append(images, api.ImageData{Base64: data, Type: mimeTypeFor(path)})
+	}
+
+	messages := []api.Message{{
+		Role: "user",
+		Content: fmt.Sprintf(`You are verifying a UI implementation against its target design.
+Image 1 is the target, image 2 is the current screenshot, image 3 highlights differing pixels in red.
+%.1f%% of pixels differ. List the visible differences a developer should fix (layout, spacing, colors, typography, missing or extra elements), most important first, and ignore anti-aliasing noise.`,
+			result.ChangedPercent),
+		Images: images,
+	}}
+
+	var comparison ImageComparison
+	response, err := api.RequestStructured(context.Background(), processor.visionClient, messages, imageComparisonSchema, true, &comparison)
+	if response != nil && response.Usage.TotalTokens > 0 {
+		lastVisionUsage = &VisionUsageInfo{
+			PromptTokens:     response.Usage.PromptTokens,
+			CompletionTokens: response.Usage.CompletionTokens,
+			TotalTokens:      response.Usage.TotalTokens,
+			EstimatedCost:    response.Usage.EstimatedCost,
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("vision comparison failed: %w", err)
+	}
+	result.Commentary = &comparison
+	return nil
+}
+
+// String formats the result for the model
+func (r *ImageDiffResult) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "## Image Comparison: %s vs %s\n\n", filepath.Base(r.ActualPath), filepath.Base(r.ExpectedPath))
+	if r.SizeMismatch {
+		fmt.Fprintf(&out, "⚠️ Sizes differ - the screenshot was scaled to the target's %dx%d\n", r.Width, r.Height)
+	}
+	fmt.Fprintf(&out, "Changed pixels: %d (%.2f%%)\n", r.ChangedPixels, r.ChangedPercent)
+	fmt.Fprintf(&out, "Perceptual similarity: %.0f%%\n", r.PerceptualSimilar*100)
+	fmt.Fprintf(&out, "Overlay: %s\n", r.OverlayPath)
+
+	if len(r.Regions) > 0 {
+		out.WriteString("\n**Changed regions (x, y, width x height):**\n")
+		for _, region := range r.Regions {
+			fmt.Fprintf(&out, "- %d, %d, %dx%d\n", region.Min.X, region.Min.Y, region.Dx(), region.Dy())
+		}
+	}
+
+	if r.Commentary != nil {
+		if r.Commentary.Matches {
+			out.WriteString("\n**Vision review:** matches the target\n")
+		} else {
+			out.WriteString("\n**Vision review:** does not match the target\n")
+		}
+		for _, difference := range r.Commentary.Differences {
+			fmt.Fprintf(&out, "- %s\n", difference)
+		}
+		if len(r.Commentary.Suggestions) > 0 {
+			out.WriteString("\n**Suggestions:**\n")
+			for _, suggestion := range r.Commentary.Suggestions {
+				fmt.Fprintf(&out, "- %s\n", suggestion)
+			}
+		}
+	}
+	return out.String()
+}
+
+// decodeImageFile reads a PNG, JPEG or GIF file
+func decodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image %s: %w", path, err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// writePNG saves an image as PNG
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create overlay %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return fmt.Errorf("failed to write overlay %s: %w", path, err)
+	}
+	return nil
+}
+
+// mimeTypeFor returns the image MIME type for a file extension
+func mimeTypeFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// colorDistance returns the normalized RGBA distance between two colors (0-1)
+func colorDistance(a, b color.Color) float64 {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	channel := func(x, y uint32) float64 {
+		d := (float64(x) - float64(y)) / 0xffff
+		return d * d
+	}
+	return math.Sqrt((channel(ar, br) + channel(ag, bg) + channel(ab, bb) + channel(aa, ba)) / 4)
+}
+
+// luminance returns the perceived brightness of a color (0-1)
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+}
+
+// resizeNearest scales an image with nearest-neighbor sampling
+func resizeNearest(src image.Image, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sb := src.Bounds()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, src.At(sb.Min.X+x*sb.Dx()/width, sb.Min.Y+y*sb.Dy()/height))
+		}
+	}
+	return dst
+}
+
+// differenceHash is a 64-bit perceptual hash: the image is reduced to 9x8
+// grayscale and each bit records whether a pixel is brighter than its neighbor
+func differenceHash(img image.Image) uint64 {
+	small := resizeNearest(img, 9, 8)
+	gray := image.NewGray(small.Bounds())
+	draw.Draw(gray, gray.Bounds(), small, image.Point{}, draw.Src)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray.GrayAt(x, y).Y > gray.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// mergeCells turns changed grid cells into bounding boxes of connected cells,
+// largest first
+func mergeCells(cells map[image.Point]bool, width, height int) []image.Rectangle {
+	visited := make(map[image.Point]bool)
+	var regions []image.Rectangle
+	for cell := range cells {
+		if visited[cell] {
+			continue
+		}
+		region := image.Rectangle{}
+		stack := []image.Point{cell}
+		visited[cell] = true
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			rect := image.Rect(current.X*diffCellSize, current.Y*diffCellSize, (current.X+1)*diffCellSize, (current.Y+1)*diffCellSize)
+			region = region.Union(rect)
+			for _, next := range []image.Point{{current.X + 1, current.Y}, {current.X - 1, current.Y}, {current.X, current.Y + 1}, {current.X, current.Y - 1}} {
+				if cells[next] && !visited[next] {
+					visited[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+		regions = append(regions, region.Intersect(image.Rect(0, 0, width, height)))
+	}
+
+	// Largest regions first so the cap keeps the most significant ones
+	sort.Slice(regions, func(i, j int) bool { return area(regions[i]) > area(regions[j]) })
+	if len(regions) > maxReportedRegions {
+		regions = regions[:maxReportedRegions]
+	}
+	return regions
+}
+
+// area returns the number of pixels in a rectangle
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
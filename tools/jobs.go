@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job is the process group of a shell command. It stays listed while
+// processes the command started in the background keep running.
+type Job struct {
+	ID      int
+	PGID    int // Process group ID, the PID of the shell that ran the command
+	Command string
+	Started time.Time
+	Done    bool // The command itself finished; what is left runs in the background
+}
+
+// jobs tracks the process groups of this session's shell commands
+var jobs = struct {
+	sync.Mutex
+	next    int
+	running map[int]*Job
+}{running: make(map[int]*Job)}
+
+// startJob starts cmd in a process group of its own and tracks it
+func startJob(cmd *exec.Cmd, command string) (*Job, error) {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	jobs.Lock()
+	defer jobs.Unlock()
+	jobs.next++
+	job := &Job{ID: jobs.next, PGID: cmd.Process.Pid, Command: command, Started: time.Now()}
+	jobs.running[job.ID] = job
+	return job, nil
+}
+
+// finishJob records that a job's command returned. The job is forgotten
+// unless processes of its group are still running.
+func finishJob(job *Job) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	if processGroupAlive(job.PGID) {
+		job.Done = true
+		return
+	}
+	delete(jobs.running, job.ID)
+}
+
+// Jobs returns the shell commands of this session whose processes are still
+// running, oldest first
+func Jobs() []Job {
+	jobs.Lock()
+	defer jobs.Unlock()
+	var list []Job
+	for id, job := range jobs.running {
+		if job.Done && !processGroupAlive(job.PGID) {
+			delete(jobs.running, id)
+			continue
+		}
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// KillJob kills every process in a job's group
+func KillJob(id int) error {
+	jobs.Lock()
+	job, ok := jobs.running[id]
+	jobs.Unlock()
+	if !ok {
+		return fmt.Errorf("no job %d", id)
+	}
+	if err := killProcessGroup(job.PGID); err != nil && processGroupAlive(job.PGID) {
+		return fmt.Errorf("failed to kill job %d: %w", id, err)
+	}
+	jobs.Lock()
+	delete(jobs.running, id)
+	jobs.Unlock()
+	return nil
+}
+
+// KillJobs kills every job still running and returns how many there were
+func KillJobs() int {
+	killed := 0
+	for _, job := range Jobs() {
+		if KillJob(job.ID) == nil {
+			killed++
+		}
+	}
+	return killed
+}
+
+// StopJobs kills what this session's shell commands left running, on exit
+func StopJobs() {
+	if killed := KillJobs(); killed > 0 {
+		fmt.Printf("🧹 Killed %d background job(s) left by shell commands\n", killed)
+	}
+}
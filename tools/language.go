@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LanguagePack is how coder works with one language's toolchain: the project
+// commands to build, test and lint, the per-file commands to format, check and
+// rename, and how to outline a file. Commands are "" when the language has no
+// standard tool for them.
+type LanguagePack interface {
+	Name() string
+	Extensions() []string
+	Markers() []string // project files that identify a project in the language
+	BuildCommand() string
+	TestCommand() string
+	LintCommand() string
+	FormatCommand(file string) string
+	CheckCommand(file string) string // fast syntax check of one file
+	RenameCommand(file string, line, column int, newName string) string
+	OutlinePattern() *regexp.Regexp // matches top-level declarations
+}
+
+// languagePack is a LanguagePack described by command templates. Per-file
+// templates use {file}, {line}, {column} and {name}.
+type languagePack struct {
+	name            string
+	extensions      []string
+	markers         []string
+	build           string
+	test            string
+	lint            string
+	format          string
+	check           string
+	checkExtensions []string // extensions check applies to; all when empty
+	rename          string
+	outline         *regexp.Regexp
+}
+
+func (p *languagePack) Name() string                   { return p.name }
+func (p *languagePack) Extensions() []string           { return p.extensions }
+func (p *languagePack) Markers() []string              { return p.markers }
+func (p *languagePack) BuildCommand() string           { return p.build }
+func (p *languagePack) TestCommand() string            { return p.test }
+func (p *languagePack) LintCommand() string            { return p.lint }
+func (p *languagePack) OutlinePattern() *regexp.Regexp { return p.outline }
+func (p *languagePack) FormatCommand(file string) string {
+	return expandTemplate(p.format, file, 0, 0, "")
+}
+
+func (p *languagePack) CheckCommand(file string) string {
+	if len(p.checkExtensions) > 0 && !hasExtension(file, p.checkExtensions) {
+		return ""
+	}
+	return expandTemplate(p.check, file, 0, 0, "")
+}
+
+func (p *languagePack) RenameCommand(file string, line, column int, newName string) string {
+	return expandTemplate(p.rename, file, line, column, newName)
+}
+
+// expandTemplate fills in a command template, quoting the file and name
+func expandTemplate(template, file string, line, column int, name string) string {
+	if template == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{file}", ShellQuote(file),
+		"{line}", strconv.Itoa(line),
+		"{column}", strconv.Itoa(column),
+		"{name}", ShellQuote(name),
+	).Replace(template)
+}
+
+// ShellQuote quotes an argument for sh
+func ShellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// languagePacks are the registered packs, in detection order
+var languagePacks = []LanguagePack{
+	&languagePack{
+		name:       "Go",
+		extensions: []string{".go"},
+		markers:    []string{"go.mod", "go.work"},
+		build:      "go build ./...",
+		test:       "go test ./...",
+		lint:       "go vet ./...",
+		format:     "gofmt -w {file}",
+		check:      "gofmt -l -e {file}",
+		rename:     "gopls rename -w {file}:{line}:{column} {name}",
+		outline:    regexp.MustCompile(`^(func|type|var|const)\s`),
+	},
+	&languagePack{
+		name:            "TypeScript/JavaScript",
+		extensions:      []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"},
+		markers:         []string{"package.json", "tsconfig.json"},
+		build:           "npx tsc --noEmit",
+		test:            "npm test",
+		lint:            "npx eslint .",
+		format:          "npx prettier --write {file}",
+		check:           "node --check {file}",
+		checkExtensions: []string{".js", ".mjs", ".cjs"},
+		outline:         regexp.MustCompile(`^(export\s+)?(default\s+)?(async\s+)?(function|class|const|let|interface|type|enum)\s`),
+	},
+	&languagePack{
+		name:       "Python",
+		extensions: []string{".py"},
+		markers:    []string{"pyproject.toml", "setup.py", "setup.cfg", "requirements.txt"},
+		build:      "python3 -m compileall -q .",
+		test:       "python3 -m pytest",
+		lint:       "ruff check .",
+		format:     "ruff format {file}",
+		check:      "python3 -m py_compile {file}",
+		outline:    regexp.MustCompile(`^\s*(async\s+def|def|class)\s`),
+	},
+	&languagePack{
+		name:       "Rust",
+		extensions: []string{".rs"},
+		markers:    []string{"Cargo.toml"},
+		build:      "cargo build",
+		test:       "cargo test",
+		lint:       "cargo clippy",
+		format:     "rustfmt {file}",
+		outline:    regexp.MustCompile(`^\s*(pub(\([a-z]+\))?\s+)?(fn|struct|enum|trait|impl|mod|type|const)\s`),
+	},
+}
+
+// RegisterLanguagePack adds a pack; it takes precedence over the built-ins
+// for its extensions
+func RegisterLanguagePack(pack LanguagePack) {
+	languagePacks = append([]LanguagePack{pack}, languagePacks...)
+}
+
+// LanguagePacks returns the registered packs
+func LanguagePacks() []LanguagePack {
+	return languagePacks
+}
+
+// LanguagePackFor returns the pack for a file by its extension, or nil
+func LanguagePackFor(filePath string) LanguagePack {
+	for _, pack := range languagePacks {
+		if hasExtension(filePath, pack.Extensions()) {
+			return pack
+		}
+	}
+	return nil
+}
+
+// DetectLanguagePacks returns the packs whose project markers exist in root
+func DetectLanguagePacks(root string) []LanguagePack {
+	var detected []LanguagePack
+	for _, pack := range languagePacks {
+		for _, marker := range pack.Markers() {
+			if _, err := os.Stat(filepath.Join(root, marker)); err == nil {
+				detected = append(detected, pack)
+				break
+			}
+		}
+	}
+	return detected
+}
+
+// CommandAvailable reports whether the program a command starts with is installed
+func CommandAvailable(command string) bool {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return false
+	}
+	_, err := exec.LookPath(fields[0])
+	return err == nil
+}
+
+// hasExtension reports whether filePath ends in one of extensions
+func hasExtension(filePath string, extensions []string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, candidate := range extensions {
+		if ext == candidate {
+			return true
+		}
+	}
+	return false
+}
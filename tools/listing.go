@@ -0,0 +1,346 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultListDepth is how many directory levels list_directory shows
+	DefaultListDepth = 2
+	// DefaultListEntries bounds the lines of a listing or glob result
+	DefaultListEntries = 500
+)
+
+// listSkipDirs are never listed outside a git repository, where there is
+// no .gitignore handling by git to leave them out
+var listSkipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true, ".venv": true, "__pycache__": true}
+
+// ListOptions narrow a directory listing or glob search. Patterns without a
+// "/" match any path component ("*.go", "testdata"); others match the path
+// from the listed directory, with "**" for any number of directories.
+type ListOptions struct {
+	MaxDepth   int      // Directory levels to descend (0 = unlimited)
+	Include    []string // Keep only files matching one of these
+	Exclude    []string // Drop files and directories matching any of these
+	MaxEntries int      // Lines before the result is cut (0 = DefaultListEntries)
+}
+
+// listedFile is a file found under a listed directory
+type listedFile struct {
+	Path string // Slash-separated, relative to the listed directory
+	Size int64
+}
+
+// listFiles returns the files under dir that git doesn't ignore. Outside a
+// git repository it walks the directory, honoring its .gitignore.
+func listFiles(dir string) ([]listedFile, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	cmd := exec.Command("git", "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	cmd.Dir = dir
+	if output, err := cmd.Output(); err == nil {
+		var files []listedFile
+		seen := make(map[string]bool)
+		for _, name := range strings.Split(string(output), "\x00") {
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true // unmerged files are listed once per stage
+			// Tracked files deleted from the working tree are still in the index
+			if info, err := os.Lstat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+				files = append(files, listedFile{Path: name, Size: info.Size()})
+			}
+		}
+		return files, nil
+	}
+
+	ignored := readIgnorePatterns(filepath.Join(dir, ".gitignore"))
+	var files []listedFile
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if listSkipDirs[d.Name()] || matchesAny(ignored, rel+"/", true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchesAny(ignored, rel, true) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files = append(files, listedFile{Path: rel, Size: info.Size()})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// readIgnorePatterns reads the patterns of a .gitignore file. Negations are
+// not supported and skipped.
+func readIgnorePatterns(file string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		patterns = append(patterns, strings.TrimPrefix(line, "/"))
+	}
+	return patterns
+}
+
+// matchesAny reports whether a slash-separated path matches one of the
+// patterns. With prefixes, a pattern matching a parent directory counts too.
+func matchesAny(patterns []string, rel string, prefixes bool) bool {
+	for _, pattern := range patterns {
+		if matchListPattern(pattern, rel, prefixes) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchListPattern matches one pattern against a slash-separated path
+func matchListPattern(pattern, rel string, prefixes bool) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+	rel = strings.TrimSuffix(rel, "/")
+	parts := strings.Split(rel, "/")
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+	patternParts := strings.Split(pattern, "/")
+	if globSegments(patternParts, parts) {
+		return true
+	}
+	if prefixes {
+		for i := 1; i < len(parts); i++ {
+			if globSegments(patternParts, parts[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globSegments matches path segments against pattern segments, where "**"
+// matches any number of segments
+func globSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if globSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return globSegments(pattern[1:], parts[1:])
+}
+
+// filterListed applies the include and exclude patterns of the options
+func filterListed(files []listedFile, opts ListOptions) []listedFile {
+	var kept []listedFile
+	for _, file := range files {
+		if matchesAny(opts.Exclude, file.Path, true) {
+			continue
+		}
+		if len(opts.Include) > 0 && !matchesAny(opts.Include, file.Path, false) {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// listNode is a directory or file in a listing tree
+type listNode struct {
+	name     string
+	size     int64
+	files    int // Files at or below this node
+	children map[string]*listNode
+}
+
+// ListDirectory lists the files under dir as an indented tree, directories
+// first, down to opts.MaxDepth levels. Deeper directories show their file
+// count. Files git ignores are left out.
+func ListDirectory(dir string, opts ListOptions) (string, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	files = filterListed(files, opts)
+	if len(files) == 0 {
+		return fmt.Sprintf("%s: no files (ignored files and patterns excluded)", dir), nil
+	}
+
+	root := &listNode{children: make(map[string]*listNode)}
+	for _, file := range files {
+		node := root
+		node.files++
+		parts := strings.Split(file.Path, "/")
+		for i, part := range parts {
+			child, ok := node.children[part]
+			if !ok {
+				child = &listNode{name: part}
+				if i < len(parts)-1 {
+					child.children = make(map[string]*listNode)
+				}
+				node.children[part] = child
+			}
+			child.files++
+			if i == len(parts)-1 {
+				child.size = file.Size
+			}
+			node = child
+		}
+	}
+
+	limit := opts.MaxEntries
+	if limit <= 0 {
+		limit = DefaultListEntries
+	}
+	var lines []string
+	truncated := writeListNode(root, 0, opts.MaxDepth, limit, &lines)
+	header := fmt.Sprintf("%s: %d files", dir, len(files))
+	if opts.MaxDepth > 0 {
+		header += fmt.Sprintf(", %d level(s) shown", opts.MaxDepth)
+	}
+	result := header + "\n" + strings.Join(lines, "\n")
+	if truncated {
+		result += fmt.Sprintf("\n... cut at %d entries - narrow it with include/exclude patterns, a subdirectory or a lower max_depth", limit)
+	}
+	return result, nil
+}
+
+// writeListNode renders the children of a node, reporting whether the limit
+// cut the listing
+func writeListNode(node *listNode, depth, maxDepth, limit int, lines *[]string) bool {
+	var dirs, files []*listNode
+	for _, child := range node.children {
+		if child.children != nil {
+			dirs = append(dirs, child)
+		} else {
+			files = append(files, child)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].name < dirs[j].name })
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	indent := strings.Repeat("  ", depth)
+	for _, dir := range dirs {
+		if len(*lines) >= limit {
+			return true
+		}
+		if maxDepth > 0 && depth+1 >= maxDepth {
+			*lines = append(*lines, fmt.Sprintf("%s%s/ (%d files)", indent, dir.name, dir.files))
+			continue
+		}
+		*lines = append(*lines, fmt.Sprintf("%s%s/", indent, dir.name))
+		if writeListNode(dir, depth+1, maxDepth, limit, lines) {
+			return true
+		}
+	}
+	for _, file := range files {
+		if len(*lines) >= limit {
+			return true
+		}
+		*lines = append(*lines, fmt.Sprintf("%s%s (%s)", indent, file.name, formatListSize(file.size)))
+	}
+	return false
+}
+
+// GlobFiles lists the files under dir matching pattern, sorted by path.
+// Files git ignores are left out.
+func GlobFiles(dir, pattern string, opts ListOptions) (string, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return "", fmt.Errorf("glob needs a pattern, e.g. \"**/*_test.go\"")
+	}
+	files, err := listFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	files = filterListed(files, opts)
+
+	var matches []listedFile
+	for _, file := range files {
+		if opts.MaxDepth > 0 && strings.Count(file.Path, "/") >= opts.MaxDepth {
+			continue
+		}
+		if matchListPattern(pattern, file.Path, false) {
+			matches = append(matches, file)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Sprintf("No files under %s match %s", dir, pattern), nil
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Path < matches[j].Path })
+
+	limit := opts.MaxEntries
+	if limit <= 0 {
+		limit = DefaultListEntries
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d file(s) under %s match %s", len(matches), dir, pattern)
+	for i, match := range matches {
+		if i == limit {
+			fmt.Fprintf(&b, "\n... %d more - narrow the pattern or add exclude patterns", len(matches)-limit)
+			break
+		}
+		fmt.Fprintf(&b, "\n%s (%s)", match.Path, formatListSize(match.Size))
+	}
+	return b.String(), nil
+}
+
+// formatListSize renders a file size for listings
+func formatListSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
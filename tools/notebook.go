@@ -0,0 +1,359 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	maxNotebookOutputChars = 2000             // output text shown per cell
+	notebookRunTimeout     = 10 * time.Minute // limit for executing the cells up to the one being run
+)
+
+// Notebook is a Jupyter notebook. It is kept as generic JSON so metadata and
+// fields coder doesn't know about are written back unchanged.
+type Notebook struct {
+	path string
+	doc  map[string]interface{}
+}
+
+// IsNotebook reports whether a path is a Jupyter notebook
+func IsNotebook(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".ipynb")
+}
+
+// LoadNotebook reads a notebook
+func LoadNotebook(path string) (*Notebook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notebook %s: %w", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // keep numbers in metadata exactly as written
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s is not a valid notebook: %w", path, err)
+	}
+	if _, ok := doc["cells"].([]interface{}); !ok {
+		if doc["cells"] != nil {
+			return nil, fmt.Errorf("%s is not a valid notebook: cells is not a list", path)
+		}
+		doc["cells"] = []interface{}{}
+	}
+	return &Notebook{path: path, doc: doc}, nil
+}
+
+// Save writes the notebook the way Jupyter does: sorted keys, one-space indent
+func (nb *Notebook) Save() error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(nb.doc); err != nil {
+		return fmt.Errorf("failed to encode notebook: %w", err)
+	}
+	if err := os.WriteFile(nb.path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write notebook %s: %w", nb.path, err)
+	}
+	return nil
+}
+
+// cells returns the notebook's cells
+func (nb *Notebook) cells() []interface{} {
+	cells, _ := nb.doc["cells"].([]interface{})
+	return cells
+}
+
+// cell returns cell i, or an error naming the valid range
+func (nb *Notebook) cell(i int) (map[string]interface{}, error) {
+	cells := nb.cells()
+	if i < 0 || i >= len(cells) {
+		return nil, fmt.Errorf("cell %d does not exist - the notebook has %d cells (0-%d)", i, len(cells), len(cells)-1)
+	}
+	cell, ok := cells[i].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cell %d is malformed", i)
+	}
+	return cell, nil
+}
+
+// NumCells returns the number of cells
+func (nb *Notebook) NumCells() int {
+	return len(nb.cells())
+}
+
+// joinSource turns a source or text field, a string or a list of lines, into text
+func joinSource(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var b strings.Builder
+		for _, line := range v {
+			if s, ok := line.(string); ok {
+				b.WriteString(s)
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// splitSource turns text into the list of lines notebooks store
+func splitSource(text string) []interface{} {
+	lines := []interface{}{}
+	for text != "" {
+		i := strings.Index(text, "\n")
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+// kernelName returns the notebook's kernel, e.g. "python3"
+func (nb *Notebook) kernelName() string {
+	metadata, _ := nb.doc["metadata"].(map[string]interface{})
+	kernelspec, _ := metadata["kernelspec"].(map[string]interface{})
+	name, _ := kernelspec["name"].(string)
+	return name
+}
+
+// Render shows the notebook as numbered cells with their text outputs
+func (nb *Notebook) Render(includeOutputs bool) string {
+	var b strings.Builder
+	kernel := nb.kernelName()
+	if kernel == "" {
+		kernel = "unknown"
+	}
+	fmt.Fprintf(&b, "Notebook %s: %d cells, kernel %s\n", nb.path, nb.NumCells(), kernel)
+	for i := range nb.cells() {
+		cell, err := nb.cell(i)
+		if err != nil {
+			fmt.Fprintf(&b, "\n[cell %d] %v\n", i, err)
+			continue
+		}
+		cellType, _ := cell["cell_type"].(string)
+		header := fmt.Sprintf("[cell %d] %s", i, cellType)
+		if count, ok := cell["execution_count"].(json.Number); ok {
+			header += fmt.Sprintf(" (execution %s)", count)
+		}
+		fmt.Fprintf(&b, "\n%s\n%s\n", header, strings.TrimRight(joinSource(cell["source"]), "\n"))
+		if includeOutputs && cellType == "code" {
+			if output := renderOutputs(cell["outputs"]); output != "" {
+				fmt.Fprintf(&b, "--- output ---\n%s\n", output)
+			}
+		}
+	}
+	return b.String()
+}
+
+// renderOutputs shows a code cell's outputs as text; rich outputs are named only
+func renderOutputs(value interface{}) string {
+	outputs, _ := value.([]interface{})
+	var parts []string
+	for _, raw := range outputs {
+		output, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch output["output_type"] {
+		case "stream":
+			parts = append(parts, joinSource(output["text"]))
+		case "error":
+			parts = append(parts, fmt.Sprintf("%v: %v", output["ename"], output["evalue"]))
+		case "execute_result", "display_data":
+			data, _ := output["data"].(map[string]interface{})
+			if text, ok := data["text/plain"]; ok {
+				parts = append(parts, joinSource(text))
+			}
+			for mime := range data {
+				if mime != "text/plain" {
+					parts = append(parts, fmt.Sprintf("[%s output]", mime))
+				}
+			}
+		}
+	}
+	text := strings.TrimRight(strings.Join(parts, "\n"), "\n")
+	if len(text) > maxNotebookOutputChars {
+		text = text[:maxNotebookOutputChars] + "\n... (output truncated)"
+	}
+	return text
+}
+
+// ReadNotebook renders a notebook's cells for the model
+func ReadNotebook(path string, includeOutputs bool) (string, error) {
+	nb, err := LoadNotebook(path)
+	if err != nil {
+		return "", err
+	}
+	return nb.Render(includeOutputs), nil
+}
+
+// Cell edit actions
+const (
+	CellReplace = "replace" // replace the source of an existing cell
+	CellInsert  = "insert"  // insert a new cell before the given index (the cell count appends)
+	CellDelete  = "delete"  // remove a cell
+)
+
+// EditCell changes one cell of a notebook. Replacing a code cell's source
+// clears its outputs, which no longer match it.
+func EditCell(path string, index int, action, source, cellType string) (string, error) {
+	nb, err := LoadNotebook(path)
+	if err != nil {
+		return "", err
+	}
+	if action == "" {
+		action = CellReplace
+	}
+	if cellType != "" && cellType != "code" && cellType != "markdown" && cellType != "raw" {
+		return "", fmt.Errorf("unknown cell type %q - use code, markdown or raw", cellType)
+	}
+	cells := nb.cells()
+
+	switch action {
+	case CellReplace:
+		cell, err := nb.cell(index)
+		if err != nil {
+			return "", err
+		}
+		if cellType != "" && cellType != cell["cell_type"] {
+			converted := nb.newCell(cellType)
+			if id, ok := cell["id"]; ok {
+				converted["id"] = id
+			}
+			cell = converted
+			cells[index] = cell
+		}
+		cell["source"] = splitSource(source)
+		if cell["cell_type"] == "code" {
+			cell["outputs"] = []interface{}{}
+			cell["execution_count"] = nil
+		}
+	case CellInsert:
+		if index < 0 || index > len(cells) {
+			return "", fmt.Errorf("cannot insert at %d - use 0-%d", index, len(cells))
+		}
+		if cellType == "" {
+			cellType = "code"
+		}
+		cell := nb.newCell(cellType)
+		cell["source"] = splitSource(source)
+		cells = append(cells[:index], append([]interface{}{cell}, cells[index:]...)...)
+	case CellDelete:
+		if _, err := nb.cell(index); err != nil {
+			return "", err
+		}
+		cells = append(cells[:index], cells[index+1:]...)
+	default:
+		return "", fmt.Errorf("unknown action %q - use replace, insert or delete", action)
+	}
+
+	nb.doc["cells"] = cells
+	if err := nb.Save(); err != nil {
+		return "", err
+	}
+	done := map[string]string{CellReplace: "replaced", CellInsert: "inserted", CellDelete: "deleted"}[action]
+	return fmt.Sprintf("Notebook %s: %s cell %d (now %d cells)", path, done, index, len(cells)), nil
+}
+
+// newCell returns an empty cell of the given type
+func (nb *Notebook) newCell(cellType string) map[string]interface{} {
+	cell := map[string]interface{}{
+		"cell_type": cellType,
+		"metadata":  map[string]interface{}{},
+		"source":    []interface{}{},
+	}
+	if cellType == "code" {
+		cell["outputs"] = []interface{}{}
+		cell["execution_count"] = nil
+	}
+	// nbformat 4.5 and later require cell ids
+	if minor, ok := nb.doc["nbformat_minor"].(json.Number); ok {
+		if n, err := minor.Int64(); err == nil && n >= 5 {
+			id := make([]byte, 4)
+			rand.Read(id)
+			cell["id"] = hex.EncodeToString(id)
+		}
+	}
+	return cell
+}
+
+// RunCell executes a code cell with the notebook's Jupyter kernel and stores
+// its outputs. The cells before it run first, so the cell sees the state it
+// depends on; their stored outputs are left unchanged.
+func RunCell(path string, index int) (string, error) {
+	nb, err := LoadNotebook(path)
+	if err != nil {
+		return "", err
+	}
+	cell, err := nb.cell(index)
+	if err != nil {
+		return "", err
+	}
+	if cell["cell_type"] != "code" {
+		return "", fmt.Errorf("cell %d is a %v cell, not code", index, cell["cell_type"])
+	}
+	if _, err := exec.LookPath("jupyter"); err != nil {
+		return "", fmt.Errorf("jupyter is not installed, so cells can't be run - run the code with shell_command instead (e.g. python3 -c)")
+	}
+
+	// Execute a copy holding the cells up to this one, next to the notebook so relative paths work
+	prefix := &Notebook{doc: make(map[string]interface{}, len(nb.doc))}
+	for key, value := range nb.doc {
+		prefix.doc[key] = value
+	}
+	prefix.doc["cells"] = nb.cells()[:index+1]
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+"-run-*.ipynb")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp notebook: %w", err)
+	}
+	temp.Close()
+	defer os.Remove(temp.Name())
+	prefix.path = temp.Name()
+	if err := prefix.Save(); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notebookRunTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "jupyter", "nbconvert", "--to", "notebook", "--execute", "--inplace", "--allow-errors",
+		fmt.Sprintf("--ExecutePreprocessor.timeout=%d", int(notebookRunTimeout.Seconds())), temp.Name())
+	cmd.Dir = filepath.Dir(path)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("jupyter failed to run the notebook: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+
+	executed, err := LoadNotebook(temp.Name())
+	if err != nil {
+		return "", err
+	}
+	ran, err := executed.cell(index)
+	if err != nil {
+		return "", err
+	}
+	cell["outputs"] = ran["outputs"]
+	cell["execution_count"] = ran["execution_count"]
+	if err := nb.Save(); err != nil {
+		return "", err
+	}
+
+	output := renderOutputs(cell["outputs"])
+	if output == "" {
+		output = "(no output)"
+	}
+	return fmt.Sprintf("Ran cell %d of %s (cells 0-%d executed):\n%s", index, path, index, output), nil
+}
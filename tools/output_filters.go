@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Shell output filters that post-processors can apply by name
+const (
+	FilterStripANSI        = "strip_ansi"        // remove terminal escape sequences
+	FilterCollapseProgress = "collapse_progress" // keep the final state of progress bars and spinners
+	FilterGoTestFailures   = "go_test_failures"  // drop passing tests, keep failures, build errors and panics
+	FilterDedupeLines      = "dedupe_lines"      // collapse runs of identical lines
+)
+
+// pipeTimeout bounds how long an external post-processor may run
+const pipeTimeout = 10 * time.Second
+
+// ansiPattern matches CSI and OSC terminal escape sequences
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// progressPattern matches lines that only report progress, like "45%", "[=====>   ]" or "12/340"
+var progressPattern = regexp.MustCompile(`^\s*(\S+\s+)?(\d{1,3}(\.\d+)?%|\[[=#>.\- ]{3,}\]|\d+/\d+)(\s|$)`)
+
+// goTestNoise matches go test lines that carry no information about failures
+var goTestNoise = regexp.MustCompile(`^(=== (RUN|PAUSE|CONT|NAME)\s|PASS$|ok\s+\S+|\?\s+\S+\s+\[no test files\])`)
+
+// ValidOutputFilter reports whether name is a known output filter
+func ValidOutputFilter(name string) bool {
+	switch name {
+	case FilterStripANSI, FilterCollapseProgress, FilterGoTestFailures, FilterDedupeLines:
+		return true
+	}
+	return false
+}
+
+// ApplyOutputFilter applies a named filter to command output; unknown names
+// leave the output unchanged
+func ApplyOutputFilter(name, output string) string {
+	switch name {
+	case FilterStripANSI:
+		return ansiPattern.ReplaceAllString(output, "")
+	case FilterCollapseProgress:
+		return collapseProgress(output)
+	case FilterGoTestFailures:
+		return goTestFailures(output)
+	case FilterDedupeLines:
+		return dedupeLines(output)
+	default:
+		return output
+	}
+}
+
+// SanitizeOutput makes command output safe to store and echo: escape
+// sequences are removed, lines redrawn with carriage returns or backspaces
+// keep what a terminal would finally show, and other control characters
+// except tabs are dropped
+func SanitizeOutput(output string) string {
+	if !strings.ContainsFunc(output, func(r rune) bool { return r < 0x20 && r != '\n' && r != '\t' || r == 0x7f }) {
+		return output
+	}
+	output = ansiPattern.ReplaceAllString(output, "")
+
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if j := strings.LastIndex(line, "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		kept := make([]rune, 0, len(line))
+		for _, r := range line {
+			switch {
+			case r == '\b':
+				if len(kept) > 0 {
+					kept = kept[:len(kept)-1]
+				}
+			case r < 0x20 && r != '\t', r == 0x7f:
+			default:
+				kept = append(kept, r)
+			}
+		}
+		lines[i] = string(kept)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseProgress keeps what a terminal would finally show for lines
+// redrawn with carriage returns, and the last of a run of progress lines
+func collapseProgress(output string) string {
+	lines := strings.Split(output, "\n")
+	result := make([]string, 0, len(lines))
+	previousProgress := false
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		progress := progressPattern.MatchString(line)
+		if progress && previousProgress {
+			result[len(result)-1] = line
+			continue
+		}
+		previousProgress = progress
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// goTestFailures drops the lines of passing and skipped tests from go test
+// output, along with their indented logs, which -v prints before the result
+// line. Failures, build errors, panics and the FAIL summary lines are kept.
+func goTestFailures(output string) string {
+	lines := strings.Split(output, "\n")
+	result := make([]string, 0, len(lines))
+	var pending []string // logs of the running test, kept if it doesn't pass
+	inPassed := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		switch {
+		case strings.HasPrefix(trimmed, "--- PASS"), strings.HasPrefix(trimmed, "--- SKIP"):
+			pending, inPassed = nil, true
+			continue
+		case strings.HasPrefix(trimmed, "--- FAIL"):
+			inPassed = false
+		case indented && inPassed:
+			continue
+		case indented:
+			pending = append(pending, line)
+			continue
+		}
+		inPassed = false
+		result = append(result, pending...)
+		pending = nil
+		if goTestNoise.MatchString(trimmed) {
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(append(result, pending...), "\n")
+}
+
+// dedupeLines collapses runs of identical lines into one with a repeat count
+func dedupeLines(output string) string {
+	lines := strings.Split(output, "\n")
+	result := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); {
+		j := i + 1
+		for j < len(lines) && lines[j] == lines[i] {
+			j++
+		}
+		result = append(result, lines[i])
+		if j-i > 1 {
+			result = append(result, fmt.Sprintf("[previous line repeated %d more times]", j-i-1))
+		}
+		i = j
+	}
+	return strings.Join(result, "\n")
+}
+
+// PipeOutput runs output through an external shell command, such as a
+// project's own failure extractor, and returns what it prints
+func PipeOutput(command, output string) (string, error) {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	cmd := exec.Command(shell, "-c", command)
+	cmd.Stdin = strings.NewReader(output)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start post-processor: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("post-processor '%s' failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), nil
+	case <-time.After(pipeTimeout):
+		killProcessGroup(cmd.Process.Pid)
+		return "", fmt.Errorf("post-processor '%s' timed out after %v", command, pipeTimeout)
+	}
+}
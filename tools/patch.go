@@ -0,0 +1,306 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeader matches "@@ -12,5 +12,7 @@" with optional counts and section text
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// PatchHunk is one hunk of a unified diff
+type PatchHunk struct {
+	OldStart int      // 1-based line the hunk starts at in the old file
+	Lines    []string // Context (" "), removed ("-") and added ("+") lines
+	// NoNewline records "\ No newline at end of file" after the old or new side
+	OldNoNewline, NewNoNewline bool
+}
+
+// FilePatch is the part of a unified diff that changes one file
+type FilePatch struct {
+	OldPath string // "" when the patch creates the file
+	NewPath string // "" when the patch deletes the file
+	Hunks   []PatchHunk
+}
+
+// Path returns the file the patch writes, or deletes
+func (p FilePatch) Path() string {
+	if p.NewPath != "" {
+		return p.NewPath
+	}
+	return p.OldPath
+}
+
+// ParsePatch reads a unified diff, as made by git diff or diff -u. Line
+// counts in hunk headers are not trusted, since models often get them
+// wrong: a hunk ends at the next header or file.
+func ParsePatch(patch string) ([]FilePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var patches []FilePatch
+	var current *FilePatch
+	var hunk *PatchHunk
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			patches = append(patches, FilePatch{OldPath: patchPath(line[4:]), NewPath: patchPath(lines[i+1][4:])})
+			current, hunk = &patches[len(patches)-1], nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("hunk %q comes before a ---/+++ file header", line)
+			}
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid hunk header %q in the patch of %s", line, current.Path())
+			}
+			start, _ := strconv.Atoi(m[1])
+			current.Hunks = append(current.Hunks, PatchHunk{OldStart: start})
+			hunk = &current.Hunks[len(current.Hunks)-1]
+		case hunk == nil:
+			// diff --git, index, mode lines and text around the diff
+		case strings.HasPrefix(line, `\`):
+			if n := len(hunk.Lines); n > 0 {
+				if hunk.Lines[n-1][0] != '+' {
+					hunk.OldNoNewline = true
+				}
+				if hunk.Lines[n-1][0] != '-' {
+					hunk.NewNoNewline = true
+				}
+			}
+		case line == "":
+			// Editors and models drop the space of empty context lines
+			if i < len(lines)-1 {
+				hunk.Lines = append(hunk.Lines, " ")
+			}
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			hunk.Lines = append(hunk.Lines, line)
+		default:
+			hunk = nil
+		}
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found - the patch needs ---/+++ file headers and @@ hunks")
+	}
+	for _, p := range patches {
+		if p.Path() == "" {
+			return nil, fmt.Errorf("a file in the patch has no path")
+		}
+		if len(p.Hunks) == 0 {
+			return nil, fmt.Errorf("the patch of %s has no hunks", p.Path())
+		}
+	}
+	return patches, nil
+}
+
+// patchPath reads the path of a ---/+++ header, dropping git's a/ and b/
+// prefixes and diff -u timestamps. /dev/null gives "".
+func patchPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return filepath.Clean(filepath.FromSlash(path))
+}
+
+// PatchPaths returns the files a patch changes, or nil if it doesn't parse
+func PatchPaths(patch string) []string {
+	patches, err := ParsePatch(patch)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, p := range patches {
+		paths = append(paths, p.Path())
+		if p.OldPath != "" && p.NewPath != "" && p.OldPath != p.NewPath {
+			paths = append(paths, p.OldPath)
+		}
+	}
+	return paths
+}
+
+// patchedFile is a file's content before and after a patch
+type patchedFile struct {
+	path          string
+	before, after []byte
+	existed       bool
+	deleted       bool
+}
+
+// ApplyPatchChanges applies a parsed patch to files relative to dir. Hunks
+// are found by their content near the line their header names, so shifted
+// line numbers are fine. Every file is patched in memory first; if any hunk
+// doesn't apply, nothing is written, and if a write fails, the files already
+// written are restored.
+func ApplyPatchChanges(dir string, patches []FilePatch) (string, []FileChange, error) {
+	var results []patchedFile
+	seen := make(map[string]bool)
+	for _, p := range patches {
+		if seen[p.Path()] {
+			return "", nil, fmt.Errorf("%s appears twice in the patch - put all its hunks under one ---/+++ header", p.Path())
+		}
+		seen[p.Path()] = true
+		result, err := patchFile(dir, p)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w - the patch was not applied", err)
+		}
+		results = append(results, result...)
+	}
+
+	var changes []FileChange
+	var summaries []string
+	for i, result := range results {
+		full := filepath.Join(dir, result.path)
+		var err error
+		if result.deleted {
+			err = os.Remove(full)
+		} else {
+			if err = os.MkdirAll(filepath.Dir(full), 0755); err == nil {
+				err = os.WriteFile(full, result.after, 0644)
+			}
+		}
+		if err != nil {
+			if unrestored := restorePatchedFiles(dir, results[:i]); len(unrestored) > 0 {
+				return "", nil, fmt.Errorf("failed to write %s: %w - and %s could not be restored", result.path, err, strings.Join(unrestored, ", "))
+			}
+			return "", nil, fmt.Errorf("failed to write %s: %w - the patch was not applied", result.path, err)
+		}
+		change := DescribeChange(full, result.before, result.after, result.existed)
+		changes = append(changes, change)
+		summaries = append(summaries, change.Summary())
+	}
+	return fmt.Sprintf("Patch applied to %d file(s):\n%s", len(changes), strings.Join(summaries, "\n")), changes, nil
+}
+
+// restorePatchedFiles puts files written by a patch back as they were,
+// returning the paths it couldn't restore
+func restorePatchedFiles(dir string, written []patchedFile) []string {
+	var unrestored []string
+	for i := len(written) - 1; i >= 0; i-- {
+		result := written[i]
+		full := filepath.Join(dir, result.path)
+		var err error
+		if result.deleted || result.existed {
+			err = os.WriteFile(full, result.before, 0644)
+		} else {
+			err = os.Remove(full)
+		}
+		if err != nil {
+			unrestored = append(unrestored, result.path)
+		}
+	}
+	return unrestored
+}
+
+// patchFile computes the result of one file's patch. A rename also yields
+// the deletion of the old path.
+func patchFile(dir string, p FilePatch) ([]patchedFile, error) {
+	var before []byte
+	existed := p.OldPath != ""
+	if existed {
+		content, err := os.ReadFile(filepath.Join(dir, p.OldPath))
+		if err != nil {
+			return nil, fmt.Errorf("cannot patch %s: %w", p.OldPath, err)
+		}
+		before = content
+	}
+	if p.NewPath != "" && p.NewPath != p.OldPath {
+		if _, err := os.Stat(filepath.Join(dir, p.NewPath)); err == nil {
+			if existed {
+				return nil, fmt.Errorf("the patch renames %s to %s, which already exists", p.OldPath, p.NewPath)
+			}
+			return nil, fmt.Errorf("the patch creates %s, which already exists", p.NewPath)
+		}
+	}
+
+	lines := splitChangeLines(string(before))
+	noNewline := len(before) > 0 && before[len(before)-1] != '\n'
+	offset := 0 // How far earlier hunks moved the lines below them
+	for i, hunk := range p.Hunks {
+		var oldLines, newLines []string
+		for _, line := range hunk.Lines {
+			if line[0] != '+' {
+				oldLines = append(oldLines, line[1:])
+			}
+			if line[0] != '-' {
+				newLines = append(newLines, line[1:])
+			}
+		}
+		at := findHunk(lines, oldLines, hunk.OldStart-1+offset)
+		if at < 0 {
+			return nil, fmt.Errorf("hunk %d of %s doesn't apply: its context and removed lines (from line %d) aren't in the file - read the file and make the patch against its current content", i+1, p.Path(), hunk.OldStart)
+		}
+		lines = append(lines[:at], append(newLines, lines[at+len(oldLines):]...)...)
+		offset += len(newLines) - len(oldLines)
+		if at+len(newLines) == len(lines) {
+			if hunk.NewNoNewline {
+				noNewline = true
+			} else if hunk.OldNoNewline {
+				noNewline = false
+			}
+		}
+	}
+
+	if p.NewPath == "" {
+		if len(lines) > 0 {
+			return nil, fmt.Errorf("the patch deletes %s but leaves %d line(s) of it", p.OldPath, len(lines))
+		}
+		return []patchedFile{{path: p.OldPath, before: before, existed: true, deleted: true}}, nil
+	}
+	after := strings.Join(lines, "\n")
+	if len(lines) > 0 && !noNewline {
+		after += "\n"
+	}
+	results := []patchedFile{{path: p.NewPath, before: before, after: []byte(after), existed: existed && p.OldPath == p.NewPath}}
+	if existed && p.OldPath != p.NewPath {
+		results = append(results, patchedFile{path: p.OldPath, before: before, existed: true, deleted: true})
+	}
+	return results, nil
+}
+
+// findHunk returns where the old lines of a hunk are in the file, preferring
+// the match closest to the expected line. Trailing whitespace is ignored if
+// there is no exact match. It returns -1 when the lines aren't there.
+func findHunk(lines, old []string, expected int) int {
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		best := -1
+		for at := 0; at+len(old) <= len(lines); at++ {
+			matched := true
+			for j := range old {
+				if !equal(lines[at+j], old[j]) {
+					matched = false
+					break
+				}
+			}
+			if matched && (best < 0 || abs(at-expected) < abs(best-expected)) {
+				best = at
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrerequisiteTimeout bounds how long a version command may run
+const PrerequisiteTimeout = 10 * time.Second
+
+// PrerequisitesFile is where a project declares the tools it needs, relative to the project root
+const PrerequisitesFile = ".coder/prerequisites.json"
+
+// Prerequisite is a tool a project needs, with an optional version constraint
+// such as ">=1.22", "<2" or "20" (meaning at least 20)
+type Prerequisite struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Command string `json:"command,omitempty"` // prints the version; default "<name> --version"
+	Install string `json:"install,omitempty"` // how to install it, passed to the agent
+	Source  string `json:"-"`                 // where the requirement was declared
+}
+
+// PrerequisiteStatus is the result of checking one prerequisite
+type PrerequisiteStatus struct {
+	Prerequisite
+	Installed string // installed version, "" if not found or unknown
+	Missing   bool   // the tool isn't on the PATH
+	OK        bool
+	Problem   string
+}
+
+// versionArgs are the version flags of tools that don't take --version
+var versionArgs = map[string][]string{
+	"go":   {"version"},
+	"java": {"-version"},
+}
+
+// goDirective matches the go version line of go.mod
+var goDirective = regexp.MustCompile(`(?m)^go\s+(\d+(?:\.\d+)*)`)
+
+// LoadPrerequisites returns the tools the project in root needs: those declared
+// in .coder/prerequisites.json, plus what go.mod, package.json engines, .nvmrc,
+// .tool-versions and docker files require. A declared tool wins over an inferred one.
+func LoadPrerequisites(root string) ([]Prerequisite, error) {
+	var prerequisites []Prerequisite
+	seen := make(map[string]bool)
+	add := func(p Prerequisite) {
+		if p.Name != "" && !seen[p.Name] {
+			seen[p.Name] = true
+			prerequisites = append(prerequisites, p)
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, PrerequisitesFile)); err == nil {
+		var declared struct {
+			Tools []Prerequisite `json:"tools"`
+		}
+		if err := json.Unmarshal(data, &declared); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", PrerequisitesFile, err)
+		}
+		for _, p := range declared.Tools {
+			p.Source = PrerequisitesFile
+			add(p)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", PrerequisitesFile, err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, ".tool-versions")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(strings.SplitN(line, "#", 2)[0])
+			if len(fields) >= 2 {
+				name := map[string]string{"golang": "go", "nodejs": "node", "python": "python3"}[fields[0]]
+				if name == "" {
+					name = fields[0]
+				}
+				add(Prerequisite{Name: name, Version: fields[1], Source: ".tool-versions"})
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+		if m := goDirective.FindSubmatch(data); m != nil {
+			add(Prerequisite{Name: "go", Version: ">=" + string(m[1]), Source: "go.mod"})
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		var pkg struct {
+			Engines map[string]string `json:"engines"`
+		}
+		if json.Unmarshal(data, &pkg) == nil && pkg.Engines["node"] != "" {
+			add(Prerequisite{Name: "node", Version: pkg.Engines["node"], Source: "package.json engines"})
+		}
+	}
+	for _, name := range []string{".nvmrc", ".node-version"} {
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			if version := strings.TrimPrefix(strings.TrimSpace(string(data)), "v"); version != "" && version[0] >= '0' && version[0] <= '9' {
+				add(Prerequisite{Name: "node", Version: version, Source: name})
+			}
+		}
+	}
+	for _, name := range []string{"Dockerfile", "docker-compose.yml", "docker-compose.yaml", "compose.yaml", "compose.yml"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			add(Prerequisite{Name: "docker", Source: name})
+			break
+		}
+	}
+	return prerequisites, nil
+}
+
+// versionNumber matches the first version number in a tool's output
+var versionNumber = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// CheckPrerequisite finds the installed version of a tool and checks it
+// against the constraint. The tool's version flag runs directly, without a
+// shell, for at most PrerequisiteTimeout. A custom command comes from the
+// project's files, so it only runs through runCustom, which should ask for
+// approval and apply the sandbox; when runCustom is nil it isn't run.
+func CheckPrerequisite(ctx context.Context, p Prerequisite, runCustom func(command string) (string, error)) PrerequisiteStatus {
+	status := PrerequisiteStatus{Prerequisite: p}
+	if p.Command == "" && (p.Name == "" || strings.ContainsAny(p.Name, `/\`) || strings.HasPrefix(p.Name, "-")) {
+		status.Problem = fmt.Sprintf("invalid tool name %q: give a command name, not a path", p.Name)
+		return status
+	}
+	if _, err := exec.LookPath(p.Name); err != nil && p.Command == "" {
+		status.Missing = true
+		status.Problem = "not installed"
+		return status
+	}
+
+	var command string
+	var output []byte
+	var err error
+	if p.Command != "" {
+		command = p.Command
+		if runCustom == nil {
+			status.Problem = fmt.Sprintf("`%s` wasn't run: custom version commands need approval", command)
+			return status
+		}
+		var result string
+		result, err = runCustom(command)
+		output = []byte(result)
+	} else {
+		args, ok := versionArgs[p.Name]
+		if !ok {
+			args = []string{"--version"}
+		}
+		command = strings.Join(append([]string{p.Name}, args...), " ")
+		output, err = runVersionCommand(ctx, p.Name, args...)
+	}
+	if m := versionNumber.FindString(string(output)); m != "" {
+		status.Installed = m
+	}
+	if err != nil && status.Installed == "" {
+		status.Problem = fmt.Sprintf("`%s` failed: %s", command, strings.TrimSpace(truncateLine(string(output))))
+		return status
+	}
+	if p.Version == "" {
+		status.OK = true
+		return status
+	}
+	if status.Installed == "" {
+		status.Problem = fmt.Sprintf("can't tell the version from `%s`", command)
+		return status
+	}
+	ok, err := VersionSatisfies(status.Installed, p.Version)
+	if err != nil {
+		status.Problem = err.Error()
+		return status
+	}
+	status.OK = ok
+	if !ok {
+		status.Problem = fmt.Sprintf("version %s doesn't satisfy %s", status.Installed, p.Version)
+	}
+	return status
+}
+
+// runVersionCommand runs a tool's version flag, without a shell, for at most
+// PrerequisiteTimeout
+func runVersionCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, PrerequisiteTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = time.Second
+	var buffer bytes.Buffer
+	cmd.Stdout = &buffer
+	cmd.Stderr = &buffer
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return buffer.Bytes(), fmt.Errorf("timed out after %v", PrerequisiteTimeout)
+	}
+	return buffer.Bytes(), err
+}
+
+// truncateLine returns the first line of output, shortened
+func truncateLine(output string) string {
+	line := strings.SplitN(strings.TrimSpace(output), "\n", 2)[0]
+	if len(line) > 200 {
+		line = line[:200] + "..."
+	}
+	return line
+}
+
+// VersionSatisfies checks a version against a constraint: one or more
+// space-separated comparisons (>=, >, <=, <, =, ^, ~) of dotted versions, with
+// "||" between alternatives. A bare version means at least that version;
+// "1.22.x" and "v20" are accepted.
+func VersionSatisfies(version, constraint string) (bool, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" || constraint == "*" || constraint == "latest" {
+		return true, nil
+	}
+	for _, alternative := range strings.Split(constraint, "||") {
+		all := true
+		for _, comparison := range strings.Fields(alternative) {
+			ok, err := versionCompare(version, comparison)
+			if err != nil {
+				return false, err
+			}
+			all = all && ok
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// versionCompare checks a version against one comparison such as ">=1.22"
+func versionCompare(version, comparison string) (bool, error) {
+	op := strings.TrimRight(comparison, "0123456789.xX*v")
+	want := strings.TrimPrefix(comparison[len(op):], "v")
+	// Only the parts given are compared: "1.22.x" and "1.22" both mean 1.22
+	want = strings.TrimRight(strings.TrimSuffix(strings.TrimSuffix(want, ".x"), ".X"), ".")
+	if want == "" {
+		return false, fmt.Errorf("invalid version constraint %q", comparison)
+	}
+	wantParts := strings.Split(want, ".")
+	cmp := compareVersionParts(strings.Split(strings.TrimPrefix(version, "v"), "."), wantParts)
+
+	switch op {
+	case "", ">=":
+		return cmp >= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "=", "==":
+		return cmp == 0, nil
+	case "^": // same major version
+		return cmp >= 0 && compareVersionParts(strings.Split(version, "."), wantParts[:1]) == 0, nil
+	case "~": // same minor version
+		return cmp >= 0 && compareVersionParts(strings.Split(version, "."), wantParts[:min(2, len(wantParts))]) == 0, nil
+	}
+	return false, fmt.Errorf("invalid version constraint %q", comparison)
+}
+
+// compareVersionParts compares version against want on the parts want has
+func compareVersionParts(version, want []string) int {
+	for i, w := range want {
+		wn, _ := strconv.Atoi(w)
+		vn := 0
+		if i < len(version) {
+			vn, _ = strconv.Atoi(version[i])
+		}
+		if vn != wn {
+			if vn < wn {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
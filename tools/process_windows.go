@@ -0,0 +1,31 @@
+//go:build windows
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows, where only the command itself is
+// killed
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process that led the group
+func killProcessGroup(pgid int) error {
+	process, err := os.FindProcess(pgid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// processGroupAlive always reports false: background processes can't be tracked
+func processGroupAlive(pgid int) bool {
+	return false
+}
+
+// processMaxRSS is unknown on Windows
+func processMaxRSS(state *os.ProcessState) int64 {
+	return 0
+}
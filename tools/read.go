@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -8,6 +9,12 @@ import (
 	"strings"
 )
 
+// DefaultReadLines is how many lines a ranged read returns without a limit
+const DefaultReadLines = 400
+
+// maxReadLineLength cuts very long lines, such as minified code, in ranged reads
+const maxReadLineLength = 2000
+
 func ReadFile(filePath string) (string, error) {
 	if filePath == "" {
 		return "", fmt.Errorf("empty file path provided")
@@ -33,7 +40,7 @@ func ReadFile(filePath string) (string, error) {
 	// Check file size (limit to reasonable size for text files)
 	const maxFileSize = 20 * 1024 // 20KB
 	if info.Size() > maxFileSize {
-		return "", fmt.Errorf("file too large (>20KB): %s", cleanPath)
+		return "", fmt.Errorf("file too large (>20KB): %s - read it in sections with offset and limit", cleanPath)
 	}
 
 	// Check file extension for common non-text file types
@@ -61,6 +68,76 @@ func ReadFile(filePath string) (string, error) {
 	return string(content), nil
 }
 
+// ReadFileLines reads limit lines of a file from line offset (1-based), with
+// line numbers, so sections of files too large for ReadFile can be read. The
+// header gives the file's line count and the offset to continue from. A limit
+// of 0 means DefaultReadLines.
+func ReadFileLines(filePath string, offset, limit int) (string, error) {
+	if filePath == "" {
+		return "", fmt.Errorf("empty file path provided")
+	}
+	if offset < 1 {
+		offset = 1
+	}
+	if limit <= 0 {
+		limit = DefaultReadLines
+	}
+	cleanPath := filepath.Clean(filePath)
+	if isNonTextFileExtension(cleanPath) {
+		return "", fmt.Errorf("only text content files can be read. %s appears to be a non-text file", cleanPath)
+	}
+	file, err := os.Open(cleanPath)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("file does not exist: %s", cleanPath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", cleanPath, err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		return "", fmt.Errorf("path is a directory, not a file: %s", cleanPath)
+	}
+
+	reader := bufio.NewReader(file)
+	if sample, _ := reader.Peek(8192); isBinaryContent(sample) {
+		return "", fmt.Errorf("only text content files can be read. %s appears to contain binary/non-text content", cleanPath)
+	}
+	var b strings.Builder
+	total, shown := 0, 0
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			if err != io.EOF {
+				return "", fmt.Errorf("failed to read file %s: %w", cleanPath, err)
+			}
+			break
+		}
+		total++
+		if total < offset || total >= offset+limit {
+			continue
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) > maxReadLineLength {
+			line = line[:maxReadLineLength] + fmt.Sprintf(" ... [%d more characters]", len(line)-maxReadLineLength)
+		}
+		fmt.Fprintf(&b, "%6d\t%s\n", total, line)
+		shown++
+	}
+
+	if total == 0 {
+		return "[the file is empty]\n", nil
+	}
+	if shown == 0 {
+		return "", fmt.Errorf("offset %d is past the end of %s, which has %d lines", offset, cleanPath, total)
+	}
+	last := offset + shown - 1
+	header := fmt.Sprintf("[lines %d-%d of %d]", offset, last, total)
+	if last < total {
+		header = fmt.Sprintf("[lines %d-%d of %d - read more with offset=%d]", offset, last, total, last+1)
+	}
+	return header + "\n" + b.String(), nil
+}
+
 // isNonTextFileExtension checks if the file extension indicates a non-text file
 func isNonTextFileExtension(filePath string) bool {
 	// Common non-text file extensions
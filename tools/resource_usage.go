@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// ResourceUsage is what the processes of shell commands used, from the
+// rusage the system reports when they exit
+type ResourceUsage struct {
+	CPUTime   time.Duration // User plus system time, including reaped child processes
+	MaxRSS    int64         // Peak resident memory of the largest process, in bytes
+	Processes int           // Commands measured
+}
+
+// resourceUsageKey carries a *ResourceUsage in a context
+type resourceUsageKey struct{}
+
+// WithResourceUsage returns a context whose shell commands add what they use
+// to usage. A nil usage returns ctx unchanged.
+func WithResourceUsage(ctx context.Context, usage *ResourceUsage) context.Context {
+	if usage == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, resourceUsageKey{}, usage)
+}
+
+// recordResourceUsage adds an exited command's usage to the context's record, if any
+func recordResourceUsage(ctx context.Context, state *os.ProcessState) {
+	usage, ok := ctx.Value(resourceUsageKey{}).(*ResourceUsage)
+	if !ok || state == nil {
+		return
+	}
+	usage.CPUTime += state.UserTime() + state.SystemTime()
+	if rss := processMaxRSS(state); rss > usage.MaxRSS {
+		usage.MaxRSS = rss
+	}
+	usage.Processes++
+}
@@ -0,0 +1,309 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Sandbox modes for shell commands
+const (
+	SandboxOff       = "off"
+	SandboxAuto      = "auto"      // a container; refused when neither docker nor podman is installed
+	SandboxPaths     = "paths"     // best-effort path checks, with the network cut off where the OS allows
+	SandboxContainer = "container" // docker or podman without a network, with only the project mounted
+)
+
+// DefaultSandboxImage runs sandboxed commands when no image is configured
+const DefaultSandboxImage = "debian:stable-slim"
+
+// Sandbox restricts the shell commands the agent runs: they stay in the
+// project, can't write outside it and have no network access
+type Sandbox struct {
+	Mode    string // SandboxPaths or SandboxContainer
+	Root    string // Project root; the only place commands may write
+	Image   string // Container image for SandboxContainer
+	Runtime string // docker or podman for SandboxContainer
+}
+
+var (
+	// sandboxSegmentPattern splits a command line into simple commands
+	sandboxSegmentPattern = regexp.MustCompile(`&&|\|\||[;|&\n()]`)
+	// sandboxRedirectPattern matches output redirections and their targets
+	sandboxRedirectPattern = regexp.MustCompile(`\d*>>?\|?\s*([^\s;|&<>()]+)`)
+)
+
+// sandboxWriteCommands write to every path they're given; the copy-like
+// ones (sandboxTargetCommands) only to their last
+var (
+	sandboxWriteCommands  = map[string]bool{"rm": true, "rmdir": true, "mkdir": true, "touch": true, "chmod": true, "chown": true, "tee": true, "truncate": true, "shred": true, "unlink": true}
+	sandboxTargetCommands = map[string]bool{"cp": true, "mv": true, "ln": true, "install": true, "rsync": true}
+)
+
+// sandboxNetworkCommands reach the network; they are refused when the OS
+// can't cut it off
+var sandboxNetworkCommands = map[string]bool{"curl": true, "wget": true, "ssh": true, "scp": true, "sftp": true, "ftp": true, "nc": true, "ncat": true, "telnet": true}
+
+// ContainerRuntime returns docker or podman, whichever is installed, or ""
+func ContainerRuntime() string {
+	for _, runtime := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(runtime); err == nil {
+			return runtime
+		}
+	}
+	return ""
+}
+
+// NewSandbox resolves a sandbox mode for a project root. It returns nil for
+// SandboxOff and an error when a container is required but neither docker
+// nor podman is installed. SandboxAuto never falls back to path checks: they
+// only catch common ways to write outside the project, so they must be chosen
+// explicitly.
+func NewSandbox(mode, root, image string) (*Sandbox, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve project root: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if image == "" {
+		image = DefaultSandboxImage
+	}
+
+	switch mode {
+	case "", SandboxOff:
+		return nil, nil
+	case SandboxPaths:
+		return &Sandbox{Mode: SandboxPaths, Root: root}, nil
+	case SandboxContainer, SandboxAuto:
+		runtime := ContainerRuntime()
+		if runtime == "" {
+			return nil, fmt.Errorf("the sandbox needs docker or podman; install one, or choose the best-effort %q sandbox explicitly", SandboxPaths)
+		}
+		return &Sandbox{Mode: SandboxContainer, Root: root, Image: image, Runtime: runtime}, nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox mode %q (use %s, %s, %s or %s)", mode, SandboxOff, SandboxAuto, SandboxPaths, SandboxContainer)
+	}
+}
+
+// String describes the sandbox for status output
+func (s *Sandbox) String() string {
+	if s.Mode == SandboxContainer {
+		return fmt.Sprintf("%s %s, no network, only %s mounted", s.Runtime, s.Image, s.Root)
+	}
+	if networkNamespaceAvailable() {
+		return fmt.Sprintf("best-effort checks for writes outside %s, no network", s.Root)
+	}
+	return fmt.Sprintf("best-effort checks for writes outside %s, network commands refused", s.Root)
+}
+
+// Check refuses commands that would leave the project or write outside it.
+// It looks at cd targets, output redirections and the paths given to
+// commands that write files. This is best effort: writes through an
+// interpreter (sh -c, python -c, perl -i), find -delete, xargs or computed
+// paths get past it. Container sandboxes need no check: nothing outside the
+// project is mounted.
+func (s *Sandbox) Check(command string) error {
+	if s.Mode == SandboxContainer {
+		return nil
+	}
+	for _, match := range sandboxRedirectPattern.FindAllStringSubmatch(command, -1) {
+		if err := s.checkWrite(match[1], "redirect output to"); err != nil {
+			return err
+		}
+	}
+
+	for _, segment := range sandboxSegmentPattern.Split(command, -1) {
+		words := sandboxWords(segment)
+		if len(words) == 0 {
+			continue
+		}
+		name, args := filepath.Base(words[0]), words[1:]
+		switch {
+		case name == "cd" || name == "pushd":
+			target := "~"
+			if len(args) > 0 {
+				target = args[0]
+			}
+			if !s.inside(target) {
+				return fmt.Errorf("sandbox: %s %s leaves the project root %s", name, target, s.Root)
+			}
+		case sandboxWriteCommands[name]:
+			for _, arg := range args {
+				if err := s.checkWrite(arg, name); err != nil {
+					return err
+				}
+			}
+		case sandboxTargetCommands[name]:
+			if len(args) > 0 {
+				if err := s.checkWrite(args[len(args)-1], name); err != nil {
+					return err
+				}
+			}
+		case name == "sed" && hasInPlaceFlag(args):
+			for _, arg := range args[1:] {
+				if err := s.checkWrite(arg, "sed -i"); err != nil {
+					return err
+				}
+			}
+		case name == "dd":
+			for _, arg := range args {
+				if target, ok := strings.CutPrefix(arg, "of="); ok {
+					if err := s.checkWrite(target, "dd"); err != nil {
+						return err
+					}
+				}
+			}
+		case !networkNamespaceAvailable() && (sandboxNetworkCommands[name] || isGitNetworkCommand(name, args)):
+			return fmt.Errorf("sandbox: %s needs the network, which is blocked", strings.Join(words[:min(len(words), 2)], " "))
+		}
+	}
+	return nil
+}
+
+// checkWrite refuses a write to a path outside the project root
+func (s *Sandbox) checkWrite(path, action string) error {
+	if strings.HasPrefix(path, "-") || strings.HasPrefix(path, "&") {
+		return nil
+	}
+	switch path {
+	case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return nil
+	}
+	if !s.inside(path) {
+		return fmt.Errorf("sandbox: %s %s is outside the project root %s", action, path, s.Root)
+	}
+	return nil
+}
+
+// inside reports whether a path, relative to the working directory, is in
+// the project root
+func (s *Sandbox) inside(path string) bool {
+	if home, err := os.UserHomeDir(); err == nil {
+		if path == "~" || strings.HasPrefix(path, "~/") {
+			path = home + path[1:]
+		}
+		path = strings.NewReplacer("$HOME", home, "${HOME}", home).Replace(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	abs = resolveExisting(abs)
+	rel, err := filepath.Rel(s.Root, abs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveExisting follows the symlinks in the part of a path that exists, so
+// a link inside the project can't point a write outside it
+func resolveExisting(path string) string {
+	var rest []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return path
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// sandboxWords splits a simple command into words, without quotes, leading
+// variable assignments or sudo
+func sandboxWords(segment string) []string {
+	var words []string
+	for _, word := range strings.Fields(segment) {
+		word = strings.Trim(word, `'"`)
+		if len(words) == 0 && (word == "sudo" || word == "env" || word == "command" || strings.Contains(word, "=")) {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// hasInPlaceFlag reports whether sed arguments edit files in place
+func hasInPlaceFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "--in-place" || (strings.HasPrefix(arg, "-i") && !strings.HasPrefix(arg, "--")) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGitNetworkCommand reports whether a git command talks to a remote
+func isGitNetworkCommand(name string, args []string) bool {
+	if name != "git" || len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "clone", "fetch", "pull", "push", "ls-remote", "submodule":
+		return true
+	}
+	return false
+}
+
+var (
+	networkNamespaceOnce sync.Once
+	networkNamespaceOK   bool
+)
+
+// networkNamespaceAvailable reports whether commands can run in a network
+// namespace of their own, which has no network. It needs Linux user
+// namespaces, which some kernels and containers disable.
+func networkNamespaceAvailable() bool {
+	networkNamespaceOnce.Do(func() {
+		if _, err := exec.LookPath("unshare"); err != nil {
+			return
+		}
+		networkNamespaceOK = exec.Command("unshare", "--user", "--map-root-user", "--net", "true").Run() == nil
+	})
+	return networkNamespaceOK
+}
+
+// ExecuteSandboxedCommand runs a shell command in a sandbox. Path sandboxes
+// check the command first and run it without network where the OS allows;
+// container sandboxes run it in a throwaway container that only has the
+// project mounted.
+func ExecuteSandboxedCommand(ctx context.Context, command string, sandbox *Sandbox) (string, error) {
+	if sandbox == nil {
+		return ExecuteShellCommand(ctx, command)
+	}
+	if strings.TrimSpace(command) == "" {
+		return "", fmt.Errorf("empty command provided")
+	}
+	if err := sandbox.Check(command); err != nil {
+		return "", err
+	}
+
+	if sandbox.Mode == SandboxContainer {
+		return runShellCommand(ctx, sandbox.Runtime, sandbox.containerArgs(command)...)
+	}
+	if networkNamespaceAvailable() {
+		return runShellCommand(ctx, "unshare", "--user", "--map-root-user", "--net", userShell(), "-c", command)
+	}
+	return ExecuteShellCommand(ctx, command)
+}
+
+// containerArgs builds the run arguments for a sandboxed command. The
+// project is mounted at the same path, so paths in output match the host.
+func (s *Sandbox) containerArgs(command string) []string {
+	dir, err := os.Getwd()
+	if err != nil || !s.inside(dir) {
+		dir = s.Root
+	}
+	args := []string{"run", "--rm", "-i", "--network", "none", "-v", s.Root + ":" + s.Root, "-w", dir}
+	// Files the command creates belong to the user, not root
+	if uid, gid := os.Getuid(), os.Getgid(); uid > 0 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, gid))
+	}
+	return append(args, s.Image, "sh", "-c", command)
+}
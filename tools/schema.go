@@ -0,0 +1,301 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSchemaItems caps how many messages, paths or schemas a summary lists
+const maxSchemaItems = 200
+
+// IsSchemaFile reports whether a file may be a .proto or OpenAPI spec
+func IsSchemaFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".proto", ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// SummarizeSchema parses a .proto file or an OpenAPI/Swagger spec into a
+// structured summary of its services, messages, endpoints and schemas
+func SummarizeSchema(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	content := string(data)
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".proto":
+		return SummarizeProto(content), nil
+	case ".json":
+		var doc map[string]interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", fmt.Errorf("%s is not valid JSON: %w", filePath, err)
+		}
+		if doc["openapi"] == nil && doc["swagger"] == nil {
+			return "", fmt.Errorf("%s is not an OpenAPI spec (no openapi or swagger field)", filePath)
+		}
+		return summarizeOpenAPI(openAPIFromJSON(doc)), nil
+	case ".yaml", ".yml":
+		spec := openAPIFromYAML(content)
+		if spec.version == "" {
+			return "", fmt.Errorf("%s is not an OpenAPI spec (no openapi or swagger field)", filePath)
+		}
+		return summarizeOpenAPI(spec), nil
+	}
+	return "", fmt.Errorf("%s is not a .proto file or an OpenAPI spec", filePath)
+}
+
+// protoComment strips // and single-line /* */ comments
+var protoComment = regexp.MustCompile(`//.*$|/\*.*?\*/`)
+
+// protoRPC matches an rpc declaration
+var protoRPC = regexp.MustCompile(`^rpc\s+(\w+)\s*\(\s*(stream\s+)?([\w.]+)\s*\)\s*returns\s*\(\s*(stream\s+)?([\w.]+)\s*\)`)
+
+// protoField matches a message field: [label] type name = number
+var protoField = regexp.MustCompile(`^((?:repeated|optional|required)\s+)?(map<[^>]+>|[\w.]+)\s+(\w+)\s*=\s*(\d+)`)
+
+// protoEnumValue matches an enum value: NAME = number
+var protoEnumValue = regexp.MustCompile(`^(\w+)\s*=\s*(-?\d+)`)
+
+// SummarizeProto summarizes a .proto file: package, imports, services with
+// their RPCs, and messages and enums with their fields
+func SummarizeProto(content string) string {
+	var b strings.Builder
+	type scope struct{ kind, name string }
+	var stack []scope
+	inBlockComment := false
+	items := 0
+
+	for _, line := range strings.Split(content, "\n") {
+		if inBlockComment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				continue
+			}
+			line = line[end+2:]
+			inBlockComment = false
+		}
+		line = protoComment.ReplaceAllString(line, "")
+		if start := strings.Index(line, "/*"); start >= 0 {
+			line = line[:start]
+			inBlockComment = true
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		indent := strings.Repeat("  ", len(stack))
+		fields := strings.Fields(line)
+		opened := scope{kind: "block"} // what a { on this line opens
+
+		switch {
+		case fields[0] == "syntax" || fields[0] == "edition":
+			fmt.Fprintf(&b, "%s\n", strings.TrimSuffix(line, ";"))
+		case fields[0] == "package" && len(fields) > 1:
+			fmt.Fprintf(&b, "package %s\n", strings.TrimSuffix(fields[1], ";"))
+		case fields[0] == "import":
+			fmt.Fprintf(&b, "%s\n", strings.TrimSuffix(line, ";"))
+		case (fields[0] == "service" || fields[0] == "message" || fields[0] == "enum" || fields[0] == "oneof") && len(fields) > 1:
+			opened = scope{fields[0], strings.TrimSuffix(fields[1], "{")}
+			if items < maxSchemaItems {
+				fmt.Fprintf(&b, "%s%s %s\n", indent, opened.kind, opened.name)
+			}
+			items++
+		case len(stack) == 0:
+			// options and extensions at the top level
+		case fields[0] == "rpc":
+			if m := protoRPC.FindStringSubmatch(line); m != nil && items < maxSchemaItems {
+				fmt.Fprintf(&b, "%s%s(%s%s) returns (%s%s)\n", indent, m[1], m[2], m[3], m[4], m[5])
+			}
+		case stack[len(stack)-1].kind == "enum":
+			if m := protoEnumValue.FindStringSubmatch(line); m != nil && items < maxSchemaItems {
+				fmt.Fprintf(&b, "%s%s = %s\n", indent, m[1], m[2])
+			}
+		case stack[len(stack)-1].kind == "message" || stack[len(stack)-1].kind == "oneof":
+			if m := protoField.FindStringSubmatch(line); m != nil && items < maxSchemaItems {
+				fmt.Fprintf(&b, "%s%s%s %s = %s\n", indent, m[1], m[2], m[3], m[4])
+			} else if strings.HasPrefix(line, "reserved") && items < maxSchemaItems {
+				fmt.Fprintf(&b, "%s%s\n", indent, strings.TrimSuffix(line, ";"))
+			}
+		}
+
+		// Option blocks like option (google.api.http) = {...} nest too
+		for depth := strings.Count(line, "{") - strings.Count(line, "}"); depth != 0; {
+			if depth > 0 {
+				stack = append(stack, opened)
+				opened = scope{kind: "block"}
+				depth--
+			} else {
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+				depth++
+			}
+		}
+	}
+	if items > maxSchemaItems {
+		fmt.Fprintf(&b, "... (%d more declarations)\n", items-maxSchemaItems)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// openAPISpec is the part of an OpenAPI spec a summary shows
+type openAPISpec struct {
+	version    string
+	title      string
+	apiVersion string
+	operations []string // "GET /users/{id} getUser - Fetch a user"
+	schemas    []string
+}
+
+// httpMethods are the operation keys of an OpenAPI path item
+var httpMethods = map[string]bool{"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true}
+
+// openAPIFromJSON reads the summary fields of a JSON spec
+func openAPIFromJSON(doc map[string]interface{}) openAPISpec {
+	var spec openAPISpec
+	spec.version = fmt.Sprint(firstNonNil(doc["openapi"], doc["swagger"]))
+	if info, ok := doc["info"].(map[string]interface{}); ok {
+		spec.title, _ = info["title"].(string)
+		spec.apiVersion = fmt.Sprint(firstNonNil(info["version"], ""))
+	}
+	if paths, ok := doc["paths"].(map[string]interface{}); ok {
+		for path, item := range paths {
+			operations, _ := item.(map[string]interface{})
+			for method, raw := range operations {
+				if !httpMethods[method] {
+					continue
+				}
+				operation, _ := raw.(map[string]interface{})
+				id, _ := operation["operationId"].(string)
+				summary, _ := operation["summary"].(string)
+				spec.operations = append(spec.operations, formatOperation(method, path, id, summary))
+			}
+		}
+	}
+	schemas, _ := doc["definitions"].(map[string]interface{})
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		schemas, _ = components["schemas"].(map[string]interface{})
+	}
+	for name := range schemas {
+		spec.schemas = append(spec.schemas, name)
+	}
+	return spec
+}
+
+// openAPIFromYAML reads the summary fields of a YAML spec by indentation,
+// which is enough for the block style specs are written in
+func openAPIFromYAML(content string) openAPISpec {
+	var spec openAPISpec
+	var path []string // keys of the enclosing mappings
+	var indents []int
+	var operation struct{ method, path, id, summary string }
+	flush := func() {
+		if operation.method != "" {
+			spec.operations = append(spec.operations, formatOperation(operation.method, operation.path, operation.id, operation.summary))
+		}
+		operation.method = ""
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			continue
+		}
+		// Keys may contain colons, e.g. /v1/{name}:cancel
+		key, value := strings.TrimSuffix(trimmed, ":"), ""
+		if !strings.HasSuffix(trimmed, ":") {
+			var ok bool
+			if key, value, ok = strings.Cut(trimmed, ": "); !ok {
+				continue // not a key, e.g. a line of a block scalar
+			}
+		}
+		key = strings.Trim(key, `"'`)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for len(indents) > 0 && indents[len(indents)-1] >= indent {
+			indents = indents[:len(indents)-1]
+			path = path[:len(path)-1]
+		}
+
+		switch {
+		case len(path) == 0 && (key == "openapi" || key == "swagger"):
+			spec.version = value
+		case len(path) == 1 && path[0] == "info" && key == "title":
+			spec.title = value
+		case len(path) == 1 && path[0] == "info" && key == "version":
+			spec.apiVersion = value
+		case len(path) == 2 && path[0] == "paths" && httpMethods[key]:
+			flush()
+			operation.method, operation.path, operation.id, operation.summary = key, path[1], "", ""
+		case len(path) == 3 && path[0] == "paths" && key == "operationId":
+			operation.id = value
+		case len(path) == 3 && path[0] == "paths" && key == "summary":
+			operation.summary = value
+		case len(path) == 2 && path[0] == "components" && path[1] == "schemas",
+			len(path) == 1 && path[0] == "definitions":
+			spec.schemas = append(spec.schemas, key)
+		}
+		if value == "" || value == "|" || value == ">" {
+			path = append(path, key)
+			indents = append(indents, indent)
+		}
+	}
+	flush()
+	return spec
+}
+
+// formatOperation formats one endpoint for a summary
+func formatOperation(method, path, id, summary string) string {
+	line := strings.ToUpper(method) + " " + path
+	if id != "" {
+		line += " " + id
+	}
+	if summary != "" {
+		line += " - " + summary
+	}
+	return line
+}
+
+// summarizeOpenAPI formats a spec's endpoints and schemas
+func summarizeOpenAPI(spec openAPISpec) string {
+	sort.Strings(spec.operations)
+	sort.Strings(spec.schemas)
+	var b strings.Builder
+	fmt.Fprintf(&b, "OpenAPI %s: %s %s\n", spec.version, spec.title, spec.apiVersion)
+	fmt.Fprintf(&b, "\nENDPOINTS (%d):\n", len(spec.operations))
+	for i, operation := range spec.operations {
+		if i == maxSchemaItems {
+			fmt.Fprintf(&b, "... (%d more)\n", len(spec.operations)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n", operation)
+	}
+	fmt.Fprintf(&b, "\nSCHEMAS (%d):\n", len(spec.schemas))
+	for i, schema := range spec.schemas {
+		if i == maxSchemaItems {
+			fmt.Fprintf(&b, "... (%d more)\n", len(spec.schemas)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n", schema)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// firstNonNil returns the first value that isn't nil
+func firstNonNil(values ...interface{}) interface{} {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultSearchResults bounds the matches search_code returns
+	DefaultSearchResults = 100
+	// maxSearchLineLength cuts long matched lines, such as minified code
+	maxSearchLineLength = 240
+	// maxSearchFileSize skips larger files, which are rarely source code
+	maxSearchFileSize = 1 << 20
+)
+
+// SearchOptions narrow a code search. Include and exclude patterns work like
+// those of ListOptions.
+type SearchOptions struct {
+	IgnoreCase bool
+	Literal    bool     // The pattern is plain text, not a regular expression
+	Include    []string // Search only files matching one of these
+	Exclude    []string // Skip files and directories matching any of these
+	MaxResults int      // Matches before the search stops (0 = DefaultSearchResults)
+}
+
+// SearchMatch is a line matching a code search
+type SearchMatch struct {
+	File string // Slash-separated, relative to the searched directory
+	Line int
+	Text string // The line, trimmed and cut at maxSearchLineLength
+}
+
+// SearchResult holds the matches of a code search in file and line order
+type SearchResult struct {
+	Matches   []SearchMatch
+	Truncated bool   // More lines matched than MaxResults
+	Engine    string // "ripgrep", or "go" when rg isn't installed
+}
+
+// SearchCode finds the lines matching a regular expression in the files
+// under dir, or in dir itself if it is a file. Files git ignores, binary
+// files and files over 1 MB are skipped. It uses ripgrep when it is
+// installed and searches in Go otherwise.
+func SearchCode(dir, pattern string, opts SearchOptions) (SearchResult, error) {
+	if pattern == "" {
+		return SearchResult{}, fmt.Errorf("search_code needs a pattern")
+	}
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = DefaultSearchResults
+	}
+	expression := pattern
+	if opts.Literal {
+		expression = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		expression = "(?i)" + expression
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("invalid search pattern %q: %w (set literal to search for plain text)", pattern, err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("cannot search %s: %w", dir, err)
+	}
+	if _, err := exec.LookPath("rg"); err == nil {
+		if result, err := searchWithRipgrep(dir, info.IsDir(), pattern, opts); err == nil {
+			return result, nil
+		}
+	}
+	return searchInGo(dir, info.IsDir(), re, opts)
+}
+
+// searchWithRipgrep runs rg with JSON output and collects its matches
+func searchWithRipgrep(dir string, isDir bool, pattern string, opts SearchOptions) (SearchResult, error) {
+	args := []string{"--json", "--sort", "path", "--hidden", "--glob", "!.git", "--max-filesize", "1M", "--no-require-git"}
+	if opts.IgnoreCase {
+		args = append(args, "--ignore-case")
+	}
+	if opts.Literal {
+		args = append(args, "--fixed-strings")
+	}
+	for _, include := range opts.Include {
+		args = append(args, "--glob", include)
+	}
+	for _, exclude := range opts.Exclude {
+		args = append(args, "--glob", "!"+exclude)
+	}
+	// An explicit path keeps rg from reading stdin
+	cmdDir, target := dir, "."
+	if !isDir {
+		cmdDir, target = filepath.Dir(dir), filepath.Base(dir)
+	}
+	cmd := exec.Command("rg", append(args, "-e", pattern, "--", target)...)
+	cmd.Dir = cmdDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return SearchResult{}, err
+	}
+
+	result := SearchResult{Engine: "ripgrep"}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type string `json:"type"`
+			Data struct {
+				Path       struct{ Text string } `json:"path"`
+				Lines      struct{ Text string } `json:"lines"`
+				LineNumber int                   `json:"line_number"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Type != "match" || event.Data.Path.Text == "" {
+			continue
+		}
+		file := filepath.ToSlash(strings.TrimPrefix(event.Data.Path.Text, "./"))
+		if isDir && (matchesAny(opts.Exclude, file, true) || len(opts.Include) > 0 && !matchesAny(opts.Include, file, false)) {
+			continue
+		}
+		if len(result.Matches) == opts.MaxResults {
+			result.Truncated = true
+			break
+		}
+		result.Matches = append(result.Matches, SearchMatch{File: file, Line: event.Data.LineNumber, Text: searchSnippet(event.Data.Lines.Text)})
+	}
+	if result.Truncated {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return result, nil
+	}
+	// rg exits with 1 when nothing matched
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			return SearchResult{}, fmt.Errorf("rg: %s", strings.TrimSpace(stderr.String()))
+		}
+	}
+	return result, nil
+}
+
+// searchInGo scans the files listFiles finds, in path order
+func searchInGo(dir string, isDir bool, re *regexp.Regexp, opts SearchOptions) (SearchResult, error) {
+	var files []listedFile
+	root := dir
+	if isDir {
+		listed, err := listFiles(dir)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		files = filterListed(listed, ListOptions{Include: opts.Include, Exclude: opts.Exclude})
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	} else {
+		root = filepath.Dir(dir)
+		files = []listedFile{{Path: filepath.Base(dir)}}
+	}
+
+	result := SearchResult{Engine: "go"}
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file.Path)))
+		if err != nil || len(content) > maxSearchFileSize || bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+			continue
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			if len(result.Matches) == opts.MaxResults {
+				result.Truncated = true
+				return result, nil
+			}
+			result.Matches = append(result.Matches, SearchMatch{File: file.Path, Line: i + 1, Text: searchSnippet(line)})
+		}
+	}
+	return result, nil
+}
+
+// searchSnippet trims a matched line and cuts it on a character boundary
+func searchSnippet(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) <= maxSearchLineLength {
+		return line
+	}
+	cut := maxSearchLineLength
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + " ..."
+}
+
+// FormatSearchResult renders matches grouped by file, one "line: text" per match
+func FormatSearchResult(dir, pattern string, result SearchResult) string {
+	if len(result.Matches) == 0 {
+		return fmt.Sprintf("No matches for %q under %s", pattern, dir)
+	}
+	files := 0
+	for i, match := range result.Matches {
+		if i == 0 || result.Matches[i-1].File != match.File {
+			files++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d match(es) for %q in %d file(s) under %s", len(result.Matches), pattern, files, dir)
+	for i, match := range result.Matches {
+		if i == 0 || result.Matches[i-1].File != match.File {
+			fmt.Fprintf(&b, "\n%s", match.File)
+		}
+		fmt.Fprintf(&b, "\n  %d: %s", match.Line, match.Text)
+	}
+	if result.Truncated {
+		fmt.Fprintf(&b, "\n... stopped at %d matches - narrow the pattern, the path or the include patterns", len(result.Matches))
+	}
+	return b.String()
+}
@@ -1,6 +1,9 @@
 package tools
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,46 +12,65 @@ import (
 	"time"
 )
 
-func ExecuteShellCommand(command string) (string, error) {
+// shellTimeout bounds how long a single command may run
+const shellTimeout = 60 * time.Second // Increased from 30s to 60s for longer operations
+
+// ExecuteShellCommand runs a command in the user's shell and returns its
+// combined output. The command is killed when ctx is cancelled, for example
+// by Ctrl+C, or when it runs past the timeout.
+func ExecuteShellCommand(ctx context.Context, command string) (string, error) {
 	if strings.TrimSpace(command) == "" {
 		return "", fmt.Errorf("empty command provided")
 	}
 
-	// Create command with timeout
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/sh"
-	}
-	cmd := exec.Command(shell, "-c", command)
-
-	// Set up timeout
-	timeout := 60 * time.Second // Increased from 30s to 60s for longer operations
+	return runShellCommand(ctx, userShell(), "-c", command)
+}
 
-	done := make(chan error, 1)
-	var output []byte
-	var err error
+// userShell returns the user's shell, or /bin/sh
+func userShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
 
-	go func() {
-		output, err = cmd.CombinedOutput()
-		done <- err
-	}()
+// runShellCommand runs a program with the shell command timeout and returns
+// its combined output, with errors in ExecuteShellCommand's form. The program
+// runs in a process group of its own: a timeout or cancel kills everything it
+// started, and processes it leaves running are listed by Jobs.
+func runShellCommand(ctx context.Context, name string, args ...string) (string, error) {
+	runCtx, cancel := context.WithTimeout(ctx, shellTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, name, args...)
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process.Pid) }
+	// Background processes the command started can hold its output open after
+	// it exits; stop waiting for them shortly after
+	cmd.WaitDelay = time.Second
+	var buffer bytes.Buffer
+	cmd.Stdout = &buffer
+	cmd.Stderr = &buffer
 
-	select {
-	case err := <-done:
-		if err != nil {
-			// Check if it's an exit error (command ran but failed)
-			if exitError, ok := err.(*exec.ExitError); ok {
-				if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
-					return string(output), fmt.Errorf("command failed with exit code %d: %s", status.ExitStatus(), string(output))
-				}
+	job, err := startJob(cmd, args[len(args)-1])
+	if err == nil {
+		err = cmd.Wait()
+		finishJob(job)
+		recordResourceUsage(ctx, cmd.ProcessState)
+	}
+	output := buffer.Bytes()
+	if ctx.Err() != nil {
+		return string(output), fmt.Errorf("command cancelled: %w", ctx.Err())
+	}
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("command timed out after %v", shellTimeout)
+	}
+	if err != nil {
+		// Check if it's an exit error (command ran but failed)
+		if exitError, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitError.Sys().(syscall.WaitStatus); ok {
+				return string(output), fmt.Errorf("command failed with exit code %d: %s", status.ExitStatus(), string(output))
 			}
-			return string(output), fmt.Errorf("command failed: %w", err)
-		}
-		return string(output), nil
-	case <-time.After(timeout):
-		if cmd.Process != nil {
-			cmd.Process.Kill()
 		}
-		return "", fmt.Errorf("command timed out after %v", timeout)
+		return string(output), fmt.Errorf("command failed: %w", err)
 	}
+	return string(output), nil
 }
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// maxSpokenChars keeps spoken notifications short enough to listen to
+const maxSpokenChars = 400
+
+// speechMarkupPattern matches markdown and symbols that speech engines read out literally
+var speechMarkupPattern = regexp.MustCompile("[*_#`>|\\[\\]]+|[\U0001F300-\U0001FAFF☀-➿️]")
+
+// speechCommand returns the OS speech engine invocation for text
+func speechCommand(text string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("say", text), nil
+	case "windows":
+		script := "Add-Type -AssemblyName System.Speech; (New-Object System.Speech.Synthesis.SpeechSynthesizer).Speak($args[0])"
+		return exec.Command("powershell", "-NoProfile", "-Command", script, text), nil
+	}
+	for _, engine := range []string{"spd-say", "espeak-ng", "espeak"} {
+		if _, err := exec.LookPath(engine); err == nil {
+			if engine == "spd-say" {
+				return exec.Command(engine, "--wait", text), nil
+			}
+			return exec.Command(engine, text), nil
+		}
+	}
+	return nil, fmt.Errorf("no speech engine found - install speech-dispatcher (spd-say) or espeak")
+}
+
+// SpeakableText strips markup and shortens text for a spoken notification
+func SpeakableText(text string) string {
+	text = speechMarkupPattern.ReplaceAllString(text, "")
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= maxSpokenChars {
+		return text
+	}
+	// Cut at the last sentence end that fits
+	cut := text[:maxSpokenChars]
+	if end := strings.LastIndexAny(cut, ".!?"); end > maxSpokenChars/2 {
+		return cut[:end+1]
+	}
+	return cut + "..."
+}
+
+// Speak reads text aloud with the OS speech engine without blocking the caller
+func Speak(text string) error {
+	text = SpeakableText(text)
+	if text == "" {
+		return nil
+	}
+	cmd, err := speechCommand(text)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start speech engine: %w", err)
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,256 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Target is a task a project defines for developers: a make target, a Taskfile
+// task, a package.json script or a mage target
+type Target struct {
+	Source      string // file it is defined in
+	Name        string
+	Description string
+	Command     string // how to run it
+}
+
+// DiscoverTargets lists the targets of the Makefile, Taskfile, package.json
+// and magefiles in root
+func DiscoverTargets(root string) []Target {
+	var targets []Target
+	for _, name := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			targets = append(targets, parseMakefile(name, string(data))...)
+			break // make uses the first one it finds
+		}
+	}
+	for _, name := range []string{"Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml"} {
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			targets = append(targets, parseTaskfile(name, string(data))...)
+			break
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		targets = append(targets, parsePackageScripts(root, data)...)
+	}
+	targets = append(targets, discoverMageTargets(root)...)
+	return targets
+}
+
+// makeRule matches a rule line: "targets: prerequisites ## description"
+var makeRule = regexp.MustCompile(`^([^\s:#=][^:#=]*?)\s*:([^=].*)?$`)
+
+// parseMakefile lists a Makefile's explicit targets. Descriptions come from a
+// "## ..." comment on the rule line or the comment lines right above it.
+func parseMakefile(source, content string) []Target {
+	var targets []Target
+	seen := make(map[string]bool)
+	var comments []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			continue // recipe
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			comments = append(comments, strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+			continue
+		}
+		m := makeRule.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(m[2], ":=") {
+			comments = nil // not a rule, or a ::= assignment
+			continue
+		}
+		if strings.HasPrefix(m[1], ".") {
+			continue // .PHONY and other special targets; a comment above them describes the next rule
+		}
+		description := strings.Join(comments, " ")
+		if _, inline, ok := strings.Cut(m[2], "##"); ok {
+			description = strings.TrimSpace(inline)
+		}
+		comments = nil
+		for _, name := range strings.Fields(m[1]) {
+			// Pattern rules and variable targets aren't run by name
+			if strings.ContainsAny(name, "%$()") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			targets = append(targets, Target{Source: source, Name: name, Description: description, Command: "make " + name})
+		}
+	}
+	return targets
+}
+
+// parseTaskfile lists the tasks of a Taskfile by indentation, with their desc
+// or summary; internal tasks are left out
+func parseTaskfile(source, content string) []Target {
+	var targets []Target
+	inTasks := false
+	taskIndent := -1
+	var current *Target
+	internal := false
+	flush := func() {
+		if current != nil && !internal {
+			targets = append(targets, *current)
+		}
+		current, internal = nil, false
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 {
+			flush()
+			inTasks = strings.TrimSpace(trimmed) == "tasks:"
+			taskIndent = -1
+			continue
+		}
+		if !inTasks {
+			continue
+		}
+		if taskIndent < 0 {
+			taskIndent = indent
+		}
+		// Task names may contain colons, e.g. docker:build
+		key, value := strings.TrimSuffix(trimmed, ":"), ""
+		if !strings.HasSuffix(trimmed, ":") {
+			key, value, _ = strings.Cut(trimmed, ": ")
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch {
+		case indent == taskIndent:
+			flush()
+			current = &Target{Source: source, Name: key, Command: "task " + key}
+		case current != nil && indent > taskIndent && (key == "desc" || key == "summary" && current.Description == ""):
+			if value != "|" && value != ">" {
+				current.Description = value
+			}
+		case current != nil && key == "internal" && value == "true":
+			internal = true
+		}
+	}
+	flush()
+	return targets
+}
+
+// parsePackageScripts lists package.json scripts, run with the package manager
+// the lockfile shows
+func parsePackageScripts(root string, data []byte) []Target {
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return nil
+	}
+	runner := "npm run"
+	for _, lock := range []struct{ file, runner string }{
+		{"pnpm-lock.yaml", "pnpm run"},
+		{"yarn.lock", "yarn run"},
+		{"bun.lockb", "bun run"},
+		{"bun.lock", "bun run"},
+	} {
+		if _, err := os.Stat(filepath.Join(root, lock.file)); err == nil {
+			runner = lock.runner
+			break
+		}
+	}
+
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var targets []Target
+	for _, name := range names {
+		// pre/post hooks run with their script, not on their own
+		if base := strings.TrimPrefix(strings.TrimPrefix(name, "pre"), "post"); base != name {
+			if _, ok := pkg.Scripts[base]; ok {
+				continue
+			}
+		}
+		targets = append(targets, Target{Source: "package.json", Name: name, Description: pkg.Scripts[name], Command: runner + " " + name})
+	}
+	return targets
+}
+
+// mageFunc matches an exported mage target, with an optional namespace receiver
+var mageFunc = regexp.MustCompile(`^func\s+(?:\(\s*(?:\w+\s+)?(\w+)\s*\)\s*)?([A-Z]\w*)\s*\(`)
+
+// mageNamespace matches a namespace type declaration
+var mageNamespace = regexp.MustCompile(`^type\s+(\w+)\s+mg\.Namespace\b`)
+
+// discoverMageTargets lists the targets of the magefiles in root and magefiles/
+func discoverMageTargets(root string) []Target {
+	var files []string
+	for _, dir := range []string{root, filepath.Join(root, "magefiles")} {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+		files = append(files, matches...)
+	}
+
+	var targets []Target
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		inMagefiles := filepath.Base(filepath.Dir(file)) == "magefiles"
+		if !inMagefiles && !strings.Contains(content, "//go:build mage") && !strings.Contains(content, "// +build mage") {
+			continue
+		}
+		source, _ := filepath.Rel(root, file)
+		namespaces := make(map[string]bool)
+		for _, line := range strings.Split(content, "\n") {
+			if m := mageNamespace.FindStringSubmatch(line); m != nil {
+				namespaces[m[1]] = true
+			}
+		}
+
+		var comments []string
+		for _, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "//go:") && !strings.HasPrefix(line, "// +build") {
+				comments = append(comments, strings.TrimSpace(strings.TrimPrefix(line, "//")))
+				continue
+			}
+			m := mageFunc.FindStringSubmatch(line)
+			if m != nil && (m[1] == "" || namespaces[m[1]]) {
+				name := strings.ToLower(m[2][:1]) + m[2][1:]
+				if m[1] != "" {
+					name = strings.ToLower(m[1][:1]) + m[1][1:] + ":" + name
+				}
+				targets = append(targets, Target{Source: source, Name: name, Description: strings.Join(comments, " "), Command: "mage " + name})
+			}
+			comments = nil
+		}
+	}
+	return targets
+}
+
+// FormatTargets lists targets grouped by the file defining them
+func FormatTargets(targets []Target) string {
+	if len(targets) == 0 {
+		return "No Makefile, Taskfile, package.json scripts or magefile targets found."
+	}
+	var b strings.Builder
+	source := ""
+	for _, target := range targets {
+		if target.Source != source {
+			source = target.Source
+			fmt.Fprintf(&b, "\n%s:\n", source)
+		}
+		fmt.Fprintf(&b, "  %s", target.Command)
+		if target.Description != "" {
+			fmt.Fprintf(&b, " - %s", target.Description)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
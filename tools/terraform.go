@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// maxPlanResources caps how many addresses a plan summary lists per kind of change
+const maxPlanResources = 50
+
+// TerraformBinary returns terraform, or OpenTofu's tofu when only that is installed
+func TerraformBinary() (string, error) {
+	for _, binary := range []string{"terraform", "tofu"} {
+		if _, err := exec.LookPath(binary); err == nil {
+			return binary, nil
+		}
+	}
+	return "", fmt.Errorf("neither terraform nor tofu is installed")
+}
+
+// TerraformPlan is a plan's resource changes by kind, as resource addresses
+type TerraformPlan struct {
+	Creates  []string
+	Updates  []string
+	Replaces []string // destroyed and recreated
+	Deletes  []string
+	Reads    []string // data sources read during apply
+	Outputs  []string // changed outputs
+}
+
+// ParseTerraformPlan reads the output of terraform show -json <planfile>
+func ParseTerraformPlan(data []byte) (*TerraformPlan, error) {
+	var raw struct {
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+		OutputChanges map[string]struct {
+			Actions []string `json:"actions"`
+		} `json:"output_changes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+
+	plan := &TerraformPlan{}
+	for _, change := range raw.ResourceChanges {
+		switch strings.Join(change.Change.Actions, ",") {
+		case "create":
+			plan.Creates = append(plan.Creates, change.Address)
+		case "update":
+			plan.Updates = append(plan.Updates, change.Address)
+		case "delete,create", "create,delete":
+			plan.Replaces = append(plan.Replaces, change.Address)
+		case "delete":
+			plan.Deletes = append(plan.Deletes, change.Address)
+		case "read":
+			plan.Reads = append(plan.Reads, change.Address)
+		}
+	}
+	for name, change := range raw.OutputChanges {
+		if len(change.Actions) > 0 && change.Actions[0] != "no-op" {
+			plan.Outputs = append(plan.Outputs, name)
+		}
+	}
+	sort.Strings(plan.Outputs)
+	return plan, nil
+}
+
+// Destructive reports whether applying the plan destroys any resource
+func (p *TerraformPlan) Destructive() bool {
+	return len(p.Deletes) > 0 || len(p.Replaces) > 0
+}
+
+// Destroyed returns the addresses of the resources applying the plan destroys
+func (p *TerraformPlan) Destroyed() []string {
+	return append(append([]string{}, p.Deletes...), p.Replaces...)
+}
+
+// Empty reports whether the plan changes nothing
+func (p *TerraformPlan) Empty() bool {
+	return len(p.Creates)+len(p.Updates)+len(p.Replaces)+len(p.Deletes)+len(p.Outputs) == 0
+}
+
+// Summary lists the plan's changes, the destructive ones first
+func (p *TerraformPlan) Summary() string {
+	if p.Empty() {
+		return "No changes. The infrastructure matches the configuration."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan: %d to add, %d to change, %d to replace, %d to destroy.\n",
+		len(p.Creates), len(p.Updates), len(p.Replaces), len(p.Deletes))
+	for _, group := range []struct {
+		label     string
+		addresses []string
+	}{
+		{"DESTROY", p.Deletes},
+		{"REPLACE (destroy, then create)", p.Replaces},
+		{"UPDATE in place", p.Updates},
+		{"CREATE", p.Creates},
+		{"READ", p.Reads},
+		{"OUTPUTS changed", p.Outputs},
+	} {
+		if len(group.addresses) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", group.label, len(group.addresses))
+		for i, address := range group.addresses {
+			if i == maxPlanResources {
+				fmt.Fprintf(&b, "  ... (%d more)\n", len(group.addresses)-i)
+				break
+			}
+			fmt.Fprintf(&b, "  %s\n", address)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// TerraformDiagnostics formats the diagnostics of terraform validate -json
+// as "error: summary (file:line)" lines
+func TerraformDiagnostics(data []byte) (valid bool, diagnostics string, err error) {
+	var raw struct {
+		Valid       bool `json:"valid"`
+		Diagnostics []struct {
+			Severity string `json:"severity"`
+			Summary  string `json:"summary"`
+			Detail   string `json:"detail"`
+			Range    *struct {
+				Filename string `json:"filename"`
+				Start    struct {
+					Line int `json:"line"`
+				} `json:"start"`
+			} `json:"range"`
+		} `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return false, "", fmt.Errorf("failed to parse validate output: %w", err)
+	}
+	var lines []string
+	for _, d := range raw.Diagnostics {
+		line := fmt.Sprintf("%s: %s", d.Severity, d.Summary)
+		if d.Range != nil {
+			line += fmt.Sprintf(" (%s:%d)", d.Range.Filename, d.Range.Start.Line)
+		}
+		if d.Detail != "" {
+			line += "\n  " + strings.ReplaceAll(d.Detail, "\n", "\n  ")
+		}
+		lines = append(lines, line)
+	}
+	return raw.Valid, strings.Join(lines, "\n"), nil
+}
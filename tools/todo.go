@@ -401,6 +401,15 @@ func ClearTodos() string {
 	return fmt.Sprintf("🗑️ Cleared %d todos", count)
 }
 
+// RestoreTodos replaces the todo list, for resuming a saved task
+func RestoreTodos(items []TodoItem) {
+	globalTodoManager.mutex.Lock()
+	defer globalTodoManager.mutex.Unlock()
+
+	globalTodoManager.items = make([]TodoItem, len(items))
+	copy(globalTodoManager.items, items)
+}
+
 // ArchiveCompleted removes completed todos from active memory to reduce context bloat
 func ArchiveCompleted() string {
 	globalTodoManager.mutex.Lock()
@@ -1,8 +1,9 @@
 package tools
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,8 +18,7 @@ import (
 
 // Global variables for vision model tracking and caching
 var lastVisionUsage *VisionUsageInfo
-var visionCache = make(map[string]string) // cache key -> result
-var visionCacheUsage = make(map[string]*VisionUsageInfo) // cache key -> usage info
+var visionCacheSavings = make(map[string]*VisionUsageInfo) // cache key -> usage avoided by a cache hit
 
 // VisionAnalysis represents the result of vision model analysis
 type VisionAnalysis struct {
@@ -41,6 +41,7 @@ type UIElement struct {
 type VisionProcessor struct {
 	visionClient api.ClientInterface
 	debug        bool
+	refresh      bool // ignore cached analyses and re-analyze
 }
 
 // NewVisionProcessor creates a new vision processor
@@ -197,12 +198,21 @@ Be thorough but concise, focusing on actionable information.`
 	}
 }
 
+// refreshFlagPattern matches the --refresh flag in a query
+var refreshFlagPattern = regexp.MustCompile(`(^|\s)--refresh(\s|$)`)
+
 // ProcessImagesInText detects images in text and processes them with vision models
 func (vp *VisionProcessor) ProcessImagesInText(text string) (string, []VisionAnalysis, error) {
 	if vp.debug {
 		fmt.Println("🔍 Scanning text for image references...")
 	}
 
+	// --refresh in the query forces re-analysis of images that are cached
+	if refreshFlagPattern.MatchString(text) {
+		vp.refresh = true
+		text = strings.TrimSpace(refreshFlagPattern.ReplaceAllString(text, " "))
+	}
+
 	// Find image references in the text
 	images := vp.extractImageReferences(text)
 	if len(images) == 0 {
@@ -286,63 +296,38 @@ func (vp *VisionProcessor) extractImageReferences(text string) []string {
 	return unique
 }
 
-// analyzeImage processes a single image with the vision model
-func (vp *VisionProcessor) analyzeImage(imagePath string) (VisionAnalysis, error) {
-	// Download or read the image
-	imageData, err := vp.getImageData(imagePath)
-	if err != nil {
-		return VisionAnalysis{}, fmt.Errorf("failed to get image data: %w", err)
-	}
-
-	// Create vision analysis prompt
-	prompt := vp.createVisionPrompt(imagePath)
-
-	// Create message with image
-	messages := []api.Message{
-		{
-			Role:    "user",
-			Content: prompt,
-			Images:  []api.ImageData{{Base64: imageData, Type: "image/jpeg"}},
+// visionAnalysisSchema is the structured output requested from the vision model
+var visionAnalysisSchema = api.ResponseSchema{
+	Name: "vision_analysis",
+	Schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"description": map[string]interface{}{"type": "string", "description": "the full written analysis, following the requested sections"},
+			"elements": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"type":        map[string]interface{}{"type": "string"},
+						"description": map[string]interface{}{"type": "string"},
+						"position":    map[string]interface{}{"type": "string"},
+						"issues":      map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"type", "description", "position", "issues"},
+					"additionalProperties": false,
+				},
+			},
+			"issues":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"suggestions": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
 		},
-	}
-
-	// Get vision analysis using the vision-enabled method
-	response, err := vp.visionClient.SendVisionRequest(messages, nil, "")
-	if err != nil {
-		return VisionAnalysis{}, fmt.Errorf("vision request failed: %w", err)
-	}
-
-	// Store usage information for cost tracking
-	if response.Usage.TotalTokens > 0 {
-		lastVisionUsage = &VisionUsageInfo{
-			PromptTokens:     response.Usage.PromptTokens,
-			CompletionTokens: response.Usage.CompletionTokens,
-			TotalTokens:      response.Usage.TotalTokens,
-			EstimatedCost:    response.Usage.EstimatedCost,
-		}
-	}
-
-	// Extract response content
-	if len(response.Choices) == 0 {
-		return VisionAnalysis{}, fmt.Errorf("no response from vision model")
-	}
-
-	resultText := response.Choices[0].Message.Content
-
-	// Try to parse as JSON first, fall back to plain text
-	var analysis VisionAnalysis
-	if err := json.Unmarshal([]byte(resultText), &analysis); err != nil {
-		// If JSON parsing fails, use as plain description
-		analysis = VisionAnalysis{
-			ImagePath:   imagePath,
-			Description: resultText,
-		}
-	} else {
-		// Ensure image path is set
-		analysis.ImagePath = imagePath
-	}
+		"required":             []string{"description", "elements", "issues", "suggestions"},
+		"additionalProperties": false,
+	},
+}
 
-	return analysis, nil
+// analyzeImage processes a single image with the vision model
+func (vp *VisionProcessor) analyzeImage(imagePath string) (VisionAnalysis, error) {
+	return vp.analyzeImageWithPrompt(imagePath, "")
 }
 
 // analyzeImageWithPrompt analyzes an image with a custom prompt
@@ -359,6 +344,20 @@ func (vp *VisionProcessor) analyzeImageWithPrompt(imagePath string, customPrompt
 		prompt = vp.createVisionPrompt(imagePath)
 	}
 
+	// The same screenshot mentioned again is answered from the cache
+	model := vp.visionClient.GetProvider() + "/" + vp.visionClient.GetVisionModel()
+	cacheKey := visionCacheKey(imageData, model, prompt)
+	if !vp.refresh {
+		if entry, ok := loadVisionAnalysis(cacheKey); ok {
+			fmt.Printf("🔄 Using cached vision analysis for %s (unchanged image, use --refresh to re-analyze)\n", filepath.Base(imagePath))
+			if entry.Usage != nil {
+				visionCacheSavings[cacheKey] = entry.Usage
+			}
+			entry.Analysis.ImagePath = imagePath
+			return entry.Analysis, nil
+		}
+	}
+
 	// Create messages for the vision model
 	messages := []api.Message{
 		{
@@ -368,42 +367,32 @@ func (vp *VisionProcessor) analyzeImageWithPrompt(imagePath string, customPrompt
 		},
 	}
 
-	// Get vision analysis using the vision-enabled method
-	response, err := vp.visionClient.SendVisionRequest(messages, nil, "")
-	if err != nil {
-		return VisionAnalysis{}, fmt.Errorf("vision request failed: %w", err)
-	}
+	// Get a schema-validated analysis using the vision-enabled method
+	var analysis VisionAnalysis
+	response, err := api.RequestStructured(context.Background(), vp.visionClient, messages, visionAnalysisSchema, true, &analysis)
 
 	// Store usage information for cost tracking
-	if response.Usage.TotalTokens > 0 {
-		lastVisionUsage = &VisionUsageInfo{
+	var usage *VisionUsageInfo
+	if response != nil && response.Usage.TotalTokens > 0 {
+		usage = &VisionUsageInfo{
 			PromptTokens:     response.Usage.PromptTokens,
 			CompletionTokens: response.Usage.CompletionTokens,
 			TotalTokens:      response.Usage.TotalTokens,
 			EstimatedCost:    response.Usage.EstimatedCost,
 		}
+		lastVisionUsage = usage
 	}
 
-	// Extract response content
-	if len(response.Choices) == 0 {
-		return VisionAnalysis{}, fmt.Errorf("no response from vision model")
-	}
-
-	resultText := response.Choices[0].Message.Content
-
-	// Try to parse as JSON first, fall back to plain text
-	var analysis VisionAnalysis
-	if err := json.Unmarshal([]byte(resultText), &analysis); err != nil {
-		// If JSON parsing fails, use as plain description
-		analysis = VisionAnalysis{
-			ImagePath:   imagePath,
-			Description: resultText,
-		}
-	} else {
-		// Ensure image path is set
-		analysis.ImagePath = imagePath
+	if errors.Is(err, api.ErrInvalidStructuredOutput) && len(response.Choices) > 0 {
+		// The model answered, just not in the requested shape - keep its text
+		analysis = VisionAnalysis{Description: response.Choices[0].Message.Content}
+	} else if err != nil {
+		return VisionAnalysis{}, fmt.Errorf("vision request failed: %w", err)
+	} else if err := storeVisionAnalysis(cacheKey, model, analysis, usage); err != nil && vp.debug {
+		fmt.Printf("⚠️  Failed to cache vision analysis: %v\n", err)
 	}
 
+	analysis.ImagePath = imagePath
 	return analysis, nil
 }
 
@@ -674,10 +663,10 @@ func ClearLastVisionUsage() {
 // GetVisionCacheStats returns statistics about vision result caching
 func GetVisionCacheStats() map[string]interface{} {
 	stats := make(map[string]interface{})
-	stats["cached_results"] = len(visionCache)
+	stats["cached_results"] = len(visionCacheSavings)
 	
 	totalSavedCost := 0.0
-	for _, usage := range visionCacheUsage {
+	for _, usage := range visionCacheSavings {
 		totalSavedCost += usage.EstimatedCost
 	}
 	stats["estimated_savings"] = totalSavedCost
@@ -685,32 +674,19 @@ func GetVisionCacheStats() map[string]interface{} {
 	return stats
 }
 
-// AnalyzeImage is the tool function called by the agent for image analysis
-func AnalyzeImage(imagePath string, analysisPrompt string, analysisMode string) (string, error) {
+// AnalyzeImage is the tool function called by the agent for image analysis.
+// Results are cached by image content; refresh forces a new analysis.
+func AnalyzeImage(imagePath string, analysisPrompt string, analysisMode string, refresh bool) (string, error) {
 	if !HasVisionCapability() {
 		return "", fmt.Errorf("vision analysis not available - please set up OPENROUTER_API_KEY, DEEPINFRA_API_KEY, GROQ_API_KEY, or install Ollama with a vision model")
 	}
 
-	// Create cache key based on image path, mode, and prompt
-	cacheKey := fmt.Sprintf("%s|%s|%s", imagePath, analysisMode, analysisPrompt)
-	
-	// Check cache first
-	if cachedResult, exists := visionCache[cacheKey]; exists {
-		fmt.Printf("🔄 Using cached vision analysis for %s [%s]\n", filepath.Base(imagePath), analysisMode)
-		
-		// Restore cached usage info for cost tracking
-		if cachedUsage, hasUsage := visionCacheUsage[cacheKey]; hasUsage {
-			lastVisionUsage = cachedUsage
-		}
-		
-		return cachedResult, nil
-	}
-
 	// Create vision processor with appropriate model based on mode
 	processor, err := NewVisionProcessorWithMode(false, analysisMode) // debug = false
 	if err != nil {
 		return "", fmt.Errorf("failed to create vision processor: %w", err)
 	}
+	processor.refresh = refresh
 
 	// Determine the appropriate prompt based on analysis mode
 	var prompt string
@@ -754,11 +730,5 @@ func AnalyzeImage(imagePath string, analysisPrompt string, analysisMode string)
 		}
 	}
 
-	// Cache the result and usage info for future calls
-	visionCache[cacheKey] = result
-	if lastVisionUsage != nil {
-		visionCacheUsage[cacheKey] = lastVisionUsage
-	}
-
 	return result, nil
 }
\ No newline at end of file
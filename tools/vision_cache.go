@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// visionCacheEntry is a stored analysis of one image content
+type visionCacheEntry struct {
+	Model     string           `json:"model"`
+	Analysis  VisionAnalysis   `json:"analysis"`
+	Usage     *VisionUsageInfo `json:"usage,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// visionCacheKey identifies an analysis by image content, model and prompt, so a
+// screenshot that is mentioned again is not re-analyzed while an edited one is
+func visionCacheKey(imageData, model, prompt string) string {
+	hasher := sha256.New()
+	for _, part := range []string{imageData, model, prompt} {
+		hasher.Write([]byte(part))
+		hasher.Write([]byte{0})
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// visionCachePath returns the file holding the analysis for a cache key
+func visionCachePath(key string) (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "cache", "vision", key+".json"), nil
+}
+
+// loadVisionAnalysis returns a cached analysis, if there is one
+func loadVisionAnalysis(key string) (*visionCacheEntry, bool) {
+	path, err := visionCachePath(key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry visionCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// storeVisionAnalysis saves an analysis for later sessions
+func storeVisionAnalysis(key, model string, analysis VisionAnalysis, usage *VisionUsageInfo) error {
+	path, err := visionCachePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create vision cache directory: %w", err)
+	}
+	data, err := json.Marshal(visionCacheEntry{Model: model, Analysis: analysis, Usage: usage, CreatedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal vision analysis: %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alantheprice/coder/config"
+)
+
+// Speech-to-text providers
+const (
+	STTOpenAI    = "openai"     // OpenAI-compatible /audio/transcriptions endpoint
+	STTGroq      = "groq"       // Groq's OpenAI-compatible Whisper endpoint
+	STTWhisperCP = "whispercpp" // local whisper.cpp binary
+)
+
+// whisperBinaries are the names whisper.cpp's CLI is installed under
+var whisperBinaries = []string{"whisper-cli", "whisper-cpp", "whisper"}
+
+// VoiceConfig describes how speech is recorded and transcribed
+type VoiceConfig struct {
+	Provider     string // openai, groq or whispercpp
+	URL          string // transcription endpoint for openai/groq
+	Model        string // transcription model, or the ggml model file for whispercpp
+	MaxSeconds   int    // recording stops after this long at the latest
+	SilenceStops bool   // stop recording after two seconds of silence (requires sox)
+}
+
+// LoadVoiceConfig reads the voice settings from the config preferences
+func LoadVoiceConfig(cfg *config.Config) VoiceConfig {
+	voice := VoiceConfig{Provider: STTOpenAI, MaxSeconds: 60, SilenceStops: true}
+	if cfg != nil {
+		voice.Provider = cfg.GetStringPreference("voice_stt", voice.Provider)
+		voice.URL = cfg.GetStringPreference("voice_stt_url", "")
+		voice.Model = cfg.GetStringPreference("voice_stt_model", "")
+		voice.MaxSeconds = cfg.GetIntPreference("voice_max_seconds", voice.MaxSeconds)
+		voice.SilenceStops = cfg.GetBoolPreference("voice_silence_stops", voice.SilenceStops)
+	}
+
+	switch voice.Provider {
+	case STTGroq:
+		if voice.URL == "" {
+			voice.URL = "https://api.groq.com/openai/v1/audio/transcriptions"
+		}
+		if voice.Model == "" {
+			voice.Model = "whisper-large-v3-turbo"
+		}
+	case STTWhisperCP:
+		if configDir, err := config.GetConfigDir(); err == nil && voice.Model == "" {
+			voice.Model = filepath.Join(configDir, "models", "ggml-base.en.bin")
+		}
+	default:
+		if voice.URL == "" {
+			voice.URL = "https://api.openai.com/v1/audio/transcriptions"
+		}
+		if voice.Model == "" {
+			voice.Model = "whisper-1"
+		}
+	}
+	return voice
+}
+
+// RecordSpeech records from the default microphone into a 16kHz mono WAV file,
+// which is the format whisper.cpp expects. The caller removes the file.
+func RecordSpeech(cfg VoiceConfig) (string, error) {
+	file, err := os.CreateTemp("", "coder-voice-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to create recording file: %w", err)
+	}
+	path := file.Name()
+	file.Close()
+
+	cmd, err := recordCommand(path, cfg)
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("recording failed: %w\n%s", err, output)
+	}
+	return path, nil
+}
+
+// recordCommand picks an available recorder: sox's rec (which can stop on
+// silence), then arecord on Linux, then ffmpeg
+func recordCommand(path string, cfg VoiceConfig) (*exec.Cmd, error) {
+	seconds := strconv.Itoa(cfg.MaxSeconds)
+	if _, err := exec.LookPath("rec"); err == nil {
+		args := []string{"-q", "-c", "1", "-r", "16000", "-b", "16", path}
+		if cfg.SilenceStops {
+			args = append(args, "silence", "1", "0.1", "1%", "1", "2.0", "1%")
+		}
+		return exec.Command("rec", append(args, "trim", "0", seconds)...), nil
+	}
+	if _, err := exec.LookPath("arecord"); err == nil && runtime.GOOS == "linux" {
+		return exec.Command("arecord", "-q", "-f", "S16_LE", "-r", "16000", "-c", "1", "-d", seconds, path), nil
+	}
+	if _, err := exec.LookPath("ffmpeg"); err == nil {
+		input := []string{"-f", "alsa", "-i", "default"}
+		if runtime.GOOS == "darwin" {
+			input = []string{"-f", "avfoundation", "-i", ":0"}
+		}
+		args := append([]string{"-loglevel", "error", "-y"}, input...)
+		return exec.Command("ffmpeg", append(args, "-t", seconds, "-ac", "1", "-ar", "16000", path)...), nil
+	}
+	return nil, fmt.Errorf("no audio recorder found - install sox (rec), alsa-utils (arecord) or ffmpeg")
+}
+
+// Transcribe converts a recording to text with the configured provider
+func Transcribe(audioPath string, cfg VoiceConfig) (string, error) {
+	var text string
+	var err error
+	if cfg.Provider == STTWhisperCP {
+		text, err = transcribeWhisperCpp(audioPath, cfg)
+	} else {
+		text, err = transcribeRemote(audioPath, cfg)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// transcribeWhisperCpp runs a local whisper.cpp binary
+func transcribeWhisperCpp(audioPath string, cfg VoiceConfig) (string, error) {
+	if _, err := os.Stat(cfg.Model); err != nil {
+		return "", fmt.Errorf("whisper.cpp model not found at %s (set voice_stt_model): %w", cfg.Model, err)
+	}
+	for _, binary := range whisperBinaries {
+		if _, err := exec.LookPath(binary); err != nil {
+			continue
+		}
+		output, err := exec.Command(binary, "-m", cfg.Model, "-f", audioPath, "--no-timestamps", "--no-prints").Output()
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %w", binary, err)
+		}
+		return string(output), nil
+	}
+	return "", fmt.Errorf("whisper.cpp not found (tried %s)", strings.Join(whisperBinaries, ", "))
+}
+
+// transcribeRemote uploads the recording to an OpenAI-compatible transcription endpoint
+func transcribeRemote(audioPath string, cfg VoiceConfig) (string, error) {
+	account, envVar := "openai", "OPENAI_API_KEY"
+	if cfg.Provider == STTGroq {
+		account, envVar = "groq", "GROQ_API_KEY"
+	}
+	apiKey, err := config.GetSecret(account, envVar)
+	if err != nil || apiKey == "" {
+		return "", fmt.Errorf("%s is not set for %s transcription", envVar, cfg.Provider)
+	}
+
+	audio, err := os.ReadFile(audioPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to build upload: %w", err)
+	}
+	part.Write(audio)
+	form.WriteField("model", cfg.Model)
+	form.WriteField("response_format", "json")
+	form.Close()
+
+	req, err := http.NewRequest("POST", cfg.URL, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	client := http.Client{Timeout: 120 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse transcription: %w", err)
+	}
+	return result.Text, nil
+}
+
+// ListenForQuery records one spoken query and returns its transcription
+func ListenForQuery(cfg VoiceConfig) (string, error) {
+	if cfg.SilenceStops {
+		fmt.Printf("🎤 Listening... (stops after 2s of silence, max %ds)\n", cfg.MaxSeconds)
+	} else {
+		fmt.Printf("🎤 Listening for %ds...\n", cfg.MaxSeconds)
+	}
+	audioPath, err := RecordSpeech(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(audioPath)
+
+	fmt.Println("📝 Transcribing...")
+	return Transcribe(audioPath, cfg)
+}
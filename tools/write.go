@@ -7,30 +7,39 @@ import (
 )
 
 func WriteFile(filePath, content string) (string, error) {
+	result, _, err := WriteFileChange(filePath, content)
+	return result, err
+}
+
+// WriteFileChange writes a file like WriteFile and returns the record of
+// what the write changed
+func WriteFileChange(filePath, content string) (string, FileChange, error) {
 	if filePath == "" {
-		return "", fmt.Errorf("empty file path provided")
+		return "", FileChange{}, fmt.Errorf("empty file path provided")
 	}
 
 	// Clean the path
 	cleanPath := filepath.Clean(filePath)
+	before, readErr := os.ReadFile(cleanPath)
 
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(cleanPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+		return "", FileChange{}, fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
 	// Write the file
 	err := os.WriteFile(cleanPath, []byte(content), 0644)
 	if err != nil {
-		return "", fmt.Errorf("failed to write file %s: %w", cleanPath, err)
+		return "", FileChange{}, fmt.Errorf("failed to write file %s: %w", cleanPath, err)
 	}
+	change := DescribeChange(cleanPath, before, []byte(content), readErr == nil)
 
 	// Get file info for confirmation
 	info, err := os.Stat(cleanPath)
 	if err != nil {
-		return fmt.Sprintf("File %s written successfully", cleanPath), nil
+		return fmt.Sprintf("File %s written successfully", cleanPath), change, nil
 	}
 
-	return fmt.Sprintf("File %s written successfully (%d bytes)", cleanPath, info.Size()), nil
+	return fmt.Sprintf("File %s written successfully (%d bytes)", cleanPath, info.Size()), change, nil
 }
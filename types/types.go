@@ -1,5 +1,7 @@
 package types
 
+import "context"
+
 // ImageData represents an image in a message
 type ImageData struct {
 	URL    string `json:"url,omitempty"`    // URL to image
@@ -54,6 +56,8 @@ type Usage struct {
 	CompletionTokens int     `json:"completion_tokens"`
 	TotalTokens      int     `json:"total_tokens"`
 	EstimatedCost    float64 `json:"estimated_cost"`
+	Cost             float64 `json:"cost,omitempty"` // charged cost reported by OpenRouter's usage accounting
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens,omitempty"` // DeepSeek's name for cached prompt tokens
 	PromptTokensDetails struct {
 		CachedTokens     int `json:"cached_tokens"`
 		CacheWriteTokens *int `json:"cache_write_tokens"`
@@ -84,7 +88,7 @@ type ModelInfo struct {
 
 // ProviderInterface defines the interface that all providers must implement
 type ProviderInterface interface {
-	SendChatRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
+	SendChatRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
 	CheckConnection() error
 	SetDebug(debug bool)
 	SetModel(model string) error
@@ -93,5 +97,71 @@ type ProviderInterface interface {
 	GetModelContextLimit() (int, error)
 	ListModels() ([]ModelInfo, error)
 	SupportsVision() bool
-	SendVisionRequest(messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
-}
\ No newline at end of file
+	SendVisionRequest(ctx context.Context, messages []Message, tools []Tool, reasoning string) (*ChatResponse, error)
+}
+// ResponseSchema describes the JSON document a structured-output request must return
+type ResponseSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// ResponseFormat returns the OpenAI-compatible response_format payload for the schema
+func (s ResponseSchema) ResponseFormat() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   s.Name,
+			"strict": true,
+			"schema": s.Schema,
+		},
+	}
+}
+
+// StructuredOutputProvider is implemented by providers that can constrain the
+// next responses to a JSON schema. A nil format turns the constraint off.
+type StructuredOutputProvider interface {
+	SetResponseFormat(format map[string]interface{})
+}
+
+// StreamCallback receives a response's content and reasoning as they are generated
+type StreamCallback func(content, reasoning string)
+
+// StreamingProvider is implemented by providers that can stream responses.
+// The assembled response is returned once the stream ends.
+type StreamingProvider interface {
+	SendChatRequestStream(ctx context.Context, messages []Message, tools []Tool, reasoning string, onToken StreamCallback) (*ChatResponse, error)
+}
+
+// RequestParametersProvider is implemented by providers that merge extra
+// request parameters (temperature, top_p, stop, provider routing, ...) into
+// each chat request
+type RequestParametersProvider interface {
+	SetRequestParameters(params map[string]interface{})
+}
+
+// MergeRequestParameters applies profile parameters to a request body. The
+// model and messages are never replaced, and max_tokens only ever lowers the
+// context-aware limit so requests still fit the model's window.
+func MergeRequestParameters(body, params map[string]interface{}) {
+	for key, value := range params {
+		switch key {
+		case "model", "messages":
+			continue
+		case "max_tokens":
+			limit, ok := value.(float64)
+			if !ok {
+				if n, isInt := value.(int); isInt {
+					limit, ok = float64(n), true
+				}
+			}
+			if current, isInt := body["max_tokens"].(int); ok && isInt && int(limit) >= current {
+				continue
+			}
+			if ok {
+				body[key] = int(limit)
+			}
+			continue
+		}
+		body[key] = value
+	}
+}